@@ -0,0 +1,190 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dnsmessage
+
+import (
+	"errors"
+	"io"
+)
+
+// Errors returned by MatchResponse.
+//
+// An attacker may attempt to spoof responses to a query.
+// A resolver should ignore messages which fail to match its query
+// and continue to wait for a valid response.
+var (
+	ErrNotResponse      = errors.New("message is not a response")
+	ErrIDMismatch       = errors.New("response ID does not match query")
+	ErrOpCodeMismatch   = errors.New("response opcode does not match query")
+	ErrQuestionMismatch = errors.New("response question does not match query")
+)
+
+// MatchOptions configures MatchResponse.
+type MatchOptions struct {
+	// CaseSensitive requires the name in the response's question to
+	// match the name in the query exactly, including letter case.
+	//
+	// Set CaseSensitive when the query name was sent with randomized case
+	// (see RandomizeCase) to make spoofing responses more difficult.
+	// Not all servers preserve the case of names in questions.
+	CaseSensitive bool
+}
+
+// MatchResponse starts parsing msg with p and reports whether msg is a
+// response to the query with the header query and the single question q.
+//
+// It checks that msg is a response, that its ID and opcode match the query,
+// and that its question section consists of q alone.
+// On success, it returns the response header and leaves p positioned at the
+// start of the answer section.
+//
+// Transport-level checks, such as verifying that a UDP response arrived
+// from the address and port the query was sent to, are the caller's
+// responsibility.
+func MatchResponse(p *Parser, msg []byte, query Header, q Question, opts MatchOptions) (Header, error) {
+	h, err := p.Start(msg)
+	if err != nil {
+		return Header{}, err
+	}
+	if !h.Response {
+		return Header{}, ErrNotResponse
+	}
+	if h.ID != query.ID {
+		return Header{}, ErrIDMismatch
+	}
+	if h.OpCode != query.OpCode {
+		return Header{}, ErrOpCodeMismatch
+	}
+	rq, err := p.Question()
+	if err == ErrSectionDone {
+		return Header{}, ErrQuestionMismatch
+	}
+	if err != nil {
+		return Header{}, err
+	}
+	if rq.Type != q.Type || rq.Class != q.Class || !nameMatches(rq.Name, q.Name, opts.CaseSensitive) {
+		return Header{}, ErrQuestionMismatch
+	}
+	if _, err := p.Question(); err != ErrSectionDone {
+		if err == nil {
+			err = ErrQuestionMismatch
+		}
+		return Header{}, err
+	}
+	return h, nil
+}
+
+func nameMatches(a, b Name, caseSensitive bool) bool {
+	if a.Length != b.Length {
+		return false
+	}
+	for i := 0; i < int(a.Length); i++ {
+		x, y := a.Data[i], b.Data[i]
+		if !caseSensitive {
+			x, y = toLower(x), toLower(y)
+		}
+		if x != y {
+			return false
+		}
+	}
+	return true
+}
+
+func toLower(c byte) byte {
+	if 'A' <= c && c <= 'Z' {
+		return c + ('a' - 'A')
+	}
+	return c
+}
+
+// RandomizeCase returns a copy of n with the case of each ASCII letter
+// chosen using bits read from rand, typically crypto/rand.Reader.
+//
+// Servers generally copy the question name from a query to the response,
+// so a resolver which randomizes the case of query names and checks for
+// the same case in responses gains additional protection against spoofed
+// responses. This technique is sometimes called "DNS 0x20".
+func RandomizeCase(n Name, rand io.Reader) (Name, error) {
+	var bits [(len(n.Data) + 7) / 8]byte
+	if _, err := io.ReadFull(rand, bits[:(int(n.Length)+7)/8]); err != nil {
+		return Name{}, err
+	}
+	for i := 0; i < int(n.Length); i++ {
+		c := toLower(n.Data[i])
+		if 'a' <= c && c <= 'z' && bits[i/8]&(1<<(i%8)) != 0 {
+			c -= 'a' - 'A'
+		}
+		n.Data[i] = c
+	}
+	return n, nil
+}
+
+// A ResponseClass classifies a DNS response by how a stub resolver
+// should act on it.
+type ResponseClass int
+
+const (
+	// ResponseSuccess is a successful response.
+	// It may contain no answers of the requested type.
+	ResponseSuccess ResponseClass = iota
+
+	// ResponseTruncated is a response which did not fit in a UDP datagram.
+	// The query should be retried over TCP.
+	ResponseTruncated
+
+	// ResponseNameError is a response indicating that the queried
+	// name does not exist (NXDOMAIN).
+	ResponseNameError
+
+	// ResponseServerFailure is a response indicating that the server
+	// failed to answer the query (SERVFAIL).
+	// The query may succeed if retried, possibly with another server.
+	ResponseServerFailure
+
+	// ResponseRejected is a response indicating that the server did not
+	// accept the query (REFUSED, NOTIMP, or FORMERR).
+	// The query should be sent to another server.
+	ResponseRejected
+
+	// ResponseUnknownError is a response with an unrecognized error code.
+	ResponseUnknownError
+)
+
+var responseClassNames = map[ResponseClass]string{
+	ResponseSuccess:       "ResponseSuccess",
+	ResponseTruncated:     "ResponseTruncated",
+	ResponseNameError:     "ResponseNameError",
+	ResponseServerFailure: "ResponseServerFailure",
+	ResponseRejected:      "ResponseRejected",
+	ResponseUnknownError:  "ResponseUnknownError",
+}
+
+// String implements fmt.Stringer.String.
+func (c ResponseClass) String() string {
+	if n, ok := responseClassNames[c]; ok {
+		return n
+	}
+	return printUint16(uint16(c))
+}
+
+// ClassifyResponse classifies a response based on its header.
+// A truncated response is ResponseTruncated regardless of its RCode.
+func ClassifyResponse(h Header) ResponseClass {
+	if h.Truncated {
+		return ResponseTruncated
+	}
+	switch h.RCode {
+	case RCodeSuccess:
+		return ResponseSuccess
+	case RCodeNameError:
+		return ResponseNameError
+	case RCodeServerFailure:
+		return ResponseServerFailure
+	case RCodeRefused, RCodeNotImplemented, RCodeFormatError:
+		return ResponseRejected
+	default:
+		return ResponseUnknownError
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dnsmessage
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestMatchResponse(t *testing.T) {
+	queryHeader := Header{ID: 0x1234, RecursionDesired: true}
+	q := Question{
+		Name:  MustNewName("ExAmple.com."),
+		Type:  TypeA,
+		Class: ClassINET,
+	}
+	answer := Resource{
+		Header: ResourceHeader{
+			Name:   q.Name,
+			Type:   TypeA,
+			Class:  ClassINET,
+			Length: 4,
+		},
+		Body: &AResource{[4]byte{127, 0, 0, 1}},
+	}
+	build := func(f func(m *Message)) []byte {
+		m := Message{
+			Header: Header{
+				ID:       queryHeader.ID,
+				Response: true,
+			},
+			Questions: []Question{q},
+			Answers:   []Resource{answer},
+		}
+		f(&m)
+		b, err := m.Pack()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+
+	for _, test := range []struct {
+		desc string
+		msg  []byte
+		opts MatchOptions
+		want error
+	}{{
+		desc: "match",
+		msg:  build(func(m *Message) {}),
+	}, {
+		desc: "name case differs",
+		msg: build(func(m *Message) {
+			m.Questions[0].Name = MustNewName("example.COM.")
+		}),
+	}, {
+		desc: "name case differs, case sensitive",
+		msg: build(func(m *Message) {
+			m.Questions[0].Name = MustNewName("example.COM.")
+		}),
+		opts: MatchOptions{CaseSensitive: true},
+		want: ErrQuestionMismatch,
+	}, {
+		desc: "name case matches, case sensitive",
+		msg:  build(func(m *Message) {}),
+		opts: MatchOptions{CaseSensitive: true},
+	}, {
+		desc: "not a response",
+		msg: build(func(m *Message) {
+			m.Header.Response = false
+		}),
+		want: ErrNotResponse,
+	}, {
+		desc: "ID mismatch",
+		msg: build(func(m *Message) {
+			m.Header.ID++
+		}),
+		want: ErrIDMismatch,
+	}, {
+		desc: "opcode mismatch",
+		msg: build(func(m *Message) {
+			m.Header.OpCode = 2
+		}),
+		want: ErrOpCodeMismatch,
+	}, {
+		desc: "different name",
+		msg: build(func(m *Message) {
+			m.Questions[0].Name = MustNewName("example.org.")
+		}),
+		want: ErrQuestionMismatch,
+	}, {
+		desc: "different type",
+		msg: build(func(m *Message) {
+			m.Questions[0].Type = TypeAAAA
+		}),
+		want: ErrQuestionMismatch,
+	}, {
+		desc: "different class",
+		msg: build(func(m *Message) {
+			m.Questions[0].Class = ClassCHAOS
+		}),
+		want: ErrQuestionMismatch,
+	}, {
+		desc: "no question",
+		msg: build(func(m *Message) {
+			m.Questions = nil
+		}),
+		want: ErrQuestionMismatch,
+	}, {
+		desc: "extra question",
+		msg: build(func(m *Message) {
+			m.Questions = append(m.Questions, q)
+		}),
+		want: ErrQuestionMismatch,
+	}} {
+		t.Run(test.desc, func(t *testing.T) {
+			var p Parser
+			h, err := MatchResponse(&p, test.msg, queryHeader, q, test.opts)
+			if err != test.want {
+				t.Fatalf("MatchResponse() = %v, want %v", err, test.want)
+			}
+			if err != nil {
+				return
+			}
+			if h.ID != queryHeader.ID || !h.Response {
+				t.Errorf("MatchResponse() header = %+v", h)
+			}
+			got, err := p.Answer()
+			if err != nil {
+				t.Fatalf("p.Answer() = %v", err)
+			}
+			if got.GoString() != answer.GoString() {
+				t.Errorf("p.Answer() = %v, want %v", got.GoString(), answer.GoString())
+			}
+		})
+	}
+}
+
+func TestMatchResponseTruncatedMessage(t *testing.T) {
+	var p Parser
+	if _, err := MatchResponse(&p, []byte{0, 1}, Header{}, Question{}, MatchOptions{}); err == nil {
+		t.Error("MatchResponse(short message) succeeded, want error")
+	}
+}
+
+func TestRandomizeCase(t *testing.T) {
+	n := MustNewName("Example-1.com.")
+	got, err := RandomizeCase(n, bytes.NewReader([]byte{0b10101010, 0xff}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "eXaMpLe-1.COM."; got.String() != want {
+		t.Errorf("RandomizeCase(%v) = %v, want %v", n, got, want)
+	}
+	if !nameMatches(got, n, false) {
+		t.Errorf("RandomizeCase(%v) = %v, does not match case-insensitively", n, got)
+	}
+
+	if _, err := RandomizeCase(n, strings.NewReader("")); err == nil {
+		t.Error("RandomizeCase with empty reader succeeded, want error")
+	}
+}
+
+func TestClassifyResponse(t *testing.T) {
+	for _, test := range []struct {
+		h    Header
+		want ResponseClass
+	}{
+		{Header{RCode: RCodeSuccess}, ResponseSuccess},
+		{Header{RCode: RCodeSuccess, Truncated: true}, ResponseTruncated},
+		{Header{RCode: RCodeServerFailure, Truncated: true}, ResponseTruncated},
+		{Header{RCode: RCodeNameError}, ResponseNameError},
+		{Header{RCode: RCodeServerFailure}, ResponseServerFailure},
+		{Header{RCode: RCodeRefused}, ResponseRejected},
+		{Header{RCode: RCodeNotImplemented}, ResponseRejected},
+		{Header{RCode: RCodeFormatError}, ResponseRejected},
+		{Header{RCode: 9}, ResponseUnknownError},
+	} {
+		if got := ClassifyResponse(test.h); got != test.want {
+			t.Errorf("ClassifyResponse(%+v) = %v, want %v", test.h, got, test.want)
+		}
+	}
+}
+
+func TestResponseClassString(t *testing.T) {
+	if got, want := ResponseNameError.String(), "ResponseNameError"; got != want {
+		t.Errorf("ResponseNameError.String() = %q, want %q", got, want)
+	}
+	if got, want := ResponseClass(42).String(), "42"; got != want {
+		t.Errorf("ResponseClass(42).String() = %q, want %q", got, want)
+	}
+}
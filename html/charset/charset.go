@@ -45,8 +45,35 @@ func (h *htmlEncoding) NewEncoder() *encoding.Encoder {
 	return encoding.HTMLEscapeUnsupported(h.Encoding.NewEncoder())
 }
 
+// Confidence describes how reliable an encoding determination is.
+// Higher values are more reliable.
+type Confidence int
+
+const (
+	// ConfidenceNone indicates that no encoding was determined.
+	ConfidenceNone Confidence = iota
+
+	// ConfidenceFallback indicates that the encoding is a fallback default,
+	// chosen without any evidence from the document.
+	ConfidenceFallback
+
+	// ConfidenceHeuristic indicates that the encoding was guessed
+	// by examining the document's content.
+	ConfidenceHeuristic
+
+	// ConfidenceTentative indicates that the encoding was declared by the document,
+	// in a <meta> element. A parser may later find the declaration to be wrong.
+	ConfidenceTentative
+
+	// ConfidenceCertain indicates that the encoding was determined by a byte order mark
+	// or by the transport layer's Content-Type.
+	ConfidenceCertain
+)
+
 // DetermineEncoding determines the encoding of an HTML document by examining
 // up to the first 1024 bytes of content and the declared Content-Type.
+// It applies SniffBOM, FromContentType, PrescanMeta, and GuessEncoding in turn,
+// and returns the first encoding found.
 //
 // See http://www.whatwg.org/specs/web-apps/current-work/multipage/parsing.html#determining-the-character-encoding
 func DetermineEncoding(content []byte, contentType string) (e encoding.Encoding, name string, certain bool) {
@@ -54,29 +81,71 @@ func DetermineEncoding(content []byte, contentType string) (e encoding.Encoding,
 		content = content[:1024]
 	}
 
+	var conf Confidence
+	if e, name, conf = SniffBOM(content); e != nil {
+		return e, name, conf == ConfidenceCertain
+	}
+	if e, name, conf = FromContentType(contentType); e != nil {
+		return e, name, conf == ConfidenceCertain
+	}
+	if e, name, conf = PrescanMeta(content); e != nil {
+		return e, name, conf == ConfidenceCertain
+	}
+	e, name, conf = GuessEncoding(content)
+	return e, name, conf == ConfidenceCertain
+}
+
+// SniffBOM returns the encoding indicated by a byte order mark
+// at the start of content, with ConfidenceCertain.
+// It returns nil, "", ConfidenceNone if content does not begin with a byte order mark.
+func SniffBOM(content []byte) (e encoding.Encoding, name string, conf Confidence) {
 	for _, b := range boms {
 		if bytes.HasPrefix(content, b.bom) {
 			e, name = Lookup(b.enc)
-			return e, name, true
+			return e, name, ConfidenceCertain
 		}
 	}
+	return nil, "", ConfidenceNone
+}
 
+// FromContentType returns the encoding named by the charset parameter
+// of an HTTP Content-Type header value, with ConfidenceCertain.
+// It returns nil, "", ConfidenceNone if contentType has no charset parameter
+// or names an unknown encoding.
+func FromContentType(contentType string) (e encoding.Encoding, name string, conf Confidence) {
 	if _, params, err := mime.ParseMediaType(contentType); err == nil {
 		if cs, ok := params["charset"]; ok {
 			if e, name = Lookup(cs); e != nil {
-				return e, name, true
+				return e, name, ConfidenceCertain
 			}
 		}
 	}
+	return nil, "", ConfidenceNone
+}
 
-	if len(content) > 0 {
-		e, name = prescan(content)
-		if e != nil {
-			return e, name, false
-		}
+// PrescanMeta returns the encoding declared by a <meta> element in up to
+// the first 1024 bytes of content, with ConfidenceTentative.
+// A declaration of UTF-16 is reported as UTF-8, as the WHATWG algorithm requires.
+// It returns nil, "", ConfidenceNone if no declaration is found.
+func PrescanMeta(content []byte) (e encoding.Encoding, name string, conf Confidence) {
+	if len(content) > 1024 {
+		content = content[:1024]
+	}
+	if len(content) == 0 {
+		return nil, "", ConfidenceNone
 	}
+	if e, name = prescan(content); e != nil {
+		return e, name, ConfidenceTentative
+	}
+	return nil, "", ConfidenceNone
+}
 
-	// Try to detect UTF-8.
+// GuessEncoding guesses the encoding of content when it carries no
+// encoding information. It reports UTF-8 with ConfidenceHeuristic if
+// content contains non-ASCII bytes and is valid UTF-8, ignoring a partial
+// rune at the end. Otherwise, it reports windows-1252 with ConfidenceFallback.
+// GuessEncoding always returns a non-nil encoding.
+func GuessEncoding(content []byte) (e encoding.Encoding, name string, conf Confidence) {
 	// First eliminate any partial rune at the end.
 	for i := len(content) - 1; i >= 0 && i > len(content)-4; i-- {
 		b := content[i]
@@ -96,11 +165,11 @@ func DetermineEncoding(content []byte, contentType string) (e encoding.Encoding,
 		}
 	}
 	if hasHighBit && utf8.Valid(content) {
-		return encoding.Nop, "utf-8", false
+		return encoding.Nop, "utf-8", ConfidenceHeuristic
 	}
 
 	// TODO: change default depending on user's locale?
-	return charmap.Windows1252, "windows-1252", false
+	return charmap.Windows1252, "windows-1252", ConfidenceFallback
 }
 
 // NewReader returns an io.Reader that converts the content of r to UTF-8.
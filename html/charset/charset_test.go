@@ -156,6 +156,118 @@ func TestSniff(t *testing.T) {
 	}
 }
 
+func TestSniffSteps(t *testing.T) {
+	for _, tc := range []struct {
+		desc     string
+		step     func() (string, Confidence)
+		wantName string
+		wantConf Confidence
+	}{{
+		desc: "SniffBOM utf-8",
+		step: func() (string, Confidence) {
+			_, name, conf := SniffBOM([]byte("\xef\xbb\xbf<html>"))
+			return name, conf
+		},
+		wantName: "utf-8",
+		wantConf: ConfidenceCertain,
+	}, {
+		desc: "SniffBOM utf-16le",
+		step: func() (string, Confidence) {
+			_, name, conf := SniffBOM([]byte("\xff\xfe<\x00"))
+			return name, conf
+		},
+		wantName: "utf-16le",
+		wantConf: ConfidenceCertain,
+	}, {
+		desc: "SniffBOM none",
+		step: func() (string, Confidence) {
+			_, name, conf := SniffBOM([]byte("<html>"))
+			return name, conf
+		},
+		wantName: "",
+		wantConf: ConfidenceNone,
+	}, {
+		desc: "FromContentType charset",
+		step: func() (string, Confidence) {
+			_, name, conf := FromContentType("text/html; charset=ISO-8859-15")
+			return name, conf
+		},
+		wantName: "iso-8859-15",
+		wantConf: ConfidenceCertain,
+	}, {
+		desc: "FromContentType no charset",
+		step: func() (string, Confidence) {
+			_, name, conf := FromContentType("text/html")
+			return name, conf
+		},
+		wantName: "",
+		wantConf: ConfidenceNone,
+	}, {
+		desc: "FromContentType unknown charset",
+		step: func() (string, Confidence) {
+			_, name, conf := FromContentType("text/html; charset=no-such-charset")
+			return name, conf
+		},
+		wantName: "",
+		wantConf: ConfidenceNone,
+	}, {
+		desc: "PrescanMeta charset",
+		step: func() (string, Confidence) {
+			_, name, conf := PrescanMeta([]byte(`<meta charset="shift_jis">`))
+			return name, conf
+		},
+		wantName: "shift_jis",
+		wantConf: ConfidenceTentative,
+	}, {
+		desc: "PrescanMeta utf-16",
+		step: func() (string, Confidence) {
+			_, name, conf := PrescanMeta([]byte(`<meta charset="utf-16">`))
+			return name, conf
+		},
+		wantName: "utf-8",
+		wantConf: ConfidenceTentative,
+	}, {
+		desc: "PrescanMeta beyond 1024 bytes",
+		step: func() (string, Confidence) {
+			content := strings.Repeat(" ", 1024) + `<meta charset="shift_jis">`
+			_, name, conf := PrescanMeta([]byte(content))
+			return name, conf
+		},
+		wantName: "",
+		wantConf: ConfidenceNone,
+	}, {
+		desc: "GuessEncoding utf-8",
+		step: func() (string, Confidence) {
+			// Ends with a partial rune.
+			_, name, conf := GuessEncoding([]byte("R\xc3\xa9sum\xc3\xa9 \xe6\x97"))
+			return name, conf
+		},
+		wantName: "utf-8",
+		wantConf: ConfidenceHeuristic,
+	}, {
+		desc: "GuessEncoding ascii",
+		step: func() (string, Confidence) {
+			_, name, conf := GuessEncoding([]byte("hello"))
+			return name, conf
+		},
+		wantName: "windows-1252",
+		wantConf: ConfidenceFallback,
+	}, {
+		desc: "GuessEncoding invalid utf-8",
+		step: func() (string, Confidence) {
+			_, name, conf := GuessEncoding([]byte("R\xe9sum\xe9"))
+			return name, conf
+		},
+		wantName: "windows-1252",
+		wantConf: ConfidenceFallback,
+	}} {
+		name, conf := tc.step()
+		if name != tc.wantName || conf != tc.wantConf {
+			t.Errorf("%s: got %q, %v; want %q, %v", tc.desc, name, conf, tc.wantName, tc.wantConf)
+		}
+	}
+}
+
 func TestReader(t *testing.T) {
 	switch runtime.GOOS {
 	case "nacl": // platforms that don't permit direct file system access
@@ -0,0 +1,269 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package links extracts references to other resources from parsed HTML
+// documents.
+//
+// It handles the details of locating URLs in HTML: attributes on the
+// various elements which refer to other resources, image candidate
+// strings in srcset attributes, the content of meta refresh elements,
+// and the document's base URL.
+package links // import "golang.org/x/net/html/links"
+
+import (
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// A Link is a reference from an HTML document to another resource.
+type Link struct {
+	// Node is the element containing the reference.
+	Node *html.Node
+
+	// Attr is the name of the attribute containing the reference,
+	// such as "href" or "srcset".
+	Attr string
+
+	// URL is the referenced URL, resolved against the document's base URL.
+	URL *url.URL
+
+	// Descriptor is the image candidate's descriptor for references
+	// in srcset attributes, such as "2x" or "100w".
+	// It is empty for other references and for candidates without a descriptor.
+	Descriptor string
+}
+
+// linkAttrs maps element names to the attributes which contain URLs.
+var linkAttrs = map[atom.Atom][]string{
+	atom.A:      {"href"},
+	atom.Area:   {"href"},
+	atom.Link:   {"href"},
+	atom.Script: {"src"},
+	atom.Img:    {"src", "srcset"},
+	atom.Source: {"src", "srcset"},
+	atom.Iframe: {"src"},
+	atom.Embed:  {"src"},
+	atom.Audio:  {"src"},
+	atom.Video:  {"src", "poster"},
+	atom.Track:  {"src"},
+	atom.Form:   {"action"},
+}
+
+// Extract returns the references in the document rooted at doc,
+// in document order.
+//
+// Relative references are resolved against the document's base URL,
+// which is the href of the document's first base element resolved against
+// docURL. If docURL is nil, references are resolved against the base element's
+// href only, and are left relative if there is none.
+// References which are not valid URLs are omitted.
+func Extract(doc *html.Node, docURL *url.URL) []Link {
+	base := docURL
+	if n := findBase(doc); n != nil {
+		if u, err := url.Parse(strings.Trim(attr(n, "href"), whitespace)); err == nil {
+			base = resolve(docURL, u)
+		}
+	}
+
+	var links []Link
+	add := func(n *html.Node, key, ref, descriptor string) {
+		ref = strings.Trim(ref, whitespace)
+		if ref == "" {
+			return
+		}
+		u, err := url.Parse(ref)
+		if err != nil {
+			return
+		}
+		links = append(links, Link{
+			Node:       n,
+			Attr:       key,
+			URL:        resolve(base, u),
+			Descriptor: descriptor,
+		})
+	}
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Namespace == "" {
+			for _, key := range linkAttrs[n.DataAtom] {
+				v, ok := lookupAttr(n, key)
+				if !ok {
+					continue
+				}
+				if key == "srcset" {
+					for _, c := range ParseSrcset(v) {
+						add(n, key, c.URL, c.Descriptor)
+					}
+					continue
+				}
+				add(n, key, v, "")
+			}
+			if n.DataAtom == atom.Meta && strings.EqualFold(attr(n, "http-equiv"), "refresh") {
+				if _, ref, ok := ParseRefresh(attr(n, "content")); ok {
+					add(n, "content", ref, "")
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	return links
+}
+
+// findBase returns the first base element with an href attribute in n.
+func findBase(n *html.Node) *html.Node {
+	if n.Type == html.ElementNode && n.DataAtom == atom.Base && n.Namespace == "" {
+		if _, ok := lookupAttr(n, "href"); ok {
+			return n
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if b := findBase(c); b != nil {
+			return b
+		}
+	}
+	return nil
+}
+
+func resolve(base, u *url.URL) *url.URL {
+	if base == nil {
+		return u
+	}
+	return base.ResolveReference(u)
+}
+
+func lookupAttr(n *html.Node, key string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Namespace == "" && a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+func attr(n *html.Node, key string) string {
+	v, _ := lookupAttr(n, key)
+	return v
+}
+
+// whitespace is the set of ASCII whitespace characters.
+// https://infra.spec.whatwg.org/#ascii-whitespace
+const whitespace = " \t\n\f\r"
+
+// An ImageCandidate is an entry in a srcset attribute.
+type ImageCandidate struct {
+	URL string
+
+	// Descriptor is the candidate's width or pixel density descriptor,
+	// such as "100w" or "2x", or empty if the candidate has no descriptor.
+	Descriptor string
+}
+
+// ParseSrcset parses the value of a srcset attribute.
+// It follows the parsing algorithm in the HTML specification,
+// and does not validate descriptors.
+//
+// https://html.spec.whatwg.org/multipage/images.html#parse-a-srcset-attribute
+func ParseSrcset(s string) []ImageCandidate {
+	var candidates []ImageCandidate
+	for {
+		s = strings.TrimLeft(s, whitespace+",")
+		if s == "" {
+			return candidates
+		}
+		i := strings.IndexAny(s, whitespace)
+		if i < 0 {
+			i = len(s)
+		}
+		u := s[:i]
+		s = s[i:]
+		var descriptor string
+		if strings.HasSuffix(u, ",") {
+			// A URL ending in a comma has no descriptors.
+			u = strings.TrimRight(u, ",")
+		} else {
+			// Descriptors run until a comma which is not in parentheses.
+			depth := 0
+			j := 0
+		descriptors:
+			for ; j < len(s); j++ {
+				switch s[j] {
+				case '(':
+					depth++
+				case ')':
+					if depth > 0 {
+						depth--
+					}
+				case ',':
+					if depth == 0 {
+						break descriptors
+					}
+				}
+			}
+			descriptor = strings.Trim(s[:j], whitespace)
+			s = s[j:]
+		}
+		if u != "" {
+			candidates = append(candidates, ImageCandidate{
+				URL:        u,
+				Descriptor: descriptor,
+			})
+		}
+	}
+}
+
+// ParseRefresh parses the content attribute of a meta refresh element,
+// such as "5; url=/next".
+// It returns the number of seconds to wait before refreshing, and the URL to
+// load, which is empty if the content only specifies a time. It reports
+// whether the content is valid.
+//
+// https://html.spec.whatwg.org/multipage/semantics.html#shared-declarative-refresh-steps
+func ParseRefresh(content string) (seconds int, ref string, ok bool) {
+	s := strings.TrimLeft(content, whitespace)
+	i := 0
+	for i < len(s) && '0' <= s[i] && s[i] <= '9' {
+		if seconds < 1<<30 {
+			seconds = seconds*10 + int(s[i]-'0')
+		}
+		i++
+	}
+	if i == 0 && !strings.HasPrefix(s, ".") {
+		return 0, "", false
+	}
+	for i < len(s) && (s[i] == '.' || ('0' <= s[i] && s[i] <= '9')) {
+		i++
+	}
+	s = s[i:]
+	if s == "" {
+		return seconds, "", true
+	}
+	if s[0] != ';' && s[0] != ',' && !strings.ContainsRune(whitespace, rune(s[0])) {
+		return 0, "", false
+	}
+	s = strings.TrimLeft(s, whitespace)
+	if s != "" && (s[0] == ';' || s[0] == ',') {
+		s = strings.TrimLeft(s[1:], whitespace)
+	}
+	// The URL may be preceded by "url=".
+	if len(s) >= 3 && strings.EqualFold(s[:3], "url") {
+		rest := strings.TrimLeft(s[3:], whitespace)
+		if strings.HasPrefix(rest, "=") {
+			s = strings.TrimLeft(rest[1:], whitespace)
+		}
+	}
+	if s != "" && (s[0] == '"' || s[0] == '\'') {
+		quote := s[0]
+		s = s[1:]
+		if i := strings.IndexByte(s, quote); i >= 0 {
+			s = s[:i]
+		}
+	}
+	return seconds, strings.TrimRight(s, whitespace), true
+}
@@ -0,0 +1,183 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package links
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestExtract(t *testing.T) {
+	for _, test := range []struct {
+		desc   string
+		docURL string
+		html   string
+		want   []string // attr: URL [descriptor]
+	}{{
+		desc:   "relative references",
+		docURL: "https://example.com/dir/page.html",
+		html: `<a href="other.html">x</a><a href=" /abs ">y</a>` +
+			`<img src="img.png"><script src="//cdn.example.com/s.js"></script>` +
+			`<link rel="stylesheet" href="../style.css">`,
+		want: []string{
+			"href: https://example.com/dir/other.html",
+			"href: https://example.com/abs",
+			"src: https://example.com/dir/img.png",
+			"src: https://cdn.example.com/s.js",
+			"href: https://example.com/style.css",
+		},
+	}, {
+		desc:   "base element",
+		docURL: "https://example.com/dir/page.html",
+		html: `<head><base href="/other/"><base href="/ignored/"></head>` +
+			`<a href="x.html">x</a>`,
+		want: []string{
+			"href: https://example.com/other/x.html",
+		},
+	}, {
+		desc: "no document URL",
+		html: `<a href="x.html">x</a>`,
+		want: []string{
+			"href: x.html",
+		},
+	}, {
+		desc:   "srcset",
+		docURL: "https://example.com/",
+		html:   `<img srcset="a.png, b.png 2x,c.png 100w">`,
+		want: []string{
+			"srcset: https://example.com/a.png",
+			"srcset: https://example.com/b.png 2x",
+			"srcset: https://example.com/c.png 100w",
+		},
+	}, {
+		desc:   "meta refresh",
+		docURL: "https://example.com/dir/",
+		html:   `<meta http-equiv="Refresh" content="5; URL='next.html'">`,
+		want: []string{
+			"content: https://example.com/dir/next.html",
+		},
+	}, {
+		desc:   "empty and invalid references",
+		docURL: "https://example.com/",
+		html:   `<a href="">x</a><a href="http://[::1">y</a><a>z</a><svg><a href="svg.html"></a></svg>`,
+		want:   nil,
+	}} {
+		t.Run(test.desc, func(t *testing.T) {
+			doc, err := html.Parse(strings.NewReader(test.html))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var docURL *url.URL
+			if test.docURL != "" {
+				docURL, err = url.Parse(test.docURL)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			var got []string
+			for _, l := range Extract(doc, docURL) {
+				s := l.Attr + ": " + l.URL.String()
+				if l.Descriptor != "" {
+					s += " " + l.Descriptor
+				}
+				got = append(got, s)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Extract:\ngot  %q\nwant %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseSrcset(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want []ImageCandidate
+	}{{
+		in:   "",
+		want: nil,
+	}, {
+		in:   "a.png",
+		want: []ImageCandidate{{URL: "a.png"}},
+	}, {
+		in: " a.png 1x , b.png  2x ",
+		want: []ImageCandidate{
+			{URL: "a.png", Descriptor: "1x"},
+			{URL: "b.png", Descriptor: "2x"},
+		},
+	}, {
+		// Commas are permitted in URLs.
+		in: "a,b.png 1x, c.png,",
+		want: []ImageCandidate{
+			{URL: "a,b.png", Descriptor: "1x"},
+			{URL: "c.png"},
+		},
+	}, {
+		in: "a.png, b.png",
+		want: []ImageCandidate{
+			{URL: "a.png"},
+			{URL: "b.png"},
+		},
+	}, {
+		// Commas in parentheses do not end descriptors.
+		in: "a.png future(1, 2), b.png 2x",
+		want: []ImageCandidate{
+			{URL: "a.png", Descriptor: "future(1, 2)"},
+			{URL: "b.png", Descriptor: "2x"},
+		},
+	}} {
+		if got := ParseSrcset(test.in); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ParseSrcset(%q) = %q; want %q", test.in, got, test.want)
+		}
+	}
+}
+
+func TestParseRefresh(t *testing.T) {
+	for _, test := range []struct {
+		in          string
+		wantSeconds int
+		wantRef     string
+		wantOK      bool
+	}{
+		{"5", 5, "", true},
+		{" 0 ", 0, "", true},
+		{"1.5; url=/next", 1, "/next", true},
+		{"3;URL = 'a b.html'", 3, "a b.html", true},
+		{`3, "quoted.html" trailing`, 3, "quoted.html", true},
+		{"0 /path", 0, "/path", true},
+		{".5;/p", 0, "/p", true},
+		{"", 0, "", false},
+		{"url=/next", 0, "", false},
+		{"5x", 0, "", false},
+	} {
+		seconds, ref, ok := ParseRefresh(test.in)
+		if seconds != test.wantSeconds || ref != test.wantRef || ok != test.wantOK {
+			t.Errorf("ParseRefresh(%q) = %v, %q, %v; want %v, %q, %v", test.in,
+				seconds, ref, ok, test.wantSeconds, test.wantRef, test.wantOK)
+		}
+	}
+}
+
+func ExampleExtract() {
+	s := `<base href="/docs/"><a href="intro.html">Intro</a><img srcset="small.png, large.png 2x">`
+	doc, err := html.Parse(strings.NewReader(s))
+	if err != nil {
+		log.Fatal(err)
+	}
+	docURL, _ := url.Parse("https://example.com/index.html")
+	for _, l := range Extract(doc, docURL) {
+		fmt.Println(l.Node.Data, l.Attr, l.URL)
+	}
+	// Output:
+	// a href https://example.com/docs/intro.html
+	// img srcset https://example.com/docs/small.png
+	// img srcset https://example.com/docs/large.png
+}
@@ -16,6 +16,10 @@ const inflowMinRefresh = 4 << 10
 type inflow struct {
 	avail  int32
 	unsent int32
+
+	// withheld is window capacity not returned to the peer
+	// while the server is under memory pressure.
+	withheld int32
 }
 
 // init sets the initial window.
@@ -52,6 +56,28 @@ func (f *inflow) add(n int) (connAdd int32) {
 	return int32(unsent)
 }
 
+// withhold limits an n-byte window update so that the peer's window
+// does not exceed max bytes, and records the excess to be returned by release.
+// It returns the permitted update.
+func (f *inflow) withhold(n int, max int32) int {
+	room := int64(max) - int64(f.avail) - int64(f.unsent)
+	if room < 0 {
+		room = 0
+	}
+	if int64(n) <= room {
+		return n
+	}
+	f.withheld += int32(int64(n) - room)
+	return int(room)
+}
+
+// release returns and clears the capacity recorded by withhold.
+func (f *inflow) release() int {
+	n := f.withheld
+	f.withheld = 0
+	return int(n)
+}
+
 // take attempts to take n bytes from the peer's flow control window.
 // It reports whether the window has available capacity.
 func (f *inflow) take(n uint32) bool {
@@ -11,8 +11,10 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"os"
 	"strings"
 	"sync"
+	"time"
 
 	"golang.org/x/net/http/httpguts"
 	"golang.org/x/net/http2/hpack"
@@ -316,6 +318,20 @@ type Framer struct {
 	// If the limit is hit, MetaHeadersFrame.Truncated is set true.
 	MaxHeaderListSize uint32
 
+	// MaxContinuationFrames is the maximum number of CONTINUATION
+	// frames which may follow a HEADERS frame in a single header block.
+	// It's used only if ReadMetaHeaders is set; 0 means a sane default
+	// (currently 1000).
+	// If the limit is exceeded, ReadFrame returns a ConnectionError
+	// with code ErrCodeProtocol.
+	MaxContinuationFrames int
+
+	// headerBlockTimeout, if non-zero, limits the time spent reading
+	// the CONTINUATION frames of a header block when ReadMetaHeaders is set.
+	// It is enforced by readDeadline.
+	headerBlockTimeout time.Duration
+	readDeadline       *connReadDeadline
+
 	// TODO: track which type of frame & with which flags was sent
 	// last. Then return an error (unless AllowIllegalWrites) if
 	// we're in the middle of a header block and a
@@ -339,6 +355,13 @@ func (fr *Framer) maxHeaderListSize() uint32 {
 	return fr.MaxHeaderListSize
 }
 
+func (fr *Framer) maxContinuationFrames() int {
+	if fr.MaxContinuationFrames <= 0 {
+		return 1000 // sane default, per docs
+	}
+	return fr.MaxContinuationFrames
+}
+
 func (f *Framer) startWrite(ftype FrameType, flags Flags, streamID uint32) {
 	// Write the FrameHeader.
 	f.wbuf = append(f.wbuf[:0],
@@ -1574,7 +1597,13 @@ func (fr *Framer) readMetaFrame(hf *HeadersFrame) (*MetaHeadersFrame, error) {
 	// Lose reference to MetaHeadersFrame:
 	defer hdec.SetEmitFunc(func(hf hpack.HeaderField) {})
 
+	if !hf.HeadersEnded() && fr.headerBlockTimeout != 0 && fr.readDeadline != nil {
+		fr.readDeadline.setLimit(time.Now().Add(fr.headerBlockTimeout))
+		defer fr.readDeadline.setLimit(time.Time{})
+	}
+
 	var hc headersOrContinuation = hf
+	continuations := 0
 	for {
 		frag := hc.HeaderBlockFragment()
 		if _, err := hdec.Write(frag); err != nil {
@@ -1584,10 +1613,28 @@ func (fr *Framer) readMetaFrame(hf *HeadersFrame) (*MetaHeadersFrame, error) {
 		if hc.HeadersEnded() {
 			break
 		}
-		if f, err := fr.ReadFrame(); err != nil {
+		f, err := fr.ReadFrame()
+		if err != nil {
+			if fr.headerBlockTimeout != 0 && errors.Is(err, os.ErrDeadlineExceeded) {
+				fr.countError("headers_timeout")
+				return nil, fr.connError(ErrCodeProtocol, "timeout reading header block")
+			}
 			return nil, err
-		} else {
-			hc = f.(*ContinuationFrame) // guaranteed by checkFrameOrder
+		}
+		hc = f.(*ContinuationFrame) // guaranteed by checkFrameOrder
+
+		// Defend against CONTINUATION floods: Limit the number of
+		// CONTINUATION frames in a header block, and don't continue
+		// decoding a header block we're going to discard anyway
+		// because it's already too large or contains an invalid field.
+		continuations++
+		if continuations > fr.maxContinuationFrames() {
+			fr.countError("headers_continuation_count")
+			return nil, fr.connError(ErrCodeProtocol, "too many CONTINUATION frames")
+		}
+		if mh.Truncated || invalid != nil || int64(len(hc.HeaderBlockFragment())) > 2*int64(remainSize) {
+			fr.countError("headers_continuation_too_large")
+			return nil, fr.connError(ErrCodeProtocol, "CONTINUATION frame after oversized or invalid header block")
 		}
 	}
 
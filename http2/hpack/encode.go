@@ -5,6 +5,7 @@
 package hpack
 
 import (
+	"errors"
 	"io"
 )
 
@@ -30,8 +31,13 @@ type Encoder struct {
 	buf             []byte
 }
 
+// ErrFieldTooLarge is returned by Encoder.AppendField when an encoded
+// header field does not fit within the caller's size limit.
+var ErrFieldTooLarge = errors.New("hpack: encoded header field exceeds size limit")
+
 // NewEncoder returns a new Encoder which performs HPACK encoding. An
 // encoded data is written to w.
+// w may be nil if the Encoder is only used with AppendField.
 func NewEncoder(w io.Writer) *Encoder {
 	e := &Encoder{
 		minSize:         uint32Max,
@@ -48,37 +54,56 @@ func NewEncoder(w io.Writer) *Encoder {
 // This function may also produce bytes for "Header Table Size Update"
 // if necessary. If produced, it is done before encoding f.
 func (e *Encoder) WriteField(f HeaderField) error {
-	e.buf = e.buf[:0]
+	e.buf, _ = e.AppendField(e.buf[:0], f, 0)
+	n, err := e.w.Write(e.buf)
+	if err == nil && n != len(e.buf) {
+		err = io.ErrShortWrite
+	}
+	return err
+}
 
+// AppendField appends the encoding of f to dst and returns the extended buffer.
+// Like WriteField, it may also produce bytes for "Header Table Size Update"
+// before encoding f.
+//
+// If maxLen is positive and the extended buffer would be longer than
+// maxLen bytes, AppendField returns dst unmodified and ErrFieldTooLarge,
+// and the state of the Encoder is unchanged.
+// The caller may then start a new buffer (for example, a CONTINUATION frame)
+// and encode f into it.
+func (e *Encoder) AppendField(dst []byte, f HeaderField, maxLen int) ([]byte, error) {
+	start := len(dst)
 	if e.tableSizeUpdate {
-		e.tableSizeUpdate = false
 		if e.minSize < e.dynTab.maxSize {
-			e.buf = appendTableSize(e.buf, e.minSize)
+			dst = appendTableSize(dst, e.minSize)
 		}
-		e.minSize = uint32Max
-		e.buf = appendTableSize(e.buf, e.dynTab.maxSize)
+		dst = appendTableSize(dst, e.dynTab.maxSize)
 	}
 
 	idx, nameValueMatch := e.searchTable(f)
+	indexing := false
 	if nameValueMatch {
-		e.buf = appendIndexed(e.buf, idx)
+		dst = appendIndexed(dst, idx)
 	} else {
-		indexing := e.shouldIndex(f)
-		if indexing {
-			e.dynTab.add(f)
-		}
-
+		indexing = e.shouldIndex(f)
 		if idx == 0 {
-			e.buf = appendNewName(e.buf, f, indexing)
+			dst = appendNewName(dst, f, indexing)
 		} else {
-			e.buf = appendIndexedName(e.buf, f, idx, indexing)
+			dst = appendIndexedName(dst, f, idx, indexing)
 		}
 	}
-	n, err := e.w.Write(e.buf)
-	if err == nil && n != len(e.buf) {
-		err = io.ErrShortWrite
+	if maxLen > 0 && len(dst) > maxLen {
+		return dst[:start], ErrFieldTooLarge
 	}
-	return err
+
+	if e.tableSizeUpdate {
+		e.tableSizeUpdate = false
+		e.minSize = uint32Max
+	}
+	if indexing {
+		e.dynTab.add(f)
+	}
+	return dst, nil
 }
 
 // searchTable searches f in both stable and dynamic header tables.
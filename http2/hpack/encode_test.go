@@ -90,6 +90,82 @@ func TestEncoderWriteField(t *testing.T) {
 	}
 }
 
+func TestEncoderAppendField(t *testing.T) {
+	e := NewEncoder(nil)
+	e.SetMaxDynamicTableSizeLimit(4096)
+	e.SetMaxDynamicTableSize(2048) // table size update precedes the first field
+	var got []HeaderField
+	d := NewDecoder(4<<10, func(f HeaderField) {
+		got = append(got, f)
+	})
+
+	hdrs := []HeaderField{
+		pair(":method", "GET"),
+		pair(":path", "/"),
+		pair("custom-key", "custom-value"),
+		pair("custom-key", "custom-value"), // indexed by the previous field
+	}
+	var buf []byte
+	for _, hf := range hdrs {
+		var err error
+		buf, err = e.AppendField(buf, hf, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := d.Write(buf); err != nil {
+		t.Fatalf("Decoder Write = %v", err)
+	}
+	if !reflect.DeepEqual(got, hdrs) {
+		t.Errorf("Decoded %+v; want %+v", got, hdrs)
+	}
+}
+
+func TestEncoderAppendFieldTooLarge(t *testing.T) {
+	e := NewEncoder(nil)
+	f := pair("custom-key", "custom-value")
+	dst := []byte("prefix")
+	got, err := e.AppendField(dst, f, len(dst)+1)
+	if err != ErrFieldTooLarge {
+		t.Fatalf("AppendField = %v; want ErrFieldTooLarge", err)
+	}
+	if string(got) != "prefix" {
+		t.Errorf("AppendField modified buffer: %q", got)
+	}
+	if e.dynTab.table.len() != 0 {
+		t.Errorf("AppendField added rejected field to the dynamic table")
+	}
+
+	// The rejected field is encoded in full into a new buffer.
+	got, err = e.AppendField(nil, f, 100)
+	if err != nil {
+		t.Fatalf("AppendField = %v", err)
+	}
+	want := appendNewName(nil, f, true)
+	if !bytes.Equal(got, want) {
+		t.Errorf("AppendField = %x; want %x", got, want)
+	}
+}
+
+func TestEncoderAppendFieldAllocs(t *testing.T) {
+	e := NewEncoder(nil)
+	hdrs := []HeaderField{
+		pair(":method", "GET"),
+		pair(":path", "/"),
+		pair("custom-key", "custom-value"),
+	}
+	buf := make([]byte, 0, 1024)
+	allocs := testing.AllocsPerRun(100, func() {
+		buf = buf[:0]
+		for _, hf := range hdrs {
+			buf, _ = e.AppendField(buf, hf, cap(buf))
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("AppendField allocations = %v; want 0", allocs)
+	}
+}
+
 func TestEncoderSearchTable(t *testing.T) {
 	e := NewEncoder(nil)
 
@@ -45,6 +45,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/net/http/httpguts"
@@ -139,6 +140,42 @@ type Server struct {
 	// maximum, a default value will be used instead.
 	MaxUploadBufferPerStream int32
 
+	// MaxContinuationFrames optionally limits the number of
+	// CONTINUATION frames a client may send in a single header block.
+	// If zero, a default of 1000 is used.
+	// A client which exceeds the limit, or which continues sending
+	// CONTINUATION frames after exceeding MaxHeaderBytes, is disconnected
+	// with a PROTOCOL_ERROR.
+	MaxContinuationFrames int
+
+	// HeaderBlockTimeout optionally limits the time a client may take
+	// to send a complete header block, from receipt of a HEADERS frame
+	// without the END_HEADERS flag until receipt of the final
+	// CONTINUATION frame. A client which exceeds the limit is disconnected
+	// with a PROTOCOL_ERROR. If zero, there is no limit.
+	HeaderBlockTimeout time.Duration
+
+	// MaxConcurrentStreamsPerClient optionally limits the total number
+	// of concurrent streams a single client IP address may have open
+	// across all of its connections to the server. Without this limit,
+	// a client can bypass MaxConcurrentStreams by opening many connections.
+	// If zero, there is no per-client limit.
+	//
+	// The limit is only enforced for servers configured with
+	// ConfigureServer. ClientStreamLimitAction determines what
+	// happens to a request which would exceed the limit.
+	MaxConcurrentStreamsPerClient int
+
+	// ClientStreamLimitAction is the action taken when a client
+	// exceeds MaxConcurrentStreamsPerClient.
+	ClientStreamLimitAction ClientStreamLimitAction
+
+	// UploadBufferUnderMemoryPressure is the maximum flow control window
+	// the server extends to each connection and each stream while it is
+	// under memory pressure. See SetMemoryPressure.
+	// If zero, a default of 65535 is used.
+	UploadBufferUnderMemoryPressure int32
+
 	// NewWriteScheduler constructs a write scheduler for a connection.
 	// If nil, a default scheduler is chosen.
 	NewWriteScheduler func() WriteScheduler
@@ -162,6 +199,45 @@ func (s *Server) initialConnRecvWindowSize() int32 {
 	return 1 << 20
 }
 
+func (s *Server) uploadBufferUnderMemoryPressure() int32 {
+	if s.UploadBufferUnderMemoryPressure > 0 {
+		return s.UploadBufferUnderMemoryPressure
+	}
+	return initialWindowSize
+}
+
+// SetMemoryPressure informs the server of whether the process is under
+// memory pressure. It is intended to be called by a process-wide
+// memory monitor, and may be called concurrently with serving requests.
+//
+// Flow control credit already granted to a client cannot be revoked.
+// While under memory pressure, the server instead stops returning credit
+// as handlers consume request bodies once a connection's or stream's
+// window reaches UploadBufferUnderMemoryPressure, so the memory used
+// for buffered uploads shrinks as data is read. When memory pressure ends,
+// the withheld credit is returned to clients in WINDOW_UPDATE frames.
+//
+// SetMemoryPressure has no effect on servers not configured with
+// ConfigureServer.
+func (s *Server) SetMemoryPressure(underPressure bool) {
+	state := s.state
+	if state == nil {
+		return
+	}
+	var v int32
+	if underPressure {
+		v = 1
+	}
+	if atomic.SwapInt32(&state.memoryPressure, v) == v || underPressure {
+		return
+	}
+	for _, sc := range state.conns() {
+		sc.sendServeMsg(func(sc *serverConn) {
+			sc.releaseWithheldFlow()
+		})
+	}
+}
+
 func (s *Server) initialStreamRecvWindowSize() int32 {
 	if s.MaxUploadBufferPerStream > 0 {
 		return s.MaxUploadBufferPerStream
@@ -206,9 +282,45 @@ func (s *Server) maxQueuedControlFrames() int {
 	return maxQueuedControlFrames
 }
 
+// A ClientStreamLimitAction is the action a Server takes when a client
+// exceeds Server.MaxConcurrentStreamsPerClient.
+type ClientStreamLimitAction int
+
+const (
+	// ClientStreamLimitRefuseStream rejects the new stream with a
+	// RST_STREAM of REFUSED_STREAM, which permits the client to retry
+	// the request later.
+	ClientStreamLimitRefuseStream ClientStreamLimitAction = iota
+
+	// ClientStreamLimitCloseConn closes the connection on which the
+	// new stream was opened with a GOAWAY of ENHANCE_YOUR_CALM.
+	ClientStreamLimitCloseConn
+)
+
 type serverInternalState struct {
 	mu          sync.Mutex
 	activeConns map[*serverConn]struct{}
+
+	// clientStreams is the number of open streams from each client IP
+	// address, across all connections. Guarded by mu.
+	clientStreams map[string]int
+
+	// memoryPressure is 1 when Server.SetMemoryPressure reports
+	// the process is under memory pressure. Accessed atomically.
+	memoryPressure int32
+}
+
+// conns returns a snapshot of the active connections.
+// Callers send messages to conns without holding s.mu,
+// since a conn's serve loop may be waiting to acquire it.
+func (s *serverInternalState) conns() []*serverConn {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	conns := make([]*serverConn, 0, len(s.activeConns))
+	for sc := range s.activeConns {
+		conns = append(conns, sc)
+	}
+	return conns
 }
 
 func (s *serverInternalState) registerConn(sc *serverConn) {
@@ -229,15 +341,34 @@ func (s *serverInternalState) unregisterConn(sc *serverConn) {
 	s.mu.Unlock()
 }
 
+// acquireClientStream reserves a stream for the client with the given IP
+// address, and reports whether the client is within its limit of open streams.
+func (s *serverInternalState) acquireClientStream(ip string, limit int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.clientStreams[ip] >= limit {
+		return false
+	}
+	s.clientStreams[ip]++
+	return true
+}
+
+// releaseClientStream releases a stream reserved by acquireClientStream.
+func (s *serverInternalState) releaseClientStream(ip string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.clientStreams[ip]--; s.clientStreams[ip] <= 0 {
+		delete(s.clientStreams, ip)
+	}
+}
+
 func (s *serverInternalState) startGracefulShutdown() {
 	if s == nil {
 		return // if the Server was used without calling ConfigureServer
 	}
-	s.mu.Lock()
-	for sc := range s.activeConns {
+	for _, sc := range s.conns() {
 		sc.startGracefulShutdown()
 	}
-	s.mu.Unlock()
 }
 
 // ConfigureServer adds HTTP/2 support to a net/http Server.
@@ -252,7 +383,10 @@ func ConfigureServer(s *http.Server, conf *Server) error {
 	if conf == nil {
 		conf = new(Server)
 	}
-	conf.state = &serverInternalState{activeConns: make(map[*serverConn]struct{})}
+	conf.state = &serverInternalState{
+		activeConns:   make(map[*serverConn]struct{}),
+		clientStreams: make(map[string]int),
+	}
 	if h1, h2 := s, conf; h2.IdleTimeout == 0 {
 		if h1.IdleTimeout != 0 {
 			h2.IdleTimeout = h1.IdleTimeout
@@ -408,6 +542,7 @@ func (s *Server) ServeConn(c net.Conn, opts *ServeConnOpts) {
 		conn:                        c,
 		baseCtx:                     baseCtx,
 		remoteAddrStr:               c.RemoteAddr().String(),
+		remoteIP:                    remoteIP(c.RemoteAddr()),
 		bw:                          newBufferedWriter(c),
 		handler:                     opts.handler(),
 		streams:                     make(map[uint32]*stream),
@@ -438,6 +573,15 @@ func (s *Server) ServeConn(c net.Conn, opts *ServeConnOpts) {
 		sc.conn.SetWriteDeadline(time.Time{})
 	}
 
+	// The read deadline set from http.Server.ReadTimeout is left in place
+	// until the first request's headers are read. We can't ask the
+	// net.Conn for it, so approximate it here, so that it is restored
+	// after applying the HeaderBlockTimeout.
+	sc.readDeadline.conn = c
+	if sc.hs.ReadTimeout != 0 {
+		sc.readDeadline.deadline = time.Now().Add(sc.hs.ReadTimeout)
+	}
+
 	if s.NewWriteScheduler != nil {
 		sc.writeSched = s.NewWriteScheduler()
 	} else {
@@ -458,6 +602,9 @@ func (s *Server) ServeConn(c net.Conn, opts *ServeConnOpts) {
 	}
 	fr.ReadMetaHeaders = hpack.NewDecoder(s.maxDecoderHeaderTableSize(), nil)
 	fr.MaxHeaderListSize = sc.maxHeaderListSize()
+	fr.MaxContinuationFrames = s.MaxContinuationFrames
+	fr.headerBlockTimeout = s.HeaderBlockTimeout
+	fr.readDeadline = &sc.readDeadline
 	fr.SetMaxReadFrameSize(s.maxReadFrameSize())
 	sc.framer = fr
 
@@ -567,7 +714,9 @@ type serverConn struct {
 	inflow           inflow                 // conn-wide inbound flow control
 	tlsState         *tls.ConnectionState   // shared by all handlers, like net/http
 	remoteAddrStr    string
+	remoteIP         string // client IP address, for MaxConcurrentStreamsPerClient
 	writeSched       WriteScheduler
+	readDeadline     connReadDeadline // sets conn's read deadline
 
 	// Everything following is owned by the serve loop; use serveG.check():
 	serveG                      goroutineLock // used to verify funcs are on serve()
@@ -609,6 +758,56 @@ type serverConn struct {
 	shutdownOnce sync.Once
 }
 
+// remoteIP returns the IP address of a connection's remote address,
+// or the full address if it does not contain an IP address.
+func remoteIP(addr net.Addr) string {
+	if a, ok := addr.(*net.TCPAddr); ok {
+		return a.IP.String()
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
+
+// A connReadDeadline sets the read deadline of a serverConn's net.Conn.
+//
+// The serve loop sets the conn's deadline, and the framer additionally
+// limits it while reading a header block. The conn's read deadline is
+// the earlier of the two.
+type connReadDeadline struct {
+	conn     net.Conn
+	mu       sync.Mutex
+	deadline time.Time // deadline set by the serve loop
+	limit    time.Time // deadline of the header block being read
+}
+
+// set sets the read deadline outside of header blocks.
+func (d *connReadDeadline) set(t time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.deadline = t
+	return d.apply()
+}
+
+// setLimit sets the deadline for reading the current header block,
+// or clears it when t is zero.
+func (d *connReadDeadline) setLimit(t time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.limit = t
+	return d.apply()
+}
+
+func (d *connReadDeadline) apply() error {
+	t := d.deadline
+	if !d.limit.IsZero() && (t.IsZero() || d.limit.Before(t)) {
+		t = d.limit
+	}
+	return d.conn.SetReadDeadline(t)
+}
+
 func (sc *serverConn) maxHeaderListSize() uint32 {
 	n := sc.hs.MaxHeaderBytes
 	if n <= 0 {
@@ -654,6 +853,7 @@ type stream struct {
 	readDeadline     *time.Timer // nil if unused
 	writeDeadline    *time.Timer // nil if unused
 	closeErr         error       // set before cw is closed
+	perClientCounted bool        // counted toward MaxConcurrentStreamsPerClient
 
 	trailer    http.Header // accumulated trailers
 	reqTrailer http.Header // handler's Request.Trailer
@@ -1634,6 +1834,9 @@ func (sc *serverConn) closeStream(st *stream, err error) {
 	} else {
 		sc.curClientStreams--
 	}
+	if st.perClientCounted {
+		sc.srv.state.releaseClientStream(sc.remoteIP)
+	}
 	delete(sc.streams, st.id)
 	if len(sc.streams) == 0 {
 		sc.setConnState(http.StateIdle)
@@ -1978,11 +2181,21 @@ func (sc *serverConn) processHeaders(f *MetaHeadersFrame) error {
 		return sc.countError("over_max_streams_race", streamError(id, ErrCodeRefusedStream))
 	}
 
+	// Limit streams across all connections from the same client.
+	perClient := sc.srv.MaxConcurrentStreamsPerClient > 0 && sc.srv.state != nil
+	if perClient && !sc.srv.state.acquireClientStream(sc.remoteIP, sc.srv.MaxConcurrentStreamsPerClient) {
+		if sc.srv.ClientStreamLimitAction == ClientStreamLimitCloseConn {
+			return sc.countError("over_client_max_streams", ConnectionError(ErrCodeEnhanceYourCalm))
+		}
+		return sc.countError("over_client_max_streams", streamError(id, ErrCodeRefusedStream))
+	}
+
 	initialState := stateOpen
 	if f.StreamEnded() {
 		initialState = stateHalfClosedRemote
 	}
 	st := sc.newStream(id, 0, initialState)
+	st.perClientCounted = perClient
 
 	if f.HasPriority() {
 		if err := sc.checkPriority(f.StreamID, f.Priority); err != nil {
@@ -2018,7 +2231,7 @@ func (sc *serverConn) processHeaders(f *MetaHeadersFrame) error {
 	// technically more like the http1 Server's ReadHeaderTimeout
 	// (in Go 1.8), though. That's a more sane option anyway.
 	if sc.hs.ReadTimeout != 0 {
-		sc.conn.SetReadDeadline(time.Time{})
+		sc.readDeadline.set(time.Time{})
 		st.readDeadline = time.AfterFunc(sc.hs.ReadTimeout, st.onReadTimeout)
 	}
 
@@ -2039,7 +2252,7 @@ func (sc *serverConn) upgradeRequest(req *http.Request) {
 	// Disable any read deadline set by the net/http package
 	// prior to the upgrade.
 	if sc.hs.ReadTimeout != 0 {
-		sc.conn.SetReadDeadline(time.Time{})
+		sc.readDeadline.set(time.Time{})
 	}
 
 	// This is the first request on the connection,
@@ -2459,13 +2672,15 @@ func (sc *serverConn) sendWindowUpdate32(st *stream, n int32) {
 func (sc *serverConn) sendWindowUpdate(st *stream, n int) {
 	sc.serveG.check()
 	var streamID uint32
-	var send int32
-	if st == nil {
-		send = sc.inflow.add(n)
-	} else {
+	f := &sc.inflow
+	if st != nil {
 		streamID = st.id
-		send = st.inflow.add(n)
+		f = &st.inflow
+	}
+	if sc.underMemoryPressure() {
+		n = f.withhold(n, sc.srv.uploadBufferUnderMemoryPressure())
 	}
+	send := f.add(n)
 	if send == 0 {
 		return
 	}
@@ -2475,6 +2690,28 @@ func (sc *serverConn) sendWindowUpdate(st *stream, n int) {
 	})
 }
 
+func (sc *serverConn) underMemoryPressure() bool {
+	return sc.srv.state != nil && atomic.LoadInt32(&sc.srv.state.memoryPressure) == 1
+}
+
+// releaseWithheldFlow returns flow control credit withheld
+// while the server was under memory pressure.
+func (sc *serverConn) releaseWithheldFlow() {
+	sc.serveG.check()
+	if sc.underMemoryPressure() {
+		return // pressure resumed before we got here
+	}
+	if n := sc.inflow.release(); n > 0 {
+		sc.sendWindowUpdate(nil, n)
+	}
+	for _, st := range sc.streams {
+		n := st.inflow.release()
+		if n > 0 && st.state != stateHalfClosedRemote && st.state != stateClosed {
+			sc.sendWindowUpdate(st, n)
+		}
+	}
+}
+
 // requestBody is the Handler's Request.Body type.
 // Read and Close may be called concurrently.
 type requestBody struct {
@@ -2965,6 +3202,70 @@ func (w *responseWriter) WriteString(s string) (n int, err error) {
 	return w.write(len(s), nil, s)
 }
 
+// handlerReadFromChunkSize is the size of the chunks ReadFrom reads
+// from its source. It matches the default maximum frame size,
+// so each chunk can be sent as a single DATA frame.
+const handlerReadFromChunkSize = 16 << 10
+
+var readFromBufPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, handlerReadFromChunkSize)
+		return &b
+	},
+}
+
+var _ io.ReaderFrom = (*responseWriter)(nil)
+
+// ReadFrom copies src to the response body.
+//
+// Unlike Write, ReadFrom bypasses the handler's write buffer:
+// it reads src in frame-sized chunks and passes each chunk directly
+// to the connection, avoiding a copy and a small DATA frame per buffer
+// when serving large bodies such as files.
+func (w *responseWriter) ReadFrom(src io.Reader) (n int64, err error) {
+	rws := w.rws
+	if rws == nil {
+		panic("ReadFrom called after Handler finished")
+	}
+	if !rws.wroteHeader {
+		w.WriteHeader(200)
+	}
+	if !bodyAllowedForStatus(rws.status) {
+		return 0, http.ErrBodyNotAllowed
+	}
+	// Data already buffered by Write must be sent first.
+	if rws.bw.Buffered() > 0 {
+		if err := rws.bw.Flush(); err != nil {
+			return 0, err
+		}
+	}
+	bp := readFromBufPool.Get().(*[]byte)
+	buf := *bp
+	for {
+		nr, rerr := src.Read(buf)
+		if nr > 0 {
+			rws.wroteBytes += int64(nr)
+			if rws.sentContentLen != 0 && rws.wroteBytes > rws.sentContentLen {
+				return n, errors.New("http2: handler wrote more than declared Content-Length")
+			}
+			nw, werr := chunkWriter{rws}.Write(buf[:nr])
+			n += int64(nw)
+			if werr != nil {
+				// A failed write may still reference buf,
+				// so don't return it to the pool. See issue 20704.
+				return n, werr
+			}
+		}
+		if rerr != nil {
+			readFromBufPool.Put(bp)
+			if rerr == io.EOF {
+				rerr = nil
+			}
+			return n, rerr
+		}
+	}
+}
+
 // either dataB or dataS is non-zero.
 func (w *responseWriter) write(lenData int, dataB []byte, dataS string) (n int, err error) {
 	rws := w.rws
@@ -1321,6 +1321,52 @@ func TestServer_Handler_Sends_WindowUpdate(t *testing.T) {
 	st.writeReadPing()
 }
 
+func TestServer_Handler_WindowUpdate_MemoryPressure(t *testing.T) {
+	const (
+		windowSize   = 65535 * 2
+		pressureSize = 65535
+	)
+	puppet := newHandlerPuppet()
+	st := newServerTester(t, func(w http.ResponseWriter, r *http.Request) {
+		puppet.act(w, r)
+	}, func(s *Server) {
+		s.MaxUploadBufferPerConnection = windowSize
+		s.MaxUploadBufferPerStream = windowSize
+		s.UploadBufferUnderMemoryPressure = pressureSize
+	})
+	defer st.Close()
+	defer puppet.done()
+
+	st.greet()
+	st.writeHeaders(HeadersFrameParam{
+		StreamID:      1,
+		BlockFragment: st.encodeHeader(":method", "POST"),
+		EndStream:     false,
+		EndHeaders:    true,
+	})
+	st.writeReadPing()
+
+	// Fill the connection and stream windows.
+	data := make([]byte, windowSize)
+	st.writeData(1, false, data)
+	st.writeReadPing()
+
+	// Under memory pressure, the handler consumes the data,
+	// but the server only returns enough credit to open the
+	// windows to UploadBufferUnderMemoryPressure.
+	st.sc.srv.SetMemoryPressure(true)
+	puppet.do(readBodyHandler(t, string(data)))
+	st.wantWindowUpdate(0, pressureSize)
+	st.wantWindowUpdate(1, pressureSize)
+	st.writeReadPing()
+
+	// When the pressure ends, the server returns the withheld credit.
+	st.sc.srv.SetMemoryPressure(false)
+	st.wantWindowUpdate(0, windowSize-pressureSize)
+	st.wantWindowUpdate(1, windowSize-pressureSize)
+	st.writeReadPing()
+}
+
 // the version of the TestServer_Handler_Sends_WindowUpdate with padding.
 // See golang.org/issue/16556
 func TestServer_Handler_Sends_WindowUpdate_Padding(t *testing.T) {
@@ -1790,6 +1836,36 @@ func TestServer_Response_NoData(t *testing.T) {
 	})
 }
 
+func TestServer_Response_ReadFrom(t *testing.T) {
+	const msg = "hello, "
+	body := strings.Repeat("x", 40<<10)
+	testServerResponse(t, func(w http.ResponseWriter, r *http.Request) error {
+		io.WriteString(w, msg)
+		n, err := w.(io.ReaderFrom).ReadFrom(strings.NewReader(body))
+		if n != int64(len(body)) || err != nil {
+			return fmt.Errorf("ReadFrom = %v, %v; want %v, nil", n, err, len(body))
+		}
+		return nil
+	}, func(st *serverTester) {
+		getSlash(st)
+		st.wantHeaders()
+		var got []byte
+		for {
+			df := st.wantData()
+			if len(df.Data()) > handlerReadFromChunkSize {
+				t.Errorf("DATA frame of %v bytes, want at most %v", len(df.Data()), handlerReadFromChunkSize)
+			}
+			got = append(got, df.Data()...)
+			if df.StreamEnded() {
+				break
+			}
+		}
+		if want := msg + body; string(got) != want {
+			t.Errorf("got %v bytes of body, want %v", len(got), len(want))
+		}
+	})
+}
+
 func TestServer_Response_NoData_Header_FooBar(t *testing.T) {
 	testServerResponse(t, func(w http.ResponseWriter, r *http.Request) error {
 		w.Header().Set("Foo-Bar", "some-value")
@@ -2813,6 +2889,241 @@ func TestServerDoS_MaxHeaderListSize(t *testing.T) {
 	}
 }
 
+// testServerRejectsHeaderBlock tests that the server closes the connection
+// with a PROTOCOL_ERROR and reports the error type errType to CountError
+// after a client sends an abusive header block.
+func testServerRejectsHeaderBlock(t *testing.T, errType string, setup func(*Server), writeReq func(*serverTester)) {
+	var (
+		mu     sync.Mutex
+		counts = map[string]int{}
+	)
+	st := newServerTester(t, func(w http.ResponseWriter, r *http.Request) {}, func(s *Server) {
+		setup(s)
+		s.CountError = func(typ string) {
+			mu.Lock()
+			defer mu.Unlock()
+			counts[typ]++
+		}
+	})
+	st.addLogFilter("connection error: PROTOCOL_ERROR")
+	defer st.Close()
+	st.greet()
+	writeReq(st)
+
+	if gf := st.wantGoAway(); gf.ErrCode != ErrCodeProtocol {
+		t.Errorf("GOAWAY ErrCode = %v; want %v", gf.ErrCode, ErrCodeProtocol)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if counts[errType] != 1 {
+		t.Errorf("CountError(%q) called %v times; want 1 (all counts: %v)", errType, counts[errType], counts)
+	}
+}
+
+func TestServerDoS_ContinuationCount(t *testing.T) {
+	const maxContinuations = 5
+	testServerRejectsHeaderBlock(t, "headers_continuation_count", func(s *Server) {
+		s.MaxContinuationFrames = maxContinuations
+	}, func(st *serverTester) {
+		st.writeHeaders(HeadersFrameParam{
+			StreamID:      1,
+			BlockFragment: st.encodeHeader(),
+			EndStream:     true,
+			EndHeaders:    false,
+		})
+		for i := 0; i < maxContinuations+1; i++ {
+			if err := st.fr.WriteContinuation(1, false, nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestServerDoS_ContinuationAfterHeaderListTooLarge(t *testing.T) {
+	testServerRejectsHeaderBlock(t, "headers_continuation_too_large", func(s *Server) {}, func(st *serverTester) {
+		st.writeHeaders(HeadersFrameParam{
+			StreamID:      1,
+			BlockFragment: st.encodeHeader("cookie", strings.Repeat("*", 1000)),
+			EndStream:     true,
+			EndHeaders:    false,
+		})
+		// Repeated references to the cookie in the dynamic table
+		// quickly exceed the maximum header list size.
+		st.headerBuf.Reset()
+		st.encodeHeaderField("cookie", strings.Repeat("*", 1000))
+		ref := st.headerBuf.Bytes()
+		chunk := bytes.Repeat(ref, 4096/len(ref))
+		for i := 0; i < 3; i++ {
+			if err := st.fr.WriteContinuation(1, false, chunk); err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestServerDoS_HeaderBlockTimeout(t *testing.T) {
+	testServerRejectsHeaderBlock(t, "headers_timeout", func(s *Server) {
+		s.HeaderBlockTimeout = 10 * time.Millisecond
+	}, func(st *serverTester) {
+		st.writeHeaders(HeadersFrameParam{
+			StreamID:      1,
+			BlockFragment: st.encodeHeader(),
+			EndStream:     true,
+			EndHeaders:    false,
+		})
+		// The client never sends the rest of the header block.
+	})
+}
+
+// readDeadlineConn is a net.Conn which records its read deadline.
+type readDeadlineConn struct {
+	net.Conn
+	deadline time.Time
+}
+
+func (c *readDeadlineConn) SetReadDeadline(t time.Time) error {
+	c.deadline = t
+	return nil
+}
+
+func TestServerHeaderBlockTimeoutRestoresReadDeadline(t *testing.T) {
+	c := &readDeadlineConn{}
+	d := &connReadDeadline{conn: c}
+	start := time.Now()
+	connDeadline := start.Add(time.Minute)
+	d.set(connDeadline)
+
+	for _, test := range []struct {
+		limit time.Time
+		want  time.Time
+	}{
+		{start.Add(time.Second), start.Add(time.Second)},
+		{start.Add(time.Hour), connDeadline},
+	} {
+		d.setLimit(test.limit)
+		if !c.deadline.Equal(test.want) {
+			t.Errorf("with header block deadline %v: conn deadline = %v, want %v", test.limit.Sub(start), c.deadline.Sub(start), test.want.Sub(start))
+		}
+		d.setLimit(time.Time{})
+		if !c.deadline.Equal(connDeadline) {
+			t.Errorf("after header block: conn deadline = %v, want %v", c.deadline.Sub(start), connDeadline.Sub(start))
+		}
+	}
+
+	// Clearing the conn deadline leaves the header block deadline in place.
+	limit := start.Add(time.Second)
+	d.setLimit(limit)
+	d.set(time.Time{})
+	if !c.deadline.Equal(limit) {
+		t.Errorf("conn deadline cleared during header block: deadline = %v, want %v", c.deadline, limit)
+	}
+	d.setLimit(time.Time{})
+	if !c.deadline.IsZero() {
+		t.Errorf("after header block: deadline = %v, want none", c.deadline)
+	}
+}
+
+// newClientConn returns a serverTester for a new client connection to st's server.
+func (st *serverTester) newClientConn() *serverTester {
+	cc, err := tls.Dial("tcp", st.ts.Listener.Addr().String(), &tls.Config{
+		InsecureSkipVerify: true,
+		NextProtos:         []string{NextProtoTLS},
+	})
+	if err != nil {
+		st.t.Fatal(err)
+	}
+	st.scMu.Lock()
+	defer st.scMu.Unlock()
+	st2 := &serverTester{
+		t:  st.t,
+		ts: st.ts,
+		cc: cc,
+		fr: NewFramer(cc, cc),
+		sc: st.sc, // shares the same Server
+	}
+	st2.hpackEnc = hpack.NewEncoder(&st2.headerBuf)
+	st2.hpackDec = hpack.NewDecoder(initialHeaderTableSize, st2.onHeaderField)
+	return st2
+}
+
+func TestServer_MaxConcurrentStreamsPerClient(t *testing.T) {
+	release := make(chan struct{})
+	st := newServerTester(t, func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}, func(s *Server) {
+		s.MaxConcurrentStreamsPerClient = 1
+	})
+	defer st.Close()
+	defer close(release)
+	st.greet()
+	st.writeHeaders(HeadersFrameParam{
+		StreamID:      1,
+		BlockFragment: st.encodeHeader(),
+		EndStream:     true,
+		EndHeaders:    true,
+	})
+
+	// A second connection from the same client is limited by
+	// the stream open on the first connection.
+	st2 := st.newClientConn()
+	defer st2.cc.Close()
+	st2.greet()
+	st2.writeHeaders(HeadersFrameParam{
+		StreamID:      1,
+		BlockFragment: st2.encodeHeader(),
+		EndStream:     true,
+		EndHeaders:    true,
+	})
+	st2.wantRSTStream(1, ErrCodeRefusedStream)
+
+	// Completing the first request permits a new stream.
+	release <- struct{}{}
+	st.wantHeaders()
+	// The PING ACK is written after the response, so the stream has closed.
+	st.writeReadPing()
+	st2.writeHeaders(HeadersFrameParam{
+		StreamID:      3,
+		BlockFragment: st2.encodeHeader(),
+		EndStream:     true,
+		EndHeaders:    true,
+	})
+	release <- struct{}{}
+	st2.wantHeaders()
+}
+
+func TestServer_MaxConcurrentStreamsPerClient_CloseConn(t *testing.T) {
+	release := make(chan struct{})
+	st := newServerTester(t, func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}, func(s *Server) {
+		s.MaxConcurrentStreamsPerClient = 1
+		s.ClientStreamLimitAction = ClientStreamLimitCloseConn
+	})
+	defer st.Close()
+	defer close(release)
+	st.greet()
+	st.writeHeaders(HeadersFrameParam{
+		StreamID:      1,
+		BlockFragment: st.encodeHeader(),
+		EndStream:     true,
+		EndHeaders:    true,
+	})
+
+	st2 := st.newClientConn()
+	defer st2.cc.Close()
+	st2.greet()
+	st2.writeHeaders(HeadersFrameParam{
+		StreamID:      1,
+		BlockFragment: st2.encodeHeader(),
+		EndStream:     true,
+		EndHeaders:    true,
+	})
+	gf := st2.wantGoAway()
+	if gf.ErrCode != ErrCodeEnhanceYourCalm {
+		t.Errorf("GOAWAY error code = %v; want %v", gf.ErrCode, ErrCodeEnhanceYourCalm)
+	}
+}
+
 func TestServer_Response_Stream_With_Missing_Trailer(t *testing.T) {
 	testServerResponse(t, func(w http.ResponseWriter, r *http.Request) error {
 		w.Header().Set("Trailer", "test-trailer")
@@ -3557,6 +3868,61 @@ func BenchmarkServer_PostRequest(b *testing.B) {
 	}
 }
 
+// BenchmarkServer_LargeResponse measures writing a large response body
+// using Write and ReadFrom.
+func BenchmarkServer_LargeResponse(b *testing.B) {
+	const size = 1 << 20
+	body := bytes.Repeat([]byte("x"), size)
+	for _, tc := range []struct {
+		name  string
+		write func(w http.ResponseWriter)
+	}{{
+		name: "Write",
+		write: func(w http.ResponseWriter) {
+			w.Write(body)
+		},
+	}, {
+		name: "ReadFrom",
+		write: func(w http.ResponseWriter) {
+			w.(io.ReaderFrom).ReadFrom(bytes.NewReader(body))
+		},
+	}} {
+		b.Run(tc.name, func(b *testing.B) {
+			defer disableGoroutineTracking()()
+			b.ReportAllocs()
+			b.SetBytes(size)
+			st := newServerTester(b, func(w http.ResponseWriter, r *http.Request) {
+				tc.write(w)
+			})
+			defer st.Close()
+			st.greet()
+			hbf := st.encodeHeader(":method", "GET")
+			for i := 0; i < b.N; i++ {
+				streamID := uint32(1 + 2*i)
+				if err := st.fr.WriteWindowUpdate(0, size); err != nil {
+					b.Fatal(err)
+				}
+				st.writeHeaders(HeadersFrameParam{
+					StreamID:      streamID,
+					BlockFragment: hbf,
+					EndStream:     true,
+					EndHeaders:    true,
+				})
+				st.wantHeaders()
+				if err := st.fr.WriteWindowUpdate(streamID, size); err != nil {
+					b.Fatal(err)
+				}
+				for {
+					df := st.wantData()
+					if df.StreamEnded() {
+						break
+					}
+				}
+			}
+		})
+	}
+}
+
 type connStateConn struct {
 	net.Conn
 	cs tls.ConnectionState
@@ -22,6 +22,37 @@ type roundRobinWriteScheduler struct {
 
 	// pool of empty queues for reuse.
 	queuePool writeQueuePool
+
+	// maxChunkSize is the maximum number of DATA bytes
+	// written from a stream before moving to the next one.
+	maxChunkSize int32
+}
+
+// RoundRobinWriteSchedulerConfig configures a round-robin WriteScheduler.
+type RoundRobinWriteSchedulerConfig struct {
+	// MaxChunkSize is the maximum number of bytes of DATA written
+	// from one stream before the scheduler moves on to the next ready stream.
+	// DATA frames larger than MaxChunkSize are split.
+	//
+	// If zero, each stream writes at most one frame of the connection's
+	// maximum frame size before yielding to the next stream.
+	MaxChunkSize int32
+}
+
+// NewRoundRobinWriteScheduler constructs a WriteScheduler that ignores
+// HTTP/2 priorities and interleaves DATA from ready streams in turn,
+// so that a large response does not delay small responses on the same connection.
+// Control frames like SETTINGS and PING are written before DATA frames.
+// If cfg is nil, default options are used.
+func NewRoundRobinWriteScheduler(cfg *RoundRobinWriteSchedulerConfig) WriteScheduler {
+	ws := &roundRobinWriteScheduler{
+		streams:      make(map[uint32]*writeQueue),
+		maxChunkSize: math.MaxInt32,
+	}
+	if cfg != nil && cfg.MaxChunkSize > 0 {
+		ws.maxChunkSize = cfg.MaxChunkSize
+	}
+	return ws
 }
 
 // newRoundRobinWriteScheduler constructs a new write scheduler.
@@ -30,10 +61,7 @@ type roundRobinWriteScheduler struct {
 // When there are no control frames to send, it performs a round-robin
 // selection from the ready streams.
 func newRoundRobinWriteScheduler() WriteScheduler {
-	ws := &roundRobinWriteScheduler{
-		streams: make(map[uint32]*writeQueue),
-	}
-	return ws
+	return NewRoundRobinWriteScheduler(nil)
 }
 
 func (ws *roundRobinWriteScheduler) OpenStream(streamID uint32, options OpenStreamOptions) {
@@ -106,7 +134,7 @@ func (ws *roundRobinWriteScheduler) Pop() (FrameWriteRequest, bool) {
 	}
 	q := ws.head
 	for {
-		if wr, ok := q.consume(math.MaxInt32); ok {
+		if wr, ok := q.consume(ws.maxChunkSize); ok {
 			ws.head = q.next
 			return wr, true
 		}
@@ -5,6 +5,7 @@
 package http2
 
 import (
+	"fmt"
 	"reflect"
 	"testing"
 )
@@ -63,3 +64,89 @@ func TestRoundRobinScheduler(t *testing.T) {
 		t.Fatalf("popped streams %v, want %v", got, want)
 	}
 }
+
+func TestRoundRobinSchedulerMaxChunkSize(t *testing.T) {
+	const maxFrameSize = 16
+	const chunkSize = 4
+	sc := &serverConn{maxFrameSize: maxFrameSize}
+	ws := NewRoundRobinWriteScheduler(&RoundRobinWriteSchedulerConfig{
+		MaxChunkSize: chunkSize,
+	})
+	for i, size := range []int{maxFrameSize, chunkSize} {
+		streamID := uint32(i) + 1
+		st := &stream{id: streamID, sc: sc}
+		st.flow.add(1 << 20) // arbitrary large value
+		ws.OpenStream(streamID, OpenStreamOptions{})
+		ws.Push(FrameWriteRequest{
+			write: &writeData{
+				streamID: streamID,
+				p:        make([]byte, size),
+			},
+			stream: st,
+		})
+	}
+
+	// The large frame on stream 1 is split into chunks,
+	// and the small frame on stream 2 is written after the first chunk.
+	want := []uint32{1, 2, 1, 1, 1}
+	var got []uint32
+	for {
+		wr, ok := ws.Pop()
+		if !ok {
+			break
+		}
+		if wr.DataSize() != chunkSize {
+			t.Fatalf("wr.Pop() = %v data bytes, want %v", wr.DataSize(), chunkSize)
+		}
+		got = append(got, wr.StreamID())
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("popped streams %v, want %v", got, want)
+	}
+}
+
+// BenchmarkRoundRobinSchedulerFairness measures the number of bytes written
+// from a stream with a large queued response before a small response
+// on another stream is written, for various chunk sizes.
+func BenchmarkRoundRobinSchedulerFairness(b *testing.B) {
+	const maxFrameSize = 16 << 10
+	const largeSize = 1 << 20
+	for _, chunkSize := range []int32{0, 4 << 10, 1 << 10} {
+		b.Run(fmt.Sprintf("chunk=%v", chunkSize), func(b *testing.B) {
+			b.ReportAllocs()
+			sc := &serverConn{maxFrameSize: maxFrameSize}
+			large := make([]byte, largeSize)
+			small := make([]byte, 100)
+			var delay int64
+			for i := 0; i < b.N; i++ {
+				ws := NewRoundRobinWriteScheduler(&RoundRobinWriteSchedulerConfig{
+					MaxChunkSize: chunkSize,
+				})
+				for id, p := range [][]byte{1: large, 3: small} {
+					if p == nil {
+						continue
+					}
+					id := uint32(id)
+					st := &stream{id: id, sc: sc}
+					st.flow.add(largeSize)
+					ws.OpenStream(id, OpenStreamOptions{})
+					ws.Push(FrameWriteRequest{
+						write:  &writeData{streamID: id, p: p},
+						stream: st,
+					})
+				}
+				for {
+					wr, ok := ws.Pop()
+					if !ok {
+						b.Fatalf("small response was never written")
+					}
+					if wr.StreamID() == 3 {
+						break
+					}
+					delay += int64(wr.DataSize())
+				}
+			}
+			b.ReportMetric(float64(delay)/float64(b.N), "bytes-before-small/op")
+		})
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package icmp
+
+import "net"
+
+// SupportsNonPrivileged reports whether the calling process is
+// permitted to create non-privileged datagram-oriented ICMP
+// endpoints for network, which must be "ip4" or "ip6".
+//
+// On Linux, non-privileged endpoints are available to processes
+// whose group ID is within the range reported by PingGroupRange.
+func SupportsNonPrivileged(network string) bool {
+	var address string
+	switch network {
+	case "ip4":
+		network, address = "udp4", "0.0.0.0"
+	case "ip6":
+		network, address = "udp6", "::"
+	default:
+		return false
+	}
+	c, err := ListenPacket(network, address)
+	if err != nil {
+		return false
+	}
+	c.Close()
+	return true
+}
+
+// ListenPing listens for incoming ICMP echo replies addressed to
+// address. Network must be "ip4" or "ip6".
+//
+// ListenPing prefers a non-privileged datagram-oriented endpoint,
+// and falls back to a privileged raw endpoint when the calling
+// process is not permitted to create one. The kind of endpoint may
+// be distinguished by the type of its local address: a *net.UDPAddr
+// for a datagram-oriented endpoint, and a *net.IPAddr for a raw
+// endpoint. Messages must be written to an address of the same type,
+// and the kernel replaces the identifier of echo requests sent
+// through a datagram-oriented endpoint.
+func ListenPing(network, address string) (*PacketConn, error) {
+	var dgram, raw string
+	switch network {
+	case "ip4":
+		dgram, raw = "udp4", "ip4:icmp"
+	case "ip6":
+		dgram, raw = "udp6", "ip6:ipv6-icmp"
+	default:
+		return nil, net.UnknownNetworkError(network)
+	}
+	if c, err := ListenPacket(dgram, address); err == nil {
+		return c, nil
+	}
+	return ListenPacket(raw, address)
+}
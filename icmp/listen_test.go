@@ -0,0 +1,64 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package icmp_test
+
+import (
+	"net"
+	"runtime"
+	"testing"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/nettest"
+)
+
+func TestListenPing(t *testing.T) {
+	for _, tt := range []struct {
+		network, address string
+		supported        func() bool
+	}{
+		{"ip4", "127.0.0.1", nettest.SupportsIPv4},
+		{"ip6", "::1", nettest.SupportsIPv6},
+	} {
+		if !tt.supported() {
+			continue
+		}
+		c, err := icmp.ListenPing(tt.network, tt.address)
+		if err != nil {
+			t.Logf("%s: %v", tt.network, err)
+			continue
+		}
+		nonPriv := icmp.SupportsNonPrivileged(tt.network)
+		switch a := c.LocalAddr().(type) {
+		case *net.UDPAddr:
+			if !nonPriv {
+				t.Errorf("%s: datagram-oriented endpoint created, but SupportsNonPrivileged = false", tt.network)
+			}
+		case *net.IPAddr:
+			if nonPriv {
+				t.Errorf("%s: raw endpoint created, but SupportsNonPrivileged = true", tt.network)
+			}
+		default:
+			t.Errorf("%s: unexpected local address %T", tt.network, a)
+		}
+		c.Close()
+	}
+	if _, err := icmp.ListenPing("udp4", "127.0.0.1"); err == nil {
+		t.Errorf("ListenPing(udp4) succeeded, want error")
+	}
+}
+
+func TestPingGroupRange(t *testing.T) {
+	min, max, err := icmp.PingGroupRange()
+	if runtime.GOOS != "linux" {
+		if err == nil {
+			t.Errorf("PingGroupRange on %s succeeded, want error", runtime.GOOS)
+		}
+		return
+	}
+	if err != nil {
+		t.Skip(err)
+	}
+	t.Logf("ping_group_range: %v-%v", min, max)
+}
@@ -0,0 +1,51 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package icmp
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// pingGroupRangeFile is the kernel state which controls access to
+// non-privileged ICMP endpoints. It applies to both IPv4 and IPv6.
+var pingGroupRangeFile = "/proc/sys/net/ipv4/ping_group_range"
+
+// PingGroupRange returns the inclusive range of group IDs permitted
+// to create non-privileged datagram-oriented ICMP endpoints.
+// The range is empty when min is greater than max.
+//
+// PingGroupRange is only supported on Linux, where the range is
+// the net.ipv4.ping_group_range kernel state.
+func PingGroupRange() (min, max uint32, err error) {
+	b, err := os.ReadFile(pingGroupRangeFile)
+	if err != nil {
+		return 0, 0, err
+	}
+	f := strings.Fields(string(b))
+	if len(f) != 2 {
+		return 0, 0, fmt.Errorf("malformed %s: %q", pingGroupRangeFile, b)
+	}
+	lo, err := strconv.ParseUint(f[0], 10, 32)
+	if err != nil {
+		return 0, 0, fmt.Errorf("malformed %s: %v", pingGroupRangeFile, err)
+	}
+	hi, err := strconv.ParseUint(f[1], 10, 32)
+	if err != nil {
+		return 0, 0, fmt.Errorf("malformed %s: %v", pingGroupRangeFile, err)
+	}
+	return uint32(lo), uint32(hi), nil
+}
+
+// SetPingGroupRange sets the inclusive range of group IDs permitted
+// to create non-privileged datagram-oriented ICMP endpoints.
+// It requires privilege to modify the kernel state.
+//
+// SetPingGroupRange is only supported on Linux.
+func SetPingGroupRange(min, max uint32) error {
+	return os.WriteFile(pingGroupRangeFile, []byte(fmt.Sprintf("%d\t%d\n", min, max)), 0644)
+}
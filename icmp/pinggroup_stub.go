@@ -0,0 +1,26 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+
+package icmp
+
+// PingGroupRange returns the inclusive range of group IDs permitted
+// to create non-privileged datagram-oriented ICMP endpoints.
+// The range is empty when min is greater than max.
+//
+// PingGroupRange is only supported on Linux, where the range is
+// the net.ipv4.ping_group_range kernel state.
+func PingGroupRange() (min, max uint32, err error) {
+	return 0, 0, errNotImplemented
+}
+
+// SetPingGroupRange sets the inclusive range of group IDs permitted
+// to create non-privileged datagram-oriented ICMP endpoints.
+// It requires privilege to modify the kernel state.
+//
+// SetPingGroupRange is only supported on Linux.
+func SetPingGroupRange(min, max uint32) error {
+	return errNotImplemented
+}
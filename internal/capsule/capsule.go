@@ -0,0 +1,58 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+// Package capsule encodes and decodes capsules sent on HTTP request streams
+// using the Capsule Protocol.
+//
+// https://www.rfc-editor.org/rfc/rfc9297#section-3.2
+package capsule
+
+import (
+	"io"
+
+	"golang.org/x/net/internal/quic/quicwire"
+)
+
+// Append appends a capsule with the given type and value to b.
+func Append(b []byte, typ uint64, value []byte) []byte {
+	b = quicwire.AppendVarint(b, typ)
+	b = quicwire.AppendVarint(b, uint64(len(value)))
+	return append(b, value...)
+}
+
+// Read reads a capsule from r.
+// The value of a capsule larger than maxLen bytes is discarded,
+// and returned as nil.
+// It returns io.EOF if r ends cleanly before the start of a capsule,
+// and io.ErrUnexpectedEOF if r ends within a capsule.
+func Read(r io.Reader, maxLen int64) (typ uint64, value []byte, err error) {
+	typ, err = quicwire.ReadVarint(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	size, err := quicwire.ReadVarint(r)
+	if err != nil {
+		return 0, nil, unexpectedEOF(err)
+	}
+	if size > uint64(maxLen) {
+		if _, err := io.CopyN(io.Discard, r, int64(size)); err != nil {
+			return 0, nil, unexpectedEOF(err)
+		}
+		return typ, nil, nil
+	}
+	value = make([]byte, size)
+	if _, err := io.ReadFull(r, value); err != nil {
+		return 0, nil, unexpectedEOF(err)
+	}
+	return typ, value, nil
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
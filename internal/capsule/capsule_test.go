@@ -0,0 +1,60 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package capsule
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	type capsule struct {
+		typ   uint64
+		value []byte
+	}
+	want := []capsule{
+		{0x00, []byte("datagram")},
+		{0x2843, []byte{}},
+		{0x190b4d3b, []byte("close")},
+	}
+	var b []byte
+	for _, c := range want {
+		b = Append(b, c.typ, c.value)
+	}
+	r := bytes.NewReader(b)
+	for _, w := range want {
+		typ, value, err := Read(r, 16)
+		if err != nil || typ != w.typ || !bytes.Equal(value, w.value) {
+			t.Fatalf("Read = %v, %q, %v; want %v, %q, nil", typ, value, err, w.typ, w.value)
+		}
+	}
+	if _, _, err := Read(r, 16); err != io.EOF {
+		t.Errorf("Read at end of input = %v, want io.EOF", err)
+	}
+}
+
+func TestReadDiscardsLargeCapsule(t *testing.T) {
+	b := Append(nil, 1, make([]byte, 100))
+	b = Append(b, 2, []byte("small"))
+	r := bytes.NewReader(b)
+	if typ, value, err := Read(r, 10); err != nil || typ != 1 || value != nil {
+		t.Errorf("Read large capsule = %v, %q, %v; want 1, nil, nil", typ, value, err)
+	}
+	if typ, value, err := Read(r, 10); err != nil || typ != 2 || string(value) != "small" {
+		t.Errorf("Read after large capsule = %v, %q, %v; want 2, %q, nil", typ, value, err, "small")
+	}
+}
+
+func TestReadTruncated(t *testing.T) {
+	b := Append(nil, 1, []byte("value"))
+	for i := 1; i < len(b); i++ {
+		if _, _, err := Read(bytes.NewReader(b[:i]), 16); err != io.ErrUnexpectedEOF {
+			t.Errorf("Read(%x) = %v, want io.ErrUnexpectedEOF", b[:i], err)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package doq
+
+import (
+	"context"
+
+	"golang.org/x/net/internal/quic"
+)
+
+// Exchange sends a DNS query on a DoQ connection and returns the response.
+// The connection's TLS configuration must have negotiated the "doq" protocol.
+//
+// The query's Message ID is sent as 0, as DoQ requires, and the response's
+// Message ID is set to the query's original Message ID.
+//
+// If ctx is canceled before the response is received,
+// the query is canceled with ErrRequestCancelled.
+// If the server resets the stream, Exchange returns the server's ErrorCode.
+func Exchange(ctx context.Context, qconn *quic.Conn, query []byte) ([]byte, error) {
+	st, err := qconn.NewStream(ctx)
+	if err != nil {
+		return nil, err
+	}
+	id := messageID(query)
+	if err := writeMessage(st, setMessageID(query, 0)); err != nil {
+		st.Reset(uint64(ErrRequestCancelled))
+		st.CloseRead()
+		return nil, err
+	}
+	resp, err := readMessage(ctx, st)
+	if err != nil {
+		// "[...] the client can cancel the query by sending a RESET_STREAM
+		// and a STOP_SENDING frame with error code DOQ_REQUEST_CANCELLED."
+		// https://www.rfc-editor.org/rfc/rfc9250#section-4.5
+		st.StopSending(uint64(ErrRequestCancelled))
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	return setMessageID(resp, id), nil
+}
@@ -0,0 +1,165 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+// Package doq implements DNS over Dedicated QUIC Connections (DoQ).
+//
+// Each DNS query and its response are exchanged on a separate
+// bidirectional QUIC stream, prefixed by a two-byte length.
+// This package does not parse DNS messages, beyond the Message ID.
+//
+// https://www.rfc-editor.org/rfc/rfc9250
+package doq
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/net/internal/quic"
+)
+
+// NextProto is the ALPN protocol identifier for DoQ.
+// https://www.rfc-editor.org/rfc/rfc9250#section-4.1
+const NextProto = "doq"
+
+// DefaultPort is the default UDP port for DoQ.
+// https://www.rfc-editor.org/rfc/rfc9250#section-4.1.1
+const DefaultPort = 853
+
+// An ErrorCode is a DoQ error code,
+// sent when closing a connection or terminating a stream.
+// https://www.rfc-editor.org/rfc/rfc9250#section-4.3
+type ErrorCode uint64
+
+const (
+	ErrNoError          = ErrorCode(0x0)
+	ErrInternalError    = ErrorCode(0x1)
+	ErrProtocolError    = ErrorCode(0x2)
+	ErrRequestCancelled = ErrorCode(0x3)
+	ErrExcessiveLoad    = ErrorCode(0x4)
+	ErrUnspecifiedError = ErrorCode(0x5)
+)
+
+func (e ErrorCode) Error() string {
+	switch e {
+	case ErrNoError:
+		return "DOQ_NO_ERROR"
+	case ErrInternalError:
+		return "DOQ_INTERNAL_ERROR"
+	case ErrProtocolError:
+		return "DOQ_PROTOCOL_ERROR"
+	case ErrRequestCancelled:
+		return "DOQ_REQUEST_CANCELLED"
+	case ErrExcessiveLoad:
+		return "DOQ_EXCESSIVE_LOAD"
+	case ErrUnspecifiedError:
+		return "DOQ_UNSPECIFIED_ERROR"
+	}
+	return fmt.Sprintf("DOQ_ERROR_%x", uint64(e))
+}
+
+func init() {
+	// Render DoQ error codes by name in QUIC errors and traces.
+	codes := &quic.ErrorCodeSpace{}
+	for e := ErrNoError; e <= ErrUnspecifiedError; e++ {
+		codes.Add(uint64(e), e.Error())
+	}
+	quic.RegisterErrorCodeSpace(NextProto, codes)
+}
+
+// maxMessageLen is the largest DNS message which can be sent
+// with a two-byte length prefix.
+const maxMessageLen = 0xffff
+
+// writeMessage writes a DNS message to st, prefixed by its length,
+// and closes the write side of the stream.
+// https://www.rfc-editor.org/rfc/rfc9250#section-4.2
+func writeMessage(st *quic.Stream, msg []byte) error {
+	if len(msg) > maxMessageLen {
+		return errors.New("doq: DNS message too large")
+	}
+	b := binary.BigEndian.AppendUint16(make([]byte, 0, 2+len(msg)), uint16(len(msg)))
+	b = append(b, msg...)
+	if _, err := st.Write(b); err != nil {
+		return streamError(err)
+	}
+	st.CloseWrite()
+	return nil
+}
+
+// readMessage reads a length-prefixed DNS message from st.
+// The message must be followed by the end of the stream.
+func readMessage(ctx context.Context, st *quic.Stream) ([]byte, error) {
+	var hdr [2]byte
+	if _, err := io.ReadFull(contextReader{ctx, st}, hdr[:]); err != nil {
+		return nil, truncatedError(err)
+	}
+	msg := make([]byte, binary.BigEndian.Uint16(hdr[:]))
+	if _, err := io.ReadFull(contextReader{ctx, st}, msg); err != nil {
+		return nil, truncatedError(err)
+	}
+	// "[...] the STREAM FIN bit is used to indicate that no further data
+	// will be sent on a stream."
+	// https://www.rfc-editor.org/rfc/rfc9250#section-4.2-5
+	var extra [1]byte
+	if n, err := (contextReader{ctx, st}).Read(extra[:]); n > 0 {
+		return nil, ErrProtocolError
+	} else if err != io.EOF {
+		return nil, streamError(err)
+	}
+	return msg, nil
+}
+
+// truncatedError converts an error reading a message into the error to return.
+func truncatedError(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		// "If a peer receives a stream [...] which is closed before the
+		// complete message is received, this MUST be treated as a
+		// DOQ_PROTOCOL_ERROR."
+		return ErrProtocolError
+	}
+	return streamError(err)
+}
+
+// streamError converts a QUIC stream error containing a DoQ error code
+// into an ErrorCode.
+func streamError(err error) error {
+	var code quic.StreamErrorCode
+	if errors.As(err, &code) {
+		return ErrorCode(code)
+	}
+	return err
+}
+
+// A contextReader reads from a stream, bounded by a context.
+type contextReader struct {
+	ctx context.Context
+	st  *quic.Stream
+}
+
+func (r contextReader) Read(b []byte) (int, error) {
+	return r.st.ReadContext(r.ctx, b)
+}
+
+// messageID returns the Message ID of a DNS message.
+// It returns 0 if the message is too short to contain one.
+func messageID(msg []byte) uint16 {
+	if len(msg) < 2 {
+		return 0
+	}
+	return binary.BigEndian.Uint16(msg)
+}
+
+// setMessageID returns a copy of msg with its Message ID set to id.
+func setMessageID(msg []byte, id uint16) []byte {
+	msg = append([]byte(nil), msg...)
+	if len(msg) >= 2 {
+		binary.BigEndian.PutUint16(msg, id)
+	}
+	return msg
+}
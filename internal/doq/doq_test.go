@@ -0,0 +1,118 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package doq
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/net/internal/quic"
+	"golang.org/x/net/internal/quic/quictest"
+)
+
+// newTestConn starts a server using h, and returns a client connection to it.
+func newTestConn(t *testing.T, h Handler) *quic.Conn {
+	t.Helper()
+	l, err := quic.Listen("udp", "127.0.0.1:0", &quic.Config{
+		TLSConfig: &tls.Config{
+			MinVersion:   tls.VersionTLS13,
+			Certificates: []tls.Certificate{quictest.LocalhostCertificate(t)},
+			NextProtos:   []string{NextProto},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &Server{Handler: h}
+	go srv.Serve(l)
+	t.Cleanup(func() {
+		l.Close(context.Background())
+	})
+
+	cl, err := quic.Listen("udp", "127.0.0.1:0", &quic.Config{
+		TLSConfig: &tls.Config{
+			MinVersion:         tls.VersionTLS13,
+			InsecureSkipVerify: true,
+			NextProtos:         []string{NextProto},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cl.Close(context.Background())
+	})
+	qconn, err := cl.Dial(quictest.Context(t), "udp", l.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return qconn
+}
+
+func TestExchange(t *testing.T) {
+	qconn := newTestConn(t, HandlerFunc(func(ctx context.Context, query []byte) ([]byte, error) {
+		if id := messageID(query); id != 0 {
+			t.Errorf("server received query with Message ID %v, want 0", id)
+		}
+		return append([]byte{0, 0, 'r', 'e'}, query[2:]...), nil
+	}))
+	ctx := quictest.Context(t)
+	for _, query := range []string{"\x12\x34query1", "\xab\xcdquery2"} {
+		resp, err := Exchange(ctx, qconn, []byte(query))
+		if err != nil {
+			t.Fatalf("Exchange(%q): %v", query, err)
+		}
+		if want := query[:2] + "re" + query[2:]; string(resp) != want {
+			t.Errorf("Exchange(%q) = %q, want %q", query, resp, want)
+		}
+	}
+}
+
+func TestExchangeServerError(t *testing.T) {
+	qconn := newTestConn(t, HandlerFunc(func(ctx context.Context, query []byte) ([]byte, error) {
+		return nil, ErrExcessiveLoad
+	}))
+	_, err := Exchange(quictest.Context(t), qconn, []byte("\x00\x00query"))
+	if !errors.Is(err, ErrExcessiveLoad) {
+		t.Errorf("Exchange with server error: %v, want %v", err, ErrExcessiveLoad)
+	}
+}
+
+func TestExchangeCanceled(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+	qconn := newTestConn(t, HandlerFunc(func(ctx context.Context, query []byte) ([]byte, error) {
+		select {
+		case <-block:
+		case <-ctx.Done():
+		}
+		return nil, ErrRequestCancelled
+	}))
+	ctx, cancel := context.WithTimeout(quictest.Context(t), 10*time.Millisecond)
+	defer cancel()
+	if _, err := Exchange(ctx, qconn, []byte("\x00\x00query")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Exchange with expired context: %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestErrorCodeString(t *testing.T) {
+	for _, test := range []struct {
+		code ErrorCode
+		want string
+	}{
+		{ErrNoError, "DOQ_NO_ERROR"},
+		{ErrRequestCancelled, "DOQ_REQUEST_CANCELLED"},
+		{0x42, "DOQ_ERROR_42"},
+	} {
+		if got := test.code.Error(); got != test.want {
+			t.Errorf("ErrorCode(%#x).Error() = %q, want %q", uint64(test.code), got, test.want)
+		}
+	}
+}
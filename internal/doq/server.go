@@ -0,0 +1,129 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package doq
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"golang.org/x/net/internal/quic"
+)
+
+// A Handler responds to a DNS query.
+//
+// ServeDNS returns the response to the query, a complete DNS message.
+// The Message ID of the query is always 0, and the response's Message ID
+// should also be 0.
+// If ServeDNS returns an error, the stream is reset with
+// ErrInternalError, or with the error if it is an ErrorCode.
+//
+// The context is canceled when the connection is closed.
+type Handler interface {
+	ServeDNS(ctx context.Context, query []byte) ([]byte, error)
+}
+
+// The HandlerFunc type is an adapter to allow the use of
+// ordinary functions as DNS handlers.
+type HandlerFunc func(ctx context.Context, query []byte) ([]byte, error)
+
+// ServeDNS calls f(ctx, query).
+func (f HandlerFunc) ServeDNS(ctx context.Context, query []byte) ([]byte, error) {
+	return f(ctx, query)
+}
+
+// A Server is a DoQ server.
+type Server struct {
+	// Handler is the handler to invoke for queries.
+	Handler Handler
+
+	// ErrorLog is the logger for errors, such as malformed queries.
+	// If nil, errors are not logged.
+	ErrorLog *log.Logger
+}
+
+// Serve accepts connections on l and serves DNS queries on them.
+// The Listener's TLS configuration must include "doq" in its NextProtos.
+// Serve returns when l is closed, and always returns a non-nil error.
+func (s *Server) Serve(l *quic.Listener) error {
+	for {
+		qconn, err := l.Accept(context.Background())
+		if err != nil {
+			return err
+		}
+		go s.serveConn(qconn)
+	}
+}
+
+func (s *Server) serveConn(qconn *quic.Conn) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		qconn.Wait(ctx)
+		cancel()
+	}()
+	for {
+		st, err := qconn.AcceptStream(context.Background())
+		if err != nil {
+			return
+		}
+		if st.IsReadOnly() {
+			// DoQ uses only bidirectional streams.
+			// https://www.rfc-editor.org/rfc/rfc9250#section-4.2
+			qconn.Abort(&quic.ApplicationError{
+				Code:   uint64(ErrProtocolError),
+				Reason: "unidirectional stream",
+			})
+			return
+		}
+		go s.serveQuery(ctx, qconn, st)
+	}
+}
+
+// serveQuery reads a query from st and responds to it.
+func (s *Server) serveQuery(ctx context.Context, qconn *quic.Conn, st *quic.Stream) {
+	query, err := readMessage(ctx, st)
+	if err != nil {
+		if err == ErrProtocolError {
+			s.logf("doq: malformed query from %v", qconn)
+			qconn.Abort(&quic.ApplicationError{
+				Code:   uint64(ErrProtocolError),
+				Reason: "malformed query",
+			})
+		}
+		st.Reset(uint64(ErrRequestCancelled))
+		return
+	}
+	// "When sending queries over a QUIC connection, the DNS Message ID
+	// MUST be set to 0. [...] If a DoQ server receives a query with a
+	// Message ID not equal to 0, it MUST treat it as a DOQ_PROTOCOL_ERROR."
+	// https://www.rfc-editor.org/rfc/rfc9250#section-4.2.1
+	if messageID(query) != 0 {
+		s.logf("doq: query with non-zero Message ID from %v", qconn)
+		qconn.Abort(&quic.ApplicationError{
+			Code:   uint64(ErrProtocolError),
+			Reason: "non-zero Message ID",
+		})
+		return
+	}
+	resp, err := s.Handler.ServeDNS(ctx, query)
+	if err != nil {
+		code := ErrInternalError
+		errors.As(err, &code)
+		st.Reset(uint64(code))
+		return
+	}
+	if err := writeMessage(st, resp); err != nil {
+		st.Reset(uint64(ErrInternalError))
+	}
+}
+
+func (s *Server) logf(format string, args ...any) {
+	if s.ErrorLog != nil {
+		s.ErrorLog.Printf(format, args...)
+	}
+}
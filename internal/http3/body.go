@@ -0,0 +1,191 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package http3
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"sync/atomic"
+)
+
+// A bodyReader reads a request or response body from the DATA frames
+// on a stream, followed by an optional trailer section.
+// https://www.rfc-editor.org/rfc/rfc9114#section-4.1
+type bodyReader struct {
+	conn *genericConn
+	st   *stream
+
+	// remain is the number of body bytes remaining as declared by
+	// the Content-Length header, or -1 if the length is unknown.
+	remain int64
+
+	// trailer is populated with the values of declared trailers
+	// when the trailer section is received. It may be nil.
+	trailer http.Header
+
+	maxHeaderBytes int64
+
+	// stopCode is the error code sent to the peer if the body is closed
+	// before it has been fully read.
+	stopCode http3Error
+
+	err    error // sticky error returned by Read; only accessed by Read
+	closed atomic.Bool
+}
+
+var errBodyClosed = errors.New("http3: read on closed body")
+
+func (r *bodyReader) Read(p []byte) (n int, err error) {
+	if r.closed.Load() {
+		return 0, errBodyClosed
+	}
+	if r.err != nil {
+		return 0, r.err
+	}
+	n, err = r.read(p)
+	if err != nil {
+		if err != io.EOF {
+			r.conn.handleStreamError(r.st, err)
+		}
+		r.err = err
+	}
+	return n, err
+}
+
+func (r *bodyReader) read(p []byte) (int, error) {
+	for r.st.lim <= 0 {
+		if r.st.lim == 0 {
+			// We've finished reading a DATA frame.
+			if err := r.st.endFrame(); err != nil {
+				return 0, err
+			}
+		}
+		ftype, err := r.st.readFrameHeader()
+		if err == io.EOF {
+			return 0, r.endOfBody()
+		}
+		if err != nil {
+			return 0, err
+		}
+		switch ftype {
+		case frameTypeData:
+		case frameTypeHeaders:
+			if err := r.readTrailer(); err != nil {
+				return 0, err
+			}
+			return 0, r.endOfBody()
+		default:
+			if err := discardUnknownFrame(r.st, ftype); err != nil {
+				return 0, err
+			}
+		}
+	}
+	n, err := r.st.readFrameData(p)
+	if r.remain >= 0 {
+		if int64(n) > r.remain {
+			return 0, errViolation(ViolationContentLength, "body larger than Content-Length")
+		}
+		r.remain -= int64(n)
+	}
+	return n, err
+}
+
+// readTrailer reads the trailer section, and checks that
+// it is the last frame on the stream.
+func (r *bodyReader) readTrailer() error {
+	fs, err := readHeaders(r.st, r.maxHeaderBytes)
+	if err != nil {
+		return err
+	}
+	if len(fs.pseudo) > 0 {
+		return errViolation(ViolationPseudoHeader, "pseudo-header field in trailer section")
+	}
+	for k, vv := range fs.header {
+		if _, ok := r.trailer[k]; ok {
+			r.trailer[k] = vv
+		}
+	}
+	for {
+		ftype, err := r.st.readFrameHeader()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if ftype == frameTypeData || ftype == frameTypeHeaders {
+			return errFrameUnexpected(ftype)
+		}
+		if err := discardUnknownFrame(r.st, ftype); err != nil {
+			return err
+		}
+	}
+}
+
+// endOfBody is called when the body ends,
+// and checks that the body has the declared length.
+func (r *bodyReader) endOfBody() error {
+	if r.remain > 0 {
+		return errViolation(ViolationContentLength, "body smaller than Content-Length")
+	}
+	return io.EOF
+}
+
+// Close closes the body.
+// If the body has not been fully read, the peer is asked to stop sending.
+func (r *bodyReader) Close() error {
+	r.closed.Store(true)
+	r.st.stream.StopSending(uint64(r.stopCode))
+	return nil
+}
+
+// discardUnknownFrame discards a frame on a request stream
+// which is not DATA or HEADERS.
+// Frames which may not appear on a request stream are an error.
+// https://www.rfc-editor.org/rfc/rfc9114#section-4.1-7
+func discardUnknownFrame(st *stream, ftype frameType) error {
+	switch ftype {
+	case frameTypeCancelPush, frameTypeSettings, frameTypeGoaway, frameTypeMaxPushID:
+		return errFrameUnexpected(ftype)
+	case frameTypePushPromise:
+		// We never send MAX_PUSH_ID, so the peer may not push.
+		return &connectionError{
+			code:    errH3IDError,
+			message: "PUSH_PROMISE received without MAX_PUSH_ID",
+		}
+	}
+	if isHTTP2FrameType(ftype) {
+		return errFrameUnexpected(ftype)
+	}
+	return st.discardFrame()
+}
+
+// writeData writes p to st as a DATA frame.
+func writeData(st *stream, p []byte) error {
+	if len(p) == 0 {
+		return nil
+	}
+	hdr := appendFrameHeader(make([]byte, 0, 16), frameTypeData, int64(len(p)))
+	if _, err := st.stream.Write(hdr); err != nil {
+		return err
+	}
+	_, err := st.stream.Write(p)
+	return err
+}
+
+// writeTrailer writes a trailer section containing the fields of trailer,
+// if any.
+func writeTrailer(st *stream, trailer http.Header) error {
+	if len(trailer) == 0 {
+		return nil
+	}
+	b := encodeFieldSection(func(yield func(name, value string)) {
+		appendHeaderFields(yield, trailer)
+	})
+	return st.writeFrame(frameTypeHeaders, b)
+}
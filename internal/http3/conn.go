@@ -0,0 +1,333 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package http3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"golang.org/x/net/internal/quic"
+	"golang.org/x/net/internal/quic/quicwire"
+)
+
+// A genericConn is the state common to client and server HTTP/3 connections.
+type genericConn struct {
+	qconn *quic.Conn
+	ext   Extension // may be nil
+
+	// onViolation, if not nil, is called when a malformed message
+	// is received, and reports whether to close the connection.
+	onViolation func(MessageViolation) bool
+
+	// controlStream is our control stream.
+	controlStream *stream
+
+	// settings are the SETTINGS parameters we send.
+	settings []Setting
+
+	// peerSettings are the SETTINGS parameters sent by the peer.
+	// It is set before settingsc is closed, and not modified after.
+	peerSettings []Setting
+	settingsc    chan struct{}
+
+	// donec is closed when the connection is closed.
+	donec chan struct{}
+
+	mu sync.Mutex
+	// peerStreams records which critical unidirectional streams
+	// the peer has created.
+	// Each may only be created once.
+	peerStreams map[streamType]bool
+}
+
+// A streamHandler handles the side-specific parts of an HTTP/3 connection.
+type streamHandler interface {
+	// handleRequestStream handles a bidirectional stream created by the peer.
+	handleRequestStream(*stream) error
+
+	// handlePushStream handles a push stream created by the peer.
+	handlePushStream(*stream) error
+
+	// handleGoaway handles a GOAWAY frame on the peer's control stream.
+	handleGoaway(id int64) error
+
+	// handleMaxPushID handles a MAX_PUSH_ID frame on the peer's control stream.
+	handleMaxPushID(id int64) error
+}
+
+func newGenericConn(qconn *quic.Conn, ext Extension) *genericConn {
+	return &genericConn{
+		qconn:       qconn,
+		ext:         ext,
+		settings:    extensionSettings(ext),
+		settingsc:   make(chan struct{}),
+		donec:       make(chan struct{}),
+		peerStreams: make(map[streamType]bool),
+	}
+}
+
+// openControlStream creates our control stream, and sends our SETTINGS.
+// https://www.rfc-editor.org/rfc/rfc9114#section-6.2.1
+func (c *genericConn) openControlStream(ctx context.Context) error {
+	qs, err := c.qconn.NewSendOnlyStream(ctx)
+	if err != nil {
+		return err
+	}
+	st := newStream(qs)
+	// We send no HTTP/3 settings: The defaults for the QPACK settings
+	// are a dynamic table capacity of zero and no blocked streams,
+	// which is what we want, and we do not limit the peer's field section size.
+	// Extensions may add their own settings.
+	var payload []byte
+	for _, s := range c.settings {
+		payload = quicwire.AppendVarint(payload, uint64(s.ID))
+		payload = quicwire.AppendVarint(payload, uint64(s.Value))
+	}
+	b := quicwire.AppendVarint(nil, uint64(streamTypeControl))
+	b = appendFrameHeader(b, frameTypeSettings, int64(len(payload)))
+	b = append(b, payload...)
+	if _, err := st.stream.Write(b); err != nil {
+		return err
+	}
+	c.controlStream = st
+	return nil
+}
+
+// acceptStreams accepts streams created by the peer,
+// until the connection is closed.
+func (c *genericConn) acceptStreams(h streamHandler) {
+	defer close(c.donec)
+	if c.sendsSetting(SettingH3Datagram) {
+		go c.receiveDatagrams()
+	}
+	for {
+		qs, err := c.qconn.AcceptStream(context.Background())
+		if err != nil {
+			// The connection has been closed.
+			return
+		}
+		go func() {
+			if c.handleExtensionStream(qs) {
+				return
+			}
+			st := newStream(qs)
+			if !qs.IsReadOnly() {
+				c.handleStreamError(st, h.handleRequestStream(st))
+			} else {
+				c.handleStreamError(st, c.handleUnidirectionalStream(st, h))
+			}
+		}()
+	}
+}
+
+// sendsSetting reports whether we send the setting id with a value of 1.
+func (c *genericConn) sendsSetting(id int64) bool {
+	for _, s := range c.settings {
+		if s.ID == id {
+			return s.Value == 1
+		}
+	}
+	return false
+}
+
+// waitPeerSettings waits for the peer's SETTINGS frame,
+// and returns its parameters.
+func (c *genericConn) waitPeerSettings(ctx context.Context) ([]Setting, error) {
+	select {
+	case <-c.settingsc:
+		return c.peerSettings, nil
+	case <-c.donec:
+		return nil, errors.New("http3: connection closed")
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// handleUnidirectionalStream reads the type of a unidirectional stream
+// created by the peer, and handles the stream.
+// https://www.rfc-editor.org/rfc/rfc9114#section-6.2
+func (c *genericConn) handleUnidirectionalStream(st *stream, h streamHandler) error {
+	v, err := st.readVarint()
+	if err != nil {
+		// "A receiver MUST tolerate unidirectional streams being closed or reset
+		// prior to the reception of the unidirectional stream header."
+		// https://www.rfc-editor.org/rfc/rfc9114#section-6.2-8
+		return nil
+	}
+	stype := streamType(v)
+	switch stype {
+	case streamTypeControl, streamTypeEncoder, streamTypeDecoder:
+		c.mu.Lock()
+		dup := c.peerStreams[stype]
+		c.peerStreams[stype] = true
+		c.mu.Unlock()
+		if dup {
+			return &connectionError{
+				code:    errH3StreamCreationError,
+				message: fmt.Sprintf("multiple %v streams", stype),
+			}
+		}
+	}
+	switch stype {
+	case streamTypeControl:
+		return c.handleControlStream(st, h)
+	case streamTypePush:
+		return h.handlePushStream(st)
+	case streamTypeEncoder, streamTypeDecoder:
+		return c.handleQPACKStream(st)
+	default:
+		// "Recipients of unknown stream types MUST either abort reading
+		// of the stream or discard incoming data without further processing."
+		// https://www.rfc-editor.org/rfc/rfc9114#section-6.2-7
+		st.stream.StopSending(uint64(errH3StreamCreationError))
+		return nil
+	}
+}
+
+// handleControlStream reads frames from the peer's control stream.
+// https://www.rfc-editor.org/rfc/rfc9114#section-6.2.1
+func (c *genericConn) handleControlStream(st *stream, h streamHandler) error {
+	// "The first frame on the control stream MUST be SETTINGS [...]"
+	// https://www.rfc-editor.org/rfc/rfc9114#section-7.2.4-2
+	ftype, err := st.readFrameHeader()
+	if err != nil {
+		return closedCriticalStreamError(err)
+	}
+	if ftype != frameTypeSettings {
+		return &connectionError{
+			code:    errH3MissingSettings,
+			message: "control stream does not begin with SETTINGS",
+		}
+	}
+	var settings []Setting
+	if err := st.readSettings(func(id, value int64) error {
+		// We use no settings ourselves, but record them for extensions.
+		// Unknown settings, and the settings we do not use, are ignored.
+		settings = append(settings, Setting{ID: id, Value: value})
+		return nil
+	}); err != nil {
+		return err
+	}
+	c.peerSettings = settings
+	close(c.settingsc)
+	for {
+		ftype, err := st.readFrameHeader()
+		if err != nil {
+			return closedCriticalStreamError(err)
+		}
+		switch ftype {
+		case frameTypeGoaway:
+			id, err := st.readVarint()
+			if err != nil {
+				return err
+			}
+			if err := st.endFrame(); err != nil {
+				return err
+			}
+			if err := h.handleGoaway(id); err != nil {
+				return err
+			}
+		case frameTypeMaxPushID:
+			id, err := st.readVarint()
+			if err != nil {
+				return err
+			}
+			if err := st.endFrame(); err != nil {
+				return err
+			}
+			if err := h.handleMaxPushID(id); err != nil {
+				return err
+			}
+		case frameTypeCancelPush:
+			// We never push or accept pushes, so there is nothing to cancel.
+			if err := st.discardFrame(); err != nil {
+				return err
+			}
+		case frameTypeData, frameTypeHeaders, frameTypeSettings, frameTypePushPromise:
+			return errFrameUnexpected(ftype)
+		default:
+			if isHTTP2FrameType(ftype) {
+				return errFrameUnexpected(ftype)
+			}
+			// "Implementations MUST discard frames [...] that have unknown
+			// or unsupported types."
+			// https://www.rfc-editor.org/rfc/rfc9114#section-9-3
+			if err := st.discardFrame(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// handleQPACKStream handles the peer's QPACK encoder or decoder stream.
+//
+// Since we have no dynamic table, and the peer may not use ours,
+// these streams carry nothing of interest to us.
+// We discard their contents, but they must not be closed.
+func (c *genericConn) handleQPACKStream(st *stream) error {
+	_, err := io.Copy(io.Discard, st.r)
+	return closedCriticalStreamError(err)
+}
+
+// closedCriticalStreamError returns the error for a critical stream
+// closed by the peer, or err if the stream failed for another reason.
+func closedCriticalStreamError(err error) error {
+	var cerr *connectionError
+	if errors.As(err, &cerr) {
+		return err
+	}
+	// "If either control stream is closed at any point,
+	// this MUST be treated as a connection error of type H3_CLOSED_CRITICAL_STREAM."
+	// https://www.rfc-editor.org/rfc/rfc9114#section-6.2.1-2
+	return &connectionError{
+		code:    errH3ClosedCriticalStream,
+		message: "critical stream closed",
+	}
+}
+
+func errFrameUnexpected(ftype frameType) error {
+	return &connectionError{
+		code:    errH3FrameUnexpected,
+		message: fmt.Sprintf("unexpected %v frame", ftype),
+	}
+}
+
+// handleStreamError handles an error which occurred while handling st.
+// A connection error terminates the connection,
+// and any other error terminates the stream.
+func (c *genericConn) handleStreamError(st *stream, err error) {
+	if err == nil {
+		return
+	}
+	var cerr *connectionError
+	if errors.As(err, &cerr) {
+		c.abort(cerr)
+		return
+	}
+	var serr *streamError
+	if c.onViolation != nil && errors.As(err, &serr) && serr.violation != violationNone {
+		if c.onViolation(serr.violation) {
+			c.abort(&connectionError{
+				code:    serr.code,
+				message: serr.message,
+			})
+			return
+		}
+	}
+	st.resetAndStop(errorCode(err))
+}
+
+// abort terminates the connection with an error.
+func (c *genericConn) abort(err *connectionError) {
+	c.qconn.Abort(&quic.ApplicationError{
+		Code:   uint64(err.code),
+		Reason: err.message,
+	})
+}
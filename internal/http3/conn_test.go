@@ -0,0 +1,124 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package http3
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"golang.org/x/net/internal/quic"
+	"golang.org/x/net/internal/quic/quicwire"
+)
+
+// sendUnidirectionalStream opens a unidirectional stream on qconn
+// and writes b to it.
+func sendUnidirectionalStream(t *testing.T, qconn *quic.Conn, b []byte) *quic.Stream {
+	t.Helper()
+	st, err := qconn.NewSendOnlyStream(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.Write(b); err != nil {
+		t.Fatal(err)
+	}
+	return st
+}
+
+// wantConnClosed waits for the peer to close qconn with the given error code.
+func wantConnClosed(t *testing.T, qconn *quic.Conn, code http3Error) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err := qconn.Wait(ctx)
+	if !errors.Is(err, &quic.ApplicationError{Code: uint64(code)}) {
+		t.Errorf("connection closed with %v, want %v", err, code)
+	}
+}
+
+func TestConnControlStreamErrors(t *testing.T) {
+	settings := appendFrameHeader(nil, frameTypeSettings, 0)
+	for _, test := range []struct {
+		name string
+		b    []byte
+		code http3Error
+	}{{
+		name: "first frame not SETTINGS",
+		b:    appendFrameHeader(quicwire.AppendVarint(nil, uint64(streamTypeControl)), frameTypeGoaway, 0),
+		code: errH3MissingSettings,
+	}, {
+		name: "second SETTINGS frame",
+		b:    append(append(quicwire.AppendVarint(nil, uint64(streamTypeControl)), settings...), settings...),
+		code: errH3FrameUnexpected,
+	}, {
+		name: "DATA frame",
+		b:    appendFrameHeader(append(quicwire.AppendVarint(nil, uint64(streamTypeControl)), settings...), frameTypeData, 0),
+		code: errH3FrameUnexpected,
+	}, {
+		name: "HTTP/2 frame type",
+		b:    appendFrameHeader(append(quicwire.AppendVarint(nil, uint64(streamTypeControl)), settings...), 0x06, 0),
+		code: errH3FrameUnexpected,
+	}} {
+		t.Run(test.name, func(t *testing.T) {
+			addr := newTestServer(t, http.NotFoundHandler())
+			qconn := dialRawConn(t, addr)
+			sendUnidirectionalStream(t, qconn, test.b)
+			wantConnClosed(t, qconn, test.code)
+		})
+	}
+}
+
+func TestConnControlStreamClosed(t *testing.T) {
+	addr := newTestServer(t, http.NotFoundHandler())
+	qconn := dialRawConn(t, addr)
+	b := quicwire.AppendVarint(nil, uint64(streamTypeControl))
+	b = appendFrameHeader(b, frameTypeSettings, 0)
+	st := sendUnidirectionalStream(t, qconn, b)
+	st.CloseWrite()
+	wantConnClosed(t, qconn, errH3ClosedCriticalStream)
+}
+
+func TestConnDuplicateControlStream(t *testing.T) {
+	addr := newTestServer(t, http.NotFoundHandler())
+	qconn := dialRawConn(t, addr)
+	b := quicwire.AppendVarint(nil, uint64(streamTypeControl))
+	b = appendFrameHeader(b, frameTypeSettings, 0)
+	sendUnidirectionalStream(t, qconn, b)
+	sendUnidirectionalStream(t, qconn, quicwire.AppendVarint(nil, uint64(streamTypeControl)))
+	wantConnClosed(t, qconn, errH3StreamCreationError)
+}
+
+func TestConnClientPushStream(t *testing.T) {
+	addr := newTestServer(t, http.NotFoundHandler())
+	qconn := dialRawConn(t, addr)
+	sendUnidirectionalStream(t, qconn, quicwire.AppendVarint(nil, uint64(streamTypePush)))
+	wantConnClosed(t, qconn, errH3StreamCreationError)
+}
+
+func TestConnUnknownStreamTypeIgnored(t *testing.T) {
+	addr := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	qconn := dialRawConn(t, addr)
+	sendUnidirectionalStream(t, qconn, quicwire.AppendVarint(nil, 0x21)) // reserved stream type
+
+	// The connection remains usable.
+	st, err := qconn.NewStream(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest("GET", "https://"+addr+"/", nil)
+	hs := newStream(st)
+	if err := hs.writeFrame(frameTypeHeaders, encodeRequestHeaders(req)); err != nil {
+		t.Fatal(err)
+	}
+	st.CloseWrite()
+	ftype, err := hs.readFrameHeader()
+	if err != nil || ftype != frameTypeHeaders {
+		t.Fatalf("reading response: frame %v, %v; want HEADERS", ftype, err)
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package http3
+
+import (
+	"fmt"
+
+	"golang.org/x/net/internal/quic"
+)
+
+// An http3Error is an HTTP/3 or QPACK error code.
+// https://www.rfc-editor.org/rfc/rfc9114#section-8.1
+// https://www.rfc-editor.org/rfc/rfc9204#section-6
+type http3Error int64
+
+const (
+	errH3NoError              = http3Error(0x100)
+	errH3GeneralProtocolError = http3Error(0x101)
+	errH3InternalError        = http3Error(0x102)
+	errH3StreamCreationError  = http3Error(0x103)
+	errH3ClosedCriticalStream = http3Error(0x104)
+	errH3FrameUnexpected      = http3Error(0x105)
+	errH3FrameError           = http3Error(0x106)
+	errH3ExcessiveLoad        = http3Error(0x107)
+	errH3IDError              = http3Error(0x108)
+	errH3SettingsError        = http3Error(0x109)
+	errH3MissingSettings      = http3Error(0x10a)
+	errH3RequestRejected      = http3Error(0x10b)
+	errH3RequestCancelled     = http3Error(0x10c)
+	errH3RequestIncomplete    = http3Error(0x10d)
+	errH3MessageError         = http3Error(0x10e)
+	errH3ConnectError         = http3Error(0x10f)
+	errH3VersionFallback      = http3Error(0x110)
+
+	// https://www.rfc-editor.org/rfc/rfc9297#section-5.2
+	errH3DatagramError = http3Error(0x33)
+
+	errQPACKDecompressionFailed = http3Error(0x200)
+	errQPACKEncoderStreamError  = http3Error(0x201)
+	errQPACKDecoderStreamError  = http3Error(0x202)
+)
+
+func (e http3Error) Error() string {
+	switch e {
+	case errH3NoError:
+		return "H3_NO_ERROR"
+	case errH3GeneralProtocolError:
+		return "H3_GENERAL_PROTOCOL_ERROR"
+	case errH3InternalError:
+		return "H3_INTERNAL_ERROR"
+	case errH3StreamCreationError:
+		return "H3_STREAM_CREATION_ERROR"
+	case errH3ClosedCriticalStream:
+		return "H3_CLOSED_CRITICAL_STREAM"
+	case errH3FrameUnexpected:
+		return "H3_FRAME_UNEXPECTED"
+	case errH3FrameError:
+		return "H3_FRAME_ERROR"
+	case errH3ExcessiveLoad:
+		return "H3_EXCESSIVE_LOAD"
+	case errH3IDError:
+		return "H3_ID_ERROR"
+	case errH3SettingsError:
+		return "H3_SETTINGS_ERROR"
+	case errH3MissingSettings:
+		return "H3_MISSING_SETTINGS"
+	case errH3RequestRejected:
+		return "H3_REQUEST_REJECTED"
+	case errH3RequestCancelled:
+		return "H3_REQUEST_CANCELLED"
+	case errH3RequestIncomplete:
+		return "H3_REQUEST_INCOMPLETE"
+	case errH3MessageError:
+		return "H3_MESSAGE_ERROR"
+	case errH3ConnectError:
+		return "H3_CONNECT_ERROR"
+	case errH3VersionFallback:
+		return "H3_VERSION_FALLBACK"
+	case errH3DatagramError:
+		return "H3_DATAGRAM_ERROR"
+	case errQPACKDecompressionFailed:
+		return "QPACK_DECOMPRESSION_FAILED"
+	case errQPACKEncoderStreamError:
+		return "QPACK_ENCODER_STREAM_ERROR"
+	case errQPACKDecoderStreamError:
+		return "QPACK_DECODER_STREAM_ERROR"
+	}
+	return fmt.Sprintf("H3_ERROR(%v)", int64(e))
+}
+
+func init() {
+	// Render HTTP/3 error codes by name in QUIC errors and traces.
+	codes := &quic.ErrorCodeSpace{}
+	for e := errH3NoError; e <= errH3VersionFallback; e++ {
+		codes.Add(uint64(e), e.Error())
+	}
+	for _, e := range []http3Error{
+		errH3DatagramError,
+		errQPACKDecompressionFailed,
+		errQPACKEncoderStreamError,
+		errQPACKDecoderStreamError,
+	} {
+		codes.Add(uint64(e), e.Error())
+	}
+	quic.RegisterErrorCodeSpace(nextProtoH3, codes)
+}
+
+// A connectionError is an error which terminates the entire connection.
+// https://www.rfc-editor.org/rfc/rfc9114#section-8
+type connectionError struct {
+	code    http3Error
+	message string
+}
+
+func (e *connectionError) Error() string {
+	return fmt.Sprintf("HTTP/3 connection error: %v: %v", e.code, e.message)
+}
+
+// A streamError is an error which terminates a single request stream.
+// https://www.rfc-editor.org/rfc/rfc9114#section-8
+type streamError struct {
+	code    http3Error
+	message string
+
+	// violation is the kind of malformed message which caused the error,
+	// if any.
+	violation MessageViolation
+}
+
+func (e *streamError) Error() string {
+	return fmt.Sprintf("HTTP/3 stream error: %v: %v", e.code, e.message)
+}
@@ -0,0 +1,185 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package http3
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+
+	"golang.org/x/net/internal/quic"
+	"golang.org/x/net/internal/quic/quicwire"
+)
+
+// A Setting is an HTTP/3 SETTINGS parameter.
+// https://www.rfc-editor.org/rfc/rfc9114#section-7.2.4
+type Setting struct {
+	ID    int64
+	Value int64
+}
+
+// Settings defined by HTTP/3 extensions which this package implements.
+const (
+	// SettingEnableConnectProtocol enables the extended CONNECT method,
+	// which carries a :protocol pseudo-header field.
+	// https://www.rfc-editor.org/rfc/rfc9220#section-5
+	SettingEnableConnectProtocol = 0x08
+
+	// SettingH3Datagram enables HTTP Datagrams.
+	// https://www.rfc-editor.org/rfc/rfc9297#section-5.1
+	SettingH3Datagram = 0x33
+)
+
+// An Extension adds support for an HTTP/3 extension, such as WebTransport,
+// to a Server or Transport.
+//
+// The methods of an Extension may be called concurrently.
+type Extension interface {
+	// Settings returns the SETTINGS parameters sent on each connection.
+	//
+	// A server which sends SettingEnableConnectProtocol accepts extended CONNECT
+	// requests. The request's :protocol pseudo-header field is provided
+	// in the request header under the ":protocol" key.
+	//
+	// An endpoint which sends SettingH3Datagram receives HTTP Datagrams,
+	// which are passed to HandleDatagram.
+	// Datagrams also require the QUIC Config's MaxDatagramFrameSize to be set.
+	Settings() []Setting
+
+	// HandleStream is called for each stream created by the peer
+	// which might belong to the extension: a unidirectional stream,
+	// or a bidirectional stream which might not be a request stream.
+	// typ is the stream type of a unidirectional stream,
+	// or the first frame type of a bidirectional stream.
+	//
+	// If the extension handles streams of this type, HandleStream
+	// takes ownership of the stream and returns true.
+	// The stream type has not been read from the stream.
+	// Otherwise, HandleStream returns false and must not use the stream.
+	//
+	// HandleStream is called on a goroutine dedicated to the stream,
+	// and may block.
+	HandleStream(qconn *quic.Conn, typ int64, st *quic.Stream) bool
+
+	// HandleDatagram is called with each HTTP Datagram received on a connection.
+	// streamID is the ID of the request stream the datagram is associated with.
+	// HandleDatagram should return promptly.
+	HandleDatagram(qconn *quic.Conn, streamID int64, payload []byte)
+}
+
+// extensionSettings returns the settings the extension e sends,
+// or nil if e is nil.
+func extensionSettings(e Extension) []Setting {
+	if e == nil {
+		return nil
+	}
+	return e.Settings()
+}
+
+// handleExtensionStream offers a stream created by the peer to the connection's extension,
+// and reports whether the extension took ownership of it.
+func (c *genericConn) handleExtensionStream(qs *quic.Stream) bool {
+	if c.ext == nil {
+		return false
+	}
+	typ, err := peekVarint(qs)
+	if err != nil {
+		// Leave the error for the HTTP/3 stream handling to report.
+		return false
+	}
+	return c.ext.HandleStream(c.qconn, typ, qs)
+}
+
+// receiveDatagrams reads HTTP Datagrams from the connection
+// and passes them to the connection's extension,
+// until the connection is closed.
+// https://www.rfc-editor.org/rfc/rfc9297#section-2.1
+func (c *genericConn) receiveDatagrams() {
+	for {
+		b, err := c.qconn.ReceiveDatagram(context.Background())
+		if err != nil {
+			// The connection has been closed, or does not support datagrams.
+			return
+		}
+		q, n := quicwire.ConsumeVarint(b)
+		if n < 0 {
+			// "If an HTTP/3 Datagram which does not contain enough bytes to parse
+			// the Quarter Stream ID field is received, the receiver MUST treat it
+			// as a connection error of type H3_DATAGRAM_ERROR."
+			// https://www.rfc-editor.org/rfc/rfc9297#section-2.1-4
+			c.abort(&connectionError{
+				code:    errH3DatagramError,
+				message: "malformed datagram",
+			})
+			return
+		}
+		c.ext.HandleDatagram(c.qconn, int64(q)*4, b[n:])
+	}
+}
+
+// SendDatagram sends an HTTP Datagram associated with the request stream streamID.
+// https://www.rfc-editor.org/rfc/rfc9297#section-2.1
+func SendDatagram(qconn *quic.Conn, streamID int64, payload []byte) error {
+	b := quicwire.AppendVarint(make([]byte, 0, 8+len(payload)), uint64(streamID/4))
+	b = append(b, payload...)
+	return qconn.SendDatagram(b)
+}
+
+// peekVarint returns the variable-length integer at the start of qs,
+// without consuming it.
+func peekVarint(qs *quic.Stream) (int64, error) {
+	b, err := qs.Peek(1)
+	if len(b) < 1 {
+		if err == nil || err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, err
+	}
+	n := 1 << (b[0] >> 6)
+	b, err = qs.Peek(n)
+	if len(b) < n {
+		if err == nil || err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, err
+	}
+	v, _ := quicwire.ConsumeVarint(b)
+	return int64(v), nil
+}
+
+type requestStreamKey struct{}
+
+// A requestStream identifies the stream carrying a request.
+type requestStream struct {
+	qconn *quic.Conn
+	id    int64
+}
+
+// RequestStream returns the QUIC connection and stream ID
+// of a request received by a Server.
+// It reports false if req was not received by a Server.
+func RequestStream(req *http.Request) (qconn *quic.Conn, streamID int64, ok bool) {
+	rs, ok := req.Context().Value(requestStreamKey{}).(requestStream)
+	if !ok {
+		return nil, 0, false
+	}
+	return rs.qconn, rs.id, true
+}
+
+// ResponseStream returns the QUIC connection and stream ID
+// of a response returned by a Transport or ClientConn.
+// It reports false if resp has no body, or was not returned by a Transport.
+func ResponseStream(resp *http.Response) (qconn *quic.Conn, streamID int64, ok bool) {
+	body, ok := resp.Body.(*responseBody)
+	if !ok {
+		return nil, 0, false
+	}
+	return body.cc.qconn, body.r.st.stream.ID(), true
+}
+
+var errExtendedConnectUnsupported = errors.New("http3: server does not support extended CONNECT")
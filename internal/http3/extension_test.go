@@ -0,0 +1,178 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package http3
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/internal/quic"
+	"golang.org/x/net/internal/quic/quicwire"
+)
+
+// testStreamType is a unidirectional stream type handled by testExtension.
+const testStreamType = 0x54
+
+// testSetting is a setting sent by testExtension.
+const testSetting = 0x4242
+
+// A testExtension records the streams and datagrams it receives.
+type testExtension struct {
+	settings  []Setting
+	streams   chan testExtensionData
+	datagrams chan testExtensionData
+}
+
+type testExtensionData struct {
+	id   int64 // stream type, or request stream ID of a datagram
+	data string
+}
+
+func newTestExtension(settings ...Setting) *testExtension {
+	return &testExtension{
+		settings:  settings,
+		streams:   make(chan testExtensionData, 10),
+		datagrams: make(chan testExtensionData, 10),
+	}
+}
+
+func (e *testExtension) Settings() []Setting { return e.settings }
+
+func (e *testExtension) HandleStream(qconn *quic.Conn, typ int64, st *quic.Stream) bool {
+	if typ != testStreamType {
+		return false
+	}
+	b, err := io.ReadAll(st)
+	if err != nil {
+		return true
+	}
+	_, n := quicwire.ConsumeVarint(b)
+	e.streams <- testExtensionData{typ, string(b[n:])}
+	return true
+}
+
+func (e *testExtension) HandleDatagram(qconn *quic.Conn, streamID int64, payload []byte) {
+	e.datagrams <- testExtensionData{streamID, string(payload)}
+}
+
+func receiveTestExtensionData(t *testing.T, what string, c chan testExtensionData) testExtensionData {
+	t.Helper()
+	select {
+	case d := <-c:
+		return d
+	case <-time.After(10 * time.Second):
+		t.Fatalf("timed out waiting for %v", what)
+	}
+	panic("unreachable")
+}
+
+func TestExtendedConnect(t *testing.T) {
+	streamIDc := make(chan int64, 1)
+	srvExt := newTestExtension(
+		Setting{SettingEnableConnectProtocol, 1},
+		Setting{SettingH3Datagram, 1},
+		Setting{testSetting, 7},
+	)
+	addr := startTestServer(t, &Server{
+		Extension: srvExt,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if got, want := r.Header.Get(":protocol"), "test-proto"; got != want {
+				t.Errorf(":protocol = %q, want %q", got, want)
+			}
+			if got, want := r.URL.Path, "/session"; got != want {
+				t.Errorf("request path = %q, want %q", got, want)
+			}
+			_, id, ok := RequestStream(r)
+			if !ok {
+				t.Errorf("RequestStream: not ok")
+			}
+			streamIDc <- id
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			io.Copy(w, r.Body)
+		}),
+	})
+	tr := newTestTransport(t)
+	tr.Extension = newTestExtension(Setting{SettingH3Datagram, 1})
+	ctx := context.Background()
+	cc, err := tr.Dial(ctx, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cc.Close()
+
+	settings, err := cc.PeerSettings(ctx)
+	if err != nil {
+		t.Fatalf("PeerSettings: %v", err)
+	}
+	if len(settings) != 3 || settings[2] != (Setting{testSetting, 7}) {
+		t.Errorf("PeerSettings = %v, want server's settings", settings)
+	}
+
+	pr, pw := io.Pipe()
+	req, _ := http.NewRequest("CONNECT", "https://"+addr+"/session", pr)
+	req.Header.Set(":protocol", "test-proto")
+	resp, err := cc.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		t.Fatalf("response status = %v, want 200", resp.StatusCode)
+	}
+	qconn, streamID, ok := ResponseStream(resp)
+	if !ok {
+		t.Fatalf("ResponseStream: not ok")
+	}
+	if got := <-streamIDc; got != streamID {
+		t.Errorf("server's request stream ID = %v, client's = %v", got, streamID)
+	}
+
+	// The request and response bodies remain open.
+	io.WriteString(pw, "hello")
+	b := make([]byte, 5)
+	if _, err := io.ReadFull(resp.Body, b); err != nil || string(b) != "hello" {
+		t.Errorf("read echoed body: %q, %v", b, err)
+	}
+
+	if err := SendDatagram(qconn, streamID, []byte("datagram")); err != nil {
+		t.Fatalf("SendDatagram: %v", err)
+	}
+	got := receiveTestExtensionData(t, "datagram", srvExt.datagrams)
+	if want := (testExtensionData{streamID, "datagram"}); got != want {
+		t.Errorf("server received datagram %v, want %v", got, want)
+	}
+
+	st, err := qconn.NewSendOnlyStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st.Write(append(quicwire.AppendVarint(nil, testStreamType), "stream"...))
+	st.CloseWrite()
+	got = receiveTestExtensionData(t, "stream", srvExt.streams)
+	if want := (testExtensionData{testStreamType, "stream"}); got != want {
+		t.Errorf("server received stream %v, want %v", got, want)
+	}
+	pw.Close()
+}
+
+func TestExtendedConnectUnsupported(t *testing.T) {
+	addr := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request")
+	}))
+	tr := newTestTransport(t)
+	req, _ := http.NewRequest("CONNECT", "https://"+addr+"/", strings.NewReader(""))
+	req.Header.Set(":protocol", "test-proto")
+	if _, err := tr.RoundTrip(req); !errors.Is(err, errExtendedConnectUnsupported) {
+		t.Errorf("extended CONNECT to server without support: %v, want errExtendedConnectUnsupported", err)
+	}
+}
@@ -0,0 +1,186 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package http3
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// isConnectionSpecificHeader reports whether the lowercase field name
+// is a connection-specific header field, which HTTP/3 does not use.
+// https://www.rfc-editor.org/rfc/rfc9114#section-4.2-4
+func isConnectionSpecificHeader(name string) bool {
+	switch name {
+	case "connection", "keep-alive", "proxy-connection", "transfer-encoding", "upgrade":
+		return true
+	}
+	return false
+}
+
+// appendHeaderFields calls yield for each field in h,
+// with names converted to lowercase.
+// Connection-specific fields and pseudo-header fields are omitted.
+func appendHeaderFields(yield func(name, value string), h http.Header) {
+	for k, vv := range h {
+		name := strings.ToLower(k)
+		if isConnectionSpecificHeader(name) || strings.HasPrefix(name, ":") {
+			continue
+		}
+		if name == "te" {
+			// "The only exception to this is the TE header field,
+			// which MAY be present in an HTTP/3 request header;
+			// when it is, it MUST NOT contain any value other than "trailers"."
+			// https://www.rfc-editor.org/rfc/rfc9114#section-4.2-5
+			for _, v := range vv {
+				if strings.EqualFold(v, "trailers") {
+					yield(name, "trailers")
+					break
+				}
+			}
+			continue
+		}
+		for _, v := range vv {
+			yield(name, v)
+		}
+	}
+}
+
+// validateHeaderFields reports an error if h contains an invalid field.
+// The ":protocol" pseudo-header field is permitted only if extendedConnect is set.
+func validateHeaderFields(h http.Header, extendedConnect bool) error {
+	for k, vv := range h {
+		if k == ":protocol" && !extendedConnect {
+			return fmt.Errorf("http3: %q header field in request which is not an extended CONNECT request", k)
+		}
+		if k != ":protocol" && !httpguts.ValidHeaderFieldName(k) {
+			return fmt.Errorf("http3: invalid header field name %q", k)
+		}
+		for _, v := range vv {
+			if !httpguts.ValidHeaderFieldValue(v) {
+				return fmt.Errorf("http3: invalid header field value for %q", k)
+			}
+		}
+	}
+	return nil
+}
+
+// A fieldSection is a decoded header or trailer section.
+type fieldSection struct {
+	pseudo map[string]string // pseudo-header fields, including the leading ':'
+	header http.Header
+}
+
+// decodeFields decodes a field section in the payload of a HEADERS frame,
+// and checks that it is well-formed.
+// A malformed field section is a stream error.
+// https://www.rfc-editor.org/rfc/rfc9114#section-4.1.2
+//
+// A field section larger than maxHeaderBytes when decoded is also a stream error:
+// QPACK references to the static table let a small encoded section
+// decode to many more bytes.
+func decodeFields(b []byte, maxHeaderBytes int64) (*fieldSection, error) {
+	fs := &fieldSection{
+		pseudo: make(map[string]string),
+		header: make(http.Header),
+	}
+	var cookies []string
+	sawRegular := false
+	var size int64
+	err := decodeFieldSection(b, func(name, value string) error {
+		// "The size of a field list is calculated based on the uncompressed
+		// size of fields, including the length of the name and value in bytes
+		// plus an overhead of 32 bytes for each field."
+		// https://www.rfc-editor.org/rfc/rfc9114#section-4.2.2-2
+		size += int64(len(name)) + int64(len(value)) + 32
+		if size > maxHeaderBytes {
+			return errHeaderSectionTooLarge()
+		}
+		if !validFieldName(name) || !httpguts.ValidHeaderFieldValue(value) {
+			return errMalformed("invalid field")
+		}
+		if strings.HasPrefix(name, ":") {
+			// "All pseudo-header fields MUST appear in the header section
+			// before regular header fields."
+			// https://www.rfc-editor.org/rfc/rfc9114#section-4.3-3
+			if sawRegular {
+				return errViolation(ViolationPseudoHeader, "pseudo-header field after regular field")
+			}
+			if _, ok := fs.pseudo[name]; ok {
+				return errViolation(ViolationPseudoHeader, "duplicate pseudo-header field")
+			}
+			fs.pseudo[name] = value
+			return nil
+		}
+		sawRegular = true
+		if isConnectionSpecificHeader(name) || name == "te" && value != "trailers" {
+			return errViolation(ViolationForbiddenHeader, "connection-specific header field")
+		}
+		if name == "cookie" {
+			// "If there are multiple cookie field lines after decompression,
+			// these MUST be concatenated into a single byte string [...]"
+			// https://www.rfc-editor.org/rfc/rfc9114#section-4.2.1-2
+			cookies = append(cookies, value)
+			return nil
+		}
+		key := http.CanonicalHeaderKey(name)
+		fs.header[key] = append(fs.header[key], value)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(cookies) > 0 {
+		fs.header.Set("Cookie", strings.Join(cookies, "; "))
+	}
+	return fs, nil
+}
+
+// validFieldName reports whether name is a valid, lowercase field name.
+// Pseudo-header field names have a leading ':'.
+func validFieldName(name string) bool {
+	name = strings.TrimPrefix(name, ":")
+	if !httpguts.ValidHeaderFieldName(name) {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if c := name[i]; 'A' <= c && c <= 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+func errMalformed(message string) error {
+	return &streamError{
+		code:    errH3MessageError,
+		message: message,
+	}
+}
+
+// readHeaders reads the payload of a HEADERS frame from st,
+// and decodes it.
+func readHeaders(st *stream, maxHeaderBytes int64) (*fieldSection, error) {
+	if st.lim > maxHeaderBytes {
+		return nil, errHeaderSectionTooLarge()
+	}
+	b, err := st.readFrameBytes()
+	if err != nil {
+		return nil, err
+	}
+	return decodeFields(b, maxHeaderBytes)
+}
+
+func errHeaderSectionTooLarge() error {
+	return &streamError{
+		code:    errH3ExcessiveLoad,
+		message: "header section too large",
+	}
+}
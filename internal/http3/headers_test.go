@@ -0,0 +1,158 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package http3
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeFields(t *testing.T) {
+	fs, err := decodeFields(encodeTestFields([]testField{
+		{":method", "GET"},
+		{":path", "/"},
+		{"x-a", "1"},
+		{"cookie", "a=1"},
+		{"x-a", "2"},
+		{"cookie", "b=2"},
+		{"te", "trailers"},
+	}), defaultMaxHeaderBytes)
+	if err != nil {
+		t.Fatalf("decodeFields: %v", err)
+	}
+	wantPseudo := map[string]string{":method": "GET", ":path": "/"}
+	if !reflect.DeepEqual(fs.pseudo, wantPseudo) {
+		t.Errorf("pseudo-header fields = %v, want %v", fs.pseudo, wantPseudo)
+	}
+	wantHeader := http.Header{
+		"X-A":    {"1", "2"},
+		"Cookie": {"a=1; b=2"},
+		"Te":     {"trailers"},
+	}
+	if !reflect.DeepEqual(fs.header, wantHeader) {
+		t.Errorf("header fields = %v, want %v", fs.header, wantHeader)
+	}
+}
+
+func TestDecodeFieldsMalformed(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		fields []testField
+	}{{
+		name:   "uppercase name",
+		fields: []testField{{"X-Upper", "v"}},
+	}, {
+		name:   "invalid name",
+		fields: []testField{{"x y", "v"}},
+	}, {
+		name:   "invalid value",
+		fields: []testField{{"x", "a\nb"}},
+	}, {
+		name:   "pseudo-header after regular field",
+		fields: []testField{{"x", "v"}, {":method", "GET"}},
+	}, {
+		name:   "duplicate pseudo-header",
+		fields: []testField{{":method", "GET"}, {":method", "GET"}},
+	}, {
+		name:   "connection-specific field",
+		fields: []testField{{"connection", "close"}},
+	}, {
+		name:   "transfer-encoding",
+		fields: []testField{{"transfer-encoding", "chunked"}},
+	}, {
+		name:   "te other than trailers",
+		fields: []testField{{"te", "gzip"}},
+	}} {
+		_, err := decodeFields(encodeTestFields(test.fields), defaultMaxHeaderBytes)
+		var serr *streamError
+		if !errors.As(err, &serr) || serr.code != errH3MessageError {
+			t.Errorf("%v: decodeFields = %v, want H3_MESSAGE_ERROR", test.name, err)
+		}
+	}
+}
+
+func TestValidateHeaderFields(t *testing.T) {
+	for _, test := range []struct {
+		name            string
+		header          http.Header
+		extendedConnect bool
+		wantErr         bool
+	}{{
+		name:   "valid",
+		header: http.Header{"X-Field": {"v"}},
+	}, {
+		name:    "invalid name",
+		header:  http.Header{"X Field": {"v"}},
+		wantErr: true,
+	}, {
+		name:    "invalid value",
+		header:  http.Header{"X-Field": {"a\nb"}},
+		wantErr: true,
+	}, {
+		name:            ":protocol in extended CONNECT",
+		header:          http.Header{":protocol": {"websocket"}},
+		extendedConnect: true,
+	}, {
+		name:    ":protocol in other request",
+		header:  http.Header{":protocol": {"websocket"}},
+		wantErr: true,
+	}, {
+		name:            "other pseudo-header in extended CONNECT",
+		header:          http.Header{":path": {"/"}},
+		extendedConnect: true,
+		wantErr:         true,
+	}} {
+		err := validateHeaderFields(test.header, test.extendedConnect)
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Errorf("%v: validateHeaderFields = %v, want error: %v", test.name, err, test.wantErr)
+		}
+	}
+}
+
+func TestAppendHeaderFields(t *testing.T) {
+	var got []testField
+	appendHeaderFields(func(name, value string) {
+		got = append(got, testField{name, value})
+	}, http.Header{
+		"X-Custom":          {"v"},
+		"Connection":        {"close"},
+		"Transfer-Encoding": {"chunked"},
+		"Te":                {"gzip", "Trailers"},
+	})
+	want := map[testField]bool{
+		{"x-custom", "v"}:  true,
+		{"te", "trailers"}: true,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("appendHeaderFields produced %q, want %v", got, want)
+	}
+	for _, f := range got {
+		if !want[f] {
+			t.Errorf("appendHeaderFields produced unexpected field %q", f)
+		}
+	}
+}
+
+func TestDecodeFieldsDecodedSizeLimit(t *testing.T) {
+	// Each field is a one-byte reference to the QPACK static table,
+	// but counts as much more than one byte when decoded.
+	var fields []testField
+	for i := 0; i < 100; i++ {
+		fields = append(fields, testField{"accept-encoding", "gzip, deflate, br"})
+	}
+	b := encodeTestFields(fields)
+	_, err := decodeFields(b, int64(len(b)))
+	var serr *streamError
+	if !errors.As(err, &serr) || serr.code != errH3ExcessiveLoad {
+		t.Errorf("decodeFields of %v byte section with limit %v = %v, want H3_EXCESSIVE_LOAD", len(b), len(b), err)
+	}
+	if _, err := decodeFields(b, defaultMaxHeaderBytes); err != nil {
+		t.Errorf("decodeFields with default limit = %v, want success", err)
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+// Package http3 implements HTTP/3, as defined in RFC 9114,
+// on top of the QUIC transport in golang.org/x/net/internal/quic.
+//
+// Header compression uses QPACK (RFC 9204) with no dynamic table.
+// Server push is not supported.
+package http3
+
+import "fmt"
+
+// nextProtoH3 is the ALPN protocol identifier for HTTP/3.
+// https://www.rfc-editor.org/rfc/rfc9114#section-3.1-1
+const nextProtoH3 = "h3"
+
+// A streamType is the type of a unidirectional stream.
+// https://www.rfc-editor.org/rfc/rfc9114#section-6.2
+type streamType int64
+
+const (
+	streamTypeControl = streamType(0x00)
+	streamTypePush    = streamType(0x01)
+	streamTypeEncoder = streamType(0x02) // QPACK encoder stream
+	streamTypeDecoder = streamType(0x03) // QPACK decoder stream
+)
+
+func (stype streamType) String() string {
+	switch stype {
+	case streamTypeControl:
+		return "control"
+	case streamTypePush:
+		return "push"
+	case streamTypeEncoder:
+		return "encoder"
+	case streamTypeDecoder:
+		return "decoder"
+	}
+	return fmt.Sprintf("streamType(%v)", int64(stype))
+}
+
+// A frameType is an HTTP/3 frame type.
+// https://www.rfc-editor.org/rfc/rfc9114#section-7.2
+type frameType int64
+
+const (
+	frameTypeData        = frameType(0x00)
+	frameTypeHeaders     = frameType(0x01)
+	frameTypeCancelPush  = frameType(0x03)
+	frameTypeSettings    = frameType(0x04)
+	frameTypePushPromise = frameType(0x05)
+	frameTypeGoaway      = frameType(0x07)
+	frameTypeMaxPushID   = frameType(0x0d)
+)
+
+func (ftype frameType) String() string {
+	switch ftype {
+	case frameTypeData:
+		return "DATA"
+	case frameTypeHeaders:
+		return "HEADERS"
+	case frameTypeCancelPush:
+		return "CANCEL_PUSH"
+	case frameTypeSettings:
+		return "SETTINGS"
+	case frameTypePushPromise:
+		return "PUSH_PROMISE"
+	case frameTypeGoaway:
+		return "GOAWAY"
+	case frameTypeMaxPushID:
+		return "MAX_PUSH_ID"
+	}
+	return fmt.Sprintf("frameType(%v)", int64(ftype))
+}
+
+// isHTTP2FrameType reports whether ftype is an HTTP/2 frame type
+// with no HTTP/3 equivalent. These frame types are reserved,
+// and receiving one is a connection error.
+// https://www.rfc-editor.org/rfc/rfc9114#section-7.2.8
+func isHTTP2FrameType(ftype frameType) bool {
+	switch ftype {
+	case 0x02, // PRIORITY
+		0x06, // PING
+		0x08, // WINDOW_UPDATE
+		0x09: // CONTINUATION
+		return true
+	}
+	return false
+}
+
+// Settings identifiers.
+// https://www.rfc-editor.org/rfc/rfc9114#section-7.2.4.1
+// https://www.rfc-editor.org/rfc/rfc9204#section-5
+const (
+	settingQPACKMaxTableCapacity = 0x01
+	settingMaxFieldSectionSize   = 0x06
+	settingQPACKBlockedStreams   = 0x07
+)
+
+// isHTTP2Setting reports whether id is an HTTP/2 setting
+// with no HTTP/3 equivalent. These settings are reserved,
+// and receiving one is a connection error.
+// https://www.rfc-editor.org/rfc/rfc9114#section-7.2.4.1-5
+func isHTTP2Setting(id int64) bool {
+	switch id {
+	case 0x00, 0x02, 0x03, 0x04, 0x05:
+		return true
+	}
+	return false
+}
+
+// defaultMaxHeaderBytes is the default limit on the size of
+// a HEADERS frame we will accept.
+const defaultMaxHeaderBytes = 1 << 20
@@ -0,0 +1,124 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package http3
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/internal/quic"
+)
+
+var testCert = sync.OnceValue(func() tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		panic(err)
+	}
+	return tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+	}
+})
+
+// newTestServer starts a Server serving h on a local address,
+// and returns the address.
+func newTestServer(t *testing.T, h http.Handler) string {
+	t.Helper()
+	return startTestServer(t, &Server{Handler: h})
+}
+
+// startTestServer starts srv on a local address,
+// and returns the address.
+func startTestServer(t *testing.T, srv *Server) string {
+	t.Helper()
+	l, err := quic.Listen("udp", "127.0.0.1:0", &quic.Config{
+		TLSConfig: &tls.Config{
+			MinVersion:   tls.VersionTLS13,
+			Certificates: []tls.Certificate{testCert()},
+			NextProtos:   []string{nextProtoH3},
+		},
+		MaxDatagramFrameSize: 1200,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(l)
+	t.Cleanup(func() {
+		l.Close(canceledContext())
+	})
+	return l.LocalAddr().String()
+}
+
+// newTestTransport returns a Transport which trusts any server certificate.
+func newTestTransport(t *testing.T) *Transport {
+	tr := &Transport{
+		Config: &quic.Config{
+			TLSConfig: &tls.Config{
+				MinVersion:         tls.VersionTLS13,
+				InsecureSkipVerify: true,
+			},
+			MaxDatagramFrameSize: 1200,
+		},
+	}
+	t.Cleanup(tr.CloseIdleConnections)
+	return tr
+}
+
+// dialRawConn creates a QUIC connection to an HTTP/3 server at addr,
+// for tests which send the server invalid data.
+func dialRawConn(t *testing.T, addr string) *quic.Conn {
+	t.Helper()
+	l, err := quic.Listen("udp", "127.0.0.1:0", &quic.Config{
+		TLSConfig: &tls.Config{
+			MinVersion:         tls.VersionTLS13,
+			InsecureSkipVerify: true,
+			NextProtos:         []string{nextProtoH3},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		l.Close(canceledContext())
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	qconn, err := l.Dial(ctx, "udp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return qconn
+}
+
+func canceledContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return ctx
+}
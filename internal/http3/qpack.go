@@ -0,0 +1,220 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package http3
+
+import "golang.org/x/net/http2/hpack"
+
+// QPACK (RFC 9204) header compression.
+//
+// We do not use the dynamic table: We advertise a table capacity of zero,
+// so the peer may not insert entries into our decoder's table,
+// and our encoder only uses the static table and literals.
+// Since the dynamic table is never used, we never need to open
+// the QPACK encoder or decoder streams.
+
+var errQPACKDecompression = &connectionError{
+	code:    errQPACKDecompressionFailed,
+	message: "invalid field section",
+}
+
+// appendPrefixedInt appends an integer with an N-bit prefix to b.
+// The high bits of the first byte are taken from firstByte.
+// https://www.rfc-editor.org/rfc/rfc7541#section-5.1
+func appendPrefixedInt(b []byte, firstByte byte, prefixLen uint8, v int64) []byte {
+	prefixMax := int64(1)<<prefixLen - 1
+	if v < prefixMax {
+		return append(b, firstByte|byte(v))
+	}
+	b = append(b, firstByte|byte(prefixMax))
+	v -= prefixMax
+	for v >= 128 {
+		b = append(b, 0x80|byte(v&0x7f))
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+// consumePrefixedInt parses an integer with an N-bit prefix,
+// returning the integer and the remainder of b.
+// The high bits of the first byte are ignored.
+func consumePrefixedInt(b []byte, prefixLen uint8) (v int64, rest []byte, err error) {
+	if len(b) == 0 {
+		return 0, nil, errQPACKDecompression
+	}
+	prefixMax := int64(1)<<prefixLen - 1
+	v = int64(b[0]) & prefixMax
+	b = b[1:]
+	if v < prefixMax {
+		return v, b, nil
+	}
+	for shift := 0; ; shift += 7 {
+		if len(b) == 0 || shift > 49 {
+			return 0, nil, errQPACKDecompression
+		}
+		c := b[0]
+		b = b[1:]
+		v += int64(c&0x7f) << shift
+		if c&0x80 == 0 {
+			return v, b, nil
+		}
+	}
+}
+
+// appendPrefixedString appends a string literal with an N-bit length prefix to b.
+// The string is Huffman-encoded when that is shorter.
+// The Huffman flag is the bit immediately above the prefix.
+// https://www.rfc-editor.org/rfc/rfc9204#section-4.1.2
+func appendPrefixedString(b []byte, firstByte byte, prefixLen uint8, s string) []byte {
+	huffmanFlag := byte(1) << prefixLen
+	if l := hpack.HuffmanEncodeLength(s); l < uint64(len(s)) {
+		b = appendPrefixedInt(b, firstByte|huffmanFlag, prefixLen, int64(l))
+		return hpack.AppendHuffmanString(b, s)
+	}
+	b = appendPrefixedInt(b, firstByte, prefixLen, int64(len(s)))
+	return append(b, s...)
+}
+
+// consumePrefixedString parses a string literal with an N-bit length prefix,
+// returning the string and the remainder of b.
+func consumePrefixedString(b []byte, prefixLen uint8) (s string, rest []byte, err error) {
+	if len(b) == 0 {
+		return "", nil, errQPACKDecompression
+	}
+	huffman := b[0]&(1<<prefixLen) != 0
+	size, b, err := consumePrefixedInt(b, prefixLen)
+	if err != nil {
+		return "", nil, err
+	}
+	if size > int64(len(b)) {
+		return "", nil, errQPACKDecompression
+	}
+	data := b[:size]
+	b = b[size:]
+	if !huffman {
+		return string(data), b, nil
+	}
+	s, err = hpack.HuffmanDecodeToString(data)
+	if err != nil {
+		return "", nil, errQPACKDecompression
+	}
+	return s, b, nil
+}
+
+// encodeFieldSection encodes a QPACK field section,
+// calling f to produce the fields.
+// https://www.rfc-editor.org/rfc/rfc9204#section-4.5
+func encodeFieldSection(f func(yield func(name, value string))) []byte {
+	// Encoded Field Section Prefix:
+	// A Required Insert Count of 0 and a Delta Base of 0.
+	b := []byte{0, 0}
+	f(func(name, value string) {
+		b = appendFieldLine(b, name, value)
+	})
+	return b
+}
+
+// appendFieldLine appends a field line representation to b.
+func appendFieldLine(b []byte, name, value string) []byte {
+	if i, ok := staticTableFieldIndex[staticTableEntry{name, value}]; ok {
+		// Indexed Field Line, static table.
+		// https://www.rfc-editor.org/rfc/rfc9204#section-4.5.2
+		return appendPrefixedInt(b, 0b1100_0000, 6, int64(i))
+	}
+	if i, ok := staticTableNameIndex[name]; ok {
+		// Literal Field Line with Name Reference, static table.
+		// https://www.rfc-editor.org/rfc/rfc9204#section-4.5.4
+		b = appendPrefixedInt(b, 0b0101_0000, 4, int64(i))
+		return appendPrefixedString(b, 0, 7, value)
+	}
+	// Literal Field Line with Literal Name.
+	// https://www.rfc-editor.org/rfc/rfc9204#section-4.5.6
+	b = appendPrefixedString(b, 0b0010_0000, 3, name)
+	return appendPrefixedString(b, 0, 7, value)
+}
+
+// errQPACKDynamicTable is returned when a field section references
+// the dynamic table, which we never permit the peer to use.
+var errQPACKDynamicTable = &connectionError{
+	code:    errQPACKDecompressionFailed,
+	message: "field section references dynamic table",
+}
+
+// decodeFieldSection decodes a QPACK field section,
+// calling f for each field.
+// https://www.rfc-editor.org/rfc/rfc9204#section-4.5
+func decodeFieldSection(b []byte, f func(name, value string) error) error {
+	requiredInsertCount, b, err := consumePrefixedInt(b, 8)
+	if err != nil {
+		return err
+	}
+	if requiredInsertCount != 0 {
+		return errQPACKDynamicTable
+	}
+	// The Delta Base is meaningless without a dynamic table.
+	if _, b, err = consumePrefixedInt(b, 7); err != nil {
+		return err
+	}
+	for len(b) > 0 {
+		var name, value string
+		switch {
+		case b[0]&0b1000_0000 != 0:
+			// Indexed Field Line.
+			if b[0]&0b0100_0000 == 0 {
+				return errQPACKDynamicTable
+			}
+			var i int64
+			if i, b, err = consumePrefixedInt(b, 6); err != nil {
+				return err
+			}
+			e, ok := staticTableLookup(i)
+			if !ok {
+				return errQPACKDecompression
+			}
+			name, value = e.name, e.value
+		case b[0]&0b0100_0000 != 0:
+			// Literal Field Line with Name Reference.
+			if b[0]&0b0001_0000 == 0 {
+				return errQPACKDynamicTable
+			}
+			var i int64
+			if i, b, err = consumePrefixedInt(b, 4); err != nil {
+				return err
+			}
+			e, ok := staticTableLookup(i)
+			if !ok {
+				return errQPACKDecompression
+			}
+			name = e.name
+			if value, b, err = consumePrefixedString(b, 7); err != nil {
+				return err
+			}
+		case b[0]&0b0010_0000 != 0:
+			// Literal Field Line with Literal Name.
+			if name, b, err = consumePrefixedString(b, 3); err != nil {
+				return err
+			}
+			if value, b, err = consumePrefixedString(b, 7); err != nil {
+				return err
+			}
+		default:
+			// Indexed Field Line with Post-Base Index, or
+			// Literal Field Line with Post-Base Name Reference.
+			return errQPACKDynamicTable
+		}
+		if err := f(name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func staticTableLookup(i int64) (staticTableEntry, bool) {
+	if i < 0 || i >= int64(len(staticTable)) {
+		return staticTableEntry{}, false
+	}
+	return staticTable[i], true
+}
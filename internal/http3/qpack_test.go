@@ -0,0 +1,148 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package http3
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPrefixedInt(t *testing.T) {
+	// Examples from RFC 7541, Appendix C.1.
+	for _, test := range []struct {
+		v         int64
+		prefixLen uint8
+		b         []byte
+	}{
+		{10, 5, []byte{0x0a}},
+		{1337, 5, []byte{0x1f, 0x9a, 0x0a}},
+		{42, 8, []byte{0x2a}},
+		{31, 5, []byte{0x1f, 0x00}},
+	} {
+		if got := appendPrefixedInt(nil, 0, test.prefixLen, test.v); !bytes.Equal(got, test.b) {
+			t.Errorf("appendPrefixedInt(%v, %v) = %x, want %x", test.v, test.prefixLen, got, test.b)
+		}
+		v, rest, err := consumePrefixedInt(test.b, test.prefixLen)
+		if err != nil || v != test.v || len(rest) != 0 {
+			t.Errorf("consumePrefixedInt(%x, %v) = %v, %x, %v; want %v", test.b, test.prefixLen, v, rest, err, test.v)
+		}
+	}
+	// Integer continues past the end of the input.
+	if _, _, err := consumePrefixedInt([]byte{0x1f, 0x9a}, 5); err == nil {
+		t.Errorf("consumePrefixedInt of truncated integer: no error")
+	}
+	// Integer too large.
+	if _, _, err := consumePrefixedInt([]byte{0x1f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}, 5); err == nil {
+		t.Errorf("consumePrefixedInt of overlong integer: no error")
+	}
+}
+
+type testField struct {
+	name, value string
+}
+
+func encodeTestFields(fields []testField) []byte {
+	return encodeFieldSection(func(yield func(name, value string)) {
+		for _, f := range fields {
+			yield(f.name, f.value)
+		}
+	})
+}
+
+func decodeTestFields(b []byte) ([]testField, error) {
+	var fields []testField
+	err := decodeFieldSection(b, func(name, value string) error {
+		fields = append(fields, testField{name, value})
+		return nil
+	})
+	return fields, err
+}
+
+func TestFieldSectionRoundTrip(t *testing.T) {
+	fields := []testField{
+		{":method", "GET"},                       // static table entry
+		{":path", "/index.html"},                 // static table name
+		{"x-custom", "value"},                    // literal name
+		{"x-long", strings.Repeat("abcde", 100)}, // Huffman-encoded
+		{"x-empty", ""},
+		{"x-binary", "\x7f\x00"}, // not shorter when Huffman-encoded
+	}
+	b := encodeTestFields(fields)
+	got, err := decodeTestFields(b)
+	if err != nil {
+		t.Fatalf("decodeFieldSection: %v", err)
+	}
+	if !reflect.DeepEqual(got, fields) {
+		t.Errorf("decoded fields:\n%q\nwant:\n%q", got, fields)
+	}
+}
+
+func TestFieldSectionEncoding(t *testing.T) {
+	// RFC 9204, Appendix B.1, with the value Huffman-encoded.
+	got := encodeTestFields([]testField{{":path", "/index.html"}})
+	want := []byte{
+		0x00, 0x00, // Required Insert Count = 0, Base = 0
+		0x51, 0x88, // Literal Field Line with Name Reference, static index 1
+		// Huffman-encoded "/index.html" (RFC 7541, Appendix C.4.1)
+		0x60, 0xd5, 0x48, 0x5f, 0x2b, 0xce, 0x9a, 0x68,
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("encoded :path = %x, want %x", got, want)
+	}
+	// The example in RFC 9204 does not use Huffman encoding.
+	example := []byte{
+		0x00, 0x00, 0x51, 0x0b, 0x2f, 0x69, 0x6e, 0x64,
+		0x65, 0x78, 0x2e, 0x68, 0x74, 0x6d, 0x6c,
+	}
+	fields, err := decodeTestFields(example)
+	if err != nil || len(fields) != 1 || fields[0] != (testField{":path", "/index.html"}) {
+		t.Errorf("decode RFC 9204 example = %q, %v", fields, err)
+	}
+
+	// Indexed field line for :status 200 (static index 25).
+	got = encodeTestFields([]testField{{":status", "200"}})
+	want = []byte{0x00, 0x00, 0xd9}
+	if !bytes.Equal(got, want) {
+		t.Errorf("encoded :status 200 = %x, want %x", got, want)
+	}
+}
+
+func TestFieldSectionDecodeErrors(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		b    []byte
+	}{{
+		name: "nonzero required insert count",
+		b:    []byte{0x01, 0x00},
+	}, {
+		name: "dynamic table indexed field line",
+		b:    []byte{0x00, 0x00, 0x80},
+	}, {
+		name: "post-base indexed field line",
+		b:    []byte{0x00, 0x00, 0x10},
+	}, {
+		name: "dynamic table name reference",
+		b:    []byte{0x00, 0x00, 0x40, 0x00},
+	}, {
+		name: "static index out of range",
+		b:    []byte{0x00, 0x00, 0xff, 0x25}, // index 63+37 = 100
+	}, {
+		name: "truncated string",
+		b:    []byte{0x00, 0x00, 0x51, 0x05, 'a'},
+	}, {
+		name: "invalid Huffman string",
+		b:    []byte{0x00, 0x00, 0x51, 0x81, 0x00},
+	}, {
+		name: "missing prefix",
+		b:    []byte{0x00},
+	}} {
+		_, err := decodeTestFields(test.b)
+		wantConnectionError(t, test.name, err, errQPACKDecompressionFailed)
+	}
+}
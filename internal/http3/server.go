@@ -0,0 +1,554 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package http3
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/internal/quic"
+)
+
+// A Server is an HTTP/3 server.
+type Server struct {
+	// Addr is the UDP address to listen on for ListenAndServe,
+	// in the form "host:port". If empty, ":443" is used.
+	Addr string
+
+	// Handler is the handler to invoke for requests.
+	// If nil, http.DefaultServeMux is used.
+	Handler http.Handler
+
+	// Config is the QUIC configuration used by ListenAndServe.
+	// It must be non-nil and include a TLSConfig.
+	// The "h3" protocol is added to the TLS configuration's NextProtos.
+	Config *quic.Config
+
+	// MaxHeaderBytes is the maximum size of a request's header section,
+	// both as encoded in a HEADERS frame and as decoded.
+	// The decoded size is the sum of the lengths of each field's name
+	// and value, plus 32 bytes per field.
+	// If zero, a default of 1MB is used.
+	MaxHeaderBytes int
+
+	// ErrorLog is the logger for errors, such as handler panics.
+	// If nil, the log package's standard logger is used.
+	ErrorLog *log.Logger
+
+	// Extension, if non-nil, adds support for an HTTP/3 extension.
+	Extension Extension
+
+	// Strictness sets the action taken with each kind of malformed request.
+	// Malformed requests are counted in the Server's Stats.
+	Strictness Strictness
+
+	stats serverStats
+}
+
+// ListenAndServe listens on s.Addr and serves HTTP/3 requests.
+// It always returns a non-nil error.
+func (s *Server) ListenAndServe() error {
+	if s.Config == nil || s.Config.TLSConfig == nil {
+		return errors.New("http3: Server.Config.TLSConfig is not set")
+	}
+	addr := s.Addr
+	if addr == "" {
+		addr = ":443"
+	}
+	config := *s.Config
+	config.TLSConfig = tlsConfigWithH3(s.Config.TLSConfig)
+	l, err := quic.Listen("udp", addr, &config)
+	if err != nil {
+		return err
+	}
+	defer l.Close(context.Background())
+	return s.Serve(l)
+}
+
+// Serve accepts connections on l and serves HTTP/3 requests on them.
+// The Listener's TLS configuration must include "h3" in its NextProtos.
+// Serve returns when l is closed, and always returns a non-nil error.
+func (s *Server) Serve(l *quic.Listener) error {
+	for {
+		qconn, err := l.Accept(context.Background())
+		if err != nil {
+			return err
+		}
+		go s.serveConn(qconn)
+	}
+}
+
+// tlsConfigWithH3 returns a copy of config with "h3" in its NextProtos.
+func tlsConfigWithH3(config *tls.Config) *tls.Config {
+	config = config.Clone()
+	for _, p := range config.NextProtos {
+		if p == nextProtoH3 {
+			return config
+		}
+	}
+	config.NextProtos = append([]string{nextProtoH3}, config.NextProtos...)
+	return config
+}
+
+func (s *Server) handler() http.Handler {
+	if s.Handler != nil {
+		return s.Handler
+	}
+	return http.DefaultServeMux
+}
+
+func (s *Server) maxHeaderBytes() int64 {
+	if s.MaxHeaderBytes > 0 {
+		return int64(s.MaxHeaderBytes)
+	}
+	return defaultMaxHeaderBytes
+}
+
+func (s *Server) logf(format string, args ...any) {
+	if s.ErrorLog != nil {
+		s.ErrorLog.Printf(format, args...)
+	} else {
+		log.Printf(format, args...)
+	}
+}
+
+// A serverConn is the server side of an HTTP/3 connection.
+type serverConn struct {
+	*genericConn
+	srv *Server
+}
+
+func (s *Server) serveConn(qconn *quic.Conn) {
+	sc := &serverConn{
+		genericConn: newGenericConn(qconn, s.Extension),
+		srv:         s,
+	}
+	sc.onViolation = s.handleViolation
+	if err := sc.openControlStream(context.Background()); err != nil {
+		qconn.Abort(err)
+		return
+	}
+	sc.acceptStreams(sc)
+}
+
+func (sc *serverConn) handlePushStream(*stream) error {
+	// "Only servers can push; if a server receives a client-initiated push stream,
+	// this MUST be treated as a connection error of type H3_STREAM_CREATION_ERROR."
+	// https://www.rfc-editor.org/rfc/rfc9114#section-6.2.2-3
+	return &connectionError{
+		code:    errH3StreamCreationError,
+		message: "client created push stream",
+	}
+}
+
+func (sc *serverConn) handleGoaway(id int64) error {
+	// A client's GOAWAY refers to push IDs, and we never push.
+	return nil
+}
+
+func (sc *serverConn) handleMaxPushID(id int64) error {
+	// We never push, so the limit doesn't matter.
+	return nil
+}
+
+// handleRequestStream reads a request from st and serves it.
+func (sc *serverConn) handleRequestStream(st *stream) error {
+	req, err := sc.readRequest(st)
+	if err != nil {
+		return err
+	}
+	if err := sc.serveRequest(st, req); err != nil {
+		return err
+	}
+	st.stream.CloseWrite()
+	// "[...] a server can send a complete response prior to the client
+	// sending an entire request [...]. In such cases, a server MAY abort
+	// reading the request stream with error code H3_NO_ERROR."
+	// https://www.rfc-editor.org/rfc/rfc9114#section-4.1-15
+	st.stream.StopSending(uint64(errH3NoError))
+	return nil
+}
+
+// readRequest reads the request's header section from st.
+func (sc *serverConn) readRequest(st *stream) (*http.Request, error) {
+	ftype, err := st.readFrameHeader()
+	for err == nil && ftype != frameTypeHeaders {
+		if ftype == frameTypeData {
+			return nil, errFrameUnexpected(ftype)
+		}
+		if err := discardUnknownFrame(st, ftype); err != nil {
+			return nil, err
+		}
+		ftype, err = st.readFrameHeader()
+	}
+	if err == io.EOF {
+		// The client closed the stream before sending a request.
+		return nil, &streamError{
+			code:    errH3RequestIncomplete,
+			message: "stream ended before request headers",
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	fs, err := readHeaders(st, sc.srv.maxHeaderBytes())
+	if err != nil {
+		return nil, err
+	}
+	req, err := newServerRequest(fs, sc.sendsSetting(SettingEnableConnectProtocol))
+	if err != nil {
+		return nil, err
+	}
+	body := &bodyReader{
+		conn:           sc.genericConn,
+		st:             st,
+		remain:         req.ContentLength,
+		trailer:        req.Trailer,
+		maxHeaderBytes: sc.srv.maxHeaderBytes(),
+		stopCode:       errH3NoError,
+	}
+	req.Body = body
+	req.RemoteAddr = sc.qconn.RemoteAddr().String()
+	tlsState := sc.qconn.ConnectionState()
+	req.TLS = &tlsState
+	return req, nil
+}
+
+// newServerRequest creates a request from a header section.
+// If extendedConnect is set, the request may use the extended CONNECT method.
+// https://www.rfc-editor.org/rfc/rfc9114#section-4.3.1
+func newServerRequest(fs *fieldSection, extendedConnect bool) (*http.Request, error) {
+	for k := range fs.pseudo {
+		switch k {
+		case ":method", ":scheme", ":authority", ":path":
+		case ":protocol":
+			if extendedConnect {
+				break
+			}
+			fallthrough
+		default:
+			return nil, errViolation(ViolationPseudoHeader, "invalid pseudo-header field "+k)
+		}
+	}
+	method := fs.pseudo[":method"]
+	scheme, hasScheme := fs.pseudo[":scheme"]
+	authority := fs.pseudo[":authority"]
+	path, hasPath := fs.pseudo[":path"]
+	protocol, hasProtocol := fs.pseudo[":protocol"]
+	if method == "" {
+		return nil, errViolation(ViolationPseudoHeader, "missing :method")
+	}
+	if hasProtocol {
+		// "On requests that contain the :protocol pseudo-header field,
+		// the :scheme and :path pseudo-header fields of the target URI
+		// MUST also be included."
+		// https://www.rfc-editor.org/rfc/rfc8441#section-4
+		if method != http.MethodConnect || scheme == "" || path == "" || authority == "" {
+			return nil, errViolation(ViolationPseudoHeader, "invalid extended CONNECT request")
+		}
+		fs.header[":protocol"] = []string{protocol}
+	}
+	req := &http.Request{
+		Method:     method,
+		Proto:      "HTTP/3.0",
+		ProtoMajor: 3,
+		Header:     fs.header,
+		Host:       authority,
+	}
+	if method == http.MethodConnect && !hasProtocol {
+		// "The :scheme and :path pseudo-header fields are omitted."
+		// https://www.rfc-editor.org/rfc/rfc9114#section-4.4-3
+		if hasScheme || hasPath || authority == "" {
+			return nil, errViolation(ViolationPseudoHeader, "invalid CONNECT request")
+		}
+		req.URL = &url.URL{Host: authority}
+		req.RequestURI = authority
+	} else {
+		if scheme == "" || path == "" {
+			return nil, errViolation(ViolationPseudoHeader, "missing :scheme or :path")
+		}
+		u, err := url.ParseRequestURI(path)
+		if err != nil {
+			return nil, errMalformed("invalid :path")
+		}
+		req.URL = u
+		req.RequestURI = path
+	}
+	if req.Host == "" {
+		req.Host = fs.header.Get("Host")
+	}
+	fs.header.Del("Host")
+
+	req.ContentLength = -1
+	if cl := fs.header.Values("Content-Length"); len(cl) > 0 {
+		n, err := strconv.ParseInt(cl[0], 10, 64)
+		if err != nil || n < 0 || len(cl) > 1 {
+			return nil, errViolation(ViolationContentLength, "invalid Content-Length")
+		}
+		req.ContentLength = n
+	}
+	for _, v := range fs.header.Values("Trailer") {
+		for _, k := range strings.Split(v, ",") {
+			k = http.CanonicalHeaderKey(strings.TrimSpace(k))
+			if k == "" {
+				continue
+			}
+			if req.Trailer == nil {
+				req.Trailer = make(http.Header)
+			}
+			req.Trailer[k] = nil
+		}
+	}
+	fs.header.Del("Trailer")
+	return req, nil
+}
+
+// serveRequest runs the handler, and finishes the response.
+func (sc *serverConn) serveRequest(st *stream, req *http.Request) (err error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ctx = context.WithValue(ctx, requestStreamKey{}, requestStream{
+		qconn: sc.qconn,
+		id:    st.stream.ID(),
+	})
+	req = req.WithContext(ctx)
+	rw := &responseWriter{
+		st:      st,
+		req:     req,
+		header:  make(http.Header),
+		trailer: make(map[string]bool),
+	}
+	defer func() {
+		if e := recover(); e != nil {
+			if e != http.ErrAbortHandler {
+				const size = 64 << 10
+				buf := make([]byte, size)
+				buf = buf[:runtime.Stack(buf, false)]
+				sc.srv.logf("http3: panic serving %v: %v\n%s", req.URL, e, buf)
+			}
+			err = &streamError{
+				code:    errH3InternalError,
+				message: "handler panicked",
+			}
+		}
+	}()
+	sc.srv.handler().ServeHTTP(rw, req)
+	return rw.finish()
+}
+
+// A responseWriter is an http.ResponseWriter for an HTTP/3 request.
+type responseWriter struct {
+	st      *stream
+	req     *http.Request
+	header  http.Header
+	trailer map[string]bool // declared trailers, by canonical name
+
+	mu          sync.Mutex
+	wroteHeader bool // WriteHeader called
+	sentHeader  bool // HEADERS frame sent
+	status      int
+	buf         []byte // buffered body data, before the header is sent
+	err         error  // sticky write error
+}
+
+// responseBufferSize is the amount of body data we buffer before
+// sending response headers, so we can sniff the Content-Type and
+// provide a Content-Length for small responses.
+const responseBufferSize = 4096
+
+func (rw *responseWriter) Header() http.Header {
+	return rw.header
+}
+
+func (rw *responseWriter) WriteHeader(code int) {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	rw.writeHeaderLocked(code)
+}
+
+func (rw *responseWriter) writeHeaderLocked(code int) {
+	// Same range check as net/http.
+	if code < 100 || code > 999 {
+		panic(fmt.Sprintf("invalid WriteHeader code %v", code))
+	}
+	if rw.wroteHeader {
+		return
+	}
+	if code >= 100 && code <= 199 {
+		if code == http.StatusSwitchingProtocols {
+			// HTTP/3 has no Upgrade mechanism.
+			return
+		}
+		// Informational responses are sent immediately.
+		rw.writeHeadersFrame(code, rw.header, false)
+		return
+	}
+	rw.wroteHeader = true
+	rw.status = code
+	for _, v := range rw.header.Values("Trailer") {
+		for _, k := range strings.Split(v, ",") {
+			if k = http.CanonicalHeaderKey(strings.TrimSpace(k)); k != "" {
+				rw.trailer[k] = true
+			}
+		}
+	}
+}
+
+func (rw *responseWriter) Write(p []byte) (int, error) {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	if !rw.wroteHeader {
+		rw.writeHeaderLocked(http.StatusOK)
+	}
+	if !bodyAllowedForStatus(rw.status) {
+		return 0, http.ErrBodyNotAllowed
+	}
+	if rw.req.Method == http.MethodHead {
+		return len(p), nil
+	}
+	if rw.err != nil {
+		return 0, rw.err
+	}
+	if !rw.sentHeader {
+		if len(rw.buf)+len(p) <= responseBufferSize {
+			rw.buf = append(rw.buf, p...)
+			return len(p), nil
+		}
+		rw.sendHeaderLocked(append(rw.buf, p...), -1)
+		if rw.err == nil {
+			rw.err = writeData(rw.st, rw.buf)
+		}
+		rw.buf = nil
+	}
+	if rw.err == nil {
+		rw.err = writeData(rw.st, p)
+	}
+	if rw.err != nil {
+		return 0, rw.err
+	}
+	return len(p), nil
+}
+
+// Flush sends any buffered data to the client.
+func (rw *responseWriter) Flush() {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	if !rw.wroteHeader {
+		rw.writeHeaderLocked(http.StatusOK)
+	}
+	if !rw.sentHeader {
+		rw.sendHeaderLocked(rw.buf, -1)
+		if rw.err == nil {
+			rw.err = writeData(rw.st, rw.buf)
+		}
+		rw.buf = nil
+	}
+}
+
+// finish is called when the handler returns,
+// and sends the remainder of the response.
+func (rw *responseWriter) finish() error {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	if !rw.wroteHeader {
+		rw.writeHeaderLocked(http.StatusOK)
+	}
+	if !rw.sentHeader {
+		contentLength := int64(-1)
+		if rw.req.Method != http.MethodHead && bodyAllowedForStatus(rw.status) {
+			contentLength = int64(len(rw.buf))
+		}
+		rw.sendHeaderLocked(rw.buf, contentLength)
+		if rw.err == nil {
+			rw.err = writeData(rw.st, rw.buf)
+		}
+		rw.buf = nil
+	}
+	if rw.err != nil {
+		return rw.err
+	}
+	return writeTrailer(rw.st, rw.trailers())
+}
+
+// sendHeaderLocked sends the response header section.
+// The start of the response body, p, is used to sniff the Content-Type.
+// If contentLength is not -1 and no Content-Length has been set, it is sent.
+func (rw *responseWriter) sendHeaderLocked(p []byte, contentLength int64) {
+	rw.sentHeader = true
+	h := rw.header.Clone()
+	for k := range h {
+		if rw.trailer[k] || strings.HasPrefix(k, http.TrailerPrefix) {
+			delete(h, k)
+		}
+	}
+	if bodyAllowedForStatus(rw.status) {
+		if _, ok := h["Content-Type"]; !ok && len(p) > 0 {
+			h.Set("Content-Type", http.DetectContentType(p))
+		}
+		if _, ok := h["Content-Length"]; !ok && contentLength >= 0 {
+			h.Set("Content-Length", strconv.FormatInt(contentLength, 10))
+		}
+	}
+	if _, ok := h["Date"]; !ok {
+		h.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	}
+	rw.writeHeadersFrame(rw.status, h, true)
+}
+
+// writeHeadersFrame writes a HEADERS frame containing a response header section.
+func (rw *responseWriter) writeHeadersFrame(status int, h http.Header, final bool) {
+	b := encodeFieldSection(func(yield func(name, value string)) {
+		yield(":status", strconv.Itoa(status))
+		appendHeaderFields(yield, h)
+	})
+	if err := rw.st.writeFrame(frameTypeHeaders, b); err != nil && final {
+		rw.err = err
+	}
+}
+
+// trailers returns the trailer section to send.
+func (rw *responseWriter) trailers() http.Header {
+	var trailer http.Header
+	for k, vv := range rw.header {
+		name := k
+		if strings.HasPrefix(k, http.TrailerPrefix) {
+			name = http.CanonicalHeaderKey(strings.TrimPrefix(k, http.TrailerPrefix))
+		} else if !rw.trailer[k] {
+			continue
+		}
+		if trailer == nil {
+			trailer = make(http.Header)
+		}
+		trailer[name] = vv
+	}
+	return trailer
+}
+
+// bodyAllowedForStatus reports whether a given response status code
+// permits a body.
+func bodyAllowedForStatus(status int) bool {
+	switch {
+	case status >= 100 && status <= 199:
+		return false
+	case status == http.StatusNoContent:
+		return false
+	case status == http.StatusNotModified:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,219 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package http3
+
+import (
+	"bufio"
+	"errors"
+	"io"
+
+	"golang.org/x/net/internal/quic"
+	"golang.org/x/net/internal/quic/quicwire"
+)
+
+// A stream wraps a QUIC stream, providing methods to read and write HTTP/3 frames.
+type stream struct {
+	stream *quic.Stream
+	r      *bufio.Reader
+
+	// lim is the number of bytes remaining in the frame being read,
+	// or -1 when not reading a frame.
+	lim int64
+}
+
+func newStream(qs *quic.Stream) *stream {
+	return &stream{
+		stream: qs,
+		r:      bufio.NewReader(qs),
+		lim:    -1,
+	}
+}
+
+// readFrameHeader reads the type and length of the next frame on the stream.
+// The caller must read the frame payload and call endFrame,
+// or call discardFrame.
+//
+// It returns io.EOF if the stream ends cleanly before the start of a frame.
+func (st *stream) readFrameHeader() (frameType, error) {
+	if st.lim >= 0 {
+		panic("BUG: readFrameHeader called with frame in progress")
+	}
+	ftype, err := st.readVarint()
+	if err != nil {
+		return 0, err
+	}
+	size, err := st.readVarint()
+	if err != nil {
+		return 0, truncatedFrameError(err)
+	}
+	st.lim = size
+	return frameType(ftype), nil
+}
+
+// endFrame is called after reading a frame's payload,
+// and reports an error if the payload was not fully consumed.
+func (st *stream) endFrame() error {
+	if st.lim != 0 {
+		return &connectionError{
+			code:    errH3FrameError,
+			message: "invalid frame payload length",
+		}
+	}
+	st.lim = -1
+	return nil
+}
+
+// discardFrame discards the remainder of the current frame's payload.
+func (st *stream) discardFrame() error {
+	if _, err := st.r.Discard(int(st.lim)); err != nil {
+		return truncatedFrameError(err)
+	}
+	st.lim = -1
+	return nil
+}
+
+// readFrameData reads data from the current frame's payload.
+// It returns io.EOF at the end of the frame.
+func (st *stream) readFrameData(p []byte) (int, error) {
+	if st.lim == 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > st.lim {
+		p = p[:st.lim]
+	}
+	n, err := st.r.Read(p)
+	st.lim -= int64(n)
+	if err == io.EOF && st.lim == 0 {
+		// The stream ended at the end of this frame.
+		// The caller sees the end of the stream when it reads the next frame header.
+		err = nil
+	}
+	if err != nil {
+		return n, truncatedFrameError(err)
+	}
+	return n, nil
+}
+
+// readFrameBytes reads the remainder of the current frame's payload,
+// and ends the frame.
+func (st *stream) readFrameBytes() ([]byte, error) {
+	b := make([]byte, st.lim)
+	if _, err := io.ReadFull(st.r, b); err != nil {
+		return nil, truncatedFrameError(err)
+	}
+	st.lim = -1
+	return b, nil
+}
+
+// readVarint reads a variable-length integer.
+// When reading a frame payload, it does not read past the end of the frame.
+func (st *stream) readVarint() (int64, error) {
+	b, err := st.readByte()
+	if err != nil {
+		return 0, err
+	}
+	v := int64(b & 0x3f)
+	n := 1 << (b >> 6)
+	for i := 1; i < n; i++ {
+		b, err := st.readByte()
+		if err != nil {
+			return 0, truncatedFrameError(err)
+		}
+		v = v<<8 | int64(b)
+	}
+	return v, nil
+}
+
+func (st *stream) readByte() (byte, error) {
+	if st.lim == 0 {
+		return 0, &connectionError{
+			code:    errH3FrameError,
+			message: "frame payload too short",
+		}
+	}
+	b, err := st.r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	if st.lim > 0 {
+		st.lim--
+	}
+	return b, nil
+}
+
+// readSettings reads the payload of a SETTINGS frame,
+// calling f for each setting, and ends the frame.
+func (st *stream) readSettings(f func(id, value int64) error) error {
+	seen := make(map[int64]bool)
+	for st.lim > 0 {
+		id, err := st.readVarint()
+		if err != nil {
+			return err
+		}
+		value, err := st.readVarint()
+		if err != nil {
+			return err
+		}
+		// "The same setting identifier MUST NOT occur more than once
+		// in the SETTINGS frame."
+		// https://www.rfc-editor.org/rfc/rfc9114#section-7.2.4-5
+		if seen[id] || isHTTP2Setting(id) {
+			return &connectionError{
+				code:    errH3SettingsError,
+				message: "invalid setting",
+			}
+		}
+		seen[id] = true
+		if err := f(id, value); err != nil {
+			return err
+		}
+	}
+	return st.endFrame()
+}
+
+// truncatedFrameError converts an unexpected end of stream
+// within a frame into a connection error.
+func truncatedFrameError(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return &connectionError{
+			code:    errH3FrameError,
+			message: "stream ended in the middle of a frame",
+		}
+	}
+	return err
+}
+
+// appendFrameHeader appends the header of a frame to b.
+func appendFrameHeader(b []byte, ftype frameType, size int64) []byte {
+	b = quicwire.AppendVarint(b, uint64(ftype))
+	return quicwire.AppendVarint(b, uint64(size))
+}
+
+// writeFrame writes a frame with the given payload to the stream.
+func (st *stream) writeFrame(ftype frameType, payload []byte) error {
+	b := make([]byte, 0, 2*8+len(payload))
+	b = appendFrameHeader(b, ftype, int64(len(payload)))
+	b = append(b, payload...)
+	_, err := st.stream.Write(b)
+	return err
+}
+
+// resetAndStop terminates the stream in both directions with an error code.
+func (st *stream) resetAndStop(code http3Error) {
+	st.stream.Reset(uint64(code))
+	st.stream.StopSending(uint64(code))
+}
+
+// errorCode returns the HTTP/3 error code with which to terminate
+// a stream after err.
+func errorCode(err error) http3Error {
+	var serr *streamError
+	if errors.As(err, &serr) {
+		return serr.code
+	}
+	return errH3InternalError
+}
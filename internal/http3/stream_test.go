@@ -0,0 +1,205 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package http3
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"golang.org/x/net/internal/quic/quicwire"
+)
+
+// newTestReadStream returns a stream which reads from b.
+// It may not be written to.
+func newTestReadStream(b []byte) *stream {
+	return &stream{
+		r:   bufio.NewReader(bytes.NewReader(b)),
+		lim: -1,
+	}
+}
+
+func wantConnectionError(t *testing.T, what string, err error, code http3Error) {
+	t.Helper()
+	var cerr *connectionError
+	if !errors.As(err, &cerr) || cerr.code != code {
+		t.Errorf("%v: %v, want connection error %v", what, err, code)
+	}
+}
+
+func TestStreamReadFrames(t *testing.T) {
+	var b []byte
+	b = appendFrameHeader(b, frameTypeData, 3)
+	b = append(b, "abc"...)
+	b = appendFrameHeader(b, 0x21, 2) // reserved frame type
+	b = append(b, 0, 0)
+	b = appendFrameHeader(b, frameTypeGoaway, 1)
+	b = quicwire.AppendVarint(b, 4)
+	st := newTestReadStream(b)
+
+	if ftype, err := st.readFrameHeader(); err != nil || ftype != frameTypeData {
+		t.Fatalf("readFrameHeader = %v, %v; want DATA", ftype, err)
+	}
+	got, err := io.ReadAll(readerFunc(st.readFrameData))
+	if err != nil || string(got) != "abc" {
+		t.Fatalf("reading DATA payload = %q, %v; want %q", got, err, "abc")
+	}
+	if err := st.endFrame(); err != nil {
+		t.Fatalf("endFrame: %v", err)
+	}
+
+	if ftype, err := st.readFrameHeader(); err != nil || ftype != 0x21 {
+		t.Fatalf("readFrameHeader = %v, %v; want 0x21", ftype, err)
+	}
+	if err := st.discardFrame(); err != nil {
+		t.Fatalf("discardFrame: %v", err)
+	}
+
+	if ftype, err := st.readFrameHeader(); err != nil || ftype != frameTypeGoaway {
+		t.Fatalf("readFrameHeader = %v, %v; want GOAWAY", ftype, err)
+	}
+	if id, err := st.readVarint(); err != nil || id != 4 {
+		t.Fatalf("readVarint = %v, %v; want 4", id, err)
+	}
+	if err := st.endFrame(); err != nil {
+		t.Fatalf("endFrame: %v", err)
+	}
+
+	if _, err := st.readFrameHeader(); err != io.EOF {
+		t.Fatalf("readFrameHeader at end of stream = %v, want io.EOF", err)
+	}
+}
+
+func TestStreamReadFrameDataAtEndOfStream(t *testing.T) {
+	// The underlying reader returns the last bytes of the stream along with io.EOF,
+	// and the stream ends at the end of the frame.
+	payload := bytes.Repeat([]byte("a"), 8192)
+	b := appendFrameHeader(nil, frameTypeData, int64(len(payload)))
+	b = append(b, payload...)
+	br := bytes.NewReader(b)
+	st := &stream{
+		r: bufio.NewReader(readerFunc(func(p []byte) (int, error) {
+			n, err := br.Read(p)
+			if br.Len() == 0 {
+				err = io.EOF
+			}
+			return n, err
+		})),
+		lim: -1,
+	}
+	if ftype, err := st.readFrameHeader(); err != nil || ftype != frameTypeData {
+		t.Fatalf("readFrameHeader = %v, %v; want DATA", ftype, err)
+	}
+	var got []byte
+	buf := make([]byte, 2*len(payload))
+	for st.lim > 0 {
+		n, err := st.readFrameData(buf)
+		got = append(got, buf[:n]...)
+		if err != nil {
+			t.Fatalf("readFrameData: %v", err)
+		}
+	}
+	if !bytes.Equal(got, payload) {
+		t.Errorf("read %v bytes of DATA payload, want %v", len(got), len(payload))
+	}
+	if err := st.endFrame(); err != nil {
+		t.Fatalf("endFrame: %v", err)
+	}
+	if _, err := st.readFrameHeader(); err != io.EOF {
+		t.Fatalf("readFrameHeader at end of stream = %v, want io.EOF", err)
+	}
+}
+
+type readerFunc func([]byte) (int, error)
+
+func (f readerFunc) Read(p []byte) (int, error) { return f(p) }
+
+func TestStreamReadVarint(t *testing.T) {
+	// Examples from RFC 9000, Appendix A.1.
+	for _, test := range []struct {
+		v uint64
+		b []byte
+	}{
+		{0, []byte{0x00}},
+		{37, []byte{0x25}},
+		{15293, []byte{0x7b, 0xbd}},
+		{494878333, []byte{0x9d, 0x7f, 0x3e, 0x7d}},
+		{151288809941952652, []byte{0xc2, 0x19, 0x7c, 0x5e, 0xff, 0x14, 0xe8, 0x8c}},
+		{quicwire.MaxVarint, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+	} {
+		st := newTestReadStream(test.b)
+		if got, err := st.readVarint(); err != nil || uint64(got) != test.v {
+			t.Errorf("readVarint(%x) = %v, %v; want %v", test.b, got, err, test.v)
+		}
+	}
+}
+
+func TestStreamFrameErrors(t *testing.T) {
+	// Varint extends past the end of the frame.
+	b := appendFrameHeader(nil, frameTypeGoaway, 1)
+	b = append(b, 0x40, 0x10)
+	st := newTestReadStream(b)
+	st.readFrameHeader()
+	_, err := st.readVarint()
+	wantConnectionError(t, "varint past end of frame", err, errH3FrameError)
+
+	// Frame payload is not fully consumed.
+	b = appendFrameHeader(nil, frameTypeGoaway, 2)
+	b = append(b, 0, 0)
+	st = newTestReadStream(b)
+	st.readFrameHeader()
+	st.readVarint()
+	wantConnectionError(t, "endFrame with unread payload", st.endFrame(), errH3FrameError)
+
+	// Stream ends in the middle of a frame.
+	b = appendFrameHeader(nil, frameTypeData, 10)
+	b = append(b, "abc"...)
+	st = newTestReadStream(b)
+	st.readFrameHeader()
+	_, err = io.ReadAll(readerFunc(st.readFrameData))
+	wantConnectionError(t, "truncated DATA frame", err, errH3FrameError)
+
+	// Stream ends in the middle of a frame header.
+	st = newTestReadStream([]byte{byte(frameTypeData)})
+	_, err = st.readFrameHeader()
+	wantConnectionError(t, "truncated frame header", err, errH3FrameError)
+}
+
+func TestStreamReadSettings(t *testing.T) {
+	settings := func(pairs ...uint64) *stream {
+		var payload []byte
+		for _, v := range pairs {
+			payload = quicwire.AppendVarint(payload, v)
+		}
+		b := appendFrameHeader(nil, frameTypeSettings, int64(len(payload)))
+		st := newTestReadStream(append(b, payload...))
+		if _, err := st.readFrameHeader(); err != nil {
+			t.Fatal(err)
+		}
+		return st
+	}
+
+	got := map[int64]int64{}
+	err := settings(settingMaxFieldSectionSize, 1000, 0x21, 5).readSettings(func(id, value int64) error {
+		got[id] = value
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("readSettings: %v", err)
+	}
+	if len(got) != 2 || got[settingMaxFieldSectionSize] != 1000 || got[0x21] != 5 {
+		t.Errorf("readSettings read %v", got)
+	}
+
+	ignore := func(id, value int64) error { return nil }
+	err = settings(0x21, 1, 0x21, 2).readSettings(ignore)
+	wantConnectionError(t, "duplicate setting", err, errH3SettingsError)
+	err = settings(0x02, 1).readSettings(ignore) // SETTINGS_ENABLE_PUSH
+	wantConnectionError(t, "HTTP/2 setting", err, errH3SettingsError)
+}
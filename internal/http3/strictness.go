@@ -0,0 +1,120 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package http3
+
+import "sync/atomic"
+
+// A MessageViolation is a way in which a request may be malformed.
+// https://www.rfc-editor.org/rfc/rfc9114#section-4.1.2
+type MessageViolation int
+
+const (
+	violationNone MessageViolation = iota
+
+	// ViolationPseudoHeader is a pseudo-header field which is unknown,
+	// repeated, or missing, or which follows a regular field
+	// or appears in a trailer section.
+	ViolationPseudoHeader
+
+	// ViolationForbiddenHeader is a connection-specific header field,
+	// or a TE header field with a value other than "trailers".
+	ViolationForbiddenHeader
+
+	// ViolationContentLength is an invalid Content-Length header field,
+	// or a body whose length differs from its Content-Length.
+	ViolationContentLength
+
+	numViolations
+)
+
+func (v MessageViolation) String() string {
+	switch v {
+	case ViolationPseudoHeader:
+		return "ViolationPseudoHeader"
+	case ViolationForbiddenHeader:
+		return "ViolationForbiddenHeader"
+	case ViolationContentLength:
+		return "ViolationContentLength"
+	}
+	return "MessageViolation(unknown)"
+}
+
+// A ViolationAction is the action a Server takes with a malformed request.
+type ViolationAction int
+
+const (
+	// ViolationResetStream resets the request's stream
+	// with the H3_MESSAGE_ERROR code.
+	// Other requests on the connection are not affected.
+	ViolationResetStream ViolationAction = iota
+
+	// ViolationCloseConnection closes the connection
+	// with the H3_MESSAGE_ERROR code,
+	// abandoning any other requests on it.
+	ViolationCloseConnection
+)
+
+// Strictness sets the action a Server takes with each kind of malformed request.
+//
+// A malformed request is never passed to a handler. When the violation
+// is in the request body, the handler's read of the body fails.
+// The zero value resets the stream of a malformed request,
+// which is the minimum RFC 9114 requires.
+type Strictness struct {
+	PseudoHeader    ViolationAction
+	ForbiddenHeader ViolationAction
+	ContentLength   ViolationAction
+}
+
+func (s *Strictness) action(v MessageViolation) ViolationAction {
+	switch v {
+	case ViolationPseudoHeader:
+		return s.PseudoHeader
+	case ViolationForbiddenHeader:
+		return s.ForbiddenHeader
+	case ViolationContentLength:
+		return s.ContentLength
+	}
+	return ViolationResetStream
+}
+
+// ServerStats contains counters of malformed requests received by a Server.
+type ServerStats struct {
+	PseudoHeaderViolations    uint64
+	ForbiddenHeaderViolations uint64
+	ContentLengthViolations   uint64
+}
+
+// serverStats holds a Server's counters.
+type serverStats struct {
+	violations [numViolations]atomic.Uint64
+}
+
+// Stats returns a snapshot of the Server's counters.
+func (s *Server) Stats() ServerStats {
+	return ServerStats{
+		PseudoHeaderViolations:    s.stats.violations[ViolationPseudoHeader].Load(),
+		ForbiddenHeaderViolations: s.stats.violations[ViolationForbiddenHeader].Load(),
+		ContentLengthViolations:   s.stats.violations[ViolationContentLength].Load(),
+	}
+}
+
+// handleViolation counts a malformed request,
+// and reports whether the connection it arrived on should be closed.
+func (s *Server) handleViolation(v MessageViolation) (closeConn bool) {
+	s.stats.violations[v].Add(1)
+	return s.Strictness.action(v) == ViolationCloseConnection
+}
+
+// errViolation returns a malformed message error for violation v.
+func errViolation(v MessageViolation, message string) error {
+	return &streamError{
+		code:      errH3MessageError,
+		message:   message,
+		violation: v,
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package http3
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+
+	"golang.org/x/net/internal/quic"
+)
+
+func TestServerStrictness(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		fields    [][2]string
+		body      string
+		violation MessageViolation
+	}{{
+		name: "pseudo-header after regular field",
+		fields: [][2]string{
+			{":method", "GET"},
+			{":scheme", "https"},
+			{"user-agent", "test"},
+			{":path", "/"},
+		},
+		violation: ViolationPseudoHeader,
+	}, {
+		name: "unknown pseudo-header",
+		fields: [][2]string{
+			{":method", "GET"},
+			{":scheme", "https"},
+			{":path", "/"},
+			{":status", "200"},
+		},
+		violation: ViolationPseudoHeader,
+	}, {
+		name: "connection-specific header",
+		fields: [][2]string{
+			{":method", "GET"},
+			{":scheme", "https"},
+			{":path", "/"},
+			{"transfer-encoding", "chunked"},
+		},
+		violation: ViolationForbiddenHeader,
+	}, {
+		name: "body smaller than content-length",
+		fields: [][2]string{
+			{":method", "POST"},
+			{":scheme", "https"},
+			{":path", "/"},
+			{"content-length", "10"},
+		},
+		body:      "short",
+		violation: ViolationContentLength,
+	}, {
+		name: "body larger than content-length",
+		fields: [][2]string{
+			{":method", "POST"},
+			{":scheme", "https"},
+			{":path", "/"},
+			{"content-length", "1"},
+		},
+		body:      "too long",
+		violation: ViolationContentLength,
+	}} {
+		for _, action := range []ViolationAction{ViolationResetStream, ViolationCloseConnection} {
+			name := test.name + "/reset stream"
+			if action == ViolationCloseConnection {
+				name = test.name + "/close connection"
+			}
+			t.Run(name, func(t *testing.T) {
+				srv := &Server{
+					Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						io.Copy(io.Discard, r.Body)
+					}),
+				}
+				switch test.violation {
+				case ViolationPseudoHeader:
+					srv.Strictness.PseudoHeader = action
+				case ViolationForbiddenHeader:
+					srv.Strictness.ForbiddenHeader = action
+				case ViolationContentLength:
+					srv.Strictness.ContentLength = action
+				}
+				addr := startTestServer(t, srv)
+				qconn := dialRawConn(t, addr)
+				qs, err := qconn.NewStream(context.Background())
+				if err != nil {
+					t.Fatal(err)
+				}
+				st := newStream(qs)
+				b := encodeFieldSection(func(yield func(name, value string)) {
+					for _, f := range test.fields {
+						yield(f[0], f[1])
+					}
+				})
+				if err := st.writeFrame(frameTypeHeaders, b); err != nil {
+					t.Fatal(err)
+				}
+				if test.body != "" {
+					if err := writeData(st, []byte(test.body)); err != nil {
+						t.Fatal(err)
+					}
+				}
+				qs.CloseWrite()
+
+				if action == ViolationCloseConnection {
+					wantConnClosed(t, qconn, errH3MessageError)
+				} else {
+					_, err := st.readFrameHeader()
+					if !errors.Is(err, quic.StreamErrorCode(errH3MessageError)) {
+						t.Errorf("reading response: %v, want stream reset with H3_MESSAGE_ERROR", err)
+					}
+				}
+
+				stats := srv.Stats()
+				counts := map[MessageViolation]uint64{
+					ViolationPseudoHeader:    stats.PseudoHeaderViolations,
+					ViolationForbiddenHeader: stats.ForbiddenHeaderViolations,
+					ViolationContentLength:   stats.ContentLengthViolations,
+				}
+				for v, got := range counts {
+					want := uint64(0)
+					if v == test.violation {
+						want = 1
+					}
+					if got != want {
+						t.Errorf("%v count = %v, want %v", v, got, want)
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,608 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package http3
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/internal/quic"
+)
+
+// A Transport is an HTTP/3 client.
+// It implements http.RoundTripper, and maintains a pool of connections.
+type Transport struct {
+	// Config is the QUIC configuration used for client connections.
+	// If nil, the zero Config is used.
+	// If Config.TLSConfig is nil, a default TLS configuration is used.
+	// The TLS configuration's ServerName defaults to the host being dialed,
+	// and "h3" is added to its NextProtos.
+	Config *quic.Config
+
+	// MaxResponseHeaderBytes is the maximum size of a response's header section,
+	// both as encoded in a HEADERS frame and as decoded.
+	// The decoded size is the sum of the lengths of each field's name
+	// and value, plus 32 bytes per field.
+	// If zero, a default of 1MB is used.
+	MaxResponseHeaderBytes int64
+
+	// Extension, if non-nil, adds support for an HTTP/3 extension.
+	Extension Extension
+
+	mu      sync.Mutex
+	conns   map[string]*ClientConn // by address
+	dialing map[string]*dialCall   // dials in progress, by address
+}
+
+// A dialCall is a dial of a connection for the pool,
+// which requests for the same address wait for.
+type dialCall struct {
+	done     chan struct{} // closed when the dial completes
+	err      error
+	canceled bool // the dialing request's context ended
+}
+
+// RoundTrip sends a request on a pooled connection, dialing a new one
+// if necessary, and returns the response.
+// The request URL's scheme must be "https".
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL == nil || req.URL.Scheme != "https" {
+		closeRequestBody(req)
+		return nil, errors.New("http3: unsupported scheme")
+	}
+	addr := req.URL.Host
+	if req.URL.Port() == "" {
+		addr = net.JoinHostPort(req.URL.Hostname(), "443")
+	}
+	cc, err := t.getConn(req.Context(), addr)
+	if err != nil {
+		closeRequestBody(req)
+		return nil, err
+	}
+	return cc.RoundTrip(req)
+}
+
+// getConn returns a connection to addr from the pool,
+// dialing a new one if necessary.
+// Only one connection to an address is dialed at a time:
+// other requests for the address wait for the dial to complete.
+func (t *Transport) getConn(ctx context.Context, addr string) (*ClientConn, error) {
+	for {
+		t.mu.Lock()
+		if cc := t.conns[addr]; cc != nil && cc.canTakeNewRequest() {
+			t.mu.Unlock()
+			return cc, nil
+		}
+		call := t.dialing[addr]
+		if call == nil {
+			call = &dialCall{done: make(chan struct{})}
+			if t.dialing == nil {
+				t.dialing = make(map[string]*dialCall)
+			}
+			t.dialing[addr] = call
+			t.mu.Unlock()
+			return t.dialForPool(ctx, addr, call)
+		}
+		t.mu.Unlock()
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if call.err != nil && !call.canceled {
+			return nil, call.err
+		}
+		// Use the new connection, or dial again if the
+		// request which was dialing has been canceled.
+	}
+}
+
+// dialForPool dials a connection to addr and adds it to the pool.
+// A connection it replaces in the pool is closed once it is idle.
+func (t *Transport) dialForPool(ctx context.Context, addr string, call *dialCall) (*ClientConn, error) {
+	cc, err := t.Dial(ctx, addr)
+	t.mu.Lock()
+	delete(t.dialing, addr)
+	var old *ClientConn
+	if err == nil {
+		if t.conns == nil {
+			t.conns = make(map[string]*ClientConn)
+		}
+		old = t.conns[addr]
+		t.conns[addr] = cc
+	} else {
+		call.err = err
+		call.canceled = ctx.Err() != nil
+	}
+	t.mu.Unlock()
+	close(call.done)
+	if old != nil {
+		old.closeWhenIdle()
+	}
+	return cc, err
+}
+
+// CloseIdleConnections closes pooled connections
+// which have no requests in progress.
+func (t *Transport) CloseIdleConnections() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for addr, cc := range t.conns {
+		if cc.closeIfIdle() {
+			delete(t.conns, addr)
+		}
+	}
+}
+
+// Dial creates a new HTTP/3 client connection to address,
+// in the form "host:port".
+// The connection is not added to the Transport's pool.
+func (t *Transport) Dial(ctx context.Context, address string) (*ClientConn, error) {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	var config quic.Config
+	if t.Config != nil {
+		config = *t.Config
+	}
+	if config.TLSConfig == nil {
+		config.TLSConfig = &tls.Config{}
+	}
+	config.TLSConfig = tlsConfigWithH3(config.TLSConfig)
+	if config.TLSConfig.ServerName == "" {
+		config.TLSConfig.ServerName = host
+	}
+	// The quic package uses a Listener's configuration for every connection
+	// it dials, so we use a separate Listener for each server name.
+	// The Listener is closed when the connection is.
+	l, err := quic.Listen("udp", ":0", &config)
+	if err != nil {
+		return nil, err
+	}
+	qconn, err := l.Dial(ctx, "udp", address)
+	if err != nil {
+		l.Close(context.Background())
+		return nil, err
+	}
+	cc := &ClientConn{
+		genericConn:    newGenericConn(qconn, t.Extension),
+		l:              l,
+		maxHeaderBytes: t.MaxResponseHeaderBytes,
+	}
+	if cc.maxHeaderBytes <= 0 {
+		cc.maxHeaderBytes = defaultMaxHeaderBytes
+	}
+	if err := cc.openControlStream(ctx); err != nil {
+		cc.Close()
+		return nil, err
+	}
+	go cc.acceptStreams(cc)
+	go func() {
+		qconn.Wait(context.Background())
+		l.Close(context.Background())
+	}()
+	return cc, nil
+}
+
+// A ClientConn is a client HTTP/3 connection.
+// Multiple goroutines may invoke methods on a ClientConn simultaneously.
+type ClientConn struct {
+	*genericConn
+	l              *quic.Listener
+	maxHeaderBytes int64
+
+	mu       sync.Mutex
+	goaway   bool // received GOAWAY
+	inflight int  // requests in progress
+	retired  bool // removed from the pool, close when idle
+}
+
+// Close closes the connection.
+// Requests in progress are aborted.
+func (cc *ClientConn) Close() error {
+	cc.abort(&connectionError{
+		code:    errH3NoError,
+		message: "client closed connection",
+	})
+	return cc.l.Close(context.Background())
+}
+
+// canTakeNewRequest reports whether the connection may be used for a new request.
+func (cc *ClientConn) canTakeNewRequest() bool {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return !cc.goaway && cc.qconn.Err() == nil
+}
+
+// closeIfIdle closes the connection if no requests are in progress,
+// and reports whether it did so.
+func (cc *ClientConn) closeIfIdle() bool {
+	cc.mu.Lock()
+	idle := cc.inflight == 0
+	cc.mu.Unlock()
+	if idle {
+		cc.Close()
+	}
+	return idle
+}
+
+// closeWhenIdle closes the connection once no requests are in progress.
+// It is called when the connection is replaced in a Transport's pool.
+func (cc *ClientConn) closeWhenIdle() {
+	cc.mu.Lock()
+	cc.retired = true
+	idle := cc.inflight == 0
+	cc.mu.Unlock()
+	if idle {
+		// Close waits for the connection to shut down;
+		// don't delay the caller.
+		go cc.Close()
+	}
+}
+
+func (cc *ClientConn) handleRequestStream(*stream) error {
+	// "Clients MUST treat receipt of a server-initiated bidirectional stream
+	// as a connection error of type H3_STREAM_CREATION_ERROR [...]"
+	// https://www.rfc-editor.org/rfc/rfc9114#section-6.1-3
+	return &connectionError{
+		code:    errH3StreamCreationError,
+		message: "server created bidirectional stream",
+	}
+}
+
+func (cc *ClientConn) handlePushStream(*stream) error {
+	// We never send MAX_PUSH_ID, so the server may not push.
+	// https://www.rfc-editor.org/rfc/rfc9114#section-4.6-3
+	return &connectionError{
+		code:    errH3IDError,
+		message: "push stream created without MAX_PUSH_ID",
+	}
+}
+
+func (cc *ClientConn) handleGoaway(id int64) error {
+	// We stop sending new requests on the connection.
+	// Requests in progress are allowed to complete:
+	// If the server does not process them, it will reset their streams.
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	cc.goaway = true
+	return nil
+}
+
+func (cc *ClientConn) handleMaxPushID(id int64) error {
+	// "A server MUST NOT send a MAX_PUSH_ID frame."
+	// https://www.rfc-editor.org/rfc/rfc9114#section-7.2.7-5
+	return errFrameUnexpected(frameTypeMaxPushID)
+}
+
+// PeerSettings waits for the server's SETTINGS frame and returns its parameters.
+func (cc *ClientConn) PeerSettings(ctx context.Context) ([]Setting, error) {
+	return cc.waitPeerSettings(ctx)
+}
+
+var errClientConnUnusable = errors.New("http3: client connection is unusable")
+
+// RoundTrip sends a request on the connection and returns the response.
+//
+// A request with the CONNECT method and a ":protocol" header
+// is sent as an extended CONNECT request.
+// https://www.rfc-editor.org/rfc/rfc9220
+func (cc *ClientConn) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := validateHeaderFields(req.Header, isExtendedConnect(req)); err != nil {
+		closeRequestBody(req)
+		return nil, err
+	}
+	if isExtendedConnect(req) {
+		// "A client MUST NOT send [...] the :protocol pseudo-header field
+		// [...] until it has received SETTINGS_ENABLE_CONNECT_PROTOCOL."
+		// https://www.rfc-editor.org/rfc/rfc9220#section-3-2
+		if err := cc.checkExtendedConnect(req.Context()); err != nil {
+			closeRequestBody(req)
+			return nil, err
+		}
+	}
+	cc.mu.Lock()
+	if cc.goaway {
+		cc.mu.Unlock()
+		closeRequestBody(req)
+		return nil, errClientConnUnusable
+	}
+	cc.inflight++
+	cc.mu.Unlock()
+
+	resp, err := cc.roundTrip(req)
+	if err != nil {
+		cc.requestDone()
+	}
+	return resp, err
+}
+
+func (cc *ClientConn) roundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	qs, err := cc.qconn.NewStream(ctx)
+	if err != nil {
+		closeRequestBody(req)
+		return nil, err
+	}
+	st := newStream(qs)
+	if err := st.writeFrame(frameTypeHeaders, encodeRequestHeaders(req)); err != nil {
+		closeRequestBody(req)
+		return nil, err
+	}
+	stop := context.AfterFunc(ctx, func() {
+		st.resetAndStop(errH3RequestCancelled)
+	})
+	if req.Body != nil && req.Body != http.NoBody {
+		go writeRequestBody(st, req)
+	} else {
+		st.stream.CloseWrite()
+	}
+	resp, err := cc.readResponse(st, req)
+	if err != nil {
+		stop()
+		cc.handleStreamError(st, err)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	body := &responseBody{
+		cc:   cc,
+		stop: stop,
+		r: &bodyReader{
+			conn:           cc.genericConn,
+			st:             st,
+			remain:         resp.ContentLength,
+			trailer:        resp.Trailer,
+			maxHeaderBytes: cc.maxHeaderBytes,
+			stopCode:       errH3RequestCancelled,
+		},
+	}
+	if req.Method == http.MethodHead || !bodyAllowedForStatus(resp.StatusCode) {
+		body.Close()
+		resp.Body = http.NoBody
+	} else {
+		resp.Body = body
+	}
+	return resp, nil
+}
+
+// isExtendedConnect reports whether req is an extended CONNECT request.
+func isExtendedConnect(req *http.Request) bool {
+	return req.Method == http.MethodConnect && req.Header.Get(":protocol") != ""
+}
+
+// checkExtendedConnect reports an error if the server does not
+// permit extended CONNECT requests.
+func (cc *ClientConn) checkExtendedConnect(ctx context.Context) error {
+	settings, err := cc.waitPeerSettings(ctx)
+	if err != nil {
+		return err
+	}
+	for _, s := range settings {
+		if s.ID == SettingEnableConnectProtocol && s.Value == 1 {
+			return nil
+		}
+	}
+	return errExtendedConnectUnsupported
+}
+
+// encodeRequestHeaders encodes the header section of a request.
+// https://www.rfc-editor.org/rfc/rfc9114#section-4.3.1
+func encodeRequestHeaders(req *http.Request) []byte {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	method := req.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	return encodeFieldSection(func(yield func(name, value string)) {
+		yield(":method", method)
+		if isExtendedConnect(req) {
+			yield(":protocol", req.Header.Get(":protocol"))
+			yield(":scheme", "https")
+			yield(":authority", host)
+			yield(":path", req.URL.RequestURI())
+		} else if method == http.MethodConnect {
+			yield(":authority", host)
+		} else {
+			yield(":scheme", "https")
+			yield(":authority", host)
+			yield(":path", req.URL.RequestURI())
+		}
+		h := req.Header
+		if len(req.Trailer) > 0 {
+			keys := make([]string, 0, len(req.Trailer))
+			for k := range req.Trailer {
+				keys = append(keys, http.CanonicalHeaderKey(k))
+			}
+			h = h.Clone()
+			h.Set("Trailer", strings.Join(keys, ","))
+		}
+		appendHeaderFields(func(name, value string) {
+			if name == "host" || name == "content-length" {
+				return
+			}
+			yield(name, value)
+		}, h)
+		if req.ContentLength > 0 {
+			yield("content-length", strconv.FormatInt(req.ContentLength, 10))
+		}
+		if _, ok := req.Header["User-Agent"]; !ok {
+			yield("user-agent", "Go-http-client/3")
+		}
+	})
+}
+
+// writeRequestBody writes the request body and trailers to st.
+func writeRequestBody(st *stream, req *http.Request) {
+	defer req.Body.Close()
+	buf := make([]byte, 16<<10)
+	for {
+		n, err := req.Body.Read(buf)
+		if n > 0 {
+			if err := writeData(st, buf[:n]); err != nil {
+				// The stream has been reset.
+				return
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			st.stream.Reset(uint64(errH3RequestCancelled))
+			return
+		}
+	}
+	if err := writeTrailer(st, req.Trailer); err != nil {
+		return
+	}
+	st.stream.CloseWrite()
+}
+
+// readResponse reads the response's header section from st.
+// Interim (1xx) responses are skipped.
+func (cc *ClientConn) readResponse(st *stream, req *http.Request) (*http.Response, error) {
+	for {
+		ftype, err := st.readFrameHeader()
+		if err == io.EOF {
+			return nil, errors.New("http3: stream ended before response headers")
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch ftype {
+		case frameTypeHeaders:
+		case frameTypeData:
+			return nil, errFrameUnexpected(ftype)
+		default:
+			if err := discardUnknownFrame(st, ftype); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		fs, err := readHeaders(st, cc.maxHeaderBytes)
+		if err != nil {
+			return nil, err
+		}
+		status, err := responseStatus(fs)
+		if err != nil {
+			return nil, err
+		}
+		if status < 200 {
+			// Interim response.
+			continue
+		}
+		return newClientResponse(req, status, fs)
+	}
+}
+
+// responseStatus returns the status code of a response header section.
+func responseStatus(fs *fieldSection) (int, error) {
+	if len(fs.pseudo) != 1 {
+		return 0, errMalformed("invalid response pseudo-header fields")
+	}
+	v, ok := fs.pseudo[":status"]
+	if !ok || len(v) != 3 {
+		return 0, errMalformed("invalid :status")
+	}
+	status, err := strconv.Atoi(v)
+	if err != nil || status < 100 || status == http.StatusSwitchingProtocols {
+		return 0, errMalformed("invalid :status")
+	}
+	return status, nil
+}
+
+// newClientResponse creates a response from a header section.
+func newClientResponse(req *http.Request, status int, fs *fieldSection) (*http.Response, error) {
+	resp := &http.Response{
+		Status:        strconv.Itoa(status) + " " + http.StatusText(status),
+		StatusCode:    status,
+		Proto:         "HTTP/3.0",
+		ProtoMajor:    3,
+		Header:        fs.header,
+		ContentLength: -1,
+		Request:       req,
+	}
+	if cl := fs.header.Values("Content-Length"); len(cl) > 0 {
+		n, err := strconv.ParseInt(cl[0], 10, 64)
+		if err != nil || n < 0 || len(cl) > 1 {
+			return nil, errMalformed("invalid Content-Length")
+		}
+		resp.ContentLength = n
+	}
+	for _, v := range fs.header.Values("Trailer") {
+		for _, k := range strings.Split(v, ",") {
+			k = http.CanonicalHeaderKey(strings.TrimSpace(k))
+			if k == "" {
+				continue
+			}
+			if resp.Trailer == nil {
+				resp.Trailer = make(http.Header)
+			}
+			resp.Trailer[k] = nil
+		}
+	}
+	fs.header.Del("Trailer")
+	return resp, nil
+}
+
+// A responseBody is the body of a response.
+type responseBody struct {
+	cc       *ClientConn
+	r        *bodyReader
+	stop     func() bool // stops the request cancelation func
+	doneOnce sync.Once
+}
+
+func (b *responseBody) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	if err != nil {
+		b.done()
+	}
+	return n, err
+}
+
+func (b *responseBody) Close() error {
+	b.r.Close()
+	b.done()
+	return nil
+}
+
+func (b *responseBody) done() {
+	b.doneOnce.Do(func() {
+		b.stop()
+		b.cc.requestDone()
+	})
+}
+
+// requestDone is called when a request is complete.
+func (cc *ClientConn) requestDone() {
+	cc.mu.Lock()
+	cc.inflight--
+	closeConn := cc.retired && cc.inflight == 0
+	cc.mu.Unlock()
+	if closeConn {
+		go cc.Close()
+	}
+}
+
+func closeRequestBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+}
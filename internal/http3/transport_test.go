@@ -0,0 +1,293 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package http3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRoundTripGet(t *testing.T) {
+	hostc := make(chan string, 1)
+	addr := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hostc <- r.Host
+		if r.Proto != "HTTP/3.0" || r.ProtoMajor != 3 {
+			t.Errorf("request proto = %v (%v), want HTTP/3.0 (3)", r.Proto, r.ProtoMajor)
+		}
+		if got, want := r.URL.RequestURI(), "/path?q=1"; got != want {
+			t.Errorf("request URI = %q, want %q", got, want)
+		}
+		if got, want := r.Header.Get("X-Request"), "req"; got != want {
+			t.Errorf("X-Request header = %q, want %q", got, want)
+		}
+		if r.RemoteAddr == "" {
+			t.Errorf("request RemoteAddr is empty")
+		}
+		if r.TLS == nil || r.TLS.NegotiatedProtocol != "h3" {
+			t.Errorf("request TLS = %v, want negotiated protocol h3", r.TLS)
+		}
+		w.Header().Set("X-Response", "resp")
+		w.Write([]byte("<html>hello</html>"))
+	}))
+	tr := newTestTransport(t)
+	req, _ := http.NewRequest("GET", "https://"+addr+"/path?q=1", nil)
+	req.Header.Set("X-Request", "req")
+	resp, err := tr.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 || resp.Proto != "HTTP/3.0" {
+		t.Errorf("response status = %v %v, want HTTP/3.0 200", resp.Proto, resp.StatusCode)
+	}
+	if got, want := resp.Header.Get("X-Response"), "resp"; got != want {
+		t.Errorf("X-Response header = %q, want %q", got, want)
+	}
+	if got, want := resp.Header.Get("Content-Type"), "text/html; charset=utf-8"; got != want {
+		t.Errorf("sniffed Content-Type = %q, want %q", got, want)
+	}
+	if got, want := resp.ContentLength, int64(len("<html>hello</html>")); got != want {
+		t.Errorf("ContentLength = %v, want %v", got, want)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil || string(body) != "<html>hello</html>" {
+		t.Errorf("body = %q, %v; want %q", body, err, "<html>hello</html>")
+	}
+	if got := <-hostc; got != addr {
+		t.Errorf("request Host = %q, want %q", got, addr)
+	}
+}
+
+func TestRoundTripPostLargeBody(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789abcdef"), 1<<16) // 1MiB
+	addr := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength != int64(len(data)) {
+			t.Errorf("request ContentLength = %v, want %v", r.ContentLength, len(data))
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		io.Copy(w, r.Body)
+	}))
+	tr := newTestTransport(t)
+	req, _ := http.NewRequest("POST", "https://"+addr+"/", bytes.NewReader(data))
+	resp, err := tr.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading response body: %v", err)
+	}
+	if !bytes.Equal(body, data) {
+		t.Errorf("echoed body mismatch: got %v bytes, want %v", len(body), len(data))
+	}
+	if resp.ContentLength != -1 {
+		t.Errorf("streamed response ContentLength = %v, want -1", resp.ContentLength)
+	}
+}
+
+func TestRoundTripTrailers(t *testing.T) {
+	addr := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		if got, want := r.Trailer.Get("X-Req-Trailer"), "reqval"; got != want {
+			t.Errorf("request trailer = %q, want %q", got, want)
+		}
+		w.Header().Set("Trailer", "X-Declared")
+		w.Write([]byte("body"))
+		w.(http.Flusher).Flush()
+		w.Header().Set("X-Declared", "declared")
+		w.Header().Set(http.TrailerPrefix+"X-Undeclared", "undeclared")
+	}))
+	tr := newTestTransport(t)
+	req, _ := http.NewRequest("POST", "https://"+addr+"/", strings.NewReader("request body"))
+	req.Trailer = http.Header{"X-Req-Trailer": nil}
+	body := &trailerSettingReader{r: req.Body, trailer: req.Trailer}
+	req.Body = io.NopCloser(body)
+	resp, err := tr.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if _, ok := resp.Trailer["X-Declared"]; !ok {
+		t.Errorf("response Trailer = %v, want X-Declared announced", resp.Trailer)
+	}
+	io.ReadAll(resp.Body)
+	if got, want := resp.Trailer.Get("X-Declared"), "declared"; got != want {
+		t.Errorf("declared response trailer = %q, want %q", got, want)
+	}
+}
+
+// A trailerSettingReader sets a request trailer when the body has been read.
+type trailerSettingReader struct {
+	r       io.Reader
+	trailer http.Header
+}
+
+func (r *trailerSettingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if err == io.EOF {
+		r.trailer.Set("X-Req-Trailer", "reqval")
+	}
+	return n, err
+}
+
+func TestRoundTripHead(t *testing.T) {
+	addr := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "1000")
+		w.Write([]byte("ignored"))
+	}))
+	tr := newTestTransport(t)
+	req, _ := http.NewRequest("HEAD", "https://"+addr+"/", nil)
+	resp, err := tr.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.ContentLength != 1000 {
+		t.Errorf("HEAD response ContentLength = %v, want 1000", resp.ContentLength)
+	}
+	if body, err := io.ReadAll(resp.Body); err != nil || len(body) != 0 {
+		t.Errorf("HEAD response body = %q, %v; want empty", body, err)
+	}
+}
+
+func TestRoundTripStatusAndInterimResponse(t *testing.T) {
+	addr := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", "</style.css>; rel=preload")
+		w.WriteHeader(http.StatusEarlyHints)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	tr := newTestTransport(t)
+	req, _ := http.NewRequest("GET", "https://"+addr+"/", nil)
+	resp, err := tr.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound || resp.Status != "404 Not Found" {
+		t.Errorf("status = %v (%q), want 404", resp.StatusCode, resp.Status)
+	}
+}
+
+func TestRoundTripHandlerPanic(t *testing.T) {
+	addr := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+	tr := newTestTransport(t)
+	req, _ := http.NewRequest("GET", "https://"+addr+"/", nil)
+	resp, err := tr.RoundTrip(req)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatalf("RoundTrip to panicking handler succeeded, want error")
+	}
+}
+
+func TestRoundTripCancel(t *testing.T) {
+	unblock := make(chan struct{})
+	defer close(unblock)
+	addr := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-unblock
+	}))
+	tr := newTestTransport(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, "GET", "https://"+addr+"/", nil)
+	errc := make(chan error, 1)
+	go func() {
+		_, err := tr.RoundTrip(req)
+		errc <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-errc:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("RoundTrip after cancel = %v, want context.Canceled", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("RoundTrip not canceled")
+	}
+}
+
+func TestRoundTripReusesConn(t *testing.T) {
+	addr := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	tr := newTestTransport(t)
+	var conns []*ClientConn
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest("GET", "https://"+addr+"/", nil)
+		resp, err := tr.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		tr.mu.Lock()
+		conns = append(conns, tr.conns[addr])
+		tr.mu.Unlock()
+	}
+	if conns[0] != conns[1] {
+		t.Errorf("second request used a new connection")
+	}
+}
+
+func TestGetConnConcurrentDials(t *testing.T) {
+	addr := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	tr := newTestTransport(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	const n = 5
+	conns := make(chan *ClientConn, n)
+	for i := 0; i < n; i++ {
+		go func() {
+			cc, err := tr.getConn(ctx, addr)
+			if err != nil {
+				t.Errorf("getConn: %v", err)
+			}
+			conns <- cc
+		}()
+	}
+	first := <-conns
+	for i := 1; i < n; i++ {
+		if cc := <-conns; cc != first {
+			t.Errorf("concurrent getConns returned different connections")
+		}
+	}
+}
+
+func TestGetConnClosesReplacedConn(t *testing.T) {
+	addr := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	tr := newTestTransport(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	old, err := tr.getConn(ctx, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old.handleGoaway(0)
+	cc, err := tr.getConn(ctx, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cc == old {
+		t.Fatalf("getConn returned connection which received GOAWAY")
+	}
+	if err := old.qconn.Wait(ctx); err == nil || ctx.Err() != nil {
+		t.Errorf("replaced connection not closed: Wait = %v", err)
+	}
+}
+
+func TestRoundTripUnsupportedScheme(t *testing.T) {
+	tr := newTestTransport(t)
+	req, _ := http.NewRequest("GET", "http://localhost/", nil)
+	if _, err := tr.RoundTrip(req); err == nil {
+		t.Errorf("RoundTrip with http scheme succeeded, want error")
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package masque
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+
+	"golang.org/x/net/internal/http3"
+)
+
+// A Client establishes UDP tunnels through CONNECT-UDP proxies.
+//
+// The Client must be installed as the Extension of its Transport.
+type Client struct {
+	connSet
+
+	// Transport is the HTTP/3 transport used to send CONNECT-UDP requests.
+	Transport *http3.Transport
+
+	// PathTemplate is the URI template path of the proxy.
+	// If empty, DefaultPathTemplate is used.
+	PathTemplate string
+}
+
+var _ http3.Extension = (*Client)(nil)
+
+// Dial establishes a tunnel to the UDP target address,
+// in the form "host:port", through the proxy at proxyAddr,
+// also in the form "host:port".
+//
+// The context bounds the time spent establishing the tunnel.
+// Once Dial returns, canceling the context has no effect on the tunnel.
+func (cl *Client) Dial(ctx context.Context, proxyAddr, target string) (*Conn, error) {
+	if cl.Transport == nil || cl.Transport.Extension != cl {
+		return nil, errors.New("masque: Client is not the Extension of its Transport")
+	}
+	host, portStr, err := net.SplitHostPort(target)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, fmt.Errorf("masque: invalid target port %q", portStr)
+	}
+	reqctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(ctx, cancel)
+	defer stop()
+
+	pr, pw := io.Pipe()
+	u := "https://" + proxyAddr + TargetPath(cl.PathTemplate, host, port)
+	req, err := http.NewRequestWithContext(reqctx, http.MethodConnect, u, pr)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	req.Header.Set(":protocol", protocol)
+	// "Clients [...] SHOULD send the Capsule-Protocol header field."
+	// https://www.rfc-editor.org/rfc/rfc9298#section-3.4
+	req.Header.Set("Capsule-Protocol", "?1")
+	resp, err := cl.Transport.RoundTrip(req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	fail := func(err error) (*Conn, error) {
+		resp.Body.Close()
+		pw.Close()
+		cancel()
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fail(fmt.Errorf("masque: proxy rejected request: %v", resp.Status))
+	}
+	qconn, id, ok := http3.ResponseStream(resp)
+	if !ok {
+		return fail(errors.New("masque: response has no stream"))
+	}
+	if !stop() {
+		// The context expired during the request.
+		return fail(ctx.Err())
+	}
+	c := newConn(qconn, id, &cl.connSet)
+	c.r = resp.Body
+	c.closeRead = func() {
+		resp.Body.Close()
+		cancel()
+	}
+	c.w = pw
+	c.flush = func() {}
+	c.closeWrite = func() { pw.Close() }
+	cl.add(c)
+	c.start()
+	return c, nil
+}
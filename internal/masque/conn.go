@@ -0,0 +1,211 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package masque
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"golang.org/x/net/internal/http3"
+	"golang.org/x/net/internal/quic"
+)
+
+// A Conn is a UDP tunnel established by a CONNECT-UDP request.
+// Each read and write carries a single UDP payload.
+//
+// Methods of a Conn may be called concurrently.
+type Conn struct {
+	qconn *quic.Conn
+	id    int64 // stream ID of the CONNECT-UDP request
+	set   *connSet
+
+	r          io.Reader // capsules from the peer
+	closeRead  func()
+	w          io.Writer // capsules to the peer
+	flush      func()
+	closeWrite func()
+
+	payloads chan []byte
+	donec    chan struct{}
+
+	wmu sync.Mutex // serializes writes to w
+
+	closeOnce sync.Once
+}
+
+// payloadQueueLen is the number of received UDP payloads which are queued
+// waiting to be read. When the queue is full, payloads are dropped.
+const payloadQueueLen = 64
+
+// errClosed is returned by operations on a closed Conn.
+var errClosed = errors.New("masque: tunnel closed")
+
+func newConn(qconn *quic.Conn, id int64, set *connSet) *Conn {
+	return &Conn{
+		qconn:    qconn,
+		id:       id,
+		set:      set,
+		payloads: make(chan []byte, payloadQueueLen),
+		donec:    make(chan struct{}),
+	}
+}
+
+// start begins reading capsules from the request stream.
+func (c *Conn) start() {
+	go c.readCapsules()
+}
+
+// readCapsules reads capsules sent by the peer on the request stream,
+// until the stream ends.
+func (c *Conn) readCapsules() {
+	r := bufio.NewReader(c.r)
+	for {
+		capsule, err := ReadCapsule(r)
+		if err != nil {
+			c.Close()
+			return
+		}
+		if capsule.Type == CapsuleTypeDatagram {
+			c.handleDatagram(capsule.Value)
+		}
+		// "Endpoints which receive a Capsule with an unknown Capsule Type
+		// MUST silently drop that Capsule."
+		// https://www.rfc-editor.org/rfc/rfc9297#section-3.2-12
+	}
+}
+
+// handleDatagram is called with each HTTP Datagram received for the tunnel.
+func (c *Conn) handleDatagram(b []byte) {
+	id, payload, err := ParseDatagram(b)
+	if err != nil || id != ContextIDUDP {
+		// "Intermediaries [...] MUST drop datagrams with unknown
+		// or unsupported context IDs."
+		// https://www.rfc-editor.org/rfc/rfc9298#section-4-4
+		return
+	}
+	select {
+	case c.payloads <- payload:
+	default:
+		// The queue is full; drop the payload.
+	}
+}
+
+// ReadPayload waits for and returns the next UDP payload received through the tunnel.
+func (c *Conn) ReadPayload(ctx context.Context) ([]byte, error) {
+	select {
+	case b := <-c.payloads:
+		return b, nil
+	case <-c.donec:
+		return nil, errClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// WritePayload sends a UDP payload through the tunnel.
+//
+// The payload is sent in a QUIC datagram, and may be lost.
+// If the payload is too large to fit in a QUIC datagram,
+// or the connection does not support datagrams,
+// it is sent reliably in a DATAGRAM capsule on the request stream.
+func (c *Conn) WritePayload(b []byte) error {
+	select {
+	case <-c.donec:
+		return errClosed
+	default:
+	}
+	dgram := AppendUDPPayload(nil, b)
+	if err := http3.SendDatagram(c.qconn, c.id, dgram); err == nil {
+		return nil
+	}
+	return c.writeCapsule(Capsule{Type: CapsuleTypeDatagram, Value: dgram})
+}
+
+func (c *Conn) writeCapsule(capsule Capsule) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if _, err := c.w.Write(AppendCapsule(nil, capsule)); err != nil {
+		return err
+	}
+	c.flush()
+	return nil
+}
+
+// Done returns a channel which is closed when the tunnel is closed.
+func (c *Conn) Done() <-chan struct{} {
+	return c.donec
+}
+
+// Close closes the tunnel.
+func (c *Conn) Close() error {
+	c.closeOnce.Do(func() {
+		c.set.remove(c)
+		close(c.donec)
+		c.wmu.Lock()
+		c.closeWrite()
+		c.wmu.Unlock()
+		c.closeRead()
+	})
+	return nil
+}
+
+// A connKey identifies a tunnel.
+type connKey struct {
+	qconn *quic.Conn
+	id    int64
+}
+
+// A connSet is the set of tunnels known to a Proxy or Client.
+// It implements http3.Extension.
+type connSet struct {
+	mu    sync.Mutex
+	conns map[connKey]*Conn
+}
+
+func (set *connSet) add(c *Conn) {
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	if set.conns == nil {
+		set.conns = make(map[connKey]*Conn)
+	}
+	set.conns[connKey{c.qconn, c.id}] = c
+}
+
+func (set *connSet) remove(c *Conn) {
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	k := connKey{c.qconn, c.id}
+	if set.conns[k] == c {
+		delete(set.conns, k)
+	}
+}
+
+// Settings implements http3.Extension.
+func (set *connSet) Settings() []http3.Setting {
+	return []http3.Setting{
+		{ID: http3.SettingH3Datagram, Value: 1},
+	}
+}
+
+// HandleStream implements http3.Extension.
+// CONNECT-UDP uses no streams other than request streams.
+func (set *connSet) HandleStream(qconn *quic.Conn, typ int64, qs *quic.Stream) bool {
+	return false
+}
+
+// HandleDatagram implements http3.Extension.
+func (set *connSet) HandleDatagram(qconn *quic.Conn, streamID int64, payload []byte) {
+	set.mu.Lock()
+	c := set.conns[connKey{qconn, streamID}]
+	set.mu.Unlock()
+	if c != nil {
+		c.handleDatagram(payload)
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+// Package masque implements proxying UDP in HTTP (CONNECT-UDP) over HTTP/3,
+// as used by MASQUE proxies.
+//
+// A client sends an extended CONNECT request to a proxy, naming a UDP target.
+// Once the proxy accepts the request, UDP payloads are exchanged as
+// HTTP Datagrams associated with the request stream, or as DATAGRAM
+// capsules on the request stream itself.
+//
+// A Proxy serves CONNECT-UDP requests received by an http3.Server,
+// and a Client establishes tunnels through a proxy.
+//
+// https://www.rfc-editor.org/rfc/rfc9298
+package masque
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/internal/capsule"
+	"golang.org/x/net/internal/quic/quicwire"
+)
+
+// protocol is the value of the :protocol pseudo-header field
+// in a CONNECT-UDP request.
+// https://www.rfc-editor.org/rfc/rfc9298#section-3.4
+const protocol = "connect-udp"
+
+// ContextIDUDP is the context ID of HTTP Datagrams which carry UDP payloads.
+// https://www.rfc-editor.org/rfc/rfc9298#section-4
+const ContextIDUDP = 0
+
+// CapsuleTypeDatagram is the type of a DATAGRAM capsule,
+// which carries an HTTP Datagram on the request stream.
+// https://www.rfc-editor.org/rfc/rfc9297#section-3.5
+const CapsuleTypeDatagram = 0x00
+
+// DefaultPathTemplate is the default URI template path of a CONNECT-UDP proxy.
+// https://www.rfc-editor.org/rfc/rfc9298#section-3
+const DefaultPathTemplate = "/.well-known/masque/udp/{target_host}/{target_port}/"
+
+// TargetPath returns the request path naming the UDP target host and port,
+// expanding the URI template path tmpl.
+// If tmpl is empty, DefaultPathTemplate is used.
+//
+// Only templates in which the variables are path segments are supported.
+func TargetPath(tmpl, host string, port int) string {
+	if tmpl == "" {
+		tmpl = DefaultPathTemplate
+	}
+	// "[...] IPv6 addresses [...] are encoded with colons percent-encoded."
+	// https://www.rfc-editor.org/rfc/rfc9298#section-2-4
+	r := strings.NewReplacer(
+		"{target_host}", strings.ReplaceAll(url.PathEscape(host), ":", "%3A"),
+		"{target_port}", strconv.Itoa(port),
+	)
+	return r.Replace(tmpl)
+}
+
+// ParseTargetPath returns the UDP target host and port named by a request path,
+// matching it against the URI template path tmpl.
+// If tmpl is empty, DefaultPathTemplate is used.
+func ParseTargetPath(tmpl, path string) (host string, port int, err error) {
+	if tmpl == "" {
+		tmpl = DefaultPathTemplate
+	}
+	tsegs := strings.Split(tmpl, "/")
+	psegs := strings.Split(path, "/")
+	if len(tsegs) != len(psegs) {
+		return "", 0, errors.New("masque: request path does not match template")
+	}
+	var hostSeg, portSeg string
+	for i, t := range tsegs {
+		switch t {
+		case "{target_host}":
+			hostSeg = psegs[i]
+		case "{target_port}":
+			portSeg = psegs[i]
+		default:
+			if t != psegs[i] {
+				return "", 0, errors.New("masque: request path does not match template")
+			}
+		}
+	}
+	host, err = url.PathUnescape(hostSeg)
+	if err != nil || host == "" {
+		return "", 0, fmt.Errorf("masque: invalid target host %q", hostSeg)
+	}
+	port, err = strconv.Atoi(portSeg)
+	if err != nil || port <= 0 || port > 65535 {
+		return "", 0, fmt.Errorf("masque: invalid target port %q", portSeg)
+	}
+	return host, port, nil
+}
+
+// targetAddr returns the address of a UDP target in the form "host:port".
+func targetAddr(host string, port int) string {
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// A Capsule is a capsule sent on a stream using the Capsule Protocol.
+// https://www.rfc-editor.org/rfc/rfc9297#section-3.2
+type Capsule struct {
+	Type  uint64
+	Value []byte
+}
+
+// AppendCapsule appends the encoding of a capsule to b.
+func AppendCapsule(b []byte, c Capsule) []byte {
+	return capsule.Append(b, c.Type, c.Value)
+}
+
+// maxCapsuleLen is the largest capsule ReadCapsule will read.
+// A DATAGRAM capsule can carry the largest UDP payload, plus a context ID.
+const maxCapsuleLen = 1<<16 + 8
+
+// ReadCapsule reads a capsule from r.
+// The value of a capsule larger than 64KiB is discarded,
+// and returned as nil.
+// It returns io.EOF if r ends cleanly before the start of a capsule.
+func ReadCapsule(r io.Reader) (Capsule, error) {
+	typ, value, err := capsule.Read(r, maxCapsuleLen)
+	if err != nil {
+		return Capsule{}, err
+	}
+	return Capsule{Type: typ, Value: value}, nil
+}
+
+// AppendUDPPayload appends an HTTP Datagram payload carrying a UDP payload to b.
+// https://www.rfc-editor.org/rfc/rfc9298#section-5
+func AppendUDPPayload(b, payload []byte) []byte {
+	b = quicwire.AppendVarint(b, ContextIDUDP)
+	return append(b, payload...)
+}
+
+// ParseDatagram parses an HTTP Datagram payload,
+// returning its context ID and the remaining payload.
+func ParseDatagram(b []byte) (contextID uint64, payload []byte, err error) {
+	id, n := quicwire.ConsumeVarint(b)
+	if n < 0 {
+		return 0, nil, errors.New("masque: datagram has no context ID")
+	}
+	return id, b[n:], nil
+}
@@ -0,0 +1,244 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package masque
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/internal/http3"
+	"golang.org/x/net/internal/quic"
+	"golang.org/x/net/internal/quic/quictest"
+)
+
+func TestTargetPath(t *testing.T) {
+	for _, test := range []struct {
+		tmpl string
+		host string
+		port int
+		path string
+	}{{
+		host: "192.0.2.6",
+		port: 443,
+		path: "/.well-known/masque/udp/192.0.2.6/443/",
+	}, {
+		host: "2001:db8::42",
+		port: 443,
+		path: "/.well-known/masque/udp/2001%3Adb8%3A%3A42/443/",
+	}, {
+		tmpl: "/proxy/{target_host}/{target_port}",
+		host: "example.com",
+		port: 53,
+		path: "/proxy/example.com/53",
+	}} {
+		if got := TargetPath(test.tmpl, test.host, test.port); got != test.path {
+			t.Errorf("TargetPath(%q, %q, %v) = %q, want %q", test.tmpl, test.host, test.port, got, test.path)
+		}
+		host, port, err := ParseTargetPath(test.tmpl, test.path)
+		if err != nil || host != test.host || port != test.port {
+			t.Errorf("ParseTargetPath(%q, %q) = %q, %v, %v; want %q, %v, nil", test.tmpl, test.path, host, port, err, test.host, test.port)
+		}
+	}
+}
+
+func TestParseTargetPathErrors(t *testing.T) {
+	for _, path := range []string{
+		"/.well-known/masque/udp/example.com/443",
+		"/.well-known/masque/udp/example.com/0/",
+		"/.well-known/masque/udp/example.com/https/",
+		"/.well-known/masque/udp//443/",
+		"/.well-known/masque/tcp/example.com/443/",
+	} {
+		if _, _, err := ParseTargetPath("", path); err == nil {
+			t.Errorf("ParseTargetPath(%q) succeeded, want error", path)
+		}
+	}
+}
+
+func TestCapsuleRoundTrip(t *testing.T) {
+	want := []Capsule{
+		{Type: CapsuleTypeDatagram, Value: AppendUDPPayload(nil, []byte("payload"))},
+		{Type: 0x1234, Value: []byte{}},
+	}
+	var b []byte
+	for _, c := range want {
+		b = AppendCapsule(b, c)
+	}
+	r := bytes.NewReader(b)
+	for _, w := range want {
+		got, err := ReadCapsule(r)
+		if err != nil || got.Type != w.Type || !bytes.Equal(got.Value, w.Value) {
+			t.Fatalf("ReadCapsule = %v, %v; want %v", got, err, w)
+		}
+	}
+	if _, err := ReadCapsule(r); err == nil {
+		t.Errorf("ReadCapsule at end of input succeeded, want error")
+	}
+	id, payload, err := ParseDatagram(want[0].Value)
+	if err != nil || id != ContextIDUDP || string(payload) != "payload" {
+		t.Errorf("ParseDatagram = %v, %q, %v; want %v, %q, nil", id, payload, err, ContextIDUDP, "payload")
+	}
+}
+
+// newTestProxy starts a CONNECT-UDP proxy, and returns its address.
+func newTestProxy(t *testing.T, p *Proxy) string {
+	t.Helper()
+	l, err := quic.Listen("udp", "127.0.0.1:0", &quic.Config{
+		TLSConfig: &tls.Config{
+			MinVersion:   tls.VersionTLS13,
+			Certificates: []tls.Certificate{quictest.LocalhostCertificate(t)},
+			NextProtos:   []string{"h3"},
+		},
+		MaxDatagramFrameSize: 1400,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http3.Server{
+		Extension: p,
+		Handler:   p,
+	}
+	go srv.Serve(l)
+	t.Cleanup(func() {
+		l.Close(context.Background())
+	})
+	return l.LocalAddr().String()
+}
+
+func newTestClient(t *testing.T) *Client {
+	tr := &http3.Transport{
+		Config: &quic.Config{
+			TLSConfig: &tls.Config{
+				MinVersion:         tls.VersionTLS13,
+				InsecureSkipVerify: true,
+			},
+			MaxDatagramFrameSize: 1400,
+		},
+	}
+	cl := &Client{Transport: tr}
+	tr.Extension = cl
+	t.Cleanup(tr.CloseIdleConnections)
+	return cl
+}
+
+// newUDPEcho starts a UDP server which echoes payloads, and returns its address.
+func newUDPEcho(t *testing.T) string {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pc.Close() })
+	go func() {
+		buf := make([]byte, 1<<16)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			pc.WriteTo(buf[:n], addr)
+		}
+	}()
+	return pc.LocalAddr().String()
+}
+
+// dialAny is a Proxy.Dial function which permits any target,
+// including the loopback addresses used by tests.
+func dialAny(ctx context.Context, network, address string) (net.Conn, error) {
+	var d net.Dialer
+	return d.DialContext(ctx, network, address)
+}
+
+func TestProxyUDP(t *testing.T) {
+	proxyAddr := newTestProxy(t, &Proxy{Dial: dialAny})
+	target := newUDPEcho(t)
+	cl := newTestClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	c, err := cl.Dial(ctx, proxyAddr, target)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer c.Close()
+
+	for _, payload := range []string{
+		"hello",
+		// Too large for a QUIC datagram, so sent in a DATAGRAM capsule.
+		strings.Repeat("x", 4000),
+	} {
+		// Datagrams are unreliable, so resend until we get a reply.
+		for {
+			if err := c.WritePayload([]byte(payload)); err != nil {
+				t.Fatal(err)
+			}
+			rctx, rcancel := context.WithTimeout(ctx, 100*time.Millisecond)
+			b, err := c.ReadPayload(rctx)
+			rcancel()
+			if err == nil {
+				if string(b) != payload {
+					t.Errorf("echoed payload of %v bytes, want %v", len(b), len(payload))
+				}
+				break
+			}
+			if ctx.Err() != nil {
+				t.Fatal("timed out waiting for payload")
+			}
+		}
+	}
+}
+
+func TestProxyRejectsTarget(t *testing.T) {
+	proxyAddr := newTestProxy(t, &Proxy{Dial: dialAny})
+	cl := newTestClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := cl.Dial(ctx, proxyAddr, "invalid.invalid:53"); err == nil {
+		t.Errorf("Dial to unresolvable target succeeded, want error")
+	}
+}
+
+func TestProxyDefaultDialDeniesLocalTargets(t *testing.T) {
+	proxyAddr := newTestProxy(t, &Proxy{})
+	target := newUDPEcho(t)
+	cl := newTestClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if c, err := cl.Dial(ctx, proxyAddr, target); err == nil {
+		c.Close()
+		t.Errorf("Dial to loopback target %v succeeded, want error", target)
+	}
+}
+
+func TestPublicAddr(t *testing.T) {
+	for _, test := range []struct {
+		addr string
+		want bool
+	}{
+		{"8.8.8.8", true},
+		{"2001:4860:4860::8888", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"fd00::1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"224.0.0.1", false},
+		{"0.0.0.0", false},
+		{"::ffff:127.0.0.1", false},
+	} {
+		if got := publicAddr(netip.MustParseAddr(test.addr)); got != test.want {
+			t.Errorf("publicAddr(%v) = %v, want %v", test.addr, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package masque
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+
+	"golang.org/x/net/internal/http3"
+)
+
+// A Proxy is an http.Handler which proxies UDP for CONNECT-UDP requests.
+//
+// The Proxy must be used as both the Handler (or a handler for its
+// PathTemplate) and the Extension of an http3.Server.
+type Proxy struct {
+	connSet
+
+	// PathTemplate is the URI template path of CONNECT-UDP requests.
+	// If empty, DefaultPathTemplate is used.
+	PathTemplate string
+
+	// Dial, if non-nil, is used to create the UDP socket for a target.
+	// It may reject a target by returning an error,
+	// in which case the request is rejected with a 403 (Forbidden) status.
+	//
+	// If nil, net.Dialer.DialContext is used, and targets with
+	// loopback, private, link-local, multicast, or unspecified addresses
+	// are rejected, so clients cannot reach hosts on the proxy's own network.
+	Dial func(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+var _ http3.Extension = (*Proxy)(nil)
+
+// Settings implements http3.Extension.
+// The proxy advertises support for extended CONNECT and HTTP Datagrams.
+func (p *Proxy) Settings() []http3.Setting {
+	return append([]http3.Setting{
+		{ID: http3.SettingEnableConnectProtocol, Value: 1},
+	}, p.connSet.Settings()...)
+}
+
+// IsConnectUDPRequest reports whether r is a CONNECT-UDP request.
+func IsConnectUDPRequest(r *http.Request) bool {
+	return r.Method == http.MethodConnect && r.Header.Get(":protocol") == protocol
+}
+
+// maxUDPPayload is the largest UDP payload.
+const maxUDPPayload = 1<<16 - 1
+
+// ServeHTTP proxies UDP for a CONNECT-UDP request,
+// until the client closes the tunnel.
+// https://www.rfc-editor.org/rfc/rfc9298#section-3
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !IsConnectUDPRequest(r) {
+		http.Error(w, "not a CONNECT-UDP request", http.StatusBadRequest)
+		return
+	}
+	qconn, id, ok := http3.RequestStream(r)
+	if !ok {
+		http.Error(w, "CONNECT-UDP requires HTTP/3", http.StatusBadRequest)
+		return
+	}
+	host, port, err := ParseTargetPath(p.PathTemplate, r.URL.Path)
+	if err != nil {
+		http.Error(w, "invalid CONNECT-UDP target", http.StatusBadRequest)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "ResponseWriter does not support flushing", http.StatusInternalServerError)
+		return
+	}
+	udp, err := p.dial(r.Context(), targetAddr(host, port))
+	if err != nil {
+		// Don't reveal details of the proxy's network to the client.
+		http.Error(w, "cannot proxy to target", http.StatusForbidden)
+		return
+	}
+	defer udp.Close()
+
+	c := newConn(qconn, id, &p.connSet)
+	c.r = r.Body
+	c.closeRead = func() { r.Body.Close() }
+	c.w = w
+	c.flush = flusher.Flush
+	// The request stream is closed when the handler returns.
+	c.closeWrite = func() {}
+	p.add(c)
+	defer c.Close()
+
+	// "The Capsule-Protocol header field [...] SHOULD be sent by the proxy."
+	// https://www.rfc-editor.org/rfc/rfc9298#section-3.5
+	w.Header().Set("Capsule-Protocol", "?1")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	c.start()
+
+	go func() {
+		defer c.Close()
+		buf := make([]byte, maxUDPPayload)
+		for {
+			n, err := udp.Read(buf)
+			if err != nil {
+				return
+			}
+			if err := c.WritePayload(buf[:n]); err != nil {
+				return
+			}
+		}
+	}()
+	for {
+		b, err := c.ReadPayload(r.Context())
+		if err != nil {
+			return
+		}
+		udp.Write(b)
+	}
+}
+
+func (p *Proxy) dial(ctx context.Context, address string) (net.Conn, error) {
+	if p.Dial != nil {
+		return p.Dial(ctx, "udp", address)
+	}
+	d := net.Dialer{
+		// The control function is called with the resolved address,
+		// so a name which resolves to a denied address is rejected.
+		Control: func(network, address string, c syscall.RawConn) error {
+			ap, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !publicAddr(ap.Addr()) {
+				return errDeniedTarget
+			}
+			return nil
+		},
+	}
+	return d.DialContext(ctx, "udp", address)
+}
+
+var errDeniedTarget = errors.New("masque: target address is not permitted")
+
+// publicAddr reports whether a is an address the default dialer may proxy to.
+func publicAddr(a netip.Addr) bool {
+	a = a.Unmap()
+	switch {
+	case a.IsLoopback(),
+		a.IsPrivate(),
+		a.IsLinkLocalUnicast(),
+		a.IsLinkLocalMulticast(),
+		a.IsInterfaceLocalMulticast(),
+		a.IsMulticast(),
+		a.IsUnspecified():
+		return false
+	}
+	return true
+}
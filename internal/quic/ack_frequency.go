@@ -0,0 +1,141 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import "time"
+
+// ackFrequencyState is the state of the Acknowledgement Frequency extension.
+// https://www.ietf.org/archive/id/draft-ietf-quic-ack-frequency-10.html
+type ackFrequencyState struct {
+	// The peer's min_ack_delay transport parameter,
+	// or -1 if the peer does not support the extension.
+	peerMinAckDelay time.Duration
+
+	// Our request to the peer, sent in an ACK_FREQUENCY frame.
+	// We send at most one request, so its sequence number is always 0.
+	send    sentVal
+	request ackFrequencyParams
+
+	// Largest sequence number of an ACK_FREQUENCY frame received from the peer,
+	// or -1 if none has been received.
+	recvSeq int64
+}
+
+// localMinAckDelay is the min_ack_delay transport parameter we send.
+// We cannot delay acknowledgements by less than the timer granularity.
+const localMinAckDelay = timerGranularity
+
+func (c *Conn) ackFrequencyInit() {
+	c.ackFreq.peerMinAckDelay = -1
+	c.ackFreq.recvSeq = -1
+}
+
+// ackFrequencyMinAckDelay returns the min_ack_delay transport parameter to send,
+// or -1 if we do not support the extension.
+func (c *Conn) ackFrequencyMinAckDelay() time.Duration {
+	if !c.config.AckFrequency {
+		return -1
+	}
+	return localMinAckDelay
+}
+
+// receivePeerMinAckDelay records the peer's min_ack_delay transport parameter.
+// It must be called after the peer's max_ack_delay has been recorded.
+func (c *Conn) receivePeerMinAckDelay(d time.Duration) {
+	c.ackFreq.peerMinAckDelay = d
+	if !c.config.AckFrequency || d < 0 {
+		return
+	}
+	threshold := c.config.ackElicitingThreshold()
+	delay := c.config.RequestedMaxAckDelay
+	if threshold == 1 && delay <= 0 {
+		// The defaults are what the peer does already.
+		return
+	}
+	if delay <= 0 {
+		delay = c.loss.maxAckDelay
+	}
+	c.ackFreq.request = ackFrequencyParams{
+		ackElicitingThreshold: threshold,
+		maxAckDelay:           max(delay, d),
+		reorderingThreshold:   1,
+	}
+	c.ackFreq.send.set()
+}
+
+// peerSupportsAckFrequency reports whether we may send ACK_FREQUENCY
+// and IMMEDIATE_ACK frames to the peer.
+func (c *Conn) peerSupportsAckFrequency() bool {
+	return c.config.AckFrequency && c.ackFreq.peerMinAckDelay >= 0
+}
+
+func (c *Conn) handleAckFrequencyFrame(now time.Time, space numberSpace, payload []byte) int {
+	seq, threshold, delay, reordering, n := consumeAckFrequencyFrame(payload)
+	if n < 0 {
+		return -1
+	}
+	if !c.config.AckFrequency {
+		// "[...] an endpoint that has not advertised the min_ack_delay
+		// transport parameter MUST treat receipt of an ACK_FREQUENCY frame
+		// as a connection error of type PROTOCOL_VIOLATION."
+		// https://www.ietf.org/archive/id/draft-ietf-quic-ack-frequency-10.html#section-3-4
+		c.abort(now, localTransportError(errProtocolViolation))
+		return -1
+	}
+	if delay < localMinAckDelay {
+		// "Receipt of a [Requested Max Ack Delay] value less than the
+		// min_ack_delay advertised by the receiver MUST be treated as
+		// a connection error of type PROTOCOL_VIOLATION."
+		// https://www.ietf.org/archive/id/draft-ietf-quic-ack-frequency-10.html#section-4-4.6.1
+		c.abort(now, localTransportError(errProtocolViolation))
+		return -1
+	}
+	if seq <= c.ackFreq.recvSeq {
+		// "[...] if the sequence number of the received frame is
+		// less than or equal to the largest received sequence number,
+		// the endpoint MUST ignore this frame."
+		// https://www.ietf.org/archive/id/draft-ietf-quic-ack-frequency-10.html#section-4-6
+		return n
+	}
+	c.ackFreq.recvSeq = seq
+	c.acks[space].setFrequency(ackFrequencyParams{
+		ackElicitingThreshold: threshold,
+		maxAckDelay:           delay,
+		reorderingThreshold:   reordering,
+	})
+	return n
+}
+
+func (c *Conn) handleImmediateAckFrame(now time.Time, space numberSpace) int {
+	if !c.config.AckFrequency {
+		// Same rule as for ACK_FREQUENCY frames, above.
+		c.abort(now, localTransportError(errProtocolViolation))
+		return -1
+	}
+	// "[...] the receiver SHOULD send an ACK frame without delay."
+	// https://www.ietf.org/archive/id/draft-ietf-quic-ack-frequency-10.html#section-5
+	c.acks[space].ackImmediately(now)
+	return 1
+}
+
+// appendAckFrequencyFrame appends an ACK_FREQUENCY frame, if one needs to be sent.
+// It returns false if the frame did not fit in the current packet.
+func (c *Conn) appendAckFrequencyFrame(pnum packetNumber, pto bool) bool {
+	if !c.ackFreq.send.shouldSendPTO(pto) {
+		return true
+	}
+	r := c.ackFreq.request
+	if !c.w.appendAckFrequencyFrame(0, r.ackElicitingThreshold, r.maxAckDelay, r.reorderingThreshold) {
+		return false
+	}
+	c.ackFreq.send.setSent(pnum)
+	// Once the peer receives this frame it may delay acknowledgements
+	// by the requested amount, so use it when computing the PTO.
+	// https://www.ietf.org/archive/id/draft-ietf-quic-ack-frequency-10.html#section-7.1
+	c.loss.setMaxAckDelay(max(c.loss.maxAckDelay, r.maxAckDelay))
+	return true
+}
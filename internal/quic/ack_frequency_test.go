@@ -0,0 +1,201 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"crypto/tls"
+	"testing"
+	"time"
+)
+
+func TestAckFrequencyTransportParameter(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		tc := newTestConn(t, clientSide, func(c *Config) {
+			c.AckFrequency = enabled
+		})
+		got := tc.sentTransportParameters.minAckDelay
+		want := time.Duration(-1)
+		if enabled {
+			want = time.Millisecond
+		}
+		if got != want {
+			t.Errorf("AckFrequency=%v: sent min_ack_delay = %v, want %v", enabled, got, want)
+		}
+	}
+}
+
+func ackFrequencyConfig(c *Config) {
+	c.AckFrequency = true
+	c.AckElicitingThreshold = 10
+	c.RequestedMaxAckDelay = 100 * time.Millisecond
+}
+
+func TestAckFrequencySendRequest(t *testing.T) {
+	tc := newTestConn(t, clientSide, ackFrequencyConfig, func(p *transportParameters) {
+		p.minAckDelay = time.Millisecond
+	})
+	tc.ignoreFrame(frameTypeAck)
+	tc.ignoreFrame(frameTypeCrypto)
+	tc.ignoreFrame(frameTypeNewConnectionID)
+	tc.writeFrames(packetTypeInitial,
+		debugFrameCrypto{
+			data: tc.cryptoDataIn[tls.QUICEncryptionLevelInitial],
+		})
+	tc.writeFrames(packetTypeHandshake,
+		debugFrameCrypto{
+			data: tc.cryptoDataIn[tls.QUICEncryptionLevelHandshake],
+		})
+	tc.wantFrame("client requests peer's acknowledgement frequency",
+		packetType1RTT, debugFrameAckFrequency{
+			seq:                   0,
+			ackElicitingThreshold: 10,
+			maxAckDelay:           100 * time.Millisecond,
+			reorderingThreshold:   1,
+		})
+	if got, want := tc.conn.loss.maxAckDelay, 100*time.Millisecond; got != want {
+		t.Errorf("after sending ACK_FREQUENCY: maxAckDelay = %v, want %v", got, want)
+	}
+}
+
+func TestAckFrequencyNoRequestWithoutPeerSupport(t *testing.T) {
+	tc := newTestConn(t, clientSide, ackFrequencyConfig)
+	tc.handshake()
+	tc.wantIdle("peer does not support ACK_FREQUENCY, no request sent")
+}
+
+func TestAckFrequencyReceiveThreshold(t *testing.T) {
+	tc := newTestConn(t, serverSide, func(c *Config) {
+		c.AckFrequency = true
+	})
+	tc.handshake()
+	tc.writeFrames(packetType1RTT, debugFrameAckFrequency{
+		seq:                   0,
+		ackElicitingThreshold: 3,
+		maxAckDelay:           100 * time.Millisecond,
+		reorderingThreshold:   1,
+	})
+	tc.writeFrames(packetType1RTT, debugFramePing{})
+	tc.writeFrames(packetType1RTT, debugFramePing{})
+	tc.wantIdle("conn delays ACK until threshold is exceeded")
+	tc.writeFrames(packetType1RTT, debugFramePing{})
+	tc.wantFrameType("conn sends ACK after receiving more than threshold packets",
+		packetType1RTT, debugFrameAck{})
+}
+
+func TestAckFrequencyReceiveMaxAckDelay(t *testing.T) {
+	tc := newTestConn(t, serverSide, func(c *Config) {
+		c.AckFrequency = true
+	})
+	tc.handshake()
+	tc.writeFrames(packetType1RTT, debugFrameAckFrequency{
+		seq:                   0,
+		ackElicitingThreshold: 10,
+		maxAckDelay:           100 * time.Millisecond,
+		reorderingThreshold:   1,
+	})
+	tc.advance(100*time.Millisecond - timerGranularity - 1)
+	tc.wantIdle("conn delays ACK by requested max ack delay")
+	tc.advance(1)
+	tc.wantFrameType("conn sends ACK after requested max ack delay",
+		packetType1RTT, debugFrameAck{})
+}
+
+func TestAckFrequencyReceiveOldSequenceNumber(t *testing.T) {
+	tc := newTestConn(t, serverSide, func(c *Config) {
+		c.AckFrequency = true
+	})
+	tc.handshake()
+	tc.writeFrames(packetType1RTT, debugFrameAckFrequency{
+		seq:                   1,
+		ackElicitingThreshold: 10,
+		maxAckDelay:           100 * time.Millisecond,
+		reorderingThreshold:   1,
+	})
+	tc.writeFrames(packetType1RTT, debugFrameAckFrequency{
+		seq:                   0,
+		ackElicitingThreshold: 1,
+		maxAckDelay:           time.Millisecond,
+		reorderingThreshold:   1,
+	})
+	tc.wantIdle("frame with old sequence number is ignored")
+}
+
+func TestAckFrequencyReceiveErrors(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		enabled bool
+		f       debugFrame
+	}{{
+		name:    "ACK_FREQUENCY when not enabled",
+		enabled: false,
+		f: debugFrameAckFrequency{
+			ackElicitingThreshold: 1,
+			maxAckDelay:           10 * time.Millisecond,
+			reorderingThreshold:   1,
+		},
+	}, {
+		name:    "IMMEDIATE_ACK when not enabled",
+		enabled: false,
+		f:       debugFrameImmediateAck{},
+	}, {
+		name:    "delay less than min_ack_delay",
+		enabled: true,
+		f: debugFrameAckFrequency{
+			ackElicitingThreshold: 1,
+			maxAckDelay:           500 * time.Microsecond,
+			reorderingThreshold:   1,
+		},
+	}} {
+		t.Run(test.name, func(t *testing.T) {
+			tc := newTestConn(t, serverSide, func(c *Config) {
+				c.AckFrequency = test.enabled
+			})
+			tc.handshake()
+			tc.writeFrames(packetType1RTT, test.f)
+			tc.wantFrame("invalid frame causes connection close",
+				packetType1RTT, debugFrameConnectionCloseTransport{
+					code: errProtocolViolation,
+				})
+		})
+	}
+}
+
+func TestAckFrequencyImmediateAck(t *testing.T) {
+	tc := newTestConn(t, serverSide, func(c *Config) {
+		c.AckFrequency = true
+	})
+	tc.handshake()
+	tc.writeFrames(packetType1RTT, debugFrameAckFrequency{
+		seq:                   0,
+		ackElicitingThreshold: 10,
+		maxAckDelay:           100 * time.Millisecond,
+		reorderingThreshold:   1,
+	})
+	tc.wantIdle("conn delays ACK")
+	tc.writeFrames(packetType1RTT, debugFrameImmediateAck{})
+	tc.wantFrameType("IMMEDIATE_ACK causes conn to send ACK without delay",
+		packetType1RTT, debugFrameAck{})
+}
+
+func TestAckFrequencyPTOProbeSendsImmediateAck(t *testing.T) {
+	tc := newTestConn(t, serverSide, func(c *Config) {
+		c.AckFrequency = true
+		c.KeepAlivePeriod = 10 * time.Second
+	}, func(p *transportParameters) {
+		p.minAckDelay = time.Millisecond
+	})
+	tc.handshake()
+	tc.ignoreFrame(frameTypeAck)
+	tc.writeAckForAll()
+
+	tc.advance(10 * time.Second)
+	tc.wantFrame("conn sends keep-alive PING",
+		packetType1RTT, debugFramePing{})
+	tc.advanceToTimer()
+	tc.wantFrame("PTO probe is an IMMEDIATE_ACK when peer supports ACK frequency",
+		packetType1RTT, debugFrameImmediateAck{})
+}
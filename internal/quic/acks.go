@@ -27,6 +27,44 @@ type ackState struct {
 
 	// The number of ack-eliciting packets in seen that we have not yet acknowledged.
 	unackedAckEliciting int
+
+	// Acknowledgement frequency requested by the peer in an ACK_FREQUENCY frame.
+	// When hasFreq is false, we use the RFC 9000 defaults.
+	freq    ackFrequencyParams
+	hasFreq bool
+}
+
+// ackFrequencyParams are the parameters of an ACK_FREQUENCY frame.
+// https://www.ietf.org/archive/id/draft-ietf-quic-ack-frequency-10.html#section-4
+type ackFrequencyParams struct {
+	ackElicitingThreshold int64
+	maxAckDelay           time.Duration
+	reorderingThreshold   int64
+}
+
+// frequency returns the acknowledgement frequency parameters in use.
+func (acks *ackState) frequency() ackFrequencyParams {
+	if acks.hasFreq {
+		return acks.freq
+	}
+	// These values produce the behavior specified by RFC 9000.
+	// https://www.ietf.org/archive/id/draft-ietf-quic-ack-frequency-10.html#section-6
+	return ackFrequencyParams{
+		ackElicitingThreshold: 1,
+		maxAckDelay:           maxAckDelay,
+		reorderingThreshold:   1,
+	}
+}
+
+// setFrequency sets the acknowledgement frequency parameters requested by the peer.
+func (acks *ackState) setFrequency(freq ackFrequencyParams) {
+	acks.freq = freq
+	acks.hasFreq = true
+}
+
+// ackImmediately causes the next ACK frame to be sent without delay.
+func (acks *ackState) ackImmediately(now time.Time) {
+	acks.nextAck = now
 }
 
 // shouldProcess reports whether a packet should be handled or discarded.
@@ -53,14 +91,16 @@ func (acks *ackState) receive(now time.Time, space numberSpace, num packetNumber
 		} else if acks.nextAck.IsZero() {
 			// This packet does not need to be acknowledged immediately,
 			// but the ack must not be intentionally delayed by more than
-			// the max_ack_delay transport parameter we sent to the peer.
+			// the max_ack_delay transport parameter we sent to the peer,
+			// or the Requested Max Ack Delay the peer sent in an ACK_FREQUENCY frame.
 			//
 			// We always delay acks by the maximum allowed, less the timer
 			// granularity. ("[max_ack_delay] SHOULD include the receiver's
 			// expected delays in alarms firing.")
 			//
 			// https://www.rfc-editor.org/rfc/rfc9000#section-18.2-4.28.1
-			acks.nextAck = now.Add(maxAckDelay - timerGranularity)
+			delay := max(0, acks.frequency().maxAckDelay-timerGranularity)
+			acks.nextAck = now.Add(delay)
 		}
 		if num > acks.maxAckEliciting {
 			acks.maxAckEliciting = num
@@ -101,13 +141,15 @@ func (acks *ackState) mustAckImmediately(space numberSpace, num packetNumber) bo
 		// https://www.rfc-editor.org/rfc/rfc9000.html#section-13.2.1-2
 		return true
 	}
-	if num < acks.maxAckEliciting {
+	freq := acks.frequency()
+	if freq.reorderingThreshold == 1 && num < acks.maxAckEliciting {
 		// "[...] when the received packet has a packet number less than another
 		// ack-eliciting packet that has been received [...]"
 		// https://www.rfc-editor.org/rfc/rfc9000.html#section-13.2.1-8.1
 		return true
 	}
-	if acks.seen.rangeContaining(acks.maxAckEliciting).end != num {
+	if freq.reorderingThreshold > 0 &&
+		int64(num-acks.seen.rangeContaining(acks.maxAckEliciting).end) >= freq.reorderingThreshold {
 		// "[...] when the packet has a packet number larger than the highest-numbered
 		// ack-eliciting packet that has been received and there are missing packets
 		// between that packet and this packet."
@@ -128,11 +170,19 @@ func (acks *ackState) mustAckImmediately(space numberSpace, num packetNumber) bo
 		// highest-numbered ack-eliciting packet: [0, 1) in the above example.
 		// If the range ends just before the packet we are now processing,
 		// there are no gaps. If it does not, there must be a gap.
+		//
+		// When the peer has set a Reordering Threshold greater than 1,
+		// we only acknowledge immediately when the gap is at least that large.
+		// A threshold of 0 disables immediate acknowledgement of reordered packets.
+		// https://www.ietf.org/archive/id/draft-ietf-quic-ack-frequency-10.html#section-6.2
 		return true
 	}
-	if acks.unackedAckEliciting >= 2 {
+	if int64(acks.unackedAckEliciting) > freq.ackElicitingThreshold {
 		// "[...] after receiving at least two ack-eliciting packets."
 		// https://www.rfc-editor.org/rfc/rfc9000.html#section-13.2.2
+		//
+		// An ACK_FREQUENCY frame from the peer may change this threshold.
+		// https://www.ietf.org/archive/id/draft-ietf-quic-ack-frequency-10.html#section-6.1
 		return true
 	}
 	return false
@@ -0,0 +1,41 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"net"
+	"net/netip"
+	"time"
+)
+
+// A CapturedDatagram is a UDP datagram sent or received by an endpoint.
+// See Config.CaptureDatagram.
+type CapturedDatagram struct {
+	Time time.Time
+	Sent bool // true if the datagram was sent, false if it was received
+	Src  netip.AddrPort
+	Dst  netip.AddrPort
+	Data []byte
+}
+
+func (l *Listener) captureDatagram(sent bool, b []byte, peerAddr netip.AddrPort) {
+	var localAddr netip.AddrPort
+	if a, ok := l.udpConn.LocalAddr().(*net.UDPAddr); ok {
+		localAddr = a.AddrPort()
+	}
+	d := &CapturedDatagram{
+		Time: l.timeNow(),
+		Sent: sent,
+		Data: b,
+	}
+	if sent {
+		d.Src, d.Dst = localAddr, peerAddr
+	} else {
+		d.Src, d.Dst = peerAddr, localAddr
+	}
+	l.config.CaptureDatagram(d)
+}
@@ -0,0 +1,78 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"bytes"
+	"net/netip"
+	"sync"
+	"testing"
+)
+
+func TestCaptureDatagram(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		captured []CapturedDatagram
+	)
+	tl := newTestListener(t, &Config{
+		TLSConfig: newTestTLSConfig(serverSide),
+		CaptureDatagram: func(d *CapturedDatagram) {
+			mu.Lock()
+			defer mu.Unlock()
+			c := *d
+			c.Data = bytes.Clone(d.Data)
+			captured = append(captured, c)
+		},
+	})
+	pkt := []byte{
+		0b1000_0000,
+		0x00, 0x00, 0x00, 0x0f, // unknown version
+		4, 1, 2, 3, 4, // dst conn id
+		4, 5, 6, 7, 8, // src conn id
+	}
+	for len(pkt) < paddedInitialDatagramSize {
+		pkt = append(pkt, 0)
+	}
+	tl.write(&datagram{
+		b:    pkt,
+		addr: testClientAddr,
+	})
+	resp := tl.read()
+	if resp == nil {
+		t.Fatalf("got no response; want Version Negotiation")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	localAddr := netip.MustParseAddrPort("127.0.0.1:443")
+	want := []CapturedDatagram{{
+		Time: tl.now,
+		Sent: false,
+		Src:  testClientAddr,
+		Dst:  localAddr,
+		Data: pkt,
+	}, {
+		Time: tl.now,
+		Sent: true,
+		Src:  localAddr,
+		Dst:  testClientAddr,
+		Data: resp,
+	}}
+	if len(captured) != len(want) {
+		t.Fatalf("captured %v datagrams, want %v", len(captured), len(want))
+	}
+	for i := range want {
+		got, want := captured[i], want[i]
+		if !got.Time.Equal(want.Time) || got.Sent != want.Sent || got.Src != want.Src || got.Dst != want.Dst {
+			t.Errorf("captured datagram %v: {Time:%v Sent:%v Src:%v Dst:%v}, want {Time:%v Sent:%v Src:%v Dst:%v}",
+				i, got.Time, got.Sent, got.Src, got.Dst, want.Time, want.Sent, want.Src, want.Dst)
+		}
+		if !bytes.Equal(got.Data, want.Data) {
+			t.Errorf("captured datagram %v: Data={%x}, want {%x}", i, got.Data, want.Data)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import "time"
+
+// A Clock is a source of time for an endpoint.
+// It is used for all connection timers and RTT measurements.
+//
+// The time returned by Now must never go backwards.
+// A coarse clock (one which only advances periodically) may be used,
+// at the cost of less precise RTT estimates and loss detection.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// AfterFunc waits for the duration to elapse and then calls f
+	// in its own goroutine.
+	// It returns a ClockTimer which can be used to cancel or reset the call.
+	AfterFunc(d time.Duration, f func()) ClockTimer
+}
+
+// A ClockTimer is a timer created by Clock.AfterFunc.
+// Its methods have the same semantics as those of time.Timer.
+type ClockTimer interface {
+	Reset(d time.Duration) bool
+	Stop() bool
+}
+
+// systemClock is the default Clock, using the time package.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+func (systemClock) AfterFunc(d time.Duration, f func()) ClockTimer {
+	return time.AfterFunc(d, f)
+}
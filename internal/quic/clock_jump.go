@@ -0,0 +1,51 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import "time"
+
+// clockJumpState tracks discontinuities in the connection's clock.
+//
+// When the host is suspended or a virtual machine is paused,
+// the clock may leap forwards by an arbitrary amount when execution resumes.
+// Every connection timer expires at once, and the connection would
+// declare all packets in flight lost, close due to the idle timeout,
+// or take an enormous RTT sample.
+//
+// Instead, a connection which wakes up well after the time it expected to
+// considers the clock to have jumped, and runs on a clock which omits the jump.
+type clockJumpState struct {
+	// skew is the total duration of all detected jumps.
+	// The connection's time is the clock's time minus skew.
+	skew time.Duration
+}
+
+// clockNow converts t, a time read from the endpoint's clock,
+// into the connection's time.
+//
+// The expect parameter is the latest connection time at which the conn
+// expected to read the clock: either the time of its next timer event,
+// or the last time it read the clock when it did not block in between.
+// If expect is zero, jumps are not detected.
+func (c *Conn) clockNow(t, expect time.Time) time.Time {
+	now := t.Add(-c.clockJump.skew)
+	threshold := c.config.clockJumpThreshold()
+	if threshold == 0 || expect.IsZero() {
+		return now
+	}
+	d := now.Sub(expect)
+	if d <= threshold {
+		return now
+	}
+	c.clockJump.skew += d
+	now = expect
+	c.traceClockJumped(now, d)
+	// The peer may have given up on the connection while we were away.
+	// Send a PING to promptly find out.
+	c.idle.sendKeepAlive = true
+	return now
+}
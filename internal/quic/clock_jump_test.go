@@ -0,0 +1,116 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// jumpClock is a Clock which may be made to leap forwards.
+type jumpClock struct {
+	offset atomic.Int64 // time.Duration
+}
+
+func (c *jumpClock) Now() time.Time {
+	return time.Now().Add(time.Duration(c.offset.Load()))
+}
+
+func (c *jumpClock) AfterFunc(d time.Duration, f func()) ClockTimer {
+	return time.AfterFunc(d, f)
+}
+
+func (c *jumpClock) jump(d time.Duration) {
+	c.offset.Add(int64(d))
+}
+
+type clockJumpTracer struct {
+	testTracer
+	mu    sync.Mutex
+	jumps []time.Duration
+}
+
+func (t *clockJumpTracer) ClockJumped(now time.Time, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.jumps = append(t.jumps, d)
+}
+
+func TestClockJump(t *testing.T) {
+	for _, workers := range []int{0, 1} {
+		clock := &jumpClock{}
+		tracer := &clockJumpTracer{}
+		cli, srv := newLocalConnPair(t, &Config{}, &Config{
+			Clock:       clock,
+			ConnWorkers: workers,
+			NewTracer:   func(*Conn) ConnTracer { return tracer },
+		})
+
+		const jump = 1 * time.Hour
+		clock.jump(jump)
+
+		// The connection survives the jump, rather than hitting its idle timeout.
+		ctx := context.Background()
+		s, err := cli.NewStream(ctx)
+		if err != nil {
+			t.Fatalf("ConnWorkers=%v: NewStream: %v", workers, err)
+		}
+		s.Write([]byte("hello"))
+		s.CloseWrite()
+		sr, err := srv.AcceptStream(ctx)
+		if err != nil {
+			t.Fatalf("ConnWorkers=%v: AcceptStream: %v", workers, err)
+		}
+		if b, err := io.ReadAll(sr); err != nil || string(b) != "hello" {
+			t.Fatalf("ConnWorkers=%v: read %q, %v; want %q", workers, b, err, "hello")
+		}
+		if err := cli.Err(); err != nil {
+			t.Fatalf("ConnWorkers=%v: after clock jump, cli.Err() = %v", workers, err)
+		}
+
+		var srtt time.Duration
+		cli.runOnLoop(func(now time.Time, c *Conn) {
+			srtt = c.loss.rtt.smoothedRTT
+		})
+		if srtt > time.Second {
+			t.Errorf("ConnWorkers=%v: after clock jump, smoothed RTT = %v", workers, srtt)
+		}
+
+		tracer.mu.Lock()
+		jumps := tracer.jumps
+		tracer.mu.Unlock()
+		if len(jumps) != 1 || jumps[0] > jump || jumps[0] < jump-defaultMaxIdleTimeout {
+			t.Errorf("ConnWorkers=%v: traced clock jumps %v, want one jump of about %v", workers, jumps, jump)
+		}
+		cli.Abort(nil)
+	}
+}
+
+func TestClockJumpDetectionDisabled(t *testing.T) {
+	clock := &jumpClock{}
+	cli, _ := newLocalConnPair(t, &Config{}, &Config{
+		Clock:              clock,
+		ClockJumpThreshold: -1,
+	})
+	clock.jump(1 * time.Hour)
+	// Wake the connection: it finds its idle timeout has long since expired.
+	s, err := cli.NewSendOnlyStream(context.Background())
+	if err == nil {
+		s.Write([]byte("hello"))
+		s.CloseWrite()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := cli.Wait(ctx); !errors.Is(err, IdleTimeoutError{}) {
+		t.Fatalf("conn.Wait() = %v, want IdleTimeoutError", err)
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// offsetClock is a Clock which runs a fixed offset from the system clock.
+type offsetClock struct {
+	offset     time.Duration
+	nowCalls   atomic.Int64
+	timerCalls atomic.Int64
+}
+
+func (c *offsetClock) Now() time.Time {
+	c.nowCalls.Add(1)
+	return time.Now().Add(c.offset)
+}
+
+func (c *offsetClock) AfterFunc(d time.Duration, f func()) ClockTimer {
+	c.timerCalls.Add(1)
+	return time.AfterFunc(d, f)
+}
+
+func TestConfigClock(t *testing.T) {
+	for _, workers := range []int{0, 1} {
+		clock := &offsetClock{offset: 24 * time.Hour}
+		var (
+			mu       sync.Mutex
+			captured []time.Time
+		)
+		conf := &Config{
+			Clock:       clock,
+			ConnWorkers: workers,
+			CaptureDatagram: func(d *CapturedDatagram) {
+				mu.Lock()
+				defer mu.Unlock()
+				captured = append(captured, d.Time)
+			},
+		}
+		start := time.Now()
+		newLocalConnPair(t, &Config{}, conf)
+
+		if clock.nowCalls.Load() == 0 {
+			t.Errorf("ConnWorkers=%v: Clock.Now never called", workers)
+		}
+		if clock.timerCalls.Load() == 0 {
+			t.Errorf("ConnWorkers=%v: Clock.AfterFunc never called", workers)
+		}
+		mu.Lock()
+		if len(captured) == 0 {
+			t.Errorf("ConnWorkers=%v: no datagrams captured", workers)
+		}
+		for _, tm := range captured {
+			if tm.Before(start.Add(clock.offset)) {
+				t.Errorf("ConnWorkers=%v: captured datagram time %v, want time from Config.Clock", workers, tm)
+				break
+			}
+		}
+		mu.Unlock()
+	}
+}
@@ -0,0 +1,187 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"sync"
+
+	"golang.org/x/net/internal/quic"
+	"golang.org/x/net/internal/quic/quicwire"
+)
+
+// An invariantChecker checks datagrams sent by an endpoint
+// against the wire-level invariants of QUIC version 1.
+//
+// Packet payloads are encrypted, so only the packet headers are checked:
+// the version-independent properties of RFC 8999, and the header and
+// datagram size requirements of RFC 9000.
+type invariantChecker struct {
+	serverAddr netip.AddrPort
+
+	mu         sync.Mutex
+	violations []string
+	datagrams  int
+}
+
+const (
+	maxConnIDLen              = 20
+	paddedInitialDatagramSize = 1200
+)
+
+// capture records a datagram for checking.
+// It is used as a Config.CaptureDatagram function.
+func (ic *invariantChecker) capture(d *quic.CapturedDatagram) {
+	if !d.Sent {
+		return
+	}
+	isClient := d.Src != ic.serverAddr
+	err := checkDatagram(d.Data, isClient)
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+	ic.datagrams++
+	if err != nil {
+		ic.violations = append(ic.violations, fmt.Sprintf("datagram %v->%v: %v", d.Src, d.Dst, err))
+	}
+}
+
+// err returns an error describing any violations found.
+func (ic *invariantChecker) err() error {
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+	switch len(ic.violations) {
+	case 0:
+		return nil
+	case 1:
+		return fmt.Errorf("invariant violated: %v", ic.violations[0])
+	default:
+		return fmt.Errorf("%v invariant violations; first: %v", len(ic.violations), ic.violations[0])
+	}
+}
+
+// checkDatagram checks the headers of the packets in a datagram.
+func checkDatagram(b []byte, isClient bool) error {
+	if len(b) == 0 {
+		return fmt.Errorf("empty datagram")
+	}
+	size := len(b)
+	for len(b) > 0 {
+		if len(b) < size && allZero(b) {
+			// Padding following a long header packet.
+			return nil
+		}
+		if b[0]&0x80 == 0 {
+			// Short header packet.
+			// https://www.rfc-editor.org/rfc/rfc9000#section-17.3.1-4.2.1
+			if b[0]&0x40 == 0 {
+				return fmt.Errorf("1-RTT packet with fixed bit clear")
+			}
+			// A short header packet extends to the end of the datagram.
+			return nil
+		}
+		n, err := checkLongHeader(b, size, isClient)
+		if err != nil {
+			return err
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
+// checkLongHeader checks a long header packet,
+// and returns its length.
+func checkLongHeader(b []byte, datagramSize int, isClient bool) (int, error) {
+	// https://www.rfc-editor.org/rfc/rfc8999#section-5.1
+	if len(b) < 7 {
+		return 0, fmt.Errorf("truncated long header")
+	}
+	version := binary.BigEndian.Uint32(b[1:])
+	if version == 0 {
+		// Version Negotiation packets are only sent by servers,
+		// in response to a client's packet.
+		if isClient {
+			return 0, fmt.Errorf("client sent Version Negotiation packet")
+		}
+		return len(b), nil
+	}
+	if version != 1 {
+		return 0, fmt.Errorf("sent packet with unknown version 0x%08x", version)
+	}
+	if b[0]&0x40 == 0 {
+		return 0, fmt.Errorf("long header packet with fixed bit clear")
+	}
+	p := b[5:]
+	dcidLen := int(p[0])
+	if dcidLen > maxConnIDLen {
+		return 0, fmt.Errorf("destination connection ID length %v exceeds %v", dcidLen, maxConnIDLen)
+	}
+	p = p[1:]
+	if len(p) < dcidLen+1 {
+		return 0, fmt.Errorf("truncated destination connection ID")
+	}
+	p = p[dcidLen:]
+	scidLen := int(p[0])
+	if scidLen > maxConnIDLen {
+		return 0, fmt.Errorf("source connection ID length %v exceeds %v", scidLen, maxConnIDLen)
+	}
+	p = p[1:]
+	if len(p) < scidLen {
+		return 0, fmt.Errorf("truncated source connection ID")
+	}
+	p = p[scidLen:]
+
+	const (
+		typeInitial   = 0x00
+		typeZeroRTT   = 0x01
+		typeHandshake = 0x02
+		typeRetry     = 0x03
+	)
+	ptype := (b[0] >> 4) & 0x03
+	switch ptype {
+	case typeRetry:
+		if isClient {
+			return 0, fmt.Errorf("client sent Retry packet")
+		}
+		return len(b), nil
+	case typeInitial:
+		// "A client MUST expand the payload of all UDP datagrams carrying
+		// Initial packets to at least the smallest allowed maximum datagram size"
+		// https://www.rfc-editor.org/rfc/rfc9000#section-14.1-1
+		if isClient && datagramSize < paddedInitialDatagramSize {
+			return 0, fmt.Errorf("client Initial in %v-byte datagram, want at least %v", datagramSize, paddedInitialDatagramSize)
+		}
+		tokenLen, n := quicwire.ConsumeVarint(p)
+		if n < 0 || uint64(len(p)-n) < tokenLen {
+			return 0, fmt.Errorf("truncated Initial token")
+		}
+		if !isClient && tokenLen != 0 {
+			return 0, fmt.Errorf("server sent Initial packet with non-empty token")
+		}
+		p = p[n+int(tokenLen):]
+	case typeZeroRTT:
+		if !isClient {
+			return 0, fmt.Errorf("server sent 0-RTT packet")
+		}
+	case typeHandshake:
+	}
+	length, n := quicwire.ConsumeVarint(p)
+	if n < 0 || uint64(len(p)-n) < length {
+		return 0, fmt.Errorf("packet length %v exceeds datagram", length)
+	}
+	return len(b) - len(p) + n + int(length), nil
+}
+
+func allZero(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
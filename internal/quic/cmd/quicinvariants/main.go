@@ -0,0 +1,326 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+// The quicinvariants command runs a local QUIC client and server
+// through a set of scripted scenarios, and checks that the datagrams
+// they exchange satisfy the wire-level invariants of QUIC.
+// It is intended to be run as a release check for the transport.
+//
+// The client and server communicate over an in-memory quictest.Transport
+// pair, which discards datagrams in lossy scenarios.
+// Each scenario checks that data is transferred correctly,
+// and that every datagram sent has valid packet headers (see RFC 8999)
+// and is appropriately sized.
+//
+// Scenarios are limited to what the public API can drive.
+// The endpoints never retire their own connection IDs, so peers sending
+// a storm of NEW_CONNECTION_ID frames with increasing Retire Prior To
+// values are not exercised here; the quic package's tests inject
+// those frames directly (see TestConnIDRetirePriorToStorm).
+//
+// Usage:
+//
+//	quicinvariants [-run regexp] [-loss fraction] [-seed n] [-v]
+//
+// The exit status is non-zero if any scenario fails.
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+
+	"golang.org/x/net/internal/quic"
+	"golang.org/x/net/internal/quic/quictest"
+)
+
+var (
+	runFlag     = flag.String("run", "", "run only scenarios matching this regular expression")
+	lossFlag    = flag.Float64("loss", 0.05, "fraction of datagrams discarded in lossy scenarios")
+	seedFlag    = flag.Int64("seed", 1, "random seed for datagram loss")
+	timeoutFlag = flag.Duration("timeout", 60*time.Second, "time limit for each scenario")
+	verboseFlag = flag.Bool("v", false, "report each scenario as it runs")
+)
+
+// A scenario is a scripted exchange between a client and server.
+type scenario struct {
+	name  string
+	lossy bool // run on a network which discards datagrams
+
+	// config, if non-nil, modifies the client and server configurations.
+	config func(client, server *quic.Config)
+
+	// run runs the client side of the scenario.
+	// The server echoes the data on each stream back to the client.
+	run func(ctx context.Context, c *quic.Conn) error
+}
+
+var scenarios = []scenario{{
+	name: "handshake",
+	run: func(ctx context.Context, c *quic.Conn) error {
+		return echo(ctx, c, []byte("hello"))
+	},
+}, {
+	name: "bulk",
+	run: func(ctx context.Context, c *quic.Conn) error {
+		return echo(ctx, c, testData(4<<20))
+	},
+}, {
+	name:  "bulk-loss",
+	lossy: true,
+	run: func(ctx context.Context, c *quic.Conn) error {
+		return echo(ctx, c, testData(1<<20))
+	},
+}, {
+	// Small flow control windows and stream limits,
+	// so the sender is repeatedly blocked on the receiver.
+	name: "flow-control",
+	config: func(client, server *quic.Config) {
+		for _, c := range []*quic.Config{client, server} {
+			c.MaxStreamReadBufferSize = 1024
+			c.MaxConnReadBufferSize = 4096
+			c.MaxBidiRemoteStreams = 2
+		}
+	},
+	run: func(ctx context.Context, c *quic.Conn) error {
+		return concurrentEcho(ctx, c, 16, testData(64<<10))
+	},
+}, {
+	name:  "flow-control-loss",
+	lossy: true,
+	config: func(client, server *quic.Config) {
+		for _, c := range []*quic.Config{client, server} {
+			c.MaxStreamReadBufferSize = 1024
+			c.MaxConnReadBufferSize = 4096
+			c.MaxBidiRemoteStreams = 2
+		}
+	},
+	run: func(ctx context.Context, c *quic.Conn) error {
+		return concurrentEcho(ctx, c, 8, testData(16<<10))
+	},
+}, {
+	// Endpoints update their 1-RTT keys after sending 1000 packets.
+	// Transfer enough data for the client to do so while datagrams
+	// carrying both old and new keys are being lost.
+	name:  "key-update-loss",
+	lossy: true,
+	run: func(ctx context.Context, c *quic.Conn) error {
+		if err := echo(ctx, c, testData(2<<20)); err != nil {
+			return err
+		}
+		if c.DebugState().KeyUpdates == 0 {
+			return errors.New("no key update completed during transfer")
+		}
+		return nil
+	},
+}, {
+	// A zero-length stream, opened and immediately closed.
+	name: "empty-stream",
+	run: func(ctx context.Context, c *quic.Conn) error {
+		return echo(ctx, c, nil)
+	},
+}}
+
+func main() {
+	flag.Parse()
+	re, err := regexp.Compile(*runFlag)
+	if err != nil {
+		log.Fatalf("-run: %v", err)
+	}
+	failed := false
+	for _, s := range scenarios {
+		if !re.MatchString(s.name) {
+			continue
+		}
+		start := time.Now()
+		err := runScenario(s, *lossFlag, *seedFlag, *timeoutFlag)
+		switch {
+		case err != nil:
+			failed = true
+			fmt.Printf("FAIL %v: %v\n", s.name, err)
+		case *verboseFlag:
+			fmt.Printf("ok   %v (%v)\n", s.name, time.Since(start).Round(time.Millisecond))
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// runScenario runs a scenario with a new client and server.
+func runScenario(s scenario, loss float64, seed int64, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	serverTransport, clientTransport := quictest.NewTransportPair(nil, quictest.ServerAddr, quictest.ClientAddr)
+	if s.lossy {
+		serverTransport.SetImpairment(quictest.Impairment{Loss: loss, Seed: seed})
+		clientTransport.SetImpairment(quictest.Impairment{Loss: loss, Seed: seed + 1})
+	}
+	checker := &invariantChecker{serverAddr: quictest.ServerAddr}
+
+	cert, err := newCertificate()
+	if err != nil {
+		return err
+	}
+	serverConfig := &quic.Config{
+		TLSConfig: &tls.Config{
+			MinVersion:   tls.VersionTLS13,
+			Certificates: []tls.Certificate{cert},
+		},
+		CaptureDatagram: checker.capture,
+	}
+	clientConfig := &quic.Config{
+		TLSConfig: &tls.Config{
+			MinVersion:         tls.VersionTLS13,
+			InsecureSkipVerify: true,
+		},
+		CaptureDatagram: checker.capture,
+	}
+	if s.config != nil {
+		s.config(clientConfig, serverConfig)
+	}
+	server, err := quic.ListenPacketConn(serverTransport, serverConfig)
+	if err != nil {
+		return err
+	}
+	defer server.Close(canceledContext())
+	client, err := quic.ListenPacketConn(clientTransport, clientConfig)
+	if err != nil {
+		return err
+	}
+	defer client.Close(canceledContext())
+	go serveEcho(ctx, server)
+
+	c, err := client.Dial(ctx, "udp", quictest.ServerAddr.String())
+	if err != nil {
+		return fmt.Errorf("dial: %w", err)
+	}
+	defer c.Abort(nil)
+	if err := s.run(ctx, c); err != nil {
+		return err
+	}
+	return checker.err()
+}
+
+// serveEcho accepts connections, and echoes the data received on each stream.
+func serveEcho(ctx context.Context, l *quic.Listener) {
+	for {
+		c, err := l.Accept(ctx)
+		if err != nil {
+			return
+		}
+		go func() {
+			for {
+				s, err := c.AcceptStream(ctx)
+				if err != nil {
+					return
+				}
+				go func() {
+					defer s.Close()
+					io.Copy(s, s)
+				}()
+			}
+		}()
+	}
+}
+
+// echo sends data on a new stream, and checks that the same data is received.
+func echo(ctx context.Context, c *quic.Conn, data []byte) error {
+	s, err := c.NewStream(ctx)
+	if err != nil {
+		return fmt.Errorf("NewStream: %w", err)
+	}
+	defer s.Close()
+	writeErr := make(chan error, 1)
+	go func() {
+		_, err := s.WriteContext(ctx, data)
+		s.CloseWrite()
+		writeErr <- err
+	}()
+	got, err := io.ReadAll(s)
+	if err != nil {
+		return fmt.Errorf("read: %w", err)
+	}
+	if err := <-writeErr; err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+	if !bytes.Equal(got, data) {
+		return fmt.Errorf("echoed data differs: got %v bytes (sha256 %x), want %v bytes (sha256 %x)",
+			len(got), sha256.Sum256(got), len(data), sha256.Sum256(data))
+	}
+	return nil
+}
+
+// concurrentEcho runs n echo exchanges at once.
+func concurrentEcho(ctx context.Context, c *quic.Conn, n int, data []byte) error {
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = echo(ctx, c, data)
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// testData returns n bytes of data.
+func testData(n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(i * 7 / 3)
+	}
+	return b
+}
+
+func canceledContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return ctx
+}
+
+// newCertificate returns a self-signed certificate for the server.
+func newCertificate() (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "quicinvariants"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"localhost"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+	}, nil
+}
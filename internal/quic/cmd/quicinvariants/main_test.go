@@ -0,0 +1,88 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScenarios(t *testing.T) {
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			if err := runScenario(s, 0.05, 1, 30*time.Second); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestCheckDatagram(t *testing.T) {
+	initial := func(size int) []byte {
+		b := []byte{
+			0xc0,                   // long header, fixed bit, Initial
+			0x00, 0x00, 0x00, 0x01, // version 1
+			0x01, 0xaa, // destination connection ID
+			0x01, 0xbb, // source connection ID
+			0x00, // token length
+		}
+		plen := size - len(b) - 2
+		b = append(b, 0x40|byte(plen>>8), byte(plen))
+		return append(b, make([]byte, plen)...)
+	}
+	for _, test := range []struct {
+		name     string
+		b        []byte
+		isClient bool
+		wantErr  bool
+	}{{
+		name:     "client initial",
+		b:        initial(1200),
+		isClient: true,
+	}, {
+		name:     "client initial with trailing padding",
+		b:        append(initial(1000), make([]byte, 200)...),
+		isClient: true,
+	}, {
+		name:     "small client initial",
+		b:        initial(1000),
+		isClient: true,
+		wantErr:  true,
+	}, {
+		name: "small server initial",
+		b:    initial(100),
+	}, {
+		name: "short header",
+		b:    []byte{0x40, 1, 2, 3},
+	}, {
+		name:    "short header without fixed bit",
+		b:       []byte{0x00, 1, 2, 3},
+		wantErr: true,
+	}, {
+		name:    "unknown version",
+		b:       []byte{0xc0, 0x1a, 0x2a, 0x3a, 0x4a, 0x00, 0x00},
+		wantErr: true,
+	}, {
+		name:    "connection ID too long",
+		b:       append([]byte{0xe0, 0x00, 0x00, 0x00, 0x01, 21}, make([]byte, 30)...),
+		wantErr: true,
+	}, {
+		name:    "length exceeds datagram",
+		b:       []byte{0xe0, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x10},
+		wantErr: true,
+	}, {
+		name:     "client retry",
+		b:        []byte{0xf0, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00},
+		isClient: true,
+		wantErr:  true,
+	}} {
+		err := checkDatagram(test.b, test.isClient)
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Errorf("%v: checkDatagram = %v, want error: %v", test.name, err, test.wantErr)
+		}
+	}
+}
@@ -8,6 +8,9 @@ package quic
 
 import (
 	"crypto/tls"
+	"math"
+	"net/netip"
+	"time"
 )
 
 // A Config structure configures a QUIC endpoint.
@@ -16,38 +19,332 @@ import (
 type Config struct {
 	// TLSConfig is the endpoint's TLS configuration.
 	// It must be non-nil and include at least one certificate or else set GetCertificate.
+	// A server may request or require certificates from clients by setting ClientAuth.
+	// The client's verified certificates are available from Conn.ConnectionState.
 	TLSConfig *tls.Config
 
+	// GetConfigForConn, if not nil, is called when a Listener receives
+	// the ClientHello for an inbound connection.
+	// It may return a Config to use for the connection in place of
+	// the Listener's Config, or nil to use the Listener's Config.
+	// If it returns an error, the handshake fails.
+	//
+	// The returned Config's TLSConfig, if not nil, replaces the TLS configuration
+	// as in tls.Config.GetConfigForClient.
+	// Its stream and flow control limits, idle timeout, keep-alive period,
+	// MaxConnectionMemory, MaxDatagramFrameSize, ErrorCodes, StreamLimitUpdate,
+	// and NewStreamScheduler also apply to the connection.
+	// All other fields, including those controlling whether the connection
+	// is accepted, retry and reset tokens, versions, loss recovery,
+	// Clock, and HalfRTTData, are taken from the Listener's Config.
+	//
+	// GetConfigForConn is not called for outbound connections.
+	GetConfigForConn func(hello *tls.ClientHelloInfo, remoteAddr netip.AddrPort) (*Config, error)
+
+	// RequireServerName, if set, causes a server to reject connections
+	// whose ClientHello does not include a server name (SNI).
+	RequireServerName bool
+
+	// VerifyServerName, if not nil, is called by a server with the server name
+	// in the ClientHello of each inbound connection, or "" if there is none.
+	// It is called before GetConfigForConn and before a certificate is selected,
+	// so a server hosting many names may cheaply reject unknown ones.
+	// If it returns an error, the connection is closed
+	// with an unrecognized_name TLS alert.
+	VerifyServerName func(serverName string) error
+
+	// Versions is the set of QUIC versions the endpoint supports,
+	// in order of preference.
+	// Connections created by Listener.Dial use the first version.
+	// If the server responds with a Version Negotiation packet,
+	// the connection switches to the most preferred version the server supports,
+	// or fails with a *VersionNegotiationError if there is none.
+	// Both endpoints send the version_information transport parameter (RFC 9368),
+	// and a client which changed versions closes the connection
+	// if the server's version information shows the change was not genuine.
+	// A Listener accepts connections using any version in the set,
+	// and responds to other versions with a Version Negotiation packet
+	// listing the set.
+	// If empty, only QUIC version 1 is supported.
+	Versions []Version
+
 	// MaxBidiRemoteStreams limits the number of simultaneous bidirectional streams
 	// a peer may open.
-	// If zero, the default value of 100 is used.
+	// If zero, the default value of 100 is used (10 if LowMemory is set).
 	// If negative, the limit is zero.
 	MaxBidiRemoteStreams int64
 
 	// MaxUniRemoteStreams limits the number of simultaneous unidirectional streams
 	// a peer may open.
-	// If zero, the default value of 100 is used.
+	// If zero, the default value of 100 is used (10 if LowMemory is set).
 	// If negative, the limit is zero.
 	MaxUniRemoteStreams int64
 
+	// StreamLimitUpdate controls when the limits on the number of streams
+	// a peer may open (MaxBidiRemoteStreams and MaxUniRemoteStreams)
+	// are raised as the peer's streams are closed.
+	StreamLimitUpdate StreamLimitUpdatePolicy
+
+	// MaxIdleTimeout is the maximum time after which an idle connection will be closed.
+	// If zero, the default of 30 seconds is used.
+	// If negative, idle connections are never closed.
+	//
+	// The idle timeout for a connection is the minimum of the maximum idle timeouts
+	// of the endpoints. A connection closed due to the idle timeout reports
+	// an IdleTimeoutError.
+	MaxIdleTimeout time.Duration
+
+	// KeepAlivePeriod is the time after which a packet will be sent to keep
+	// an idle connection alive.
+	// If zero, keep alive packets are not sent.
+	// If greater than zero, the keep alive period is the smaller of KeepAlivePeriod and
+	// half the connection idle timeout.
+	KeepAlivePeriod time.Duration
+
+	// HandshakeTimeout is the maximum time a connection created by the Listener
+	// in response to a peer's Initial packet may take to complete the handshake.
+	// A connection which does not complete the handshake in time is discarded.
+	// If zero, the default of 10 seconds is used.
+	// If negative, there is no handshake timeout, and a stalled handshake is
+	// discarded only when the idle timeout expires.
+	HandshakeTimeout time.Duration
+
+	// DialHandshakeTimeout is the maximum time a connection created by
+	// Listener.Dial may take to complete the handshake.
+	// Dial also returns when the context passed to it is done.
+	// If zero, HandshakeTimeout is used.
+	// If negative, there is no handshake timeout.
+	DialHandshakeTimeout time.Duration
+
+	// Resolver is used by Listener.Dial and Listener.DialAddrs
+	// to look up the addresses of hosts.
+	// If nil, net.DefaultResolver is used.
+	Resolver Resolver
+
+	// ConnectionAttemptDelay is the time Listener.DialAddrs waits
+	// for a connection attempt to complete before starting an attempt
+	// to the next address (the Connection Attempt Delay of RFC 8305).
+	// If zero or negative, the default of 250ms is used.
+	ConnectionAttemptDelay time.Duration
+
+	// MaxAcceptQueue is the maximum number of inbound connections a Listener
+	// will hold ready to be returned by Accept.
+	// Connections which have not yet completed the handshake
+	// do not count towards the limit; see MaxHandshakes to limit those.
+	// Handshakes in progress when the limit is reached may still complete,
+	// so the number of connections waiting for Accept may exceed MaxAcceptQueue
+	// by at most the number of handshakes in progress.
+	// When the limit is reached, the Listener refuses new connections
+	// by responding to the peer's Initial packet with a CONNECTION_CLOSE
+	// carrying the CONNECTION_REFUSED error.
+	// If zero, the default of 1000 is used.
+	// If negative, there is no limit.
+	MaxAcceptQueue int
+
+	// HalfRTTData, if set, lets a server send application data
+	// before the handshake completes (0.5-RTT data).
+	// Inbound connections are returned by Listener.Accept as soon as
+	// the server has sent its handshake messages, and data written to
+	// streams is sent immediately, without waiting a round trip for the
+	// client to complete the handshake.
+	//
+	// 0.5-RTT data is sent before the client has been authenticated,
+	// so it should not depend on the client's identity.
+	// Until the client's address is validated, the server may send
+	// at most three times the amount of data it has received.
+	//
+	// HalfRTTData has no effect on a Listener with ProtocolListeners,
+	// since the connection's protocol is not known until the handshake completes.
+	HalfRTTData bool
+
+	// ConnWorkers, if positive, is the number of goroutines
+	// a Listener uses to run its connections.
+	//
+	// By default, each connection runs its own goroutine.
+	// When ConnWorkers is set, each connection is instead assigned to one of
+	// a fixed pool of workers, which handle events for their connections
+	// one at a time. This reduces memory use for Listeners holding many
+	// mostly idle connections, at the cost of added latency when many
+	// connections assigned to the same worker are busy.
+	ConnWorkers int
+
+	// Clock is the source of time used for all timers and RTT measurements.
+	// If nil, the system clock is used.
+	Clock Clock
+
+	// ClockJumpThreshold is the amount by which the clock may advance
+	// past the time a connection expects to next wake before the connection
+	// considers the clock to have jumped: for example, when the host
+	// resumes from suspension or a virtual machine is unpaused.
+	// A connection does not count the time skipped by a jump towards
+	// its idle timeout, loss detection, ACK delay, or RTT measurements,
+	// avoiding spurious timeouts and bursts of retransmissions on resume.
+	// If zero, the default of 5 seconds is used.
+	// If negative, clock jumps are not detected.
+	ClockJumpThreshold time.Duration
+
 	// MaxStreamReadBufferSize is the maximum amount of data sent by the peer that a
 	// stream will buffer for reading.
-	// If zero, the default value of 1MiB is used.
+	// This is the largest flow control window a stream will provide to the peer.
+	// If zero, the default value of 1MiB is used (16KiB if LowMemory is set).
 	// If negative, the limit is zero.
 	MaxStreamReadBufferSize int64
 
+	// InitialStreamReadWindowBidiLocal, InitialStreamReadWindowBidiRemote,
+	// and InitialStreamReadWindowUni are the initial flow control windows
+	// for locally-initiated bidirectional streams, peer-initiated bidirectional
+	// streams, and unidirectional streams respectively.
+	// The window for a stream grows to MaxStreamReadBufferSize
+	// once the application begins reading from it,
+	// or gradually when AutoTuneReceiveWindows is set.
+	// If zero or negative, or larger than MaxStreamReadBufferSize,
+	// the initial window is MaxStreamReadBufferSize
+	// (or 64KiB when AutoTuneReceiveWindows is set).
+	InitialStreamReadWindowBidiLocal  int64
+	InitialStreamReadWindowBidiRemote int64
+	InitialStreamReadWindowUni        int64
+
 	// MaxStreamWriteBufferSize is the maximum amount of data a stream will buffer for
 	// sending to the peer.
-	// If zero, the default value of 1MiB is used.
+	// If zero, the default value of 1MiB is used (16KiB if LowMemory is set).
 	// If negative, the limit is zero.
 	MaxStreamWriteBufferSize int64
 
 	// MaxConnReadBufferSize is the maximum amount of data sent by the peer that a
 	// connection will buffer for reading, across all streams.
-	// If zero, the default value of 1MiB is used.
+	// This is the largest flow control window a connection will provide to the peer.
+	// If zero, the default value of 1MiB is used (64KiB if LowMemory is set).
 	// If negative, the limit is zero.
 	MaxConnReadBufferSize int64
 
+	// InitialConnReadWindow is the initial connection flow control window.
+	// The window grows to MaxConnReadBufferSize once the application
+	// begins reading data, or gradually when AutoTuneReceiveWindows is set.
+	// If zero or negative, or larger than MaxConnReadBufferSize,
+	// the initial window is MaxConnReadBufferSize
+	// (or 96KiB when AutoTuneReceiveWindows is set).
+	InitialConnReadWindow int64
+
+	// AutoTuneReceiveWindows enables dynamic sizing of stream and connection
+	// flow control windows.
+	//
+	// When set, windows start small and double in size when the application
+	// consumes data quickly enough that the window, rather than the network
+	// or the application, limits the rate at which the peer can send.
+	// Stream windows grow to at most MaxStreamReadBufferSize,
+	// and the connection window to at most MaxConnReadBufferSize,
+	// which bounds the memory used for received data on each connection.
+	// The connection window is kept at least 1.5 times the size of
+	// any stream window.
+	AutoTuneReceiveWindows bool
+
+	// MaxConnectionMemory is the maximum amount of memory a connection will use
+	// to buffer data received from the peer: stream data not yet read by the
+	// application, including out-of-order data awaiting reassembly,
+	// and CRYPTO data not yet processed.
+	//
+	// A portion of the limit is reserved for CRYPTO data: one sixteenth,
+	// but no less than 4096 bytes (the minimum required by RFC 9000)
+	// and no more than half the limit. The connection flow control window
+	// is limited to the rest, so a peer which respects flow control never
+	// causes the limit to be exceeded. A peer which sends more CRYPTO data
+	// than the reserved portion is closed with a CRYPTO_BUFFER_EXCEEDED error.
+	// If zero or negative, there is no limit beyond MaxConnReadBufferSize.
+	MaxConnectionMemory int64
+
+	// PacketThreshold is the number of packets which must be acknowledged
+	// after a sent packet before that packet is declared lost
+	// (kPacketThreshold in RFC 9002).
+	// If zero or negative, the default of 3 is used.
+	// Larger values reduce spurious retransmissions on paths which
+	// reorder packets, at the cost of slower loss detection.
+	PacketThreshold int
+
+	// TimeThreshold is the multiple of the round-trip time after which
+	// a sent packet is declared lost when a later packet has been acknowledged
+	// (kTimeThreshold in RFC 9002).
+	// If zero or negative, the default of 9/8 is used.
+	// Values less than 1 are treated as 1.
+	TimeThreshold float64
+
+	// MaxPTOBackoff limits the number of times the probe timeout (PTO)
+	// period is doubled when consecutive probe timeouts expire without
+	// an acknowledgement from the peer.
+	// If zero, the period doubles without limit.
+	// If negative, the period is not doubled.
+	// Limiting the backoff speeds recovery on links with long outages,
+	// such as cellular or satellite links.
+	MaxPTOBackoff int
+
+	// InitialRTT is the round-trip time estimate used before
+	// a connection has measured the round-trip time to its peer.
+	// If zero or negative, the default of 333 milliseconds is used.
+	// Setting InitialRTT close to the actual round-trip time on
+	// high-latency paths avoids spurious retransmissions during the handshake.
+	InitialRTT time.Duration
+
+	// AckFrequency enables the QUIC Acknowledgement Frequency extension
+	// (draft-ietf-quic-ack-frequency).
+	//
+	// When set, the endpoint advertises support for the extension and
+	// follows requests from the peer to send acknowledgements less often.
+	// If the peer also supports the extension, the endpoint asks the peer
+	// to acknowledge packets as configured by AckElicitingThreshold and
+	// RequestedMaxAckDelay.
+	AckFrequency bool
+
+	// AckElicitingThreshold is the number of ack-eliciting packets the peer
+	// may receive before it must send an acknowledgement,
+	// when the ACK frequency extension is in use.
+	// Larger values reduce the number of acknowledgements on links
+	// with limited capacity in the direction of the peer.
+	// If zero or negative, the RFC 9000 default of 1 is used,
+	// causing the peer to acknowledge every second ack-eliciting packet.
+	AckElicitingThreshold int
+
+	// RequestedMaxAckDelay is the maximum time the peer may delay sending
+	// an acknowledgement, when the ACK frequency extension is in use.
+	// If zero or negative, the peer's max_ack_delay transport parameter is used.
+	// Values less than the peer's minimum ack delay are raised to it.
+	RequestedMaxAckDelay time.Duration
+
+	// DatagramBufferSize is the largest UDP payload the endpoint accepts.
+	// It is sent to the peer in the max_udp_payload_size transport parameter,
+	// and is the size in bytes of the buffers used to receive datagrams.
+	// Larger values allow receiving datagrams on paths supporting jumbo frames.
+	// If zero, the default of 1472 is used, the largest UDP payload
+	// in a 1500-byte IPv4 packet.
+	// Values are limited to the range 1200 to 65527.
+	//
+	// A peer may send datagrams larger than max_udp_payload_size
+	// before it receives our transport parameters, such as a client's
+	// first Initial packets, so receive buffers are never smaller than 1472 bytes.
+	// Values below 1472 limit the size of datagrams the peer sends
+	// after the handshake, but do not reduce memory use.
+	DatagramBufferSize int
+
+	// MaxIdleDatagramBuffers is the maximum number of unused datagram buffers
+	// of each size a Listener retains for reuse.
+	// If zero, the default of 256 is used (16 if LowMemory is set).
+	// If negative, buffers are not retained.
+	MaxIdleDatagramBuffers int
+
+	// LowMemory selects defaults suited to memory-constrained devices,
+	// such as 32-bit embedded systems.
+	//
+	// When LowMemory is set, the limits above use smaller defaults,
+	// and the congestion window is limited to 64KiB, which bounds the
+	// amount of sent packet history a connection retains.
+	// With these defaults, a connection with one active stream buffers
+	// at most 64KiB of received data and 16KiB of unsent data,
+	// and tracks at most 64KiB of data in flight.
+	// A connection whose receive buffers are full occupies less than 128KiB
+	// on both 32- and 64-bit platforms.
+	//
+	// Tracing state is only allocated when NewTracer is set,
+	// so memory-constrained endpoints should leave it nil.
+	LowMemory bool
+
 	// RequireAddressValidation may be set to true to enable address validation
 	// of client connections prior to starting the handshake.
 	//
@@ -56,6 +353,93 @@ type Config struct {
 	// at the cost of increased handshake latency.
 	RequireAddressValidation bool
 
+	// AcceptFilter, if non-nil, is called by a Listener for each client Initial packet
+	// which would create a new connection, before any handshake work is performed.
+	// It returns the action to take with the packet.
+	// AcceptFilter is called from the Listener's receive loop,
+	// and should return quickly.
+	//
+	// When RequireAddressValidation is set and AcceptFilter returns AcceptConn,
+	// the client's address is still validated before the connection is created.
+	AcceptFilter func(*InitialPacket) AcceptAction
+
+	// HandshakeRateLimit limits the rate at which a single client may start
+	// new connections to a Listener, in handshakes per second.
+	// A client is identified by its IPv4 address or IPv6 /64 prefix.
+	// If zero or negative, there is no limit.
+	HandshakeRateLimit float64
+
+	// HandshakeRateBurst is the number of handshakes a client may start
+	// at once before HandshakeRateLimit applies.
+	// If zero or negative, it is HandshakeRateLimit rounded up.
+	HandshakeRateBurst int
+
+	// HandshakeRateLimitAction is the action taken with an Initial packet
+	// from a client which has exceeded HandshakeRateLimit.
+	// It may be AcceptRetry, AcceptRefuse, or AcceptDrop;
+	// any other value is treated as AcceptDrop.
+	//
+	// A dropped or refused client may try again once its rate allows.
+	// With AcceptRetry, an Initial which returns a valid Retry token
+	// is not checked against the rate, so a client which proves
+	// it owns its address connects without waiting.
+	HandshakeRateLimitAction AcceptAction
+
+	// MaxHandshakes limits the number of inbound connections a Listener
+	// may have in the handshaking state at once, across all clients.
+	// If zero or negative, there is no limit.
+	MaxHandshakes int
+
+	// MaxHandshakesAction is the action taken with an Initial packet
+	// received while the Listener has MaxHandshakes handshakes in progress.
+	// It may be AcceptRetry, AcceptRefuse, or AcceptDrop;
+	// any other value is treated as AcceptDrop.
+	//
+	// A dropped or refused client may try again once existing handshakes
+	// complete. With AcceptRetry, the Listener keeps no state for the
+	// client until it returns the Retry token; its connection is then
+	// created even if the limit is still reached, so the limit applies
+	// only to clients whose addresses have not been validated.
+	MaxHandshakesAction AcceptAction
+
+	// AllowedSources and DeniedSources restrict the addresses
+	// a Listener communicates with.
+	// Datagrams from a denied address are discarded before any processing,
+	// and Listener.Dial returns an error for a denied address.
+	//
+	// An address is denied if it is contained in any prefix in DeniedSources,
+	// or if AllowedSources is non-empty and it is not contained in
+	// any prefix in AllowedSources.
+	// IPv4-mapped IPv6 addresses are treated as IPv4 addresses.
+	AllowedSources []netip.Prefix
+	DeniedSources  []netip.Prefix
+
+	// DatagramFilter, if non-nil, is called by a Listener with each
+	// UDP datagram it receives from an allowed source, before the datagram
+	// is parsed or any cryptographic work is performed.
+	// It returns a verdict on the datagram.
+	// This permits integrating external traffic scrubbing decisions,
+	// such as flowspec rules or address allowlists, with the Listener.
+	//
+	// DatagramFilter is called from the Listener's receive loop,
+	// and must return quickly.
+	// The datagram b is valid only during the call.
+	DatagramFilter func(addr netip.AddrPort, b []byte) DatagramVerdict
+
+	// MaxDatagramFrameSize is the maximum size of a DATAGRAM frame
+	// the endpoint is willing to receive, including the frame header.
+	// https://www.rfc-editor.org/rfc/rfc9221
+	// If zero or negative, the endpoint does not support DATAGRAM frames,
+	// and Conn.SendDatagram and Conn.ReceiveDatagram return errors.
+	MaxDatagramFrameSize int64
+
+	// DisableSpinBit disables the latency spin bit, which permits
+	// on-path observers to measure a connection's round-trip time.
+	// When false, the spin bit is enabled for a randomly chosen
+	// 15 of every 16 connections, as RFC 9000 requires.
+	// https://www.rfc-editor.org/rfc/rfc9000#section-17.4
+	DisableSpinBit bool
+
 	// StatelessResetKey is used to provide stateless reset of connections.
 	// A restart may leave an endpoint without access to the state of
 	// existing connections. Stateless reset permits an endpoint to respond
@@ -65,6 +449,8 @@ type Config struct {
 	// The contents should remain stable across restarts,
 	// to permit an endpoint to send a reset for
 	// connections created before a restart.
+	// Reset tokens are derived from this key and the connection ID,
+	// so no record of the connection IDs issued before a restart is needed.
 	//
 	// The contents of the StatelessResetKey should not be exposed.
 	// An attacker can use knowledge of this field's value to
@@ -72,8 +458,201 @@ type Config struct {
 	//
 	// If this field is left as zero, stateless reset is disabled.
 	StatelessResetKey [32]byte
+
+	// RetryKey is used to protect the address validation tokens
+	// sent in Retry packets (see RequireAddressValidation).
+	// Listeners with the same RetryKey accept each other's tokens.
+	//
+	// The contents of the RetryKey should not be exposed.
+	// An attacker can use knowledge of this field's value
+	// to bypass address validation.
+	//
+	// If this field is left as zero, each Listener uses a random key.
+	RetryKey [32]byte
+
+	// V6Only restricts a Listener bound to the IPv6 unspecified address
+	// to IPv6 traffic, by setting the IPV6_V6ONLY socket option.
+	// By default, a Listener created with network "udp" and address "[::]:port"
+	// is dual-stack, and receives IPv4 traffic as IPv4-mapped IPv6 addresses.
+	// Listeners created with network "udp6" are always IPv6-only.
+	//
+	// V6Only is used by Listen, ListenReusePort, and ListenAddrs.
+	// It is not supported on all platforms.
+	V6Only bool
+
+	// BindToDevice, if non-empty, is the name of a network interface
+	// (such as "eth0") to bind the Listener's socket to,
+	// using the SO_BINDTODEVICE socket option.
+	// The Listener then only sends and receives datagrams on that interface.
+	// Binding to an interface may require elevated privileges.
+	//
+	// BindToDevice is used by Listen, ListenReusePort, and ListenAddrs.
+	// It is only supported on Linux.
+	BindToDevice string
+
+	// ConnectedSockets causes each connection created by Dial or DialAddrs
+	// to send and receive datagrams on its own UDP socket, connected to
+	// the peer's address, rather than on the Listener's socket.
+	// A connected socket reports ICMP errors, such as port unreachable,
+	// which allow a connection attempt to an unresponsive server to fail quickly.
+	// It also lets the kernel deliver each connection's datagrams directly
+	// to it, rather than through the Listener.
+	//
+	// Connected sockets are created with the BindToDevice option,
+	// and are bound to the Listener's address when it is not unspecified.
+	// ConnectedSockets has no effect on a Listener which does not use
+	// an operating system UDP socket, such as one created by ListenPacketConn
+	// with a DatagramTransport.
+	ConnectedSockets bool
+
+	// NewTracer, if non-nil, is called when a connection is created
+	// to create a ConnTracer for the connection.
+	// It may return nil to disable tracing for the connection.
+	//
+	// The Conn must not be used until NewTracer returns.
+	NewTracer func(*Conn) ConnTracer
+
+	// NewStreamScheduler, if non-nil, is called when a connection is created
+	// to create a StreamScheduler for the connection.
+	// The scheduler decides which streams send data in each outgoing packet.
+	// If NewStreamScheduler is nil or returns nil, the connection sends data
+	// from streams in priority order (see Stream.SetPriority).
+	//
+	// The Conn must not be used until NewStreamScheduler returns.
+	NewStreamScheduler func(*Conn) StreamScheduler
+
+	// InsecureLoadTesting enables a mode intended for load testing the
+	// transport, in which the cost of the TLS handshake is minimized.
+	//
+	// When set, clients do not verify the server's certificate,
+	// and connections created by the same Listener share a TLS session cache.
+	// Servers send a session ticket to each client after the handshake completes,
+	// so all connections to a server after the first resume a previous session
+	// and skip certificate signing and verification.
+	// The ECDHE key exchange is still performed.
+	//
+	// This mode is insecure. It must never be used outside of testing.
+	InsecureLoadTesting bool
+
+	// CaptureDatagram, if non-nil, is called with each UDP datagram
+	// sent or received by the endpoint.
+	// Datagrams are provided exactly as they appear on the network,
+	// so packet contents are encrypted.
+	// It may be used to record traffic (for example, in a pcap file)
+	// when capturing packets at the network layer is not possible.
+	//
+	// CaptureDatagram may be called concurrently from multiple goroutines,
+	// and must not block.
+	// The CapturedDatagram and its Data are valid only during the call.
+	CaptureDatagram func(*CapturedDatagram)
+
+	// NonQUICDatagram, if non-nil, is called with each UDP datagram
+	// received by the endpoint which does not contain a QUIC packet:
+	// The QUIC fixed bit is not set, or the packet header is invalid.
+	// This permits a UDP port to be shared with other protocols,
+	// such as STUN (RFC 9443).
+	// Replies may be sent with Listener.WriteTo.
+	// When NonQUICDatagram is nil, the fixed bit is not checked,
+	// and datagrams with invalid headers are discarded.
+	//
+	// NonQUICDatagram is called on the Listener's receive goroutine,
+	// and must not block.
+	// The datagram b is valid only during the call.
+	NonQUICDatagram func(l *Listener, b []byte, addr netip.AddrPort)
+
+	// FrameThresholds sets per-connection thresholds on the number of frames
+	// of each type received from the peer, for detecting abusive peers
+	// (for example, floods of PING or PATH_CHALLENGE frames).
+	// Fields left as zero have no threshold.
+	FrameThresholds FrameCounts
+
+	// OnFrameThreshold, if non-nil, is called the first time a connection
+	// receives more frames of some type than permitted by FrameThresholds.
+	// frameType is the frame type's name, such as "PATH_CHALLENGE".
+	//
+	// OnFrameThreshold is called on the connection's event loop goroutine:
+	// it must not block, and must not call methods on the Conn.
+	// If it returns a non-nil error, the connection is closed as if by Conn.Abort.
+	OnFrameThreshold func(c *Conn, frameType string, count uint64) error
+
+	// ErrorCodes names the application protocol error codes used on connections,
+	// for rendering in error strings and traces.
+	// If nil, the space registered with RegisterErrorCodeSpace
+	// for the negotiated ALPN protocol is used, if any.
+	ErrorCodes *ErrorCodeSpace
 }
 
+// A StreamLimitUpdatePolicy controls when an endpoint sends MAX_STREAMS frames
+// to permit its peer to open new streams in place of closed ones.
+type StreamLimitUpdatePolicy int
+
+const (
+	// StreamLimitUpdateBatched raises the peer's stream limit when the peer
+	// is close to reaching it, or when the increase would double the number
+	// of streams the peer may open.
+	// This reduces the number of MAX_STREAMS frames sent.
+	StreamLimitUpdateBatched StreamLimitUpdatePolicy = iota
+
+	// StreamLimitUpdateImmediate raises the peer's stream limit
+	// as soon as one of its streams is closed.
+	StreamLimitUpdateImmediate
+)
+
+// An InitialPacket describes a client Initial packet received by a Listener
+// for which no connection exists.
+// See Config.AcceptFilter.
+//
+// The slices in an InitialPacket are only valid for the duration
+// of the call to AcceptFilter.
+type InitialPacket struct {
+	RemoteAddr netip.AddrPort
+	Version    Version
+	DstConnID  []byte
+	SrcConnID  []byte
+}
+
+// An AcceptAction is the action a Listener takes with a client Initial packet.
+// See Config.AcceptFilter.
+type AcceptAction int
+
+const (
+	// AcceptConn creates a new connection.
+	AcceptConn AcceptAction = iota
+
+	// AcceptRetry requires the client to validate its address before
+	// a connection is created, as if RequireAddressValidation were set.
+	// Initial packets without a Retry token are answered with a Retry packet.
+	// The client's next Initial packet, carrying the token, is passed to
+	// AcceptFilter again.
+	AcceptRetry
+
+	// AcceptDrop discards the packet without a response.
+	AcceptDrop
+
+	// AcceptRefuse refuses the connection by responding with a
+	// CONNECTION_CLOSE frame carrying the CONNECTION_REFUSED error.
+	AcceptRefuse
+)
+
+// A DatagramVerdict is the action a Listener takes with a received datagram.
+// See Config.DatagramFilter.
+type DatagramVerdict int
+
+const (
+	// DatagramAccept processes the datagram normally.
+	DatagramAccept DatagramVerdict = iota
+
+	// DatagramDrop discards the datagram.
+	DatagramDrop
+
+	// DatagramMark processes the datagram, but marks it as suspect:
+	// If it contains a client Initial packet which would create a new connection,
+	// the client must validate its address before the connection is created,
+	// as if RequireAddressValidation were set.
+	// Datagrams for existing connections are processed normally.
+	DatagramMark
+)
+
 func configDefault(v, def, limit int64) int64 {
 	switch {
 	case v == 0:
@@ -85,22 +664,297 @@ func configDefault(v, def, limit int64) int64 {
 	}
 }
 
+// lowMemoryDefault returns def, or lowMem if the LowMemory profile is enabled.
+func (c *Config) lowMemoryDefault(def, lowMem int64) int64 {
+	if c.LowMemory {
+		return lowMem
+	}
+	return def
+}
+
+// datagramBufferSize returns the max_udp_payload_size we send to the peer.
+func (c *Config) datagramBufferSize() int {
+	if c.DatagramBufferSize == 0 {
+		return maxUDPPayloadSize
+	}
+	return min(max(c.DatagramBufferSize, paddedInitialDatagramSize), defaultParamMaxUDPPayloadSize)
+}
+
+// recvBufferSize returns the size of the buffers used to receive datagrams.
+// It is large enough to receive datagrams of the size commonly sent
+// by peers which don't know our max_udp_payload_size yet.
+func (c *Config) recvBufferSize() int {
+	return max(c.datagramBufferSize(), maxUDPPayloadSize)
+}
+
+func (c *Config) maxIdleDatagramBuffers() int {
+	return int(configDefault(int64(c.MaxIdleDatagramBuffers), c.lowMemoryDefault(256, 16), math.MaxInt))
+}
+
 func (c *Config) maxBidiRemoteStreams() int64 {
-	return configDefault(c.MaxBidiRemoteStreams, 100, maxStreamsLimit)
+	return configDefault(c.MaxBidiRemoteStreams, c.lowMemoryDefault(100, 10), maxStreamsLimit)
 }
 
 func (c *Config) maxUniRemoteStreams() int64 {
-	return configDefault(c.MaxUniRemoteStreams, 100, maxStreamsLimit)
+	return configDefault(c.MaxUniRemoteStreams, c.lowMemoryDefault(100, 10), maxStreamsLimit)
 }
 
 func (c *Config) maxStreamReadBufferSize() int64 {
-	return configDefault(c.MaxStreamReadBufferSize, 1<<20, maxVarint)
+	return configDefault(c.MaxStreamReadBufferSize, c.lowMemoryDefault(1<<20, 16<<10), maxVarint)
 }
 
 func (c *Config) maxStreamWriteBufferSize() int64 {
-	return configDefault(c.MaxStreamWriteBufferSize, 1<<20, maxVarint)
+	return configDefault(c.MaxStreamWriteBufferSize, c.lowMemoryDefault(1<<20, 16<<10), maxVarint)
 }
 
 func (c *Config) maxConnReadBufferSize() int64 {
-	return configDefault(c.MaxConnReadBufferSize, 1<<20, maxVarint)
+	v := configDefault(c.MaxConnReadBufferSize, c.lowMemoryDefault(1<<20, 64<<10), maxVarint)
+	if c.MaxConnectionMemory > 0 {
+		v = min(v, c.MaxConnectionMemory-c.maxConnCryptoBufferSize())
+	}
+	return v
+}
+
+// connDatagramBacklog returns the maximum number of received datagrams
+// queued for a conn. It is the number of minimum-size datagrams needed
+// to fill the conn's receive buffer, so a peer sending within its flow
+// control limits is not dropped while the conn catches up.
+func (c *Config) connDatagramBacklog() int {
+	n := c.maxConnReadBufferSize() / paddedInitialDatagramSize
+	return int(min(max(n, minConnDatagramBacklog), maxConnDatagramBacklog))
+}
+
+// maxConnCryptoBufferSize returns the portion of MaxConnectionMemory
+// reserved for buffering CRYPTO data, or 0 if there is no limit.
+func (c *Config) maxConnCryptoBufferSize() int64 {
+	if c.MaxConnectionMemory <= 0 {
+		return 0
+	}
+	return min(max(c.MaxConnectionMemory/16, minCryptoBufferSize), c.MaxConnectionMemory/2)
+}
+
+// initialWindow returns the initial flow control window for a buffer
+// with the given maximum size.
+// autoTune is the default window when AutoTuneReceiveWindows is set.
+func (c *Config) initialWindow(v, maxBuf, autoTune int64) int64 {
+	if v <= 0 {
+		if !c.AutoTuneReceiveWindows {
+			return maxBuf
+		}
+		v = autoTune
+	}
+	return min(v, maxBuf)
+}
+
+func (c *Config) initialStreamReadWindowBidiLocal() int64 {
+	return c.initialWindow(c.InitialStreamReadWindowBidiLocal, c.maxStreamReadBufferSize(), 64<<10)
+}
+
+func (c *Config) initialStreamReadWindowBidiRemote() int64 {
+	return c.initialWindow(c.InitialStreamReadWindowBidiRemote, c.maxStreamReadBufferSize(), 64<<10)
+}
+
+func (c *Config) initialStreamReadWindowUni() int64 {
+	return c.initialWindow(c.InitialStreamReadWindowUni, c.maxStreamReadBufferSize(), 64<<10)
+}
+
+func (c *Config) initialConnReadWindow() int64 {
+	return c.initialWindow(c.InitialConnReadWindow, c.maxConnReadBufferSize(), 96<<10)
+}
+
+// newWindowTuner returns a windowTuner for a window with the given
+// initial and maximum sizes.
+// When auto-tuning is disabled, the window is always the maximum size.
+func (c *Config) newWindowTuner(initial, maxSize int64) windowTuner {
+	var t windowTuner
+	if c.AutoTuneReceiveWindows {
+		t.init(initial, maxSize)
+	} else {
+		t.init(maxSize, maxSize)
+	}
+	return t
+}
+
+func (c *Config) maxIdleTimeout() time.Duration {
+	switch {
+	case c.MaxIdleTimeout == 0:
+		return defaultMaxIdleTimeout
+	case c.MaxIdleTimeout < 0:
+		return 0
+	default:
+		return c.MaxIdleTimeout
+	}
+}
+
+// handshakeTimeout returns the handshake timeout for a connection,
+// or 0 for no timeout.
+func (c *Config) handshakeTimeout(side connSide) time.Duration {
+	v := c.HandshakeTimeout
+	if side == clientSide && c.DialHandshakeTimeout != 0 {
+		v = c.DialHandshakeTimeout
+	}
+	switch {
+	case v == 0:
+		return defaultHandshakeTimeout
+	case v < 0:
+		return 0
+	default:
+		return v
+	}
+}
+
+func (c *Config) connectionAttemptDelay() time.Duration {
+	if c.ConnectionAttemptDelay <= 0 {
+		return defaultConnectionAttemptDelay
+	}
+	return c.ConnectionAttemptDelay
+}
+
+func (c *Config) clockJumpThreshold() time.Duration {
+	switch {
+	case c.ClockJumpThreshold == 0:
+		return defaultClockJumpThreshold
+	case c.ClockJumpThreshold < 0:
+		return 0
+	default:
+		return c.ClockJumpThreshold
+	}
+}
+
+func (c *Config) clock() Clock {
+	if c.Clock == nil {
+		return systemClock{}
+	}
+	return c.Clock
+}
+
+// sourceAllowed reports whether the Listener may communicate with addr.
+// See AllowedSources and DeniedSources.
+func (c *Config) sourceAllowed(addr netip.Addr) bool {
+	if len(c.AllowedSources) == 0 && len(c.DeniedSources) == 0 {
+		return true
+	}
+	addr = addr.Unmap()
+	for _, p := range c.DeniedSources {
+		if p.Contains(addr) {
+			return false
+		}
+	}
+	if len(c.AllowedSources) == 0 {
+		return true
+	}
+	for _, p := range c.AllowedSources {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// maxAcceptQueue returns the accept queue limit, or 0 for no limit.
+func (c *Config) maxAcceptQueue() int {
+	switch {
+	case c.MaxAcceptQueue == 0:
+		return defaultMaxAcceptQueue
+	case c.MaxAcceptQueue < 0:
+		return 0
+	default:
+		return c.MaxAcceptQueue
+	}
+}
+
+func (c *Config) packetThreshold() packetNumber {
+	if c.PacketThreshold <= 0 {
+		return defaultPacketThreshold
+	}
+	return packetNumber(c.PacketThreshold)
+}
+
+func (c *Config) timeThreshold() float64 {
+	switch {
+	case c.TimeThreshold <= 0:
+		return defaultTimeThreshold
+	case c.TimeThreshold < 1:
+		return 1
+	default:
+		return c.TimeThreshold
+	}
+}
+
+// maxPTOBackoff returns the maximum PTO backoff count.
+func (c *Config) maxPTOBackoff() int {
+	switch {
+	case c.MaxPTOBackoff == 0:
+		return math.MaxInt
+	case c.MaxPTOBackoff < 0:
+		return 0
+	default:
+		return c.MaxPTOBackoff
+	}
+}
+
+func (c *Config) initialRTT() time.Duration {
+	if c.InitialRTT <= 0 {
+		return defaultInitialRTT
+	}
+	return c.InitialRTT
+}
+
+// ackElicitingThreshold returns the Ack-Eliciting Threshold to request of the peer.
+func (c *Config) ackElicitingThreshold() int64 {
+	if c.AckElicitingThreshold <= 0 {
+		return 1
+	}
+	return int64(c.AckElicitingThreshold)
+}
+
+// maxCongestionWindow returns the limit on the congestion window,
+// or 0 for no limit.
+func (c *Config) maxCongestionWindow() int {
+	if c.LowMemory {
+		return 64 << 10
+	}
+	return 0
+}
+
+func (c *Config) maxDatagramFrameSize() int64 {
+	return max(0, min(c.MaxDatagramFrameSize, maxVarint))
+}
+
+// dialVersion returns the QUIC version used for outbound connections.
+func (c *Config) dialVersion() *versionParams {
+	if len(c.Versions) == 0 {
+		return version1Params
+	}
+	return paramsForVersion(uint32(c.Versions[0]))
+}
+
+// acceptVersion returns the QUIC version v if it is enabled,
+// or nil if it is not.
+func (c *Config) acceptVersion(v uint32) *versionParams {
+	if len(c.Versions) == 0 {
+		if v == quicVersion1 {
+			return version1Params
+		}
+		return nil
+	}
+	for _, enabled := range c.Versions {
+		if uint32(enabled) == v {
+			return paramsForVersion(v)
+		}
+	}
+	return nil
+}
+
+// versionNumbers returns the enabled QUIC versions,
+// as sent in a Version Negotiation packet.
+func (c *Config) versionNumbers() []uint32 {
+	if len(c.Versions) == 0 {
+		return []uint32{quicVersion1}
+	}
+	versions := make([]uint32, len(c.Versions))
+	for i, v := range c.Versions {
+		versions[i] = uint32(v)
+	}
+	return versions
 }
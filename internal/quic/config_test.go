@@ -6,7 +6,11 @@
 
 package quic
 
-import "testing"
+import (
+	"net/netip"
+	"runtime"
+	"testing"
+)
 
 func TestConfigTransportParameters(t *testing.T) {
 	const (
@@ -45,3 +49,162 @@ func TestConfigTransportParameters(t *testing.T) {
 		t.Errorf("initial_max_stream_data_uni = %v, want %v", got, want)
 	}
 }
+
+func TestConfigInitialWindows(t *testing.T) {
+	tc := newTestConn(t, clientSide, func(c *Config) {
+		c.MaxStreamReadBufferSize = 100
+		c.InitialStreamReadWindowBidiLocal = 10
+		c.InitialStreamReadWindowBidiRemote = 20
+		c.InitialStreamReadWindowUni = 200 // larger than the maximum
+		c.MaxConnReadBufferSize = 1000
+		c.InitialConnReadWindow = 30
+	})
+	tc.handshake()
+	p := tc.sentTransportParameters
+	if got, want := p.initialMaxData, int64(30); got != want {
+		t.Errorf("initial_max_data = %v, want %v", got, want)
+	}
+	if got, want := p.initialMaxStreamDataBidiLocal, int64(10); got != want {
+		t.Errorf("initial_max_stream_data_bidi_local = %v, want %v", got, want)
+	}
+	if got, want := p.initialMaxStreamDataBidiRemote, int64(20); got != want {
+		t.Errorf("initial_max_stream_data_bidi_remote = %v, want %v", got, want)
+	}
+	if got, want := p.initialMaxStreamDataUni, int64(100); got != want {
+		t.Errorf("initial_max_stream_data_uni = %v, want %v", got, want)
+	}
+}
+
+func TestConfigLowMemory(t *testing.T) {
+	tc := newTestConn(t, clientSide, func(c *Config) {
+		c.LowMemory = true
+		c.MaxUniRemoteStreams = 3 // explicit limits override LowMemory defaults
+	})
+	tc.handshake()
+	p := tc.sentTransportParameters
+	if got, want := p.initialMaxData, int64(64<<10); got != want {
+		t.Errorf("initial_max_data = %v, want %v", got, want)
+	}
+	if got, want := p.initialMaxStreamDataBidiRemote, int64(16<<10); got != want {
+		t.Errorf("initial_max_stream_data_bidi_remote = %v, want %v", got, want)
+	}
+	if got, want := p.initialMaxStreamsBidi, int64(10); got != want {
+		t.Errorf("initial_max_streams_bidi = %v, want %v", got, want)
+	}
+	if got, want := p.initialMaxStreamsUni, int64(3); got != want {
+		t.Errorf("initial_max_streams_uni = %v, want %v", got, want)
+	}
+	if got, want := tc.conn.loss.cc.maxCongestionWindow, 64<<10; got != want {
+		t.Errorf("maxCongestionWindow = %v, want %v", got, want)
+	}
+}
+
+func TestConfigSourceAllowed(t *testing.T) {
+	prefixes := func(s ...string) (p []netip.Prefix) {
+		for _, s := range s {
+			p = append(p, netip.MustParsePrefix(s))
+		}
+		return p
+	}
+	for _, test := range []struct {
+		name  string
+		allow []netip.Prefix
+		deny  []netip.Prefix
+		addr  string
+		want  bool
+	}{{
+		name: "no lists",
+		addr: "192.0.2.1",
+		want: true,
+	}, {
+		name:  "allowed",
+		allow: prefixes("192.0.2.0/24"),
+		addr:  "192.0.2.1",
+		want:  true,
+	}, {
+		name:  "not in allow list",
+		allow: prefixes("192.0.2.0/24"),
+		addr:  "198.51.100.1",
+		want:  false,
+	}, {
+		name: "denied",
+		deny: prefixes("192.0.2.0/24"),
+		addr: "192.0.2.1",
+		want: false,
+	}, {
+		name: "not in deny list",
+		deny: prefixes("192.0.2.0/24"),
+		addr: "198.51.100.1",
+		want: true,
+	}, {
+		name:  "deny takes precedence",
+		allow: prefixes("192.0.2.0/24"),
+		deny:  prefixes("192.0.2.128/25"),
+		addr:  "192.0.2.200",
+		want:  false,
+	}, {
+		name:  "IPv4-mapped address",
+		allow: prefixes("192.0.2.0/24"),
+		addr:  "::ffff:192.0.2.1",
+		want:  true,
+	}, {
+		name:  "IPv6",
+		allow: prefixes("2001:db8::/32"),
+		addr:  "2001:db8::1",
+		want:  true,
+	}} {
+		t.Run(test.name, func(t *testing.T) {
+			c := &Config{
+				AllowedSources: test.allow,
+				DeniedSources:  test.deny,
+			}
+			if got := c.sourceAllowed(netip.MustParseAddr(test.addr)); got != test.want {
+				t.Errorf("sourceAllowed(%v) = %v, want %v", test.addr, got, test.want)
+			}
+		})
+	}
+}
+
+func TestConfigLowMemoryFootprint(t *testing.T) {
+	// Measure the memory held by connections whose peers have filled
+	// their receive buffers. The measurement includes the test harness,
+	// so this is an upper bound on the conn's own footprint.
+	const (
+		conns          = 16
+		wantMaxPerConn = 128 << 10
+	)
+	heapAlloc := func() int64 {
+		// Run GC twice to also release objects held by sync.Pools.
+		runtime.GC()
+		runtime.GC()
+		var ms runtime.MemStats
+		runtime.ReadMemStats(&ms)
+		return int64(ms.HeapAlloc)
+	}
+	before := heapAlloc()
+	tcs := make([]*testConn, conns)
+	for i := range tcs {
+		tc := newTestConn(t, serverSide, func(c *Config) {
+			c.LowMemory = true
+		})
+		tc.handshake()
+		// Four streams of 16KiB fill the 64KiB connection receive window.
+		for j := int64(0); j < 4; j++ {
+			tc.writeFrames(packetType1RTT, debugFrameStream{
+				id:   newStreamID(clientSide, bidiStream, j),
+				data: make([]byte, 16<<10),
+			})
+		}
+		if tc.conn.isClosingOrDraining() {
+			t.Fatalf("conn closed after peer filled receive window")
+		}
+		tcs[i] = tc
+	}
+	after := heapAlloc()
+	runtime.KeepAlive(tcs)
+	perConn := (after - before) / conns
+	t.Logf("%v bytes per conn (GOARCH=%v)", perConn, runtime.GOARCH)
+	if perConn > wantMaxPerConn {
+		t.Errorf("LowMemory conn with full receive buffers uses %v bytes, want at most %v", perConn, wantMaxPerConn)
+	}
+}
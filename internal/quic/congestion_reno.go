@@ -19,6 +19,9 @@ type ccReno struct {
 	// Maximum number of bytes allowed to be in flight.
 	congestionWindow int
 
+	// Upper limit on congestionWindow, or 0 for no limit.
+	maxCongestionWindow int
+
 	// Sum of size of all packets that contain at least one ack-eliciting
 	// or PADDING frame (i.e., any non-ACK frame), and have neither been
 	// acknowledged nor declared lost.
@@ -217,6 +220,10 @@ func (c *ccReno) packetBatchEnd(now time.Time, space numberSpace, rtt *rttState,
 			c.congestionPendingAcks -= c.congestionWindow
 			c.congestionWindow += c.maxDatagramSize
 		}
+		if c.maxCongestionWindow > 0 && c.congestionWindow > c.maxCongestionWindow {
+			c.congestionWindow = c.maxCongestionWindow
+			c.congestionPendingAcks = 0
+		}
 	}
 	if !c.ackLastLoss.IsZero() {
 		// Check for persistent congestion.
@@ -56,6 +56,23 @@ func TestRenoSlowStartWindowIncreases(t *testing.T) {
 	test.wantVar("congestion_window", 12000+1200+600+300)
 }
 
+func TestRenoMaxCongestionWindow(t *testing.T) {
+	test := newRenoTest(t, 1200)
+	test.cc.maxCongestionWindow = 13000
+
+	p0 := test.packetSent(initialSpace, 1200)
+	test.wantVar("congestion_window", 12000)
+	test.packetAcked(initialSpace, p0)
+	test.packetBatchEnd(initialSpace)
+	test.wantVar("congestion_window", 13000) // limited by maxCongestionWindow
+
+	p1 := test.packetSent(initialSpace, 1200)
+	test.packetAcked(initialSpace, p1)
+	test.packetBatchEnd(initialSpace)
+	test.wantVar("congestion_window", 13000)
+	test.wantVar("congestion_pending_acks", 0)
+}
+
 func TestRenoSlowStartToRecovery(t *testing.T) {
 	// "The sender MUST exit slow start and enter a recovery period
 	// when a packet is lost [...]"
@@ -11,7 +11,9 @@ import (
 	"crypto/tls"
 	"errors"
 	"fmt"
+	"net"
 	"net/netip"
+	"runtime"
 	"time"
 )
 
@@ -24,10 +26,29 @@ type Conn struct {
 	config    *Config
 	testHooks connTestHooks
 	peerAddr  netip.AddrPort
+	localAddr netip.AddrPort // if valid, more specific than the Listener's address
+	sock      *net.UDPConn   // connected socket; nil unless Config.ConnectedSockets is set
+	version   *versionParams // negotiated QUIC version
+
+	// versionNegotiated is set when a client has changed versions
+	// in response to a Version Negotiation packet.
+	versionNegotiated bool
+
+	// localParams returns the transport parameters we send to the peer.
+	// A client uses it to start a new handshake after version negotiation.
+	localParams func() transportParameters
+
+	connConfig connConfigState // set when Config.GetConfigForConn is used
+
+	// acceptQueued is set when an inbound conn has been added to an accept queue.
+	acceptQueued bool
 
 	msgc   chan any
+	recvq  recvQueue     // datagrams received by the Listener
+	sendq  sendQueue     // datagrams waiting to be sent to the peer
 	donec  chan struct{} // closed when conn loop exits
 	exited bool          // set to make the conn loop exit immediately
+	pool   pooledConnState
 
 	w           packetWriter
 	acks        [numberSpaceCount]ackState // indexed by number space
@@ -35,11 +56,16 @@ type Conn struct {
 	connIDState connIDState
 	loss        lossState
 	streams     streamsState
-
-	// idleTimeout is the time at which the connection will be closed due to inactivity.
-	// https://www.rfc-editor.org/rfc/rfc9000#section-10.1
-	maxIdleTimeout time.Duration
-	idleTimeout    time.Time
+	datagrams   datagramsState
+	counters    connCounters
+	trace       traceState
+	idle        idleState
+	clockJump   clockJumpState
+	ackFreq     ackFrequencyState
+	spin        spinState
+	errorCodes  errorCodesState
+	mem         connMemory
+	values      connValues // set by the application
 
 	// Packet protection keys, CRYPTO streams, and TLS state.
 	keysInitial   fixedKeyPair
@@ -47,6 +73,7 @@ type Conn struct {
 	keysAppData   updatingKeyPair
 	crypto        [numberSpaceCount]cryptoStream
 	tls           *tls.QUICConn
+	tlsState      tls.ConnectionState // set when the TLS handshake completes
 
 	// retryToken is the token provided by the peer in a Retry packet.
 	retryToken []byte
@@ -57,6 +84,8 @@ type Conn struct {
 
 	peerAckDelayExponent int8 // -1 when unknown
 
+	pings pingState
+
 	// Tests only: Send a PING in a specific number space.
 	testSendPingSpace numberSpace
 	testSendPing      sentVal
@@ -86,22 +115,23 @@ type connTestHooks interface {
 	timeNow() time.Time
 }
 
-func newConn(now time.Time, side connSide, originalDstConnID, retrySrcConnID []byte, peerAddr netip.AddrPort, config *Config, l *Listener) (*Conn, error) {
+func newConn(now time.Time, side connSide, version *versionParams, originalDstConnID, retrySrcConnID []byte, peerAddr, localAddr netip.AddrPort, config *Config, l *Listener) (*Conn, error) {
 	c := &Conn{
 		side:                 side,
+		version:              version,
 		listener:             l,
 		config:               config,
 		peerAddr:             peerAddr,
+		localAddr:            localAddr,
 		msgc:                 make(chan any, 1),
 		donec:                make(chan struct{}),
-		maxIdleTimeout:       defaultMaxIdleTimeout,
-		idleTimeout:          now.Add(defaultMaxIdleTimeout),
 		peerAckDelayExponent: -1,
 	}
 
 	// A one-element buffer allows us to wake a Conn's event loop as a
 	// non-blocking operation.
 	c.msgc = make(chan any, 1)
+	c.recvq.limit = config.connDatagramBacklog()
 
 	if l.testHooks != nil {
 		l.testHooks.newConn(c)
@@ -127,34 +157,81 @@ func newConn(now time.Time, side connSide, originalDstConnID, retrySrcConnID []b
 	// The smallest allowed maximum QUIC datagram size is 1200 bytes.
 	// TODO: PMTU discovery.
 	const maxDatagramSize = 1200
+	c.traceInit(now)
+	c.countersInit()
 	c.keysAppData.init()
-	c.loss.init(c.side, maxDatagramSize, now)
+	c.loss.init(c.side, maxDatagramSize, c.config, now)
+	c.loss.cc.maxCongestionWindow = c.config.maxCongestionWindow()
+	c.memoryInit()
 	c.streamsInit()
-	c.lifetimeInit()
-
-	if err := c.startTLS(now, initialConnID, transportParameters{
-		initialSrcConnID:               c.connIDState.srcConnID(),
-		originalDstConnID:              originalDstConnID,
-		retrySrcConnID:                 retrySrcConnID,
-		ackDelayExponent:               ackDelayExponent,
-		maxUDPPayloadSize:              maxUDPPayloadSize,
-		maxAckDelay:                    maxAckDelay,
-		disableActiveMigration:         true,
-		initialMaxData:                 config.maxConnReadBufferSize(),
-		initialMaxStreamDataBidiLocal:  config.maxStreamReadBufferSize(),
-		initialMaxStreamDataBidiRemote: config.maxStreamReadBufferSize(),
-		initialMaxStreamDataUni:        config.maxStreamReadBufferSize(),
-		initialMaxStreamsBidi:          c.streams.remoteLimit[bidiStream].max,
-		initialMaxStreamsUni:           c.streams.remoteLimit[uniStream].max,
-		activeConnIDLimit:              activeConnIDLimit,
-	}); err != nil {
+	c.datagramsInit()
+	c.lifetimeInit(now)
+	c.idleInit(now)
+	c.ackFrequencyInit()
+	c.spinInit()
+	c.errorCodesInit()
+
+	var resetToken []byte
+	if c.side == serverSide {
+		// Provide a stateless reset token for the connection ID the server
+		// used during the handshake, which the client may continue to use
+		// for the life of the connection.
+		// https://www.rfc-editor.org/rfc/rfc9000#section-18.2-4.6.1
+		token := l.resetGen.tokenForConnID(c.connIDState.srcConnID())
+		resetToken = token[:]
+	}
+	params := func() transportParameters {
+		// The datagram size is a property of the Listener,
+		// but other parameters come from Config.GetConfigForConn if set.
+		connConfig := c.config
+		return transportParameters{
+			initialSrcConnID:               c.connIDState.srcConnID(),
+			statelessResetToken:            resetToken,
+			maxIdleTimeout:                 c.idle.localMaxIdleTimeout,
+			originalDstConnID:              originalDstConnID,
+			retrySrcConnID:                 retrySrcConnID,
+			ackDelayExponent:               ackDelayExponent,
+			maxUDPPayloadSize:              int64(config.datagramBufferSize()),
+			maxAckDelay:                    maxAckDelay,
+			disableActiveMigration:         true,
+			initialMaxData:                 connConfig.initialConnReadWindow(),
+			initialMaxStreamDataBidiLocal:  connConfig.initialStreamReadWindowBidiLocal(),
+			initialMaxStreamDataBidiRemote: connConfig.initialStreamReadWindowBidiRemote(),
+			initialMaxStreamDataUni:        connConfig.initialStreamReadWindowUni(),
+			initialMaxStreamsBidi:          c.streams.remoteLimit[bidiStream].max,
+			initialMaxStreamsUni:           c.streams.remoteLimit[uniStream].max,
+			activeConnIDLimit:              activeConnIDLimit,
+			maxDatagramFrameSize:           connConfig.maxDatagramFrameSize(),
+			minAckDelay:                    c.ackFrequencyMinAckDelay(),
+			chosenVersion:                  c.version.number,
+			availableVersions:              c.availableVersions(),
+		}
+	}
+	c.localParams = params
+	if err := c.startTLS(now, initialConnID, params); err != nil {
 		return nil, err
 	}
 
+	if c.side == clientSide && config.ConnectedSockets {
+		sock, err := l.dialSocket(peerAddr)
+		if err != nil {
+			return nil, err
+		}
+		c.sock = sock
+	}
+
 	if c.testHooks != nil {
 		c.testHooks.init()
 	}
-	go c.loop(now)
+	if l.pool != nil && c.testHooks == nil {
+		c.pool.shard = l.pool.assign()
+		c.post(wakeEvent{})
+	} else {
+		go c.loop(now)
+	}
+	if c.sock != nil {
+		go c.readSocket()
+	}
 	return c, nil
 }
 
@@ -162,6 +239,51 @@ func (c *Conn) String() string {
 	return fmt.Sprintf("quic.Conn(%v,->%v)", c.side, c.peerAddr)
 }
 
+// Version returns the QUIC version used by the connection.
+func (c *Conn) Version() Version {
+	return Version(c.version.number)
+}
+
+// LocalAddr returns the local address of the connection.
+//
+// For an inbound connection to a Listener bound to an unspecified address,
+// this is the address the client sent its first datagram to,
+// if the platform reports it.
+// Otherwise, it is the address of the connection's socket.
+func (c *Conn) LocalAddr() netip.AddrPort {
+	if c.sock != nil {
+		a, _ := c.sock.LocalAddr().(*net.UDPAddr)
+		return a.AddrPort()
+	}
+	if c.localAddr.IsValid() {
+		return c.localAddr
+	}
+	return c.listener.LocalAddr()
+}
+
+// RemoteAddr returns the address of the connection's peer.
+//
+// Connections do not currently migrate to new paths,
+// so the address does not change during the life of the connection.
+func (c *Conn) RemoteAddr() netip.AddrPort {
+	return c.peerAddr
+}
+
+// ConnectionState returns basic TLS details about the connection,
+// such as the negotiated application protocol and the peer's certificates.
+//
+// Until the TLS handshake completes, it returns the zero ConnectionState.
+// A server which requires client certificates (see tls.Config.ClientAuth)
+// may use PeerCertificates and VerifiedChains to authenticate the client.
+func (c *Conn) ConnectionState() tls.ConnectionState {
+	select {
+	case <-c.lifetime.readyc:
+		return c.tlsState
+	default:
+		return tls.ConnectionState{}
+	}
+}
+
 // confirmHandshake is called when the handshake is confirmed.
 // https://www.rfc-editor.org/rfc/rfc9001#section-4.1.2
 func (c *Conn) confirmHandshake(now time.Time) {
@@ -183,6 +305,7 @@ func (c *Conn) confirmHandshake(now time.Time) {
 		c.handshakeConfirmed.setReceived()
 	}
 	c.loss.confirmHandshake()
+	c.traceStateChanged(now, TraceStateHandshakeConfirmed)
 	// "An endpoint MUST discard its Handshake keys when the TLS handshake is confirmed"
 	// https://www.rfc-editor.org/rfc/rfc9001#section-4.9.2-1
 	c.discardKeys(now, handshakeSpace)
@@ -213,7 +336,10 @@ func (c *Conn) receiveTransportParameters(p transportParameters) error {
 	c.streams.peerInitialMaxStreamDataRemote[bidiStream] = p.initialMaxStreamDataBidiRemote
 	c.streams.peerInitialMaxStreamDataRemote[uniStream] = p.initialMaxStreamDataUni
 	c.peerAckDelayExponent = p.ackDelayExponent
+	c.receivePeerMaxIdleTimeout(p.maxIdleTimeout)
 	c.loss.setMaxAckDelay(p.maxAckDelay)
+	c.receivePeerMinAckDelay(p.minAckDelay)
+	c.datagrams.peerMaxFrameSize.Store(p.maxDatagramFrameSize)
 	if err := c.connIDState.setPeerActiveConnIDLimit(c, p.activeConnIDLimit); err != nil {
 		return err
 	}
@@ -228,7 +354,9 @@ func (c *Conn) receiveTransportParameters(p transportParameters) error {
 			return err
 		}
 	}
-	// TODO: max_idle_timeout
+	if err := c.validateVersionInformation(p); err != nil {
+		return err
+	}
 	// TODO: stateless_reset_token
 	// TODO: max_udp_payload_size
 	// TODO: disable_active_migration
@@ -241,6 +369,10 @@ type (
 	wakeEvent  struct{}
 )
 
+// maxBusyEvents is the number of consecutive events the conn's loop
+// will handle without blocking before yielding the processor.
+const maxBusyEvents = 32
+
 // loop is the connection main loop.
 //
 // Except where otherwise noted, all connection state is owned by the loop goroutine.
@@ -248,94 +380,167 @@ type (
 // The loop processes messages from c.msgc and timer events.
 // Other goroutines may examine or modify conn state by sending the loop funcs to execute.
 func (c *Conn) loop(now time.Time) {
-	defer close(c.donec)
-	defer c.tls.Close()
-	defer c.listener.connDrained(c)
+	defer func() {
+		c.loopExited(now)
+	}()
 
 	// The connection timer sends a message to the connection loop on expiry.
 	// We need to give it an expiry when creating it, so set the initial timeout to
 	// an arbitrary large value. The timer will be reset before this expires (and it
 	// isn't a problem if it does anyway). Skip creating the timer in tests which
 	// take control of the connection message loop.
-	var timer *time.Timer
+	var timer ClockTimer
 	var lastTimeout time.Time
+	clock := c.config.clock()
 	hooks := c.testHooks
 	if hooks == nil {
-		timer = time.AfterFunc(1*time.Hour, func() {
+		timer = clock.AfterFunc(1*time.Hour, func() {
 			c.sendMsg(timerEvent{})
 		})
 		defer timer.Stop()
 	}
 
+	// busy counts the events handled since the loop last found msgc empty.
+	busy := 0
 	for !c.exited {
-		sendTimeout := c.maybeSend(now) // try sending
-
-		// Note that we only need to consider the ack timer for the App Data space,
-		// since the Initial and Handshake spaces always ack immediately.
-		nextTimeout := sendTimeout
-		nextTimeout = firstTime(nextTimeout, c.idleTimeout)
-		if !c.isClosingOrDraining() {
-			nextTimeout = firstTime(nextTimeout, c.loss.timer)
-			nextTimeout = firstTime(nextTimeout, c.acks[appDataSpace].nextAck)
-		} else {
-			nextTimeout = firstTime(nextTimeout, c.lifetime.drainEndTime)
+		if c.handleQueuedDatagrams(now) {
+			return
 		}
+		sendTimeout, sendMore := c.maybeSend(now) // try sending
+		nextTimeout := firstTime(sendTimeout, c.nextTimeout())
 
 		var m any
-		if hooks != nil {
+		if hooks != nil && sendMore {
+			// Tests only: Keep sending without consulting the test.
+			m = wakeEvent{}
+		} else if hooks != nil {
 			// Tests only: Wait for the test to tell us to continue.
 			now, m = hooks.nextMessage(c.msgc, nextTimeout)
 		} else if !nextTimeout.IsZero() && nextTimeout.Before(now) {
 			// A connection timer has expired.
-			now = time.Now()
+			now = c.clockNow(clock.Now(), now)
 			m = timerEvent{}
+		} else if sendMore {
+			// maybeSend stopped before running out of data to send.
+			// Let other goroutines run, then handle any pending event
+			// before resuming sending.
+			runtime.Gosched()
+			select {
+			case m = <-c.msgc:
+			default:
+				m = wakeEvent{}
+			}
+			now = c.clockNow(clock.Now(), now)
 		} else {
 			// Reschedule the connection timer if necessary
 			// and wait for the next event.
 			if !nextTimeout.Equal(lastTimeout) && !nextTimeout.IsZero() {
-				// Resetting a timer created with time.AfterFunc guarantees
+				// Resetting a timer created with AfterFunc guarantees
 				// that the timer will run again. We might generate a spurious
 				// timer event under some circumstances, but that's okay.
 				timer.Reset(nextTimeout.Sub(now))
 				lastTimeout = nextTimeout
 			}
-			m = <-c.msgc
-			now = time.Now()
-		}
-		switch m := m.(type) {
-		case *datagram:
-			c.handleDatagram(now, m)
-			m.recycle()
-		case timerEvent:
-			// A connection timer has expired.
-			if !now.Before(c.idleTimeout) {
-				// "[...] the connection is silently closed and
-				// its state is discarded [...]"
-				// https://www.rfc-editor.org/rfc/rfc9000#section-10.1-1
-				c.exited = true
-				return
-			}
-			c.loss.advance(now, c.handleAckOrLoss)
-			if c.lifetimeAdvance(now) {
-				// The connection has completed the draining period,
-				// and may be shut down.
-				return
+			select {
+			case m = <-c.msgc:
+				// A backlog of events is waiting for us.
+				// If we've been busy for a while, yield to other goroutines
+				// so a single heavily loaded connection doesn't monopolize
+				// the processor.
+				busy++
+				if busy >= maxBusyEvents {
+					busy = 0
+					runtime.Gosched()
+				}
+			default:
+				busy = 0
+				m = <-c.msgc
 			}
-		case wakeEvent:
-			// We're being woken up to try sending some frames.
-		case func(time.Time, *Conn):
-			// Send a func to msgc to run it on the main Conn goroutine
-			m(now, c)
-		default:
-			panic(fmt.Sprintf("quic: unrecognized conn message %T", m))
+			now = c.clockNow(clock.Now(), nextTimeout)
+		}
+		if c.handleEvent(now, m) {
+			return
 		}
 	}
 }
 
+// loopExited is called when the conn's loop exits.
+func (c *Conn) loopExited(now time.Time) {
+	c.traceStateChanged(now, TraceStateClosed)
+	c.recvq.close()
+	c.listener.connDrained(c)
+	if c.sock != nil {
+		c.sock.Close()
+	}
+	c.tls.Close()
+	close(c.donec)
+}
+
+// nextTimeout returns the time of the next connection timer event,
+// not including the time at which the conn may next send a packet.
+func (c *Conn) nextTimeout() time.Time {
+	// Note that we only need to consider the ack timer for the App Data space,
+	// since the Initial and Handshake spaces always ack immediately.
+	nextTimeout := c.idleNextTimeout()
+	if !c.isClosingOrDraining() {
+		nextTimeout = firstTime(nextTimeout, c.lifetime.handshakeDeadline)
+		nextTimeout = firstTime(nextTimeout, c.loss.timer)
+		nextTimeout = firstTime(nextTimeout, c.acks[appDataSpace].nextAck)
+	} else {
+		nextTimeout = firstTime(nextTimeout, c.lifetime.drainEndTime)
+	}
+	return nextTimeout
+}
+
+// handleEvent handles a single message sent to the conn's loop.
+// It reports whether the loop should exit.
+func (c *Conn) handleEvent(now time.Time, m any) (exit bool) {
+	switch m := m.(type) {
+	case *datagram:
+		c.handleDatagram(now, m)
+		m.recycle()
+	case timerEvent:
+		// A connection timer has expired.
+		if c.idleAdvance(now) {
+			// "[...] the connection is silently closed and
+			// its state is discarded [...]"
+			// https://www.rfc-editor.org/rfc/rfc9000#section-10.1-1
+			c.abortImmediately(now, IdleTimeoutError{})
+			return true
+		}
+		if !c.isClosingOrDraining() && c.handshakeTimedOut(now) {
+			// The peer has not completed the handshake in time.
+			// Discard the connection without sending a CONNECTION_CLOSE,
+			// since the peer may not have the keys to read it.
+			c.abortImmediately(now, errHandshakeTimeout)
+			return true
+		}
+		c.loss.advance(now, c.ackOrLossFunc(now))
+		c.traceCongestion(now)
+		if c.lifetimeAdvance(now) {
+			// The connection has completed the draining period,
+			// and may be shut down.
+			return true
+		}
+	case wakeEvent:
+		// We're being woken up to try sending some frames.
+	case func(time.Time, *Conn):
+		// Send a func to msgc to run it on the main Conn goroutine
+		m(now, c)
+	default:
+		panic(fmt.Sprintf("quic: unrecognized conn message %T", m))
+	}
+	return c.exited
+}
+
 // sendMsg sends a message to the conn's loop.
 // It does not wait for the message to be processed.
 // The conn may close before processing the message, in which case it is lost.
 func (c *Conn) sendMsg(m any) {
+	if c.pool.shard != nil {
+		c.post(m)
+		return
+	}
 	select {
 	case c.msgc <- m:
 	case <-c.donec:
@@ -344,6 +549,10 @@ func (c *Conn) sendMsg(m any) {
 
 // wake wakes up the conn's loop.
 func (c *Conn) wake() {
+	if c.pool.shard != nil {
+		c.post(wakeEvent{})
+		return
+	}
 	select {
 	case c.msgc <- wakeEvent{}:
 	default:
@@ -158,7 +158,21 @@ func (as *asyncTestState) waitUntil(ctx context.Context, until func() bool) erro
 	select {
 	case <-b.donec:
 	case <-ctx.Done():
-		return ctx.Err()
+		// Remove the operation from the blocked set before returning.
+		// Otherwise a later wakeAsync may call until on behalf of an
+		// operation which has already returned, and until commonly
+		// acquires a lock (such as a gate) which would then never be
+		// released.
+		as.mu.Lock()
+		_, blocked := as.blocked[b]
+		delete(as.blocked, b)
+		as.mu.Unlock()
+		if blocked {
+			return ctx.Err()
+		}
+		// wakeAsync has already called until, which may have acquired a lock,
+		// so the operation must proceed.
+		<-b.donec
 	}
 	return nil
 }
@@ -20,6 +20,11 @@ type lifetimeState struct {
 	readyc    chan struct{} // closed when TLS handshake completes
 	drainingc chan struct{} // closed when entering the draining state
 
+	// ctx is canceled when the connection enters the closing or draining state.
+	// Stream contexts are canceled along with it.
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+
 	// Possible states for the connection:
 	//
 	// Alive: localErr and finalErr are both nil.
@@ -39,18 +44,36 @@ type lifetimeState struct {
 	// https://www.rfc-editor.org/rfc/rfc9000.html#section-10.2.2
 	localErr error // error sent to the peer
 	finalErr error // error sent by the peer, or transport error; always set before draining
+	closeErr error // error returned by Conn.Err; set when entering draining
 
 	connCloseSentTime time.Time     // send time of last CONNECTION_CLOSE frame
 	connCloseDelay    time.Duration // delay until next CONNECTION_CLOSE frame sent
+	connCloseDatagram []byte        // last datagram sent containing a CONNECTION_CLOSE frame
 	drainEndTime      time.Time     // time the connection exits the draining state
+
+	// handshakeDeadline is the time by which the handshake must complete.
+	// It is zero if there is no handshake timeout or the handshake has completed.
+	handshakeDeadline time.Time
 }
 
-func (c *Conn) lifetimeInit() {
+func (c *Conn) lifetimeInit(now time.Time) {
 	c.lifetime.readyc = make(chan struct{})
 	c.lifetime.drainingc = make(chan struct{})
+	c.lifetime.ctx, c.lifetime.cancel = context.WithCancelCause(context.Background())
+	if d := c.config.handshakeTimeout(c.side); d > 0 {
+		c.lifetime.handshakeDeadline = now.Add(d)
+	}
 }
 
-var errNoPeerResponse = errors.New("peer did not respond to CONNECTION_CLOSE")
+var (
+	errNoPeerResponse   = errors.New("peer did not respond to CONNECTION_CLOSE")
+	errHandshakeTimeout = errors.New("handshake timeout")
+)
+
+// handshakeTimedOut reports whether the handshake deadline has passed.
+func (c *Conn) handshakeTimedOut(now time.Time) bool {
+	return !c.lifetime.handshakeDeadline.IsZero() && !now.Before(c.lifetime.handshakeDeadline)
+}
 
 // advance is called when time passes.
 func (c *Conn) lifetimeAdvance(now time.Time) (done bool) {
@@ -62,14 +85,17 @@ func (c *Conn) lifetimeAdvance(now time.Time) (done bool) {
 	c.lifetime.drainEndTime = time.Time{}
 	if c.lifetime.finalErr == nil {
 		// The peer never responded to our CONNECTION_CLOSE.
-		c.enterDraining(errNoPeerResponse)
+		c.enterDraining(now, errNoPeerResponse)
 	}
 	return true
 }
 
 // confirmHandshake is called when the TLS handshake completes.
-func (c *Conn) handshakeDone() {
+func (c *Conn) handshakeDone(now time.Time) {
+	c.lifetime.handshakeDeadline = time.Time{}
 	close(c.lifetime.readyc)
+	c.errorCodesHandshakeDone()
+	c.traceStateChanged(now, TraceStateHandshakeDone)
 }
 
 // isDraining reports whether the conn is in the draining state.
@@ -152,7 +178,7 @@ func (c *Conn) sendOK(now time.Time) bool {
 }
 
 // enterDraining enters the draining state.
-func (c *Conn) enterDraining(err error) {
+func (c *Conn) enterDraining(now time.Time, err error) {
 	if c.isDraining() {
 		return
 	}
@@ -163,8 +189,44 @@ func (c *Conn) enterDraining(err error) {
 	} else {
 		c.lifetime.finalErr = err
 	}
+	c.lifetime.closeErr = c.connectionCloseError(err)
 	close(c.lifetime.drainingc)
+	c.lifetime.cancel(c.lifetime.finalErr)
 	c.streams.queue.close(c.lifetime.finalErr)
+	c.streams.uniQueue.close(c.lifetime.finalErr)
+	c.datagrams.recv.close(c.lifetime.finalErr)
+	c.pingsClose(c.lifetime.finalErr)
+	c.traceStateChanged(now, TraceStateDraining)
+}
+
+// connectionCloseError returns the error reported by Conn.Err
+// for a connection entering the draining state due to err.
+func (c *Conn) connectionCloseError(err error) error {
+	if c.lifetime.localErr != nil && !c.lifetime.connCloseSentTime.IsZero() {
+		// We sent a CONNECTION_CLOSE before the connection terminated.
+		e := localConnectionCloseError(c.lifetime.localErr)
+		e.codes = c.errorCodeSpace()
+		return e
+	}
+	switch e := err.(type) {
+	case peerTransportError:
+		return &ConnectionCloseError{
+			Remote:    true,
+			Code:      uint64(e.code),
+			FrameType: e.frameType,
+			Reason:    e.reason,
+		}
+	case *ApplicationError:
+		// The peer sent an application CONNECTION_CLOSE.
+		return &ConnectionCloseError{
+			Remote:      true,
+			Application: true,
+			Code:        e.Code,
+			Reason:      e.Reason,
+			codes:       e.codes,
+		}
+	}
+	return c.lifetime.finalErr
 }
 
 func (c *Conn) waitReady(ctx context.Context) error {
@@ -197,6 +259,24 @@ func (c *Conn) Close() error {
 	return c.lifetime.finalErr
 }
 
+// CloseWithError closes the connection with an application error code
+// and reason phrase, which the peer receives as an *ApplicationError.
+// The code must be less than 2^62; larger codes are sent as 2^62-1.
+// The reason is truncated if it does not fit in a single packet.
+//
+// CloseWithError is equivalent to:
+//
+//	conn.Abort(&ApplicationError{Code: code, Reason: reason})
+//	err := conn.Wait(context.Background())
+func (c *Conn) CloseWithError(code uint64, reason string) error {
+	c.Abort(&ApplicationError{
+		Code:   min(code, maxVarint),
+		Reason: reason,
+	})
+	<-c.lifetime.drainingc
+	return c.lifetime.finalErr
+}
+
 // Wait waits for the peer to close the connection.
 //
 // If the connection is closed locally and the peer does not close its end of the connection,
@@ -213,6 +293,24 @@ func (c *Conn) Wait(ctx context.Context) error {
 	return c.lifetime.finalErr
 }
 
+// Err reports why the connection was closed.
+// It returns nil if the connection has not been closed,
+// or is still waiting for the peer to acknowledge its closure.
+//
+// If the connection was terminated by a CONNECTION_CLOSE frame,
+// Err returns a *ConnectionCloseError describing the frame and
+// whether it was sent by the peer or by this endpoint.
+// Otherwise, such as after an idle timeout or stateless reset,
+// it returns the same error as Wait.
+func (c *Conn) Err() error {
+	select {
+	case <-c.lifetime.drainingc:
+		return c.lifetime.closeErr
+	default:
+		return nil
+	}
+}
+
 // Abort closes the connection and returns immediately.
 //
 // If err is nil, Abort sends a transport error of NO_ERROR to the peer.
@@ -233,20 +331,24 @@ func (c *Conn) abort(now time.Time, err error) {
 		return // already closing
 	}
 	c.lifetime.localErr = err
+	c.lifetime.cancel(err)
+	if !c.isDraining() {
+		c.traceStateChanged(now, TraceStateClosing)
+	}
 }
 
 // abortImmediately terminates a connection.
 // The connection does not send a CONNECTION_CLOSE, and skips the draining period.
 func (c *Conn) abortImmediately(now time.Time, err error) {
 	c.abort(now, err)
-	c.enterDraining(err)
+	c.enterDraining(now, err)
 	c.exited = true
 }
 
 // exit fully terminates a connection immediately.
 func (c *Conn) exit() {
 	c.sendMsg(func(now time.Time, c *Conn) {
-		c.enterDraining(errors.New("connection closed"))
+		c.enterDraining(now, errors.New("connection closed"))
 		c.exited = true
 	})
 }
@@ -10,8 +10,11 @@ import (
 	"context"
 	"crypto/tls"
 	"errors"
+	"math"
+	"strings"
 	"testing"
 	"time"
+	"unicode/utf8"
 )
 
 func TestConnCloseResponseBackoff(t *testing.T) {
@@ -59,7 +62,12 @@ func TestConnCloseResponseBackoff(t *testing.T) {
 
 	tc.advance(100000 * time.Microsecond)
 	tc.writeFrames(packetType1RTT, debugFramePing{})
-	tc.wantIdle("drain timer expired, no more responses")
+	tc.wantFrame("drain timer expired, listener resends last CONN_CLOSE",
+		packetType1RTT, debugFrameConnectionCloseTransport{
+			code: errNo,
+		})
+	tc.writeFrames(packetType1RTT, debugFramePing{})
+	tc.wantIdle("listener continues CONN_CLOSE backoff")
 
 	if _, err := waiting.result(); !errors.Is(err, errNoPeerResponse) {
 		t.Errorf("blocked conn.Wait() = %v, want errNoPeerResponse", err)
@@ -130,6 +138,132 @@ func TestConnClosePeerCloses(t *testing.T) {
 		})
 }
 
+func TestConnCloseWithError(t *testing.T) {
+	ctx := context.Background()
+	cli, srv := newLocalConnPair(t, &Config{}, &Config{})
+	closeDone := make(chan error, 1)
+	go func() {
+		closeDone <- cli.CloseWithError(42, "goodbye")
+	}()
+
+	waitCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	err := srv.Wait(waitCtx)
+	var appErr *ApplicationError
+	if !errors.As(err, &appErr) || appErr.Code != 42 || appErr.Reason != "goodbye" {
+		t.Fatalf("peer conn.Wait() = %v, want ApplicationError{42, \"goodbye\"}", err)
+	}
+	if got, want := err.Error(), `AppError 42: "goodbye"`; got != want {
+		t.Errorf("err.Error() = %q, want %q", got, want)
+	}
+
+	srv.Abort(nil)
+	if err, want := <-closeDone, (peerTransportError{code: errNo}); !errors.Is(err, want) {
+		t.Errorf("CloseWithError() = %v, want %v", err, want)
+	}
+}
+
+func TestConnCloseWithErrorCodeTooLarge(t *testing.T) {
+	ctx := context.Background()
+	cli, srv := newLocalConnPair(t, &Config{}, &Config{})
+	closeDone := make(chan error, 1)
+	go func() {
+		closeDone <- cli.CloseWithError(math.MaxUint64, "too large")
+	}()
+
+	waitCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	err := srv.Wait(waitCtx)
+	var appErr *ApplicationError
+	if !errors.As(err, &appErr) || appErr.Code != maxVarint {
+		t.Fatalf("peer conn.Wait() = %v, want ApplicationError with code %v", err, uint64(maxVarint))
+	}
+	srv.Abort(nil)
+	<-closeDone
+}
+
+func TestConnCloseApplicationErrorReasonTruncated(t *testing.T) {
+	tc := newTestConn(t, clientSide)
+	tc.handshake()
+
+	reason := strings.Repeat("\u00e9", 1000) // 2000 bytes, too large for one packet
+	tc.conn.Abort(&ApplicationError{
+		Code:   1,
+		Reason: reason,
+	})
+	f, ptype := tc.readFrame()
+	got, ok := f.(debugFrameConnectionCloseApplication)
+	if !ok || ptype != packetType1RTT {
+		t.Fatalf("got %v %v, want 1-RTT CONNECTION_CLOSE", ptype, f)
+	}
+	if got.code != 1 {
+		t.Errorf("CONNECTION_CLOSE code = %v, want 1", got.code)
+	}
+	if len(got.reason) == 0 || len(got.reason) >= len(reason) || !strings.HasPrefix(reason, got.reason) {
+		t.Errorf("CONNECTION_CLOSE reason has length %v, want a truncated prefix of the %v byte reason",
+			len(got.reason), len(reason))
+	}
+	if !utf8.ValidString(got.reason) {
+		t.Errorf("truncated CONNECTION_CLOSE reason is not valid UTF-8")
+	}
+}
+
+func TestConnErrPeerClosed(t *testing.T) {
+	tc := newTestConn(t, clientSide)
+	tc.handshake()
+	if err := tc.conn.Err(); err != nil {
+		t.Fatalf("conn.Err() on open conn = %v, want nil", err)
+	}
+	tc.writeFrames(packetType1RTT, debugFrameConnectionCloseTransport{
+		code:      errProtocolViolation,
+		frameType: frameTypeStreamBase,
+		reason:    "bad stream",
+	})
+	want := &ConnectionCloseError{
+		Remote:    true,
+		Code:      uint64(errProtocolViolation),
+		FrameType: frameTypeStreamBase,
+		Reason:    "bad stream",
+	}
+	var got *ConnectionCloseError
+	if err := tc.conn.Err(); !errors.As(err, &got) || *got != *want {
+		t.Errorf("conn.Err() = %#v, want %#v", err, want)
+	}
+}
+
+func TestConnErrLocallyClosed(t *testing.T) {
+	tc := newTestConn(t, clientSide)
+	tc.handshake()
+	tc.conn.Abort(&ApplicationError{Code: 9, Reason: "because"})
+	tc.wantFrame("aborting connection generates CONN_CLOSE",
+		packetType1RTT, debugFrameConnectionCloseApplication{
+			code:   9,
+			reason: "because",
+		})
+	if err := tc.conn.Err(); err != nil {
+		t.Errorf("conn.Err() while closing = %v, want nil", err)
+	}
+	tc.writeFrames(packetType1RTT, debugFrameConnectionCloseTransport{
+		code: errNo,
+	})
+	want := &ConnectionCloseError{
+		Application: true,
+		Code:        9,
+		Reason:      "because",
+	}
+	err := tc.conn.Err()
+	var got *ConnectionCloseError
+	if !errors.As(err, &got) || *got != *want {
+		t.Errorf("conn.Err() = %#v, want %#v", err, want)
+	}
+	if !errors.Is(err, &ApplicationError{Code: 9}) {
+		t.Errorf("conn.Err() = %v, want it to match ApplicationError{Code: 9}", err)
+	}
+	if got, want := err.Error(), `closed connection: AppError 9: "because"`; got != want {
+		t.Errorf("conn.Err().Error() = %q, want %q", got, want)
+	}
+}
+
 func TestConnCloseReceiveInInitial(t *testing.T) {
 	tc := newTestConn(t, clientSide)
 	tc.wantFrame("client sends Initial CRYPTO frame",
@@ -198,3 +332,87 @@ func TestConnCloseClosedByListener(t *testing.T) {
 			code: errNo,
 		})
 }
+
+func TestConnHandshakeTimeout(t *testing.T) {
+	for _, test := range []struct {
+		name        string
+		side        connSide
+		config      func(*Config)
+		wantTimeout time.Duration
+	}{{
+		name:        "server default",
+		side:        serverSide,
+		config:      func(c *Config) {},
+		wantTimeout: defaultHandshakeTimeout,
+	}, {
+		name: "server",
+		side: serverSide,
+		config: func(c *Config) {
+			c.HandshakeTimeout = 5 * time.Second
+			c.DialHandshakeTimeout = 20 * time.Second
+		},
+		wantTimeout: 5 * time.Second,
+	}, {
+		name: "client uses HandshakeTimeout",
+		side: clientSide,
+		config: func(c *Config) {
+			c.HandshakeTimeout = 5 * time.Second
+		},
+		wantTimeout: 5 * time.Second,
+	}, {
+		name: "client",
+		side: clientSide,
+		config: func(c *Config) {
+			c.HandshakeTimeout = 5 * time.Second
+			c.DialHandshakeTimeout = 20 * time.Second
+		},
+		wantTimeout: 20 * time.Second,
+	}} {
+		t.Run(test.name, func(t *testing.T) {
+			tc := newTestConn(t, test.side, test.config)
+			tc.ignoreFrame(frameTypeCrypto)
+			tc.ignoreFrame(frameTypePing)
+			tc.advance(test.wantTimeout - 1)
+			if tc.conn.exited {
+				t.Fatalf("conn exited before handshake timeout")
+			}
+			tc.advance(1)
+			if !tc.conn.exited {
+				t.Fatalf("conn did not exit after handshake timeout")
+			}
+			if err := tc.conn.Wait(canceledContext()); !errors.Is(err, errHandshakeTimeout) {
+				t.Errorf("conn.Wait() = %v, want errHandshakeTimeout", err)
+			}
+		})
+	}
+}
+
+func TestConnHandshakeTimeoutAfterHandshake(t *testing.T) {
+	tc := newTestConn(t, serverSide, func(c *Config) {
+		c.HandshakeTimeout = 5 * time.Second
+	})
+	tc.handshake()
+	tc.ignoreFrame(frameTypeAck)
+	tc.writeAckForAll()
+	tc.advance(10 * time.Second)
+	if tc.conn.exited {
+		t.Fatalf("conn exited after completing handshake")
+	}
+}
+
+func TestConnHandshakeTimeoutDisabled(t *testing.T) {
+	tc := newTestConn(t, serverSide, func(c *Config) {
+		c.HandshakeTimeout = -1
+	})
+	tc.ignoreFrame(frameTypeCrypto)
+	tc.ignoreFrame(frameTypePing)
+	tc.advance(defaultMaxIdleTimeout - 1)
+	if tc.conn.exited {
+		t.Fatalf("conn exited with handshake timeout disabled")
+	}
+	tc.advance(1)
+	var idleErr IdleTimeoutError
+	if err := tc.conn.Wait(canceledContext()); !errors.As(err, &idleErr) {
+		t.Errorf("conn.Wait() = %v, want IdleTimeoutError", err)
+	}
+}
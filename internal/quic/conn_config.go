@@ -0,0 +1,99 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"crypto/tls"
+	"time"
+)
+
+// connConfigState is the state of a server conn whose Config
+// is chosen by Config.GetConfigForConn.
+type connConfigState struct {
+	// pending is the Config returned by GetConfigForConn.
+	// It is set during the TLS handshake, and applied by the conn loop.
+	pending *Config
+
+	// params returns the conn's transport parameters.
+	// It is nil once the transport parameters have been provided to TLS.
+	params func() transportParameters
+}
+
+// connConfigTLSConfig returns a TLS configuration for a server conn
+// which calls Config.GetConfigForConn when the ClientHello is received.
+func (c *Conn) connConfigTLSConfig(tlsConfig *tls.Config) *tls.Config {
+	getConfigForConn := c.config.GetConfigForConn
+	getConfigForClient := tlsConfig.GetConfigForClient
+	tlsConfig = tlsConfig.Clone()
+	tlsConfig.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		// This is called by the TLS handshake while the conn loop
+		// is blocked handling CRYPTO data, so we may set pending here.
+		config, err := getConfigForConn(hello, c.peerAddr)
+		if err != nil {
+			return nil, err
+		}
+		if config != nil {
+			c.connConfig.pending = config
+			if config.TLSConfig != nil {
+				return config.TLSConfig, nil
+			}
+		}
+		if getConfigForClient != nil {
+			return getConfigForClient(hello)
+		}
+		return nil, nil
+	}
+	return tlsConfig
+}
+
+// applyConnConfig switches the conn to the Config returned by Config.GetConfigForConn.
+//
+// The conn has handled no data other than the ClientHello,
+// so the state derived from the Config may be reinitialized.
+func (c *Conn) applyConnConfig(now time.Time) {
+	c.config = mergeConnConfig(c.config, c.connConfig.pending)
+	c.connConfig.pending = nil
+	c.loss.cc.maxCongestionWindow = c.config.maxCongestionWindow()
+	c.memoryInit()
+	c.streamsInit()
+	c.idleInit(now)
+	c.spinInit()
+	c.errorCodesInit()
+	c.countersInit()
+}
+
+// mergeConnConfig returns a copy of the Listener's Config with the fields
+// which GetConfigForConn may change for a single conn taken from conn.
+// See Config.GetConfigForConn.
+func mergeConnConfig(listener, conn *Config) *Config {
+	c := *listener
+	if conn.TLSConfig != nil {
+		c.TLSConfig = conn.TLSConfig
+	}
+	// Stream limits.
+	c.MaxBidiRemoteStreams = conn.MaxBidiRemoteStreams
+	c.MaxUniRemoteStreams = conn.MaxUniRemoteStreams
+	c.StreamLimitUpdate = conn.StreamLimitUpdate
+	// Flow control.
+	c.MaxStreamReadBufferSize = conn.MaxStreamReadBufferSize
+	c.InitialStreamReadWindowBidiLocal = conn.InitialStreamReadWindowBidiLocal
+	c.InitialStreamReadWindowBidiRemote = conn.InitialStreamReadWindowBidiRemote
+	c.InitialStreamReadWindowUni = conn.InitialStreamReadWindowUni
+	c.MaxStreamWriteBufferSize = conn.MaxStreamWriteBufferSize
+	c.MaxConnReadBufferSize = conn.MaxConnReadBufferSize
+	c.InitialConnReadWindow = conn.InitialConnReadWindow
+	c.AutoTuneReceiveWindows = conn.AutoTuneReceiveWindows
+	// Timeouts.
+	c.MaxIdleTimeout = conn.MaxIdleTimeout
+	c.KeepAlivePeriod = conn.KeepAlivePeriod
+	// Other per-conn settings.
+	c.MaxConnectionMemory = conn.MaxConnectionMemory
+	c.MaxDatagramFrameSize = conn.MaxDatagramFrameSize
+	c.ErrorCodes = conn.ErrorCodes
+	c.NewStreamScheduler = conn.NewStreamScheduler
+	return &c
+}
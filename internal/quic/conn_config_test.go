@@ -0,0 +1,135 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestGetConfigForConn(t *testing.T) {
+	var gotHello *tls.ClientHelloInfo
+	var gotAddr netip.AddrPort
+	tc := newTestConn(t, serverSide, func(c *Config) {
+		connConfig := *c
+		connConfig.GetConfigForConn = nil
+		connConfig.MaxBidiRemoteStreams = 5
+		connConfig.MaxConnReadBufferSize = 64 << 10
+		c.GetConfigForConn = func(hello *tls.ClientHelloInfo, remoteAddr netip.AddrPort) (*Config, error) {
+			gotHello = hello
+			gotAddr = remoteAddr
+			return &connConfig, nil
+		}
+	})
+	tc.handshake()
+	if gotHello == nil {
+		t.Fatalf("GetConfigForConn was not called")
+	}
+	if gotAddr != tc.conn.peerAddr {
+		t.Errorf("GetConfigForConn called with remote address %v, want %v", gotAddr, tc.conn.peerAddr)
+	}
+	p := tc.sentTransportParameters
+	if got, want := p.initialMaxStreamsBidi, int64(5); got != want {
+		t.Errorf("initial_max_streams_bidi = %v, want %v", got, want)
+	}
+	if got, want := p.initialMaxData, int64(64<<10); got != want {
+		t.Errorf("initial_max_data = %v, want %v", got, want)
+	}
+}
+
+func TestGetConfigForConnKeepsListenerFields(t *testing.T) {
+	clock := systemClock{}
+	tc := newTestConn(t, serverSide, func(c *Config) {
+		c.Clock = clock
+		c.HalfRTTData = true
+		c.ClockJumpThreshold = 5 * time.Second
+		c.GetConfigForConn = func(hello *tls.ClientHelloInfo, remoteAddr netip.AddrPort) (*Config, error) {
+			// A per-conn Config which sets only a stream limit.
+			return &Config{MaxBidiRemoteStreams: 5}, nil
+		}
+	})
+	tc.handshake()
+	config := tc.conn.config
+	if config.Clock != clock {
+		t.Errorf("conn Clock = %v, want Listener's Clock", config.Clock)
+	}
+	if !config.HalfRTTData {
+		t.Errorf("conn HalfRTTData = false, want Listener's value (true)")
+	}
+	if got, want := config.ClockJumpThreshold, 5*time.Second; got != want {
+		t.Errorf("conn ClockJumpThreshold = %v, want %v", got, want)
+	}
+	if got, want := config.MaxBidiRemoteStreams, int64(5); got != want {
+		t.Errorf("conn MaxBidiRemoteStreams = %v, want %v (from per-conn Config)", got, want)
+	}
+	if got, want := tc.sentTransportParameters.initialMaxStreamsBidi, int64(5); got != want {
+		t.Errorf("initial_max_streams_bidi = %v, want %v", got, want)
+	}
+}
+
+func TestGetConfigForConnNil(t *testing.T) {
+	called := false
+	tc := newTestConn(t, serverSide, func(c *Config) {
+		c.MaxBidiRemoteStreams = 7
+		c.GetConfigForConn = func(hello *tls.ClientHelloInfo, remoteAddr netip.AddrPort) (*Config, error) {
+			called = true
+			return nil, nil
+		}
+	})
+	tc.handshake()
+	if !called {
+		t.Fatalf("GetConfigForConn was not called")
+	}
+	if got, want := tc.sentTransportParameters.initialMaxStreamsBidi, int64(7); got != want {
+		t.Errorf("initial_max_streams_bidi = %v, want %v (from Listener's Config)", got, want)
+	}
+}
+
+func TestGetConfigForConnError(t *testing.T) {
+	ctx := context.Background()
+	srv := newLocalListener(t, serverSide, &Config{
+		GetConfigForConn: func(hello *tls.ClientHelloInfo, remoteAddr netip.AddrPort) (*Config, error) {
+			return nil, errors.New("refusing connection")
+		},
+	})
+	cli := newLocalListener(t, clientSide, &Config{})
+	if _, err := cli.Dial(ctx, "udp", srv.LocalAddr().String()); err == nil {
+		t.Fatalf("Dial succeeded, want error from GetConfigForConn")
+	}
+}
+
+func TestGetConfigForConnTLSConfig(t *testing.T) {
+	ctx := context.Background()
+	verified := false
+	srv := newLocalListener(t, serverSide, &Config{
+		GetConfigForConn: func(hello *tls.ClientHelloInfo, remoteAddr netip.AddrPort) (*Config, error) {
+			tlsConfig := newTestTLSConfig(serverSide)
+			tlsConfig.GetConfigForClient = nil
+			tlsConfig.VerifyConnection = func(cs tls.ConnectionState) error {
+				verified = true
+				return nil
+			}
+			return &Config{TLSConfig: tlsConfig}, nil
+		},
+	})
+	cli := newLocalListener(t, clientSide, &Config{})
+	c, err := cli.Dial(ctx, "udp", srv.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Abort(nil)
+	if _, err := srv.Accept(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !verified {
+		t.Errorf("TLS config returned by GetConfigForConn was not used")
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Unreliable datagrams.
+// https://www.rfc-editor.org/rfc/rfc9221
+
+const (
+	// maxDatagramPayloadSize is the largest datagram we will send.
+	// A datagram must fit in a single packet alongside an ACK frame.
+	// Packets are at most 1200 bytes. A 1-RTT packet header is at most
+	// 25 bytes, the AEAD tag is 16 bytes, and the DATAGRAM frame header
+	// is 3 bytes. We reserve the remaining 56 bytes for ACKs.
+	maxDatagramPayloadSize = 1100
+
+	// maxQueuedDatagrams is the number of received datagrams we buffer.
+	// Datagrams received while the buffer is full are dropped.
+	maxQueuedDatagrams = 128
+)
+
+var (
+	errDatagramsUnsupported = errors.New("DATAGRAM frames not supported")
+	errDatagramTooLarge     = errors.New("datagram too large")
+)
+
+type datagramsState struct {
+	recv queue[[]byte] // received datagrams
+
+	// peerMaxFrameSize is the peer's max_datagram_frame_size transport parameter.
+	// It is zero until the peer's transport parameters have been received,
+	// or if the peer does not support DATAGRAM frames.
+	peerMaxFrameSize atomic.Int64
+
+	needSend atomic.Bool
+	sendMu   sync.Mutex
+	send     [][]byte // datagrams waiting to be sent
+}
+
+func (c *Conn) datagramsInit() {
+	c.datagrams.recv = newQueue[[]byte]()
+}
+
+// MaxDatagramSize returns the size of the largest datagram which may be
+// sent with SendDatagram.
+// It returns zero if the peer does not support DATAGRAM frames.
+func (c *Conn) MaxDatagramSize() int {
+	frameSize := c.datagrams.peerMaxFrameSize.Load()
+	if frameSize == 0 {
+		return 0
+	}
+	// The frame size includes the frame type (1 byte) and length (2 bytes).
+	// A peer limit too small to hold those leaves no room for a payload.
+	return int(max(0, min(frameSize-3, maxDatagramPayloadSize)))
+}
+
+// SendDatagram sends an unreliable datagram to the peer.
+//
+// SendDatagram does not block. Datagrams are queued for sending and
+// may be lost in transit; they are not retransmitted.
+// It returns an error if the peer does not support DATAGRAM frames
+// or if b is larger than MaxDatagramSize.
+func (c *Conn) SendDatagram(b []byte) error {
+	if c.config.maxDatagramFrameSize() == 0 {
+		return errDatagramsUnsupported
+	}
+	maxSize := c.MaxDatagramSize()
+	if maxSize == 0 {
+		return errDatagramsUnsupported
+	}
+	if len(b) > maxSize {
+		return errDatagramTooLarge
+	}
+	select {
+	case <-c.lifetime.drainingc:
+		return c.lifetime.finalErr
+	default:
+	}
+	c.datagrams.sendMu.Lock()
+	c.datagrams.send = append(c.datagrams.send, append([]byte(nil), b...))
+	c.datagrams.sendMu.Unlock()
+	c.datagrams.needSend.Store(true)
+	c.wake()
+	return nil
+}
+
+// ReceiveDatagram waits for and returns the next datagram sent by the peer.
+// It returns an error if the Config does not enable DATAGRAM frames.
+func (c *Conn) ReceiveDatagram(ctx context.Context) ([]byte, error) {
+	if c.config.maxDatagramFrameSize() == 0 {
+		return nil, errDatagramsUnsupported
+	}
+	return c.datagrams.recv.get(ctx, c.testHooks)
+}
+
+func (c *Conn) handleDatagramFrame(now time.Time, payload []byte) int {
+	data, n := consumeDatagramFrame(payload)
+	if n < 0 {
+		return -1
+	}
+	if int64(n) > c.config.maxDatagramFrameSize() {
+		// "An endpoint MUST treat receipt of a DATAGRAM frame whose size exceeds
+		// [max_datagram_frame_size] as a connection error of type PROTOCOL_VIOLATION."
+		// The limit is zero when we do not support DATAGRAM frames.
+		// https://www.rfc-editor.org/rfc/rfc9221#section-3-4
+		c.abort(now, localTransportError(errProtocolViolation))
+		return -1
+	}
+	if c.datagrams.recv.len() < maxQueuedDatagrams {
+		c.datagrams.recv.put(append([]byte(nil), data...))
+	}
+	return n
+}
+
+// appendDatagramFrames appends DATAGRAM frames for queued datagrams.
+// It returns true if no more frames need appending,
+// false if not everything fit in the current packet.
+func (c *Conn) appendDatagramFrames() bool {
+	if !c.datagrams.needSend.Load() {
+		return true
+	}
+	c.datagrams.sendMu.Lock()
+	defer c.datagrams.sendMu.Unlock()
+	for len(c.datagrams.send) > 0 {
+		if !c.w.appendDatagramFrame(c.datagrams.send[0]) {
+			return false
+		}
+		c.datagrams.send[0] = nil
+		c.datagrams.send = c.datagrams.send[1:]
+	}
+	c.datagrams.send = nil
+	c.datagrams.needSend.Store(false)
+	return true
+}
@@ -0,0 +1,144 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func newDatagramTestConn(t *testing.T, side connSide, localMax, peerMax int64) *testConn {
+	t.Helper()
+	tc := newTestConn(t, side, func(c *Config) {
+		c.MaxDatagramFrameSize = localMax
+	}, func(p *transportParameters) {
+		p.maxDatagramFrameSize = peerMax
+	})
+	tc.handshake()
+	tc.ignoreFrame(frameTypeAck)
+	return tc
+}
+
+func TestDatagramSend(t *testing.T) {
+	tc := newDatagramTestConn(t, clientSide, 65535, 65535)
+	want := []byte("hello")
+	if err := tc.conn.SendDatagram(want); err != nil {
+		t.Fatalf("SendDatagram: %v", err)
+	}
+	tc.wantFrame("SendDatagram sends a DATAGRAM frame",
+		packetType1RTT, debugFrameDatagram{
+			data: want,
+		})
+
+	// DATAGRAM frames are not retransmitted.
+	tc.triggerLossOrPTO(packetType1RTT, false)
+	tc.wantIdle("lost DATAGRAM frame is not retransmitted")
+}
+
+func TestDatagramReceive(t *testing.T) {
+	tc := newDatagramTestConn(t, serverSide, 65535, 65535)
+	want := []byte("hello")
+	tc.writeFrames(packetType1RTT, debugFrameDatagram{
+		data: want,
+	})
+	got, err := tc.conn.ReceiveDatagram(context.Background())
+	if err != nil {
+		t.Fatalf("ReceiveDatagram: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("ReceiveDatagram = %q, want %q", got, want)
+	}
+}
+
+func TestDatagramReceiveQueueFull(t *testing.T) {
+	tc := newDatagramTestConn(t, serverSide, 65535, 65535)
+	for i := 0; i < maxQueuedDatagrams+1; i++ {
+		tc.writeFrames(packetType1RTT, debugFrameDatagram{
+			data: []byte{byte(i)},
+		})
+	}
+	if got, want := tc.conn.datagrams.recv.len(), maxQueuedDatagrams; got != want {
+		t.Fatalf("after receiving %v datagrams: %v queued, want %v", maxQueuedDatagrams+1, got, want)
+	}
+}
+
+func TestDatagramMaxDatagramSize(t *testing.T) {
+	for _, test := range []struct {
+		peerMax int64
+		want    int
+	}{
+		{peerMax: 0, want: 0},
+		{peerMax: 1, want: 0},
+		{peerMax: 3, want: 0},
+		{peerMax: 103, want: 100},
+		{peerMax: 65535, want: maxDatagramPayloadSize},
+	} {
+		tc := newDatagramTestConn(t, clientSide, 65535, test.peerMax)
+		if got := tc.conn.MaxDatagramSize(); got != test.want {
+			t.Errorf("peer max_datagram_frame_size = %v: MaxDatagramSize() = %v, want %v", test.peerMax, got, test.want)
+		}
+	}
+}
+
+func TestDatagramSendErrors(t *testing.T) {
+	for _, test := range []struct {
+		name              string
+		localMax, peerMax int64
+		size              int
+	}{{
+		name:     "local endpoint does not support datagrams",
+		localMax: 0,
+		peerMax:  65535,
+		size:     1,
+	}, {
+		name:     "peer does not support datagrams",
+		localMax: 65535,
+		peerMax:  0,
+		size:     1,
+	}, {
+		name:     "datagram too large",
+		localMax: 65535,
+		peerMax:  103,
+		size:     101,
+	}} {
+		t.Run(test.name, func(t *testing.T) {
+			tc := newDatagramTestConn(t, clientSide, test.localMax, test.peerMax)
+			if err := tc.conn.SendDatagram(make([]byte, test.size)); err == nil {
+				t.Fatalf("SendDatagram: succeeded, want error")
+			}
+			tc.wantIdle("no DATAGRAM frame sent")
+		})
+	}
+}
+
+func TestDatagramReceiveErrors(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		localMax int64
+		size     int
+	}{{
+		name:     "local endpoint does not support datagrams",
+		localMax: 0,
+		size:     1,
+	}, {
+		name:     "frame too large",
+		localMax: 100,
+		size:     98, // 1 byte type + 2 byte length + 98 bytes data
+	}} {
+		t.Run(test.name, func(t *testing.T) {
+			tc := newDatagramTestConn(t, serverSide, test.localMax, 65535)
+			tc.writeFrames(packetType1RTT, debugFrameDatagram{
+				data: make([]byte, test.size),
+			})
+			tc.wantFrame("invalid DATAGRAM frame causes connection close",
+				packetType1RTT, debugFrameConnectionCloseTransport{
+					code: errProtocolViolation,
+				})
+		})
+	}
+}
@@ -33,13 +33,22 @@ type connInflow struct {
 	sentLimit int64   // last MAX_DATA sent to the peer
 	newLimit  int64   // new MAX_DATA to send
 
+	tuner windowTuner  // sizes the flow-control window
+	size  atomic.Int64 // current window size, set from tuner.size
+
 	credit atomic.Int64 // bytes read but not yet applied to extending the flow-control window
 }
 
 func (c *Conn) inflowInit() {
 	// The initial MAX_DATA limit is sent as a transport parameter.
-	c.streams.inflow.sentLimit = c.config.maxConnReadBufferSize()
+	c.streams.inflow.sentLimit = c.config.initialConnReadWindow()
 	c.streams.inflow.newLimit = c.streams.inflow.sentLimit
+	c.streams.inflow.tuner = c.config.newWindowTuner(c.streams.inflow.sentLimit, c.config.maxConnReadBufferSize())
+	c.streams.inflow.size.Store(c.streams.inflow.tuner.size)
+	// If the initial window is smaller than the window size,
+	// start with credit for the difference so that the window
+	// grows to the full size with the first update.
+	c.streams.inflow.credit.Store(c.streams.inflow.tuner.size - c.streams.inflow.sentLimit)
 }
 
 // handleStreamBytesReadOffLoop records that the user has consumed bytes from a stream.
@@ -83,7 +92,30 @@ func (c *Conn) sendMaxDataUpdate() {
 }
 
 func (c *Conn) shouldUpdateFlowControl(credit int64) bool {
-	return shouldUpdateFlowControl(c.config.maxConnReadBufferSize(), credit)
+	return shouldUpdateFlowControl(c.streams.inflow.size.Load(), credit)
+}
+
+// tuneInflowWindow adjusts the size of the connection flow-control window
+// before sending a MAX_DATA frame.
+func (c *Conn) tuneInflowWindow(now time.Time) {
+	f := &c.streams.inflow
+	// newLimit is always the bytes read plus the window size.
+	read := f.newLimit - f.tuner.size
+	if grow := f.tuner.update(now, read, c.loss.rtt.smoothedRTT); grow > 0 {
+		f.newLimit += grow
+		f.size.Store(f.tuner.size)
+	}
+}
+
+// growInflowWindow increases the size of the connection flow-control window
+// to at least size, when a stream window grows.
+func (c *Conn) growInflowWindow(size int64) {
+	f := &c.streams.inflow
+	if grow := f.tuner.growTo(size); grow > 0 {
+		f.newLimit += grow
+		f.size.Store(f.tuner.size)
+		f.sent.setUnsent()
+	}
 }
 
 // handleStreamBytesReceived records that the peer has sent us stream data.
@@ -99,14 +131,17 @@ func (c *Conn) handleStreamBytesReceived(n int64) error {
 //
 // It returns true if no more frames need appending,
 // false if it could not fit a frame in the current packet.
-func (c *Conn) appendMaxDataFrame(w *packetWriter, pnum packetNumber, pto bool) bool {
+func (c *Conn) appendMaxDataFrame(now time.Time, w *packetWriter, pnum packetNumber, pto bool) bool {
 	if c.streams.inflow.sent.shouldSendPTO(pto) {
 		// Add any unapplied credit to the new limit now.
 		c.streams.inflow.newLimit += c.streams.inflow.credit.Swap(0)
+		if !pto {
+			c.tuneInflowWindow(now)
+		}
 		if !w.appendMaxDataFrame(c.streams.inflow.newLimit) {
 			return false
 		}
-		c.streams.inflow.sentLimit += c.streams.inflow.newLimit
+		c.streams.inflow.sentLimit = c.streams.inflow.newLimit
 		c.streams.inflow.sent.setSent(pnum)
 	}
 	return true
@@ -47,6 +47,28 @@ func TestConnInflowReturnOnRead(t *testing.T) {
 	}
 }
 
+func TestConnInflowGrowsInitialWindow(t *testing.T) {
+	ctx := canceledContext()
+	tc, s := newTestConnAndRemoteStream(t, serverSide, uniStream, func(c *Config) {
+		c.InitialConnReadWindow = 16
+		c.MaxConnReadBufferSize = 64
+	})
+	if got, want := tc.sentTransportParameters.initialMaxData, int64(16); got != want {
+		t.Errorf("initial_max_data = %v, want %v", got, want)
+	}
+	tc.writeFrames(packetType1RTT, debugFrameStream{
+		id:   s.id,
+		data: make([]byte, 16),
+	})
+	if n, err := s.ReadContext(ctx, make([]byte, 1)); n != 1 || err != nil {
+		t.Fatalf("s.Read() = %v, %v; want 1, nil", n, err)
+	}
+	tc.wantFrame("window grows to the maximum after reading data",
+		packetType1RTT, debugFrameMaxData{
+			max: 64 + 1,
+		})
+}
+
 func TestConnInflowReturnOnRacingReads(t *testing.T) {
 	// Perform two reads at the same time,
 	// one for half of MaxConnReadBufferSize
@@ -175,6 +197,35 @@ func TestConnInflowStreamViolation(t *testing.T) {
 		})
 }
 
+func TestConnInflowViolationAfterMaxData(t *testing.T) {
+	// The peer's limit is the value of the last MAX_DATA frame sent,
+	// not the sum of all values sent.
+	ctx := canceledContext()
+	tc, s := newTestConnAndRemoteStream(t, serverSide, uniStream, func(c *Config) {
+		c.MaxConnReadBufferSize = 64
+	})
+	tc.writeFrames(packetType1RTT, debugFrameStream{
+		id:   s.id,
+		data: make([]byte, 64),
+	})
+	if n, err := s.ReadContext(ctx, make([]byte, 64)); n != 64 || err != nil {
+		t.Fatalf("s.Read() = %v, %v; want 64, nil", n, err)
+	}
+	tc.wantFrame("available window increases, send a MAX_DATA",
+		packetType1RTT, debugFrameMaxData{
+			max: 128,
+		})
+	tc.writeFrames(packetType1RTT, debugFrameStream{
+		id:   s.id,
+		off:  64,
+		data: make([]byte, 65),
+	})
+	tc.wantFrame("peer violates updated MAX_DATA limit",
+		packetType1RTT, debugFrameConnectionCloseTransport{
+			code: errFlowControl,
+		})
+}
+
 func TestConnInflowResetViolation(t *testing.T) {
 	tc := newTestConn(t, serverSide, func(c *Config) {
 		c.MaxConnReadBufferSize = 100
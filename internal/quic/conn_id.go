@@ -76,9 +76,7 @@ func (s *connIDState) initClient(c *Conn) error {
 		cid: locid,
 	})
 	s.nextLocalSeq = 1
-	c.listener.connsMap.updateConnIDs(func(conns *connsMap) {
-		conns.addConnID(c, locid)
-	})
+	c.listener.connsMap.addConnID(c, locid)
 
 	// Client chooses an initial, transient connection ID for the server,
 	// and sends it in the Destination Connection ID field of the first Initial packet.
@@ -117,10 +115,8 @@ func (s *connIDState) initServer(c *Conn, dstConnID []byte) error {
 		cid: locid,
 	})
 	s.nextLocalSeq = 1
-	c.listener.connsMap.updateConnIDs(func(conns *connsMap) {
-		conns.addConnID(c, dstConnID)
-		conns.addConnID(c, locid)
-	})
+	c.listener.connsMap.addConnID(c, dstConnID)
+	c.listener.connsMap.addConnID(c, locid)
 	return nil
 }
 
@@ -186,11 +182,9 @@ func (s *connIDState) issueLocalIDs(c *Conn) error {
 		s.needSend = true
 		toIssue--
 	}
-	c.listener.connsMap.updateConnIDs(func(conns *connsMap) {
-		for _, cid := range newIDs {
-			conns.addConnID(c, cid)
-		}
-	})
+	for _, cid := range newIDs {
+		c.listener.connsMap.addConnID(c, cid)
+	}
 	return nil
 }
 
@@ -224,9 +218,7 @@ func (s *connIDState) validateTransportParameters(c *Conn, isRetry bool, p trans
 		}
 		token := statelessResetToken(p.statelessResetToken)
 		s.remote[0].resetToken = token
-		c.listener.connsMap.updateConnIDs(func(conns *connsMap) {
-			conns.addResetToken(c, token)
-		})
+		c.listener.connsMap.addResetToken(c, token)
 	}
 	return nil
 }
@@ -264,9 +256,7 @@ func (s *connIDState) handlePacket(c *Conn, ptype packetType, srcConnID []byte)
 			// the client. Discard the transient, client-chosen connection ID used
 			// for Initial packets; the client will never send it again.
 			cid := s.local[0].cid
-			c.listener.connsMap.updateConnIDs(func(conns *connsMap) {
-				conns.retireConnID(c, cid)
-			})
+			c.listener.connsMap.retireConnID(c, cid)
 			s.local = append(s.local[:0], s.local[1:]...)
 		}
 	}
@@ -299,9 +289,7 @@ func (s *connIDState) handleNewConnID(c *Conn, seq, retire int64, cid []byte, re
 		rcid := &s.remote[i]
 		if !rcid.retired && rcid.seq >= 0 && rcid.seq < s.retireRemotePriorTo {
 			s.retireRemote(rcid)
-			c.listener.connsMap.updateConnIDs(func(conns *connsMap) {
-				conns.retireResetToken(c, rcid.resetToken)
-			})
+			c.listener.connsMap.retireResetToken(c, rcid.resetToken)
 		}
 		if !rcid.retired {
 			active++
@@ -332,9 +320,7 @@ func (s *connIDState) handleNewConnID(c *Conn, seq, retire int64, cid []byte, re
 			s.retireRemote(&s.remote[len(s.remote)-1])
 		} else {
 			active++
-			c.listener.connsMap.updateConnIDs(func(conns *connsMap) {
-				conns.addResetToken(c, resetToken)
-			})
+			c.listener.connsMap.addResetToken(c, resetToken)
 		}
 	}
 
@@ -372,9 +358,7 @@ func (s *connIDState) handleRetireConnID(c *Conn, seq int64) error {
 	for i := range s.local {
 		if s.local[i].seq == seq {
 			cid := s.local[i].cid
-			c.listener.connsMap.updateConnIDs(func(conns *connsMap) {
-				conns.retireConnID(c, cid)
-			})
+			c.listener.connsMap.retireConnID(c, cid)
 			s.local = append(s.local[:i], s.local[i+1:]...)
 			break
 		}
@@ -365,6 +365,36 @@ func TestConnIDPeerDoesNotAcknowledgeRetirement(t *testing.T) {
 		})
 }
 
+func TestConnIDRetirePriorToStorm(t *testing.T) {
+	// A peer which acknowledges our RETIRE_CONNECTION_ID frames
+	// may rotate through connection IDs indefinitely,
+	// and we retain state only for the IDs it has not yet retired.
+	tc := newTestConn(t, clientSide)
+	tc.handshake()
+	tc.ignoreFrame(frameTypeAck)
+
+	for seq := int64(2); seq < 200; seq++ {
+		tc.writeFrames(packetType1RTT,
+			debugFrameNewConnectionID{
+				seq:           seq,
+				retirePriorTo: seq - 1,
+				connID:        testPeerConnID(seq),
+			})
+		tc.wantFrame("peer asked for prior conn id to be retired",
+			packetType1RTT, debugFrameRetireConnectionID{
+				seq: seq - 2,
+			})
+		if got, want := tc.lastPacket.dstConnID, testPeerConnID(seq-1); !bytes.Equal(got, want) {
+			t.Fatalf("used destination conn id {%x}, want {%x}", got, want)
+		}
+		tc.writeAckForAll()
+	}
+	if got, want := len(tc.conn.connIDState.remote), 2; got > want {
+		t.Errorf("conn retains state for %v remote conn ids, want at most %v", got, want)
+	}
+	tc.wantIdle("conn remains open")
+}
+
 func TestConnIDRepeatedNewConnectionIDFrame(t *testing.T) {
 	// "Receipt of the same [NEW_CONNECTION_ID] frame multiple times
 	// MUST NOT be treated as a connection error.
@@ -651,13 +681,19 @@ func TestConnIDsCleanedUpAfterClose(t *testing.T) {
 		// (normally only done by the listener read loop).
 		tc.advanceToTimer()
 		<-tc.conn.donec
-		tc.listener.l.connsMap.applyUpdates()
+		tc.listener.l.connsMap.applyAllUpdates()
 
-		if got := len(tc.listener.l.connsMap.byConnID); got != 0 {
-			t.Errorf("%v conn ids in listener map after closing, want 0", got)
+		var cids, tokens int
+		for i := range tc.listener.l.connsMap.shards {
+			s := &tc.listener.l.connsMap.shards[i]
+			cids += len(s.byConnID)
+			tokens += len(s.byResetToken)
+		}
+		if cids != 0 {
+			t.Errorf("%v conn ids in listener map after closing, want 0", cids)
 		}
-		if got := len(tc.listener.l.connsMap.byResetToken); got != 0 {
-			t.Errorf("%v reset tokens in listener map after closing, want 0", got)
+		if tokens != 0 {
+			t.Errorf("%v reset tokens in listener map after closing, want 0", tokens)
 		}
 	})
 }
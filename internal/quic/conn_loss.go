@@ -78,6 +78,8 @@ func (c *Conn) handleAckOrLoss(space numberSpace, sent *sentPacket, fate packetF
 			c.connIDState.ackOrLossRetireConnectionID(sent.num, seq, fate)
 		case frameTypeHandshakeDone:
 			c.handshakeConfirmed.ackOrLoss(sent.num, fate)
+		case frameTypeAckFrequency:
+			c.ackFreq.send.ackOrLoss(sent.num, fate)
 		}
 	}
 }
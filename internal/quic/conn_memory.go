@@ -0,0 +1,49 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+// minCryptoBufferSize is the amount of out-of-order CRYPTO data
+// an endpoint must be able to buffer.
+// https://www.rfc-editor.org/rfc/rfc9000#section-7.5-2
+const minCryptoBufferSize = 4096
+
+// connMemory limits the memory a connection uses to buffer data received from the peer.
+//
+// Config.MaxConnectionMemory is divided between stream data and CRYPTO data.
+// Stream data not yet read by the user (including out-of-order data awaiting reassembly)
+// is limited by the connection flow control window, which is never larger than
+// the stream portion of the limit.
+// CRYPTO data is not subject to flow control, so CRYPTO data not yet processed
+// is limited to the remaining portion, and a peer which sends more
+// is closed with a CRYPTO_BUFFER_EXCEEDED error.
+// A peer which respects flow control and the minimum CRYPTO buffer size
+// can therefore never cause the conn to exceed the limit.
+type connMemory struct {
+	limited     bool  // Config.MaxConnectionMemory is set
+	cryptoLimit int64 // maximum CRYPTO data buffered
+}
+
+func (c *Conn) memoryInit() {
+	c.mem.limited = c.config.MaxConnectionMemory > 0
+	c.mem.cryptoLimit = c.config.maxConnCryptoBufferSize()
+}
+
+// checkCryptoBuffered returns an error if the conn is buffering
+// more CRYPTO data than the memory limit permits.
+func (c *Conn) checkCryptoBuffered() error {
+	if !c.mem.limited {
+		return nil
+	}
+	var n int64
+	for i := range c.crypto {
+		n += c.crypto[i].in.end - c.crypto[i].in.start
+	}
+	if n > c.mem.cryptoLimit {
+		return localTransportError(errCryptoBufferExceeded)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"testing"
+)
+
+func TestConfigMaxConnCryptoBufferSize(t *testing.T) {
+	for _, test := range []struct {
+		limit, want int64
+	}{
+		{0, 0},
+		{64, 32},
+		{64 << 10, 4096},
+		{1 << 20, 64 << 10},
+	} {
+		c := &Config{MaxConnectionMemory: test.limit}
+		if got := c.maxConnCryptoBufferSize(); got != test.want {
+			t.Errorf("MaxConnectionMemory = %v: CRYPTO buffer size %v, want %v", test.limit, got, test.want)
+		}
+	}
+}
+
+func TestConnMemoryLimitsInitialWindow(t *testing.T) {
+	tc := newTestConn(t, serverSide, func(c *Config) {
+		c.MaxConnReadBufferSize = 1 << 20
+		c.MaxConnectionMemory = 64 << 10
+	})
+	tc.handshake()
+	if got, want := tc.sentTransportParameters.initialMaxData, int64(64<<10-4096); got != want {
+		t.Errorf("initial_max_data = %v, want %v", got, want)
+	}
+}
+
+func TestConnMemoryCompliantPeer(t *testing.T) {
+	// A peer which fills both the flow control window
+	// and the CRYPTO buffer stays within the memory limit.
+	tc, s := newTestConnAndRemoteStream(t, serverSide, uniStream, func(c *Config) {
+		c.MaxConnectionMemory = 64
+	})
+	tc.writeFrames(packetType1RTT, debugFrameCrypto{
+		off:  8,
+		data: make([]byte, 32-8),
+	})
+	tc.writeFrames(packetType1RTT, debugFrameStream{
+		id:   s.id,
+		data: make([]byte, 32),
+	})
+	tc.wantIdle("peer is within the memory limit")
+}
+
+func TestConnMemoryCryptoBufferExceeded(t *testing.T) {
+	tc, _ := newTestConnAndRemoteStream(t, serverSide, uniStream, func(c *Config) {
+		c.MaxConnectionMemory = 64
+	})
+	tc.writeFrames(packetType1RTT, debugFrameCrypto{
+		off:  8,
+		data: make([]byte, 32-8+1),
+	})
+	tc.wantFrame("CRYPTO data exceeds the reserved portion of the memory limit",
+		packetType1RTT, debugFrameConnectionCloseTransport{
+			code: errCryptoBufferExceeded,
+		})
+}
@@ -0,0 +1,232 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxPooledConnBacklog is the maximum number of events queued for a pooled conn
+// before further datagrams for the conn are dropped.
+// A conn running its own goroutine applies backpressure to the Listener instead.
+const maxPooledConnBacklog = 64
+
+// A connPool runs the event loops of a Listener's conns
+// on a fixed set of worker goroutines (shards),
+// rather than on a dedicated goroutine per conn.
+// See Config.ConnWorkers.
+type connPool struct {
+	shards []*connShard
+	next   atomic.Uint32 // next shard to assign a conn to
+}
+
+func newConnPool(workers int, closec <-chan struct{}) *connPool {
+	p := &connPool{}
+	for i := 0; i < workers; i++ {
+		s := &connShard{
+			wakec: make(chan struct{}, 1),
+		}
+		p.shards = append(p.shards, s)
+		go s.run(closec)
+	}
+	return p
+}
+
+// assign returns the shard to run a new conn on.
+func (p *connPool) assign() *connShard {
+	n := p.next.Add(1)
+	return p.shards[int(n)%len(p.shards)]
+}
+
+// A connShard is a worker goroutine which runs some number of conns.
+// Each conn is assigned to a single shard for its lifetime,
+// so a conn's events are always handled on one goroutine at a time.
+type connShard struct {
+	mu    sync.Mutex
+	ready []*Conn       // conns with events to handle
+	wakec chan struct{} // signaled when ready becomes non-empty
+}
+
+// schedule adds c to the set of conns with events to handle.
+func (s *connShard) schedule(c *Conn) {
+	s.mu.Lock()
+	s.ready = append(s.ready, c)
+	s.mu.Unlock()
+	select {
+	case s.wakec <- struct{}{}:
+	default:
+	}
+}
+
+// run is the shard's worker loop.
+// It exits when closec is closed, after the Listener has shut down.
+func (s *connShard) run(closec <-chan struct{}) {
+	var ready []*Conn
+	for {
+		s.mu.Lock()
+		ready, s.ready = s.ready, ready[:0]
+		s.mu.Unlock()
+		if len(ready) == 0 {
+			select {
+			case <-s.wakec:
+			case <-closec:
+				return
+			}
+			continue
+		}
+		for i, c := range ready {
+			c.runPooled()
+			ready[i] = nil
+		}
+	}
+}
+
+// pooledConnState is the state of a conn run by a connShard.
+type pooledConnState struct {
+	shard *connShard // nil if the conn runs its own goroutine
+
+	mu        sync.Mutex
+	msgs      []any // events waiting to be handled
+	scheduled bool  // conn is waiting in shard.ready or running
+	done      bool  // conn has exited
+
+	// Owned by the shard's worker goroutine.
+	timer       ClockTimer
+	lastTimeout time.Time
+
+	// expect is the time at which the conn next expects to run,
+	// for detecting clock jumps. See Conn.clockNow.
+	expect time.Time
+}
+
+// post queues a message for a pooled conn.
+func (c *Conn) post(m any) {
+	p := &c.pool
+	p.mu.Lock()
+	if p.done {
+		p.mu.Unlock()
+		if d, ok := m.(*datagram); ok {
+			d.recycle()
+		}
+		return
+	}
+	switch m := m.(type) {
+	case *datagram:
+		if len(p.msgs) >= maxPooledConnBacklog {
+			p.mu.Unlock()
+			c.listener.stats.datagramsDropped.Add(1)
+			m.recycle()
+			return
+		}
+	case wakeEvent:
+		if len(p.msgs) > 0 {
+			// The conn will try sending after handling the pending events.
+			p.mu.Unlock()
+			return
+		}
+	}
+	p.msgs = append(p.msgs, m)
+	schedule := !p.scheduled
+	p.scheduled = true
+	p.mu.Unlock()
+	if schedule {
+		p.shard.schedule(c)
+	}
+}
+
+// runPooled handles a pooled conn's pending events and sends any packets it can.
+// It is the equivalent of one or more iterations of Conn.loop,
+// and is only called from the conn's shard.
+func (c *Conn) runPooled() {
+	p := &c.pool
+	p.mu.Lock()
+	msgs := p.msgs
+	p.msgs = nil
+	p.mu.Unlock()
+
+	clock := c.config.clock()
+	now := c.clockNow(clock.Now(), p.expect)
+	for _, m := range msgs {
+		if c.exited {
+			if d, ok := m.(*datagram); ok {
+				d.recycle()
+			}
+			continue
+		}
+		if _, ok := m.(timerEvent); ok {
+			// The timer has fired, and must be set again
+			// even if the conn's next timeout is unchanged.
+			p.lastTimeout = time.Time{}
+		}
+		if c.handleEvent(now, m) {
+			c.exited = true
+		}
+	}
+
+	var (
+		nextTimeout time.Time
+		sendMore    bool
+	)
+	for !c.exited {
+		var sendTimeout time.Time
+		sendTimeout, sendMore = c.maybeSend(now)
+		nextTimeout = firstTime(sendTimeout, c.nextTimeout())
+		if nextTimeout.IsZero() || !nextTimeout.Before(now) {
+			break
+		}
+		// A connection timer has expired.
+		now = c.clockNow(clock.Now(), now)
+		if c.handleEvent(now, timerEvent{}) {
+			c.exited = true
+		}
+	}
+
+	p.mu.Lock()
+	if c.exited {
+		p.done = true
+		msgs = p.msgs
+		p.msgs = nil
+	}
+	// If more events arrived while we were running, or we stopped before
+	// running out of data to send, go to the back of the shard's queue
+	// so other conns get a turn.
+	more := !c.exited && (len(p.msgs) > 0 || sendMore)
+	p.scheduled = more
+	p.mu.Unlock()
+
+	if c.exited {
+		for _, m := range msgs {
+			if d, ok := m.(*datagram); ok {
+				d.recycle()
+			}
+		}
+		if p.timer != nil {
+			p.timer.Stop()
+		}
+		c.loopExited(now)
+		return
+	}
+	if more {
+		p.expect = now
+		p.shard.schedule(c)
+		return
+	}
+	p.expect = nextTimeout
+	if !nextTimeout.Equal(p.lastTimeout) && !nextTimeout.IsZero() {
+		if p.timer == nil {
+			p.timer = clock.AfterFunc(nextTimeout.Sub(now), func() {
+				c.post(timerEvent{})
+			})
+		} else {
+			// As in Conn.loop, a spurious timer event is harmless.
+			p.timer.Reset(nextTimeout.Sub(now))
+		}
+		p.lastTimeout = nextTimeout
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestConnWorkersStreamTransfer(t *testing.T) {
+	ctx := context.Background()
+	cli, srv := newLocalConnPair(t, &Config{ConnWorkers: 1}, &Config{ConnWorkers: 1})
+	if cli.pool.shard == nil || srv.pool.shard == nil {
+		t.Fatalf("conns are not pooled with ConnWorkers set")
+	}
+	data := makeTestData(1 << 20)
+
+	srvdone := make(chan struct{})
+	go func() {
+		defer close(srvdone)
+		s, err := srv.AcceptStream(ctx)
+		if err != nil {
+			t.Errorf("AcceptStream: %v", err)
+			return
+		}
+		b, err := io.ReadAll(s)
+		if err != nil {
+			t.Errorf("io.ReadAll(s): %v", err)
+			return
+		}
+		if !bytes.Equal(b, data) {
+			t.Errorf("read data mismatch (got %v bytes, want %v", len(b), len(data))
+		}
+		if err := s.Close(); err != nil {
+			t.Errorf("s.Close() = %v", err)
+		}
+	}()
+
+	s, err := cli.NewStream(ctx)
+	if err != nil {
+		t.Fatalf("NewStream: %v", err)
+	}
+	n, err := io.Copy(s, bytes.NewBuffer(data))
+	if n != int64(len(data)) || err != nil {
+		t.Fatalf("io.Copy(s, data) = %v, %v; want %v, nil", n, err, len(data))
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("s.Close() = %v", err)
+	}
+	<-srvdone
+}
+
+func TestConnWorkersManyConns(t *testing.T) {
+	ctx := context.Background()
+	const (
+		workers = 2
+		conns   = 50
+	)
+	srv := newLocalListener(t, serverSide, &Config{ConnWorkers: workers})
+	cli := newLocalListener(t, clientSide, &Config{ConnWorkers: workers})
+
+	before := runtime.NumGoroutine()
+	var clientConns, serverConns []*Conn
+	for i := 0; i < conns; i++ {
+		c, err := cli.Dial(ctx, "udp", srv.LocalAddr().String())
+		if err != nil {
+			t.Fatalf("Dial: %v", err)
+		}
+		clientConns = append(clientConns, c)
+		s, err := srv.Accept(ctx)
+		if err != nil {
+			t.Fatalf("Accept: %v", err)
+		}
+		serverConns = append(serverConns, s)
+	}
+	// Each side has two listener conns, so without pooling
+	// we'd have at least 2*conns additional goroutines.
+	if got := runtime.NumGoroutine() - before; got >= conns {
+		t.Errorf("%v pooled conns started %v goroutines, want fewer than %v", 2*conns, got, conns)
+	}
+
+	for i, c := range clientConns {
+		s, err := c.NewSendOnlyStream(ctx)
+		if err != nil {
+			t.Fatalf("NewSendOnlyStream: %v", err)
+		}
+		s.Write([]byte{byte(i)})
+		s.Close()
+	}
+	for i, c := range serverConns {
+		s, err := c.AcceptStream(ctx)
+		if err != nil {
+			t.Fatalf("AcceptStream: %v", err)
+		}
+		b, err := io.ReadAll(s)
+		if err != nil || !bytes.Equal(b, []byte{byte(i)}) {
+			t.Fatalf("conn %v: read %v, %v; want [%v], nil", i, b, err, i)
+		}
+	}
+}
+
+func TestConnWorkersIdleTimeout(t *testing.T) {
+	cli, _ := newLocalConnPair(t, &Config{
+		ConnWorkers:    1,
+		MaxIdleTimeout: 10 * time.Millisecond,
+	}, &Config{
+		ConnWorkers:    1,
+		MaxIdleTimeout: 10 * time.Millisecond,
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := cli.Wait(ctx); !errors.Is(err, IdleTimeoutError{}) {
+		t.Fatalf("conn.Wait() = %v, want IdleTimeoutError", err)
+	}
+}
+
+// earlyClock is a Clock whose timers fire a millisecond before their deadline.
+type earlyClock struct{}
+
+func (earlyClock) Now() time.Time { return time.Now() }
+
+func (earlyClock) AfterFunc(d time.Duration, f func()) ClockTimer {
+	return earlyTimer{time.AfterFunc(d-time.Millisecond, f)}
+}
+
+type earlyTimer struct {
+	t *time.Timer
+}
+
+func (t earlyTimer) Reset(d time.Duration) bool { return t.t.Reset(d - time.Millisecond) }
+func (t earlyTimer) Stop() bool                 { return t.t.Stop() }
+
+func TestConnWorkersEarlyTimer(t *testing.T) {
+	// A timer which fires before the conn's deadline leaves the deadline unchanged.
+	// The conn must set a new timer for it.
+	cli, _ := newLocalConnPair(t, &Config{
+		ConnWorkers:    1,
+		MaxIdleTimeout: 10 * time.Millisecond,
+		Clock:          earlyClock{},
+	}, &Config{
+		ConnWorkers:    1,
+		MaxIdleTimeout: 10 * time.Millisecond,
+		Clock:          earlyClock{},
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := cli.Wait(ctx); !errors.Is(err, IdleTimeoutError{}) {
+		t.Fatalf("conn.Wait() = %v, want IdleTimeoutError", err)
+	}
+}
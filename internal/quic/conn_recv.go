@@ -10,6 +10,7 @@ import (
 	"bytes"
 	"encoding/binary"
 	"errors"
+	"slices"
 	"time"
 )
 
@@ -27,6 +28,7 @@ func (c *Conn) handleDatagram(now time.Time, dgram *datagram) {
 			if c.side == serverSide && len(dgram.b) < paddedInitialDatagramSize {
 				// Discard client-sent Initial packets in too-short datagrams.
 				// https://www.rfc-editor.org/rfc/rfc9000#section-14.1-4
+				c.traceDroppedPacket(now, ptype, -1, len(buf), TraceDropInvalid)
 				return
 			}
 			n = c.handleLongHeader(now, ptype, initialSpace, c.keysInitial.r, buf)
@@ -41,6 +43,7 @@ func (c *Conn) handleDatagram(now time.Time, dgram *datagram) {
 			c.handleVersionNegotiation(now, buf)
 			return
 		default:
+			c.traceDroppedPacket(now, ptype, -1, len(buf), TraceDropInvalid)
 			n = -1
 		}
 		if n <= 0 {
@@ -56,24 +59,31 @@ func (c *Conn) handleDatagram(now time.Time, dgram *datagram) {
 			if len(buf) == len(dgram.b) && len(buf) > statelessResetTokenLen {
 				var token statelessResetToken
 				copy(token[:], buf[len(buf)-len(token):])
-				c.handleStatelessReset(token)
+				c.handleStatelessReset(now, token, len(buf))
 			}
 			// Invalid data at the end of a datagram is ignored.
 			break
 		}
-		c.idleTimeout = now.Add(c.maxIdleTimeout)
+		c.idleHandlePacketReceived(now)
 		buf = buf[n:]
 	}
 }
 
 func (c *Conn) handleLongHeader(now time.Time, ptype packetType, space numberSpace, k fixedKeys, buf []byte) int {
 	if !k.isSet() {
-		return skipLongHeaderPacket(buf)
+		n := skipLongHeaderPacket(buf)
+		size := n
+		if size < 0 {
+			size = len(buf)
+		}
+		c.traceDroppedPacket(now, ptype, -1, size, TraceDropKeysUnavailable)
+		return n
 	}
 
 	pnumMax := c.acks[space].largestSeen()
 	p, n := parseLongHeaderPacket(buf, k, pnumMax)
 	if n < 0 {
+		c.traceDroppedPacket(now, ptype, -1, len(buf), TraceDropDecryptionFailed)
 		return -1
 	}
 	if buf[0]&reservedLongBits != 0 {
@@ -82,19 +92,22 @@ func (c *Conn) handleLongHeader(now time.Time, ptype packetType, space numberSpa
 		c.abort(now, localTransportError(errProtocolViolation))
 		return -1
 	}
-	if p.version != quicVersion1 {
+	if p.version != c.version.number {
 		// The peer has changed versions on us mid-handshake?
 		c.abort(now, localTransportError(errProtocolViolation))
 		return -1
 	}
 
 	if !c.acks[space].shouldProcess(p.num) {
+		c.traceDroppedPacket(now, ptype, p.num, n, TraceDropDuplicate)
 		return n
 	}
 
 	if logPackets {
 		logInboundLongPacket(c, p)
 	}
+	c.traceReceivedPacket(now, p.ptype, p.num, n, p.payload)
+	c.countPacket(p.ptype)
 	c.connIDState.handlePacket(c, p.ptype, p.srcConnID)
 	ackEliciting := c.handleFrames(now, ptype, space, p.payload)
 	c.acks[space].receive(now, space, p.num, ackEliciting)
@@ -113,6 +126,7 @@ func (c *Conn) handle1RTT(now time.Time, buf []byte) int {
 	if !c.keysAppData.canRead() {
 		// 1-RTT packets extend to the end of the datagram,
 		// so skip the remainder of the datagram if we can't parse this.
+		c.traceDroppedPacket(now, packetType1RTT, -1, len(buf), TraceDropKeysUnavailable)
 		return len(buf)
 	}
 
@@ -123,6 +137,8 @@ func (c *Conn) handle1RTT(now time.Time, buf []byte) int {
 		// Other errors indicate an unparseable packet, but otherwise may be ignored.
 		if _, ok := err.(localTransportError); ok {
 			c.abort(now, err)
+		} else {
+			c.traceDroppedPacket(now, packetType1RTT, -1, len(buf), TraceDropDecryptionFailed)
 		}
 		return -1
 	}
@@ -134,87 +150,193 @@ func (c *Conn) handle1RTT(now time.Time, buf []byte) int {
 	}
 
 	if !c.acks[appDataSpace].shouldProcess(p.num) {
+		c.traceDroppedPacket(now, packetType1RTT, p.num, len(buf), TraceDropDuplicate)
 		return len(buf)
 	}
 
 	if logPackets {
 		logInboundShortPacket(c, p)
 	}
+	c.traceReceivedPacket(now, packetType1RTT, p.num, len(buf), p.payload)
+	c.countPacket(packetType1RTT)
+	c.handleSpinBit(buf[0], p.num)
 	ackEliciting := c.handleFrames(now, packetType1RTT, appDataSpace, p.payload)
 	c.acks[appDataSpace].receive(now, appDataSpace, p.num, ackEliciting)
 	return len(buf)
 }
 
 func (c *Conn) handleRetry(now time.Time, pkt []byte) {
+	drop := func(reason TraceDropReason) {
+		c.traceDroppedPacket(now, packetTypeRetry, -1, len(pkt), reason)
+	}
 	if c.side != clientSide {
+		drop(TraceDropUnexpected)
 		return // clients don't send Retry packets
 	}
 	// "After the client has received and processed an Initial or Retry packet
 	// from the server, it MUST discard any subsequent Retry packets that it receives."
 	// https://www.rfc-editor.org/rfc/rfc9000#section-17.2.5.2-1
 	if !c.keysInitial.canRead() {
+		drop(TraceDropUnexpected)
 		return // discarded Initial keys, connection is already established
 	}
 	if c.acks[initialSpace].seen.numRanges() != 0 {
+		drop(TraceDropUnexpected)
 		return // processed at least one packet
 	}
 	if c.retryToken != nil {
+		drop(TraceDropUnexpected)
 		return // received a Retry already
 	}
 	// "Clients MUST discard Retry packets that have a Retry Integrity Tag
 	// that cannot be validated."
 	// https://www.rfc-editor.org/rfc/rfc9000#section-17.2.5.2-2
-	p, ok := parseRetryPacket(pkt, c.connIDState.originalDstConnID)
+	p, ok := parseRetryPacket(c.version, pkt, c.connIDState.originalDstConnID)
 	if !ok {
+		drop(TraceDropIntegrityCheckFailed)
 		return
 	}
 	// "A client MUST discard a Retry packet with a zero-length Retry Token field."
 	// https://www.rfc-editor.org/rfc/rfc9000#section-17.2.5.2-2
 	if len(p.token) == 0 {
+		drop(TraceDropInvalid)
 		return
 	}
+	c.traceUnnumberedPacket(now, packetTypeRetry.String(), len(pkt))
+	c.countPacket(packetTypeRetry)
 	c.retryToken = cloneBytes(p.token)
 	c.connIDState.handleRetryPacket(p.srcConnID)
 	// We need to resend any data we've already sent in Initial packets.
 	// We must not reuse already sent packet numbers.
-	c.loss.discardPackets(initialSpace, c.handleAckOrLoss)
+	c.loss.discardPackets(initialSpace, c.ackOrLossFunc(now))
 	// TODO: Discard 0-RTT packets as well, once we support 0-RTT.
 }
 
-var errVersionNegotiation = errors.New("server does not support QUIC version 1")
-
 func (c *Conn) handleVersionNegotiation(now time.Time, pkt []byte) {
+	drop := func(reason TraceDropReason) {
+		c.traceDroppedPacket(now, packetTypeVersionNegotiation, -1, len(pkt), reason)
+	}
 	if c.side != clientSide {
+		drop(TraceDropUnexpected)
 		return // servers don't handle Version Negotiation packets
 	}
 	// "A client MUST discard any Version Negotiation packet if it has
-	// received and successfully processed any other packet [...]"
+	// received and successfully processed any other packet, including
+	// an earlier Version Negotiation packet."
 	// https://www.rfc-editor.org/rfc/rfc9000#section-6.2-2
 	if !c.keysInitial.canRead() {
+		drop(TraceDropUnexpected)
 		return // discarded Initial keys, connection is already established
 	}
 	if c.acks[initialSpace].seen.numRanges() != 0 {
+		drop(TraceDropUnexpected)
 		return // processed at least one packet
 	}
+	if c.retryToken != nil || c.versionNegotiated {
+		drop(TraceDropUnexpected)
+		return // processed a Retry or Version Negotiation packet
+	}
 	_, srcConnID, versions := parseVersionNegotiation(pkt)
 	if len(c.connIDState.remote) < 1 || !bytes.Equal(c.connIDState.remote[0].cid, srcConnID) {
+		drop(TraceDropInvalid)
 		return // Source Connection ID doesn't match what we sent
 	}
+	c.countPacket(packetTypeVersionNegotiation)
+	var serverVersions []Version
 	for len(versions) >= 4 {
 		ver := binary.BigEndian.Uint32(versions)
-		if ver == 1 {
+		if ver == c.version.number {
 			// "A client MUST discard a Version Negotiation packet that lists
 			// the QUIC version selected by the client."
 			// https://www.rfc-editor.org/rfc/rfc9000#section-6.2-2
+			drop(TraceDropInvalid)
 			return
 		}
+		serverVersions = append(serverVersions, Version(ver))
 		versions = versions[4:]
 	}
-	// "A client that supports only this version of QUIC MUST
-	// abandon the current connection attempt if it receives
-	// a Version Negotiation packet, [with the two exceptions handled above]."
-	// https://www.rfc-editor.org/rfc/rfc9000#section-6.2-2
-	c.abortImmediately(now, errVersionNegotiation)
+	c.traceUnnumberedPacket(now, packetTypeVersionNegotiation.String(), len(pkt))
+
+	vp := c.selectVersion(serverVersions)
+	if vp == nil {
+		// "A client that supports only this version of QUIC MUST
+		// abandon the current connection attempt if it receives
+		// a Version Negotiation packet, [with the two exceptions handled above]."
+		// https://www.rfc-editor.org/rfc/rfc9000#section-6.2-2
+		c.abortImmediately(now, &VersionNegotiationError{Versions: serverVersions})
+		return
+	}
+
+	// Restart the handshake with the new version, as in RFC 9368, Section 2.1.
+	// We keep our connection IDs, and send a new ClientHello
+	// containing a version_information transport parameter for the new version.
+	// The server's version_information lets us detect a forged
+	// Version Negotiation packet; see validateVersionInformation.
+	c.version = vp
+	c.versionNegotiated = true
+	// We must not reuse already sent packet numbers.
+	c.loss.discardPackets(initialSpace, c.ackOrLossFunc(now))
+	c.tls.Close()
+	c.crypto[initialSpace] = cryptoStream{}
+	initialConnID, _ := c.connIDState.dstConnID()
+	if err := c.startTLS(now, initialConnID, c.localParams); err != nil {
+		c.abortImmediately(now, err)
+	}
+}
+
+// selectVersion returns the first version in our order of preference
+// which is in versions, or nil if there is none.
+func (c *Conn) selectVersion(versions []Version) *versionParams {
+	for _, v := range c.config.Versions {
+		if slices.Contains(versions, v) {
+			return paramsForVersion(uint32(v))
+		}
+	}
+	return nil
+}
+
+// availableVersions returns the Available Versions field of
+// the version_information transport parameter we send.
+// https://www.rfc-editor.org/rfc/rfc9368#section-3
+func (c *Conn) availableVersions() []uint32 {
+	if c.side == clientSide {
+		// A client lists the versions its first flight is compatible with.
+		// We don't implement compatible version negotiation,
+		// so this is only the version in use.
+		return []uint32{c.version.number}
+	}
+	// A server lists all the versions it supports.
+	return c.config.versionNumbers()
+}
+
+// validateVersionInformation checks the version_information transport parameter
+// sent by the peer, to detect an attacker influencing version negotiation.
+// https://www.rfc-editor.org/rfc/rfc9368#section-4
+func (c *Conn) validateVersionInformation(p transportParameters) error {
+	if p.chosenVersion == 0 {
+		if c.versionNegotiated {
+			// We changed versions in response to a Version Negotiation packet,
+			// and can't confirm the server sent it.
+			return localTransportError(errVersionNegotiation)
+		}
+		// The peer doesn't support version negotiation.
+		return nil
+	}
+	if p.chosenVersion != c.version.number {
+		return localTransportError(errVersionNegotiation)
+	}
+	if c.versionNegotiated {
+		// We must have chosen the same version if the Version Negotiation packet
+		// had listed the versions the server says it supports.
+		serverVersions := make([]Version, len(p.availableVersions))
+		for i, v := range p.availableVersions {
+			serverVersions[i] = Version(v)
+		}
+		if c.selectVersion(serverVersions) != c.version {
+			return localTransportError(errVersionNegotiation)
+		}
+	}
+	return nil
 }
 
 func (c *Conn) handleFrames(now time.Time, ptype packetType, space numberSpace, payload []byte) (ackEliciting bool) {
@@ -251,6 +373,12 @@ func (c *Conn) handleFrames(now time.Time, ptype packetType, space numberSpace,
 		default:
 			ackEliciting = true
 		}
+		// Most frame types are a single byte, but extensions may use
+		// longer types. An invalid type is rejected below.
+		ftype, _ := consumeVarint(payload)
+		if !c.countFrame(now, ftype) {
+			return false
+		}
 		n := -1
 		switch payload[0] {
 		case frameTypePadding:
@@ -345,6 +473,23 @@ func (c *Conn) handleFrames(now time.Time, ptype packetType, space numberSpace,
 				return
 			}
 			n = c.handleHandshakeDoneFrame(now, space, payload)
+		case frameTypeImmediateAck:
+			if !frameOK(c, ptype, __01) {
+				return
+			}
+			n = c.handleImmediateAckFrame(now, space)
+		case frameTypeDatagram, frameTypeDatagramWithLength:
+			if !frameOK(c, ptype, __01) {
+				return
+			}
+			n = c.handleDatagramFrame(now, payload)
+		default:
+			if ftype == frameTypeAckFrequency {
+				if !frameOK(c, ptype, __01) {
+					return
+				}
+				n = c.handleAckFrequencyFrame(now, space, payload)
+			}
 		}
 		if n < 0 {
 			c.abort(now, localTransportError(errFrameEncoding))
@@ -357,13 +502,14 @@ func (c *Conn) handleFrames(now time.Time, ptype packetType, space numberSpace,
 
 func (c *Conn) handleAckFrame(now time.Time, space numberSpace, payload []byte) int {
 	c.loss.receiveAckStart()
+	ackOrLoss := c.ackOrLossFunc(now)
 	largest, ackDelay, n := consumeAckFrame(payload, func(rangeIndex int, start, end packetNumber) {
 		if end > c.loss.nextNumber(space) {
 			// Acknowledgement of a packet we never sent.
 			c.abort(now, localTransportError(errProtocolViolation))
 			return
 		}
-		c.loss.receiveAckRange(now, space, rangeIndex, start, end, c.handleAckOrLoss)
+		c.loss.receiveAckRange(now, space, rangeIndex, start, end, ackOrLoss)
 	})
 	// Prior to receiving the peer's transport parameters, we cannot
 	// interpret the ACK Delay field because we don't know the ack_delay_exponent
@@ -389,7 +535,8 @@ func (c *Conn) handleAckFrame(now time.Time, space numberSpace, payload []byte)
 	if c.peerAckDelayExponent >= 0 {
 		delay = ackDelay.Duration(uint8(c.peerAckDelayExponent))
 	}
-	c.loss.receiveAckEnd(now, space, delay, c.handleAckOrLoss)
+	c.loss.receiveAckEnd(now, space, delay, ackOrLoss)
+	c.traceCongestion(now)
 	if space == appDataSpace {
 		c.keysAppData.handleAckFor(largest)
 	}
@@ -500,11 +647,11 @@ func (c *Conn) handleRetireConnectionIDFrame(now time.Time, space numberSpace, p
 }
 
 func (c *Conn) handleConnectionCloseTransportFrame(now time.Time, payload []byte) int {
-	code, _, reason, n := consumeConnectionCloseTransportFrame(payload)
+	code, frameType, reason, n := consumeConnectionCloseTransportFrame(payload)
 	if n < 0 {
 		return -1
 	}
-	c.enterDraining(peerTransportError{code: code, reason: reason})
+	c.enterDraining(now, peerTransportError{code: code, frameType: frameType, reason: reason})
 	return n
 }
 
@@ -513,7 +660,11 @@ func (c *Conn) handleConnectionCloseApplicationFrame(now time.Time, payload []by
 	if n < 0 {
 		return -1
 	}
-	c.enterDraining(&ApplicationError{Code: code, Reason: reason})
+	c.enterDraining(now, &ApplicationError{
+		Code:   code,
+		Reason: reason,
+		codes:  c.errorCodeSpace(),
+	})
 	return n
 }
 
@@ -532,9 +683,10 @@ func (c *Conn) handleHandshakeDoneFrame(now time.Time, space numberSpace, payloa
 
 var errStatelessReset = errors.New("received stateless reset")
 
-func (c *Conn) handleStatelessReset(resetToken statelessResetToken) {
+func (c *Conn) handleStatelessReset(now time.Time, resetToken statelessResetToken, size int) {
 	if !c.connIDState.isValidStatelessResetToken(resetToken) {
 		return
 	}
-	c.enterDraining(errStatelessReset)
+	c.traceUnnumberedPacket(now, "Stateless Reset", size)
+	c.enterDraining(now, errStatelessReset)
 }
@@ -12,13 +12,27 @@ import (
 	"time"
 )
 
+// maxSendBurst is the maximum number of datagrams maybeSend will send
+// before returning control to the conn's loop.
+//
+// Bounding the work done on each pass through the loop keeps a connection
+// with a large amount of data to send responsive to other events
+// (inbound datagrams, timers, user calls), and periodically gives
+// other connections' goroutines a chance to run.
+const maxSendBurst = 16
+
 // maybeSend sends datagrams, if possible.
 //
 // If sending is blocked by pacing, it returns the next time
 // a datagram may be sent.
 //
 // If sending is blocked indefinitely, it returns the zero Time.
-func (c *Conn) maybeSend(now time.Time) (next time.Time) {
+//
+// If maybeSend stopped after sending maxSendBurst datagrams
+// and may have more to send, it reports more as true.
+func (c *Conn) maybeSend(now time.Time) (next time.Time, more bool) {
+	defer c.flushDatagrams()
+
 	// Assumption: The congestion window is not underutilized.
 	// If congestion control, pacing, and anti-amplification all permit sending,
 	// but we have no packet to send, then we will declare the window underutilized.
@@ -35,14 +49,17 @@ func (c *Conn) maybeSend(now time.Time) (next time.Time) {
 	// Speculatively constructing packets means we don't need
 	// separate code paths for "do we have data to send?" and
 	// "send the data" that need to be kept in sync.
-	for {
+	for sent := 0; ; sent++ {
+		if sent >= maxSendBurst {
+			return time.Time{}, true
+		}
 		limit, next := c.loss.sendLimit(now)
 		if limit == ccBlocked {
 			// If anti-amplification blocks sending, then no packet can be sent.
-			return next
+			return next, false
 		}
 		if !c.sendOK(now) {
-			return time.Time{}
+			return time.Time{}, false
 		}
 		// We may still send ACKs, even if congestion control or pacing limit sending.
 
@@ -53,7 +70,7 @@ func (c *Conn) maybeSend(now time.Time) (next time.Time) {
 		if !ok {
 			// It is currently not possible for us to end up without a connection ID,
 			// but handle the case anyway.
-			return time.Time{}
+			return time.Time{}, false
 		}
 
 		// Initial packet.
@@ -64,7 +81,7 @@ func (c *Conn) maybeSend(now time.Time) (next time.Time) {
 			pnum := c.loss.nextNumber(initialSpace)
 			p := longPacket{
 				ptype:     packetTypeInitial,
-				version:   quicVersion1,
+				version:   c.version.number,
 				num:       pnum,
 				dstConnID: dstConnID,
 				srcConnID: c.connIDState.srcConnID(),
@@ -75,8 +92,11 @@ func (c *Conn) maybeSend(now time.Time) (next time.Time) {
 			if logPackets {
 				logSentPacket(c, packetTypeInitial, pnum, p.srcConnID, p.dstConnID, c.w.payload())
 			}
+			c.traceSendingPacket(c.w.payload())
 			sentInitial = c.w.finishProtectedLongHeaderPacket(pnumMaxAcked, c.keysInitial.w, p)
+			c.traceSentPacket(now, packetTypeInitial, sentInitial)
 			if sentInitial != nil {
+				c.idleHandlePacketSent(now, sentInitial)
 				// Client initial packets and ack-eliciting server initial packaets
 				// need to be sent in a datagram padded to at least 1200 bytes.
 				// We can't add the padding yet, however, since we may want to
@@ -93,7 +113,7 @@ func (c *Conn) maybeSend(now time.Time) (next time.Time) {
 			pnum := c.loss.nextNumber(handshakeSpace)
 			p := longPacket{
 				ptype:     packetTypeHandshake,
-				version:   quicVersion1,
+				version:   c.version.number,
 				num:       pnum,
 				dstConnID: dstConnID,
 				srcConnID: c.connIDState.srcConnID(),
@@ -103,7 +123,10 @@ func (c *Conn) maybeSend(now time.Time) (next time.Time) {
 			if logPackets {
 				logSentPacket(c, packetTypeHandshake, pnum, p.srcConnID, p.dstConnID, c.w.payload())
 			}
+			c.traceSendingPacket(c.w.payload())
 			if sent := c.w.finishProtectedLongHeaderPacket(pnumMaxAcked, c.keysHandshake.w, p); sent != nil {
+				c.traceSentPacket(now, packetTypeHandshake, sent)
+				c.idleHandlePacketSent(now, sent)
 				c.loss.packetSent(now, handshakeSpace, sent)
 				if c.side == clientSide {
 					// "[...] a client MUST discard Initial keys when it first
@@ -130,7 +153,10 @@ func (c *Conn) maybeSend(now time.Time) (next time.Time) {
 			if logPackets {
 				logSentPacket(c, packetType1RTT, pnum, nil, dstConnID, c.w.payload())
 			}
-			if sent := c.w.finish1RTTPacket(pnum, pnumMaxAcked, dstConnID, &c.keysAppData); sent != nil {
+			c.traceSendingPacket(c.w.payload())
+			if sent := c.w.finish1RTTPacket(pnum, pnumMaxAcked, dstConnID, c.spin.value, &c.keysAppData); sent != nil {
+				c.traceSentPacket(now, packetType1RTT, sent)
+				c.idleHandlePacketSent(now, sent)
 				c.loss.packetSent(now, appDataSpace, sent)
 			}
 		}
@@ -142,7 +168,7 @@ func (c *Conn) maybeSend(now time.Time) (next time.Time) {
 				// block sending. The congestion window is underutilized.
 				c.loss.cc.setUnderutilized(true)
 			}
-			return next
+			return next, false
 		}
 
 		if sentInitial != nil {
@@ -167,7 +193,12 @@ func (c *Conn) maybeSend(now time.Time) (next time.Time) {
 			}
 		}
 
-		c.listener.sendDatagram(buf, c.peerAddr)
+		if c.lifetime.localErr != nil {
+			// Retain the CONNECTION_CLOSE, so the listener can resend it
+			// in response to packets arriving after the connection is gone.
+			c.lifetime.connCloseDatagram = append(c.lifetime.connCloseDatagram[:0], buf...)
+		}
+		c.sendDatagram(buf)
 	}
 }
 
@@ -250,15 +281,35 @@ func (c *Conn) appendFrames(now time.Time, space numberSpace, pnum packetNumber,
 			c.handshakeConfirmed.setSent(pnum)
 		}
 
+		// PING requested by Conn.Ping
+		if !c.appendPingFrames(pnum, pto) {
+			return
+		}
+
 		// NEW_CONNECTION_ID, RETIRE_CONNECTION_ID
 		if !c.connIDState.appendFrames(c, pnum, pto) {
 			return
 		}
 
+		// ACK_FREQUENCY
+		if !c.appendAckFrequencyFrame(pnum, pto) {
+			return
+		}
+
+		// DATAGRAM
+		if !c.appendDatagramFrames() {
+			return
+		}
+
 		// All stream-related frames. This should come last in the packet,
 		// so large amounts of STREAM data don't crowd out other frames
 		// we may need to send.
-		if !c.appendStreamFrames(&c.w, pnum, pto) {
+		if !c.appendStreamFrames(now, &c.w, pnum, pto) {
+			return
+		}
+
+		// PING (keep-alive), if nothing else made the packet ack-eliciting.
+		if c.handshakeConfirmed.isSet() && !c.appendKeepAlive() {
 			return
 		}
 	}
@@ -310,8 +361,16 @@ func (c *Conn) appendFrames(now time.Time, space numberSpace, pnum packetNumber,
 	// out the probe for the Application Data space. However, since this probe is
 	// optional (recall that the Application Data PTO timer is never set until
 	// after Handshake keys have been discarded), dropping it is acceptable.
+	//
+	// When the peer supports the ACK frequency extension, we send an IMMEDIATE_ACK
+	// rather than a PING, since the peer may otherwise delay acknowledging the probe.
+	// https://www.ietf.org/archive/id/draft-ietf-quic-ack-frequency-10.html#section-7.2
 	if pto && !c.w.sent.ackEliciting {
-		c.w.appendPingFrame()
+		if space == appDataSpace && c.peerSupportsAckFrequency() {
+			c.w.appendImmediateAckFrame()
+		} else {
+			c.w.appendPingFrame()
+		}
 	}
 }
 
@@ -326,28 +385,39 @@ func (c *Conn) appendAckFrame(now time.Time, space numberSpace) bool {
 
 func (c *Conn) appendConnectionCloseFrame(now time.Time, space numberSpace, err error) {
 	c.lifetime.connCloseSentTime = now
+	e := localConnectionCloseError(err)
+	switch {
+	case !e.Application:
+		c.w.appendConnectionCloseTransportFrame(transportError(e.Code), 0, "")
+	case space != appDataSpace:
+		// "CONNECTION_CLOSE frames signaling application errors (type 0x1d)
+		// MUST only appear in the application data packet number space."
+		// https://www.rfc-editor.org/rfc/rfc9000#section-12.5-2.2
+		c.w.appendConnectionCloseTransportFrame(errApplicationError, 0, "")
+	default:
+		c.w.appendConnectionCloseApplicationFrame(e.Code, e.Reason)
+	}
+}
+
+// localConnectionCloseError returns the contents of the CONNECTION_CLOSE frame
+// we send to terminate a connection with err.
+func localConnectionCloseError(err error) *ConnectionCloseError {
 	switch e := err.(type) {
 	case localTransportError:
-		c.w.appendConnectionCloseTransportFrame(transportError(e), 0, "")
+		return &ConnectionCloseError{Code: uint64(e)}
 	case *ApplicationError:
-		if space != appDataSpace {
-			// "CONNECTION_CLOSE frames signaling application errors (type 0x1d)
-			// MUST only appear in the application data packet number space."
-			// https://www.rfc-editor.org/rfc/rfc9000#section-12.5-2.2
-			c.w.appendConnectionCloseTransportFrame(errApplicationError, 0, "")
-		} else {
-			c.w.appendConnectionCloseApplicationFrame(e.Code, e.Reason)
-		}
-	default:
-		// TLS alerts are sent using error codes [0x0100,0x01ff).
-		// https://www.rfc-editor.org/rfc/rfc9000#section-20.1-2.36.1
-		var alert tls.AlertError
-		if errors.As(err, &alert) {
-			// tls.AlertError is a uint8, so this can't exceed 0x01ff.
-			code := errTLSBase + transportError(alert)
-			c.w.appendConnectionCloseTransportFrame(code, 0, "")
-		} else {
-			c.w.appendConnectionCloseTransportFrame(errInternal, 0, "")
+		return &ConnectionCloseError{
+			Application: true,
+			Code:        e.Code,
+			Reason:      e.Reason,
 		}
 	}
+	// TLS alerts are sent using error codes [0x0100,0x01ff).
+	// https://www.rfc-editor.org/rfc/rfc9000#section-20.1-2.36.1
+	var alert tls.AlertError
+	if errors.As(err, &alert) {
+		// tls.AlertError is a uint8, so this can't exceed 0x01ff.
+		return &ConnectionCloseError{Code: uint64(errTLSBase + transportError(alert))}
+	}
+	return &ConnectionCloseError{Code: uint64(errInternal)}
 }
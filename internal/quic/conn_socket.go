@@ -0,0 +1,131 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"net"
+	"net/netip"
+	"time"
+)
+
+// dialSocket creates a UDP socket connected to addr,
+// for a client connection when Config.ConnectedSockets is set.
+// It returns nil if the Listener does not use an operating system socket.
+func (l *Listener) dialSocket(addr netip.AddrPort) (*net.UDPConn, error) {
+	u, ok := l.udpConn.(*net.UDPConn)
+	if !ok {
+		return nil, nil
+	}
+	var laddr netip.AddrPort
+	if a, ok := u.LocalAddr().(*net.UDPAddr); ok {
+		if ip := a.AddrPort().Addr(); !ip.IsUnspecified() {
+			laddr = netip.AddrPortFrom(ip, 0)
+		}
+	}
+	return dialUDP(laddr, addr, l.config)
+}
+
+// readSocket reads datagrams from the conn's connected socket,
+// until the socket is closed.
+func (c *Conn) readSocket() {
+	for {
+		m := c.listener.dgrams.get()
+		n, err := c.sock.Read(m.b)
+		if err != nil {
+			m.recycle()
+			if isUnreachableError(err) {
+				// An ICMP error was received for a datagram we sent.
+				c.sendMsg(func(now time.Time, c *Conn) {
+					c.handleSocketRefused(now, err)
+				})
+				continue
+			}
+			// The socket has been closed.
+			return
+		}
+		if n == 0 {
+			m.recycle()
+			continue
+		}
+		l := c.listener
+		l.stats.datagramsReceived.Add(1)
+		if l.config.CaptureDatagram != nil {
+			l.captureDatagram(false, m.b[:n], c.peerAddr)
+		}
+		m.addr = c.peerAddr
+		m.b = m.b[:n]
+		c.sendMsg(m)
+	}
+}
+
+// handleSocketRefused handles an ICMP port, host, or network unreachable error
+// received on the conn's connected socket.
+//
+// ICMP messages are not authenticated, so we only act on them before
+// the handshake completes, when the server has not yet responded.
+// An established connection relies on its idle timeout instead.
+func (c *Conn) handleSocketRefused(now time.Time, err error) {
+	select {
+	case <-c.lifetime.readyc:
+		return
+	default:
+	}
+	c.abortImmediately(now, err)
+}
+
+// sendBatchSize is the maximum number of datagrams a conn sends at once.
+const sendBatchSize = 8
+
+// A sendQueue holds datagrams built by the conn's loop,
+// so they may be sent to the peer together.
+type sendQueue struct {
+	dgrams [sendBatchSize]*datagram
+	n      int
+}
+
+// sendDatagram queues a datagram to be sent to the conn's peer.
+// The datagram is sent when the queue is full or by the next flushDatagrams.
+func (c *Conn) sendDatagram(b []byte) {
+	q := &c.sendq
+	pool := &c.listener.dgrams
+	m := pool.getSize(max(pool.size, len(b)))
+	m.b = append(m.b[:0], b...)
+	q.dgrams[q.n] = m
+	q.n++
+	if q.n == len(q.dgrams) {
+		c.flushDatagrams()
+	}
+}
+
+// flushDatagrams sends the datagrams queued by sendDatagram.
+//
+// Datagrams sent on the Listener's socket are written in a batch,
+// from the local address the peer sends to.
+func (c *Conn) flushDatagrams() {
+	q := &c.sendq
+	if q.n == 0 {
+		return
+	}
+	var bufs [sendBatchSize][]byte
+	for i, m := range q.dgrams[:q.n] {
+		bufs[i] = m.b
+	}
+	if c.sock == nil {
+		c.listener.sendDatagrams(bufs[:q.n], c.localAddr.Addr(), c.peerAddr)
+	} else {
+		for _, b := range bufs[:q.n] {
+			if _, err := c.sock.Write(b); err == nil {
+				c.listener.datagramSent(b, c.peerAddr)
+			}
+		}
+	}
+	for i, m := range q.dgrams[:q.n] {
+		m.recycle()
+		q.dgrams[i] = nil
+	}
+	q.n = 0
+}
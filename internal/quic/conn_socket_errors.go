@@ -0,0 +1,21 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21 && !plan9
+
+package quic
+
+import (
+	"errors"
+	"syscall"
+)
+
+// isUnreachableError reports whether err is the result of an ICMP
+// port, host, or network unreachable error for a datagram sent on
+// a connected socket.
+func isUnreachableError(err error) bool {
+	return errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EHOSTUNREACH) ||
+		errors.Is(err, syscall.ENETUNREACH)
+}
@@ -0,0 +1,13 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+// isUnreachableError reports whether err is the result of an ICMP
+// unreachable error. Plan 9 does not report these as distinct errors.
+func isUnreachableError(err error) bool {
+	return false
+}
@@ -0,0 +1,90 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestConnectedSocket(t *testing.T) {
+	ctx := context.Background()
+	cli, srv := newLocalConnPair(t, &Config{}, &Config{ConnectedSockets: true})
+	defer cli.Abort(nil)
+	defer srv.Abort(nil)
+	if cli.sock == nil {
+		t.Fatalf("client conn has no connected socket")
+	}
+	if got, want := srv.peerAddr, cli.sock.LocalAddr().(*net.UDPAddr).AddrPort(); got != want {
+		t.Errorf("server sees client address %v, want connected socket address %v", got, want)
+	}
+	if got, notWant := srv.peerAddr, cli.listener.LocalAddr(); got == notWant {
+		t.Errorf("server sees client address %v, the client Listener's address", got)
+	}
+
+	data := makeTestData(1 << 16)
+	s, err := cli.NewStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Write(data)
+	s.CloseWrite()
+	ss, err := srv.AcceptStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(ss)
+	if err != nil {
+		t.Fatalf("io.ReadAll(server stream): %v", err)
+	}
+	if !bytes.Equal(b, data) {
+		t.Errorf("read data mismatch (got %v bytes, want %v)", len(b), len(data))
+	}
+}
+
+func TestConnectedSocketClosedWithConn(t *testing.T) {
+	cli, srv := newLocalConnPair(t, &Config{}, &Config{ConnectedSockets: true})
+	defer srv.Abort(nil)
+	cli.Abort(nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	select {
+	case <-cli.donec:
+	case <-ctx.Done():
+		t.Fatalf("conn did not exit after Abort")
+	}
+	if _, err := cli.sock.Write([]byte{0}); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("write to connected socket after conn exit: %v, want net.ErrClosed", err)
+	}
+}
+
+func TestConnectedSocketRefused(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skipf("ICMP errors on loopback are not reliably reported on %v", runtime.GOOS)
+	}
+	// Find a port with nothing listening on it.
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := pc.LocalAddr().String()
+	pc.Close()
+
+	l := newLocalListener(t, clientSide, &Config{ConnectedSockets: true})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err = l.Dial(ctx, "udp", addr)
+	if !isUnreachableError(err) {
+		t.Fatalf("Dial to closed port = %v, want ECONNREFUSED", err)
+	}
+}
@@ -8,16 +8,19 @@ package quic
 
 import (
 	"context"
+	"errors"
 	"sync"
 	"sync/atomic"
 	"time"
 )
 
 type streamsState struct {
-	queue queue[*Stream] // new, peer-created streams
+	queue    queue[*Stream] // new, peer-created streams
+	uniQueue queue[*Stream] // new, peer-created unidirectional streams, if uniSeparate
 
-	streamsMu sync.Mutex
-	streams   map[streamID]*Stream
+	streamsMu   sync.Mutex
+	streams     map[streamID]*Stream
+	uniSeparate bool // set when AcceptUniStream is called
 
 	// Limits on the number of streams, indexed by streamType.
 	localLimit  [streamTypeCount]localStreamLimits
@@ -31,29 +34,59 @@ type streamsState struct {
 	inflow  connInflow
 	outflow connOutflow
 
-	// Streams with frames to send are stored in one of two circular linked lists,
-	// depending on whether they require connection-level flow control.
+	// Streams with non-flow-controlled frames to send are stored in a
+	// circular linked list. Streams with only flow-controlled frames
+	// are held by the scheduler.
 	needSend  atomic.Bool
 	sendMu    sync.Mutex
-	queueMeta streamRing // streams with any non-flow-controlled frames
-	queueData streamRing // streams with only flow-controlled frames
+	queueMeta streamRing      // streams with any non-flow-controlled frames
+	queueData StreamScheduler // streams with only flow-controlled frames
+	dataLen   int             // number of streams in queueData
 }
 
 func (c *Conn) streamsInit() {
 	c.streams.streams = make(map[streamID]*Stream)
 	c.streams.queue = newQueue[*Stream]()
+	c.streams.uniQueue = newQueue[*Stream]()
 	c.streams.localLimit[bidiStream].init()
 	c.streams.localLimit[uniStream].init()
-	c.streams.remoteLimit[bidiStream].init(c.config.maxBidiRemoteStreams())
-	c.streams.remoteLimit[uniStream].init(c.config.maxUniRemoteStreams())
+	c.streams.remoteLimit[bidiStream].init(c.config.maxBidiRemoteStreams(), c.config.StreamLimitUpdate)
+	c.streams.remoteLimit[uniStream].init(c.config.maxUniRemoteStreams(), c.config.StreamLimitUpdate)
+	if c.config.NewStreamScheduler != nil {
+		c.streams.queueData = c.config.NewStreamScheduler(c)
+	}
+	if c.streams.queueData == nil {
+		c.streams.queueData = &urgencyScheduler{}
+	}
 	c.inflowInit()
 }
 
 // AcceptStream waits for and returns the next stream created by the peer.
+// If AcceptUniStream has been called, it returns only bidirectional streams.
 func (c *Conn) AcceptStream(ctx context.Context) (*Stream, error) {
 	return c.streams.queue.get(ctx, c.testHooks)
 }
 
+// ErrStreamLimitReached is returned by OpenStream and OpenUniStream
+// when the peer's maximum stream limit for the connection has been reached.
+var ErrStreamLimitReached = errors.New("quic: stream limit reached")
+
+// OpenStream creates a bidirectional stream without blocking.
+//
+// If the peer's maximum stream limit for the connection has been reached,
+// OpenStream returns ErrStreamLimitReached.
+func (c *Conn) OpenStream() (*Stream, error) {
+	return c.tryNewLocalStream(bidiStream)
+}
+
+// OpenStreamSync creates a bidirectional stream.
+//
+// If the peer's maximum stream limit for the connection has been reached,
+// OpenStreamSync blocks until the limit is increased or the context expires.
+func (c *Conn) OpenStreamSync(ctx context.Context) (*Stream, error) {
+	return c.newLocalStream(ctx, bidiStream)
+}
+
 // NewStream creates a stream.
 //
 // If the peer's maximum stream limit for the connection has been reached,
@@ -71,26 +104,43 @@ func (c *Conn) NewSendOnlyStream(ctx context.Context) (*Stream, error) {
 }
 
 func (c *Conn) newLocalStream(ctx context.Context, styp streamType) (*Stream, error) {
-	c.streams.streamsMu.Lock()
-	defer c.streams.streamsMu.Unlock()
-
+	// Don't hold streamsMu while waiting for stream quota:
+	// The conn needs it to process frames, including the MAX_STREAMS
+	// which will unblock us.
 	num, err := c.streams.localLimit[styp].open(ctx, c)
 	if err != nil {
 		return nil, err
 	}
+	return c.newLocalStreamNum(styp, num), nil
+}
+
+func (c *Conn) tryNewLocalStream(styp streamType) (*Stream, error) {
+	num, ok := c.streams.localLimit[styp].tryOpen()
+	if !ok {
+		return nil, ErrStreamLimitReached
+	}
+	return c.newLocalStreamNum(styp, num), nil
+}
+
+// newLocalStreamNum creates the local stream with the given number,
+// which has been allocated from the stream limit.
+func (c *Conn) newLocalStreamNum(styp streamType, num int64) *Stream {
+	c.streams.streamsMu.Lock()
+	defer c.streams.streamsMu.Unlock()
 
 	s := newStream(c, newStreamID(c.side, styp, num))
 	s.outmaxbuf = c.config.maxStreamWriteBufferSize()
 	s.outwin = c.streams.peerInitialMaxStreamDataRemote[styp]
 	if styp == bidiStream {
 		s.inmaxbuf = c.config.maxStreamReadBufferSize()
-		s.inwin = c.config.maxStreamReadBufferSize()
+		s.inwin = c.config.initialStreamReadWindowBidiLocal()
+		s.intuner = c.config.newWindowTuner(s.inwin, s.inmaxbuf)
 	}
 	s.inUnlock()
 	s.outUnlock()
 
 	c.streams.streams[s.id] = s
-	return s, nil
+	return s
 }
 
 // streamFrameType identifies which direction of a stream,
@@ -175,7 +225,11 @@ func (c *Conn) streamForFrame(now time.Time, id streamID, ftype streamFrameType)
 
 	s = newStream(c, id)
 	s.inmaxbuf = c.config.maxStreamReadBufferSize()
-	s.inwin = c.config.maxStreamReadBufferSize()
+	s.inwin = c.config.initialStreamReadWindowUni()
+	if id.streamType() == bidiStream {
+		s.inwin = c.config.initialStreamReadWindowBidiRemote()
+	}
+	s.intuner = c.config.newWindowTuner(s.inwin, s.inmaxbuf)
 	if id.streamType() == bidiStream {
 		s.outmaxbuf = c.config.maxStreamWriteBufferSize()
 		s.outwin = c.streams.peerInitialMaxStreamDataBidiLocal
@@ -184,7 +238,11 @@ func (c *Conn) streamForFrame(now time.Time, id streamID, ftype streamFrameType)
 	s.outUnlock()
 
 	c.streams.streams[id] = s
-	c.streams.queue.put(s)
+	if id.streamType() == uniStream && c.streams.uniSeparate {
+		c.streams.uniQueue.put(s)
+	} else {
+		c.streams.queue.put(s)
+	}
 	return s
 }
 
@@ -218,7 +276,8 @@ func (c *Conn) queueStreamForSendLocked(s *Stream, state streamState) {
 		case metaQueue:
 			c.streams.queueMeta.remove(s)
 		case dataQueue:
-			c.streams.queueData.remove(s)
+			c.streams.queueData.Remove(s)
+			c.streams.dataLen--
 		}
 
 		switch wantQueue {
@@ -226,7 +285,8 @@ func (c *Conn) queueStreamForSendLocked(s *Stream, state streamState) {
 			c.streams.queueMeta.append(s)
 			state = s.state.set(streamQueueMeta, streamQueueMeta|streamQueueData)
 		case dataQueue:
-			c.streams.queueData.append(s)
+			c.streams.queueData.Add(s)
+			c.streams.dataLen++
 			state = s.state.set(streamQueueData, streamQueueMeta|streamQueueData)
 		case noQueue:
 			state = s.state.set(0, streamQueueMeta|streamQueueData)
@@ -253,9 +313,9 @@ func (c *Conn) queueStreamForSendLocked(s *Stream, state streamState) {
 //
 // It returns true if no more frames need appending,
 // false if not everything fit in the current packet.
-func (c *Conn) appendStreamFrames(w *packetWriter, pnum packetNumber, pto bool) bool {
+func (c *Conn) appendStreamFrames(now time.Time, w *packetWriter, pnum packetNumber, pto bool) bool {
 	// MAX_DATA
-	if !c.appendMaxDataFrame(w, pnum, pto) {
+	if !c.appendMaxDataFrame(now, w, pnum, pto) {
 		return false
 	}
 
@@ -268,7 +328,7 @@ func (c *Conn) appendStreamFrames(w *packetWriter, pnum packetNumber, pto bool)
 	}
 
 	if pto {
-		return c.appendStreamFramesPTO(w, pnum)
+		return c.appendStreamFramesPTO(now, w, pnum)
 	}
 	if !c.streams.needSend.Load() {
 		return true
@@ -284,7 +344,7 @@ func (c *Conn) appendStreamFrames(w *packetWriter, pnum packetNumber, pto bool)
 		}
 		if state&streamInSendMeta != 0 {
 			s.ingate.lock()
-			ok := s.appendInFramesLocked(w, pnum, pto)
+			ok := s.appendInFramesLocked(now, w, pnum, pto)
 			state = s.inUnlockNoQueue()
 			if !ok {
 				return false
@@ -314,7 +374,10 @@ func (c *Conn) appendStreamFrames(w *packetWriter, pnum packetNumber, pto bool)
 		if state&(streamInDone|streamOutDone) == streamInDone|streamOutDone {
 			// Stream is finished, remove it from the conn.
 			state = s.state.set(streamConnRemoved, streamQueueMeta|streamConnRemoved)
+			c.streams.streamsMu.Lock()
 			delete(c.streams.streams, s.id)
+			c.streams.streamsMu.Unlock()
+			s.contextRemoved()
 
 			// Record finalization of remote streams, to know when
 			// to extend the peer's stream limit.
@@ -330,49 +393,50 @@ func (c *Conn) appendStreamFrames(w *packetWriter, pnum packetNumber, pto bool)
 		// If so, put the stream back on a queue.
 		c.queueStreamForSendLocked(s, state)
 	}
-	// queueData contains streams with flow-controlled frames.
-	for c.streams.queueData.head != nil {
+	// queueData contains streams with flow-controlled frames,
+	// in the order chosen by the scheduler.
+	for {
+		s := c.streams.queueData.Next()
+		if s == nil {
+			break
+		}
 		avail := c.streams.outflow.avail()
 		if avail == 0 {
-			break // no flow control quota available
+			return true // no flow control quota available
+		}
+		if state := s.state.load(); state&(streamQueueData|streamConnRemoved) != streamQueueData {
+			panic("BUG: scheduled stream is not streamQueueData")
 		}
-		s := c.streams.queueData.head
 		s.outgate.lock()
 		ok := s.appendOutFramesLocked(w, pnum, pto)
 		state := s.outUnlockNoQueue()
+		sent := int(avail - c.streams.outflow.avail())
 		if !ok {
 			// We've sent some data for this stream, but it still has more to send.
-			// If the stream got a reasonable chance to put data in a packet,
-			// advance sendHead to the next stream in line, to avoid starvation.
-			// We'll come back to this stream after going through the others.
-			//
-			// If the packet was already mostly out of space, leave sendHead alone
-			// and come back to this stream again on the next packet.
-			if avail > 512 {
-				c.streams.queueData.head = s.next
-			}
+			// Let the scheduler decide whether to come back to this stream
+			// on the next packet, or move on to another stream.
+			c.streams.queueData.Sent(s, sent)
 			return false
 		}
-		if state&streamQueueData == 0 {
-			panic("BUG: queueData stream is not streamQueueData")
-		}
 		if state&streamOutSendData != 0 {
 			// We must have run out of connection-level flow control:
 			// appendOutFramesLocked says it wrote all it can, but there's
 			// still data to send.
-			//
-			// Advance sendHead to the next stream in line to avoid starvation.
 			if c.streams.outflow.avail() != 0 {
 				panic("BUG: streamOutSendData set and flow control available after send")
 			}
-			c.streams.queueData.head = s.next
+			c.streams.queueData.Sent(s, sent)
 			return true
 		}
-		c.streams.queueData.remove(s)
+		c.streams.queueData.Remove(s)
+		c.streams.dataLen--
 		state = s.state.set(0, streamQueueData)
 		c.queueStreamForSendLocked(s, state)
 	}
-	if c.streams.queueMeta.head == nil && c.streams.queueData.head == nil {
+	// The scheduler may decline to send data from the streams it holds.
+	// Keep needSend set until every stream has been removed from the scheduler,
+	// so we come back to them when sending the next packet.
+	if c.streams.queueMeta.head == nil && c.streams.dataLen == 0 {
 		c.streams.needSend.Store(false)
 	}
 	return true
@@ -383,14 +447,24 @@ func (c *Conn) appendStreamFrames(w *packetWriter, pnum packetNumber, pto bool)
 //
 // It returns true if no more frames need appending,
 // false if not everything fit in the current packet.
-func (c *Conn) appendStreamFramesPTO(w *packetWriter, pnum packetNumber) bool {
+func (c *Conn) appendStreamFramesPTO(now time.Time, w *packetWriter, pnum packetNumber) bool {
+	// Copy the streams out of the map rather than holding streamsMu
+	// while locking each stream: User goroutines add to the map concurrently.
+	c.streams.streamsMu.Lock()
+	streams := make([]*Stream, 0, len(c.streams.streams))
+	for _, s := range c.streams.streams {
+		if s != nil {
+			streams = append(streams, s)
+		}
+	}
+	c.streams.streamsMu.Unlock()
+
 	c.streams.sendMu.Lock()
 	defer c.streams.sendMu.Unlock()
 	const pto = true
-	for _, s := range c.streams.streams {
-		const pto = true
+	for _, s := range streams {
 		s.ingate.lock()
-		inOK := s.appendInFramesLocked(w, pnum, pto)
+		inOK := s.appendInFramesLocked(now, w, pnum, pto)
 		s.inUnlockNoQueue()
 		if !inOK {
 			return false
@@ -280,6 +280,52 @@ func TestStreamsWriteQueueFairness(t *testing.T) {
 	}
 }
 
+func TestStreamsWriteQueuePriority(t *testing.T) {
+	ctx := canceledContext()
+	tc := newTestConn(t, clientSide, permissiveTransportParameters,
+		func(p *transportParameters) {
+			p.initialMaxData = 0
+		})
+	tc.handshake()
+	tc.ignoreFrame(frameTypeAck)
+
+	s1, err := tc.conn.newLocalStream(ctx, uniStream)
+	if err != nil {
+		t.Fatalf("conn.newLocalStream(%v) = %v", uniStream, err)
+	}
+	s2, err := tc.conn.newLocalStream(ctx, uniStream)
+	if err != nil {
+		t.Fatalf("conn.newLocalStream(%v) = %v", uniStream, err)
+	}
+	s2.SetPriority(0)
+
+	s1.Write(make([]byte, 10))
+	s2.Write(make([]byte, 10))
+
+	for i := int64(0); i < 2; i++ {
+		tc.writeFrames(packetType1RTT, debugFrameMaxData{
+			max: i + 1,
+		})
+		tc.wantFrame("more urgent stream 2 writes data up to MAX_DATA limit",
+			packetType1RTT, debugFrameStream{
+				id:   s2.id,
+				off:  i,
+				data: []byte{0},
+			})
+	}
+
+	// Changing the priority of a stream with queued data takes effect immediately.
+	s2.SetPriority(7)
+	tc.writeFrames(packetType1RTT, debugFrameMaxData{
+		max: 3,
+	})
+	tc.wantFrame("stream 1 is now more urgent, writes data up to MAX_DATA limit",
+		packetType1RTT, debugFrameStream{
+			id:   s1.id,
+			data: []byte{0},
+		})
+}
+
 func TestStreamsShutdown(t *testing.T) {
 	// These tests verify that a stream is removed from the Conn's map of live streams
 	// after it is fully shut down.
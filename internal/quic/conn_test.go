@@ -24,7 +24,10 @@ import (
 var testVV = flag.Bool("vv", false, "even more verbose test output")
 
 func TestConnTestConn(t *testing.T) {
-	tc := newTestConn(t, serverSide)
+	tc := newTestConn(t, serverSide, func(c *Config) {
+		// Disable the handshake timeout, so the conn's only timer is the idle timeout.
+		c.HandshakeTimeout = -1
+	})
 	if got, want := tc.timeUntilEvent(), defaultMaxIdleTimeout; got != want {
 		t.Errorf("new conn timeout=%v, want %v (max_idle_timeout)", got, want)
 	}
@@ -146,6 +149,11 @@ type testConn struct {
 	cryptoDataIn  map[tls.QUICEncryptionLevel][]byte
 	peerTLSConn   *tls.QUICConn
 
+	// connTLS is the conn's QUICConn.
+	// A client conn replaces its QUICConn to start a new handshake
+	// after version negotiation, and the test's peer starts over as well.
+	connTLS *tls.QUICConn
+
 	// Information about the conn's (fake) peer.
 	peerConnID        []byte                         // source conn id of peer's packets
 	peerNextPacketNum [numberSpaceCount]packetNumber // next packet number to use
@@ -168,6 +176,10 @@ type testConn struct {
 	// Values to set in packets sent to the conn.
 	sendKeyNumber   int
 	sendKeyPhaseBit bool
+	sendSpinBit     bool
+
+	// Spin bit of the last 1-RTT packet read from the conn.
+	lastSpinBit bool
 
 	asyncTestState
 }
@@ -218,9 +230,11 @@ func newTestConn(t *testing.T, side connSide, opts ...any) *testConn {
 	conn, err := listener.l.newConn(
 		listener.now,
 		side,
+		config.dialVersion(),
 		initialConnID,
 		nil,
-		netip.MustParseAddrPort("127.0.0.1:443"))
+		netip.MustParseAddrPort("127.0.0.1:443"),
+		netip.AddrPort{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -251,13 +265,24 @@ func newTestConnForConn(t *testing.T, listener *testListener, conn *Conn) *testC
 		listener.peerTLSConn = nil
 		return tc
 	}
+	tc.startPeerTLS()
+	return tc
+}
 
+// startPeerTLS creates the QUICConn representing the conn's peer.
+func (tc *testConn) startPeerTLS() {
+	conn := tc.conn
 	peerProvidedParams := defaultTransportParameters()
 	peerProvidedParams.initialSrcConnID = testPeerConnID(0)
 	if conn.side == clientSide {
 		peerProvidedParams.originalDstConnID = testLocalConnID(-1)
+		if conn.versionNegotiated {
+			// The peer supports only the version the conn changed to.
+			peerProvidedParams.chosenVersion = conn.version.number
+			peerProvidedParams.availableVersions = []uint32{conn.version.number}
+		}
 	}
-	for _, f := range listener.configTransportParams {
+	for _, f := range tc.listener.configTransportParams {
 		f(&peerProvidedParams)
 	}
 
@@ -269,8 +294,6 @@ func newTestConnForConn(t *testing.T, listener *testListener, conn *Conn) *testC
 	}
 	tc.peerTLSConn.SetTransportParameters(marshalTransportParameters(peerProvidedParams))
 	tc.peerTLSConn.Start(context.Background())
-
-	return tc
 }
 
 // advance causes time to pass.
@@ -409,7 +432,7 @@ func (tc *testConn) writeFrames(ptype packetType, frames ...debugFrame) {
 			keyNumber:   tc.sendKeyNumber,
 			keyPhaseBit: tc.sendKeyPhaseBit,
 			frames:      frames,
-			version:     quicVersion1,
+			version:     tc.conn.version.number,
 			dstConnID:   dstConnID,
 			srcConnID:   tc.peerConnID,
 		}},
@@ -523,6 +546,12 @@ func (tc *testConn) readDatagram() *testDatagram {
 			return frameTypeConnectionCloseApplication
 		case debugFrameHandshakeDone:
 			return frameTypeHandshakeDone
+		case debugFrameDatagram:
+			return frameTypeDatagramWithLength
+		case debugFrameAckFrequency:
+			return frameTypeAckFrequency
+		case debugFrameImmediateAck:
+			return frameTypeImmediateAck
 		}
 		panic(fmt.Errorf("unhandled frame type %T", f))
 	}
@@ -640,6 +669,15 @@ func (tc *testConn) wantIdle(expectation string) {
 	}
 }
 
+// testVersionParams returns the QUIC version used by tc,
+// or version 1 if tc is nil.
+func testVersionParams(tc *testConn) *versionParams {
+	if tc == nil {
+		return version1Params
+	}
+	return tc.conn.version
+}
+
 func encodeTestPacket(t *testing.T, tc *testConn, p *testPacket, pad int) []byte {
 	t.Helper()
 	var w packetWriter
@@ -647,7 +685,7 @@ func encodeTestPacket(t *testing.T, tc *testConn, p *testPacket, pad int) []byte
 	var pnumMaxAcked packetNumber
 	switch p.ptype {
 	case packetTypeRetry:
-		return encodeRetryPacket(p.originalDstConnID, retryPacket{
+		return encodeRetryPacket(testVersionParams(tc), p.originalDstConnID, retryPacket{
 			srcConnID: p.srcConnID,
 			dstConnID: p.dstConnID,
 			token:     p.token,
@@ -672,7 +710,7 @@ func encodeTestPacket(t *testing.T, tc *testConn, p *testPacket, pad int) []byte
 		var k fixedKeys
 		if tc == nil {
 			if p.ptype == packetTypeInitial {
-				k = initialKeys(p.dstConnID, serverSide).r
+				k = initialKeys(paramsForVersion(p.version), p.dstConnID, serverSide).r
 			} else {
 				t.Fatalf("sending %v packet with no conn", p.ptype)
 			}
@@ -714,7 +752,7 @@ func encodeTestPacket(t *testing.T, tc *testConn, p *testPacket, pad int) []byte
 		if p.keyPhaseBit {
 			k.phase |= keyPhaseBit
 		}
-		w.finish1RTTPacket(p.num, pnumMaxAcked, p.dstConnID, k)
+		w.finish1RTTPacket(p.num, pnumMaxAcked, p.dstConnID, tc.sendSpinBit, k)
 	}
 	return w.datagram()
 }
@@ -732,7 +770,7 @@ func parseTestDatagram(t *testing.T, tl *testListener, tc *testConn, buf []byte)
 		ptype := getPacketType(buf)
 		switch ptype {
 		case packetTypeRetry:
-			retry, ok := parseRetryPacket(buf, tl.lastInitialDstConnID)
+			retry, ok := parseRetryPacket(testVersionParams(tc), buf, tl.lastInitialDstConnID)
 			if !ok {
 				t.Fatalf("could not parse %v packet", ptype)
 			}
@@ -749,7 +787,7 @@ func parseTestDatagram(t *testing.T, tl *testListener, tc *testConn, buf []byte)
 			if tc == nil {
 				if ptype == packetTypeInitial {
 					p, _ := parseGenericLongHeaderPacket(buf)
-					k = initialKeys(p.srcConnID, serverSide).w
+					k = initialKeys(paramsForVersion(p.version), p.srcConnID, serverSide).w
 				} else {
 					t.Fatalf("reading %v packet with no conn", ptype)
 				}
@@ -818,6 +856,7 @@ func parseTestDatagram(t *testing.T, tl *testListener, tc *testConn, buf []byte)
 			if err != nil {
 				t.Fatal(err)
 			}
+			tc.lastSpinBit = hdr[0]&spinBit != 0
 			d.packets = append(d.packets, &testPacket{
 				ptype:       packetType1RTT,
 				num:         pnum,
@@ -903,6 +942,17 @@ func (tc *testConnHooks) init() {
 // and verify that both sides of the connection are getting
 // matching keys.
 func (tc *testConnHooks) handleTLSEvent(e tls.QUICEvent) {
+	if tc.connTLS != tc.conn.tls {
+		if tc.connTLS != nil {
+			tc.peerTLSConn.Close()
+			clear(tc.cryptoDataOut)
+			clear(tc.cryptoDataIn)
+			tc.rsecrets = [numberSpaceCount]keySecret{}
+			tc.wsecrets = [numberSpaceCount]keySecret{}
+			(*testConn)(tc).startPeerTLS()
+		}
+		tc.connTLS = tc.conn.tls
+	}
 	checkKey := func(typ string, secrets *[numberSpaceCount]keySecret, e tls.QUICEvent) {
 		var space numberSpace
 		switch {
@@ -921,11 +971,12 @@ func (tc *testConnHooks) handleTLSEvent(e tls.QUICEvent) {
 			tc.t.Errorf("%v key mismatch for level for level %v", typ, e.Level)
 		}
 	}
+	vp := tc.conn.version
 	setAppDataKey := func(suite uint16, secret []byte, k *test1RTTKeys) {
-		k.hdr.init(suite, secret)
+		k.hdr.init(vp, suite, secret)
 		for i := 0; i < len(k.pkt); i++ {
-			k.pkt[i].init(suite, secret)
-			secret = updateSecret(suite, secret)
+			k.pkt[i].init(vp, suite, secret)
+			secret = updateSecret(vp, suite, secret)
 		}
 	}
 	switch e.Kind {
@@ -933,7 +984,7 @@ func (tc *testConnHooks) handleTLSEvent(e tls.QUICEvent) {
 		checkKey("write", &tc.wsecrets, e)
 		switch e.Level {
 		case tls.QUICEncryptionLevelHandshake:
-			tc.keysHandshake.w.init(e.Suite, e.Data)
+			tc.keysHandshake.w.init(vp, e.Suite, e.Data)
 		case tls.QUICEncryptionLevelApplication:
 			setAppDataKey(e.Suite, e.Data, &tc.wkeyAppData)
 		}
@@ -941,7 +992,7 @@ func (tc *testConnHooks) handleTLSEvent(e tls.QUICEvent) {
 		checkKey("read", &tc.rsecrets, e)
 		switch e.Level {
 		case tls.QUICEncryptionLevelHandshake:
-			tc.keysHandshake.r.init(e.Suite, e.Data)
+			tc.keysHandshake.r.init(vp, e.Suite, e.Data)
 		case tls.QUICEncryptionLevelApplication:
 			setAppDataKey(e.Suite, e.Data, &tc.rkeyAppData)
 		}
@@ -958,7 +1009,7 @@ func (tc *testConnHooks) handleTLSEvent(e tls.QUICEvent) {
 			checkKey("write", &tc.rsecrets, e)
 			switch e.Level {
 			case tls.QUICEncryptionLevelHandshake:
-				tc.keysHandshake.r.init(e.Suite, e.Data)
+				tc.keysHandshake.r.init(vp, e.Suite, e.Data)
 			case tls.QUICEncryptionLevelApplication:
 				setAppDataKey(e.Suite, e.Data, &tc.rkeyAppData)
 			}
@@ -966,7 +1017,7 @@ func (tc *testConnHooks) handleTLSEvent(e tls.QUICEvent) {
 			checkKey("read", &tc.wsecrets, e)
 			switch e.Level {
 			case tls.QUICEncryptionLevelHandshake:
-				tc.keysHandshake.w.init(e.Suite, e.Data)
+				tc.keysHandshake.w.init(vp, e.Suite, e.Data)
 			case tls.QUICEncryptionLevelApplication:
 				setAppDataKey(e.Suite, e.Data, &tc.wkeyAppData)
 			}
@@ -1018,6 +1069,12 @@ func (tc *testConnHooks) nextMessage(msgc chan any, timer time.Time) (now time.T
 }
 
 func (tc *testConnHooks) newConnID(seq int64) ([]byte, error) {
+	if err := tc.listener.newConnIDErr; err != nil {
+		// The Conn will not be created, so there is nothing to clean up.
+		delete(tc.listener.conns, tc.conn)
+		tc.conn = nil
+		return nil, err
+	}
 	return testLocalConnID(seq), nil
 }
 
@@ -1059,3 +1116,25 @@ func canceledContext() context.Context {
 	cancel()
 	return ctx
 }
+
+func TestConnSendBurstLimit(t *testing.T) {
+	// Open the congestion window and pacer wide, so only maxSendBurst
+	// limits the number of datagrams sent at once.
+	tc, s := newTestConnAndLocalStream(t, clientSide, uniStream, permissiveTransportParameters)
+	tc.ignoreFrame(frameTypeAck)
+	var more bool
+	tc.conn.runOnLoop(func(now time.Time, c *Conn) {
+		c.loss.cc.congestionWindow = 1 << 30
+		c.loss.pacer.bucket = 1 << 30
+		c.loss.pacer.maxBucket = 1 << 30
+		s.Write(make([]byte, 2*maxSendBurst*maxUDPPayloadSize))
+		_, more = c.maybeSend(now)
+	})
+	if !more {
+		t.Errorf("maybeSend with more than maxSendBurst datagrams to send: more = false, want true")
+	}
+	for i := 0; i < 2*maxSendBurst; i++ {
+		tc.wantFrameType("stream data is sent",
+			packetType1RTT, debugFrameStream{})
+	}
+}
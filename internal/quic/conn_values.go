@@ -0,0 +1,50 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import "sync"
+
+// connValues holds the values associated with a Conn by the application.
+type connValues struct {
+	mu sync.Mutex
+	m  map[any]any
+}
+
+// SetValue associates val with key on the connection.
+// If val is nil, any value associated with key is removed.
+//
+// Values allow an application to attach data to a connection,
+// such as the result of authenticating the peer,
+// and retrieve it wherever the connection is available,
+// including from a stream with Stream.Conn.
+//
+// As with context.WithValue, the key must be comparable,
+// and should be of an unexported type to avoid collisions
+// between packages.
+func (c *Conn) SetValue(key, val any) {
+	if key == nil {
+		panic("quic: nil key")
+	}
+	c.values.mu.Lock()
+	defer c.values.mu.Unlock()
+	if val == nil {
+		delete(c.values.m, key)
+		return
+	}
+	if c.values.m == nil {
+		c.values.m = make(map[any]any)
+	}
+	c.values.m[key] = val
+}
+
+// Value returns the value associated with key on the connection by SetValue,
+// or nil if there is none.
+func (c *Conn) Value(key any) any {
+	c.values.mu.Lock()
+	defer c.values.mu.Unlock()
+	return c.values.m[key]
+}
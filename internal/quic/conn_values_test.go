@@ -0,0 +1,65 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"context"
+	"testing"
+)
+
+type testValueKey struct{ name string }
+
+func TestConnValues(t *testing.T) {
+	tc := newTestConn(t, clientSide)
+	k1, k2 := testValueKey{"k1"}, testValueKey{"k2"}
+	if got := tc.conn.Value(k1); got != nil {
+		t.Errorf("Value(k1) before SetValue = %v, want nil", got)
+	}
+	tc.conn.SetValue(k1, "v1")
+	tc.conn.SetValue(k2, 2)
+	if got, want := tc.conn.Value(k1), "v1"; got != want {
+		t.Errorf("Value(k1) = %v, want %v", got, want)
+	}
+	if got, want := tc.conn.Value(k2), 2; got != want {
+		t.Errorf("Value(k2) = %v, want %v", got, want)
+	}
+	tc.conn.SetValue(k1, "v1b")
+	if got, want := tc.conn.Value(k1), "v1b"; got != want {
+		t.Errorf("Value(k1) after replacing = %v, want %v", got, want)
+	}
+	tc.conn.SetValue(k1, nil)
+	if got := tc.conn.Value(k1); got != nil {
+		t.Errorf("Value(k1) after removing = %v, want nil", got)
+	}
+	if got, want := tc.conn.Value(k2), 2; got != want {
+		t.Errorf("Value(k2) after removing k1 = %v, want %v", got, want)
+	}
+}
+
+func TestConnValuesFromStream(t *testing.T) {
+	ctx := context.Background()
+	type tenantKey struct{}
+	cli, srv := newLocalConnPair(t, &Config{}, &Config{})
+	srv.SetValue(tenantKey{}, "tenant")
+
+	s, err := cli.NewStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Write([]byte("hello"))
+	s.CloseWrite()
+	ss, err := srv.AcceptStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ss.Conn(); got != srv {
+		t.Fatalf("Stream.Conn() = %v, want %v", got, srv)
+	}
+	if got, want := ss.Conn().Value(tenantKey{}), "tenant"; got != want {
+		t.Errorf("Stream.Conn().Value(key) = %v, want %v", got, want)
+	}
+}
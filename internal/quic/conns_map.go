@@ -0,0 +1,221 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"hash/maphash"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// connsMapShards is the number of shards in a connsMap.
+const connsMapShards = 64
+
+// connsMapMaxPendingUpdates is the number of queued updates across all shards
+// at which the receive loop applies every shard's updates.
+const connsMapMaxPendingUpdates = 4 * connsMapShards
+
+// A connsMap is a listener's mapping of conn ids and reset tokens to conns.
+//
+// The map is only read by the listener's datagram receive loop.
+// Conns add and remove entries from their own goroutines.
+// These updates are queued, and applied by the receive loop
+// the next time it performs a lookup in the affected part of the map.
+//
+// The map is divided into shards by a hash of the conn id or reset token,
+// each with its own update queue. This bounds the size of each map
+// as the number of conns grows, spreads contention between conns
+// updating the map at the same time, and lets the receive loop apply
+// only the updates relevant to each lookup.
+//
+// Some shards may not be looked up for a long time.
+// For example, reset tokens are only looked up for datagrams
+// which don't match any conn id.
+// To keep the queues of those shards from growing without bound,
+// the receive loop applies all queued updates whenever the total
+// number of queued updates reaches connsMapMaxPendingUpdates.
+type connsMap struct {
+	seed    maphash.Seed
+	pending atomic.Int64 // number of queued updates in all shards
+	shards  [connsMapShards]connsMapShard
+}
+
+type connsMapShard struct {
+	// Only accessed by the receive loop.
+	byConnID     map[string]*Conn
+	byResetToken map[statelessResetToken]*Conn
+
+	// Recently closed connections, by conn id and in order of expiration.
+	tombstones     map[string]*connTombstone
+	tombstoneQueue []*connTombstone
+
+	updateMu     sync.Mutex
+	updateNeeded atomic.Bool
+	updates      []connsMapUpdate
+	spare        []connsMapUpdate // only accessed by the receive loop
+}
+
+// A connsMapUpdate is a queued change to a connsMapShard.
+type connsMapUpdate struct {
+	op    connsMapOp
+	c     *Conn
+	cid   string
+	token statelessResetToken
+	tomb  *connTombstone
+}
+
+type connsMapOp uint8
+
+const (
+	connsMapAddConnID = connsMapOp(iota)
+	connsMapRetireConnID
+	connsMapAddResetToken
+	connsMapRetireResetToken
+	connsMapAddTombstone // retire the conn id and add a tombstone for it
+)
+
+func (m *connsMap) init() {
+	m.seed = maphash.MakeSeed()
+	for i := range m.shards {
+		s := &m.shards[i]
+		s.byConnID = map[string]*Conn{}
+		s.byResetToken = map[statelessResetToken]*Conn{}
+		s.tombstones = map[string]*connTombstone{}
+	}
+}
+
+func (m *connsMap) shard(key []byte) *connsMapShard {
+	return &m.shards[maphash.Bytes(m.seed, key)%connsMapShards]
+}
+
+// connForID returns the conn for a conn id, or nil if there is none.
+// It is called by the receive loop.
+func (m *connsMap) connForID(cid []byte) *Conn {
+	s := m.shard(cid)
+	m.applyUpdates(s)
+	return s.byConnID[string(cid)]
+}
+
+// connForResetToken returns the conn for a stateless reset token, or nil if there is none.
+// It is called by the receive loop.
+func (m *connsMap) connForResetToken(token statelessResetToken) *Conn {
+	s := m.shard(token[:])
+	m.applyUpdates(s)
+	return s.byResetToken[token]
+}
+
+func (m *connsMap) addConnID(c *Conn, cid []byte) {
+	m.update(m.shard(cid), connsMapUpdate{op: connsMapAddConnID, c: c, cid: string(cid)})
+}
+
+func (m *connsMap) retireConnID(c *Conn, cid []byte) {
+	m.update(m.shard(cid), connsMapUpdate{op: connsMapRetireConnID, c: c, cid: string(cid)})
+}
+
+func (m *connsMap) addResetToken(c *Conn, token statelessResetToken) {
+	m.update(m.shard(token[:]), connsMapUpdate{op: connsMapAddResetToken, c: c, token: token})
+}
+
+func (m *connsMap) retireResetToken(c *Conn, token statelessResetToken) {
+	m.update(m.shard(token[:]), connsMapUpdate{op: connsMapRetireResetToken, c: c, token: token})
+}
+
+// addTombstone retires a discarded conn's ids, and adds a tombstone for them.
+// Each id is replaced by the tombstone in a single update,
+// so the receive loop never sees the id as belonging to an unknown conn.
+func (m *connsMap) addTombstone(c *Conn, tomb *connTombstone) {
+	for _, cid := range tomb.cids {
+		m.update(m.shard(cid), connsMapUpdate{op: connsMapAddTombstone, c: c, cid: string(cid), tomb: tomb})
+	}
+}
+
+// applyAllUpdates applies all queued updates.
+func (m *connsMap) applyAllUpdates() {
+	for i := range m.shards {
+		m.applyShardUpdates(&m.shards[i])
+	}
+}
+
+func (m *connsMap) update(s *connsMapShard, u connsMapUpdate) {
+	s.updateMu.Lock()
+	defer s.updateMu.Unlock()
+	s.updates = append(s.updates, u)
+	s.updateNeeded.Store(true)
+	m.pending.Add(1)
+}
+
+// applyUpdates is called by the datagram receive loop before a lookup in a shard.
+// It applies the shard's queued updates,
+// or every shard's queued updates if too many are pending.
+func (m *connsMap) applyUpdates(s *connsMapShard) {
+	if m.pending.Load() >= connsMapMaxPendingUpdates {
+		m.applyAllUpdates()
+		return
+	}
+	m.applyShardUpdates(s)
+}
+
+// applyShardUpdates applies a shard's queued updates.
+func (m *connsMap) applyShardUpdates(s *connsMapShard) {
+	if !s.updateNeeded.Load() {
+		return
+	}
+	s.updateMu.Lock()
+	updates := s.updates
+	s.updates = s.spare[:0]
+	s.updateNeeded.Store(false)
+	m.pending.Add(-int64(len(updates)))
+	s.updateMu.Unlock()
+	for _, u := range updates {
+		switch u.op {
+		case connsMapAddConnID:
+			s.byConnID[u.cid] = u.c
+		case connsMapRetireConnID:
+			delete(s.byConnID, u.cid)
+		case connsMapAddResetToken:
+			s.byResetToken[u.token] = u.c
+		case connsMapRetireResetToken:
+			delete(s.byResetToken, u.token)
+		case connsMapAddTombstone:
+			// Tombstones are created with a fixed lifetime,
+			// so any tombstone expiring before this one was created has expired.
+			s.purgeTombstones(u.tomb.expires.Add(-connTombstoneDuration))
+			delete(s.byConnID, u.cid)
+			s.tombstones[u.cid] = u.tomb
+			s.tombstoneQueue = append(s.tombstoneQueue, u.tomb)
+		}
+	}
+	clear(updates)
+	s.spare = updates[:0]
+}
+
+// tombstone returns the unexpired tombstone for a conn id, if any.
+// It discards expired tombstones.
+func (m *connsMap) tombstone(now time.Time, cid []byte) *connTombstone {
+	s := m.shard(cid)
+	m.applyUpdates(s)
+	s.purgeTombstones(now)
+	return s.tombstones[string(cid)]
+}
+
+// purgeTombstones discards the shard's tombstones which have expired by now.
+//
+// Expired tombstones are purged when a tombstone is looked up,
+// and when a new tombstone is added to the shard.
+func (s *connsMapShard) purgeTombstones(now time.Time) {
+	for len(s.tombstoneQueue) > 0 && !s.tombstoneQueue[0].expires.After(now) {
+		tomb := s.tombstoneQueue[0]
+		for _, cid := range tomb.cids {
+			if s.tombstones[string(cid)] == tomb {
+				delete(s.tombstones, string(cid))
+			}
+		}
+		s.tombstoneQueue[0] = nil
+		s.tombstoneQueue = s.tombstoneQueue[1:]
+	}
+}
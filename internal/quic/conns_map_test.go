@@ -0,0 +1,175 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestConnsMapConnIDs(t *testing.T) {
+	var m connsMap
+	m.init()
+	c1, c2 := &Conn{}, &Conn{}
+	var cids [][]byte
+	for i := 0; i < 2*connsMapShards; i++ {
+		cid := []byte(fmt.Sprintf("cid-%v", i))
+		cids = append(cids, cid)
+		if i%2 == 0 {
+			m.addConnID(c1, cid)
+		} else {
+			m.addConnID(c2, cid)
+		}
+	}
+	for i, cid := range cids {
+		want := c1
+		if i%2 != 0 {
+			want = c2
+		}
+		if got := m.connForID(cid); got != want {
+			t.Errorf("connForID(%q) = %p, want %p", cid, got, want)
+		}
+	}
+	m.retireConnID(c1, cids[0])
+	if got := m.connForID(cids[0]); got != nil {
+		t.Errorf("connForID(%q) after retiring = %p, want nil", cids[0], got)
+	}
+	if got := m.connForID([]byte("unknown")); got != nil {
+		t.Errorf("connForID(unknown) = %p, want nil", got)
+	}
+}
+
+func TestConnsMapResetTokens(t *testing.T) {
+	var m connsMap
+	m.init()
+	c := &Conn{}
+	token := statelessResetToken{1, 2, 3}
+	m.addResetToken(c, token)
+	if got := m.connForResetToken(token); got != c {
+		t.Errorf("connForResetToken = %p, want %p", got, c)
+	}
+	m.retireResetToken(c, token)
+	if got := m.connForResetToken(token); got != nil {
+		t.Errorf("connForResetToken after retiring = %p, want nil", got)
+	}
+}
+
+func TestConnsMapUpdatesAppliedPerShard(t *testing.T) {
+	var m connsMap
+	m.init()
+	c := &Conn{}
+	cid1 := []byte("cid-1")
+	var cid2 []byte
+	for i := 2; cid2 == nil; i++ {
+		cid := []byte(fmt.Sprintf("cid-%v", i))
+		if m.shard(cid) != m.shard(cid1) {
+			cid2 = cid
+		}
+	}
+	m.addConnID(c, cid1)
+	m.addConnID(c, cid2)
+	m.connForID(cid1)
+	if s := m.shard(cid2); len(s.byConnID) != 0 || len(s.updates) != 1 {
+		t.Errorf("after lookup in another shard: shard has %v conn ids and %v queued updates, want 0 and 1", len(s.byConnID), len(s.updates))
+	}
+	m.applyAllUpdates()
+	if s := m.shard(cid2); len(s.byConnID) != 1 || len(s.updates) != 0 {
+		t.Errorf("after applyAllUpdates: shard has %v conn ids and %v queued updates, want 1 and 0", len(s.byConnID), len(s.updates))
+	}
+}
+
+func TestConnsMapUpdateQueueBounded(t *testing.T) {
+	// Conns come and go, while the receive loop only looks up a single conn id.
+	// The reset token shards are never looked up,
+	// but their queued updates must still be applied.
+	var m connsMap
+	m.init()
+	active := &Conn{}
+	activeID := []byte("active")
+	m.addConnID(active, activeID)
+	for i := 0; i < 100*connsMapMaxPendingUpdates; i++ {
+		c := &Conn{}
+		cid := []byte(fmt.Sprintf("cid-%v", i))
+		token := statelessResetToken{byte(i), byte(i >> 8), byte(i >> 16)}
+		m.addConnID(c, cid)
+		m.addResetToken(c, token)
+		m.retireResetToken(c, token)
+		m.retireConnID(c, cid)
+		if got := m.connForID(activeID); got != active {
+			t.Fatalf("connForID(%q) = %p, want %p", activeID, got, active)
+		}
+		queued := 0
+		for i := range m.shards {
+			queued += len(m.shards[i].updates)
+		}
+		if queued > connsMapMaxPendingUpdates {
+			t.Fatalf("after %v conns: %v queued updates, want at most %v", i+1, queued, connsMapMaxPendingUpdates)
+		}
+	}
+}
+
+func TestConnsMapTombstones(t *testing.T) {
+	var m connsMap
+	m.init()
+	c := &Conn{}
+	now := time.Now()
+	cids := [][]byte{[]byte("cid-1"), []byte("cid-2")}
+	for _, cid := range cids {
+		m.addConnID(c, cid)
+	}
+	tomb := &connTombstone{
+		cids:    cids,
+		expires: now.Add(connTombstoneDuration),
+	}
+	m.addTombstone(c, tomb)
+	for _, cid := range cids {
+		if got := m.connForID(cid); got != nil {
+			t.Errorf("connForID(%q) after adding tombstone = %p, want nil", cid, got)
+		}
+		if got := m.tombstone(now, cid); got != tomb {
+			t.Errorf("tombstone(%q) = %p, want %p", cid, got, tomb)
+		}
+	}
+	later := now.Add(connTombstoneDuration)
+	for _, cid := range cids {
+		if got := m.tombstone(later, cid); got != nil {
+			t.Errorf("tombstone(%q) after expiry = %p, want nil", cid, got)
+		}
+	}
+}
+
+func TestConnsMapTombstonesPurgedOnInsert(t *testing.T) {
+	// Expired tombstones are discarded when a new tombstone is added,
+	// even if no tombstone is ever looked up.
+	var m connsMap
+	m.init()
+	c := &Conn{}
+	now := time.Now()
+	cid1 := []byte("cid-1")
+	var cid2 []byte
+	for i := 2; cid2 == nil; i++ {
+		cid := []byte(fmt.Sprintf("cid-%v", i))
+		if m.shard(cid) == m.shard(cid1) {
+			cid2 = cid
+		}
+	}
+	m.addTombstone(c, &connTombstone{
+		cids:    [][]byte{cid1},
+		expires: now.Add(connTombstoneDuration),
+	})
+	later := now.Add(connTombstoneDuration)
+	m.addTombstone(c, &connTombstone{
+		cids:    [][]byte{cid2},
+		expires: later.Add(connTombstoneDuration),
+	})
+	m.applyAllUpdates()
+	s := m.shard(cid1)
+	if _, ok := s.tombstones[string(cid1)]; ok || len(s.tombstoneQueue) != 1 {
+		t.Errorf("after adding a tombstone: expired tombstone is present, shard has %v queued tombstones; want expired tombstone removed, 1 queued tombstone", len(s.tombstoneQueue))
+	}
+}
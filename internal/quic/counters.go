@@ -0,0 +1,319 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// PacketCounts counts QUIC packets by type.
+type PacketCounts struct {
+	Initial            uint64
+	ZeroRTT            uint64
+	Handshake          uint64
+	Retry              uint64
+	OneRTT             uint64
+	VersionNegotiation uint64
+}
+
+// FrameCounts counts QUIC frames by type.
+// PADDING frames are not counted.
+type FrameCounts struct {
+	Ping               uint64
+	Ack                uint64
+	ResetStream        uint64
+	StopSending        uint64
+	Crypto             uint64
+	NewToken           uint64
+	Stream             uint64
+	MaxData            uint64
+	MaxStreamData      uint64
+	MaxStreams         uint64
+	DataBlocked        uint64
+	StreamDataBlocked  uint64
+	StreamsBlocked     uint64
+	NewConnectionID    uint64
+	RetireConnectionID uint64
+	PathChallenge      uint64
+	PathResponse       uint64
+	ConnectionClose    uint64
+	HandshakeDone      uint64
+	Datagram           uint64
+	AckFrequency       uint64
+	ImmediateAck       uint64
+}
+
+// ConnStats contains counters of Conn activity.
+type ConnStats struct {
+	PacketsReceived PacketCounts // packets successfully decrypted and processed
+	FramesReceived  FrameCounts
+}
+
+// Stats returns a snapshot of the Conn's counters.
+func (c *Conn) Stats() ConnStats {
+	return ConnStats{
+		PacketsReceived: c.counters.packets.counts(),
+		FramesReceived:  c.counters.frames.counts(),
+	}
+}
+
+// A FrameThresholdTracer is a ConnTracer which is notified
+// when a connection exceeds one of the Config.FrameThresholds.
+type FrameThresholdTracer interface {
+	FrameThresholdExceeded(now time.Time, frameType string, count uint64)
+}
+
+// connCounters holds a Conn's counters.
+//
+// Counters are only written by the conn's loop,
+// but may be read by other goroutines.
+type connCounters struct {
+	packets packetCounters
+	frames  frameCounters
+
+	// Configured per-frame-type thresholds, and the set of thresholds exceeded.
+	thresholds [frameCounterCount]uint64
+	exceeded   uint32
+}
+
+func (c *Conn) countersInit() {
+	c.counters.thresholds = c.config.FrameThresholds.array()
+}
+
+// countPacket records the receipt of a packet.
+func (c *Conn) countPacket(ptype packetType) {
+	c.counters.packets.add(ptype)
+}
+
+// countFrame records the receipt of a frame with the given type.
+// It reports whether the connection should continue processing frames.
+func (c *Conn) countFrame(now time.Time, ftype uint64) bool {
+	i := frameCounterIndex(ftype)
+	if i < 0 {
+		return true
+	}
+	count := c.counters.frames[i].Add(1)
+	limit := c.counters.thresholds[i]
+	if limit == 0 || count <= limit || c.counters.exceeded&(1<<i) != 0 {
+		return true
+	}
+	c.counters.exceeded |= 1 << i
+	name := frameCounterNames[i]
+	if t, ok := c.trace.t.(FrameThresholdTracer); ok {
+		t.FrameThresholdExceeded(now, name, count)
+	}
+	if c.config.OnFrameThreshold == nil {
+		return true
+	}
+	if err := c.config.OnFrameThreshold(c, name, count); err != nil {
+		c.abort(now, err)
+		return false
+	}
+	return true
+}
+
+// Indexes into frameCounters, in the order of the fields of FrameCounts.
+const (
+	frameCounterPing = iota
+	frameCounterAck
+	frameCounterResetStream
+	frameCounterStopSending
+	frameCounterCrypto
+	frameCounterNewToken
+	frameCounterStream
+	frameCounterMaxData
+	frameCounterMaxStreamData
+	frameCounterMaxStreams
+	frameCounterDataBlocked
+	frameCounterStreamDataBlocked
+	frameCounterStreamsBlocked
+	frameCounterNewConnectionID
+	frameCounterRetireConnectionID
+	frameCounterPathChallenge
+	frameCounterPathResponse
+	frameCounterConnectionClose
+	frameCounterHandshakeDone
+	frameCounterDatagram
+	frameCounterAckFrequency
+	frameCounterImmediateAck
+	frameCounterCount
+)
+
+var frameCounterNames = [frameCounterCount]string{
+	frameCounterPing:               "PING",
+	frameCounterAck:                "ACK",
+	frameCounterResetStream:        "RESET_STREAM",
+	frameCounterStopSending:        "STOP_SENDING",
+	frameCounterCrypto:             "CRYPTO",
+	frameCounterNewToken:           "NEW_TOKEN",
+	frameCounterStream:             "STREAM",
+	frameCounterMaxData:            "MAX_DATA",
+	frameCounterMaxStreamData:      "MAX_STREAM_DATA",
+	frameCounterMaxStreams:         "MAX_STREAMS",
+	frameCounterDataBlocked:        "DATA_BLOCKED",
+	frameCounterStreamDataBlocked:  "STREAM_DATA_BLOCKED",
+	frameCounterStreamsBlocked:     "STREAMS_BLOCKED",
+	frameCounterNewConnectionID:    "NEW_CONNECTION_ID",
+	frameCounterRetireConnectionID: "RETIRE_CONNECTION_ID",
+	frameCounterPathChallenge:      "PATH_CHALLENGE",
+	frameCounterPathResponse:       "PATH_RESPONSE",
+	frameCounterConnectionClose:    "CONNECTION_CLOSE",
+	frameCounterHandshakeDone:      "HANDSHAKE_DONE",
+	frameCounterDatagram:           "DATAGRAM",
+	frameCounterAckFrequency:       "ACK_FREQUENCY",
+	frameCounterImmediateAck:       "IMMEDIATE_ACK",
+}
+
+// frameCounterIndex returns the frameCounters index for a frame type,
+// or -1 if frames of this type are not counted.
+func frameCounterIndex(ftype uint64) int {
+	switch ftype {
+	case frameTypePing:
+		return frameCounterPing
+	case frameTypeAck, frameTypeAckECN:
+		return frameCounterAck
+	case frameTypeResetStream:
+		return frameCounterResetStream
+	case frameTypeStopSending:
+		return frameCounterStopSending
+	case frameTypeCrypto:
+		return frameCounterCrypto
+	case frameTypeNewToken:
+		return frameCounterNewToken
+	case 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f:
+		return frameCounterStream
+	case frameTypeMaxData:
+		return frameCounterMaxData
+	case frameTypeMaxStreamData:
+		return frameCounterMaxStreamData
+	case frameTypeMaxStreamsBidi, frameTypeMaxStreamsUni:
+		return frameCounterMaxStreams
+	case frameTypeDataBlocked:
+		return frameCounterDataBlocked
+	case frameTypeStreamDataBlocked:
+		return frameCounterStreamDataBlocked
+	case frameTypeStreamsBlockedBidi, frameTypeStreamsBlockedUni:
+		return frameCounterStreamsBlocked
+	case frameTypeNewConnectionID:
+		return frameCounterNewConnectionID
+	case frameTypeRetireConnectionID:
+		return frameCounterRetireConnectionID
+	case frameTypePathChallenge:
+		return frameCounterPathChallenge
+	case frameTypePathResponse:
+		return frameCounterPathResponse
+	case frameTypeConnectionCloseTransport, frameTypeConnectionCloseApplication:
+		return frameCounterConnectionClose
+	case frameTypeHandshakeDone:
+		return frameCounterHandshakeDone
+	case frameTypeDatagram, frameTypeDatagramWithLength:
+		return frameCounterDatagram
+	case frameTypeAckFrequency:
+		return frameCounterAckFrequency
+	case frameTypeImmediateAck:
+		return frameCounterImmediateAck
+	}
+	return -1
+}
+
+// frameCounters counts frames, indexed by frameCounterIndex.
+type frameCounters [frameCounterCount]atomic.Uint64
+
+func (fc *frameCounters) addAll(o *frameCounters) {
+	for i := range fc {
+		fc[i].Add(o[i].Load())
+	}
+}
+
+func (fc *frameCounters) counts() FrameCounts {
+	var a [frameCounterCount]uint64
+	for i := range fc {
+		a[i] = fc[i].Load()
+	}
+	return frameCountsFromArray(a)
+}
+
+func (f FrameCounts) array() [frameCounterCount]uint64 {
+	return [frameCounterCount]uint64{
+		frameCounterPing:               f.Ping,
+		frameCounterAck:                f.Ack,
+		frameCounterResetStream:        f.ResetStream,
+		frameCounterStopSending:        f.StopSending,
+		frameCounterCrypto:             f.Crypto,
+		frameCounterNewToken:           f.NewToken,
+		frameCounterStream:             f.Stream,
+		frameCounterMaxData:            f.MaxData,
+		frameCounterMaxStreamData:      f.MaxStreamData,
+		frameCounterMaxStreams:         f.MaxStreams,
+		frameCounterDataBlocked:        f.DataBlocked,
+		frameCounterStreamDataBlocked:  f.StreamDataBlocked,
+		frameCounterStreamsBlocked:     f.StreamsBlocked,
+		frameCounterNewConnectionID:    f.NewConnectionID,
+		frameCounterRetireConnectionID: f.RetireConnectionID,
+		frameCounterPathChallenge:      f.PathChallenge,
+		frameCounterPathResponse:       f.PathResponse,
+		frameCounterConnectionClose:    f.ConnectionClose,
+		frameCounterHandshakeDone:      f.HandshakeDone,
+		frameCounterDatagram:           f.Datagram,
+		frameCounterAckFrequency:       f.AckFrequency,
+		frameCounterImmediateAck:       f.ImmediateAck,
+	}
+}
+
+func frameCountsFromArray(a [frameCounterCount]uint64) FrameCounts {
+	return FrameCounts{
+		Ping:               a[frameCounterPing],
+		Ack:                a[frameCounterAck],
+		ResetStream:        a[frameCounterResetStream],
+		StopSending:        a[frameCounterStopSending],
+		Crypto:             a[frameCounterCrypto],
+		NewToken:           a[frameCounterNewToken],
+		Stream:             a[frameCounterStream],
+		MaxData:            a[frameCounterMaxData],
+		MaxStreamData:      a[frameCounterMaxStreamData],
+		MaxStreams:         a[frameCounterMaxStreams],
+		DataBlocked:        a[frameCounterDataBlocked],
+		StreamDataBlocked:  a[frameCounterStreamDataBlocked],
+		StreamsBlocked:     a[frameCounterStreamsBlocked],
+		NewConnectionID:    a[frameCounterNewConnectionID],
+		RetireConnectionID: a[frameCounterRetireConnectionID],
+		PathChallenge:      a[frameCounterPathChallenge],
+		PathResponse:       a[frameCounterPathResponse],
+		ConnectionClose:    a[frameCounterConnectionClose],
+		HandshakeDone:      a[frameCounterHandshakeDone],
+		Datagram:           a[frameCounterDatagram],
+		AckFrequency:       a[frameCounterAckFrequency],
+		ImmediateAck:       a[frameCounterImmediateAck],
+	}
+}
+
+// packetCounters counts packets, indexed by packetType.
+type packetCounters [packetTypeVersionNegotiation + 1]atomic.Uint64
+
+func (pc *packetCounters) add(ptype packetType) {
+	if int(ptype) < len(pc) {
+		pc[ptype].Add(1)
+	}
+}
+
+func (pc *packetCounters) addAll(o *packetCounters) {
+	for i := range pc {
+		pc[i].Add(o[i].Load())
+	}
+}
+
+func (pc *packetCounters) counts() PacketCounts {
+	return PacketCounts{
+		Initial:            pc[packetTypeInitial].Load(),
+		ZeroRTT:            pc[packetType0RTT].Load(),
+		Handshake:          pc[packetTypeHandshake].Load(),
+		Retry:              pc[packetTypeRetry].Load(),
+		OneRTT:             pc[packetType1RTT].Load(),
+		VersionNegotiation: pc[packetTypeVersionNegotiation].Load(),
+	}
+}
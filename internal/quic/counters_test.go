@@ -0,0 +1,104 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConnStatsCounts(t *testing.T) {
+	tc := newTestConn(t, clientSide)
+	tc.handshake()
+	tc.ignoreFrame(frameTypeAck)
+
+	tc.writeFrames(packetType1RTT,
+		debugFramePing{},
+		debugFramePing{},
+		debugFrameMaxData{max: 1 << 20})
+
+	stats := tc.conn.Stats()
+	if stats.PacketsReceived.Initial == 0 || stats.PacketsReceived.Handshake == 0 || stats.PacketsReceived.OneRTT == 0 {
+		t.Errorf("PacketsReceived = %+v, want nonzero Initial, Handshake, and 1-RTT counts", stats.PacketsReceived)
+	}
+	if got, want := stats.FramesReceived.Ping, uint64(2); got != want {
+		t.Errorf("FramesReceived.Ping = %v, want %v", got, want)
+	}
+	if got, want := stats.FramesReceived.MaxData, uint64(1); got != want {
+		t.Errorf("FramesReceived.MaxData = %v, want %v", got, want)
+	}
+	if got, want := stats.FramesReceived.HandshakeDone, uint64(1); got != want {
+		t.Errorf("FramesReceived.HandshakeDone = %v, want %v", got, want)
+	}
+	if stats.FramesReceived.Crypto == 0 {
+		t.Errorf("FramesReceived.Crypto = 0, want nonzero")
+	}
+}
+
+type thresholdTestTracer struct {
+	*testTracer
+	exceeded []string
+}
+
+func (t *thresholdTestTracer) FrameThresholdExceeded(now time.Time, frameType string, count uint64) {
+	t.exceeded = append(t.exceeded, frameType)
+}
+
+func TestFrameThreshold(t *testing.T) {
+	type call struct {
+		frameType string
+		count     uint64
+	}
+	var calls []call
+	tr := &thresholdTestTracer{testTracer: &testTracer{}}
+	tc := newTestConn(t, clientSide, func(c *Config) {
+		c.FrameThresholds.Ping = 2
+		c.OnFrameThreshold = func(c *Conn, frameType string, count uint64) error {
+			calls = append(calls, call{frameType, count})
+			return nil
+		}
+		c.NewTracer = func(*Conn) ConnTracer {
+			return tr
+		}
+	})
+	tc.handshake()
+	tc.ignoreFrame(frameTypeAck)
+
+	tc.writeFrames(packetType1RTT, debugFramePing{}, debugFramePing{})
+	if len(calls) != 0 {
+		t.Fatalf("after receiving 2 PINGs with threshold of 2: OnFrameThreshold called %v", calls)
+	}
+	tc.writeFrames(packetType1RTT, debugFramePing{})
+	tc.writeFrames(packetType1RTT, debugFramePing{})
+	if want := []call{{"PING", 3}}; len(calls) != 1 || calls[0] != want[0] {
+		t.Fatalf("after receiving 4 PINGs with threshold of 2: OnFrameThreshold called %v, want %v", calls, want)
+	}
+	if len(tr.exceeded) != 1 || tr.exceeded[0] != "PING" {
+		t.Errorf("tracer FrameThresholdExceeded called with %v, want [PING]", tr.exceeded)
+	}
+	tc.wantIdle("connection remains open when OnFrameThreshold returns nil")
+}
+
+func TestFrameThresholdAbort(t *testing.T) {
+	tc := newTestConn(t, serverSide, func(c *Config) {
+		c.FrameThresholds.MaxData = 1
+		c.OnFrameThreshold = func(c *Conn, frameType string, count uint64) error {
+			return &ApplicationError{Code: 1, Reason: frameType}
+		}
+	})
+	tc.handshake()
+	tc.ignoreFrame(frameTypeAck)
+
+	tc.writeFrames(packetType1RTT, debugFrameMaxData{max: 1 << 20})
+	tc.wantIdle("first MAX_DATA does not exceed threshold")
+	tc.writeFrames(packetType1RTT, debugFrameMaxData{max: 1 << 21})
+	tc.wantFrame("OnFrameThreshold error closes the connection",
+		packetType1RTT, debugFrameConnectionCloseApplication{
+			code:   1,
+			reason: "MAX_DATA",
+		})
+}
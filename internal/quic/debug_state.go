@@ -0,0 +1,278 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"encoding/hex"
+	"net/netip"
+	"sort"
+	"time"
+)
+
+// A ConnDebugState is a snapshot of a connection's internal state,
+// for use in bug reports and debugging endpoints.
+//
+// The contents of a ConnDebugState are intended for humans,
+// and may change from one version of this package to the next.
+type ConnDebugState struct {
+	Time     time.Time // time the snapshot was taken
+	Side     string    // "client" or "server"
+	PeerAddr netip.AddrPort
+	State    string // "handshake", "established", "closing", "draining", or "closed"
+
+	LocalConnIDs  []DebugConnID // connection IDs the peer may send to
+	RemoteConnIDs []DebugConnID // connection IDs we may send to
+
+	NumberSpaces []DebugNumberSpace
+	KeyUpdates   int64 // number of completed 1-RTT key updates
+	Congestion   TraceCongestion
+	Flow         DebugFlowControl
+	Streams      []DebugStream // ordered by ID
+}
+
+// A DebugConnID describes a connection ID.
+type DebugConnID struct {
+	ID      string // hex-encoded
+	Seq     int64  // sequence number, or -1 for a client's transient Initial ID
+	Retired bool
+}
+
+// A DebugNumberSpace describes the state of a packet number space.
+type DebugNumberSpace struct {
+	Name             string // "Initial", "Handshake", or "Application Data"
+	NextPacketNumber int64  // number of the next packet to send
+	LargestAcked     int64  // largest packet number acknowledged by the peer, or -1
+	LargestReceived  int64  // largest packet number received from the peer, or -1
+	InFlight         []DebugSentPacket
+}
+
+// A DebugSentPacket describes a sent packet
+// which has not yet been acknowledged or declared lost.
+type DebugSentPacket struct {
+	Number       int64
+	Size         int
+	Time         time.Time // time sent
+	AckEliciting bool
+}
+
+// DebugFlowControl describes connection-level flow control limits.
+type DebugFlowControl struct {
+	SendLimit int64 // largest MAX_DATA received from the peer
+	Sent      int64 // bytes of stream data sent
+	RecvLimit int64 // largest MAX_DATA sent to the peer
+	Received  int64 // bytes of stream data received
+}
+
+// A DebugStream describes the state of a stream.
+//
+// Stream states are named as in RFC 9000, Section 3.
+// SendState is empty for streams which only receive,
+// and RecvState is empty for streams which only send.
+type DebugStream struct {
+	ID    int64
+	Type  string // "bidi" or "uni"
+	Local bool   // opened by this endpoint
+
+	SendState string
+	Sent      int64 // largest offset sent
+	Acked     int64 // offset up to which all sent data is acknowledged
+	SendLimit int64 // largest MAX_STREAM_DATA received from the peer
+	Buffered  int64 // bytes written but not yet acknowledged
+
+	RecvState string
+	Received  int64 // largest offset received
+	Read      int64 // offset up to which data has been read
+	RecvLimit int64 // largest MAX_STREAM_DATA sent to the peer
+	FinalSize int64 // -1 if not known
+}
+
+// DebugState returns a snapshot of the connection's internal state.
+func (c *Conn) DebugState() ConnDebugState {
+	var st ConnDebugState
+	if err := c.runOnLoop(func(now time.Time, c *Conn) {
+		st = c.debugState(now)
+	}); err != nil {
+		st = ConnDebugState{
+			Time:     c.listener.timeNow(),
+			Side:     c.side.String(),
+			PeerAddr: c.peerAddr,
+			State:    "closed",
+		}
+	}
+	return st
+}
+
+func (c *Conn) debugState(now time.Time) ConnDebugState {
+	st := ConnDebugState{
+		Time:     now,
+		Side:     c.side.String(),
+		PeerAddr: c.peerAddr,
+	}
+	switch {
+	case c.isDraining():
+		st.State = "draining"
+	case c.isClosingOrDraining():
+		st.State = "closing"
+	case c.handshakeConfirmed.isSet():
+		st.State = "established"
+	default:
+		st.State = "handshake"
+	}
+	for _, id := range c.connIDState.local {
+		st.LocalConnIDs = append(st.LocalConnIDs, debugConnID(id))
+	}
+	for _, id := range c.connIDState.remote {
+		st.RemoteConnIDs = append(st.RemoteConnIDs, debugConnID(id.connID))
+	}
+	for space := initialSpace; space < numberSpaceCount; space++ {
+		st.NumberSpaces = append(st.NumberSpaces, c.debugNumberSpace(space))
+	}
+	st.KeyUpdates = c.keysAppData.updates
+	st.Congestion = TraceCongestion{
+		CongestionWindow:   c.loss.cc.congestionWindow,
+		BytesInFlight:      c.loss.cc.bytesInFlight,
+		SlowStartThreshold: c.loss.cc.slowStartThreshold,
+		SmoothedRTT:        c.loss.rtt.smoothedRTT,
+		MinRTT:             c.loss.rtt.minRTT,
+		RTTVariation:       c.loss.rtt.rttvar,
+	}
+	st.Flow = DebugFlowControl{
+		SendLimit: c.streams.outflow.max,
+		Sent:      c.streams.outflow.used,
+		RecvLimit: c.streams.inflow.sentLimit,
+		Received:  c.streams.inflow.usedLimit,
+	}
+	c.streams.streamsMu.Lock()
+	streams := make([]*Stream, 0, len(c.streams.streams))
+	for _, s := range c.streams.streams {
+		if s != nil {
+			streams = append(streams, s)
+		}
+	}
+	c.streams.streamsMu.Unlock()
+	sort.Slice(streams, func(i, j int) bool {
+		return streams[i].id < streams[j].id
+	})
+	for _, s := range streams {
+		st.Streams = append(st.Streams, s.debugState())
+	}
+	return st
+}
+
+func debugConnID(id connID) DebugConnID {
+	return DebugConnID{
+		ID:      hex.EncodeToString(id.cid),
+		Seq:     id.seq,
+		Retired: id.retired,
+	}
+}
+
+func (c *Conn) debugNumberSpace(space numberSpace) DebugNumberSpace {
+	sp := &c.loss.spaces[space]
+	ds := DebugNumberSpace{
+		Name:             space.String(),
+		NextPacketNumber: int64(sp.nextNum),
+		LargestAcked:     int64(sp.maxAcked),
+		LargestReceived:  -1,
+	}
+	if seen := c.acks[space].seen; seen.numRanges() > 0 {
+		ds.LargestReceived = int64(seen.max())
+	}
+	for i := 0; i < sp.size; i++ {
+		sent := sp.nth(i)
+		if sent.acked || sent.lost {
+			continue
+		}
+		ds.InFlight = append(ds.InFlight, DebugSentPacket{
+			Number:       int64(sent.num),
+			Size:         sent.size,
+			Time:         sent.time,
+			AckEliciting: sent.ackEliciting,
+		})
+	}
+	return ds
+}
+
+func (s *Stream) debugState() DebugStream {
+	ds := DebugStream{
+		ID:        int64(s.id),
+		Type:      s.id.streamType().String(),
+		Local:     s.id.initiator() == s.conn.side,
+		FinalSize: -1,
+	}
+	state := s.state.load()
+	if !s.IsReadOnly() {
+		s.outgate.lock()
+		ds.Sent = s.outmaxsent
+		ds.Acked = s.outacked.rangeContaining(0).end
+		ds.SendLimit = s.outwin
+		ds.Buffered = s.out.end - s.out.start
+		switch {
+		case s.outreset.isSet() && state&streamOutDone != 0:
+			ds.SendState = "Reset Recvd"
+		case s.outreset.isSet():
+			ds.SendState = "Reset Sent"
+		case s.outclosed.isSet() && state&streamOutDone != 0:
+			ds.SendState = "Data Recvd"
+		case s.outclosed.isSet():
+			ds.SendState = "Data Sent"
+		default:
+			ds.SendState = "Send"
+		}
+		s.outUnlock()
+	}
+	if !s.IsWriteOnly() {
+		s.ingate.lock()
+		ds.Received = s.inset.end()
+		ds.Read = s.in.start
+		ds.RecvLimit = s.inwin
+		ds.FinalSize = s.insize
+		switch {
+		case s.inresetcode != -1 && state&streamInDone != 0:
+			ds.RecvState = "Reset Read"
+		case s.inresetcode != -1:
+			ds.RecvState = "Reset Recvd"
+		case s.insize >= 0 && state&streamInDone != 0:
+			ds.RecvState = "Data Read"
+		case s.insize >= 0 && s.inset.isrange(0, s.insize):
+			ds.RecvState = "Data Recvd"
+		case s.insize >= 0:
+			ds.RecvState = "Size Known"
+		default:
+			ds.RecvState = "Recv"
+		}
+		s.inUnlock()
+	}
+	return ds
+}
+
+// A ListenerDebugState is a snapshot of a Listener's internal state,
+// for use in bug reports and debugging endpoints.
+type ListenerDebugState struct {
+	LocalAddr netip.AddrPort
+	Stats     ListenerStats
+	Conns     []ConnDebugState
+}
+
+// DebugState returns a snapshot of the Listener's state,
+// including the state of each of its connections.
+func (l *Listener) DebugState() ListenerDebugState {
+	st := ListenerDebugState{
+		LocalAddr: l.LocalAddr(),
+		Stats:     l.Stats(),
+	}
+	l.connsMu.Lock()
+	conns := make([]*Conn, 0, len(l.conns))
+	for c := range l.conns {
+		conns = append(conns, c)
+	}
+	l.connsMu.Unlock()
+	for _, c := range conns {
+		st.Conns = append(st.Conns, c.DebugState())
+	}
+	return st
+}
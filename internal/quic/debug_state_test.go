@@ -0,0 +1,95 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"context"
+	"testing"
+)
+
+func TestConnDebugState(t *testing.T) {
+	tc, s := newTestConnAndLocalStream(t, clientSide, bidiStream, permissiveTransportParameters)
+	s.Write(make([]byte, 100))
+	s.CloseWrite()
+	tc.wantFrameType("stream data is sent",
+		packetType1RTT, debugFrameStream{})
+
+	st := tc.conn.debugState(tc.listener.now)
+	if got, want := st.State, "established"; got != want {
+		t.Errorf("State = %q, want %q", got, want)
+	}
+	if got, want := st.Side, "client"; got != want {
+		t.Errorf("Side = %q, want %q", got, want)
+	}
+	if len(st.LocalConnIDs) == 0 || len(st.RemoteConnIDs) == 0 {
+		t.Errorf("LocalConnIDs = %v, RemoteConnIDs = %v; want non-empty", st.LocalConnIDs, st.RemoteConnIDs)
+	}
+	if got := len(st.NumberSpaces); got != int(numberSpaceCount) {
+		t.Fatalf("len(NumberSpaces) = %v, want %v", got, numberSpaceCount)
+	}
+	app := st.NumberSpaces[appDataSpace]
+	if len(app.InFlight) == 0 {
+		t.Errorf("1-RTT space has no packets in flight, want stream data in flight")
+	}
+	if got, want := st.Flow.Sent, int64(100); got != want {
+		t.Errorf("Flow.Sent = %v, want %v", got, want)
+	}
+	if len(st.Streams) != 1 {
+		t.Fatalf("Streams = %v, want one stream", st.Streams)
+	}
+	ds := st.Streams[0]
+	if ds.ID != s.ID() || !ds.Local || ds.Type != "bidi" {
+		t.Errorf("stream = %+v, want local bidi stream %v", ds, s.ID())
+	}
+	if got, want := ds.SendState, "Data Sent"; got != want {
+		t.Errorf("stream SendState = %q, want %q", got, want)
+	}
+	if got, want := ds.RecvState, "Recv"; got != want {
+		t.Errorf("stream RecvState = %q, want %q", got, want)
+	}
+	if got, want := ds.Sent, int64(100); got != want {
+		t.Errorf("stream Sent = %v, want %v", got, want)
+	}
+
+	tc.writeAckForAll()
+	st = tc.conn.debugState(tc.listener.now)
+	if got, want := st.Streams[0].SendState, "Data Recvd"; got != want {
+		t.Errorf("after ack, stream SendState = %q, want %q", got, want)
+	}
+}
+
+func TestListenerDebugState(t *testing.T) {
+	cli, srv := newLocalConnPair(t, &Config{}, &Config{})
+	ctx := context.Background()
+	s, err := cli.NewStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Write([]byte("hello"))
+	s.CloseWrite()
+	if _, err := srv.AcceptStream(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	st := srv.listener.DebugState()
+	if len(st.Conns) != 1 {
+		t.Fatalf("server Listener has %v conns, want 1", len(st.Conns))
+	}
+	cs := st.Conns[0]
+	if cs.Side != "server" {
+		t.Errorf("conn Side = %q, want server", cs.Side)
+	}
+	if len(cs.Streams) != 1 || cs.Streams[0].Local {
+		t.Errorf("conn Streams = %+v, want one remote stream", cs.Streams)
+	}
+
+	srv.exit()
+	<-srv.donec
+	if got := srv.DebugState().State; got != "closed" {
+		t.Errorf("after conn exits, DebugState().State = %q, want closed", got)
+	}
+}
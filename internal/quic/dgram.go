@@ -12,27 +12,111 @@ import (
 )
 
 type datagram struct {
-	b    []byte
-	addr netip.AddrPort
+	b         []byte
+	addr      netip.AddrPort
+	localAddr netip.Addr    // local address a received datagram was sent to, if known
+	ecn       ecnBits       // ECN codepoint of a received datagram, if known
+	marked    bool          // marked by Config.DatagramFilter
+	pool      *datagramPool // pool the datagram is returned to by recycle, or nil
 }
 
-var datagramPool = sync.Pool{
-	New: func() any {
-		return &datagram{
-			b: make([]byte, maxUDPPayloadSize),
-		}
-	},
+// ecnBits is the ECN (Explicit Congestion Notification) codepoint of a datagram.
+// https://www.rfc-editor.org/rfc/rfc3168#section-5
+type ecnBits byte
+
+const (
+	ecnNotECT = ecnBits(0b00)
+	ecnECT1   = ecnBits(0b01)
+	ecnECT0   = ecnBits(0b10)
+	ecnCE     = ecnBits(0b11)
+	ecnMask   = 0b11
+)
+
+func (m *datagram) recycle() {
+	if m.pool != nil {
+		m.pool.put(m)
+	}
 }
 
-func newDatagram() *datagram {
-	m := datagramPool.Get().(*datagram)
-	m.b = m.b[:cap(m.b)]
-	return m
+// A datagramPool is a pool of datagram buffers.
+//
+// Buffers are grouped into size classes by capacity,
+// and each class retains at most max unused buffers.
+// Buffers recycled to a full class are left to the garbage collector.
+type datagramPool struct {
+	size int // size of buffers returned by get
+	max  int // maximum number of unused buffers retained per size class
+
+	mu      sync.Mutex
+	classes []datagramSizeClass
 }
 
-func (m *datagram) recycle() {
-	if cap(m.b) != maxUDPPayloadSize {
+// maxDatagramSizeClasses is the maximum number of size classes in a datagramPool.
+const maxDatagramSizeClasses = 4
+
+type datagramSizeClass struct {
+	size int
+	free []*datagram
+}
+
+func (p *datagramPool) init(size, max int) {
+	p.size = size
+	p.max = max
+}
+
+// get returns a datagram with a buffer of the pool's default size.
+func (p *datagramPool) get() *datagram {
+	return p.getSize(p.size)
+}
+
+// getSize returns a datagram with a buffer of the given size.
+func (p *datagramPool) getSize(size int) *datagram {
+	p.mu.Lock()
+	if c := p.class(size); c != nil && len(c.free) > 0 {
+		m := c.free[len(c.free)-1]
+		c.free[len(c.free)-1] = nil
+		c.free = c.free[:len(c.free)-1]
+		p.mu.Unlock()
+		m.b = m.b[:size]
+		m.addr = netip.AddrPort{}
+		m.localAddr = netip.Addr{}
+		m.ecn = ecnNotECT
+		m.marked = false
+		return m
+	}
+	p.mu.Unlock()
+	return &datagram{
+		b:    make([]byte, size),
+		pool: p,
+	}
+}
+
+// put returns a datagram to the pool.
+func (p *datagramPool) put(m *datagram) {
+	if p.max <= 0 {
 		return
 	}
-	datagramPool.Put(m)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	c := p.class(cap(m.b))
+	if c == nil {
+		if len(p.classes) >= maxDatagramSizeClasses {
+			return
+		}
+		p.classes = append(p.classes, datagramSizeClass{size: cap(m.b)})
+		c = &p.classes[len(p.classes)-1]
+	}
+	if len(c.free) < p.max {
+		c.free = append(c.free, m)
+	}
+}
+
+// class returns the size class for buffers of the given size, or nil if none exists.
+func (p *datagramPool) class(size int) *datagramSizeClass {
+	for i := range p.classes {
+		if p.classes[i].size == size {
+			return &p.classes[i]
+		}
+	}
+	return nil
 }
@@ -0,0 +1,119 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"testing"
+)
+
+func TestDatagramPoolReuse(t *testing.T) {
+	var p datagramPool
+	p.init(1500, 2)
+	m1 := p.get()
+	if got, want := len(m1.b), 1500; got != want {
+		t.Fatalf("len(get().b) = %v, want %v", got, want)
+	}
+	m1.marked = true
+	m1.b = m1.b[:10]
+	m1.recycle()
+	m2 := p.get()
+	if m2 != m1 {
+		t.Errorf("get() after recycle returned new datagram, want recycled one")
+	}
+	if len(m2.b) != 1500 || m2.marked {
+		t.Errorf("recycled datagram: len(b) = %v, marked = %v; want 1500, false", len(m2.b), m2.marked)
+	}
+}
+
+func TestDatagramPoolMaxRetained(t *testing.T) {
+	var p datagramPool
+	p.init(1500, 2)
+	ms := []*datagram{p.get(), p.get(), p.get()}
+	for _, m := range ms {
+		m.recycle()
+	}
+	got := map[*datagram]bool{}
+	for i := 0; i < 3; i++ {
+		got[p.get()] = true
+	}
+	reused := 0
+	for _, m := range ms {
+		if got[m] {
+			reused++
+		}
+	}
+	if reused != 2 {
+		t.Errorf("reused %v of 3 recycled datagrams, want 2", reused)
+	}
+}
+
+func TestDatagramPoolSizeClasses(t *testing.T) {
+	var p datagramPool
+	p.init(1500, 4)
+	small := p.getSize(1200)
+	jumbo := p.getSize(9000)
+	small.recycle()
+	jumbo.recycle()
+	if m := p.getSize(9000); m != jumbo {
+		t.Errorf("getSize(9000) did not reuse 9000-byte buffer")
+	}
+	if m := p.getSize(1200); m != small {
+		t.Errorf("getSize(1200) did not reuse 1200-byte buffer")
+	}
+	if m := p.get(); cap(m.b) != 1500 {
+		t.Errorf("get() returned buffer with capacity %v, want 1500", cap(m.b))
+	}
+}
+
+func TestDatagramPoolNoRetain(t *testing.T) {
+	var p datagramPool
+	p.init(1500, (&Config{MaxIdleDatagramBuffers: -1}).maxIdleDatagramBuffers())
+	m := p.get()
+	m.recycle()
+	if p.get() == m {
+		t.Errorf("datagram retained with MaxIdleDatagramBuffers < 0")
+	}
+}
+
+func TestConfigDatagramBufferSize(t *testing.T) {
+	for _, test := range []struct {
+		size, want, wantRecv int
+	}{
+		{0, 1472, 1472},
+		{100, 1200, 1472},
+		{1300, 1300, 1472},
+		{9000, 9000, 9000},
+		{100000, 65527, 65527},
+	} {
+		c := &Config{DatagramBufferSize: test.size}
+		if got := c.datagramBufferSize(); got != test.want {
+			t.Errorf("DatagramBufferSize = %v: max_udp_payload_size %v, want %v", test.size, got, test.want)
+		}
+		if got := c.recvBufferSize(); got != test.wantRecv {
+			t.Errorf("DatagramBufferSize = %v: receive buffer size %v, want %v", test.size, got, test.wantRecv)
+		}
+	}
+}
+
+func TestDatagramBufferSizeSmallReceiveBuffers(t *testing.T) {
+	// A listener with a small DatagramBufferSize can still receive
+	// a client Initial filling a 1500-byte packet.
+	l := newLocalListener(t, serverSide, &Config{DatagramBufferSize: 1200})
+	if got, want := len(l.dgrams.get().b), maxUDPPayloadSize; got != want {
+		t.Errorf("receive buffer size = %v, want %v", got, want)
+	}
+}
+
+func TestDatagramBufferSizeTransportParameter(t *testing.T) {
+	tc := newTestConn(t, serverSide, func(c *Config) {
+		c.DatagramBufferSize = 9000
+	})
+	tc.handshake()
+	if got, want := tc.sentTransportParameters.maxUDPPayloadSize, int64(9000); got != want {
+		t.Errorf("max_udp_payload_size = %v, want %v", got, want)
+	}
+}
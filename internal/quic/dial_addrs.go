@@ -0,0 +1,178 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"time"
+)
+
+// DialAddrs creates and returns a connection to a network address
+// whose host may resolve to several IP addresses.
+//
+// DialAddrs resolves the host to all its IPv4 and IPv6 addresses with Config.Resolver,
+// and races connection attempts to them following the Happy Eyeballs
+// algorithm of RFC 8305: Addresses are tried in turn, alternating
+// between address families and starting with IPv6. A new attempt is started
+// each time the previous attempt fails or Config.ConnectionAttemptDelay elapses
+// without the attempt completing its handshake.
+// The first connection to complete its handshake is returned,
+// and all other attempts are abandoned.
+//
+// If the Listener is bound to an IPv4 address, only IPv4 addresses are tried.
+func (l *Listener) DialAddrs(ctx context.Context, network, address string) (*Conn, error) {
+	ips, port, err := l.resolve(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	var addrs []netip.AddrPort
+	for _, ip := range happyEyeballsOrder(ips) {
+		if l.LocalAddr().Addr().Is4() && !ip.Is4() {
+			continue
+		}
+		addrs = append(addrs, netip.AddrPortFrom(ip, port))
+	}
+	return l.dialAddrs(ctx, addrs)
+}
+
+// happyEyeballsOrder returns ips reordered to alternate between
+// IPv6 and IPv4 addresses, starting with IPv6.
+// The relative order of addresses of each family is preserved.
+// https://www.rfc-editor.org/rfc/rfc8305#section-4
+func happyEyeballsOrder(ips []netip.Addr) []netip.Addr {
+	var v4, v6 []netip.Addr
+	for _, ip := range ips {
+		ip = ip.Unmap()
+		if ip.Is4() {
+			v4 = append(v4, ip)
+		} else {
+			v6 = append(v6, ip)
+		}
+	}
+	ordered := make([]netip.Addr, 0, len(ips))
+	for len(v4) > 0 || len(v6) > 0 {
+		if len(v6) > 0 {
+			ordered = append(ordered, v6[0])
+			v6 = v6[1:]
+		}
+		if len(v4) > 0 {
+			ordered = append(ordered, v4[0])
+			v4 = v4[1:]
+		}
+	}
+	return ordered
+}
+
+// dialAddrs races connection attempts to addrs, in order.
+func (l *Listener) dialAddrs(ctx context.Context, addrs []netip.AddrPort) (*Conn, error) {
+	if len(addrs) == 0 {
+		return nil, errors.New("quic: no addresses to dial")
+	}
+	dial := func(ctx context.Context, i int) (*Conn, error) {
+		c, err := l.dialAddr(ctx, addrs[i])
+		if err != nil {
+			err = fmt.Errorf("dial %v: %w", addrs[i], err)
+		}
+		return c, err
+	}
+	abort := func(c *Conn) { c.Abort(nil) }
+	return raceDials(ctx, l.config.clock(), l.config.connectionAttemptDelay(), len(addrs), dial, abort)
+}
+
+type raceDialsResult[T any] struct {
+	c   T
+	err error
+}
+
+// raceDials races n connection attempts, made by calling dial
+// with each attempt's index in turn.
+// A new attempt is started each time the previous attempt fails
+// or delay elapses without the attempt completing.
+// If delay is negative, a new attempt is started only when
+// the previous attempt fails.
+//
+// The first successful connection is returned,
+// and the contexts of all other attempts are canceled.
+// abandon is called with connections made by attempts which lost the race.
+func raceDials[T any](ctx context.Context, clock Clock, delay time.Duration, n int, dial func(ctx context.Context, i int) (T, error), abandon func(T)) (T, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan raceDialsResult[T], n)
+	pending := 0
+	next := 0
+	dialNext := func() {
+		i := next
+		next++
+		pending++
+		go func() {
+			c, err := dial(ctx, i)
+			results <- raceDialsResult[T]{c, err}
+		}()
+	}
+
+	var timer ClockTimer
+	var timerc chan struct{}
+	startTimer := func() {
+		if timer != nil {
+			timer.Stop()
+		}
+		if next >= n || delay < 0 {
+			timerc = nil
+			return
+		}
+		c := make(chan struct{})
+		timer = clock.AfterFunc(delay, func() { close(c) })
+		timerc = c
+	}
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+
+	dialNext()
+	startTimer()
+	var errs []error
+	for pending > 0 {
+		select {
+		case <-timerc:
+			dialNext()
+			startTimer()
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				if pending > 0 {
+					cancel()
+					go abandonRaceDialsResults(results, pending, abandon)
+				}
+				return r.c, nil
+			}
+			errs = append(errs, r.err)
+			if next < n {
+				// Don't wait for the timer after a failed attempt.
+				dialNext()
+				startTimer()
+			}
+		}
+	}
+	var zero T
+	return zero, errors.Join(errs...)
+}
+
+// abandonRaceDialsResults calls abandon with connections established by
+// dial attempts which lost a race.
+func abandonRaceDialsResults[T any](results <-chan raceDialsResult[T], pending int, abandon func(T)) {
+	for ; pending > 0; pending-- {
+		if r := <-results; r.err == nil {
+			abandon(r.c)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestHappyEyeballsOrder(t *testing.T) {
+	ips := []netip.Addr{
+		netip.MustParseAddr("192.0.2.1"),
+		netip.MustParseAddr("192.0.2.2"),
+		netip.MustParseAddr("192.0.2.3"),
+		netip.MustParseAddr("2001:db8::1"),
+		netip.MustParseAddr("::ffff:192.0.2.4"),
+		netip.MustParseAddr("2001:db8::2"),
+	}
+	got := happyEyeballsOrder(ips)
+	want := []netip.Addr{
+		netip.MustParseAddr("2001:db8::1"),
+		netip.MustParseAddr("192.0.2.1"),
+		netip.MustParseAddr("2001:db8::2"),
+		netip.MustParseAddr("192.0.2.2"),
+		netip.MustParseAddr("192.0.2.3"),
+		netip.MustParseAddr("192.0.2.4"),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("happyEyeballsOrder(%v) =\n%v\nwant:\n%v", ips, got, want)
+	}
+}
+
+func TestDialAddrsUnresponsiveAddress(t *testing.T) {
+	// A UDP socket which never responds.
+	blackhole, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer blackhole.Close()
+	sl := newLocalListener(t, serverSide, &Config{})
+	cl := newLocalListener(t, clientSide, &Config{
+		ConnectionAttemptDelay: 10 * time.Millisecond,
+	})
+	// Don't wait for connections to finish draining.
+	defer sl.Close(canceledContext())
+	defer cl.Close(canceledContext())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	c, err := cl.dialAddrs(ctx, []netip.AddrPort{
+		blackhole.LocalAddr().(*net.UDPAddr).AddrPort(),
+		sl.LocalAddr(),
+	})
+	if err != nil {
+		t.Fatalf("dialAddrs: %v", err)
+	}
+	if got, want := c.peerAddr, sl.LocalAddr(); got != want {
+		t.Errorf("connected to %v, want %v", got, want)
+	}
+}
+
+func TestDialAddrsHostname(t *testing.T) {
+	sl := newLocalListener(t, serverSide, &Config{})
+	cl := newLocalListener(t, clientSide, &Config{})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	address := net.JoinHostPort("localhost", strconv.Itoa(int(sl.LocalAddr().Port())))
+	c, err := cl.DialAddrs(ctx, "udp", address)
+	if err != nil {
+		t.Fatalf("DialAddrs(%q): %v", address, err)
+	}
+	if got, want := c.peerAddr, sl.LocalAddr(); got != want {
+		t.Errorf("connected to %v, want %v", got, want)
+	}
+}
+
+func TestDialAddrsAllFail(t *testing.T) {
+	cl := newLocalListener(t, clientSide, &Config{
+		AllowedSources: []netip.Prefix{netip.MustParsePrefix("192.0.2.0/24")},
+	})
+	_, err := cl.dialAddrs(context.Background(), []netip.AddrPort{
+		netip.MustParseAddrPort("127.0.0.1:443"),
+		netip.MustParseAddrPort("127.0.0.2:443"),
+	})
+	if err == nil {
+		t.Fatalf("dialAddrs to denied addresses succeeded, want error")
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// An ErrorCodeSpace names the application protocol error codes
+// (RFC 9000, Section 20.2) used by an application protocol.
+//
+// When a connection has an ErrorCodeSpace, error codes sent or received
+// by the connection are rendered by name in error strings and traces:
+// for example, "H3_REQUEST_CANCELLED (0x10c)" rather than "268".
+//
+// An ErrorCodeSpace is safe for concurrent use.
+type ErrorCodeSpace struct {
+	mu     sync.Mutex
+	names  map[uint64]string
+	ranges []errorCodeRange
+}
+
+type errorCodeRange struct {
+	first, last uint64
+	name        string
+}
+
+// Add names the error code code.
+func (s *ErrorCodeSpace) Add(code uint64, name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.names == nil {
+		s.names = make(map[uint64]string)
+	}
+	s.names[code] = name
+}
+
+// AddRange names the error codes in the range [first, last].
+// A code in the range is rendered as the name followed by the code's offset
+// from the start of the range: for example, "WT_APPLICATION_ERROR+5".
+// Codes named by Add take precedence over ranges.
+func (s *ErrorCodeSpace) AddRange(first, last uint64, name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ranges = append(s.ranges, errorCodeRange{first, last, name})
+}
+
+// Name returns the name of an error code,
+// or the empty string if the code has no name.
+func (s *ErrorCodeSpace) Name(code uint64) string {
+	if s == nil {
+		return ""
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if name, ok := s.names[code]; ok {
+		return name
+	}
+	for _, r := range s.ranges {
+		if code >= r.first && code <= r.last {
+			return fmt.Sprintf("%v+%v", r.name, code-r.first)
+		}
+	}
+	return ""
+}
+
+// Format returns a description of an error code:
+// its name and value in hexadecimal if it has a name,
+// or its value in decimal if it does not.
+func (s *ErrorCodeSpace) Format(code uint64) string {
+	if name := s.Name(code); name != "" {
+		return fmt.Sprintf("%v (%#x)", name, code)
+	}
+	return fmt.Sprint(code)
+}
+
+var errorCodeSpaces sync.Map // ALPN protocol -> *ErrorCodeSpace
+
+// RegisterErrorCodeSpace registers the error codes used by the application
+// protocol with the ALPN identifier proto (for example, "h3").
+// Connections which negotiate proto use the space,
+// unless their Config sets ErrorCodes.
+func RegisterErrorCodeSpace(proto string, s *ErrorCodeSpace) {
+	errorCodeSpaces.Store(proto, s)
+}
+
+// LookupErrorCodeSpace returns the error codes registered
+// for the ALPN protocol proto, or nil if there are none.
+func LookupErrorCodeSpace(proto string) *ErrorCodeSpace {
+	s, _ := errorCodeSpaces.Load(proto)
+	space, _ := s.(*ErrorCodeSpace)
+	return space
+}
+
+// errorCodesState tracks the error code space used by a connection.
+type errorCodesState struct {
+	// space is set when the handshake completes,
+	// and may be read from any goroutine.
+	space atomic.Pointer[ErrorCodeSpace]
+}
+
+func (c *Conn) errorCodesInit() {
+	if c.config.ErrorCodes != nil {
+		c.errorCodes.space.Store(c.config.ErrorCodes)
+	}
+}
+
+// errorCodesHandshakeDone selects the error code space
+// for the negotiated application protocol.
+func (c *Conn) errorCodesHandshakeDone() {
+	if c.config.ErrorCodes != nil {
+		return
+	}
+	proto := c.tlsState.NegotiatedProtocol
+	if s := LookupErrorCodeSpace(proto); s != nil {
+		c.errorCodes.space.Store(s)
+	}
+}
+
+// errorCodeSpace returns the connection's error code space, or nil.
+func (c *Conn) errorCodeSpace() *ErrorCodeSpace {
+	return c.errorCodes.space.Load()
+}
@@ -0,0 +1,109 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"context"
+	"testing"
+)
+
+func TestErrorCodeSpaceName(t *testing.T) {
+	s := &ErrorCodeSpace{}
+	s.Add(0x10c, "H3_REQUEST_CANCELLED")
+	s.AddRange(0x52e4a40fa8db, 0x52e5ac983162, "WT_APPLICATION_ERROR")
+	s.Add(0x52e4a40fa8db+1, "OVERRIDE")
+	for _, test := range []struct {
+		code       uint64
+		wantName   string
+		wantFormat string
+	}{{
+		code:       0x10c,
+		wantName:   "H3_REQUEST_CANCELLED",
+		wantFormat: "H3_REQUEST_CANCELLED (0x10c)",
+	}, {
+		code:       0x10d,
+		wantName:   "",
+		wantFormat: "269",
+	}, {
+		code:       0x52e4a40fa8db,
+		wantName:   "WT_APPLICATION_ERROR+0",
+		wantFormat: "WT_APPLICATION_ERROR+0 (0x52e4a40fa8db)",
+	}, {
+		code:       0x52e4a40fa8db + 1,
+		wantName:   "OVERRIDE",
+		wantFormat: "OVERRIDE (0x52e4a40fa8dc)",
+	}, {
+		code:       0x52e4a40fa8db + 5,
+		wantName:   "WT_APPLICATION_ERROR+5",
+		wantFormat: "WT_APPLICATION_ERROR+5 (0x52e4a40fa8e0)",
+	}} {
+		if got := s.Name(test.code); got != test.wantName {
+			t.Errorf("Name(%#x) = %q, want %q", test.code, got, test.wantName)
+		}
+		if got := s.Format(test.code); got != test.wantFormat {
+			t.Errorf("Format(%#x) = %q, want %q", test.code, got, test.wantFormat)
+		}
+	}
+
+	var nilSpace *ErrorCodeSpace
+	if got, want := nilSpace.Format(0x10c), "268"; got != want {
+		t.Errorf("nil space: Format(0x10c) = %q, want %q", got, want)
+	}
+}
+
+func TestErrorCodeSpaceRegister(t *testing.T) {
+	s := &ErrorCodeSpace{}
+	RegisterErrorCodeSpace("test-error-codes", s)
+	if got := LookupErrorCodeSpace("test-error-codes"); got != s {
+		t.Errorf("LookupErrorCodeSpace(registered) = %p, want %p", got, s)
+	}
+	if got := LookupErrorCodeSpace("test-unregistered"); got != nil {
+		t.Errorf("LookupErrorCodeSpace(unregistered) = %p, want nil", got)
+	}
+}
+
+func testErrorCodes() *ErrorCodeSpace {
+	s := &ErrorCodeSpace{}
+	s.Add(42, "TEST_ERROR")
+	return s
+}
+
+func TestErrorCodeSpacePeerClose(t *testing.T) {
+	tc := newTestConn(t, clientSide, func(c *Config) {
+		c.ErrorCodes = testErrorCodes()
+	})
+	tc.handshake()
+	tc.writeFrames(packetType1RTT, debugFrameConnectionCloseApplication{
+		code:   42,
+		reason: "why?",
+	})
+	err := tc.conn.Wait(canceledContext())
+	if err == nil {
+		t.Fatalf("conn.Wait() = nil, want error")
+	}
+	if got, want := err.Error(), `AppError TEST_ERROR (0x2a): "why?"`; got != want {
+		t.Errorf("conn.Wait() = %q, want %q", got, want)
+	}
+}
+
+func TestErrorCodeSpaceStreamReset(t *testing.T) {
+	tc, s := newTestConnAndRemoteStream(t, serverSide, bidiStream, func(c *Config) {
+		c.ErrorCodes = testErrorCodes()
+	})
+	tc.writeFrames(packetType1RTT, debugFrameResetStream{
+		id:        s.id,
+		finalSize: 0,
+		code:      42,
+	})
+	_, err := s.ReadContext(context.Background(), make([]byte, 4))
+	if err == nil {
+		t.Fatalf("Read reset stream: got nil error, want error")
+	}
+	if got, want := err.Error(), "stream reset by peer: TEST_ERROR (0x2a)"; got != want {
+		t.Errorf("Read reset stream: got %q, want %q", got, want)
+	}
+}
@@ -36,6 +36,7 @@ const (
 	errKeyUpdateError       = transportError(0x0e)
 	errAEADLimitReached     = transportError(0x0f)
 	errNoViablePath         = transportError(0x10)
+	errVersionNegotiation   = transportError(0x11)   // RFC 9368
 	errTLSBase              = transportError(0x0100) // 0x0100-0x01ff; base + TLS code
 )
 
@@ -75,6 +76,8 @@ func (e transportError) String() string {
 		return "AEAD_LIMIT_REACHED"
 	case errNoViablePath:
 		return "NO_VIABLE_PATH"
+	case errVersionNegotiation:
+		return "VERSION_NEGOTIATION_ERROR"
 	}
 	if e >= 0x0100 && e <= 0x01ff {
 		return fmt.Sprintf("CRYPTO_ERROR(%v)", uint64(e)&0xff)
@@ -91,14 +94,63 @@ func (e localTransportError) Error() string {
 
 // A peerTransportError is an error received from the peer.
 type peerTransportError struct {
-	code   transportError
-	reason string
+	code      transportError
+	frameType uint64
+	reason    string
 }
 
 func (e peerTransportError) Error() string {
 	return fmt.Sprintf("peer closed connection: %v: %q", e.code, e.reason)
 }
 
+// A ConnectionCloseError describes the CONNECTION_CLOSE frame
+// which terminated a connection (RFC 9000, Section 19.19).
+// See Conn.Err.
+type ConnectionCloseError struct {
+	// Remote is true if the peer closed the connection,
+	// and false if the connection was closed locally.
+	Remote bool
+
+	// Application is true if Code is an application protocol error code,
+	// and false if it is a transport error code (RFC 9000, Section 20.1).
+	Application bool
+
+	Code uint64
+
+	// FrameType is the type of frame which triggered a transport error,
+	// or 0 if unknown. It is always 0 for application errors.
+	FrameType uint64
+
+	Reason string
+
+	codes *ErrorCodeSpace // names application error codes; may be nil
+}
+
+func (e *ConnectionCloseError) Error() string {
+	var code string
+	if e.Application {
+		code = "AppError " + e.codes.Format(e.Code)
+	} else {
+		code = transportError(e.Code).String()
+	}
+	prefix := "closed connection: "
+	if e.Remote {
+		prefix = "peer closed connection: "
+	}
+	if e.Reason == "" {
+		return prefix + code
+	}
+	return fmt.Sprintf("%v%v: %q", prefix, code, e.Reason)
+}
+
+// Unwrap returns an *ApplicationError for application errors, and nil otherwise.
+func (e *ConnectionCloseError) Unwrap() error {
+	if !e.Application {
+		return nil
+	}
+	return &ApplicationError{Code: e.Code, Reason: e.Reason, codes: e.codes}
+}
+
 // A StreamErrorCode is an application protocol error code (RFC 9000, Section 20.2)
 // indicating whay a stream is being closed.
 type StreamErrorCode uint64
@@ -107,16 +159,61 @@ func (e StreamErrorCode) Error() string {
 	return fmt.Sprintf("stream error code %v", uint64(e))
 }
 
+// A StreamResetError is returned by reads from a stream
+// which the peer terminated with a RESET_STREAM frame.
+// It wraps the StreamErrorCode sent by the peer.
+type StreamResetError struct {
+	Code uint64
+
+	codes *ErrorCodeSpace // names error codes; may be nil
+}
+
+func (e *StreamResetError) Error() string {
+	if name := e.codes.Name(e.Code); name != "" {
+		return fmt.Sprintf("stream reset by peer: %v (%#x)", name, e.Code)
+	}
+	return fmt.Sprintf("stream reset by peer: %v", StreamErrorCode(e.Code))
+}
+
+func (e *StreamResetError) Unwrap() error {
+	return StreamErrorCode(e.Code)
+}
+
+// A StreamStoppedError is returned by writes to a stream
+// after the peer has asked us to stop sending with a STOP_SENDING frame.
+// It wraps the StreamErrorCode sent by the peer.
+type StreamStoppedError struct {
+	Code uint64
+
+	codes *ErrorCodeSpace // names error codes; may be nil
+}
+
+func (e *StreamStoppedError) Error() string {
+	if name := e.codes.Name(e.Code); name != "" {
+		return fmt.Sprintf("stream stopped by peer: %v (%#x)", name, e.Code)
+	}
+	return fmt.Sprintf("stream stopped by peer: %v", StreamErrorCode(e.Code))
+}
+
+func (e *StreamStoppedError) Unwrap() error {
+	return StreamErrorCode(e.Code)
+}
+
 // An ApplicationError is an application protocol error code (RFC 9000, Section 20.2).
 // Application protocol errors may be sent when terminating a stream or connection.
 type ApplicationError struct {
 	Code   uint64
 	Reason string
+
+	codes *ErrorCodeSpace // names error codes; may be nil
 }
 
 func (e *ApplicationError) Error() string {
-	// TODO: Include the Reason string here, but sanitize it first.
-	return fmt.Sprintf("AppError %v", e.Code)
+	if e.Reason == "" {
+		return "AppError " + e.codes.Format(e.Code)
+	}
+	// The reason is provided by the peer, so quote it.
+	return fmt.Sprintf("AppError %v: %q", e.codes.Format(e.Code), e.Reason)
 }
 
 // Is reports a match if err is an *ApplicationError with a matching Code.
@@ -124,3 +221,15 @@ func (e *ApplicationError) Is(err error) bool {
 	e2, ok := err.(*ApplicationError)
 	return ok && e2.Code == e.Code
 }
+
+// A VersionNegotiationError is returned when a client connection attempt fails
+// because the server does not support any QUIC version enabled in Config.Versions.
+type VersionNegotiationError struct {
+	// Versions are the versions the server listed as supported
+	// in its Version Negotiation packet.
+	Versions []Version
+}
+
+func (e *VersionNegotiationError) Error() string {
+	return fmt.Sprintf("server does not support the requested QUIC version (server supports %v)", e.Versions)
+}
@@ -0,0 +1,260 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// A FallbackPolicy determines when a FallbackDialer connects with TCP.
+type FallbackPolicy int
+
+const (
+	// FallbackRace starts a TCP connection when the QUIC handshake fails
+	// or has not completed after the fallback delay,
+	// and uses whichever connection is established first.
+	FallbackRace = FallbackPolicy(iota)
+
+	// FallbackSequential stops waiting for the QUIC handshake when it fails
+	// or has not completed after the fallback delay,
+	// and then connects with TCP.
+	FallbackSequential
+)
+
+const (
+	defaultFallbackDelay  = 300 * time.Millisecond
+	defaultBrokenDuration = 5 * time.Minute
+)
+
+// A FallbackDialer connects to a host using QUIC,
+// falling back to TLS over TCP on networks where QUIC is unavailable,
+// such as networks which block UDP.
+//
+// Connections are returned as net.Conns.
+// A QUIC connection is represented by a bidirectional stream,
+// as in Listener.NetListener, and closing the net.Conn closes the connection.
+// A TCP connection is a *tls.Conn.
+//
+// When a TCP connection is used in place of a QUIC connection attempt
+// which has not completed, the attempt continues in the background
+// until its handshake completes or times out (see Config.DialHandshakeTimeout),
+// and the resulting connection is closed.
+// When a QUIC connection attempt fails, the dialer records QUIC
+// as broken on the current network, and uses only TCP on that network
+// until the BrokenDuration expires. A QUIC connection which is merely
+// slower than TCP does not mark QUIC as broken.
+//
+// A FallbackDialer must not be copied after first use.
+type FallbackDialer struct {
+	// Listener creates QUIC connections. It must be non-nil.
+	// QUIC connections use the Listener's Config.TLSConfig.
+	// Its Config.Clock is also used by the dialer.
+	Listener *Listener
+
+	// TLSConfig is the TLS configuration for TCP connections.
+	// It must be non-nil.
+	// Application protocols generally use different ALPN identifiers
+	// over QUIC and TCP (for example, "h3" and "h2"),
+	// so TLSConfig.NextProtos usually differs from that of
+	// the Listener's Config.TLSConfig.
+	TLSConfig *tls.Config
+
+	// NetDialer creates TCP connections.
+	// If nil, a zero net.Dialer is used.
+	NetDialer *net.Dialer
+
+	// Policy determines when to connect with TCP.
+	Policy FallbackPolicy
+
+	// FallbackDelay is the time to wait for the QUIC handshake
+	// before connecting with TCP.
+	// If zero or negative, a default of 300ms is used.
+	FallbackDelay time.Duration
+
+	// BrokenDuration is how long QUIC is considered broken on a network
+	// after a TCP connection is used in its place.
+	// If zero or negative, a default of 5 minutes is used.
+	BrokenDuration time.Duration
+
+	// Network, if non-nil, returns an identifier for the network
+	// the host is currently attached to, such as a Wi-Fi network name.
+	// QUIC brokenness is recorded separately for each network.
+	// If nil, all connections are considered to use the same network.
+	Network func() string
+
+	attempts sync.WaitGroup // QUIC connection attempts in progress
+
+	mu     sync.Mutex
+	broken map[string]time.Time // network -> time QUIC is considered working again
+}
+
+// Dial connects to the address on the named host.
+// See net.Dial for a description of the address format.
+func (d *FallbackDialer) Dial(ctx context.Context, address string) (net.Conn, error) {
+	if d.TLSConfig == nil {
+		return nil, errors.New("quic: FallbackDialer.TLSConfig is nil")
+	}
+	network := d.network()
+	if d.quicBroken(network) {
+		return d.dialTCP(ctx, address)
+	}
+	delay := d.fallbackDelay()
+	if d.Policy == FallbackSequential {
+		// The QUIC attempt gives up after the delay,
+		// and TCP starts only once it has.
+		delay = -1
+	}
+	dial := func(raceCtx context.Context, i int) (net.Conn, error) {
+		if i == 0 {
+			return d.dialQUIC(ctx, raceCtx, network, address)
+		}
+		return d.dialTCP(raceCtx, address)
+	}
+	return raceDials(ctx, d.Listener.config.clock(), delay, 2, dial, closeFallbackConn)
+}
+
+// errFallbackDelay is returned by a QUIC connection attempt which
+// has not completed after the fallback delay, with FallbackSequential.
+var errFallbackDelay = errors.New("quic: handshake did not complete within fallback delay")
+
+// dialQUIC makes a QUIC connection attempt.
+//
+// ctx is the Dial context, and raceCtx is canceled when the Dial context is done
+// or a TCP connection wins the race. When the Dial context is done,
+// the attempt is canceled. Otherwise, when dialQUIC returns before the
+// attempt completes, the attempt continues in the background to learn whether
+// QUIC is broken on the network, and any connection it makes is closed.
+func (d *FallbackDialer) dialQUIC(ctx, raceCtx context.Context, network, address string) (net.Conn, error) {
+	// The attempt does not inherit ctx's deadline; it is bounded
+	// by the Listener's handshake timeout instead.
+	attemptCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(ctx, cancel)
+	type result struct {
+		c   net.Conn
+		err error
+	}
+	donec := make(chan result, 1)
+	d.attempts.Add(1)
+	go func() {
+		defer d.attempts.Done()
+		defer stop()
+		defer cancel()
+		c, err := d.Listener.Dial(attemptCtx, "udp", address)
+		if err != nil {
+			if attemptCtx.Err() == nil {
+				// The handshake failed, rather than being canceled.
+				d.markBroken(network)
+			}
+			donec <- result{nil, err}
+			return
+		}
+		s, err := c.NewStream(attemptCtx)
+		if err != nil {
+			c.Abort(nil)
+			donec <- result{nil, err}
+			return
+		}
+		donec <- result{newStreamConn(c, s), nil}
+	}()
+
+	var timerc chan struct{}
+	if d.Policy == FallbackSequential {
+		timerc = make(chan struct{})
+		timer := d.Listener.config.clock().AfterFunc(d.fallbackDelay(), func() {
+			close(timerc)
+		})
+		defer timer.Stop()
+	}
+	var err error
+	select {
+	case r := <-donec:
+		return r.c, r.err
+	case <-raceCtx.Done():
+		err = raceCtx.Err()
+	case <-timerc:
+		err = errFallbackDelay
+	}
+	if ctx.Err() == nil {
+		// Continue the attempt after Dial returns.
+		stop()
+	}
+	go func() {
+		if r := <-donec; r.c != nil {
+			closeFallbackConn(r.c)
+		}
+	}()
+	return nil, err
+}
+
+// closeFallbackConn closes a connection which lost a dial race.
+func closeFallbackConn(c net.Conn) {
+	if sc, ok := c.(*streamConn); ok {
+		// Don't wait for the peer to acknowledge the stream's closure.
+		sc.c.Abort(nil)
+	} else {
+		c.Close()
+	}
+}
+
+func (d *FallbackDialer) dialTCP(ctx context.Context, address string) (net.Conn, error) {
+	td := &tls.Dialer{
+		NetDialer: d.NetDialer,
+		Config:    d.TLSConfig,
+	}
+	return td.DialContext(ctx, "tcp", address)
+}
+
+func (d *FallbackDialer) network() string {
+	if d.Network == nil {
+		return ""
+	}
+	return d.Network()
+}
+
+func (d *FallbackDialer) fallbackDelay() time.Duration {
+	if d.FallbackDelay <= 0 {
+		return defaultFallbackDelay
+	}
+	return d.FallbackDelay
+}
+
+func (d *FallbackDialer) brokenDuration() time.Duration {
+	if d.BrokenDuration <= 0 {
+		return defaultBrokenDuration
+	}
+	return d.BrokenDuration
+}
+
+// quicBroken reports whether QUIC is considered broken on a network.
+func (d *FallbackDialer) quicBroken(network string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	until, ok := d.broken[network]
+	if !ok {
+		return false
+	}
+	if !d.Listener.config.clock().Now().Before(until) {
+		delete(d.broken, network)
+		return false
+	}
+	return true
+}
+
+// markBroken records QUIC as broken on a network.
+func (d *FallbackDialer) markBroken(network string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.broken == nil {
+		d.broken = make(map[string]time.Time)
+	}
+	d.broken[network] = d.Listener.config.clock().Now().Add(d.brokenDuration())
+}
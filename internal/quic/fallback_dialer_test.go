@@ -0,0 +1,197 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// ALPN protocols used by fallback tests.
+const (
+	fallbackTestQUICProto = "echo-quic"
+	fallbackTestTCPProto  = "echo-tcp"
+)
+
+// newFallbackTestTLSConfig returns a TLS config offering the ALPN protocol proto.
+func newFallbackTestTLSConfig(side connSide, proto string) *tls.Config {
+	config := newTestTLSConfig(side)
+	config.NextProtos = []string{proto}
+	return config
+}
+
+// newFallbackTestServer starts a TLS over TCP server which echoes the data it reads.
+// If withQUIC is true, it also starts a QUIC server on the same port number,
+// which echoes data read from the first stream of each connection.
+func newFallbackTestServer(t *testing.T, withQUIC bool) (address string) {
+	t.Helper()
+	var tl net.Listener
+	for i := 0; ; i++ {
+		var port int
+		if withQUIC {
+			l := newLocalListener(t, serverSide, &Config{
+				TLSConfig: newFallbackTestTLSConfig(serverSide, fallbackTestQUICProto),
+			})
+			port = int(l.LocalAddr().Port())
+			go serveFallbackTestConns(l.NetListener())
+		}
+		var err error
+		tl, err = tls.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)), newFallbackTestTLSConfig(serverSide, fallbackTestTCPProto))
+		if err == nil {
+			break
+		}
+		if i == 10 {
+			t.Fatalf("tls.Listen: %v", err)
+		}
+	}
+	t.Cleanup(func() { tl.Close() })
+	go serveFallbackTestConns(tl)
+	return tl.Addr().String()
+}
+
+func serveFallbackTestConns(l net.Listener) {
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer c.Close()
+			io.Copy(c, c)
+		}()
+	}
+}
+
+func newFallbackTestDialer(t *testing.T) *FallbackDialer {
+	l := newLocalListener(t, clientSide, &Config{
+		TLSConfig:            newFallbackTestTLSConfig(clientSide, fallbackTestQUICProto),
+		DialHandshakeTimeout: 100 * time.Millisecond,
+	})
+	// Don't wait for abandoned QUIC connections to finish draining.
+	t.Cleanup(func() { l.Close(canceledContext()) })
+	return &FallbackDialer{
+		Listener:      l,
+		TLSConfig:     newFallbackTestTLSConfig(clientSide, fallbackTestTCPProto),
+		FallbackDelay: 10 * time.Millisecond,
+	}
+}
+
+// fallbackDial dials address and checks that the connection works.
+// It reports whether the connection uses QUIC.
+func fallbackDial(t *testing.T, d *FallbackDialer, address string) (isQUIC bool) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	c, err := d.Dial(ctx, address)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer c.Close()
+	c.SetDeadline(time.Now().Add(10 * time.Second))
+	if _, err := c.Write([]byte("hello")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	b := make([]byte, 5)
+	if _, err := io.ReadFull(c, b); err != nil || string(b) != "hello" {
+		t.Fatalf("Read = %q, %v; want %q, nil", b, err, "hello")
+	}
+	var proto, wantProto string
+	switch c := c.(type) {
+	case *tls.Conn:
+		proto, wantProto = c.ConnectionState().NegotiatedProtocol, fallbackTestTCPProto
+	case *streamConn:
+		proto, wantProto = c.c.ConnectionState().NegotiatedProtocol, fallbackTestQUICProto
+		isQUIC = true
+	default:
+		t.Fatalf("Dial returned unexpected %T", c)
+	}
+	if proto != wantProto {
+		t.Errorf("negotiated protocol %q, want %q", proto, wantProto)
+	}
+	return isQUIC
+}
+
+func TestFallbackDialerQUIC(t *testing.T) {
+	address := newFallbackTestServer(t, true)
+	d := newFallbackTestDialer(t)
+	d.FallbackDelay = 10 * time.Second
+	if !fallbackDial(t, d, address) {
+		t.Errorf("connected with TCP, want QUIC")
+	}
+	if d.quicBroken("") {
+		t.Errorf("QUIC marked as broken after successful QUIC connection")
+	}
+}
+
+func TestFallbackDialerUDPBlocked(t *testing.T) {
+	for _, policy := range []FallbackPolicy{FallbackRace, FallbackSequential} {
+		address := newFallbackTestServer(t, false)
+		d := newFallbackTestDialer(t)
+		d.Policy = policy
+		if fallbackDial(t, d, address) {
+			t.Fatalf("policy %v: connected with QUIC to TCP-only server", policy)
+		}
+		// QUIC is marked as broken when the abandoned attempt times out.
+		d.attempts.Wait()
+		if !d.quicBroken("") {
+			t.Errorf("policy %v: QUIC not marked as broken after falling back to TCP", policy)
+		}
+		// With QUIC marked as broken, the dialer connects with TCP immediately.
+		d.FallbackDelay = time.Hour
+		if fallbackDial(t, d, address) {
+			t.Fatalf("policy %v: connected with QUIC while marked as broken", policy)
+		}
+	}
+}
+
+func TestFallbackDialerSlowQUIC(t *testing.T) {
+	for _, policy := range []FallbackPolicy{FallbackRace, FallbackSequential} {
+		address := newFallbackTestServer(t, true)
+		d := newFallbackTestDialer(t)
+		d.Policy = policy
+		// Fall back to TCP before the QUIC handshake can complete.
+		d.FallbackDelay = time.Nanosecond
+		d.Listener.config.DialHandshakeTimeout = 10 * time.Second
+		fallbackDial(t, d, address)
+		d.attempts.Wait()
+		if d.quicBroken("") {
+			t.Errorf("policy %v: QUIC marked as broken after a QUIC handshake which was slower than TCP", policy)
+		}
+	}
+}
+
+func TestFallbackDialerNoTLSConfig(t *testing.T) {
+	d := newFallbackTestDialer(t)
+	d.TLSConfig = nil
+	if _, err := d.Dial(context.Background(), "127.0.0.1:1"); err == nil {
+		t.Errorf("Dial with nil TLSConfig succeeded, want error")
+	}
+}
+
+func TestFallbackDialerBrokenPerNetwork(t *testing.T) {
+	clock := &offsetClock{}
+	d := &FallbackDialer{
+		Listener:       newLocalListener(t, clientSide, &Config{Clock: clock}),
+		BrokenDuration: time.Minute,
+	}
+	d.markBroken("wifi")
+	if !d.quicBroken("wifi") {
+		t.Errorf("QUIC not broken on network where it was marked broken")
+	}
+	if d.quicBroken("cellular") {
+		t.Errorf("QUIC broken on a different network")
+	}
+	clock.offset = time.Minute
+	if d.quicBroken("wifi") {
+		t.Errorf("QUIC still broken after BrokenDuration")
+	}
+}
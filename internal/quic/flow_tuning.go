@@ -0,0 +1,72 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import "time"
+
+// A windowTuner sizes a receive flow control window.
+//
+// The window grows when the peer appears to be limited by it,
+// similar to TCP receive window auto-tuning:
+// If the application consumes a window's worth of data in less than
+// two round trips, the window is smaller than the connection's
+// bandwidth-delay product, and we double it (up to maxSize).
+//
+// Measurement proceeds in epochs, each starting when the window is updated.
+type windowTuner struct {
+	size       int64 // current window size
+	maxSize    int64 // window never grows beyond this size
+	epochStart time.Time
+	epochRead  int64 // bytes consumed at epochStart
+}
+
+func (t *windowTuner) init(size, maxSize int64) {
+	t.size = size
+	t.maxSize = maxSize
+}
+
+// update is called when sending a window update, with the total
+// number of bytes consumed by the application.
+// It returns the amount by which the window has grown.
+func (t *windowTuner) update(now time.Time, read int64, rtt time.Duration) (grow int64) {
+	if t.size >= t.maxSize {
+		return 0
+	}
+	if t.epochStart.IsZero() {
+		t.epochStart = now
+		t.epochRead = read
+		return 0
+	}
+	consumed := read - t.epochRead
+	if consumed <= t.size/2 {
+		// Too little data consumed to measure the rate.
+		return 0
+	}
+	elapsed := now.Sub(t.epochStart)
+	rtt = max(rtt, timerGranularity)
+	// Grow the window if the time to consume a full window
+	// (elapsed * size / consumed) is less than two round trips.
+	if float64(elapsed)*float64(t.size) < 2*float64(rtt)*float64(consumed) {
+		grow = min(2*t.size, t.maxSize) - t.size
+		t.size += grow
+	}
+	t.epochStart = now
+	t.epochRead = read
+	return grow
+}
+
+// growTo increases the window size to at least size, up to maxSize.
+// It returns the amount by which the window has grown.
+func (t *windowTuner) growTo(size int64) (grow int64) {
+	size = min(size, t.maxSize)
+	if size <= t.size {
+		return 0
+	}
+	grow = size - t.size
+	t.size = size
+	return grow
+}
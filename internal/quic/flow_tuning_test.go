@@ -0,0 +1,121 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWindowTuner(t *testing.T) {
+	const rtt = 10 * time.Millisecond
+	now := time.Now()
+	var tuner windowTuner
+	tuner.init(100, 400)
+
+	if got := tuner.update(now, 0, rtt); got != 0 {
+		t.Fatalf("first update: grew window by %v, want 0", got)
+	}
+
+	// Consuming a window of data in one RTT doubles the window.
+	now = now.Add(rtt)
+	if got := tuner.update(now, 100, rtt); got != 100 {
+		t.Fatalf("window consumed in one RTT: grew window by %v, want 100", got)
+	}
+
+	// Consuming too little data to measure does not start a new epoch.
+	now = now.Add(rtt)
+	if got := tuner.update(now, 150, rtt); got != 0 {
+		t.Fatalf("half window consumed: grew window by %v, want 0", got)
+	}
+
+	// Consuming a window of data over many RTTs does not grow the window.
+	now = now.Add(10 * rtt)
+	if got := tuner.update(now, 300, rtt); got != 0 {
+		t.Fatalf("window consumed in 11 RTTs: grew window by %v, want 0", got)
+	}
+
+	// The window does not grow past the maximum.
+	now = now.Add(rtt)
+	if got := tuner.update(now, 500, rtt); got != 200 {
+		t.Fatalf("window consumed in one RTT: grew window by %v, want 200", got)
+	}
+	now = now.Add(rtt)
+	if got := tuner.update(now, 900, rtt); got != 0 {
+		t.Fatalf("window at maximum: grew window by %v, want 0", got)
+	}
+	if got, want := tuner.size, int64(400); got != want {
+		t.Fatalf("window size = %v, want %v", got, want)
+	}
+}
+
+func TestWindowTunerGrowTo(t *testing.T) {
+	var tuner windowTuner
+	tuner.init(100, 400)
+	if got := tuner.growTo(50); got != 0 {
+		t.Errorf("growTo(50) = %v, want 0", got)
+	}
+	if got := tuner.growTo(150); got != 50 {
+		t.Errorf("growTo(150) = %v, want 50", got)
+	}
+	if got := tuner.growTo(1000); got != 250 {
+		t.Errorf("growTo(1000) = %v, want 250", got)
+	}
+}
+
+func TestStreamReceiveWindowAutoTuning(t *testing.T) {
+	const (
+		initialWindowSize = 64
+		maxWindowSize     = 1024
+	)
+	ctx := canceledContext()
+	tc, s := newTestConnAndRemoteStream(t, serverSide, uniStream, func(c *Config) {
+		c.AutoTuneReceiveWindows = true
+		c.InitialStreamReadWindowUni = initialWindowSize
+		c.MaxStreamReadBufferSize = maxWindowSize
+	})
+	tc.ignoreFrame(frameTypeMaxData)
+	buf := make([]byte, maxWindowSize)
+	off := int64(0)
+	receive := func(n int64) {
+		t.Helper()
+		tc.writeFrames(packetType1RTT, debugFrameStream{
+			id:   s.id,
+			off:  off,
+			data: make([]byte, n),
+		})
+		off += n
+		if _, err := s.ReadContext(ctx, buf); err != nil {
+			t.Fatalf("s.ReadContext() = %v", err)
+		}
+	}
+
+	receive(initialWindowSize)
+	tc.wantFrame("first window update starts measurement, window does not grow",
+		packetType1RTT, debugFrameMaxStreamData{
+			id:  s.id,
+			max: off + initialWindowSize,
+		})
+
+	// The peer sends a full window of data immediately.
+	receive(initialWindowSize)
+	tc.wantFrame("window consumed quickly, window grows",
+		packetType1RTT, debugFrameMaxStreamData{
+			id:  s.id,
+			max: off + 2*initialWindowSize,
+		})
+
+	// The peer sends a full window of data after many round trips.
+	tc.writeAckForAll()
+	tc.advance(100 * max(tc.conn.loss.rtt.smoothedRTT, timerGranularity))
+	receive(2 * initialWindowSize)
+	tc.wantFrame("window consumed slowly, window does not grow",
+		packetType1RTT, debugFrameMaxStreamData{
+			id:  s.id,
+			max: off + 2*initialWindowSize,
+		})
+}
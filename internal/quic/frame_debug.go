@@ -8,6 +8,7 @@ package quic
 
 import (
 	"fmt"
+	"time"
 )
 
 // A debugFrame is a representation of the contents of a QUIC frame,
@@ -64,7 +65,15 @@ func parseDebugFrame(b []byte) (f debugFrame, n int) {
 		f, n = parseDebugFrameConnectionCloseApplication(b)
 	case frameTypeHandshakeDone:
 		f, n = parseDebugFrameHandshakeDone(b)
+	case frameTypeImmediateAck:
+		f, n = parseDebugFrameImmediateAck(b)
+	case frameTypeDatagram, frameTypeDatagramWithLength:
+		f, n = parseDebugFrameDatagram(b)
 	default:
+		if ftype, _ := consumeVarint(b); ftype == frameTypeAckFrequency {
+			f, n = parseDebugFrameAckFrequency(b)
+			break
+		}
 		return nil, -1
 	}
 	return f, n
@@ -502,3 +511,57 @@ func (f debugFrameHandshakeDone) String() string {
 func (f debugFrameHandshakeDone) write(w *packetWriter) bool {
 	return w.appendHandshakeDoneFrame()
 }
+
+// debugFrameDatagram is a DATAGRAM frame.
+type debugFrameDatagram struct {
+	data []byte
+}
+
+func parseDebugFrameDatagram(b []byte) (f debugFrameDatagram, n int) {
+	f.data, n = consumeDatagramFrame(b)
+	return f, n
+}
+
+func (f debugFrameDatagram) String() string {
+	return fmt.Sprintf("DATAGRAM Data=%x", f.data)
+}
+
+func (f debugFrameDatagram) write(w *packetWriter) bool {
+	return w.appendDatagramFrame(f.data)
+}
+
+// debugFrameAckFrequency is an ACK_FREQUENCY frame.
+type debugFrameAckFrequency struct {
+	seq                   int64
+	ackElicitingThreshold int64
+	maxAckDelay           time.Duration
+	reorderingThreshold   int64
+}
+
+func parseDebugFrameAckFrequency(b []byte) (f debugFrameAckFrequency, n int) {
+	f.seq, f.ackElicitingThreshold, f.maxAckDelay, f.reorderingThreshold, n = consumeAckFrequencyFrame(b)
+	return f, n
+}
+
+func (f debugFrameAckFrequency) String() string {
+	return fmt.Sprintf("ACK_FREQUENCY Seq=%v Threshold=%v MaxAckDelay=%v Reordering=%v", f.seq, f.ackElicitingThreshold, f.maxAckDelay, f.reorderingThreshold)
+}
+
+func (f debugFrameAckFrequency) write(w *packetWriter) bool {
+	return w.appendAckFrequencyFrame(f.seq, f.ackElicitingThreshold, f.maxAckDelay, f.reorderingThreshold)
+}
+
+// debugFrameImmediateAck is an IMMEDIATE_ACK frame.
+type debugFrameImmediateAck struct{}
+
+func parseDebugFrameImmediateAck(b []byte) (f debugFrameImmediateAck, n int) {
+	return f, 1
+}
+
+func (f debugFrameImmediateAck) String() string {
+	return "IMMEDIATE_ACK"
+}
+
+func (f debugFrameImmediateAck) write(w *packetWriter) bool {
+	return w.appendImmediateAckFrame()
+}
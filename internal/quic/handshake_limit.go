@@ -0,0 +1,112 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"math"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+// maxHandshakeBuckets is the maximum number of clients a handshakeLimiter tracks.
+const maxHandshakeBuckets = 1 << 16
+
+// A handshakeLimiter limits the rate at which each client may start
+// new connections. See Config.HandshakeRateLimit.
+//
+// It is a token bucket per client, where a client is an IPv4 address
+// or an IPv6 /64 prefix.
+// Since clients choose their own addresses, the number of buckets is capped
+// at maxHandshakeBuckets. When the limiter is full, clients without a bucket
+// are rate limited until existing buckets refill and are swept.
+// It is shared by the Listener's unknownDestPool workers.
+type handshakeLimiter struct {
+	mu        sync.Mutex
+	rate      float64 // tokens per second
+	burst     float64 // maximum tokens in a bucket
+	buckets   map[netip.Prefix]handshakeBucket
+	lastSweep time.Time
+}
+
+type handshakeBucket struct {
+	tokens float64
+	last   time.Time // time tokens was last updated
+}
+
+func newHandshakeLimiter(rate float64, burst int) *handshakeLimiter {
+	if burst <= 0 {
+		burst = int(math.Ceil(rate))
+	}
+	return &handshakeLimiter{
+		rate:    rate,
+		burst:   float64(max(burst, 1)),
+		buckets: make(map[netip.Prefix]handshakeBucket),
+	}
+}
+
+// clientPrefix returns the prefix identifying the client at addr.
+func clientPrefix(addr netip.Addr) netip.Prefix {
+	addr = addr.Unmap()
+	bits := 32
+	if addr.Is6() {
+		// A single host is commonly assigned an entire /64.
+		bits = 64
+	}
+	p, _ := addr.Prefix(bits)
+	return p
+}
+
+// allow reports whether the client at addr may start a new handshake,
+// and consumes a token from its bucket if so.
+func (h *handshakeLimiter) allow(now time.Time, addr netip.Addr) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.sweep(now)
+	key := clientPrefix(addr)
+	b, ok := h.buckets[key]
+	if !ok {
+		if len(h.buckets) >= maxHandshakeBuckets {
+			return false
+		}
+		b = handshakeBucket{tokens: h.burst}
+	} else {
+		b.tokens = h.refill(b, now)
+	}
+	b.last = now
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	h.buckets[key] = b
+	return allowed
+}
+
+// refill returns the number of tokens in b at time now.
+func (h *handshakeLimiter) refill(b handshakeBucket, now time.Time) float64 {
+	elapsed := now.Sub(b.last).Seconds()
+	if elapsed <= 0 {
+		return b.tokens
+	}
+	return min(h.burst, b.tokens+elapsed*h.rate)
+}
+
+// sweep discards buckets which have refilled completely,
+// since they are equivalent to a new bucket.
+// To bound the cost of sweeping, it runs at most once per refill period.
+func (h *handshakeLimiter) sweep(now time.Time) {
+	period := time.Duration(h.burst / h.rate * float64(time.Second))
+	if now.Sub(h.lastSweep) < period {
+		return
+	}
+	h.lastSweep = now
+	for key, b := range h.buckets {
+		if h.refill(b, now) >= h.burst {
+			delete(h.buckets, key)
+		}
+	}
+}
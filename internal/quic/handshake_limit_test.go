@@ -0,0 +1,158 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestHandshakeLimiter(t *testing.T) {
+	h := newHandshakeLimiter(2, 3)
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	a := netip.MustParseAddr("10.0.0.1")
+	b := netip.MustParseAddr("10.0.0.2")
+	for i := 0; i < 3; i++ {
+		if !h.allow(now, a) {
+			t.Fatalf("handshake %v within burst: not allowed", i)
+		}
+	}
+	if h.allow(now, a) {
+		t.Fatalf("handshake exceeding burst: allowed")
+	}
+	if !h.allow(now, b) {
+		t.Fatalf("handshake from a different client: not allowed")
+	}
+	now = now.Add(500 * time.Millisecond) // one token at 2/s
+	if !h.allow(now, a) {
+		t.Fatalf("handshake after refill: not allowed")
+	}
+	if h.allow(now, a) {
+		t.Fatalf("second handshake after refilling one token: allowed")
+	}
+}
+
+func TestHandshakeLimiterIPv6Prefix(t *testing.T) {
+	h := newHandshakeLimiter(1, 1)
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	if !h.allow(now, netip.MustParseAddr("2001:db8::1")) {
+		t.Fatalf("first handshake: not allowed")
+	}
+	if h.allow(now, netip.MustParseAddr("2001:db8::2")) {
+		t.Fatalf("handshake from the same /64: allowed")
+	}
+	if !h.allow(now, netip.MustParseAddr("2001:db8:0:1::1")) {
+		t.Fatalf("handshake from a different /64: not allowed")
+	}
+	if !h.allow(now, netip.MustParseAddr("::ffff:10.0.0.1")) {
+		t.Fatalf("handshake from IPv4-mapped address: not allowed")
+	}
+	if h.allow(now, netip.MustParseAddr("10.0.0.1")) {
+		t.Fatalf("handshake from IPv4 address after its IPv4-mapped form: allowed")
+	}
+}
+
+func TestHandshakeLimiterSweep(t *testing.T) {
+	h := newHandshakeLimiter(10, 10)
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	h.allow(now, netip.MustParseAddr("10.0.0.1"))
+	h.allow(now, netip.MustParseAddr("10.0.0.2"))
+	if got, want := len(h.buckets), 2; got != want {
+		t.Fatalf("buckets = %v, want %v", got, want)
+	}
+	// After the buckets refill, they are discarded.
+	now = now.Add(2 * time.Second)
+	h.allow(now, netip.MustParseAddr("10.0.0.3"))
+	if got, want := len(h.buckets), 1; got != want {
+		t.Fatalf("after refill: buckets = %v, want %v", got, want)
+	}
+}
+
+func TestHandshakeLimiterMaxBuckets(t *testing.T) {
+	h := newHandshakeLimiter(1, 1)
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	addr := func(i int) netip.Addr {
+		return netip.AddrFrom4([4]byte{10, byte(i >> 16), byte(i >> 8), byte(i)})
+	}
+	for i := 0; i < maxHandshakeBuckets; i++ {
+		if !h.allow(now, addr(i)) {
+			t.Fatalf("first handshake from %v: not allowed", addr(i))
+		}
+	}
+	if h.allow(now, addr(maxHandshakeBuckets)) {
+		t.Fatalf("handshake from new client with limiter full: allowed")
+	}
+	if got, want := len(h.buckets), maxHandshakeBuckets; got != want {
+		t.Fatalf("buckets = %v, want %v", got, want)
+	}
+	// After the buckets refill, they are discarded and new clients are allowed.
+	now = now.Add(2 * time.Second)
+	if !h.allow(now, addr(maxHandshakeBuckets)) {
+		t.Fatalf("handshake from new client after refill: not allowed")
+	}
+}
+
+func TestListenerHandshakeRateLimit(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		action    AcceptAction
+		wantRetry bool
+		wantClose bool
+	}{{
+		name:   "drop",
+		action: AcceptDrop,
+	}, {
+		name:      "retry",
+		action:    AcceptRetry,
+		wantRetry: true,
+	}, {
+		name:      "refuse",
+		action:    AcceptRefuse,
+		wantClose: true,
+	}} {
+		t.Run(test.name, func(t *testing.T) {
+			tl := newTestListener(t, &Config{
+				TLSConfig:                newTestTLSConfig(serverSide),
+				HandshakeRateLimit:       1,
+				HandshakeRateBurst:       1,
+				HandshakeRateLimitAction: test.action,
+			})
+			tl.writeClientInitial(testPeerConnID(0), testLocalConnID(-1), nil)
+			tl.accept()
+			for tl.read() != nil {
+				// Discard the first connection's handshake datagrams.
+			}
+
+			srcConnID, dstConnID := testPeerConnID(10), []byte("limited!")
+			tl.writeClientInitial(srcConnID, dstConnID, nil)
+			if len(tl.acceptQueue) != 0 {
+				t.Errorf("server created a connection for rate limited Initial")
+			}
+			switch {
+			case test.wantClose:
+				tl.wantDatagram("server refuses rate limited connection",
+					initialConnectionCloseDatagram(dstConnID, srcConnID, errConnectionRefused))
+			case test.wantRetry:
+				d := tl.readDatagram()
+				if d == nil || len(d.packets) != 1 || d.packets[0].ptype != packetTypeRetry {
+					t.Fatalf("got datagram %v, want Retry", d)
+				}
+				retry := d.packets[0]
+				tl.writeClientInitial(srcConnID, retry.srcConnID, retry.token)
+				if len(tl.acceptQueue) == 0 {
+					t.Errorf("no connection created after client validated its address")
+				}
+			default:
+				tl.wantIdle("server drops rate limited Initial")
+			}
+			if got, want := tl.l.Stats().HandshakesRateLimited, uint64(1); got != want {
+				t.Errorf("HandshakesRateLimited = %v, want %v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"time"
+)
+
+// idleState tracks connection idle events.
+//
+// A connection is closed when no packets have been received from the peer
+// for the idle timeout period, and may send PING frames to keep itself alive.
+// https://www.rfc-editor.org/rfc/rfc9000#section-10.1
+type idleState struct {
+	// localMaxIdleTimeout is the max_idle_timeout in our transport parameters.
+	// peerMaxIdleTimeout is the max_idle_timeout in the peer's transport parameters.
+	// Zero means no timeout.
+	localMaxIdleTimeout time.Duration
+	peerMaxIdleTimeout  time.Duration
+
+	// idleDuration is the negotiated idle timeout, or zero for no timeout.
+	idleDuration time.Duration
+
+	// idleTimeout is the time at which the connection will be closed due to inactivity.
+	// keepAliveTime is the time at which we will send a PING to keep the connection alive.
+	// Either may be zero when unset.
+	idleTimeout   time.Time
+	keepAliveTime time.Time
+
+	// sentSinceLastReceive is set when we send an ack-eliciting packet,
+	// and cleared when we receive a packet.
+	sentSinceLastReceive bool
+
+	// sendKeepAlive is set when we should send a PING to keep the connection alive.
+	sendKeepAlive bool
+}
+
+// An IdleTimeoutError indicates that a connection was closed
+// because the peer did not send any packets within the idle timeout period.
+type IdleTimeoutError struct{}
+
+func (IdleTimeoutError) Error() string { return "quic: connection closed: idle timeout" }
+
+// Timeout reports whether the error is a timeout. It always returns true.
+func (IdleTimeoutError) Timeout() bool { return true }
+
+func (c *Conn) idleInit(now time.Time) {
+	c.idle.localMaxIdleTimeout = c.config.maxIdleTimeout()
+	c.idle.idleDuration = c.idle.localMaxIdleTimeout
+	c.restartIdleTimer(now)
+}
+
+// receivePeerMaxIdleTimeout handles the peer's max_idle_timeout transport parameter.
+func (c *Conn) receivePeerMaxIdleTimeout(peerMaxIdleTimeout time.Duration) {
+	c.idle.peerMaxIdleTimeout = peerMaxIdleTimeout
+	// "Each endpoint advertises a max_idle_timeout, but the effective value
+	// at an endpoint is computed as the minimum of the two advertised values
+	// (or the sole advertised value, if only one endpoint advertises a non-zero value)."
+	// https://www.rfc-editor.org/rfc/rfc9000#section-10.1-2
+	switch {
+	case c.idle.localMaxIdleTimeout == 0:
+		c.idle.idleDuration = peerMaxIdleTimeout
+	case peerMaxIdleTimeout == 0:
+		c.idle.idleDuration = c.idle.localMaxIdleTimeout
+	default:
+		c.idle.idleDuration = min(c.idle.localMaxIdleTimeout, peerMaxIdleTimeout)
+	}
+}
+
+// idleHandlePacketReceived is called when we process a packet from the peer.
+func (c *Conn) idleHandlePacketReceived(now time.Time) {
+	// "An endpoint restarts its idle timer when a packet from its peer is
+	// received and processed successfully."
+	// https://www.rfc-editor.org/rfc/rfc9000#section-10.1-3
+	c.idle.sentSinceLastReceive = false
+	c.restartIdleTimer(now)
+}
+
+// idleHandlePacketSent is called when we send a packet.
+func (c *Conn) idleHandlePacketSent(now time.Time, sent *sentPacket) {
+	if !sent.ackEliciting {
+		return
+	}
+	// Any ack-eliciting packet serves to keep the connection alive.
+	c.idle.sendKeepAlive = false
+	c.restartKeepAliveTimer(now)
+	// "An endpoint also restarts its idle timer when sending an ack-eliciting
+	// packet if no other ack-eliciting packets have been sent since last
+	// receiving and processing a packet."
+	// https://www.rfc-editor.org/rfc/rfc9000#section-10.1-3
+	if c.idle.sentSinceLastReceive {
+		return
+	}
+	c.idle.sentSinceLastReceive = true
+	c.restartIdleTimer(now)
+}
+
+func (c *Conn) restartIdleTimer(now time.Time) {
+	if c.idle.idleDuration == 0 {
+		c.idle.idleTimeout = time.Time{}
+	} else {
+		// "To avoid excessively small idle timeout periods, endpoints MUST
+		// increase the idle timeout period to be at least three times the
+		// current Probe Timeout (PTO)."
+		// https://www.rfc-editor.org/rfc/rfc9000#section-10.1-4
+		c.idle.idleTimeout = now.Add(max(c.idle.idleDuration, 3*c.loss.ptoBasePeriod()))
+	}
+	c.restartKeepAliveTimer(now)
+}
+
+func (c *Conn) restartKeepAliveTimer(now time.Time) {
+	period := c.config.KeepAlivePeriod
+	if period <= 0 {
+		c.idle.keepAliveTime = time.Time{}
+		return
+	}
+	if c.idle.idleDuration > 0 {
+		period = min(period, c.idle.idleDuration/2)
+	}
+	c.idle.keepAliveTime = now.Add(period)
+}
+
+// idleAdvance is called when time passes.
+// It reports whether the connection has timed out.
+func (c *Conn) idleAdvance(now time.Time) (shouldExit bool) {
+	if !c.idle.idleTimeout.IsZero() && !now.Before(c.idle.idleTimeout) {
+		return true
+	}
+	if !c.idle.keepAliveTime.IsZero() && !now.Before(c.idle.keepAliveTime) {
+		c.idle.keepAliveTime = time.Time{}
+		c.idle.sendKeepAlive = true
+	}
+	return false
+}
+
+// idleNextTimeout returns the time of the next idle-related event,
+// or zero if there is none.
+func (c *Conn) idleNextTimeout() time.Time {
+	return firstTime(c.idle.idleTimeout, c.idle.keepAliveTime)
+}
+
+// appendKeepAlive appends a PING frame to keep the connection alive, if necessary.
+// It reports whether the frame was appended or was unnecessary.
+func (c *Conn) appendKeepAlive() bool {
+	if !c.idle.sendKeepAlive || c.w.sent.ackEliciting {
+		return true // no keep-alive needed, or packet is already ack-eliciting
+	}
+	return c.w.appendPingFrame()
+}
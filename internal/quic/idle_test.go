@@ -0,0 +1,165 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestIdleTimeoutNegotiated(t *testing.T) {
+	for _, test := range []struct {
+		localMaxIdleTimeout time.Duration
+		peerMaxIdleTimeout  time.Duration
+		wantTimeout         time.Duration
+	}{{
+		localMaxIdleTimeout: 10 * time.Second,
+		peerMaxIdleTimeout:  20 * time.Second,
+		wantTimeout:         10 * time.Second,
+	}, {
+		localMaxIdleTimeout: 20 * time.Second,
+		peerMaxIdleTimeout:  10 * time.Second,
+		wantTimeout:         10 * time.Second,
+	}, {
+		localMaxIdleTimeout: 0, // default
+		peerMaxIdleTimeout:  0, // no timeout
+		wantTimeout:         defaultMaxIdleTimeout,
+	}, {
+		localMaxIdleTimeout: -1, // no timeout
+		peerMaxIdleTimeout:  10 * time.Second,
+		wantTimeout:         10 * time.Second,
+	}} {
+		name := fmt.Sprintf("local=%v/peer=%v", test.localMaxIdleTimeout, test.peerMaxIdleTimeout)
+		t.Run(name, func(t *testing.T) {
+			tc := newTestConn(t, serverSide, func(p *transportParameters) {
+				p.maxIdleTimeout = test.peerMaxIdleTimeout
+			}, func(c *Config) {
+				c.MaxIdleTimeout = test.localMaxIdleTimeout
+			})
+			tc.handshake()
+			tc.ignoreFrame(frameTypeAck)
+			tc.writeAckForAll()
+			tc.writeFrames(packetType1RTT, debugFramePing{})
+
+			tc.advance(test.wantTimeout - 1)
+			if tc.conn.exited {
+				t.Fatalf("conn exited before idle timeout")
+			}
+			tc.advance(1)
+			if !tc.conn.exited {
+				t.Fatalf("conn did not exit after idle timeout")
+			}
+			var idleErr IdleTimeoutError
+			if err := tc.conn.Wait(canceledContext()); !errors.As(err, &idleErr) {
+				t.Fatalf("conn.Wait() = %v, want IdleTimeoutError", err)
+			}
+			tc.wantIdle("conn does not send CONNECTION_CLOSE after idle timeout")
+		})
+	}
+}
+
+func TestIdleTimeoutDisabled(t *testing.T) {
+	tc := newTestConn(t, serverSide, func(p *transportParameters) {
+		p.maxIdleTimeout = 0
+	}, func(c *Config) {
+		c.MaxIdleTimeout = -1
+	})
+	tc.handshake()
+	tc.ignoreFrame(frameTypeAck)
+	tc.writeAckForAll()
+	tc.advance(24 * time.Hour)
+	if tc.conn.exited {
+		t.Fatalf("conn with no idle timeout exited")
+	}
+}
+
+func TestIdleTimeoutRestartedBySend(t *testing.T) {
+	const idleTimeout = 10 * time.Second
+	ctx := canceledContext()
+	tc := newTestConn(t, clientSide, permissiveTransportParameters, func(c *Config) {
+		c.MaxIdleTimeout = idleTimeout
+	})
+	tc.handshake()
+	tc.ignoreFrame(frameTypeAck)
+	tc.writeAckForAll()
+	tc.writeFrames(packetType1RTT, debugFramePing{})
+
+	// Sending the first ack-eliciting packet since the last packet received
+	// restarts the idle timer.
+	tc.advance(idleTimeout / 2)
+	s, err := tc.conn.newLocalStream(ctx, uniStream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Write([]byte{0})
+	tc.wantFrame("stream data is sent",
+		packetType1RTT, debugFrameStream{
+			id:   s.id,
+			data: []byte{0},
+		})
+	// The peer does not acknowledge the data, so the idle timer was
+	// last restarted when the stream data was sent.
+	tc.advance(idleTimeout - 1)
+	if tc.conn.exited {
+		t.Fatalf("conn exited before idle timeout")
+	}
+	tc.advance(1)
+	if !tc.conn.exited {
+		t.Fatalf("conn did not exit after idle timeout")
+	}
+}
+
+func TestIdleKeepAlive(t *testing.T) {
+	for _, test := range []struct {
+		maxIdleTimeout  time.Duration
+		keepAlivePeriod time.Duration
+		wantPeriod      time.Duration
+	}{{
+		maxIdleTimeout:  30 * time.Second,
+		keepAlivePeriod: 10 * time.Second,
+		wantPeriod:      10 * time.Second,
+	}, {
+		// The keep-alive period is at most half the idle timeout.
+		maxIdleTimeout:  10 * time.Second,
+		keepAlivePeriod: 30 * time.Second,
+		wantPeriod:      5 * time.Second,
+	}, {
+		maxIdleTimeout:  -1, // no timeout
+		keepAlivePeriod: 30 * time.Second,
+		wantPeriod:      30 * time.Second,
+	}} {
+		name := fmt.Sprintf("idle=%v/keepalive=%v", test.maxIdleTimeout, test.keepAlivePeriod)
+		t.Run(name, func(t *testing.T) {
+			tc := newTestConn(t, serverSide, func(p *transportParameters) {
+				p.maxIdleTimeout = 0
+			}, func(c *Config) {
+				c.MaxIdleTimeout = test.maxIdleTimeout
+				c.KeepAlivePeriod = test.keepAlivePeriod
+			})
+			tc.handshake()
+			tc.ignoreFrame(frameTypeAck)
+			tc.writeAckForAll()
+			tc.writeFrames(packetType1RTT, debugFramePing{})
+
+			// Keep-alive PINGs keep the connection open for longer
+			// than the idle timeout, so long as the peer responds.
+			for i := 0; i < 4; i++ {
+				tc.advance(test.wantPeriod - 1)
+				tc.wantIdle("no keep-alive before keep-alive period")
+				tc.advance(1)
+				tc.wantFrame("conn sends keep-alive PING",
+					packetType1RTT, debugFramePing{})
+				tc.writeAckForAll()
+			}
+			if tc.conn.exited {
+				t.Fatalf("conn exited despite keep-alives")
+			}
+		})
+	}
+}
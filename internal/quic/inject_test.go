@@ -0,0 +1,262 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"testing"
+)
+
+// Tests in this file inject forged or unexpected packets into a connection,
+// and verify both that the connection discards or accepts them correctly
+// and that the decision is reported to the connection's tracer.
+
+// injectRetry sends the client under test a Retry packet
+// as if from an on-path attacker or a confused server.
+// The mutate func, if non-nil, may modify the encoded packet.
+func (tc *testConn) injectRetry(token []byte, mutate func(pkt []byte)) {
+	tc.t.Helper()
+	pkt := encodeRetryPacket(version1Params, testLocalConnID(-1), retryPacket{
+		srcConnID: testPeerConnID(100),
+		dstConnID: testLocalConnID(0),
+		token:     token,
+	})
+	if mutate != nil {
+		mutate(pkt)
+	}
+	tc.listener.write(&datagram{
+		b:    pkt,
+		addr: testClientAddr,
+	})
+}
+
+// injectVersionNegotiation sends the client under test a Version Negotiation packet
+// in response to its Initial packet p.
+func (tc *testConn) injectVersionNegotiation(p *testPacket, srcConnID []byte, versions ...uint32) {
+	tc.t.Helper()
+	tc.listener.write(&datagram{
+		b: appendVersionNegotiation(nil, p.srcConnID, srcConnID, versions...),
+	})
+}
+
+// injectStatelessReset sends the conn under test a datagram
+// ending in the given stateless reset token.
+func (tc *testConn) injectStatelessReset(token statelessResetToken) {
+	tc.t.Helper()
+	dgram := append([]byte{headerFormShort | fixedBit}, testLocalConnID(0)...)
+	for len(dgram) < 100-len(token) {
+		dgram = append(dgram, byte(len(dgram))) // semi-random junk
+	}
+	dgram = append(dgram, token[:]...)
+	tc.listener.write(&datagram{
+		b: dgram,
+	})
+}
+
+// wantDropped asserts that the most recently traced dropped packet
+// has the given type and reason.
+func (tr *testTracer) wantDropped(t *testing.T, expectation, ptype string, reason TraceDropReason) {
+	t.Helper()
+	if len(tr.dropped) == 0 {
+		t.Fatalf("%v: no dropped packets traced, want %v (%v)", expectation, ptype, reason)
+	}
+	got := tr.dropped[len(tr.dropped)-1]
+	if got.p.Type != ptype || got.reason != reason {
+		t.Fatalf("%v: traced dropped packet %v (%v), want %v (%v)",
+			expectation, got.p.Type, got.reason, ptype, reason)
+	}
+	if got.p.Size <= 0 {
+		t.Errorf("%v: traced dropped packet has size %v, want > 0", expectation, got.p.Size)
+	}
+}
+
+// wantReceived asserts that a packet of the given type was traced as received.
+func (tr *testTracer) wantReceived(t *testing.T, expectation, ptype string) {
+	t.Helper()
+	for _, p := range tr.received {
+		if p.Type == ptype {
+			if p.Number != -1 {
+				t.Errorf("%v: traced %v packet has number %v, want -1", expectation, ptype, p.Number)
+			}
+			if p.Size <= 0 {
+				t.Errorf("%v: traced %v packet has size %v, want > 0", expectation, ptype, p.Size)
+			}
+			return
+		}
+	}
+	t.Fatalf("%v: no %v packet traced as received", expectation, ptype)
+}
+
+// continueClientHandshake feeds the client under test the server's
+// Initial and Handshake flights and expects it to respond.
+func (tc *testConn) continueClientHandshake(expectation string) {
+	tc.t.Helper()
+	tc.writeFrames(packetTypeInitial,
+		debugFrameCrypto{
+			data: tc.cryptoDataIn[tls.QUICEncryptionLevelInitial],
+		})
+	tc.writeFrames(packetTypeHandshake,
+		debugFrameCrypto{
+			data: tc.cryptoDataIn[tls.QUICEncryptionLevelHandshake],
+		})
+	tc.wantFrameType(expectation,
+		packetTypeHandshake, debugFrameCrypto{})
+}
+
+func TestInjectRetryInvalidIntegrityTag(t *testing.T) {
+	tc, tr := newTracedTestConn(t, clientSide)
+	tc.ignoreFrame(frameTypeAck)
+	tc.wantFrameType("client Initial CRYPTO data",
+		packetTypeInitial, debugFrameCrypto{})
+	tc.injectRetry([]byte{1, 2, 3, 4}, func(pkt []byte) {
+		pkt[len(pkt)-1] ^= 1 // invalidate the integrity tag
+	})
+	tr.wantDropped(t, "forged Retry", "Retry", TraceDropIntegrityCheckFailed)
+	tc.wantIdle("client ignores forged Retry")
+	tc.continueClientHandshake("handshake continues after forged Retry")
+}
+
+func TestInjectRetryAfterProcessingPacket(t *testing.T) {
+	tc, tr := newTracedTestConn(t, clientSide)
+	tc.ignoreFrame(frameTypeAck)
+	tc.ignoreFrame(frameTypeNewConnectionID)
+	tc.wantFrameType("client Initial CRYPTO data",
+		packetTypeInitial, debugFrameCrypto{})
+	tc.writeFrames(packetTypeInitial,
+		debugFrameCrypto{
+			data: tc.cryptoDataIn[tls.QUICEncryptionLevelInitial],
+		})
+	tc.injectRetry([]byte{1, 2, 3, 4}, nil)
+	tr.wantDropped(t, "Retry after server Initial", "Retry", TraceDropUnexpected)
+	tc.wantIdle("client ignores Retry after server Initial")
+}
+
+func TestInjectRetryTwice(t *testing.T) {
+	tc, tr := newTracedTestConn(t, clientSide)
+	tc.wantFrameType("client Initial CRYPTO data",
+		packetTypeInitial, debugFrameCrypto{})
+	tc.injectRetry([]byte{1, 2, 3, 4}, nil)
+	tr.wantReceived(t, "first Retry", "Retry")
+	if len(tr.dropped) != 0 {
+		t.Fatalf("first Retry: traced dropped packets %v, want none", tr.dropped)
+	}
+	tc.wantFrameType("client resends Initial CRYPTO data",
+		packetTypeInitial, debugFrameCrypto{})
+	tc.injectRetry([]byte{5, 6, 7, 8}, nil)
+	tr.wantDropped(t, "second Retry", "Retry", TraceDropUnexpected)
+	tc.wantIdle("client ignores second Retry")
+}
+
+func TestInjectRetryZeroLengthToken(t *testing.T) {
+	tc, tr := newTracedTestConn(t, clientSide)
+	tc.wantFrameType("client Initial CRYPTO data",
+		packetTypeInitial, debugFrameCrypto{})
+	tc.injectRetry([]byte{}, nil)
+	tr.wantDropped(t, "Retry with zero-length token", "Retry", TraceDropInvalid)
+	tc.wantIdle("client ignores Retry with zero-length token")
+}
+
+func TestInjectRetryToServer(t *testing.T) {
+	tc, tr := newTracedTestConn(t, serverSide)
+	tc.handshake()
+	tc.write(&testDatagram{
+		packets: []*testPacket{{
+			ptype:             packetTypeRetry,
+			originalDstConnID: testLocalConnID(-1),
+			srcConnID:         testPeerConnID(0),
+			dstConnID:         testLocalConnID(0),
+			token:             []byte{1, 2, 3, 4},
+		}},
+	})
+	tr.wantDropped(t, "Retry sent to server", "Retry", TraceDropUnexpected)
+}
+
+func TestInjectVersionNegotiationMismatchingSourceConnID(t *testing.T) {
+	tc, tr := newTracedTestConn(t, clientSide)
+	tc.ignoreFrame(frameTypeAck)
+	p := tc.readPacket() // client Initial packet
+	tc.injectVersionNegotiation(p, []byte("mismatch"), 10)
+	tr.wantDropped(t, "Version Negotiation with mismatched conn ID",
+		"Version Negotiation", TraceDropInvalid)
+	tc.continueClientHandshake("handshake continues after forged Version Negotiation")
+}
+
+func TestInjectVersionNegotiationListingOwnVersion(t *testing.T) {
+	tc, tr := newTracedTestConn(t, clientSide)
+	p := tc.readPacket() // client Initial packet
+	tc.injectVersionNegotiation(p, p.dstConnID, 10, quicVersion1)
+	tr.wantDropped(t, "Version Negotiation listing client's version",
+		"Version Negotiation", TraceDropInvalid)
+	if err := tc.conn.waitReady(canceledContext()); err != context.Canceled {
+		t.Errorf("conn.waitReady() = %v, want context.Canceled", err)
+	}
+}
+
+func TestInjectVersionNegotiationAfterProcessingPacket(t *testing.T) {
+	tc, tr := newTracedTestConn(t, clientSide)
+	tc.ignoreFrame(frameTypeAck)
+	p := tc.readPacket() // client Initial packet
+	tc.writeFrames(packetTypeInitial,
+		debugFrameCrypto{
+			data: tc.cryptoDataIn[tls.QUICEncryptionLevelInitial],
+		})
+	tc.injectVersionNegotiation(p, p.dstConnID, 10)
+	tr.wantDropped(t, "Version Negotiation after server Initial",
+		"Version Negotiation", TraceDropUnexpected)
+	if err := tc.conn.waitReady(canceledContext()); err != context.Canceled {
+		t.Errorf("conn.waitReady() = %v, want context.Canceled", err)
+	}
+}
+
+func TestInjectVersionNegotiationAccepted(t *testing.T) {
+	tc, tr := newTracedTestConn(t, clientSide)
+	p := tc.readPacket() // client Initial packet
+	tc.injectVersionNegotiation(p, p.dstConnID, 10)
+	tr.wantReceived(t, "valid Version Negotiation", "Version Negotiation")
+	if len(tr.dropped) != 0 {
+		t.Errorf("valid Version Negotiation: traced dropped packets %v, want none", tr.dropped)
+	}
+	var verr *VersionNegotiationError
+	if err := tc.conn.waitReady(canceledContext()); !errors.As(err, &verr) {
+		t.Errorf("conn.waitReady() = %v, want VersionNegotiationError", err)
+	}
+}
+
+func TestInjectStatelessResetDuringHandshake(t *testing.T) {
+	tc, tr := newTracedTestConn(t, clientSide)
+	tc.ignoreFrame(frameTypeAck)
+	tc.wantFrameType("client Initial CRYPTO data",
+		packetTypeInitial, debugFrameCrypto{})
+	// The server hasn't sent any stateless reset tokens yet,
+	// so any reset is necessarily forged.
+	tc.injectStatelessReset(testPeerStatelessResetToken(0))
+	tr.wantDropped(t, "forged stateless reset", "1-RTT", TraceDropKeysUnavailable)
+	for _, p := range tr.received {
+		if p.Type == "Stateless Reset" {
+			t.Fatalf("forged stateless reset traced as received")
+		}
+	}
+	if err := tc.conn.Wait(canceledContext()); err != context.Canceled {
+		t.Fatalf("conn.Wait() = %v, want context.Canceled (conn still open)", err)
+	}
+	tc.continueClientHandshake("handshake continues after forged stateless reset")
+}
+
+func TestInjectStatelessResetAccepted(t *testing.T) {
+	resetToken := testPeerStatelessResetToken(0)
+	tc, tr := newTracedTestConn(t, clientSide, func(p *transportParameters) {
+		p.statelessResetToken = resetToken[:]
+	})
+	tc.handshake()
+	tc.injectStatelessReset(resetToken)
+	tr.wantReceived(t, "valid stateless reset", "Stateless Reset")
+	if err := tc.conn.Wait(canceledContext()); !errors.Is(err, errStatelessReset) {
+		t.Errorf("conn.Wait() = %v, want errStatelessReset", err)
+	}
+}
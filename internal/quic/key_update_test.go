@@ -215,6 +215,9 @@ func TestKeyUpdateLocallyInitiated(t *testing.T) {
 	if got, want := tc.lastPacket.keyNumber, 1; got != want {
 		t.Errorf("after key update, conn sent packet with key %v, want %v", got, want)
 	}
+	if got, want := tc.conn.keysAppData.updates, int64(1); got != want {
+		t.Errorf("after key update, completed updates = %v, want %v", got, want)
+	}
 
 	// Peer initiates its own update.
 	tc.sendKeyNumber = 2
@@ -9,11 +9,12 @@ package quic
 import (
 	"context"
 	"crypto/rand"
+	"crypto/tls"
 	"errors"
+	"fmt"
 	"net"
 	"net/netip"
 	"sync"
-	"sync/atomic"
 	"time"
 )
 
@@ -24,17 +25,43 @@ import (
 type Listener struct {
 	config    *Config
 	udpConn   udpConn
+	batch     *batchConn // nil if udpConn is not a *net.UDPConn
 	testHooks listenerTestHooks
 	resetGen  statelessResetTokenGenerator
+	dgrams    datagramPool
 	retry     retryState
-
-	acceptQueue queue[*Conn] // new inbound connections
-	connsMap    connsMap     // only accessed by the listen loop
-
-	connsMu sync.Mutex
-	conns   map[*Conn]struct{}
-	closing bool          // set when Close is called
-	closec  chan struct{} // closed when the listen loop exits
+	stats     listenerStats
+	pool      *connPool // nil unless Config.ConnWorkers is set
+
+	// handshakeLimit is nil unless Config.HandshakeRateLimit is set.
+	handshakeLimit *handshakeLimiter
+
+	// loadTestSessions is the session cache shared by client connections
+	// when Config.InsecureLoadTesting is set.
+	loadTestSessions tls.ClientSessionCache
+
+	acceptQueue queue[*Conn]    // new inbound connections
+	connsMap    connsMap        // read by the listen loop, updated by conns
+	unknownDest unknownDestPool // handles datagrams for unknown conns
+
+	// connsMu guards the fields below.
+	// It must not be held while sending a message to a conn
+	// (Conn.Abort, Conn.exit), since a conn's loop acquires it
+	// in connDrained and may not be receiving messages until it does.
+	connsMu     sync.Mutex
+	conns       map[*Conn]struct{}
+	unaccepted  map[*Conn]struct{} // inbound conns not yet returned by Accept
+	queued      map[*Conn]struct{} // unaccepted conns in an accept queue
+	handshaking map[*Conn]string   // inbound conns not yet established, to key in initialConns
+	// initialConns maps the destination connection ID of a client's Initial packet
+	// to the inbound conn it created, until the conn is established.
+	// This lets unknownDestPool workers find conns which the listen loop
+	// may not know about yet.
+	initialConns map[string]*Conn
+	protocols    map[string]*ProtocolListener // by ALPN protocol
+	closing      bool                         // set when Close or Shutdown is called
+	onShutdown   []func()                     // functions registered with RegisterOnShutdown
+	closec       chan struct{}                // closed when the listen loop exits
 }
 
 type listenerTestHooks interface {
@@ -51,36 +78,155 @@ type udpConn interface {
 	WriteToUDPAddrPort(b []byte, addr netip.AddrPort) (int, error)
 }
 
+// A DatagramTransport sends and receives datagrams for a Listener.
+// It is implemented by net.PacketConn.
+//
+// A DatagramTransport may be used to run QUIC over something other
+// than an operating system UDP socket, such as a datagram channel
+// provided by the host in js/wasm or wasip1 environments,
+// or an in-memory network for testing.
+//
+// The addresses used by the transport should be *net.UDPAddrs,
+// or have a String method returning an address accepted by netip.ParseAddrPort.
+type DatagramTransport interface {
+	// ReadFrom reads a datagram into p, returning its length and source address.
+	// It blocks until a datagram is available or the transport is closed.
+	ReadFrom(p []byte) (n int, addr net.Addr, err error)
+
+	// WriteTo sends a datagram containing p to addr.
+	WriteTo(p []byte, addr net.Addr) (n int, err error)
+
+	// Close closes the transport.
+	// Any blocked ReadFrom operations are unblocked and return errors.
+	Close() error
+
+	// LocalAddr returns the transport's local address.
+	LocalAddr() net.Addr
+}
+
+// A udpPacketConn adapts a DatagramTransport which is not a *net.UDPConn
+// to the udpConn interface.
+type udpPacketConn struct {
+	pc DatagramTransport
+}
+
+func (c *udpPacketConn) Close() error {
+	return c.pc.Close()
+}
+
+func (c *udpPacketConn) LocalAddr() net.Addr {
+	addr := c.pc.LocalAddr()
+	if _, ok := addr.(*net.UDPAddr); ok {
+		return addr
+	}
+	ap, _ := addrPortFromAddr(addr)
+	return net.UDPAddrFromAddrPort(ap)
+}
+
+func (c *udpPacketConn) ReadMsgUDPAddrPort(b, control []byte) (n, controln, flags int, _ netip.AddrPort, _ error) {
+	n, addr, err := c.pc.ReadFrom(b)
+	if err != nil {
+		return n, 0, 0, netip.AddrPort{}, err
+	}
+	ap, err := addrPortFromAddr(addr)
+	if err != nil {
+		// Drop datagrams from addresses we can't reply to.
+		return 0, 0, 0, netip.AddrPort{}, nil
+	}
+	return n, 0, 0, ap, nil
+}
+
+func (c *udpPacketConn) WriteToUDPAddrPort(b []byte, addr netip.AddrPort) (int, error) {
+	return c.pc.WriteTo(b, net.UDPAddrFromAddrPort(addr))
+}
+
+// addrPortFromAddr converts a net.Addr to a netip.AddrPort.
+func addrPortFromAddr(addr net.Addr) (netip.AddrPort, error) {
+	if a, ok := addr.(*net.UDPAddr); ok {
+		return a.AddrPort(), nil
+	}
+	return netip.ParseAddrPort(addr.String())
+}
+
 // Listen listens on a local network address.
 // The configuration config must be non-nil.
 func Listen(network, address string, config *Config) (*Listener, error) {
-	if config.TLSConfig == nil {
-		return nil, errors.New("TLSConfig is not set")
+	if err := validateListenerConfig(config); err != nil {
+		return nil, err
 	}
-	a, err := net.ResolveUDPAddr(network, address)
+	udpConn, err := listenUDP(network, address, config, false)
 	if err != nil {
 		return nil, err
 	}
-	udpConn, err := net.ListenUDP(network, a)
+	l, err := newListener(udpConn, config, nil)
 	if err != nil {
+		udpConn.Close()
 		return nil, err
 	}
-	return newListener(udpConn, config, nil)
+	return l, nil
+}
+
+// ListenPacketConn creates a Listener which sends and receives datagrams on pc.
+// The configuration config must be non-nil.
+//
+// ListenPacketConn allows the caller to supply a pre-configured socket,
+// such as one with custom socket options or a file descriptor inherited
+// from a service manager, or a DatagramTransport which is not
+// an operating system socket at all.
+// The Listener takes ownership of pc, and closes it when the Listener is closed.
+// The local address of pc should be a *net.UDPAddr.
+func ListenPacketConn(pc DatagramTransport, config *Config) (*Listener, error) {
+	if err := validateListenerConfig(config); err != nil {
+		return nil, err
+	}
+	u, ok := pc.(udpConn)
+	if !ok {
+		u = &udpPacketConn{pc}
+	}
+	return newListener(u, config, nil)
+}
+
+func validateListenerConfig(config *Config) error {
+	if config.TLSConfig == nil {
+		return errors.New("TLSConfig is not set")
+	}
+	for _, v := range config.Versions {
+		if paramsForVersion(uint32(v)) == nil {
+			return fmt.Errorf("unsupported QUIC version %v", v)
+		}
+	}
+	return nil
 }
 
 func newListener(udpConn udpConn, config *Config, hooks listenerTestHooks) (*Listener, error) {
 	l := &Listener{
-		config:      config,
-		udpConn:     udpConn,
-		testHooks:   hooks,
-		conns:       make(map[*Conn]struct{}),
-		acceptQueue: newQueue[*Conn](),
-		closec:      make(chan struct{}),
-	}
+		config:       config,
+		udpConn:      udpConn,
+		testHooks:    hooks,
+		conns:        make(map[*Conn]struct{}),
+		unaccepted:   make(map[*Conn]struct{}),
+		queued:       make(map[*Conn]struct{}),
+		handshaking:  make(map[*Conn]string),
+		initialConns: make(map[string]*Conn),
+		acceptQueue:  newQueue[*Conn](),
+		closec:       make(chan struct{}),
+	}
+	l.batch = newBatchConn(udpConn)
 	l.resetGen.init(config.StatelessResetKey)
+	l.unknownDest.init(l)
+	l.dgrams.init(config.recvBufferSize(), config.maxIdleDatagramBuffers())
 	l.connsMap.init()
-	if config.RequireAddressValidation {
-		if err := l.retry.init(); err != nil {
+	if config.ConnWorkers > 0 {
+		l.pool = newConnPool(config.ConnWorkers, l.closec)
+	}
+	if config.InsecureLoadTesting {
+		l.loadTestSessions = tls.NewLRUClientSessionCache(0)
+	}
+	if config.HandshakeRateLimit > 0 {
+		l.handshakeLimit = newHandshakeLimiter(config.HandshakeRateLimit, config.HandshakeRateBurst)
+	}
+	if config.RequireAddressValidation || config.AcceptFilter != nil || config.DatagramFilter != nil || l.handshakeLimit != nil || config.MaxHandshakes > 0 {
+		if err := l.retry.init(config.RetryKey); err != nil {
 			return nil, err
 		}
 	}
@@ -94,6 +240,13 @@ func (l *Listener) LocalAddr() netip.AddrPort {
 	return a.AddrPort()
 }
 
+// WriteTo sends a UDP datagram containing b to addr
+// from the Listener's socket.
+// It may be used to reply to datagrams received by Config.NonQUICDatagram.
+func (l *Listener) WriteTo(b []byte, addr netip.AddrPort) error {
+	return l.sendDatagram(b, netip.Addr{}, addr)
+}
+
 // Close closes the listener.
 // Any blocked operations on the Listener or associated Conns and Stream will be unblocked
 // and return errors.
@@ -102,45 +255,144 @@ func (l *Listener) LocalAddr() netip.AddrPort {
 // Data in stream read and write buffers is discarded.
 // It waits for the peers of any open connection to acknowledge the connection has been closed.
 func (l *Listener) Close(ctx context.Context) error {
-	l.acceptQueue.close(errors.New("listener closed"))
+	l.acceptQueue.close(errListenerClosed)
+	l.closeProtocolListeners()
 	l.connsMu.Lock()
-	if !l.closing {
-		l.closing = true
-		for c := range l.conns {
-			c.Abort(localTransportError(errNo))
-		}
-		if len(l.conns) == 0 {
-			l.udpConn.Close()
-		}
-	}
+	l.startClosing()
+	conns := connSlice(l.conns)
 	l.connsMu.Unlock()
+	// Don't hold connsMu while sending to conns:
+	// A conn which is exiting may be blocked waiting for it in connDrained.
+	for _, c := range conns {
+		c.Abort(localTransportError(errNo))
+	}
 	select {
 	case <-l.closec:
 	case <-ctx.Done():
+		// As above, don't hold connsMu while sending to conns.
 		l.connsMu.Lock()
-		for c := range l.conns {
+		conns := connSlice(l.conns)
+		l.connsMu.Unlock()
+		for _, c := range conns {
 			c.exit()
 		}
-		l.connsMu.Unlock()
 		return ctx.Err()
 	}
 	return nil
 }
 
+// Shutdown gracefully shuts down the listener.
+//
+// Shutdown stops accepting new connections,
+// and closes any inbound connections which have not been returned by Accept.
+// It then waits for all other connections to close before closing the underlying socket.
+// Functions registered with RegisterOnShutdown are called when Shutdown begins,
+// and may be used to ask open connections to close.
+//
+// If ctx expires before all connections have closed,
+// Shutdown returns the context's error and leaves the remaining connections open.
+// Close may be called to abort them.
+func (l *Listener) Shutdown(ctx context.Context) error {
+	l.acceptQueue.close(errListenerClosed)
+	l.closeProtocolListeners()
+	l.connsMu.Lock()
+	var unaccepted []*Conn
+	if !l.closing {
+		unaccepted = connSlice(l.unaccepted)
+		for _, f := range l.onShutdown {
+			go f()
+		}
+	}
+	l.startClosing()
+	l.connsMu.Unlock()
+	// As in Close, abort the conns after releasing connsMu.
+	for _, c := range unaccepted {
+		c.Abort(localTransportError(errNo))
+	}
+	select {
+	case <-l.closec:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// RegisterOnShutdown registers a function to call on Shutdown.
+// Each function is called in its own goroutine.
+func (l *Listener) RegisterOnShutdown(f func()) {
+	l.connsMu.Lock()
+	defer l.connsMu.Unlock()
+	l.onShutdown = append(l.onShutdown, f)
+}
+
+// connSlice returns the conns in a set.
+func connSlice(set map[*Conn]struct{}) []*Conn {
+	conns := make([]*Conn, 0, len(set))
+	for c := range set {
+		conns = append(conns, c)
+	}
+	return conns
+}
+
+// startClosing prevents the creation of new connections,
+// and arranges for the socket to be closed once no connections remain.
+// l.connsMu must be held.
+func (l *Listener) startClosing() {
+	if l.closing {
+		return
+	}
+	l.closing = true
+	if len(l.conns) == 0 {
+		l.udpConn.Close()
+	}
+}
+
 // Accept waits for and returns the next connection to the listener.
 func (l *Listener) Accept(ctx context.Context) (*Conn, error) {
-	return l.acceptQueue.get(ctx, nil)
+	c, err := l.acceptQueue.get(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	l.connsMu.Lock()
+	l.removeUnacceptedLocked(c)
+	l.connsMu.Unlock()
+	return c, nil
+}
+
+// removeUnacceptedLocked records that an inbound conn has been returned by Accept,
+// or has exited.
+func (l *Listener) removeUnacceptedLocked(c *Conn) {
+	delete(l.unaccepted, c)
+	delete(l.queued, c)
 }
 
 // Dial creates and returns a connection to a network address.
+//
+// The host is resolved with Config.Resolver.
+// If it has several addresses, Dial uses the first IPv4 address, if any.
+// See DialAddrs to try each address in turn.
 func (l *Listener) Dial(ctx context.Context, network, address string) (*Conn, error) {
-	u, err := net.ResolveUDPAddr(network, address)
+	ips, port, err := l.resolve(ctx, network, address)
 	if err != nil {
 		return nil, err
 	}
-	addr := u.AddrPort()
+	ip := ips[0]
+	for _, a := range ips {
+		if a.Is4() {
+			ip = a
+			break
+		}
+	}
+	return l.dialAddr(ctx, netip.AddrPortFrom(ip, port))
+}
+
+// dialAddr creates and returns a connection to addr.
+func (l *Listener) dialAddr(ctx context.Context, addr netip.AddrPort) (*Conn, error) {
 	addr = netip.AddrPortFrom(addr.Addr().Unmap(), addr.Port())
-	c, err := l.newConn(time.Now(), clientSide, nil, nil, addr)
+	if !l.config.sourceAllowed(addr.Addr()) {
+		return nil, fmt.Errorf("quic: address %v is denied by Config", addr.Addr())
+	}
+	c, err := l.newConn(l.timeNow(), clientSide, l.config.dialVersion(), nil, nil, addr, netip.AddrPort{})
 	if err != nil {
 		return nil, err
 	}
@@ -151,24 +403,100 @@ func (l *Listener) Dial(ctx context.Context, network, address string) (*Conn, er
 	return c, nil
 }
 
-func (l *Listener) newConn(now time.Time, side connSide, originalDstConnID, retrySrcConnID []byte, peerAddr netip.AddrPort) (*Conn, error) {
+var (
+	// errListenerClosed is returned by newConn after Close or Shutdown is called.
+	errListenerClosed = errors.New("listener closed")
+
+	// errAcceptQueueFull is returned by newConn when an inbound connection
+	// would exceed Config.MaxAcceptQueue.
+	errAcceptQueueFull = errors.New("accept queue full")
+
+	// errHandshakesAtLimit is returned by newConn when an inbound connection
+	// would exceed Config.MaxHandshakes.
+	errHandshakesAtLimit = errors.New("too many handshakes in progress")
+)
+
+// newConn creates a conn.
+// localAddr is the local address the peer sent its first datagram to,
+// if it is known and more specific than the Listener's address.
+func (l *Listener) newConn(now time.Time, side connSide, version *versionParams, originalDstConnID, retrySrcConnID []byte, peerAddr, localAddr netip.AddrPort) (*Conn, error) {
 	l.connsMu.Lock()
 	defer l.connsMu.Unlock()
 	if l.closing {
-		return nil, errors.New("listener closed")
+		return nil, errListenerClosed
+	}
+	if side == serverSide {
+		if lim := l.config.maxAcceptQueue(); lim > 0 && len(l.queued) >= lim {
+			l.stats.connsRefused.Add(1)
+			return nil, errAcceptQueueFull
+		}
+		// unknownDestPool workers check the handshake limit before creating
+		// a conn, but several may do so at once. Check it again here,
+		// where the conn is added to handshaking.
+		// A client which has validated its address in response to
+		// an AcceptRetry for the limit may exceed it.
+		validated := retrySrcConnID != nil && l.config.MaxHandshakesAction == AcceptRetry
+		if l.handshakesAtLimitLocked() && !validated {
+			return nil, errHandshakesAtLimit
+		}
 	}
-	c, err := newConn(now, side, originalDstConnID, retrySrcConnID, peerAddr, l.config, l)
+	c, err := newConn(now, side, version, originalDstConnID, retrySrcConnID, peerAddr, localAddr, l.config, l)
 	if err != nil {
 		return nil, err
 	}
 	l.conns[c] = struct{}{}
+	if side == serverSide {
+		l.unaccepted[c] = struct{}{}
+		// Datagrams from the client are addressed to the Retry packet's
+		// source connection ID, if we sent one.
+		key := string(originalDstConnID)
+		if retrySrcConnID != nil {
+			key = string(retrySrcConnID)
+		}
+		l.handshaking[c] = key
+		l.initialConns[key] = c
+	}
+	l.stats.handshakesStarted.Add(1)
 	return c, nil
 }
 
 // serverConnEstablished is called by a conn when the handshake completes
 // for an inbound (serverSide) connection.
 func (l *Listener) serverConnEstablished(c *Conn) {
-	l.acceptQueue.put(c)
+	l.connsMu.Lock()
+	l.removeHandshakingLocked(c)
+	l.connsMu.Unlock()
+	l.serverConnReady(c)
+}
+
+// removeHandshakingLocked records that an inbound conn is no longer in its handshake.
+func (l *Listener) removeHandshakingLocked(c *Conn) {
+	if key, ok := l.handshaking[c]; ok {
+		delete(l.handshaking, c)
+		if l.initialConns[key] == c {
+			delete(l.initialConns, key)
+		}
+	}
+}
+
+// initialConn returns the inbound conn created by a client Initial packet
+// with the given destination connection ID, if it is still in its handshake.
+func (l *Listener) initialConn(dstConnID []byte) *Conn {
+	l.connsMu.Lock()
+	defer l.connsMu.Unlock()
+	return l.initialConns[string(dstConnID)]
+}
+
+// handshakesAtLimit reports whether the number of inbound connections
+// in the handshaking state has reached Config.MaxHandshakes.
+func (l *Listener) handshakesAtLimit() bool {
+	l.connsMu.Lock()
+	defer l.connsMu.Unlock()
+	return l.handshakesAtLimitLocked()
+}
+
+func (l *Listener) handshakesAtLimitLocked() bool {
+	return l.config.MaxHandshakes > 0 && len(l.handshaking) >= l.config.MaxHandshakes
 }
 
 // connDrained is called by a conn when it leaves the draining state,
@@ -182,104 +510,208 @@ func (l *Listener) connDrained(c *Conn) {
 	for i := range c.connIDState.remote {
 		tokens = append(tokens, c.connIDState.remote[i].resetToken)
 	}
-	l.connsMap.updateConnIDs(func(conns *connsMap) {
-		for _, cid := range cids {
-			conns.retireConnID(c, cid)
-		}
-		for _, token := range tokens {
-			conns.retireResetToken(c, token)
-		}
-	})
+	tomb := &connTombstone{
+		cids:    cids,
+		expires: l.timeNow().Add(connTombstoneDuration),
+	}
+	if errors.Is(c.lifetime.finalErr, errNoPeerResponse) {
+		// The peer never responded to our CONNECTION_CLOSE,
+		// and may still be sending packets.
+		tomb.datagram = c.lifetime.connCloseDatagram
+		tomb.lastSent = c.lifetime.connCloseSentTime
+		tomb.sendDelay = c.lifetime.connCloseDelay
+	}
+	for _, token := range tokens {
+		l.connsMap.retireResetToken(c, token)
+	}
+	l.connsMap.addTombstone(c, tomb)
+	select {
+	case <-c.lifetime.readyc:
+	default:
+		l.stats.handshakesFailed.Add(1)
+	}
 	l.connsMu.Lock()
 	defer l.connsMu.Unlock()
+	l.stats.closedPackets.addAll(&c.counters.packets)
+	l.stats.closedFrames.addAll(&c.counters.frames)
 	delete(l.conns, c)
+	l.removeUnacceptedLocked(c)
+	l.removeHandshakingLocked(c)
 	if l.closing && len(l.conns) == 0 {
 		l.udpConn.Close()
 	}
 }
 
+// listenBatchSize is the maximum number of datagrams the listen loop reads at once.
+const listenBatchSize = 8
+
 func (l *Listener) listen() {
 	defer close(l.closec)
+	ms := make([]*datagram, listenBatchSize)
+	if l.batch == nil {
+		ms = ms[:1]
+	}
 	for {
-		m := newDatagram()
-		// TODO: Read and process the ECN (explicit congestion notification) field.
-		// https://tools.ietf.org/html/draft-ietf-quic-transport-32#section-13.4
-		n, _, _, addr, err := l.udpConn.ReadMsgUDPAddrPort(m.b, nil)
+		for i := range ms {
+			if ms[i] == nil {
+				ms[i] = l.dgrams.get()
+			}
+		}
+		n, err := l.readDatagrams(ms)
 		if err != nil {
 			// The user has probably closed the listener.
 			// We currently don't surface errors from other causes;
 			// we could check to see if the listener has been closed and
 			// record the unexpected error if it has not.
+			for _, m := range ms {
+				m.recycle()
+			}
 			return
 		}
-		if n == 0 {
-			continue
+		for i := 0; i < n; i++ {
+			m := ms[i]
+			ms[i] = nil
+			l.receiveDatagram(m)
 		}
-		if l.connsMap.updateNeeded.Load() {
-			l.connsMap.applyUpdates()
+	}
+}
+
+// readDatagrams reads one or more datagrams into ms,
+// and returns the number of datagrams read.
+//
+// When the Listener's socket is a *net.UDPConn, datagrams are read in batches
+// and their control messages are recorded in each datagram.
+// Otherwise, a single datagram is read.
+func (l *Listener) readDatagrams(ms []*datagram) (int, error) {
+	if l.batch != nil {
+		return l.batch.read(ms)
+	}
+	m := ms[0]
+	n, _, _, addr, err := l.udpConn.ReadMsgUDPAddrPort(m.b, nil)
+	if err != nil {
+		return 0, err
+	}
+	m.b = m.b[:n]
+	m.addr = addr
+	return 1, nil
+}
+
+// receiveDatagram handles a datagram read by the listen loop.
+func (l *Listener) receiveDatagram(m *datagram) {
+	if len(m.b) == 0 {
+		m.recycle()
+		return
+	}
+	// TODO: Process the ECN (explicit congestion notification) field,
+	// recorded in m.ecn where the platform provides it.
+	// https://www.rfc-editor.org/rfc/rfc9000#section-13.4
+	l.stats.datagramsReceived.Add(1)
+	if !l.config.sourceAllowed(m.addr.Addr()) {
+		l.stats.datagramsDenied.Add(1)
+		m.recycle()
+		return
+	}
+	if l.config.DatagramFilter != nil {
+		switch l.config.DatagramFilter(m.addr, m.b) {
+		case DatagramAccept:
+		case DatagramMark:
+			l.stats.datagramsMarked.Add(1)
+			m.marked = true
+		default: // DatagramDrop
+			l.stats.datagramsFiltered.Add(1)
+			m.recycle()
+			return
 		}
-		m.addr = addr
-		m.b = m.b[:n]
-		l.handleDatagram(m)
 	}
+	if l.config.CaptureDatagram != nil {
+		l.captureDatagram(false, m.b, m.addr)
+	}
+	l.handleDatagram(m)
 }
 
 func (l *Listener) handleDatagram(m *datagram) {
+	if l.config.NonQUICDatagram != nil && !isQUICDatagram(m.b) {
+		l.handleNonQUICDatagram(m)
+		return
+	}
 	dstConnID, ok := dstConnIDForDatagram(m.b)
 	if !ok {
-		m.recycle()
+		l.handleNonQUICDatagram(m)
 		return
 	}
-	c := l.connsMap.byConnID[string(dstConnID)]
+	c := l.connsMap.connForID(dstConnID)
 	if c == nil {
-		// TODO: Move this branch into a separate goroutine to avoid blocking
-		// the listener while processing packets.
-		l.handleUnknownDestinationDatagram(m)
+		if tomb := l.connsMap.tombstone(l.timeNow(), dstConnID); tomb != nil {
+			l.handleTombstoneDatagram(tomb, m)
+			return
+		}
+		l.handleUnknownDestinationDatagram(dstConnID, m)
 		return
 	}
 
-	// TODO: This can block the listener while waiting for the conn to accept the dgram.
-	// Think about buffering between the receive loop and the conn.
-	c.sendMsg(m)
+	c.queueDatagram(m)
 }
 
-func (l *Listener) handleUnknownDestinationDatagram(m *datagram) {
-	defer func() {
-		if m != nil {
-			m.recycle()
-		}
-	}()
+// handleNonQUICDatagram handles a datagram which does not contain a QUIC packet.
+func (l *Listener) handleNonQUICDatagram(m *datagram) {
+	if l.config.NonQUICDatagram != nil {
+		l.stats.datagramsNotQUIC.Add(1)
+		l.config.NonQUICDatagram(l, m.b, m.addr)
+	}
+	m.recycle()
+}
+
+// handleUnknownDestinationDatagram handles a datagram addressed to an unknown connection.
+// Stateless resets for known conns are handled on the listen loop,
+// and all other datagrams are passed to the unknownDestPool.
+func (l *Listener) handleUnknownDestinationDatagram(dstConnID []byte, m *datagram) {
 	const minimumValidPacketSize = 21
 	if len(m.b) < minimumValidPacketSize {
+		m.recycle()
 		return
 	}
 	// Check to see if this is a stateless reset.
 	var token statelessResetToken
 	copy(token[:], m.b[len(m.b)-len(token):])
-	if c := l.connsMap.byResetToken[token]; c != nil {
+	if c := l.connsMap.connForResetToken(token); c != nil {
+		size := len(m.b)
 		c.sendMsg(func(now time.Time, c *Conn) {
-			c.handleStatelessReset(token)
+			c.handleStatelessReset(now, token, size)
 		})
+		m.recycle()
 		return
 	}
+	l.unknownDest.dispatch(dstConnID, m)
+}
+
+// handleUnknownDestinationDatagramOffLoop handles a datagram addressed to an unknown connection
+// which is not a stateless reset.
+//
+// This is called by an unknownDestPool worker.
+func (l *Listener) handleUnknownDestinationDatagramOffLoop(m *datagram) {
+	defer func() {
+		if m != nil {
+			m.recycle()
+		}
+	}()
 	// If this is a 1-RTT packet, there's nothing productive we can do with it.
 	// Send a stateless reset if possible.
 	if !isLongHeader(m.b[0]) {
-		l.maybeSendStatelessReset(m.b, m.addr)
+		l.maybeSendStatelessReset(m)
 		return
 	}
 	p, ok := parseGenericLongHeaderPacket(m.b)
 	if !ok || len(m.b) < paddedInitialDatagramSize {
 		return
 	}
-	switch p.version {
-	case quicVersion1:
-	case 0:
+	if p.version == 0 {
 		// Version Negotiation for an unknown connection.
 		return
-	default:
-		// Unknown version.
-		l.sendVersionNegotiation(p, m.addr)
+	}
+	version := l.config.acceptVersion(p.version)
+	if version == nil {
+		// Unknown or disabled version.
+		l.sendVersionNegotiation(p, m)
 		return
 	}
 	if getPacketType(m.b) != packetTypeInitial {
@@ -290,37 +722,116 @@ func (l *Listener) handleUnknownDestinationDatagram(m *datagram) {
 		// https://www.rfc-editor.org/rfc/rfc9000#section-10.3-16
 		return
 	}
-	var now time.Time
-	if l.testHooks != nil {
-		now = l.testHooks.timeNow()
-	} else {
-		now = time.Now()
+	if c := l.initialConn(p.dstConnID); c != nil {
+		// Another Initial for a conn the listen loop does not know about yet.
+		c.queueDatagram(m)
+		m = nil // don't recycle, queueDatagram takes ownership
+		return
+	}
+	now := l.timeNow()
+	// An Initial carrying a valid token from one of our Retry packets comes from
+	// a client whose address has been validated. The client was charged against
+	// the handshake rate limit when we sent the Retry, and isn't charged again.
+	retried := l.hasValidRetryToken(now, p, m.addr)
+	requireAddressValidation := l.config.RequireAddressValidation || m.marked || retried
+	if l.handshakeLimit != nil && !retried && !l.handshakeLimit.allow(now, m.addr.Addr()) {
+		l.stats.handshakesRateLimited.Add(1)
+		if !l.takeAcceptAction(limitAction(l.config.HandshakeRateLimitAction), p, m, &requireAddressValidation) {
+			return
+		}
+	}
+	if l.handshakesAtLimit() {
+		l.stats.handshakesLimited.Add(1)
+		if !l.takeAcceptAction(limitAction(l.config.MaxHandshakesAction), p, m, &requireAddressValidation) {
+			return
+		}
+	}
+	if l.config.AcceptFilter != nil {
+		a := l.config.AcceptFilter(&InitialPacket{
+			RemoteAddr: m.addr,
+			Version:    Version(p.version),
+			DstConnID:  p.dstConnID,
+			SrcConnID:  p.srcConnID,
+		})
+		if !l.takeAcceptAction(a, p, m, &requireAddressValidation) {
+			return
+		}
 	}
 	var originalDstConnID, retrySrcConnID []byte
-	if l.config.RequireAddressValidation {
+	if requireAddressValidation {
 		var ok bool
 		retrySrcConnID = p.dstConnID
-		originalDstConnID, ok = l.validateInitialAddress(now, p, m.addr)
+		originalDstConnID, ok = l.validateInitialAddress(now, p, m)
 		if !ok {
 			return
 		}
 	} else {
 		originalDstConnID = p.dstConnID
 	}
-	var err error
-	c, err := l.newConn(now, serverSide, originalDstConnID, retrySrcConnID, m.addr)
-	if err != nil {
-		// The accept queue is probably full.
-		// We could send a CONNECTION_CLOSE to the peer to reject the connection.
-		// Currently, we just drop the datagram.
+	var localAddr netip.AddrPort
+	if m.localAddr.IsValid() {
+		localAddr = netip.AddrPortFrom(m.localAddr, l.LocalAddr().Port())
+	}
+	c, err := l.newConn(now, serverSide, version, originalDstConnID, retrySrcConnID, m.addr, localAddr)
+	switch {
+	case err == errAcceptQueueFull || err == errListenerClosed:
+		// "A server that chooses not to accept a connection [...]
+		// MAY send a CONNECTION_CLOSE frame with a CONNECTION_REFUSED error."
 		// https://www.rfc-editor.org/rfc/rfc9000.html#section-5.2.2-5
+		l.sendConnectionClose(p, m, errConnectionRefused)
+		return
+	case err == errHandshakesAtLimit:
+		// Another worker started a handshake after we checked the limit.
+		l.stats.handshakesLimited.Add(1)
+		if l.takeAcceptAction(limitAction(l.config.MaxHandshakesAction), p, m, &requireAddressValidation) {
+			// AcceptRetry: send the client a Retry.
+			l.validateInitialAddress(now, p, m)
+		}
+		return
+	case err != nil:
+		// Tell the client we couldn't create the connection,
+		// rather than leaving it to retransmit its Initial until it times out.
+		l.sendConnectionClose(p, m, errInternal)
 		return
 	}
-	c.sendMsg(m)
-	m = nil // don't recycle, sendMsg takes ownership
+	c.queueDatagram(m)
+	m = nil // don't recycle, queueDatagram takes ownership
 }
 
-func (l *Listener) maybeSendStatelessReset(b []byte, addr netip.AddrPort) {
+// takeAcceptAction takes the action a with the Initial packet p in datagram m.
+// It sets *requireAddressValidation for AcceptRetry,
+// and reports whether the packet may go on to create a connection.
+func (l *Listener) takeAcceptAction(a AcceptAction, p genericLongPacket, m *datagram, requireAddressValidation *bool) bool {
+	switch a {
+	case AcceptConn:
+		return true
+	case AcceptRetry:
+		*requireAddressValidation = true
+		return true
+	case AcceptRefuse:
+		l.sendConnectionClose(p, m, errConnectionRefused)
+		return false
+	default: // AcceptDrop
+		return false
+	}
+}
+
+// limitAction returns the action to take with an Initial packet which
+// exceeds a handshake limit configured with the action a.
+// Actions other than AcceptRetry and AcceptRefuse drop the packet.
+func limitAction(a AcceptAction) AcceptAction {
+	switch a {
+	case AcceptRetry, AcceptRefuse:
+		return a
+	default:
+		return AcceptDrop
+	}
+}
+
+// maybeSendStatelessReset sends a stateless reset in response to
+// the datagram m, which was not for any known conn.
+func (l *Listener) maybeSendStatelessReset(m *datagram) {
+	b := m.b
 	if !l.resetGen.canReset {
 		// Config.StatelessResetKey isn't set, so we don't send stateless resets.
 		return
@@ -361,22 +872,25 @@ func (l *Listener) maybeSendStatelessReset(b []byte, addr netip.AddrPort) {
 	b[0] &^= headerFormLong // clear long header bit
 	b[0] |= fixedBit        // set fixed bit
 	copy(b[len(b)-statelessResetTokenLen:], token[:])
-	l.sendDatagram(b, addr)
+	l.stats.statelessResetsSent.Add(1)
+	l.sendDatagram(b, m.localAddr, m.addr)
 }
 
-func (l *Listener) sendVersionNegotiation(p genericLongPacket, addr netip.AddrPort) {
-	m := newDatagram()
-	m.b = appendVersionNegotiation(m.b[:0], p.srcConnID, p.dstConnID, quicVersion1)
-	l.sendDatagram(m.b, addr)
+func (l *Listener) sendVersionNegotiation(p genericLongPacket, in *datagram) {
+	m := l.dgrams.get()
+	m.b = appendVersionNegotiation(m.b[:0], p.srcConnID, p.dstConnID, l.config.versionNumbers()...)
+	l.stats.versionNegotiationsSent.Add(1)
+	l.sendDatagram(m.b, in.localAddr, in.addr)
 	m.recycle()
 }
 
-func (l *Listener) sendConnectionClose(in genericLongPacket, addr netip.AddrPort, code transportError) {
-	keys := initialKeys(in.dstConnID, serverSide)
+func (l *Listener) sendConnectionClose(in genericLongPacket, m *datagram, code transportError) {
+	vp := paramsForVersion(in.version)
+	keys := initialKeys(vp, in.dstConnID, serverSide)
 	var w packetWriter
 	p := longPacket{
 		ptype:     packetTypeInitial,
-		version:   quicVersion1,
+		version:   vp.number,
 		num:       0,
 		dstConnID: in.srcConnID,
 		srcConnID: in.dstConnID,
@@ -390,60 +904,51 @@ func (l *Listener) sendConnectionClose(in genericLongPacket, addr netip.AddrPort
 	if len(buf) == 0 {
 		return
 	}
-	l.sendDatagram(buf, addr)
-}
-
-func (l *Listener) sendDatagram(p []byte, addr netip.AddrPort) error {
-	_, err := l.udpConn.WriteToUDPAddrPort(p, addr)
-	return err
-}
-
-// A connsMap is a listener's mapping of conn ids and reset tokens to conns.
-type connsMap struct {
-	byConnID     map[string]*Conn
-	byResetToken map[statelessResetToken]*Conn
-
-	updateMu     sync.Mutex
-	updateNeeded atomic.Bool
-	updates      []func(*connsMap)
-}
-
-func (m *connsMap) init() {
-	m.byConnID = map[string]*Conn{}
-	m.byResetToken = map[statelessResetToken]*Conn{}
-}
-
-func (m *connsMap) addConnID(c *Conn, cid []byte) {
-	m.byConnID[string(cid)] = c
+	l.sendDatagram(buf, m.localAddr, m.addr)
 }
 
-func (m *connsMap) retireConnID(c *Conn, cid []byte) {
-	delete(m.byConnID, string(cid))
-}
-
-func (m *connsMap) addResetToken(c *Conn, token statelessResetToken) {
-	m.byResetToken[token] = c
+func (l *Listener) timeNow() time.Time {
+	if l.testHooks != nil {
+		return l.testHooks.timeNow()
+	}
+	return l.config.clock().Now()
 }
 
-func (m *connsMap) retireResetToken(c *Conn, token statelessResetToken) {
-	delete(m.byResetToken, token)
+// sendDatagram sends a datagram containing p to addr,
+// from the local address src if it is valid and the platform supports it.
+func (l *Listener) sendDatagram(p []byte, src netip.Addr, addr netip.AddrPort) error {
+	bufs := [1][]byte{p}
+	return l.sendDatagrams(bufs[:], src, addr)
 }
 
-func (m *connsMap) updateConnIDs(f func(*connsMap)) {
-	m.updateMu.Lock()
-	defer m.updateMu.Unlock()
-	m.updates = append(m.updates, f)
-	m.updateNeeded.Store(true)
+// sendDatagrams sends datagrams containing each of bufs to addr,
+// from the local address src if it is valid and the platform supports it.
+//
+// When the Listener's socket is a *net.UDPConn,
+// the datagrams are written in batches.
+func (l *Listener) sendDatagrams(bufs [][]byte, src netip.Addr, addr netip.AddrPort) error {
+	var n int
+	var err error
+	if l.batch != nil {
+		n, err = l.batch.write(bufs, src, addr)
+	} else {
+		for _, p := range bufs {
+			if _, err = l.udpConn.WriteToUDPAddrPort(p, addr); err != nil {
+				break
+			}
+			n++
+		}
+	}
+	for _, p := range bufs[:n] {
+		l.datagramSent(p, addr)
+	}
+	return err
 }
 
-// applyConnIDUpdates is called by the datagram receive loop to update its connection ID map.
-func (m *connsMap) applyUpdates() {
-	m.updateMu.Lock()
-	defer m.updateMu.Unlock()
-	for _, f := range m.updates {
-		f(m)
+// datagramSent records a datagram sent to addr.
+func (l *Listener) datagramSent(p []byte, addr netip.AddrPort) {
+	l.stats.datagramsSent.Add(1)
+	if l.config.CaptureDatagram != nil {
+		l.captureDatagram(true, p, addr)
 	}
-	clear(m.updates)
-	m.updates = m.updates[:0]
-	m.updateNeeded.Store(false)
 }
@@ -0,0 +1,245 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21 && (aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris || zos)
+
+package quic
+
+import (
+	"io"
+	"net"
+	"net/netip"
+	"sync"
+
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+	"golang.org/x/sys/unix"
+)
+
+// A batchConn reads and writes datagrams on a *net.UDPConn
+// using the golang.org/x/net/ipv4 or ipv6 package for the socket's address family.
+//
+// These packages read and write several datagrams in a single system call
+// where the platform supports it (recvmmsg and sendmmsg on Linux),
+// and provide each datagram's control messages:
+// The local address the datagram was sent to,
+// and the ECN bits of the IPv4 type-of-service or IPv6 traffic class.
+// Datagrams are sent from a chosen local address, where the platform supports it.
+type batchConn struct {
+	conn *net.UDPConn
+	v4   *ipv4.PacketConn // set for IPv4 sockets
+	v6   *ipv6.PacketConn // set for IPv6 sockets
+
+	// Only accessed by the listen loop.
+	// ipv4.Message and ipv6.Message are the same type.
+	rms  []ipv4.Message
+	bufs [][]byte
+	cm4  ipv4.ControlMessage
+	cm6  ipv6.ControlMessage
+
+	wmu   sync.Mutex
+	wms   [sendBatchSize]ipv4.Message
+	wbufs [sendBatchSize][]byte
+	waddr net.UDPAddr
+	wip   [16]byte
+	wsrc  [16]byte
+	wcm4  ipv4.ControlMessage
+	wcm6  ipv6.ControlMessage
+	woob  []byte
+}
+
+// newBatchConn returns a batchConn for u,
+// or nil if u is not a *net.UDPConn.
+func newBatchConn(u udpConn) *batchConn {
+	conn, ok := u.(*net.UDPConn)
+	if !ok {
+		return nil
+	}
+	laddr, ok := conn.LocalAddr().(*net.UDPAddr)
+	if !ok {
+		return nil
+	}
+	b := &batchConn{conn: conn}
+	// Not all platforms support all control messages,
+	// so enabling them is best-effort.
+	var oobSize int
+	if laddr.IP.To4() != nil {
+		flags := ipv4.FlagDst | ipv4.FlagTOS
+		b.v4 = ipv4.NewPacketConn(conn)
+		b.v4.SetControlMessage(flags, true)
+		oobSize = len(ipv4.NewControlMessage(flags))
+	} else {
+		flags := ipv6.FlagDst | ipv6.FlagTrafficClass
+		b.v6 = ipv6.NewPacketConn(conn)
+		b.v6.SetControlMessage(flags, true)
+		oobSize = len(ipv6.NewControlMessage(flags))
+	}
+	b.rms = make([]ipv4.Message, listenBatchSize)
+	b.bufs = make([][]byte, listenBatchSize)
+	for i := range b.rms {
+		b.rms[i].Buffers = b.bufs[i : i+1]
+		b.rms[i].OOB = make([]byte, oobSize)
+	}
+	for i := range b.wms {
+		b.wms[i].Buffers = b.wbufs[i : i+1]
+		b.wms[i].Addr = &b.waddr
+	}
+	return b
+}
+
+// read reads one or more datagrams into ms,
+// and returns the number of datagrams read.
+//
+// This is called by the listen loop.
+func (b *batchConn) read(ms []*datagram) (int, error) {
+	rms := b.rms[:len(ms)]
+	for i, m := range ms {
+		b.bufs[i] = m.b
+	}
+	var n int
+	var err error
+	if b.v4 != nil {
+		n, err = b.v4.ReadBatch(rms, 0)
+	} else {
+		n, err = b.v6.ReadBatch(rms, 0)
+	}
+	for i := range rms {
+		b.bufs[i] = nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	for i := 0; i < n; i++ {
+		rm := &rms[i]
+		m := ms[i]
+		m.b = m.b[:rm.N]
+		if a, ok := rm.Addr.(*net.UDPAddr); ok {
+			m.addr = a.AddrPort()
+		} else {
+			// Drop datagrams from addresses we can't reply to.
+			m.b = m.b[:0]
+		}
+		if rm.Flags&unix.MSG_TRUNC != 0 {
+			// Drop datagrams larger than the buffer.
+			// A truncated datagram can't be authenticated, and would be
+			// mistaken for a datagram containing only the part we read.
+			m.b = m.b[:0]
+		}
+		b.parseControlMessage(m, rm.OOB[:rm.NN])
+		rm.Addr = nil
+	}
+	return n, nil
+}
+
+// parseControlMessage records the information in a datagram's control message.
+func (b *batchConn) parseControlMessage(m *datagram, oob []byte) {
+	if len(oob) == 0 {
+		return
+	}
+	// Parse reuses the Dst slice when it is large enough,
+	// so clear rather than truncate it.
+	var dst net.IP
+	if b.v4 != nil {
+		cm := &b.cm4
+		clear(cm.Dst)
+		cm.TOS = 0
+		if cm.Parse(oob) != nil {
+			return
+		}
+		dst = cm.Dst
+		m.ecn = ecnBits(cm.TOS & ecnMask)
+	} else {
+		cm := &b.cm6
+		clear(cm.Dst)
+		cm.TrafficClass = 0
+		if cm.Parse(oob) != nil {
+			return
+		}
+		dst = cm.Dst
+		m.ecn = ecnBits(cm.TrafficClass & ecnMask)
+	}
+	if a, ok := netip.AddrFromSlice(dst); ok && !a.IsUnspecified() {
+		m.localAddr = a
+	}
+}
+
+// write sends datagrams containing each of bufs to addr,
+// from the local address src if it is valid.
+// It returns the number of datagrams sent.
+func (b *batchConn) write(bufs [][]byte, src netip.Addr, addr netip.AddrPort) (int, error) {
+	if b.v6 != nil && addr.Addr().Unmap().Is4() {
+		// The ipv6 package encodes IPv4 and IPv4-mapped addresses as
+		// AF_INET socket addresses, which not all platforms accept
+		// on a dual-stack IPv6 socket.
+		for i, p := range bufs {
+			if _, err := b.conn.WriteToUDPAddrPort(p, addr); err != nil {
+				return i, err
+			}
+		}
+		return len(bufs), nil
+	}
+	b.wmu.Lock()
+	defer b.wmu.Unlock()
+	ip := addr.Addr()
+	if ip.Is4() {
+		a := ip.As4()
+		b.waddr.IP = b.wip[:copy(b.wip[:], a[:])]
+	} else {
+		a := ip.As16()
+		b.waddr.IP = b.wip[:copy(b.wip[:], a[:])]
+	}
+	b.waddr.Port = int(addr.Port())
+	b.waddr.Zone = ip.Zone()
+	oob := b.appendSourceAddr(b.woob[:0], src)
+	b.woob = oob
+	sent := 0
+	for sent < len(bufs) {
+		wms := b.wms[:min(len(bufs)-sent, len(b.wms))]
+		for i := range wms {
+			b.wbufs[i] = bufs[sent+i]
+			wms[i].OOB = oob
+		}
+		var n int
+		var err error
+		if b.v4 != nil {
+			n, err = b.v4.WriteBatch(wms, 0)
+		} else {
+			n, err = b.v6.WriteBatch(wms, 0)
+		}
+		clear(b.wbufs[:])
+		if err != nil {
+			return sent, err
+		}
+		if n == 0 {
+			return sent, io.ErrShortWrite
+		}
+		sent += n
+	}
+	return sent, nil
+}
+
+// appendSourceAddr appends a control message setting the source address
+// of a datagram to src, if src is valid.
+func (b *batchConn) appendSourceAddr(oob []byte, src netip.Addr) []byte {
+	if !src.IsValid() || src.IsLinkLocalUnicast() {
+		// A link-local source address requires an interface index,
+		// which we don't record, so let the kernel choose.
+		return oob
+	}
+	src = src.Unmap()
+	if b.v4 != nil {
+		if !src.Is4() {
+			return oob
+		}
+		a := src.As4()
+		b.wcm4.Src = b.wsrc[:copy(b.wsrc[:], a[:])]
+		return b.wcm4.AppendMarshal(oob)
+	}
+	if !src.Is6() {
+		return oob
+	}
+	a := src.As16()
+	b.wcm6.Src = b.wsrc[:copy(b.wsrc[:], a[:])]
+	return b.wcm6.AppendMarshal(oob)
+}
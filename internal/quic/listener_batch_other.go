@@ -0,0 +1,28 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21 && !(aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris || zos)
+
+package quic
+
+import (
+	"errors"
+	"net/netip"
+)
+
+// A batchConn is not supported on this platform.
+// The Listener reads and writes one datagram at a time.
+type batchConn struct{}
+
+func newBatchConn(u udpConn) *batchConn {
+	return nil
+}
+
+func (b *batchConn) read(ms []*datagram) (int, error) {
+	return 0, errors.New("batch reads are not supported on this platform")
+}
+
+func (b *batchConn) write(bufs [][]byte, src netip.Addr, addr netip.AddrPort) (int, error) {
+	return 0, errors.New("batch writes are not supported on this platform")
+}
@@ -0,0 +1,178 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21 && linux
+
+package quic
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"testing"
+
+	"golang.org/x/net/ipv4"
+)
+
+func TestBatchConnReadWrite(t *testing.T) {
+	u, err := net.ListenUDP("udp", net.UDPAddrFromAddrPort(netip.MustParseAddrPort("127.0.0.1:0")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer u.Close()
+	b := newBatchConn(u)
+	if b == nil {
+		t.Fatalf("newBatchConn(*net.UDPConn) = nil")
+	}
+	peer, err := net.ListenUDP("udp", net.UDPAddrFromAddrPort(netip.MustParseAddrPort("127.0.0.1:0")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer peer.Close()
+	peerAddr := peer.LocalAddr().(*net.UDPAddr).AddrPort()
+	laddr := u.LocalAddr().(*net.UDPAddr)
+
+	const count = 3
+	for i := 0; i < count; i++ {
+		if _, err := peer.WriteToUDP([]byte(fmt.Sprint("datagram ", i)), laddr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var pool datagramPool
+	pool.init(1500, 0)
+	var got []*datagram
+	for len(got) < count {
+		ms := make([]*datagram, listenBatchSize)
+		for i := range ms {
+			ms[i] = pool.get()
+		}
+		n, err := b.read(ms)
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		got = append(got, ms[:n]...)
+	}
+	for i, m := range got {
+		if want := fmt.Sprint("datagram ", i); string(m.b) != want {
+			t.Errorf("datagram %v = %q, want %q", i, m.b, want)
+		}
+		if m.addr != peerAddr {
+			t.Errorf("datagram %v: addr = %v, want %v", i, m.addr, peerAddr)
+		}
+		if want := laddr.AddrPort().Addr(); m.localAddr != want {
+			t.Errorf("datagram %v: localAddr = %v, want %v", i, m.localAddr, want)
+		}
+	}
+
+	want := [][]byte{[]byte("reply 0"), []byte("reply 1"), []byte("reply 2")}
+	if n, err := b.write(want, laddr.AddrPort().Addr(), peerAddr); n != len(want) || err != nil {
+		t.Fatalf("write: %v, %v; want %v, nil", n, err, len(want))
+	}
+	for _, w := range want {
+		buf := make([]byte, 1500)
+		n, from, err := peer.ReadFromUDPAddrPort(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf[:n], w) || from != laddr.AddrPort() {
+			t.Errorf("peer read %q from %v, want %q from %v", buf[:n], from, w, laddr.AddrPort())
+		}
+	}
+}
+
+func TestBatchConnUnspecifiedAddr(t *testing.T) {
+	u, err := net.ListenUDP("udp4", net.UDPAddrFromAddrPort(netip.MustParseAddrPort("0.0.0.0:0")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer u.Close()
+	b := newBatchConn(u)
+	if b == nil {
+		t.Fatalf("newBatchConn(*net.UDPConn) = nil")
+	}
+	peer, err := net.ListenUDP("udp4", net.UDPAddrFromAddrPort(netip.MustParseAddrPort("127.0.0.1:0")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer peer.Close()
+	if err := ipv4.NewPacketConn(peer).SetTOS(int(ecnECT1)); err != nil {
+		t.Skipf("SetTOS: %v", err)
+	}
+	peerAddr := peer.LocalAddr().(*net.UDPAddr).AddrPort()
+	loopback := netip.MustParseAddr("127.0.0.1")
+	dst := netip.AddrPortFrom(loopback, u.LocalAddr().(*net.UDPAddr).AddrPort().Port())
+
+	var pool datagramPool
+	pool.init(64, 0)
+	readOne := func() *datagram {
+		t.Helper()
+		ms := []*datagram{pool.get()}
+		if n, err := b.read(ms); n != 1 || err != nil {
+			t.Fatalf("read: %v, %v; want 1, nil", n, err)
+		}
+		return ms[0]
+	}
+
+	// The datagram is delivered to the address the peer sent it to,
+	// which is recorded along with the datagram's ECN bits.
+	if _, err := peer.WriteToUDPAddrPort([]byte("datagram"), dst); err != nil {
+		t.Fatal(err)
+	}
+	m := readOne()
+	if string(m.b) != "datagram" {
+		t.Errorf("read %q, want %q", m.b, "datagram")
+	}
+	if m.localAddr != loopback {
+		t.Errorf("localAddr = %v, want %v", m.localAddr, loopback)
+	}
+	if m.ecn != ecnECT1 {
+		t.Errorf("ecn = %v, want %v", m.ecn, ecnECT1)
+	}
+
+	// A datagram larger than the buffer is dropped.
+	if _, err := peer.WriteToUDPAddrPort(make([]byte, 100), dst); err != nil {
+		t.Fatal(err)
+	}
+	if m := readOne(); len(m.b) != 0 {
+		t.Errorf("read truncated datagram of %v bytes, want it dropped", len(m.b))
+	}
+
+	// Replies are sent from the local address the datagram was received on.
+	if _, err := b.write([][]byte{[]byte("reply")}, m.localAddr, peerAddr); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	buf := make([]byte, 1500)
+	n, from, err := peer.ReadFromUDPAddrPort(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf[:n]) != "reply" || from != dst {
+		t.Errorf("peer read %q from %v, want %q from %v", buf[:n], from, "reply", dst)
+	}
+}
+
+func TestConnLocalAddrUnspecifiedListener(t *testing.T) {
+	ctx := context.Background()
+	srvl, err := Listen("udp4", "0.0.0.0:0", &Config{
+		TLSConfig: newTestTLSConfig(serverSide),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srvl.Close(ctx)
+	clil := newLocalListener(t, clientSide, &Config{})
+	want := netip.AddrPortFrom(netip.MustParseAddr("127.0.0.1"), srvl.LocalAddr().Port())
+	if _, err := clil.Dial(ctx, "udp", want.String()); err != nil {
+		t.Fatal(err)
+	}
+	srv, err := srvl.Accept(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := srv.LocalAddr(); got != want {
+		t.Errorf("LocalAddr() = %v, want %v (listener address %v)", got, want, srvl.LocalAddr())
+	}
+}
@@ -0,0 +1,28 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21 && (darwin || freebsd)
+
+package quic
+
+import (
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// setDontFragment sets the Don't Fragment bit on datagrams sent from c.
+func setDontFragment(network string, c syscall.RawConn) error {
+	var serr error
+	if err := c.Control(func(fd uintptr) {
+		if network == "udp6" {
+			serr = unix.SetsockoptInt(int(fd), unix.IPPROTO_IPV6, unix.IPV6_DONTFRAG, 1)
+		} else {
+			serr = unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_DONTFRAG, 1)
+		}
+	}); err != nil {
+		return err
+	}
+	return serr
+}
@@ -0,0 +1,37 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21 && linux
+
+package quic
+
+import (
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// setDontFragment sets the Don't Fragment bit on datagrams sent from c,
+// and disables path MTU discovery by the kernel:
+// IP_PMTUDISC_PROBE sets DF, but ignores the kernel's path MTU estimate,
+// leaving PMTU discovery to the QUIC implementation.
+func setDontFragment(network string, c syscall.RawConn) error {
+	var serr error
+	if err := c.Control(func(fd uintptr) {
+		// An IPv6 socket may also carry IPv4 traffic, so set both options.
+		if network == "udp6" {
+			serr = unix.SetsockoptInt(int(fd), unix.IPPROTO_IPV6, unix.IPV6_MTU_DISCOVER, unix.IPV6_PMTUDISC_PROBE)
+			if serr != nil {
+				return
+			}
+		}
+		err := unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_MTU_DISCOVER, unix.IP_PMTUDISC_PROBE)
+		if network != "udp6" {
+			serr = err
+		}
+	}); err != nil {
+		return err
+	}
+	return serr
+}
@@ -0,0 +1,16 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21 && !(darwin || freebsd || linux)
+
+package quic
+
+import (
+	"errors"
+	"syscall"
+)
+
+func setDontFragment(network string, c syscall.RawConn) error {
+	return errors.New("disabling fragmentation is not supported on this platform")
+}
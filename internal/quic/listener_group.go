@@ -0,0 +1,146 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// A ListenerGroup is a set of Listeners with a combined Accept queue.
+type ListenerGroup struct {
+	listeners   []*Listener
+	acceptQueue queue[*Conn]
+	ctx         context.Context // canceled when the group is closed
+	cancel      context.CancelFunc
+	wg          sync.WaitGroup // acceptConns goroutines
+}
+
+// ListenAddrs listens on each of the given local network addresses,
+// which may include addresses of different families
+// (for example, "0.0.0.0:443" and "[::]:443" with V6Only set).
+// Connections accepted by any of the Listeners are returned by
+// the ListenerGroup's Accept method.
+//
+// All the Listeners use the same configuration, which must be non-nil.
+// The configuration is not copied, so if StatelessResetKey or RetryKey
+// are set, the Listeners accept each other's reset and retry tokens.
+func ListenAddrs(network string, addresses []string, config *Config) (*ListenerGroup, error) {
+	if len(addresses) == 0 {
+		return nil, errors.New("ListenAddrs requires at least one address")
+	}
+	var listeners []*Listener
+	for _, address := range addresses {
+		l, err := Listen(network, address, config)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close(context.Background())
+			}
+			return nil, err
+		}
+		listeners = append(listeners, l)
+	}
+	return newListenerGroup(listeners), nil
+}
+
+func newListenerGroup(listeners []*Listener) *ListenerGroup {
+	ctx, cancel := context.WithCancel(context.Background())
+	g := &ListenerGroup{
+		listeners:   listeners,
+		acceptQueue: newQueue[*Conn](),
+		ctx:         ctx,
+		cancel:      cancel,
+	}
+	for _, l := range listeners {
+		g.wg.Add(1)
+		go g.acceptConns(l)
+	}
+	return g
+}
+
+// acceptConns moves connections accepted by l to the group's Accept queue.
+func (g *ListenerGroup) acceptConns(l *Listener) {
+	defer g.wg.Done()
+	for {
+		c, err := l.Accept(g.ctx)
+		if err != nil {
+			return
+		}
+		if !g.acceptQueue.put(c) {
+			c.Abort(localTransportError(errNo))
+			return
+		}
+	}
+}
+
+// Listeners returns the Listeners in the group,
+// in the order of the addresses passed to ListenAddrs.
+func (g *ListenerGroup) Listeners() []*Listener {
+	return append([]*Listener(nil), g.listeners...)
+}
+
+// Accept waits for and returns the next connection to any of the group's Listeners.
+func (g *ListenerGroup) Accept(ctx context.Context) (*Conn, error) {
+	return g.acceptQueue.get(ctx, nil)
+}
+
+// Close closes every Listener in the group.
+// It returns the first error returned by Listener.Close.
+func (g *ListenerGroup) Close(ctx context.Context) error {
+	g.stopAccepting()
+	return g.forEach(func(l *Listener) error {
+		return l.Close(ctx)
+	})
+}
+
+// Shutdown gracefully shuts down every Listener in the group.
+// Connections which have not been returned by Accept are closed.
+// It returns the first error returned by Listener.Shutdown.
+func (g *ListenerGroup) Shutdown(ctx context.Context) error {
+	g.stopAccepting()
+	return g.forEach(func(l *Listener) error {
+		return l.Shutdown(ctx)
+	})
+}
+
+// stopAccepting closes the Accept queue,
+// and aborts any connections remaining in it.
+func (g *ListenerGroup) stopAccepting() {
+	g.acceptQueue.close(errListenerClosed)
+	g.cancel()
+	g.wg.Wait()
+	g.acceptQueue.gate.lock()
+	conns := g.acceptQueue.q
+	g.acceptQueue.q = nil
+	g.acceptQueue.unlock()
+	for _, c := range conns {
+		c.Abort(localTransportError(errNo))
+	}
+}
+
+// forEach calls f concurrently for each Listener in the group,
+// and returns the first error.
+func (g *ListenerGroup) forEach(f func(*Listener) error) error {
+	errs := make([]error, len(g.listeners))
+	var wg sync.WaitGroup
+	for i, l := range g.listeners {
+		i, l := i, l
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = f(l)
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
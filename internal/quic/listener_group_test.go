@@ -0,0 +1,64 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestListenAddrs(t *testing.T) {
+	g, err := ListenAddrs("udp", []string{"127.0.0.1:0", "127.0.0.1:0"}, &Config{
+		TLSConfig: newTestTLSConfig(serverSide),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close(canceledContext())
+	ls := g.Listeners()
+	if len(ls) != 2 {
+		t.Fatalf("len(g.Listeners()) = %v, want 2", len(ls))
+	}
+	if ls[0].LocalAddr() == ls[1].LocalAddr() {
+		t.Fatalf("listeners share address %v", ls[0].LocalAddr())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	cl := newLocalListener(t, clientSide, &Config{})
+	for _, l := range ls {
+		if _, err := cl.Dial(ctx, "udp", l.LocalAddr().String()); err != nil {
+			t.Fatalf("Dial: %v", err)
+		}
+		c, err := g.Accept(ctx)
+		if err != nil {
+			t.Fatalf("Accept: %v", err)
+		}
+		if got, want := c.listener, l; got != want {
+			t.Errorf("accepted connection from wrong listener")
+		}
+		c.Abort(nil)
+	}
+
+	if err := g.Close(ctx); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if _, err := g.Accept(ctx); !errors.Is(err, errListenerClosed) {
+		t.Errorf("Accept after Close: %v, want errListenerClosed", err)
+	}
+}
+
+func TestListenAddrsError(t *testing.T) {
+	_, err := ListenAddrs("udp", []string{"127.0.0.1:0", "not an address"}, &Config{
+		TLSConfig: newTestTLSConfig(serverSide),
+	})
+	if err == nil {
+		t.Fatalf("ListenAddrs with invalid address succeeded, want error")
+	}
+}
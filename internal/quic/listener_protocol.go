@@ -0,0 +1,150 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"context"
+	"errors"
+)
+
+// A ProtocolListener accepts the inbound connections of a Listener
+// which negotiate a particular application protocol.
+//
+// ProtocolListeners allow a single Listener (and UDP port) to serve several protocols,
+// such as HTTP/3 ("h3") and DNS over QUIC ("doq"), with a separate handler for each.
+type ProtocolListener struct {
+	l           *Listener
+	proto       string
+	acceptQueue queue[*Conn]
+}
+
+// ListenProtocol returns a ProtocolListener which accepts inbound connections
+// that negotiate the ALPN protocol proto.
+// The Listener's TLSConfig.NextProtos should include proto.
+//
+// Connections which negotiate a protocol with no ProtocolListener
+// are returned by the Listener's Accept method.
+//
+// Connections are routed when the TLS handshake completes,
+// even if Config.HalfRTTData is set.
+//
+// It is an error to register more than one ProtocolListener for a protocol.
+func (l *Listener) ListenProtocol(proto string) (*ProtocolListener, error) {
+	l.connsMu.Lock()
+	defer l.connsMu.Unlock()
+	if l.closing {
+		return nil, errListenerClosed
+	}
+	if _, ok := l.protocols[proto]; ok {
+		return nil, errors.New("quic: ListenProtocol called twice for protocol " + proto)
+	}
+	pl := &ProtocolListener{
+		l:           l,
+		proto:       proto,
+		acceptQueue: newQueue[*Conn](),
+	}
+	if l.protocols == nil {
+		l.protocols = make(map[string]*ProtocolListener)
+	}
+	l.protocols[proto] = pl
+	return pl, nil
+}
+
+// Protocol returns the application protocol of the connections accepted by pl.
+func (pl *ProtocolListener) Protocol() string {
+	return pl.proto
+}
+
+// Listener returns the Listener which pl accepts connections from.
+func (pl *ProtocolListener) Listener() *Listener {
+	return pl.l
+}
+
+// Accept waits for and returns the next connection which negotiates pl's protocol.
+func (pl *ProtocolListener) Accept(ctx context.Context) (*Conn, error) {
+	c, err := pl.acceptQueue.get(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	pl.l.connsMu.Lock()
+	pl.l.removeUnacceptedLocked(c)
+	pl.l.connsMu.Unlock()
+	return c, nil
+}
+
+// Close stops pl from accepting connections,
+// and closes any connections which have not been returned by Accept.
+// Connections which negotiate pl's protocol after Close
+// are returned by the Listener's Accept method.
+// Close does not close the Listener.
+func (pl *ProtocolListener) Close() error {
+	pl.l.connsMu.Lock()
+	if pl.l.protocols[pl.proto] == pl {
+		delete(pl.l.protocols, pl.proto)
+	}
+	pl.l.connsMu.Unlock()
+	pl.stopAccepting()
+	return nil
+}
+
+// stopAccepting closes pl's accept queue,
+// and aborts any connections remaining in it.
+func (pl *ProtocolListener) stopAccepting() {
+	pl.acceptQueue.close(errListenerClosed)
+	pl.acceptQueue.gate.lock()
+	conns := pl.acceptQueue.q
+	pl.acceptQueue.q = nil
+	pl.acceptQueue.unlock()
+	for _, c := range conns {
+		c.Abort(localTransportError(errNo))
+	}
+}
+
+// serverConnReady is called when an inbound conn is ready to be returned by Accept:
+// When the handshake completes, or if Config.HalfRTTData is set,
+// when the conn can send 0.5-RTT data.
+//
+// The conn is added to the accept queue for its application protocol.
+// If the Listener has ProtocolListeners and the handshake has not completed,
+// the protocol is not yet known, and the conn is added to a queue
+// when the handshake completes.
+func (l *Listener) serverConnReady(c *Conn) {
+	if c.acceptQueued {
+		return
+	}
+	l.connsMu.Lock()
+	var pl *ProtocolListener
+	if len(l.protocols) > 0 {
+		if !c.handshakeConfirmed.isSet() {
+			l.connsMu.Unlock()
+			return
+		}
+		pl = l.protocols[c.tlsState.NegotiatedProtocol]
+	}
+	if _, ok := l.unaccepted[c]; ok {
+		l.queued[c] = struct{}{}
+	}
+	l.connsMu.Unlock()
+	c.acceptQueued = true
+	if pl != nil && pl.acceptQueue.put(c) {
+		return
+	}
+	// If pl was closed after we found it, the conn goes to the Listener
+	// as if pl had been closed first.
+	l.acceptQueue.put(c)
+}
+
+// closeProtocolListeners stops all the Listener's ProtocolListeners from accepting connections.
+func (l *Listener) closeProtocolListeners() {
+	l.connsMu.Lock()
+	protocols := l.protocols
+	l.protocols = nil
+	l.connsMu.Unlock()
+	for _, pl := range protocols {
+		pl.stopAccepting()
+	}
+}
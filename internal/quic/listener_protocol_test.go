@@ -0,0 +1,147 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"context"
+	"crypto/tls"
+	"testing"
+	"time"
+)
+
+func newLocalProtocolListener(t *testing.T, protos ...string) *Listener {
+	t.Helper()
+	config := &Config{TLSConfig: newTestTLSConfig(serverSide)}
+	config.TLSConfig.NextProtos = protos
+	return newLocalListener(t, serverSide, config)
+}
+
+func dialProtocol(t *testing.T, l *Listener, proto string) *Conn {
+	t.Helper()
+	config := &Config{TLSConfig: newTestTLSConfig(clientSide)}
+	config.TLSConfig.NextProtos = []string{proto}
+	cli := newLocalListener(t, clientSide, config)
+	c, err := cli.Dial(context.Background(), "udp", l.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestListenProtocolRouting(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	srv := newLocalProtocolListener(t, "h3", "doq", "other")
+	h3, err := srv.ListenProtocol("h3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	doq, err := srv.ListenProtocol("doq")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		proto  string
+		accept func(context.Context) (*Conn, error)
+	}{{
+		proto:  "doq",
+		accept: doq.Accept,
+	}, {
+		proto:  "h3",
+		accept: h3.Accept,
+	}, {
+		proto:  "other",
+		accept: srv.Accept,
+	}} {
+		dialProtocol(t, srv, test.proto)
+		c, err := test.accept(ctx)
+		if err != nil {
+			t.Fatalf("dial with protocol %q: Accept: %v", test.proto, err)
+		}
+		if got := c.ConnectionState().NegotiatedProtocol; got != test.proto {
+			t.Errorf("dial with protocol %q: accepted conn negotiated %q", test.proto, got)
+		}
+	}
+	for _, accept := range []func(context.Context) (*Conn, error){
+		srv.Accept, h3.Accept, doq.Accept,
+	} {
+		if c, err := accept(canceledContext()); err == nil {
+			t.Errorf("unexpected extra conn accepted: negotiated %q", c.ConnectionState().NegotiatedProtocol)
+		}
+	}
+}
+
+func TestListenProtocolTwice(t *testing.T) {
+	srv := newLocalProtocolListener(t, "h3")
+	if _, err := srv.ListenProtocol("h3"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := srv.ListenProtocol("h3"); err == nil {
+		t.Fatalf("second ListenProtocol(%q) succeeded, want error", "h3")
+	}
+}
+
+func TestListenProtocolClose(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	srv := newLocalProtocolListener(t, "h3")
+	pl, err := srv.ListenProtocol("h3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pl.Close()
+	if _, err := pl.Accept(ctx); err == nil {
+		t.Fatalf("ProtocolListener.Accept after Close succeeded, want error")
+	}
+	dialProtocol(t, srv, "h3")
+	if _, err := srv.Accept(ctx); err != nil {
+		t.Fatalf("Listener.Accept of conn for closed ProtocolListener: %v", err)
+	}
+	if _, err := srv.ListenProtocol("h3"); err != nil {
+		t.Fatalf("ListenProtocol after closing previous ProtocolListener: %v", err)
+	}
+}
+
+func TestListenProtocolListenerClosed(t *testing.T) {
+	srv := newLocalProtocolListener(t, "h3")
+	pl, err := srv.ListenProtocol("h3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.Close(context.Background())
+	if _, err := pl.Accept(context.Background()); err == nil {
+		t.Fatalf("ProtocolListener.Accept after Listener.Close succeeded, want error")
+	}
+	if _, err := srv.ListenProtocol("doq"); err == nil {
+		t.Fatalf("ListenProtocol after Listener.Close succeeded, want error")
+	}
+}
+
+func TestListenProtocolHalfRTTData(t *testing.T) {
+	tc := newTestConn(t, serverSide, func(c *Config) {
+		c.HalfRTTData = true
+	})
+	if _, err := tc.listener.l.ListenProtocol("h3"); err != nil {
+		t.Fatal(err)
+	}
+	tc.ignoreFrame(frameTypeAck)
+	tc.writeFrames(packetTypeInitial,
+		debugFrameCrypto{
+			data: tc.cryptoDataIn[tls.QUICEncryptionLevelInitial],
+		})
+	if _, err := tc.listener.l.Accept(canceledContext()); err == nil {
+		t.Fatalf("Accept before handshake completes succeeded, want error")
+	}
+	tc.writeFrames(packetTypeHandshake,
+		debugFrameCrypto{
+			data: tc.cryptoDataIn[tls.QUICEncryptionLevelHandshake],
+		})
+	if _, err := tc.listener.l.Accept(canceledContext()); err != nil {
+		t.Fatalf("Accept after handshake completes: %v", err)
+	}
+}
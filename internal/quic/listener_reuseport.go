@@ -0,0 +1,101 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+)
+
+// ListenReusePort opens n Listeners bound to the same local network address
+// with the SO_REUSEPORT socket option.
+// Each Listener has its own socket and receive goroutine,
+// and the operating system distributes incoming datagrams among them,
+// so a server can receive datagrams on several cores at once.
+// The configuration config must be non-nil.
+//
+// The Listeners share a RetryKey, so one Listener can validate the address
+// of a client sent a Retry by another.
+//
+// Each Listener has its own stateless reset key,
+// derived from config.StatelessResetKey and the Listener's index.
+// The operating system may deliver a datagram for a live connection
+// to a Listener which does not know of it, such as after the peer's
+// address changes; that Listener must not reset the connection.
+// A stateless reset for a connection created before a restart is only
+// effective when the datagram reaches the Listener with the same index.
+//
+// If either key in config is zero, a random key is used in its place.
+// Since a random StatelessResetKey is used only by this set of Listeners,
+// it permits stateless resets to be sent.
+//
+// If the address has a port of zero, the Listeners share a randomly chosen port.
+// ListenReusePort returns an error on platforms which do not support SO_REUSEPORT.
+func ListenReusePort(network, address string, n int, config *Config) ([]*Listener, error) {
+	if n < 1 {
+		return nil, errors.New("ListenReusePort requires at least one listener")
+	}
+	if err := validateListenerConfig(config); err != nil {
+		return nil, err
+	}
+	c := *config
+	config = &c
+	zero := [32]byte{}
+	if config.StatelessResetKey == zero {
+		if _, err := rand.Read(config.StatelessResetKey[:]); err != nil {
+			return nil, err
+		}
+	}
+	if config.RetryKey == zero {
+		if _, err := rand.Read(config.RetryKey[:]); err != nil {
+			return nil, err
+		}
+	}
+	var listeners []*Listener
+	for i := 0; i < n; i++ {
+		lc := *config
+		lc.StatelessResetKey = reusePortResetKey(config.StatelessResetKey, i)
+		l, err := listenReusePort(network, address, &lc)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close(context.Background())
+			}
+			return nil, err
+		}
+		listeners = append(listeners, l)
+		// Bind the remaining listeners to the port chosen for the first.
+		address = l.LocalAddr().String()
+	}
+	return listeners, nil
+}
+
+// reusePortResetKey returns the stateless reset key for
+// the i'th Listener created by ListenReusePort.
+func reusePortResetKey(key [32]byte, i int) (derived [32]byte) {
+	mac := hmac.New(sha256.New, key[:])
+	mac.Write([]byte("quic reuseport reset key"))
+	mac.Write(binary.BigEndian.AppendUint64(nil, uint64(i)))
+	copy(derived[:], mac.Sum(nil))
+	return derived
+}
+
+func listenReusePort(network, address string, config *Config) (*Listener, error) {
+	udpConn, err := listenUDP(network, address, config, true)
+	if err != nil {
+		return nil, err
+	}
+	l, err := newListener(udpConn, config, nil)
+	if err != nil {
+		udpConn.Close()
+		return nil, err
+	}
+	return l, nil
+}
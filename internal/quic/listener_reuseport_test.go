@@ -0,0 +1,71 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestListenReusePort(t *testing.T) {
+	switch runtime.GOOS {
+	case "darwin", "dragonfly", "freebsd", "linux", "netbsd", "openbsd":
+	default:
+		t.Skipf("SO_REUSEPORT not supported on %v", runtime.GOOS)
+	}
+	ls, err := ListenReusePort("udp", "127.0.0.1:0", 2, &Config{
+		TLSConfig: newTestTLSConfig(serverSide),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ls[0].config.StatelessResetKey == ([32]byte{}) || ls[0].config.RetryKey == ([32]byte{}) {
+		t.Errorf("listeners created with zero StatelessResetKey or RetryKey")
+	}
+	acceptc := make(chan *Conn)
+	for _, l := range ls {
+		l := l
+		defer l.Close(canceledContext())
+		if got, want := l.LocalAddr(), ls[0].LocalAddr(); got != want {
+			t.Errorf("listener address %v, want %v", got, want)
+		}
+		if l.config.RetryKey != ls[0].config.RetryKey {
+			t.Errorf("listeners do not share a RetryKey")
+		}
+		if l != ls[0] && l.config.StatelessResetKey == ls[0].config.StatelessResetKey {
+			t.Errorf("listeners share a StatelessResetKey")
+		}
+		go func() {
+			for {
+				c, err := l.Accept(context.Background())
+				if err != nil {
+					return
+				}
+				acceptc <- c
+			}
+		}()
+	}
+
+	const numConns = 4
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	cl := newLocalListener(t, clientSide, &Config{})
+	defer cl.Close(canceledContext())
+	for i := 0; i < numConns; i++ {
+		if _, err := cl.Dial(ctx, "udp", ls[0].LocalAddr().String()); err != nil {
+			t.Fatalf("Dial: %v", err)
+		}
+		select {
+		case c := <-acceptc:
+			c.Abort(nil)
+		case <-ctx.Done():
+			t.Fatalf("no listener accepted the connection")
+		}
+	}
+}
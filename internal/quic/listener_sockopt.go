@@ -0,0 +1,80 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"syscall"
+)
+
+// listenUDP creates a UDP socket bound to address,
+// applying the socket options in config.
+//
+// The socket is configured to set the Don't Fragment bit where possible,
+// so oversized datagrams are dropped rather than fragmented:
+// "UDP datagrams MUST NOT be fragmented at the IP layer."
+// https://www.rfc-editor.org/rfc/rfc9000#section-14-7
+func listenUDP(network, address string, config *Config, reusePort bool) (*net.UDPConn, error) {
+	if _, err := net.ResolveUDPAddr(network, address); err != nil {
+		return nil, err
+	}
+	lc := net.ListenConfig{
+		Control: func(network, address string, c syscall.RawConn) error {
+			// Not all platforms support disabling fragmentation,
+			// so this is best-effort.
+			setDontFragment(network, c)
+			if reusePort {
+				if err := setReusePort(network, address, c); err != nil {
+					return err
+				}
+			}
+			if config.V6Only && network == "udp6" {
+				if err := setV6Only(c); err != nil {
+					return err
+				}
+			}
+			if config.BindToDevice != "" {
+				if err := bindToDevice(c, config.BindToDevice); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+	pc, err := lc.ListenPacket(context.Background(), network, address)
+	if err != nil {
+		return nil, err
+	}
+	return pc.(*net.UDPConn), nil
+}
+
+// dialUDP creates a UDP socket connected to raddr,
+// applying the socket options in config.
+// If laddr is valid, the socket is bound to it.
+func dialUDP(laddr netip.AddrPort, raddr netip.AddrPort, config *Config) (*net.UDPConn, error) {
+	d := net.Dialer{
+		Control: func(network, address string, c syscall.RawConn) error {
+			setDontFragment(network, c)
+			if config.BindToDevice != "" {
+				if err := bindToDevice(c, config.BindToDevice); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+	if laddr.IsValid() {
+		d.LocalAddr = net.UDPAddrFromAddrPort(laddr)
+	}
+	c, err := d.Dial("udp", raddr.String())
+	if err != nil {
+		return nil, err
+	}
+	return c.(*net.UDPConn), nil
+}
@@ -0,0 +1,87 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21 && linux
+
+package quic
+
+import (
+	"context"
+	"errors"
+	"net"
+	"syscall"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func listenerSockopt(t *testing.T, l *Listener, level, opt int) int {
+	t.Helper()
+	rc, err := l.udpConn.(*net.UDPConn).SyscallConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v int
+	var serr error
+	if err := rc.Control(func(fd uintptr) {
+		v, serr = unix.GetsockoptInt(int(fd), level, opt)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	return v
+}
+
+func TestListenV6Only(t *testing.T) {
+	for _, v6only := range []bool{false, true} {
+		l, err := Listen("udp", "[::]:0", &Config{
+			TLSConfig: newTestTLSConfig(serverSide),
+			V6Only:    v6only,
+		})
+		if err != nil {
+			t.Skipf("IPv6 not available: %v", err)
+		}
+		got := listenerSockopt(t, l, unix.IPPROTO_IPV6, unix.IPV6_V6ONLY) != 0
+		l.Close(canceledContext())
+		if got != v6only {
+			t.Errorf("V6Only = %v: IPV6_V6ONLY = %v", v6only, got)
+		}
+	}
+}
+
+func TestListenBindToDevice(t *testing.T) {
+	l, err := Listen("udp", "127.0.0.1:0", &Config{
+		TLSConfig:    newTestTLSConfig(serverSide),
+		BindToDevice: "lo",
+	})
+	if errors.Is(err, syscall.EPERM) {
+		t.Skipf("binding to a device not permitted: %v", err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close(canceledContext())
+	cl := newLocalListener(t, clientSide, &Config{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if _, err := cl.Dial(ctx, "udp", l.LocalAddr().String()); err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+
+	if _, err := Listen("udp", "127.0.0.1:0", &Config{
+		TLSConfig:    newTestTLSConfig(serverSide),
+		BindToDevice: "no-such-device",
+	}); err == nil {
+		t.Errorf("Listen with BindToDevice of nonexistent device succeeded, want error")
+	}
+}
+
+func TestListenDontFragment(t *testing.T) {
+	l := newLocalListener(t, serverSide, &Config{})
+	if got, want := listenerSockopt(t, l, unix.IPPROTO_IP, unix.IP_MTU_DISCOVER), unix.IP_PMTUDISC_PROBE; got != want {
+		t.Errorf("IP_MTU_DISCOVER = %v, want IP_PMTUDISC_PROBE (%v)", got, want)
+	}
+}
@@ -0,0 +1,20 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21 && !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package quic
+
+import (
+	"errors"
+	"syscall"
+)
+
+func setV6Only(c syscall.RawConn) error {
+	return errors.New("IPV6_V6ONLY is not supported on this platform")
+}
+
+func bindToDevice(c syscall.RawConn, name string) error {
+	return errors.New("SO_BINDTODEVICE is not supported on this platform")
+}
@@ -0,0 +1,28 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21 && (darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package quic
+
+import (
+	"errors"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+func setV6Only(c syscall.RawConn) error {
+	var serr error
+	if err := c.Control(func(fd uintptr) {
+		serr = unix.SetsockoptInt(int(fd), unix.IPPROTO_IPV6, unix.IPV6_V6ONLY, 1)
+	}); err != nil {
+		return err
+	}
+	return serr
+}
+
+func bindToDevice(c syscall.RawConn, name string) error {
+	return errors.New("SO_BINDTODEVICE is not supported on this platform")
+}
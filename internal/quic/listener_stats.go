@@ -0,0 +1,105 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"expvar"
+	"sync/atomic"
+)
+
+// ListenerStats contains counters of Listener activity.
+type ListenerStats struct {
+	DatagramsReceived       uint64 // UDP datagrams read from the network
+	DatagramsSent           uint64 // UDP datagrams written to the network
+	DatagramsDenied         uint64 // UDP datagrams discarded by Config.AllowedSources or DeniedSources
+	DatagramsFiltered       uint64 // UDP datagrams dropped by Config.DatagramFilter
+	DatagramsMarked         uint64 // UDP datagrams marked by Config.DatagramFilter
+	DatagramsNotQUIC        uint64 // UDP datagrams passed to Config.NonQUICDatagram
+	DatagramsDropped        uint64 // UDP datagrams dropped because a connection's receive queue was full
+	StatelessResetsSent     uint64
+	VersionNegotiationsSent uint64
+	HandshakesStarted       uint64 // inbound and outbound connections created
+	HandshakesFailed        uint64 // connections closed before the handshake completed
+	ConnsRefused            uint64 // inbound connections refused because the accept queue was full
+	HandshakesRateLimited   uint64 // Initial packets exceeding Config.HandshakeRateLimit
+	HandshakesLimited       uint64 // Initial packets exceeding Config.MaxHandshakes
+	ActiveConns             int    // connections currently open, including draining connections
+
+	// Packets and frames received by all connections, open and closed.
+	PacketsReceived PacketCounts
+	FramesReceived  FrameCounts
+}
+
+// listenerStats holds a Listener's counters.
+type listenerStats struct {
+	datagramsReceived       atomic.Uint64
+	datagramsSent           atomic.Uint64
+	datagramsDenied         atomic.Uint64
+	datagramsFiltered       atomic.Uint64
+	datagramsMarked         atomic.Uint64
+	datagramsNotQUIC        atomic.Uint64
+	datagramsDropped        atomic.Uint64
+	statelessResetsSent     atomic.Uint64
+	versionNegotiationsSent atomic.Uint64
+	handshakesStarted       atomic.Uint64
+	handshakesFailed        atomic.Uint64
+	connsRefused            atomic.Uint64
+	handshakesRateLimited   atomic.Uint64
+	handshakesLimited       atomic.Uint64
+
+	// Packets and frames received by closed connections.
+	// Guarded by Listener.connsMu, so a conn is counted here
+	// at the same time it is removed from Listener.conns.
+	closedPackets packetCounters
+	closedFrames  frameCounters
+}
+
+// Stats returns a snapshot of the Listener's counters.
+func (l *Listener) Stats() ListenerStats {
+	var (
+		packets packetCounters
+		frames  frameCounters
+	)
+	l.connsMu.Lock()
+	active := len(l.conns)
+	packets.addAll(&l.stats.closedPackets)
+	frames.addAll(&l.stats.closedFrames)
+	for c := range l.conns {
+		packets.addAll(&c.counters.packets)
+		frames.addAll(&c.counters.frames)
+	}
+	l.connsMu.Unlock()
+	return ListenerStats{
+		DatagramsReceived:       l.stats.datagramsReceived.Load(),
+		DatagramsSent:           l.stats.datagramsSent.Load(),
+		DatagramsDenied:         l.stats.datagramsDenied.Load(),
+		DatagramsFiltered:       l.stats.datagramsFiltered.Load(),
+		DatagramsMarked:         l.stats.datagramsMarked.Load(),
+		DatagramsNotQUIC:        l.stats.datagramsNotQUIC.Load(),
+		DatagramsDropped:        l.stats.datagramsDropped.Load(),
+		StatelessResetsSent:     l.stats.statelessResetsSent.Load(),
+		VersionNegotiationsSent: l.stats.versionNegotiationsSent.Load(),
+		HandshakesStarted:       l.stats.handshakesStarted.Load(),
+		HandshakesFailed:        l.stats.handshakesFailed.Load(),
+		ConnsRefused:            l.stats.connsRefused.Load(),
+		HandshakesRateLimited:   l.stats.handshakesRateLimited.Load(),
+		HandshakesLimited:       l.stats.handshakesLimited.Load(),
+		ActiveConns:             active,
+		PacketsReceived:         packets.counts(),
+		FramesReceived:          frames.counts(),
+	}
+}
+
+// StatsVar returns an expvar.Var reporting the Listener's counters.
+// The Var is not published; use expvar.Publish to do so:
+//
+//	expvar.Publish("quic", l.StatsVar())
+func (l *Listener) StatsVar() expvar.Var {
+	return expvar.Func(func() any {
+		return l.Stats()
+	})
+}
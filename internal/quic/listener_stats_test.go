@@ -0,0 +1,80 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestListenerStats(t *testing.T) {
+	cli, srv := newLocalConnPair(t, &Config{}, &Config{})
+
+	for _, test := range []struct {
+		name string
+		l    *Listener
+	}{
+		{"client", cli.listener},
+		{"server", srv.listener},
+	} {
+		stats := test.l.Stats()
+		if stats.DatagramsReceived == 0 || stats.DatagramsSent == 0 {
+			t.Errorf("%v: stats = %+v, want nonzero datagram counts", test.name, stats)
+		}
+		if got, want := stats.HandshakesStarted, uint64(1); got != want {
+			t.Errorf("%v: HandshakesStarted = %v, want %v", test.name, got, want)
+		}
+		if got, want := stats.HandshakesFailed, uint64(0); got != want {
+			t.Errorf("%v: HandshakesFailed = %v, want %v", test.name, got, want)
+		}
+		if got, want := stats.ActiveConns, 1; got != want {
+			t.Errorf("%v: ActiveConns = %v, want %v", test.name, got, want)
+		}
+		if stats.PacketsReceived.Initial == 0 || stats.FramesReceived.Crypto == 0 {
+			t.Errorf("%v: PacketsReceived = %+v, FramesReceived = %+v, want nonzero Initial packets and CRYPTO frames", test.name, stats.PacketsReceived, stats.FramesReceived)
+		}
+	}
+}
+
+func TestListenerStatsVersionNegotiation(t *testing.T) {
+	tl := newTestListener(t, &Config{
+		TLSConfig: newTestTLSConfig(serverSide),
+	})
+	pkt := []byte{
+		0b1000_0000,
+		0x00, 0x00, 0x00, 0x0f, // unknown version
+		4, 1, 2, 3, 4, // dst conn id
+		4, 5, 6, 7, 8, // src conn id
+	}
+	for len(pkt) < paddedInitialDatagramSize {
+		pkt = append(pkt, 0)
+	}
+	tl.write(&datagram{b: pkt})
+	if tl.read() == nil {
+		t.Fatalf("got no response; want Version Negotiation")
+	}
+	stats := tl.l.Stats()
+	if got, want := stats.VersionNegotiationsSent, uint64(1); got != want {
+		t.Errorf("VersionNegotiationsSent = %v, want %v", got, want)
+	}
+	if got, want := stats.DatagramsReceived, uint64(1); got != want {
+		t.Errorf("DatagramsReceived = %v, want %v", got, want)
+	}
+}
+
+func TestListenerStatsVar(t *testing.T) {
+	tl := newTestListener(t, &Config{
+		TLSConfig: newTestTLSConfig(serverSide),
+	})
+	var got ListenerStats
+	if err := json.Unmarshal([]byte(tl.l.StatsVar().String()), &got); err != nil {
+		t.Fatalf("unmarshal StatsVar: %v", err)
+	}
+	if want := tl.l.Stats(); got != want {
+		t.Errorf("StatsVar = %+v, want %+v", got, want)
+	}
+}
@@ -10,10 +10,12 @@ import (
 	"bytes"
 	"context"
 	"crypto/tls"
+	"errors"
 	"io"
 	"net"
 	"net/netip"
 	"reflect"
+	"sync"
 	"testing"
 	"time"
 )
@@ -22,6 +24,44 @@ func TestConnect(t *testing.T) {
 	newLocalConnPair(t, &Config{}, &Config{})
 }
 
+func TestConnAddrs(t *testing.T) {
+	ctx := context.Background()
+	srvl := newLocalListener(t, serverSide, &Config{})
+	clil := newLocalListener(t, clientSide, &Config{})
+	cli, err := clil.Dial(ctx, "udp", srvl.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv, err := srvl.Accept(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		name          string
+		local, remote netip.AddrPort
+		wantL, wantR  netip.AddrPort
+	}{{
+		name:   "client",
+		local:  cli.LocalAddr(),
+		remote: cli.RemoteAddr(),
+		wantL:  clil.LocalAddr(),
+		wantR:  srvl.LocalAddr(),
+	}, {
+		name:   "server",
+		local:  srv.LocalAddr(),
+		remote: srv.RemoteAddr(),
+		wantL:  srvl.LocalAddr(),
+		wantR:  clil.LocalAddr(),
+	}} {
+		if test.local != test.wantL {
+			t.Errorf("%v: LocalAddr() = %v, want %v", test.name, test.local, test.wantL)
+		}
+		if test.remote != test.wantR {
+			t.Errorf("%v: RemoteAddr() = %v, want %v", test.name, test.remote, test.wantR)
+		}
+	}
+}
+
 func TestStreamTransfer(t *testing.T) {
 	ctx := context.Background()
 	cli, srv := newLocalConnPair(t, &Config{}, &Config{})
@@ -61,6 +101,152 @@ func TestStreamTransfer(t *testing.T) {
 	}
 }
 
+// wrappedPacketConn hides the *net.UDPConn methods of a net.PacketConn.
+type wrappedPacketConn struct {
+	net.PacketConn
+}
+
+func TestListenPacketConn(t *testing.T) {
+	ctx := context.Background()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l1, err := ListenPacketConn(wrappedPacketConn{pc}, &Config{
+		TLSConfig: newTestTLSConfig(serverSide),
+	})
+	if err != nil {
+		pc.Close()
+		t.Fatal(err)
+	}
+	defer l1.Close(canceledContext())
+	if got, want := l1.LocalAddr().String(), pc.LocalAddr().String(); got != want {
+		t.Errorf("l.LocalAddr() = %v, want %v", got, want)
+	}
+
+	l2 := newLocalListener(t, clientSide, &Config{})
+	defer l2.Close(canceledContext())
+	cli, err := l2.Dial(ctx, "udp", l1.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv, err := l1.Accept(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := cli.NewStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte("hello")
+	s.Write(data)
+	s.CloseWrite()
+	ss, err := srv.AcceptStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make([]byte, len(data))
+	if _, err := io.ReadFull(ss, got); err != nil || !bytes.Equal(got, data) {
+		t.Errorf("read %q, %v; want %q", got, err, data)
+	}
+
+	closeCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if err := l1.Close(closeCtx); err != nil {
+		t.Errorf("l.Close() = %v", err)
+	}
+	if _, err := pc.WriteTo([]byte{0}, pc.LocalAddr()); err == nil {
+		t.Errorf("PacketConn still open after closing Listener")
+	}
+}
+
+// memTransport is an in-memory DatagramTransport.
+type memTransport struct {
+	addr   *net.UDPAddr
+	peer   *memTransport
+	recvc  chan []byte
+	closec chan struct{}
+	once   sync.Once
+}
+
+func newMemTransportPair(addr1, addr2 string) (*memTransport, *memTransport) {
+	t1 := &memTransport{
+		addr:   net.UDPAddrFromAddrPort(netip.MustParseAddrPort(addr1)),
+		recvc:  make(chan []byte, 100),
+		closec: make(chan struct{}),
+	}
+	t2 := &memTransport{
+		addr:   net.UDPAddrFromAddrPort(netip.MustParseAddrPort(addr2)),
+		recvc:  make(chan []byte, 100),
+		closec: make(chan struct{}),
+	}
+	t1.peer, t2.peer = t2, t1
+	return t1, t2
+}
+
+func (t *memTransport) ReadFrom(p []byte) (int, net.Addr, error) {
+	select {
+	case b := <-t.recvc:
+		return copy(p, b), t.peer.addr, nil
+	case <-t.closec:
+		return 0, nil, net.ErrClosed
+	}
+}
+
+func (t *memTransport) WriteTo(p []byte, addr net.Addr) (int, error) {
+	select {
+	case t.peer.recvc <- bytes.Clone(p):
+	default: // drop
+	}
+	return len(p), nil
+}
+
+func (t *memTransport) Close() error {
+	t.once.Do(func() { close(t.closec) })
+	return nil
+}
+
+func (t *memTransport) LocalAddr() net.Addr { return t.addr }
+
+func TestListenPacketConnTransport(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	srvTransport, cliTransport := newMemTransportPair("10.0.0.1:443", "10.0.0.2:1000")
+	srvl, err := ListenPacketConn(srvTransport, &Config{TLSConfig: newTestTLSConfig(serverSide)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srvl.Close(canceledContext())
+	clil, err := ListenPacketConn(cliTransport, &Config{TLSConfig: newTestTLSConfig(clientSide)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clil.Close(canceledContext())
+
+	cli, err := clil.Dial(ctx, "udp", "10.0.0.1:443")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv, err := srvl.Accept(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := cli.NewStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Write([]byte("hello"))
+	s.CloseWrite()
+	ss, err := srv.AcceptStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, err := io.ReadAll(ss); err != nil || string(b) != "hello" {
+		t.Errorf("read %q, %v; want %q", b, err, "hello")
+	}
+}
+
 func newLocalConnPair(t *testing.T, conf1, conf2 *Config) (clientConn, serverConn *Conn) {
 	t.Helper()
 	ctx := context.Background()
@@ -104,6 +290,7 @@ type testListener struct {
 	sentDatagrams         [][]byte
 	peerTLSConn           *tls.QUICConn
 	lastInitialDstConnID  []byte // for parsing Retry packets
+	newConnIDErr          error  // if set, returned by Conns' newConnID hook
 }
 
 func newTestListener(t *testing.T, config *Config) *testListener {
@@ -132,6 +319,7 @@ func (tl *testListener) wait() {
 	case tl.idlec <- struct{}{}:
 	case <-tl.l.closec:
 	}
+	tl.l.unknownDest.wait()
 	for _, tc := range tl.conns {
 		tc.wait()
 	}
@@ -251,6 +439,30 @@ func (tl *testListener) wantIdle(expectation string) {
 	}
 }
 
+// writeClientInitial sends the listener a client Initial packet
+// starting a new connection.
+func (tl *testListener) writeClientInitial(srcConnID, dstConnID, token []byte) {
+	tl.t.Helper()
+	params := defaultTransportParameters()
+	params.initialSrcConnID = srcConnID
+	tl.writeDatagram(&testDatagram{
+		packets: []*testPacket{{
+			ptype:     packetTypeInitial,
+			num:       0,
+			version:   quicVersion1,
+			srcConnID: srcConnID,
+			dstConnID: dstConnID,
+			token:     token,
+			frames: []debugFrame{
+				debugFrameCrypto{
+					data: initialClientCrypto(tl.t, tl, params),
+				},
+			},
+		}},
+		paddedSize: 1200,
+	})
+}
+
 func (tl *testListener) newClientTLS(srcConnID, dstConnID []byte) []byte {
 	peerProvidedParams := defaultTransportParameters()
 	peerProvidedParams.initialSrcConnID = srcConnID
@@ -341,3 +553,533 @@ func (tl *testListenerUDPConn) WriteToUDPAddrPort(b []byte, addr netip.AddrPort)
 	tl.sentDatagrams = append(tl.sentDatagrams, append([]byte(nil), b...))
 	return len(b), nil
 }
+
+func TestListenerNewConnFailure(t *testing.T) {
+	tl := newTestListener(t, &Config{
+		TLSConfig: newTestTLSConfig(serverSide),
+	})
+	tl.newConnIDErr = errors.New("test error")
+	srcConnID, dstConnID := testPeerConnID(0), []byte("failed!!")
+	tl.writeClientInitial(srcConnID, dstConnID, nil)
+	tl.wantDatagram("server closes connection it could not create",
+		initialConnectionCloseDatagram(dstConnID, srcConnID, errInternal))
+	if len(tl.acceptQueue) != 0 {
+		t.Errorf("server created a connection for failed Initial")
+	}
+}
+
+func TestListenerAcceptQueueExcludesHandshakes(t *testing.T) {
+	tl := newTestListener(t, &Config{
+		TLSConfig:      newTestTLSConfig(serverSide),
+		MaxAcceptQueue: 1,
+	})
+	// Neither connection completes its handshake,
+	// so neither counts towards the accept queue limit.
+	for i := 0; i < 2; i++ {
+		tl.writeClientInitial(testPeerConnID(int64(i)), testLocalConnID(int64(-1-i)), nil)
+		tl.accept()
+		for tl.read() != nil {
+			// Discard the connection's handshake datagrams.
+		}
+	}
+	if got := tl.l.Stats().ConnsRefused; got != 0 {
+		t.Errorf("ConnsRefused = %v, want 0", got)
+	}
+}
+
+// waitAcceptQueued waits until l holds n connections ready to be returned by Accept.
+func waitAcceptQueued(t *testing.T, l *Listener, n int) {
+	t.Helper()
+	for start := time.Now(); ; time.Sleep(time.Millisecond) {
+		l.connsMu.Lock()
+		queued := len(l.queued)
+		l.connsMu.Unlock()
+		if queued == n {
+			return
+		}
+		if time.Since(start) > 10*time.Second {
+			t.Fatalf("accept queue holds %v conns, want %v", queued, n)
+		}
+	}
+}
+
+func TestListenerAcceptQueueFull(t *testing.T) {
+	// "A server that chooses not to accept a connection [...]
+	// MAY send a CONNECTION_CLOSE frame with a CONNECTION_REFUSED error."
+	// https://www.rfc-editor.org/rfc/rfc9000.html#section-5.2.2-5
+	ctx := context.Background()
+	srv := newLocalListener(t, serverSide, &Config{MaxAcceptQueue: 1})
+	cli := newLocalListener(t, clientSide, &Config{})
+	// Don't wait for the refused connection to finish draining.
+	defer cli.Close(canceledContext())
+
+	if _, err := cli.Dial(ctx, "udp", srv.LocalAddr().String()); err != nil {
+		t.Fatalf("first Dial: %v", err)
+	}
+	waitAcceptQueued(t, srv, 1)
+	_, err := cli.Dial(ctx, "udp", srv.LocalAddr().String())
+	if want := (peerTransportError{code: errConnectionRefused}); !errors.Is(err, want) {
+		t.Fatalf("second Dial with full accept queue: %v, want %v", err, want)
+	}
+	if got, want := srv.Stats().ConnsRefused, uint64(1); got != want {
+		t.Errorf("ConnsRefused = %v, want %v", got, want)
+	}
+}
+
+func TestListenerAcceptQueueFreedByAccept(t *testing.T) {
+	ctx := context.Background()
+	srv := newLocalListener(t, serverSide, &Config{MaxAcceptQueue: 1})
+	cli := newLocalListener(t, clientSide, &Config{})
+
+	if _, err := cli.Dial(ctx, "udp", srv.LocalAddr().String()); err != nil {
+		t.Fatalf("first Dial: %v", err)
+	}
+	if _, err := srv.Accept(ctx); err != nil {
+		t.Fatalf("Accept: %v", err)
+	}
+	if _, err := cli.Dial(ctx, "udp", srv.LocalAddr().String()); err != nil {
+		t.Fatalf("second Dial after Accept: %v", err)
+	}
+	// The second connection has not been accepted, so the queue is full.
+	waitAcceptQueued(t, srv, 1)
+	_, err := cli.Dial(ctx, "udp", srv.LocalAddr().String())
+	if want := (peerTransportError{code: errConnectionRefused}); !errors.Is(err, want) {
+		t.Fatalf("third Dial with full accept queue: %v, want %v", err, want)
+	}
+	// Don't wait for the refused connection to finish draining.
+	cli.Close(canceledContext())
+}
+
+func TestListenerMaxHandshakes(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		action    AcceptAction
+		wantRetry bool
+		wantClose bool
+	}{{
+		name:   "drop",
+		action: AcceptDrop,
+	}, {
+		name:      "retry",
+		action:    AcceptRetry,
+		wantRetry: true,
+	}, {
+		name:      "refuse",
+		action:    AcceptRefuse,
+		wantClose: true,
+	}} {
+		t.Run(test.name, func(t *testing.T) {
+			tl := newTestListener(t, &Config{
+				TLSConfig:           newTestTLSConfig(serverSide),
+				MaxHandshakes:       1,
+				MaxHandshakesAction: test.action,
+			})
+			tl.writeClientInitial(testPeerConnID(0), testLocalConnID(-1), nil)
+			tl.accept()
+			for tl.read() != nil {
+				// Discard the first connection's handshake datagrams.
+			}
+
+			// The first connection is still handshaking.
+			srcConnID, dstConnID := testPeerConnID(10), []byte("limited!")
+			tl.writeClientInitial(srcConnID, dstConnID, nil)
+			if len(tl.acceptQueue) != 0 {
+				t.Errorf("server created a connection beyond MaxHandshakes")
+			}
+			switch {
+			case test.wantClose:
+				tl.wantDatagram("server refuses connection beyond MaxHandshakes",
+					initialConnectionCloseDatagram(dstConnID, srcConnID, errConnectionRefused))
+			case test.wantRetry:
+				d := tl.readDatagram()
+				if d == nil || len(d.packets) != 1 || d.packets[0].ptype != packetTypeRetry {
+					t.Fatalf("got datagram %v, want Retry", d)
+				}
+				retry := d.packets[0]
+				tl.writeClientInitial(srcConnID, retry.srcConnID, retry.token)
+				if len(tl.acceptQueue) == 0 {
+					t.Errorf("no connection created after client validated its address")
+				}
+			default:
+				tl.wantIdle("server drops Initial beyond MaxHandshakes")
+			}
+			if got, want := tl.l.Stats().HandshakesLimited, uint64(1); got < want {
+				t.Errorf("HandshakesLimited = %v, want at least %v", got, want)
+			}
+		})
+	}
+}
+
+func TestListenerMaxHandshakesCheckedAtConnCreation(t *testing.T) {
+	// unknownDestPool workers may check MaxHandshakes concurrently,
+	// and find it not yet reached. Creating the conn enforces the limit.
+	tl := newTestListener(t, &Config{
+		TLSConfig:     newTestTLSConfig(serverSide),
+		MaxHandshakes: 1,
+	})
+	for i, want := range []error{nil, errHandshakesAtLimit} {
+		odcid := testPeerConnID(int64(i))
+		_, err := tl.l.newConn(tl.now, serverSide, version1Params, odcid, nil, testClientAddr, netip.AddrPort{})
+		if err != want {
+			t.Errorf("newConn #%v = %v, want %v", i, err, want)
+		}
+	}
+	// A client which validated its address after an AcceptRetry may exceed the limit.
+	tl.l.config.MaxHandshakesAction = AcceptRetry
+	odcid, rscid := testPeerConnID(10), testLocalConnID(10)
+	if _, err := tl.l.newConn(tl.now, serverSide, version1Params, odcid, rscid, testClientAddr, netip.AddrPort{}); err != nil {
+		t.Errorf("newConn for validated client = %v, want success", err)
+	}
+}
+
+func TestListenerMaxHandshakesFreedByHandshakeCompletion(t *testing.T) {
+	ctx := context.Background()
+	srv := newLocalListener(t, serverSide, &Config{
+		MaxHandshakes:       1,
+		MaxHandshakesAction: AcceptRefuse,
+	})
+	cli := newLocalListener(t, clientSide, &Config{})
+	for i := 0; i < 3; i++ {
+		if _, err := cli.Dial(ctx, "udp", srv.LocalAddr().String()); err != nil {
+			t.Fatalf("Dial #%v: %v", i, err)
+		}
+		if _, err := srv.Accept(ctx); err != nil {
+			t.Fatalf("Accept #%v: %v", i, err)
+		}
+	}
+	if got := srv.Stats().HandshakesLimited; got != 0 {
+		t.Errorf("HandshakesLimited = %v, want 0", got)
+	}
+}
+
+func TestListenerShutdown(t *testing.T) {
+	ctx := context.Background()
+	srv := newLocalListener(t, serverSide, &Config{})
+	cli := newLocalListener(t, clientSide, &Config{})
+	// Don't wait for the refused connection to finish draining.
+	defer cli.Close(canceledContext())
+
+	cliConn, err := cli.Dial(ctx, "udp", srv.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	srvConn, err := srv.Accept(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	onShutdown := make(chan struct{})
+	srv.RegisterOnShutdown(func() { close(onShutdown) })
+	shutdownDone := make(chan error, 1)
+	go func() {
+		shutdownDone <- srv.Shutdown(ctx)
+	}()
+	<-onShutdown
+
+	if _, err := srv.Accept(ctx); err == nil {
+		t.Errorf("Accept after Shutdown succeeded, want error")
+	}
+	_, err = cli.Dial(ctx, "udp", srv.LocalAddr().String())
+	if want := (peerTransportError{code: errConnectionRefused}); !errors.Is(err, want) {
+		t.Errorf("Dial after Shutdown: %v, want %v", err, want)
+	}
+
+	// The existing connection continues to work.
+	s, err := cliConn.NewStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Write([]byte("hello"))
+	s.CloseWrite()
+	ss, err := srvConn.AcceptStream(ctx)
+	if err != nil {
+		t.Fatalf("AcceptStream after Shutdown: %v", err)
+	}
+	if b, err := io.ReadAll(ss); err != nil || string(b) != "hello" {
+		t.Fatalf("read %q, %v from stream after Shutdown, want %q, nil", b, err, "hello")
+	}
+	select {
+	case err := <-shutdownDone:
+		t.Fatalf("Shutdown returned %v with an open connection", err)
+	default:
+	}
+
+	srvConn.Close()
+	if err := <-shutdownDone; err != nil {
+		t.Errorf("Shutdown() = %v, want nil", err)
+	}
+}
+
+func TestListenerShutdownContextExpires(t *testing.T) {
+	ctx := context.Background()
+	srv := newLocalListener(t, serverSide, &Config{})
+	cli := newLocalListener(t, clientSide, &Config{})
+	if _, err := cli.Dial(ctx, "udp", srv.LocalAddr().String()); err != nil {
+		t.Fatal(err)
+	}
+	srvConn, err := srv.Accept(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != context.DeadlineExceeded {
+		t.Fatalf("Shutdown with open connection = %v, want context.DeadlineExceeded", err)
+	}
+	if err := srvConn.Wait(canceledContext()); err != context.Canceled {
+		t.Errorf("conn.Wait() after Shutdown = %v, want context.Canceled (conn still open)", err)
+	}
+	// Close aborts the connection Shutdown left open.
+	if err := srv.Close(ctx); err != nil {
+		t.Errorf("Close after Shutdown = %v, want nil", err)
+	}
+}
+
+func TestListenerAcceptFilter(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		action    AcceptAction
+		wantConn  bool
+		wantClose bool
+		wantRetry bool
+	}{{
+		name:     "accept",
+		action:   AcceptConn,
+		wantConn: true,
+	}, {
+		name:   "drop",
+		action: AcceptDrop,
+	}, {
+		name:      "refuse",
+		action:    AcceptRefuse,
+		wantClose: true,
+	}, {
+		name:      "retry",
+		action:    AcceptRetry,
+		wantRetry: true,
+	}} {
+		t.Run(test.name, func(t *testing.T) {
+			var got []InitialPacket
+			tl := newTestListener(t, &Config{
+				TLSConfig: newTestTLSConfig(serverSide),
+				AcceptFilter: func(p *InitialPacket) AcceptAction {
+					got = append(got, InitialPacket{
+						RemoteAddr: p.RemoteAddr,
+						Version:    p.Version,
+						DstConnID:  append([]byte(nil), p.DstConnID...),
+						SrcConnID:  append([]byte(nil), p.SrcConnID...),
+					})
+					return test.action
+				},
+			})
+			srcConnID, dstConnID := testPeerConnID(0), testLocalConnID(-1)
+			tl.writeClientInitial(srcConnID, dstConnID, nil)
+
+			want := []InitialPacket{{
+				RemoteAddr: testClientAddr,
+				Version:    Version1,
+				DstConnID:  dstConnID,
+				SrcConnID:  srcConnID,
+			}}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("AcceptFilter called with:\n%+v\nwant:\n%+v", got, want)
+			}
+			if gotConn := len(tl.acceptQueue) > 0; gotConn != test.wantConn {
+				t.Errorf("connection created: %v, want %v", gotConn, test.wantConn)
+			}
+			switch {
+			case test.wantClose:
+				tl.wantDatagram("filter refuses connection",
+					initialConnectionCloseDatagram(dstConnID, srcConnID, errConnectionRefused))
+			case test.wantRetry:
+				d := tl.readDatagram()
+				if d == nil || len(d.packets) != 1 || d.packets[0].ptype != packetTypeRetry {
+					t.Fatalf("got datagram %v, want Retry", d)
+				}
+				retry := d.packets[0]
+				tl.writeClientInitial(srcConnID, retry.srcConnID, retry.token)
+				if len(got) != 2 {
+					t.Fatalf("AcceptFilter called %v times, want 2 (once per Initial)", len(got))
+				}
+				if len(tl.acceptQueue) == 0 {
+					t.Errorf("no connection created after validating address")
+				}
+			case !test.wantConn:
+				tl.wantIdle("filter drops Initial")
+			}
+		})
+	}
+}
+
+func TestListenerDeniedSource(t *testing.T) {
+	tl := newTestListener(t, &Config{
+		TLSConfig:     newTestTLSConfig(serverSide),
+		DeniedSources: []netip.Prefix{netip.PrefixFrom(testClientAddr.Addr(), 32)},
+	})
+	tl.writeClientInitial(testPeerConnID(0), testLocalConnID(-1), nil)
+	tl.wantIdle("listener ignores datagram from denied source")
+	if len(tl.acceptQueue) != 0 {
+		t.Errorf("listener created a connection for denied source")
+	}
+	if got, want := tl.l.Stats().DatagramsDenied, uint64(1); got != want {
+		t.Errorf("DatagramsDenied = %v, want %v", got, want)
+	}
+}
+
+func TestListenerDatagramFilter(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		verdict   DatagramVerdict
+		wantConn  bool
+		wantRetry bool
+	}{{
+		name:     "accept",
+		verdict:  DatagramAccept,
+		wantConn: true,
+	}, {
+		name:    "drop",
+		verdict: DatagramDrop,
+	}, {
+		name:      "mark",
+		verdict:   DatagramMark,
+		wantRetry: true,
+	}} {
+		t.Run(test.name, func(t *testing.T) {
+			var gotAddrs []netip.AddrPort
+			tl := newTestListener(t, &Config{
+				TLSConfig: newTestTLSConfig(serverSide),
+				DatagramFilter: func(addr netip.AddrPort, b []byte) DatagramVerdict {
+					gotAddrs = append(gotAddrs, addr)
+					return test.verdict
+				},
+			})
+			srcConnID, dstConnID := testPeerConnID(0), testLocalConnID(-1)
+			tl.writeClientInitial(srcConnID, dstConnID, nil)
+			if len(gotAddrs) != 1 || gotAddrs[0] != testClientAddr {
+				t.Errorf("DatagramFilter called with addresses %v, want [%v]", gotAddrs, testClientAddr)
+			}
+			if gotConn := len(tl.acceptQueue) > 0; gotConn != test.wantConn {
+				t.Errorf("connection created: %v, want %v", gotConn, test.wantConn)
+			}
+			stats := tl.l.Stats()
+			switch {
+			case test.wantRetry:
+				if got, want := stats.DatagramsMarked, uint64(1); got != want {
+					t.Errorf("DatagramsMarked = %v, want %v", got, want)
+				}
+				d := tl.readDatagram()
+				if d == nil || len(d.packets) != 1 || d.packets[0].ptype != packetTypeRetry {
+					t.Fatalf("got datagram %v, want Retry", d)
+				}
+				retry := d.packets[0]
+				tl.writeClientInitial(srcConnID, retry.srcConnID, retry.token)
+				if len(tl.acceptQueue) == 0 {
+					t.Errorf("no connection created after validating address")
+				}
+			case !test.wantConn:
+				tl.wantIdle("filter drops datagram")
+				if got, want := stats.DatagramsFiltered, uint64(1); got != want {
+					t.Errorf("DatagramsFiltered = %v, want %v", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestListenerDialDeniedAddress(t *testing.T) {
+	l := newLocalListener(t, clientSide, &Config{
+		AllowedSources: []netip.Prefix{netip.MustParsePrefix("192.0.2.0/24")},
+	})
+	if _, err := l.Dial(context.Background(), "udp", "127.0.0.1:443"); err == nil {
+		t.Errorf("Dial to address not in AllowedSources succeeded, want error")
+	}
+}
+
+func TestListenerNonQUICDatagram(t *testing.T) {
+	var got [][]byte
+	tl := newTestListener(t, &Config{
+		TLSConfig: newTestTLSConfig(serverSide),
+		NonQUICDatagram: func(l *Listener, b []byte, addr netip.AddrPort) {
+			got = append(got, bytes.Clone(b))
+			l.WriteTo([]byte("reply"), addr)
+		},
+	})
+	// A STUN Binding Request has the first two bits clear.
+	stun := []byte{0x00, 0x01, 0x00, 0x00, 0x21, 0x12, 0xa4, 0x42}
+	tl.write(&datagram{b: stun, addr: testClientAddr})
+	if len(got) != 1 || !bytes.Equal(got[0], stun) {
+		t.Errorf("NonQUICDatagram called with %x, want [%x]", got, stun)
+	}
+	if b := tl.read(); string(b) != "reply" {
+		t.Errorf("sent datagram %q, want %q", b, "reply")
+	}
+	if got, want := tl.l.Stats().DatagramsNotQUIC, uint64(1); got != want {
+		t.Errorf("DatagramsNotQUIC = %v, want %v", got, want)
+	}
+
+	// QUIC packets are not passed to NonQUICDatagram.
+	got = nil
+	tl.writeClientInitial(testPeerConnID(0), testLocalConnID(-1), nil)
+	if len(got) != 0 {
+		t.Errorf("NonQUICDatagram called with QUIC Initial packet")
+	}
+}
+
+func TestListenerDuplicateInitialHandledOffLoop(t *testing.T) {
+	// Two Initial packets for the same connection are handled by an
+	// unknownDestPool worker before the listen loop learns of the conn
+	// created by the first.
+	tl := newTestListener(t, &Config{
+		TLSConfig: newTestTLSConfig(serverSide),
+	})
+	srcConnID, dstConnID := testPeerConnID(0), testLocalConnID(-1)
+	params := defaultTransportParameters()
+	params.initialSrcConnID = srcConnID
+	b := encodeTestPacket(t, nil, &testPacket{
+		ptype:     packetTypeInitial,
+		num:       0,
+		version:   quicVersion1,
+		srcConnID: srcConnID,
+		dstConnID: dstConnID,
+		frames: []debugFrame{
+			debugFrameCrypto{
+				data: initialClientCrypto(t, tl, params),
+			},
+		},
+	}, 0)
+	for len(b) < paddedInitialDatagramSize {
+		b = append(b, 0)
+	}
+	for i := 0; i < 2; i++ {
+		tl.l.handleUnknownDestinationDatagramOffLoop(&datagram{
+			b:    append([]byte(nil), b...),
+			addr: testClientAddr,
+		})
+	}
+	tl.wait()
+	if got := len(tl.conns); got != 1 {
+		t.Errorf("after two Initial packets for one connection: %v conns created, want 1", got)
+	}
+	if got, want := tl.l.Stats().HandshakesStarted, uint64(1); got != want {
+		t.Errorf("HandshakesStarted = %v, want %v", got, want)
+	}
+}
+
+func TestListenerInitialConnRemovedOnClose(t *testing.T) {
+	tl := newTestListener(t, &Config{
+		TLSConfig: newTestTLSConfig(serverSide),
+	})
+	dstConnID := testLocalConnID(-1)
+	tl.writeClientInitial(testPeerConnID(0), dstConnID, nil)
+	tc := tl.accept()
+	if tl.l.initialConn(dstConnID) != tc.conn {
+		t.Fatalf("initialConn(%x) does not return handshaking conn", dstConnID)
+	}
+	tc.conn.Abort(nil)
+	tc.wait()
+	tc.advanceToTimer() // drain timer expires
+	if c := tl.l.initialConn(dstConnID); c != nil {
+		t.Errorf("initialConn(%x) returns conn after it is closed", dstConnID)
+	}
+}
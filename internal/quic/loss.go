@@ -37,6 +37,12 @@ type lossState struct {
 	// https://www.rfc-editor.org/rfc/rfc9002#section-6.2.1-9
 	ptoBackoffCount int
 
+	// Loss detection parameters (Config.PacketThreshold, TimeThreshold,
+	// and MaxPTOBackoff).
+	packetThreshold packetNumber
+	timeThreshold   float64
+	maxPTOBackoff   int
+
 	// Anti-amplification limit: Three times the amount of data received from
 	// the peer, less the amount of data sent.
 	//
@@ -68,13 +74,24 @@ type lossState struct {
 
 const antiAmplificationUnlimited = math.MaxInt
 
-func (c *lossState) init(side connSide, maxDatagramSize int, now time.Time) {
+// Default loss detection thresholds.
+// https://www.rfc-editor.org/rfc/rfc9002.html#section-6.1.1-1
+// https://www.rfc-editor.org/rfc/rfc9002.html#section-6.1.2-2
+const (
+	defaultPacketThreshold = 3
+	defaultTimeThreshold   = 9.0 / 8
+)
+
+func (c *lossState) init(side connSide, maxDatagramSize int, config *Config, now time.Time) {
 	c.side = side
 	if side == clientSide {
 		// Clients don't have an anti-amplification limit.
 		c.antiAmplificationLimit = antiAmplificationUnlimited
 	}
-	c.rtt.init()
+	c.packetThreshold = config.packetThreshold()
+	c.timeThreshold = config.timeThreshold()
+	c.maxPTOBackoff = config.maxPTOBackoff()
+	c.rtt.init(config.initialRTT())
 	c.cc = newReno(maxDatagramSize)
 	c.pacer.init(now, c.cc.congestionWindow, timerGranularity)
 
@@ -309,13 +326,11 @@ func (c *lossState) discardKeys(now time.Time, space numberSpace) {
 
 func (c *lossState) lossDuration() time.Duration {
 	// https://www.rfc-editor.org/rfc/rfc9002.html#section-6.1.2
-	return max((9*max(c.rtt.smoothedRTT, c.rtt.latestRTT))/8, timerGranularity)
+	d := time.Duration(c.timeThreshold * float64(max(c.rtt.smoothedRTT, c.rtt.latestRTT)))
+	return max(d, timerGranularity)
 }
 
 func (c *lossState) detectLoss(now time.Time, lossf func(numberSpace, *sentPacket, packetFate)) {
-	// https://www.rfc-editor.org/rfc/rfc9002.html#section-6.1.1-1
-	const lossThreshold = 3
-
 	lossTime := now.Add(-c.lossDuration())
 	for space := numberSpace(0); space < numberSpaceCount; space++ {
 		for i := 0; i < c.spaces[space].size; i++ {
@@ -329,7 +344,7 @@ func (c *lossState) detectLoss(now time.Time, lossf func(numberSpace, *sentPacke
 			// packets, and the loss algorithm in Appendix A handles loss detection of
 			// not-in-flight packets identically to all others, so we do the same here.
 			switch {
-			case c.spaces[space].maxAcked-sent.num >= lossThreshold:
+			case c.spaces[space].maxAcked-sent.num >= c.packetThreshold:
 				// Packet threshold
 				// https://www.rfc-editor.org/rfc/rfc9002.html#section-6.1.1
 				fallthrough
@@ -432,7 +447,7 @@ func (c *lossState) scheduleTimer(now time.Time) {
 		return
 	}
 	// https://www.rfc-editor.org/rfc/rfc9002.html#section-6.2.1
-	pto := c.ptoBasePeriod() << c.ptoBackoffCount
+	pto := c.ptoBasePeriod() << min(c.ptoBackoffCount, c.maxPTOBackoff)
 	c.timer = last.Add(pto)
 	c.ptoTimerArmed = true
 }
@@ -166,6 +166,18 @@ func TestLossInitialRTTSample(t *testing.T) {
 	test.wantVar("rttvar", 5*time.Millisecond)
 }
 
+func TestLossInitialRTTConfigured(t *testing.T) {
+	test := newLossTest(t, serverSide, lossTestOpts{
+		config: &Config{InitialRTT: 1 * time.Second},
+	})
+	test.wantVar("smoothed_rtt", 1*time.Second)
+	test.wantVar("rttvar", 500*time.Millisecond)
+	test.datagramReceived(1200)
+	test.send(initialSpace, 0)
+	t.Logf("# PTO = smoothed_rtt + max(4*rttvar, 1ms)")
+	test.wantTimeout(3 * time.Second)
+}
+
 func TestLossSmoothedRTTIgnoresMaxAckDelayBeforeHandshakeConfirmed(t *testing.T) {
 	test := newLossTest(t, clientSide, lossTestOpts{})
 	test.setMaxAckDelay(1 * time.Millisecond)
@@ -276,6 +288,20 @@ func TestLossPacketThreshold(t *testing.T) {
 	test.wantLoss(appDataSpace, 0, 1)
 }
 
+func TestLossPacketThresholdConfigured(t *testing.T) {
+	test := newLossTest(t, clientSide, lossTestOpts{
+		config: &Config{PacketThreshold: 5},
+	})
+	t.Logf("# acking a packet 4 packets later does not trigger loss")
+	test.send(appDataSpace, 0, 1, 2, 3, 4, 5, 6)
+	test.ack(appDataSpace, 0*time.Millisecond, i64range[packetNumber]{4, 5})
+	test.wantAck(appDataSpace, 4)
+	t.Logf("# acking a packet 5 packets later triggers loss")
+	test.ack(appDataSpace, 0*time.Millisecond, i64range[packetNumber]{5, 6})
+	test.wantAck(appDataSpace, 5)
+	test.wantLoss(appDataSpace, 0)
+}
+
 func TestLossOutOfOrderAcks(t *testing.T) {
 	test := newLossTest(t, clientSide, lossTestOpts{})
 	t.Logf("# out of order acks, no loss")
@@ -579,6 +605,64 @@ func TestLossPTOBackoffDoubles(t *testing.T) {
 	test.wantNoTimeout()
 }
 
+func TestLossTimeThresholdConfigured(t *testing.T) {
+	test := newLossTest(t, clientSide, lossTestOpts{
+		config: &Config{TimeThreshold: 2},
+	})
+	t.Logf("# first ack establishes smoothed_rtt")
+	test.send(initialSpace, 0)
+	test.advance(10 * time.Millisecond)
+	test.ack(initialSpace, 0*time.Millisecond, i64range[packetNumber]{0, 1})
+	test.wantAck(initialSpace, 0)
+
+	t.Logf("# ack of packet 2 starts loss timer for packet 1")
+	test.send(initialSpace, 1, 2)
+	test.advance(10 * time.Millisecond)
+	test.ack(initialSpace, 0*time.Millisecond, i64range[packetNumber]{2, 3})
+	test.wantAck(initialSpace, 2)
+
+	t.Logf("# timeout = 2 * smoothed_rtt, measured since packet 1 sent")
+	test.wantTimeout(10 * time.Millisecond)
+	test.advanceToLossTimer()
+	test.wantLoss(initialSpace, 1)
+}
+
+func TestLossPTOBackoffLimit(t *testing.T) {
+	test := newLossTest(t, serverSide, lossTestOpts{
+		config: &Config{MaxPTOBackoff: 1},
+	})
+	test.datagramReceived(1200)
+	test.send(initialSpace, 0)
+	test.wantTimeout(999 * time.Millisecond)
+	test.advanceToLossTimer()
+	test.wantPTOExpired()
+
+	t.Logf("# PTO timer doubles")
+	test.send(initialSpace, 1)
+	test.wantTimeout(2 * 999 * time.Millisecond)
+	test.advanceToLossTimer()
+	test.wantPTOExpired()
+
+	t.Logf("# PTO timer does not double past MaxPTOBackoff")
+	test.send(initialSpace, 2)
+	test.wantTimeout(2 * 999 * time.Millisecond)
+	test.advanceToLossTimer()
+	test.wantPTOExpired()
+}
+
+func TestLossPTOBackoffDisabled(t *testing.T) {
+	test := newLossTest(t, serverSide, lossTestOpts{
+		config: &Config{MaxPTOBackoff: -1},
+	})
+	test.datagramReceived(1200)
+	for i := 0; i < 3; i++ {
+		test.send(initialSpace, i)
+		test.wantTimeout(999 * time.Millisecond)
+		test.advanceToLossTimer()
+		test.wantPTOExpired()
+	}
+}
+
 func TestLossPTOBackoffResetOnAck(t *testing.T) {
 	// "The PTO backoff factor is reset when an acknowledgment is received [...]"
 	// https://www.rfc-editor.org/rfc/rfc9002.html#section-6.2.1-9
@@ -1325,6 +1409,7 @@ type lossTest struct {
 
 type lossTestOpts struct {
 	maxDatagramSize int
+	config          *Config
 }
 
 func newLossTest(t *testing.T, side connSide, opts lossTestOpts) *lossTest {
@@ -1337,7 +1422,11 @@ func newLossTest(t *testing.T, side connSide, opts lossTestOpts) *lossTest {
 	if opts.maxDatagramSize != 0 {
 		maxDatagramSize = opts.maxDatagramSize
 	}
-	c.c.init(side, maxDatagramSize, c.now)
+	config := opts.config
+	if config == nil {
+		config = &Config{}
+	}
+	c.c.init(side, maxDatagramSize, config, c.now)
 	t.Cleanup(func() {
 		if !c.failed {
 			c.checkUnexpectedEvents()
@@ -0,0 +1,233 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"time"
+)
+
+// NetListener returns a net.Listener which accepts connections from l.
+//
+// Each net.Conn returned by the net.Listener's Accept method is
+// the first bidirectional stream created by the peer of a new connection.
+// Unidirectional streams created by the peer before its first bidirectional stream
+// are closed for reading, and any streams created after it are ignored.
+// Closing the net.Conn closes the stream and the connection.
+//
+// Closing the net.Listener does not close l, but aborts any connections
+// which have been accepted from l and not yet returned by Accept.
+func (l *Listener) NetListener() net.Listener {
+	ctx, cancel := context.WithCancel(context.Background())
+	nl := &netListener{
+		l:      l,
+		ctx:    ctx,
+		cancel: cancel,
+		connc:  make(chan *streamConn),
+		donec:  make(chan struct{}),
+	}
+	go nl.acceptConns()
+	return nl
+}
+
+type netListener struct {
+	l      *Listener
+	ctx    context.Context // canceled when the netListener is closed
+	cancel context.CancelFunc
+	connc  chan *streamConn
+
+	donec chan struct{} // closed when acceptConns exits
+	err   error         // error from Listener.Accept, set before donec is closed
+}
+
+// acceptConns accepts connections from the Listener,
+// and starts a goroutine to wait for each one's first bidirectional stream.
+func (nl *netListener) acceptConns() {
+	defer close(nl.donec)
+	for {
+		c, err := nl.l.Accept(nl.ctx)
+		if err != nil {
+			nl.err = err
+			return
+		}
+		go nl.acceptStream(c)
+	}
+}
+
+func (nl *netListener) acceptStream(c *Conn) {
+	for {
+		s, err := c.AcceptStream(nl.ctx)
+		if err != nil {
+			c.Abort(nil)
+			return
+		}
+		if s.IsReadOnly() {
+			s.CloseRead()
+			continue
+		}
+		select {
+		case nl.connc <- newStreamConn(c, s):
+		case <-nl.ctx.Done():
+			c.Abort(nil)
+		}
+		return
+	}
+}
+
+func (nl *netListener) Accept() (net.Conn, error) {
+	select {
+	case <-nl.ctx.Done():
+		return nil, nl.opError(net.ErrClosed)
+	default:
+	}
+	select {
+	case sc := <-nl.connc:
+		return sc, nil
+	case <-nl.ctx.Done():
+		return nil, nl.opError(net.ErrClosed)
+	case <-nl.donec:
+		return nil, nl.opError(nl.err)
+	}
+}
+
+func (nl *netListener) Close() error {
+	nl.cancel()
+	return nil
+}
+
+func (nl *netListener) Addr() net.Addr {
+	return net.UDPAddrFromAddrPort(nl.l.LocalAddr())
+}
+
+func (nl *netListener) opError(err error) error {
+	return &net.OpError{
+		Op:   "accept",
+		Net:  "quic",
+		Addr: nl.Addr(),
+		Err:  err,
+	}
+}
+
+// A streamConn is a net.Conn which reads from and writes to a single Stream.
+type streamConn struct {
+	c *Conn
+	s *Stream
+
+	readDeadline  ioDeadline
+	writeDeadline ioDeadline
+}
+
+func newStreamConn(c *Conn, s *Stream) *streamConn {
+	sc := &streamConn{
+		c: c,
+		s: s,
+	}
+	sc.readDeadline.init()
+	sc.writeDeadline.init()
+	return sc
+}
+
+func (sc *streamConn) Read(b []byte) (n int, err error) {
+	for {
+		ctx, changed := sc.readDeadline.context()
+		n, err = sc.s.ReadContext(ctx, b)
+		if err == nil || err == io.EOF {
+			return n, err
+		}
+		if n > 0 || ctx.Err() == nil {
+			return n, sc.opError("read", err)
+		}
+		select {
+		case <-changed:
+			// The deadline was changed while we were waiting; try again.
+			continue
+		default:
+		}
+		return 0, sc.opError("read", os.ErrDeadlineExceeded)
+	}
+}
+
+func (sc *streamConn) Write(b []byte) (n int, err error) {
+	for {
+		ctx, changed := sc.writeDeadline.context()
+		var nn int
+		nn, err = sc.s.WriteContext(ctx, b[n:])
+		n += nn
+		if err == nil {
+			return n, nil
+		}
+		if ctx.Err() == nil {
+			return n, sc.opError("write", err)
+		}
+		select {
+		case <-changed:
+			// The deadline was changed while we were waiting; try again.
+			continue
+		default:
+		}
+		return n, sc.opError("write", os.ErrDeadlineExceeded)
+	}
+}
+
+// Close closes the stream, waiting until the peer has received
+// all data written to it or the write deadline expires,
+// and then closes the connection.
+func (sc *streamConn) Close() error {
+	ctx, _ := sc.writeDeadline.context()
+	err := sc.s.CloseContext(ctx)
+	sc.c.Abort(nil)
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = os.ErrDeadlineExceeded
+	}
+	if err != nil {
+		return sc.opError("close", err)
+	}
+	return nil
+}
+
+func (sc *streamConn) LocalAddr() net.Addr {
+	return net.UDPAddrFromAddrPort(sc.c.LocalAddr())
+}
+
+func (sc *streamConn) RemoteAddr() net.Addr {
+	return net.UDPAddrFromAddrPort(sc.c.RemoteAddr())
+}
+
+func (sc *streamConn) SetDeadline(t time.Time) error {
+	sc.readDeadline.set(t)
+	sc.writeDeadline.set(t)
+	return nil
+}
+
+func (sc *streamConn) SetReadDeadline(t time.Time) error {
+	sc.readDeadline.set(t)
+	return nil
+}
+
+func (sc *streamConn) SetWriteDeadline(t time.Time) error {
+	sc.writeDeadline.set(t)
+	return nil
+}
+
+func (sc *streamConn) opError(op string, err error) error {
+	return &net.OpError{
+		Op:     op,
+		Net:    "quic",
+		Source: sc.LocalAddr(),
+		Addr:   sc.RemoteAddr(),
+		Err:    err,
+	}
+}
+
+var (
+	_ net.Listener = (*netListener)(nil)
+	_ net.Conn     = (*streamConn)(nil)
+)
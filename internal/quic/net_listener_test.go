@@ -0,0 +1,125 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestNetListener(t *testing.T) {
+	ctx := context.Background()
+	srvl := newLocalListener(t, serverSide, &Config{})
+	nl := srvl.NetListener()
+	defer nl.Close()
+	if got, want := nl.Addr().String(), srvl.LocalAddr().String(); got != want {
+		t.Errorf("Addr() = %v, want %v", got, want)
+	}
+
+	clil := newLocalListener(t, clientSide, &Config{})
+	c, err := clil.Dial(ctx, "udp", srvl.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A unidirectional stream created before the first bidirectional stream
+	// is not returned by the net.Listener.
+	us, err := c.NewSendOnlyStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	us.Write([]byte("ignored"))
+	s, err := c.NewStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	s.CloseWrite()
+
+	sc, err := nl.Accept()
+	if err != nil {
+		t.Fatalf("Accept: %v", err)
+	}
+	sc.SetDeadline(time.Now().Add(10 * time.Second))
+	got, err := io.ReadAll(sc)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if string(got) != "hello" {
+		t.Errorf("read %q, want %q", got, "hello")
+	}
+	if _, err := sc.Write([]byte("goodbye")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := sc.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	got, err = io.ReadAll(s)
+	if err != nil {
+		t.Fatalf("client ReadAll: %v", err)
+	}
+	if string(got) != "goodbye" {
+		t.Errorf("client read %q, want %q", got, "goodbye")
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if err := c.Wait(waitCtx); errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("client conn.Wait: %v, want server to close conn", err)
+	}
+}
+
+func TestNetListenerClose(t *testing.T) {
+	srvl := newLocalListener(t, serverSide, &Config{})
+	nl := srvl.NetListener()
+	errc := make(chan error)
+	go func() {
+		_, err := nl.Accept()
+		errc <- err
+	}()
+	nl.Close()
+	if err := <-errc; !errors.Is(err, net.ErrClosed) {
+		t.Fatalf("Accept after Close: %v, want net.ErrClosed", err)
+	}
+}
+
+func TestNetListenerConnReadDeadline(t *testing.T) {
+	ctx := context.Background()
+	srvl := newLocalListener(t, serverSide, &Config{})
+	nl := srvl.NetListener()
+	defer nl.Close()
+	clil := newLocalListener(t, clientSide, &Config{})
+	c, err := clil.Dial(ctx, "udp", srvl.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := c.NewStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Write([]byte("x"))
+
+	sc, err := nl.Accept()
+	if err != nil {
+		t.Fatalf("Accept: %v", err)
+	}
+	defer sc.Close()
+	buf := make([]byte, 10)
+	if _, err := io.ReadFull(sc, buf[:1]); err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	sc.SetReadDeadline(time.Now().Add(-1 * time.Second))
+	if _, err := sc.Read(buf); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("Read after deadline: %v, want os.ErrDeadlineExceeded", err)
+	}
+}
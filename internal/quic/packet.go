@@ -37,6 +37,8 @@ func (p packetType) String() string {
 		return "Retry"
 	case packetType1RTT:
 		return "1-RTT"
+	case packetTypeVersionNegotiation:
+		return "Version Negotiation"
 	}
 	return fmt.Sprintf("unknown packet type %v", byte(p))
 }
@@ -49,9 +51,11 @@ const (
 	reservedLongBits = 0x0c // https://www.rfc-editor.org/rfc/rfc9000#section-17.2-8.2.1
 	reserved1RTTBits = 0x18 // https://www.rfc-editor.org/rfc/rfc9000#section-17.3.1-4.8.1
 	keyPhaseBit      = 0x04 // https://www.rfc-editor.org/rfc/rfc9000#section-17.3.1-4.10.1
+	spinBit          = 0x20 // https://www.rfc-editor.org/rfc/rfc9000#section-17.3.1-4.6.1
 )
 
-// Long Packet Type bits.
+// Long Packet Type bits in QUIC version 1.
+// Other versions may assign these differently; see versionParams.
 // https://www.rfc-editor.org/rfc/rfc9000.html#section-17.2-3.6.1
 const (
 	longPacketTypeInitial   = 0 << 4
@@ -87,6 +91,10 @@ const (
 	frameTypeConnectionCloseTransport   = 0x1c
 	frameTypeConnectionCloseApplication = 0x1d
 	frameTypeHandshakeDone              = 0x1e
+	frameTypeImmediateAck               = 0x1f // draft-ietf-quic-ack-frequency
+	frameTypeDatagram                   = 0x30 // RFC 9221
+	frameTypeDatagramWithLength         = 0x31
+	frameTypeAckFrequency               = 0xaf // draft-ietf-quic-ack-frequency
 )
 
 // The low three bits of STREAM frames.
@@ -126,17 +134,21 @@ func getPacketType(b []byte) packetType {
 	if b[0]&fixedBit != fixedBit {
 		return packetTypeInvalid
 	}
-	switch b[0] & 0x30 {
-	case longPacketTypeInitial:
-		return packetTypeInitial
-	case longPacketType0RTT:
-		return packetType0RTT
-	case longPacketTypeHandshake:
-		return packetTypeHandshake
-	case longPacketTypeRetry:
-		return packetTypeRetry
-	}
-	return packetTypeInvalid
+	vp := headerParamsForVersion(binary.BigEndian.Uint32(b[1:]))
+	return vp.packetType(b[0])
+}
+
+// isQUICDatagram reports whether a datagram appears to contain a QUIC packet,
+// according to the checks in RFC 9443, Section 5: The fixed bit is set,
+// or the datagram contains a Version Negotiation packet.
+func isQUICDatagram(b []byte) bool {
+	if len(b) == 0 {
+		return false
+	}
+	if b[0]&fixedBit == fixedBit {
+		return true
+	}
+	return getPacketType(b) == packetTypeVersionNegotiation
 }
 
 // dstConnIDForDatagram returns the destination connection ID field of the
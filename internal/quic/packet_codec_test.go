@@ -17,7 +17,7 @@ func TestParseLongHeaderPacket(t *testing.T) {
 	// Example Initial packet from:
 	// https://www.rfc-editor.org/rfc/rfc9001.html#section-a.3
 	cid := unhex(`8394c8f03e515708`)
-	initialServerKeys := initialKeys(cid, clientSide).r
+	initialServerKeys := initialKeys(version1Params, cid, clientSide).r
 	pkt := unhex(`
 		cf000000010008f067a5502a4262b500 4075c0d95a482cd0991cd25b0aac406a
 		5816b6394100f37a1c69797554780bb3 8cc5a99f5ede4cf73c3ec2493a1839b3
@@ -65,7 +65,7 @@ func TestParseLongHeaderPacket(t *testing.T) {
 	}
 
 	// Parse with the wrong keys.
-	invalidKeys := initialKeys([]byte{}, clientSide).w
+	invalidKeys := initialKeys(version1Params, []byte{}, clientSide).w
 	if _, n := parseLongHeaderPacket(pkt, invalidKeys, 0); n != -1 {
 		t.Fatalf("parse long header packet with wrong keys: n=%v, want -1", n)
 	}
@@ -73,9 +73,9 @@ func TestParseLongHeaderPacket(t *testing.T) {
 
 func TestRoundtripEncodeLongPacket(t *testing.T) {
 	var aes128Keys, aes256Keys, chachaKeys fixedKeys
-	aes128Keys.init(tls.TLS_AES_128_GCM_SHA256, []byte("secret"))
-	aes256Keys.init(tls.TLS_AES_256_GCM_SHA384, []byte("secret"))
-	chachaKeys.init(tls.TLS_CHACHA20_POLY1305_SHA256, []byte("secret"))
+	aes128Keys.init(version1Params, tls.TLS_AES_128_GCM_SHA256, []byte("secret"))
+	aes256Keys.init(version1Params, tls.TLS_AES_256_GCM_SHA384, []byte("secret"))
+	chachaKeys.init(version1Params, tls.TLS_CHACHA20_POLY1305_SHA256, []byte("secret"))
 	for _, test := range []struct {
 		desc string
 		p    longPacket
@@ -147,9 +147,9 @@ func TestRoundtripEncodeLongPacket(t *testing.T) {
 
 func TestRoundtripEncodeShortPacket(t *testing.T) {
 	var aes128Keys, aes256Keys, chachaKeys updatingKeyPair
-	aes128Keys.r.init(tls.TLS_AES_128_GCM_SHA256, []byte("secret"))
-	aes256Keys.r.init(tls.TLS_AES_256_GCM_SHA384, []byte("secret"))
-	chachaKeys.r.init(tls.TLS_CHACHA20_POLY1305_SHA256, []byte("secret"))
+	aes128Keys.r.init(version1Params, tls.TLS_AES_128_GCM_SHA256, []byte("secret"))
+	aes256Keys.r.init(version1Params, tls.TLS_AES_256_GCM_SHA384, []byte("secret"))
+	chachaKeys.r.init(version1Params, tls.TLS_CHACHA20_POLY1305_SHA256, []byte("secret"))
 	aes128Keys.w = aes128Keys.r
 	aes256Keys.w = aes256Keys.r
 	chachaKeys.w = chachaKeys.r
@@ -191,7 +191,7 @@ func TestRoundtripEncodeShortPacket(t *testing.T) {
 			w.reset(1200)
 			w.start1RTTPacket(test.num, 0, connID)
 			w.b = append(w.b, test.payload...)
-			w.finish1RTTPacket(test.num, 0, connID, &test.k)
+			w.finish1RTTPacket(test.num, 0, connID, false, &test.k)
 			pkt := w.datagram()
 			p, err := parse1RTTPacket(pkt, &test.k, connIDLen, 0)
 			if err != nil {
@@ -530,12 +530,28 @@ func TestFrameEncodeDecode(t *testing.T) {
 			0x04,               // Reason Phrase Length (i),
 			'o', 'o', 'p', 's', // Reason Phrase (..),
 		},
+		truncated: []byte{
+			0x1d,          // Type (i) = 0x1c..0x1d,
+			0x01,          // Error Code (i),
+			0x03,          // Reason Phrase Length (i),
+			'o', 'o', 'p', // Reason Phrase (..),
+		},
 	}, {
 		s: "HANDSHAKE_DONE",
 		f: debugFrameHandshakeDone{},
 		b: []byte{
 			0x1e, // Type (i) = 0x1e,
 		},
+	}, {
+		s: "DATAGRAM Data=0102",
+		f: debugFrameDatagram{
+			data: []byte{1, 2},
+		},
+		b: []byte{
+			0x31,       // Type (i) = 0x30..0x31,
+			0x02,       // Length (i),
+			0x01, 0x02, // Datagram Data (..),
+		},
 	}} {
 		var w packetWriter
 		w.reset(1200)
@@ -708,7 +724,7 @@ func TestFrameDecodeErrors(t *testing.T) {
 
 func FuzzParseLongHeaderPacket(f *testing.F) {
 	cid := unhex(`0000000000000000`)
-	initialServerKeys := initialKeys(cid, clientSide).r
+	initialServerKeys := initialKeys(version1Params, cid, clientSide).r
 	f.Fuzz(func(t *testing.T, in []byte) {
 		parseLongHeaderPacket(in, initialServerKeys, 0)
 	})
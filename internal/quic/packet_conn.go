@@ -0,0 +1,175 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"context"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// PacketConn returns a net.PacketConn which sends and receives
+// the Conn's unreliable datagrams, as SendDatagram and ReceiveDatagram do.
+// DATAGRAM frames must be enabled with Config.MaxDatagramFrameSize.
+//
+// Every packet is sent to and received from the Conn's peer.
+// ReadFrom always reports the peer's address, and WriteTo ignores its address.
+// Closing the PacketConn does not close the Conn.
+func (c *Conn) PacketConn() net.PacketConn {
+	pc := &packetConn{
+		c:      c,
+		closec: make(chan struct{}),
+	}
+	pc.readDeadline.init()
+	pc.writeDeadline.init()
+	return pc
+}
+
+type packetConn struct {
+	c *Conn
+
+	closeOnce sync.Once
+	closec    chan struct{}
+
+	readDeadline  ioDeadline
+	writeDeadline ioDeadline
+}
+
+func (pc *packetConn) ReadFrom(p []byte) (n int, addr net.Addr, err error) {
+	for {
+		ctx, changed := pc.readDeadline.context()
+		var b []byte
+		b, err = pc.c.ReceiveDatagram(ctx)
+		if err == nil {
+			// As with UDP, excess bytes are discarded.
+			return copy(p, b), pc.RemoteAddr(), nil
+		}
+		select {
+		case <-pc.closec:
+			return 0, nil, pc.opError("read", net.ErrClosed)
+		default:
+		}
+		if ctx.Err() == nil {
+			return 0, nil, pc.opError("read", err)
+		}
+		select {
+		case <-changed:
+			// The deadline was changed while we were waiting; try again.
+			continue
+		default:
+		}
+		return 0, nil, pc.opError("read", os.ErrDeadlineExceeded)
+	}
+}
+
+func (pc *packetConn) WriteTo(p []byte, addr net.Addr) (n int, err error) {
+	select {
+	case <-pc.closec:
+		return 0, pc.opError("write", net.ErrClosed)
+	default:
+	}
+	// SendDatagram never blocks, so the write deadline only matters
+	// if it has already expired.
+	if ctx, _ := pc.writeDeadline.context(); ctx.Err() != nil {
+		return 0, pc.opError("write", os.ErrDeadlineExceeded)
+	}
+	if err := pc.c.SendDatagram(p); err != nil {
+		return 0, pc.opError("write", err)
+	}
+	return len(p), nil
+}
+
+func (pc *packetConn) Close() error {
+	pc.closeOnce.Do(func() {
+		close(pc.closec)
+		pc.readDeadline.close()
+		pc.writeDeadline.close()
+	})
+	return nil
+}
+
+func (pc *packetConn) LocalAddr() net.Addr {
+	return net.UDPAddrFromAddrPort(pc.c.LocalAddr())
+}
+
+// RemoteAddr returns the address of the Conn's peer.
+func (pc *packetConn) RemoteAddr() net.Addr {
+	return net.UDPAddrFromAddrPort(pc.c.RemoteAddr())
+}
+
+func (pc *packetConn) SetDeadline(t time.Time) error {
+	pc.readDeadline.set(t)
+	pc.writeDeadline.set(t)
+	return nil
+}
+
+func (pc *packetConn) SetReadDeadline(t time.Time) error {
+	pc.readDeadline.set(t)
+	return nil
+}
+
+func (pc *packetConn) SetWriteDeadline(t time.Time) error {
+	pc.writeDeadline.set(t)
+	return nil
+}
+
+func (pc *packetConn) opError(op string, err error) error {
+	return &net.OpError{
+		Op:     op,
+		Net:    "quic",
+		Source: pc.LocalAddr(),
+		Addr:   pc.RemoteAddr(),
+		Err:    err,
+	}
+}
+
+// An ioDeadline is a deadline for I/O operations on a net.Conn or net.PacketConn.
+// Changing the deadline cancels the context of in-progress operations,
+// which then resume with the new deadline.
+type ioDeadline struct {
+	mu      sync.Mutex
+	ctx     context.Context
+	cancel  context.CancelFunc
+	changed chan struct{} // closed when the deadline is changed
+}
+
+func (d *ioDeadline) init() {
+	d.ctx, d.cancel = context.WithCancel(context.Background())
+	d.changed = make(chan struct{})
+}
+
+// context returns a context which is done when the deadline expires,
+// and a channel which is closed when the deadline is changed.
+func (d *ioDeadline) context() (context.Context, <-chan struct{}) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.ctx, d.changed
+}
+
+func (d *ioDeadline) set(t time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	close(d.changed)
+	d.cancel()
+	d.changed = make(chan struct{})
+	if t.IsZero() {
+		d.ctx, d.cancel = context.WithCancel(context.Background())
+	} else {
+		d.ctx, d.cancel = context.WithDeadline(context.Background(), t)
+	}
+}
+
+// close cancels in-progress operations.
+func (d *ioDeadline) close() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.cancel()
+}
+
+var _ net.PacketConn = (*packetConn)(nil)
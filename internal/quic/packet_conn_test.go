@@ -0,0 +1,81 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"errors"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+func newLocalPacketConnPair(t *testing.T) (cli, srv net.PacketConn) {
+	t.Helper()
+	c1, c2 := newLocalConnPair(t,
+		&Config{MaxDatagramFrameSize: 65535},
+		&Config{MaxDatagramFrameSize: 65535})
+	return c1.PacketConn(), c2.PacketConn()
+}
+
+func TestPacketConnReadWrite(t *testing.T) {
+	cli, srv := newLocalPacketConnPair(t)
+	want := "hello"
+	if _, err := cli.WriteTo([]byte(want), srv.LocalAddr()); err != nil {
+		t.Fatalf("WriteTo: %v", err)
+	}
+	srv.SetReadDeadline(time.Now().Add(10 * time.Second))
+	buf := make([]byte, 100)
+	n, addr, err := srv.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("ReadFrom: %v", err)
+	}
+	if got := string(buf[:n]); got != want {
+		t.Errorf("ReadFrom read %q, want %q", got, want)
+	}
+	if got, want := addr.String(), cli.LocalAddr().String(); got != want {
+		t.Errorf("ReadFrom addr = %v, want %v", got, want)
+	}
+}
+
+func TestPacketConnReadDeadline(t *testing.T) {
+	_, srv := newLocalPacketConnPair(t)
+	srv.SetReadDeadline(time.Now().Add(-1 * time.Second))
+	_, _, err := srv.ReadFrom(make([]byte, 100))
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("ReadFrom after deadline: %v, want os.ErrDeadlineExceeded", err)
+	}
+
+	// Extending the deadline during a read does not interrupt it.
+	srv.SetReadDeadline(time.Now().Add(1 * time.Hour))
+	errc := make(chan error)
+	go func() {
+		_, _, err := srv.ReadFrom(make([]byte, 100))
+		errc <- err
+	}()
+	srv.SetReadDeadline(time.Now().Add(2 * time.Hour))
+	srv.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+	if err := <-errc; !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("ReadFrom after deadline set during read: %v, want os.ErrDeadlineExceeded", err)
+	}
+}
+
+func TestPacketConnClose(t *testing.T) {
+	cli, srv := newLocalPacketConnPair(t)
+	errc := make(chan error)
+	go func() {
+		_, _, err := srv.ReadFrom(make([]byte, 100))
+		errc <- err
+	}()
+	srv.Close()
+	if err := <-errc; !errors.Is(err, net.ErrClosed) {
+		t.Errorf("ReadFrom during Close: %v, want net.ErrClosed", err)
+	}
+	if _, err := srv.WriteTo([]byte{0}, cli.LocalAddr()); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("WriteTo after Close: %v, want net.ErrClosed", err)
+	}
+}
@@ -6,6 +6,8 @@
 
 package quic
 
+import "time"
+
 // parseLongHeaderPacket parses a QUIC long header packet.
 //
 // It does not parse Version Negotiation packets.
@@ -502,6 +504,52 @@ func consumeConnectionCloseTransportFrame(b []byte) (code transportError, frameT
 	return code, frameType, reason, n
 }
 
+func consumeDatagramFrame(b []byte) (data []byte, n int) {
+	if b[0] == frameTypeDatagram {
+		// Without a Length field, the frame extends to the end of the packet.
+		return b[1:], len(b)
+	}
+	n = 1
+	data, nn := consumeVarintBytes(b[n:])
+	if nn < 0 {
+		return nil, -1
+	}
+	n += nn
+	return data, n
+}
+
+func consumeAckFrequencyFrame(b []byte) (seq, ackElicitingThreshold int64, maxAckDelay time.Duration, reorderingThreshold int64, n int) {
+	_, n = consumeVarint(b) // frame type
+	if n < 0 {
+		return 0, 0, 0, 0, -1
+	}
+	var nn int
+	seq, nn = consumeVarintInt64(b[n:])
+	if nn < 0 {
+		return 0, 0, 0, 0, -1
+	}
+	n += nn
+	ackElicitingThreshold, nn = consumeVarintInt64(b[n:])
+	if nn < 0 {
+		return 0, 0, 0, 0, -1
+	}
+	n += nn
+	// Requested Max Ack Delay is in microseconds.
+	// Clamp it to avoid overflowing a time.Duration.
+	delay, nn := consumeVarint(b[n:])
+	if nn < 0 {
+		return 0, 0, 0, 0, -1
+	}
+	n += nn
+	maxAckDelay = time.Duration(min(delay, 1<<32)) * time.Microsecond
+	reorderingThreshold, nn = consumeVarintInt64(b[n:])
+	if nn < 0 {
+		return 0, 0, 0, 0, -1
+	}
+	n += nn
+	return seq, ackElicitingThreshold, maxAckDelay, reorderingThreshold, n
+}
+
 func consumeConnectionCloseApplicationFrame(b []byte) (code uint64, reason string, n int) {
 	n = 1
 	var nn int
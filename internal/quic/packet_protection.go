@@ -41,9 +41,9 @@ func (k headerKey) isSet() bool {
 	return k.hp != nil
 }
 
-func (k *headerKey) init(suite uint16, secret []byte) {
+func (k *headerKey) init(vp *versionParams, suite uint16, secret []byte) {
 	h, keySize := hashForSuite(suite)
-	hpKey := hkdfExpandLabel(h.New, secret, "quic hp", nil, keySize)
+	hpKey := hkdfExpandLabel(h.New, secret, vp.hpLabel, nil, keySize)
 	switch suite {
 	case tls.TLS_AES_128_GCM_SHA256, tls.TLS_AES_256_GCM_SHA384:
 		c, err := aes.NewCipher(hpKey)
@@ -150,10 +150,10 @@ type packetKey struct {
 	iv   []byte      // IV used to construct the AEAD nonce.
 }
 
-func (k *packetKey) init(suite uint16, secret []byte) {
+func (k *packetKey) init(vp *versionParams, suite uint16, secret []byte) {
 	// https://www.rfc-editor.org/rfc/rfc9001#section-5.1
 	h, keySize := hashForSuite(suite)
-	key := hkdfExpandLabel(h.New, secret, "quic key", nil, keySize)
+	key := hkdfExpandLabel(h.New, secret, vp.keyLabel, nil, keySize)
 	switch suite {
 	case tls.TLS_AES_128_GCM_SHA256, tls.TLS_AES_256_GCM_SHA384:
 		k.aead = newAESAEAD(key)
@@ -162,7 +162,7 @@ func (k *packetKey) init(suite uint16, secret []byte) {
 	default:
 		panic("BUG: unknown cipher suite")
 	}
-	k.iv = hkdfExpandLabel(h.New, secret, "quic iv", nil, k.aead.NonceSize())
+	k.iv = hkdfExpandLabel(h.New, secret, vp.ivLabel, nil, k.aead.NonceSize())
 }
 
 func newAESAEAD(key []byte) cipher.AEAD {
@@ -219,9 +219,9 @@ type fixedKeys struct {
 	pkt packetKey
 }
 
-func (k *fixedKeys) init(suite uint16, secret []byte) {
-	k.hdr.init(suite, secret)
-	k.pkt.init(suite, secret)
+func (k *fixedKeys) init(vp *versionParams, suite uint16, secret []byte) {
+	k.hdr.init(vp, suite, secret)
+	k.pkt.init(vp, suite, secret)
 }
 
 func (k fixedKeys) isSet() bool {
@@ -284,17 +284,19 @@ func (k *fixedKeyPair) canWrite() bool {
 // over the lifetime of a connection.
 // https://www.rfc-editor.org/rfc/rfc9001#section-6
 type updatingKeys struct {
+	vp         *versionParams
 	suite      uint16
 	hdr        headerKey
 	pkt        [2]packetKey // current, next
 	nextSecret []byte       // secret used to generate pkt[1]
 }
 
-func (k *updatingKeys) init(suite uint16, secret []byte) {
+func (k *updatingKeys) init(vp *versionParams, suite uint16, secret []byte) {
+	k.vp = vp
 	k.suite = suite
-	k.hdr.init(suite, secret)
+	k.hdr.init(vp, suite, secret)
 	// Initialize pkt[1] with secret_0, and then call update to generate secret_1.
-	k.pkt[1].init(suite, secret)
+	k.pkt[1].init(vp, suite, secret)
 	k.nextSecret = secret
 	k.update()
 }
@@ -304,14 +306,14 @@ func (k *updatingKeys) init(suite uint16, secret []byte) {
 // The next key in pkt[1] becomes the current key.
 // A new next key is generated in pkt[1].
 func (k *updatingKeys) update() {
-	k.nextSecret = updateSecret(k.suite, k.nextSecret)
+	k.nextSecret = updateSecret(k.vp, k.suite, k.nextSecret)
 	k.pkt[0] = k.pkt[1]
-	k.pkt[1].init(k.suite, k.nextSecret)
+	k.pkt[1].init(k.vp, k.suite, k.nextSecret)
 }
 
-func updateSecret(suite uint16, secret []byte) (nextSecret []byte) {
+func updateSecret(vp *versionParams, suite uint16, secret []byte) (nextSecret []byte) {
 	h, _ := hashForSuite(suite)
-	return hkdfExpandLabel(h.New, secret, "quic ku", nil, len(secret))
+	return hkdfExpandLabel(h.New, secret, vp.kuLabel, nil, len(secret))
 }
 
 // An updatingKeyPair is a read/write pair of updating keys.
@@ -342,6 +344,7 @@ type updatingKeyPair struct {
 	minSent      packetNumber // min packet number sent since entering the updating state
 	minReceived  packetNumber // min packet number received in the next phase
 	updateAfter  packetNumber // packet number after which to initiate key update
+	updates      int64        // number of completed key updates
 	r, w         updatingKeys
 }
 
@@ -369,6 +372,7 @@ func (k *updatingKeyPair) handleAckFor(pnum packetNumber) {
 		k.phase ^= keyPhaseBit
 		k.r.update()
 		k.w.update()
+		k.updates++
 	}
 }
 
@@ -464,23 +468,20 @@ func aeadIntegrityLimit(suite uint16) int64 {
 	}
 }
 
-// https://www.rfc-editor.org/rfc/rfc9001#section-5.2-2
-var initialSalt = []byte{0x38, 0x76, 0x2c, 0xf7, 0xf5, 0x59, 0x34, 0xb3, 0x4d, 0x17, 0x9a, 0xe6, 0xa4, 0xc8, 0x0c, 0xad, 0xcc, 0xbb, 0x7f, 0x0a}
-
 // initialKeys returns the keys used to protect Initial packets.
 //
 // The Initial packet keys are derived from the Destination Connection ID
 // field in the client's first Initial packet.
 //
 // https://www.rfc-editor.org/rfc/rfc9001#section-5.2
-func initialKeys(cid []byte, side connSide) fixedKeyPair {
-	initialSecret := hkdf.Extract(sha256.New, cid, initialSalt)
+func initialKeys(vp *versionParams, cid []byte, side connSide) fixedKeyPair {
+	initialSecret := hkdf.Extract(sha256.New, cid, vp.initialSalt)
 	var clientKeys fixedKeys
 	clientSecret := hkdfExpandLabel(sha256.New, initialSecret, "client in", nil, sha256.Size)
-	clientKeys.init(tls.TLS_AES_128_GCM_SHA256, clientSecret)
+	clientKeys.init(vp, tls.TLS_AES_128_GCM_SHA256, clientSecret)
 	var serverKeys fixedKeys
 	serverSecret := hkdfExpandLabel(sha256.New, initialSecret, "server in", nil, sha256.Size)
-	serverKeys.init(tls.TLS_AES_128_GCM_SHA256, serverSecret)
+	serverKeys.init(vp, tls.TLS_AES_128_GCM_SHA256, serverSecret)
 	if side == clientSide {
 		return fixedKeyPair{r: serverKeys, w: clientKeys}
 	} else {
@@ -16,7 +16,7 @@ func TestPacketProtection(t *testing.T) {
 	// Test cases from:
 	// https://www.rfc-editor.org/rfc/rfc9001#section-appendix.a
 	cid := unhex(`8394c8f03e515708`)
-	k := initialKeys(cid, clientSide)
+	k := initialKeys(version1Params, cid, clientSide)
 	initialClientKeys, initialServerKeys := k.w, k.r
 	for _, test := range []struct {
 		name string
@@ -110,7 +110,7 @@ func TestPacketProtection(t *testing.T) {
 				5443f18203a07d6060f688f30f21632b
 			`)
 			var k fixedKeys
-			k.init(tls.TLS_CHACHA20_POLY1305_SHA256, secret)
+			k.init(version1Params, tls.TLS_CHACHA20_POLY1305_SHA256, secret)
 			return k
 		}(),
 		pnum: 654360564,
@@ -119,6 +119,45 @@ func TestPacketProtection(t *testing.T) {
 		prot: unhex(`
 			4cfe4189655e5cd55c41f69080575d79 99c25a5bfb
 		`),
+	}, {
+		// https://www.rfc-editor.org/rfc/rfc9369#section-a.3
+		name: "Server Initial (QUIC v2)",
+		k:    initialKeys(version2Params, cid, clientSide).r,
+		pnum: 1,
+		hdr: unhex(`
+			d16b3343cf0008f067a5502a4262b500 40750001
+		`),
+		pay: unhex(`
+			02000000000600405a020000560303ee fce7f7b37ba1d1632e96677825ddf739
+			88cfc79825df566dc5430b9a045a1200 130100002e00330024001d00209d3c94
+			0d89690b84d08a60993c144eca684d10 81287c834d5311bcf32bb9da1a002b00
+			020304
+		`),
+		prot: unhex(`
+			dc6b3343cf0008f067a5502a4262b500 4075d92faaf16f05d8a4398c47089698
+			baeea26b91eb761d9b89237bbf872630 17915358230035f7fd3945d88965cf17
+			f9af6e16886c61bfc703106fbaf3cb4c fa52382dd16a393e42757507698075b2
+			c984c707f0a0812d8cd5a6881eaf21ce da98f4bd23f6fe1a3e2c43edd9ce7ca8
+			4bed8521e2e140
+		`),
+	}, {
+		// https://www.rfc-editor.org/rfc/rfc9369#section-a.5
+		name: "ChaCha20_Poly1305 Short Header (QUIC v2)",
+		k: func() fixedKeys {
+			secret := unhex(`
+				9ac312a7f877468ebe69422748ad00a1
+				5443f18203a07d6060f688f30f21632b
+			`)
+			var k fixedKeys
+			k.init(version2Params, tls.TLS_CHACHA20_POLY1305_SHA256, secret)
+			return k
+		}(),
+		pnum: 654360564,
+		hdr:  unhex(`4200bff4`),
+		pay:  unhex(`01`),
+		prot: unhex(`
+			5558b1c60ae7b6b932bc27d786f4bc2b b20f2162ba
+		`),
 	}} {
 		test := test
 		t.Run(test.name, func(t *testing.T) {
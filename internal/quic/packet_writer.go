@@ -8,6 +8,8 @@ package quic
 
 import (
 	"encoding/binary"
+	"time"
+	"unicode/utf8"
 )
 
 // A packetWriter constructs QUIC datagrams.
@@ -109,17 +111,7 @@ func (w *packetWriter) finishProtectedLongHeaderPacket(pnumMaxAcked packetNumber
 	pnumLen := packetNumberLength(p.num, pnumMaxAcked)
 	plen := w.padPacketLength(pnumLen)
 	hdr := w.b[:w.pktOff]
-	var typeBits byte
-	switch p.ptype {
-	case packetTypeInitial:
-		typeBits = longPacketTypeInitial
-	case packetType0RTT:
-		typeBits = longPacketType0RTT
-	case packetTypeHandshake:
-		typeBits = longPacketTypeHandshake
-	case packetTypeRetry:
-		typeBits = longPacketTypeRetry
-	}
+	typeBits := headerParamsForVersion(p.version).longPacketTypeBits(p.ptype)
 	hdr = append(hdr, headerFormLong|fixedBit|typeBits|byte(pnumLen-1))
 	hdr = binary.BigEndian.AppendUint32(hdr, p.version)
 	hdr = appendUint8Bytes(hdr, p.dstConnID)
@@ -162,17 +154,21 @@ func (w *packetWriter) start1RTTPacket(pnum, pnumMaxAcked packetNumber, dstConnI
 
 // finish1RTTPacket finishes writing a 1-RTT packet,
 // canceling the packet if it contains no payload.
+// The spin parameter is the value of the latency spin bit.
 // It returns a sentPacket describing the packet, or nil if no packet was written.
-func (w *packetWriter) finish1RTTPacket(pnum, pnumMaxAcked packetNumber, dstConnID []byte, k *updatingKeyPair) *sentPacket {
+func (w *packetWriter) finish1RTTPacket(pnum, pnumMaxAcked packetNumber, dstConnID []byte, spin bool, k *updatingKeyPair) *sentPacket {
 	if len(w.b) == w.payOff {
 		// The payload is empty, so just abandon the packet.
 		w.b = w.b[:w.pktOff]
 		return nil
 	}
-	// TODO: Spin
 	pnumLen := packetNumberLength(pnum, pnumMaxAcked)
 	hdr := w.b[:w.pktOff]
-	hdr = append(hdr, 0x40|byte(pnumLen-1))
+	first := headerFormShort | fixedBit | byte(pnumLen-1)
+	if spin {
+		first |= spinBit
+	}
+	hdr = append(hdr, first)
 	hdr = append(hdr, dstConnID...)
 	pnumOff := len(hdr)
 	hdr = appendPacketNumber(hdr, pnum, pnumMaxAcked)
@@ -531,8 +527,16 @@ func (w *packetWriter) appendConnectionCloseTransportFrame(code transportError,
 // appendConnectionCloseTransportFrame appends a CONNECTION_CLOSE frame
 // carrying an application protocol error code.
 func (w *packetWriter) appendConnectionCloseApplicationFrame(code uint64, reason string) (added bool) {
-	if w.avail() < 1+sizeVarint(code)+sizeVarint(uint64(len(reason)))+len(reason) {
-		return false
+	if n := w.avail() - 1 - sizeVarint(code) - sizeVarint(uint64(len(reason))); n < len(reason) {
+		// The reason phrase is purely informational,
+		// so truncate it rather than failing to close the connection.
+		if n < 0 {
+			return false
+		}
+		for n > 0 && !utf8.RuneStart(reason[n]) {
+			n--
+		}
+		reason = reason[:n]
 	}
 	w.b = append(w.b, frameTypeConnectionCloseApplication)
 	w.b = appendVarint(w.b, code)
@@ -542,6 +546,54 @@ func (w *packetWriter) appendConnectionCloseApplicationFrame(code uint64, reason
 	return true
 }
 
+// appendDatagramFrame appends a DATAGRAM frame with a Length field.
+func (w *packetWriter) appendDatagramFrame(data []byte) (added bool) {
+	if w.avail() < 1+sizeVarint(uint64(len(data)))+len(data) {
+		return false
+	}
+	w.b = append(w.b, frameTypeDatagramWithLength)
+	w.b = appendVarintBytes(w.b, data)
+	// DATAGRAM frames are never retransmitted, so we don't record them in w.sent.
+	// They are ack-eliciting and count towards bytes in flight.
+	// https://www.rfc-editor.org/rfc/rfc9221#section-5.2
+	w.sent.ackEliciting = true
+	w.sent.inFlight = true
+	return true
+}
+
+// appendAckFrequencyFrame appends an ACK_FREQUENCY frame.
+// https://www.ietf.org/archive/id/draft-ietf-quic-ack-frequency-10.html#section-4
+func (w *packetWriter) appendAckFrequencyFrame(seq, ackElicitingThreshold int64, maxAckDelay time.Duration, reorderingThreshold int64) (added bool) {
+	delay := uint64(maxAckDelay / time.Microsecond)
+	if w.avail() < sizeVarint(frameTypeAckFrequency)+
+		sizeVarint(uint64(seq))+
+		sizeVarint(uint64(ackElicitingThreshold))+
+		sizeVarint(delay)+
+		sizeVarint(uint64(reorderingThreshold)) {
+		return false
+	}
+	w.b = appendVarint(w.b, frameTypeAckFrequency)
+	w.b = appendVarint(w.b, uint64(seq))
+	w.b = appendVarint(w.b, uint64(ackElicitingThreshold))
+	w.b = appendVarint(w.b, delay)
+	w.b = appendVarint(w.b, uint64(reorderingThreshold))
+	w.sent.appendAckElicitingFrame(frameTypeAckFrequency)
+	return true
+}
+
+// appendImmediateAckFrame appends an IMMEDIATE_ACK frame.
+// https://www.ietf.org/archive/id/draft-ietf-quic-ack-frequency-10.html#section-5
+func (w *packetWriter) appendImmediateAckFrame() (added bool) {
+	if w.avail() < 1 {
+		return false
+	}
+	w.b = append(w.b, frameTypeImmediateAck)
+	// Like PING, there's no need to record the presence of an IMMEDIATE_ACK frame.
+	w.sent.ackEliciting = true
+	w.sent.inFlight = true
+	return true
+}
+
 func (w *packetWriter) appendHandshakeDoneFrame() (added bool) {
 	if w.avail() < 1 {
 		return false
@@ -6,7 +6,124 @@
 
 package quic
 
-import "time"
+import (
+	"context"
+	"time"
+)
+
+// pingState tracks PING frames sent on behalf of Conn.Ping.
+type pingState struct {
+	send    sentVal // a PING should be sent for a waiter
+	waiters []*pingWaiter
+}
+
+// A pingWaiter is a call to Conn.Ping waiting for an acknowledgement.
+type pingWaiter struct {
+	pnum  packetNumber // first packet sent after the waiter started, or -1
+	rtt   time.Duration
+	err   error
+	donec chan struct{} // closed when rtt or err is set
+}
+
+// Ping sends a PING frame to the peer and waits for it to be acknowledged.
+// It returns the round-trip time: the time between sending the packet
+// containing the PING and receiving its acknowledgement,
+// including any delay by the peer in sending the acknowledgement.
+//
+// If the packet containing the PING is lost, another PING is sent.
+// Ping waits for the handshake to complete before sending a PING.
+// It returns an error if ctx is done or the connection is closed
+// before the PING is acknowledged.
+func (c *Conn) Ping(ctx context.Context) (time.Duration, error) {
+	if err := c.waitReady(ctx); err != nil {
+		return 0, err
+	}
+	w := &pingWaiter{
+		pnum:  -1,
+		donec: make(chan struct{}),
+	}
+	c.sendMsg(func(now time.Time, c *Conn) {
+		if c.isDraining() {
+			w.err = c.lifetime.finalErr
+			close(w.donec)
+			return
+		}
+		c.pings.waiters = append(c.pings.waiters, w)
+		c.pings.send.setUnsent()
+	})
+	if err := c.waitOnDone(ctx, w.donec); err != nil {
+		c.sendMsg(func(now time.Time, c *Conn) {
+			c.pingRemoveWaiter(w)
+		})
+		return 0, err
+	}
+	return w.rtt, w.err
+}
+
+// appendPingFrames appends a PING frame requested by Conn.Ping, if necessary.
+// It reports whether the frame was appended or was unnecessary.
+func (c *Conn) appendPingFrames(pnum packetNumber, pto bool) bool {
+	if !c.pings.send.shouldSendPTO(pto) {
+		return true
+	}
+	if !c.w.sent.ackEliciting && !c.w.appendPingFrame() {
+		return false
+	}
+	c.pings.send.setSent(pnum)
+	for _, w := range c.pings.waiters {
+		if w.pnum < 0 {
+			w.pnum = pnum
+		}
+	}
+	return true
+}
+
+// pingAckOrLoss handles the acknowledgement or loss of a 1-RTT packet.
+func (c *Conn) pingAckOrLoss(now time.Time, sent *sentPacket, fate packetFate) {
+	if fate == packetLost {
+		c.pings.send.ackOrLoss(sent.num, fate)
+		return
+	}
+	// Any acknowledged packet sent after a waiter started
+	// shows the peer is reachable.
+	waiters := c.pings.waiters[:0]
+	for _, w := range c.pings.waiters {
+		if w.pnum < 0 || sent.num < w.pnum {
+			waiters = append(waiters, w)
+			continue
+		}
+		w.rtt = now.Sub(sent.time)
+		close(w.donec)
+	}
+	clear(c.pings.waiters[len(waiters):])
+	c.pings.waiters = waiters
+	if len(waiters) == 0 {
+		c.pings.send.clear()
+	}
+}
+
+// pingRemoveWaiter removes a waiter whose call to Ping has returned.
+func (c *Conn) pingRemoveWaiter(w *pingWaiter) {
+	for i, ww := range c.pings.waiters {
+		if ww == w {
+			c.pings.waiters = append(c.pings.waiters[:i], c.pings.waiters[i+1:]...)
+			break
+		}
+	}
+	if len(c.pings.waiters) == 0 {
+		c.pings.send.clear()
+	}
+}
+
+// pingsClose fails all waiting calls to Ping with err.
+func (c *Conn) pingsClose(err error) {
+	for _, w := range c.pings.waiters {
+		w.err = err
+		close(w.donec)
+	}
+	c.pings.waiters = nil
+	c.pings.send.clear()
+}
 
 func (c *Conn) ping(space numberSpace) {
 	c.sendMsg(func(now time.Time, c *Conn) {
@@ -6,7 +6,12 @@
 
 package quic
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
 
 func TestPing(t *testing.T) {
 	tc := newTestConn(t, clientSide)
@@ -23,6 +28,87 @@ func TestPing(t *testing.T) {
 	tc.wantIdle("after sending PTO probe, no additional frames to send")
 }
 
+func TestConnPing(t *testing.T) {
+	tc := newTestConn(t, clientSide)
+	tc.handshake()
+
+	pinger := runAsync(tc, func(ctx context.Context) (time.Duration, error) {
+		return tc.conn.Ping(ctx)
+	})
+	tc.wantFrame("Ping sends a PING frame",
+		packetType1RTT, debugFramePing{})
+	if _, err := pinger.result(); err != errNotDone {
+		t.Fatalf("Ping before ack: %v, want still waiting", err)
+	}
+
+	const rtt = 30 * time.Millisecond
+	tc.advance(rtt)
+	tc.writeAckForAll()
+	got, err := pinger.result()
+	if err != nil || got != rtt {
+		t.Fatalf("Ping() = %v, %v; want %v, nil", got, err, rtt)
+	}
+	if tc.conn.pings.send.isSet() || len(tc.conn.pings.waiters) != 0 {
+		t.Errorf("after Ping returns, ping state is not cleared")
+	}
+}
+
+func TestConnPingLost(t *testing.T) {
+	tc := newTestConn(t, clientSide)
+	tc.handshake()
+
+	pinger := runAsync(tc, func(ctx context.Context) (time.Duration, error) {
+		return tc.conn.Ping(ctx)
+	})
+	tc.wantFrame("Ping sends a PING frame",
+		packetType1RTT, debugFramePing{})
+
+	tc.triggerLossOrPTO(packetType1RTT, true)
+	tc.wantFrame("PING is resent on PTO",
+		packetType1RTT, debugFramePing{})
+
+	tc.writeAckForLatest()
+	if _, err := pinger.result(); err != nil {
+		t.Fatalf("Ping() = %v, want success after ack of resent PING", err)
+	}
+}
+
+func TestConnPingCanceled(t *testing.T) {
+	tc := newTestConn(t, clientSide)
+	tc.handshake()
+
+	pinger := runAsync(tc, func(ctx context.Context) (time.Duration, error) {
+		return tc.conn.Ping(ctx)
+	})
+	tc.wantFrame("Ping sends a PING frame",
+		packetType1RTT, debugFramePing{})
+	pinger.cancel()
+	tc.wait()
+	if _, err := pinger.result(); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Ping() = %v, want context.Canceled", err)
+	}
+	if len(tc.conn.pings.waiters) != 0 {
+		t.Errorf("after canceled Ping, %v waiters remain", len(tc.conn.pings.waiters))
+	}
+}
+
+func TestConnPingConnClosed(t *testing.T) {
+	tc := newTestConn(t, clientSide)
+	tc.handshake()
+
+	pinger := runAsync(tc, func(ctx context.Context) (time.Duration, error) {
+		return tc.conn.Ping(ctx)
+	})
+	tc.wantFrame("Ping sends a PING frame",
+		packetType1RTT, debugFramePing{})
+	tc.writeFrames(packetType1RTT, debugFrameConnectionCloseApplication{
+		code: 1,
+	})
+	if _, err := pinger.result(); err == nil || err == errNotDone {
+		t.Fatalf("Ping() on closed conn = %v, want error", err)
+	}
+}
+
 func TestAck(t *testing.T) {
 	tc := newTestConn(t, serverSide)
 	tc.handshake()
@@ -0,0 +1,436 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// A QlogWriter writes traces of connections in the qlog format
+// to a directory of files.
+//
+// The QlogWriter's NewTracer method may be used as Config.NewTracer.
+// Events from all traced connections are written to a shared sequence
+// of files in the JSON-SEQ qlog serialization, each event tagged
+// with a group_id identifying its connection.
+// Files are rotated when they reach MaxFileSize,
+// and only the most recent MaxFiles files are kept,
+// bounding the disk space used by traces.
+// Buffered events are written out within a second,
+// and when a traced connection is closed.
+//
+// https://datatracker.ietf.org/doc/draft-ietf-quic-qlog-main-schema/
+// https://datatracker.ietf.org/doc/draft-ietf-quic-qlog-quic-events/
+type QlogWriter struct {
+	// Dir is the directory in which to write trace files.
+	// It must already exist.
+	Dir string
+
+	// SampleRate is the fraction of connections traced:
+	// one in every SampleRate connections is traced.
+	// If zero or one, every connection is traced.
+	SampleRate int
+
+	// MaxFileSize is the size in bytes at which a trace file is closed
+	// and a new one started. When Compress is set, the size is measured
+	// before compression.
+	// If zero, a default of 64MiB is used.
+	MaxFileSize int64
+
+	// MaxFiles is the number of trace files to keep.
+	// When a file is rotated and more than MaxFiles files have been written,
+	// the oldest is deleted.
+	// Trace files left in Dir by earlier QlogWriters count towards MaxFiles.
+	// If zero, a default of 8 is used.
+	// If negative, files are never deleted.
+	MaxFiles int
+
+	// Compress causes trace files to be compressed with gzip.
+	Compress bool
+
+	conns atomic.Uint64 // count of connections seen by NewTracer
+
+	mu           sync.Mutex
+	f            *os.File
+	gz           *gzip.Writer
+	bw           *bufio.Writer
+	size         int64      // bytes written to the current file, uncompressed
+	seq          int        // sequence number of the next file
+	files        []string   // files in Dir, oldest first
+	scanned      bool       // files includes those left by earlier writers
+	flushTimer   ClockTimer // flushes the current file
+	flushPending bool       // flushTimer is scheduled
+	err          error      // first error encountered
+	closed       bool
+}
+
+const (
+	defaultQlogMaxFileSize = 64 << 20
+	defaultQlogMaxFiles    = 8
+
+	// qlogFlushInterval is the maximum time events are buffered
+	// before being written to the trace file.
+	qlogFlushInterval = 1 * time.Second
+)
+
+// NewTracer returns a ConnTracer which writes qlog events for c,
+// or nil if c is not selected by SampleRate.
+func (w *QlogWriter) NewTracer(c *Conn) ConnTracer {
+	if !w.sample() {
+		return nil
+	}
+	return w.newTracer(c.config.clock(), hex.EncodeToString(c.connIDState.srcConnID()), c.side.String())
+}
+
+// sample reports whether the next connection should be traced.
+func (w *QlogWriter) sample() bool {
+	n := w.conns.Add(1)
+	if w.SampleRate <= 1 {
+		return true
+	}
+	return (n-1)%uint64(w.SampleRate) == 0
+}
+
+func (w *QlogWriter) newTracer(clock Clock, groupID, vantagePoint string) *qlogTracer {
+	t := &qlogTracer{
+		w:       w,
+		clock:   clock,
+		groupID: groupID,
+	}
+	t.event(clock.Now(), "connectivity:connection_started", map[string]any{
+		"vantage_point": vantagePoint,
+	})
+	return t
+}
+
+// Close flushes and closes the current trace file.
+// Events for connections traced after Close are discarded.
+// Close returns the first error encountered writing traces, if any.
+func (w *QlogWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closed = true
+	if w.flushTimer != nil {
+		w.flushTimer.Stop()
+	}
+	w.closeFileLocked()
+	return w.err
+}
+
+// flush writes buffered events to the current file.
+func (w *QlogWriter) flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.flushPending = false
+	if w.f == nil {
+		return
+	}
+	err := w.bw.Flush()
+	if w.gz != nil && err == nil {
+		err = w.gz.Flush()
+	}
+	if err != nil && w.err == nil {
+		w.err = err
+	}
+}
+
+// write writes a JSON-SEQ record containing v.
+// The clock is used to schedule a flush of the record.
+func (w *QlogWriter) write(clock Clock, now time.Time, v any) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed || w.err != nil {
+		return
+	}
+	if w.f == nil {
+		if err := w.openFileLocked(now); err != nil {
+			w.err = err
+			return
+		}
+	}
+	// https://www.rfc-editor.org/rfc/rfc7464#section-2.2
+	w.bw.WriteByte(0x1e)
+	w.bw.Write(b)
+	w.bw.WriteByte('\n')
+	w.size += int64(len(b)) + 2
+	if w.size >= w.maxFileSize() {
+		w.closeFileLocked()
+		w.removeOldFilesLocked()
+		return
+	}
+	if !w.flushPending {
+		w.flushPending = true
+		if w.flushTimer == nil {
+			w.flushTimer = clock.AfterFunc(qlogFlushInterval, w.flush)
+		} else {
+			w.flushTimer.Reset(qlogFlushInterval)
+		}
+	}
+}
+
+func (w *QlogWriter) maxFileSize() int64 {
+	if w.MaxFileSize <= 0 {
+		return defaultQlogMaxFileSize
+	}
+	return w.MaxFileSize
+}
+
+func (w *QlogWriter) maxFiles() int {
+	if w.MaxFiles == 0 {
+		return defaultQlogMaxFiles
+	}
+	return w.MaxFiles
+}
+
+func (w *QlogWriter) openFileLocked(now time.Time) error {
+	if !w.scanned {
+		if err := w.scanFilesLocked(); err != nil {
+			return err
+		}
+		w.removeOldFilesLocked()
+	}
+	var f *os.File
+	for {
+		name := fmt.Sprintf("quic-%v-%06d.sqlog", now.UTC().Format("20060102T150405"), w.seq)
+		if w.Compress {
+			name += ".gz"
+		}
+		w.seq++
+		var err error
+		f, err = os.OpenFile(filepath.Join(w.Dir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err == nil {
+			break
+		}
+		// Another writer created a file with the same name
+		// in the same second; try the next sequence number.
+		if !os.IsExist(err) {
+			return err
+		}
+	}
+	w.f = f
+	w.files = append(w.files, f.Name())
+	var dst io.Writer = f
+	if w.Compress {
+		w.gz = gzip.NewWriter(f)
+		dst = w.gz
+	}
+	w.bw = bufio.NewWriter(dst)
+	w.size = 0
+	hdr, _ := json.Marshal(map[string]any{
+		"qlog_version": "0.3",
+		"qlog_format":  "JSON-SEQ",
+		"trace": map[string]any{
+			"common_fields": map[string]any{
+				"time_format": "absolute",
+			},
+		},
+	})
+	w.bw.WriteByte(0x1e)
+	w.bw.Write(hdr)
+	w.bw.WriteByte('\n')
+	w.size += int64(len(hdr)) + 2
+	return nil
+}
+
+func (w *QlogWriter) closeFileLocked() {
+	if w.f == nil {
+		return
+	}
+	err := w.bw.Flush()
+	if w.gz != nil {
+		if gzErr := w.gz.Close(); err == nil {
+			err = gzErr
+		}
+	}
+	if closeErr := w.f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil && w.err == nil {
+		w.err = err
+	}
+	w.f, w.gz, w.bw = nil, nil, nil
+}
+
+// scanFilesLocked records the trace files in Dir left by earlier writers,
+// so they count towards MaxFiles.
+func (w *QlogWriter) scanFilesLocked() error {
+	ents, err := os.ReadDir(w.Dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range ents {
+		name := e.Name()
+		if !e.Type().IsRegular() || !strings.HasPrefix(name, "quic-") {
+			continue
+		}
+		if !strings.HasSuffix(name, ".sqlog") && !strings.HasSuffix(name, ".sqlog.gz") {
+			continue
+		}
+		// File names begin with a timestamp and sequence number,
+		// so ReadDir's sorted order is oldest first.
+		w.files = append(w.files, filepath.Join(w.Dir, name))
+	}
+	w.scanned = true
+	return nil
+}
+
+// removeOldFilesLocked deletes the oldest closed files beyond MaxFiles.
+func (w *QlogWriter) removeOldFilesLocked() {
+	max := w.maxFiles()
+	if max < 0 {
+		return
+	}
+	for len(w.files) > max {
+		os.Remove(w.files[0])
+		w.files = w.files[1:]
+	}
+}
+
+// A qlogTracer is a ConnTracer which writes events to a QlogWriter.
+type qlogTracer struct {
+	w       *QlogWriter
+	clock   Clock
+	groupID string
+}
+
+func (t *qlogTracer) event(now time.Time, name string, data map[string]any) {
+	t.w.write(t.clock, now, map[string]any{
+		"time":     float64(now.UnixNano()) / 1e6,
+		"name":     name,
+		"group_id": t.groupID,
+		"data":     data,
+	})
+}
+
+func (t *qlogTracer) PacketSent(now time.Time, p TracePacket) {
+	t.event(now, "transport:packet_sent", qlogPacket(p))
+}
+
+func (t *qlogTracer) PacketReceived(now time.Time, p TracePacket) {
+	t.event(now, "transport:packet_received", qlogPacket(p))
+}
+
+func (t *qlogTracer) PacketLost(now time.Time, p TracePacket) {
+	t.event(now, "recovery:packet_lost", qlogPacket(p))
+}
+
+func (t *qlogTracer) PacketDropped(now time.Time, p TracePacket, reason TraceDropReason) {
+	data := qlogPacket(p)
+	data["trigger"] = reason.String()
+	t.event(now, "transport:packet_dropped", data)
+}
+
+func (t *qlogTracer) CongestionUpdated(now time.Time, s TraceCongestion) {
+	data := map[string]any{
+		"congestion_window": s.CongestionWindow,
+		"bytes_in_flight":   s.BytesInFlight,
+		"smoothed_rtt":      qlogDuration(s.SmoothedRTT),
+		"rtt_variance":      qlogDuration(s.RTTVariation),
+	}
+	if s.SlowStartThreshold != math.MaxInt {
+		data["ssthresh"] = s.SlowStartThreshold
+	}
+	if s.MinRTT >= 0 {
+		data["min_rtt"] = qlogDuration(s.MinRTT)
+	}
+	t.event(now, "recovery:metrics_updated", data)
+}
+
+func (t *qlogTracer) StateChanged(now time.Time, s TraceState) {
+	t.event(now, "connectivity:connection_state_updated", map[string]any{
+		"new": s.String(),
+	})
+	if s == TraceStateClosed {
+		t.w.flush()
+	}
+}
+
+func (t *qlogTracer) ClockJumped(now time.Time, d time.Duration) {
+	t.event(now, "connectivity:clock_jumped", map[string]any{
+		"duration": qlogDuration(d),
+	})
+}
+
+// qlogPacket returns the qlog representation of a packet.
+func qlogPacket(p TracePacket) map[string]any {
+	hdr := map[string]any{
+		"packet_type": qlogPacketType(p.Type),
+	}
+	if p.Number >= 0 {
+		hdr["packet_number"] = p.Number
+	}
+	data := map[string]any{
+		"header": hdr,
+		"raw": map[string]any{
+			"length": p.Size,
+		},
+	}
+	if len(p.Frames) > 0 {
+		frames := make([]map[string]any, len(p.Frames))
+		for i, f := range p.Frames {
+			frames[i] = map[string]any{
+				"frame_type": qlogFrameType(f.Type),
+				"summary":    f.Summary,
+			}
+		}
+		data["frames"] = frames
+	}
+	return data
+}
+
+// qlogPacketType returns the qlog name of a TracePacket.Type.
+func qlogPacketType(s string) string {
+	switch s {
+	case "Initial":
+		return "initial"
+	case "0-RTT":
+		return "0RTT"
+	case "Handshake":
+		return "handshake"
+	case "Retry":
+		return "retry"
+	case "1-RTT":
+		return "1RTT"
+	case "Version Negotiation":
+		return "version_negotiation"
+	case "Stateless Reset":
+		return "stateless_reset"
+	}
+	return "unknown"
+}
+
+// qlogFrameType returns the qlog name of a frame type.
+func qlogFrameType(ftype uint64) string {
+	if i := frameCounterIndex(ftype); i >= 0 {
+		return strings.ToLower(frameCounterNames[i])
+	}
+	if ftype == frameTypePadding {
+		return "padding"
+	}
+	return "unknown"
+}
+
+// qlogDuration returns a duration in milliseconds.
+func qlogDuration(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,276 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+// readQlogFiles returns the records in the qlog files in dir, oldest first.
+func readQlogFiles(t *testing.T, dir string) (files []string, records []map[string]any) {
+	t.Helper()
+	ents, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range ents {
+		files = append(files, e.Name())
+	}
+	sort.Strings(files)
+	for _, name := range files {
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if filepath.Ext(name) == ".gz" {
+			gz, err := gzip.NewReader(bytes.NewReader(b))
+			if err != nil {
+				t.Fatalf("%v: %v", name, err)
+			}
+			if b, err = io.ReadAll(gz); err != nil {
+				t.Fatalf("%v: %v", name, err)
+			}
+		}
+		s := bufio.NewScanner(bytes.NewReader(b))
+		for s.Scan() {
+			line := s.Bytes()
+			if len(line) == 0 || line[0] != 0x1e {
+				t.Fatalf("%v: record %q does not begin with RS", name, line)
+			}
+			var rec map[string]any
+			if err := json.Unmarshal(line[1:], &rec); err != nil {
+				t.Fatalf("%v: %v", name, err)
+			}
+			records = append(records, rec)
+		}
+	}
+	return files, records
+}
+
+func TestQlogWriterConn(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		dir := t.TempDir()
+		w := &QlogWriter{
+			Dir:      dir,
+			Compress: compress,
+		}
+		cli, srv := newLocalConnPair(t, &Config{}, &Config{NewTracer: w.NewTracer})
+		ctx := context.Background()
+		s, err := cli.NewStream(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.Write([]byte("hello"))
+		s.CloseWrite()
+		if _, err := srv.AcceptStream(ctx); err != nil {
+			t.Fatal(err)
+		}
+		cli.Abort(nil)
+		srv.Wait(ctx)
+		if err := w.Close(); err != nil {
+			t.Fatalf("Close() = %v", err)
+		}
+
+		files, records := readQlogFiles(t, dir)
+		if len(files) != 1 {
+			t.Fatalf("compress=%v: wrote files %v, want one file", compress, files)
+		}
+		if got := records[0]["qlog_format"]; got != "JSON-SEQ" {
+			t.Errorf("compress=%v: header qlog_format = %v, want JSON-SEQ", compress, got)
+		}
+		names := map[any]bool{}
+		for _, rec := range records[1:] {
+			names[rec["name"]] = true
+		}
+		for _, name := range []string{
+			"connectivity:connection_started",
+			"transport:packet_sent",
+			"transport:packet_received",
+			"connectivity:connection_state_updated",
+		} {
+			if !names[name] {
+				t.Errorf("compress=%v: no %v event in trace", compress, name)
+			}
+		}
+	}
+}
+
+func TestQlogWriterSampling(t *testing.T) {
+	w := &QlogWriter{
+		Dir:        t.TempDir(),
+		SampleRate: 3,
+	}
+	var got []bool
+	for i := 0; i < 7; i++ {
+		got = append(got, w.sample())
+	}
+	want := []bool{true, false, false, true, false, false, true}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("sampled connections = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestQlogWriterRotation(t *testing.T) {
+	dir := t.TempDir()
+	w := &QlogWriter{
+		Dir:         dir,
+		MaxFileSize: 1024,
+		MaxFiles:    2,
+	}
+	tr := w.newTracer(systemClock{}, "0102", "client")
+	now := time.Now()
+	for i := 0; i < 100; i++ {
+		tr.PacketSent(now, TracePacket{
+			Type:   "1-RTT",
+			Number: int64(i),
+			Size:   1200,
+		})
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+	files, records := readQlogFiles(t, dir)
+	// Two rotated files, plus the file in progress when Close was called.
+	if len(files) != 3 {
+		t.Fatalf("after rotation, files = %v, want 3 files", files)
+	}
+	for _, name := range files {
+		fi, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// A file may exceed MaxFileSize by at most one record.
+		if fi.Size() > 2*w.MaxFileSize {
+			t.Errorf("%v: size %v, want at most %v", name, fi.Size(), 2*w.MaxFileSize)
+		}
+	}
+	last := records[len(records)-1]
+	data := last["data"].(map[string]any)
+	hdr := data["header"].(map[string]any)
+	if got := hdr["packet_number"]; got != float64(99) {
+		t.Errorf("last event packet_number = %v, want 99", got)
+	}
+	if got := last["group_id"]; got != "0102" {
+		t.Errorf("last event group_id = %v, want 0102", got)
+	}
+}
+
+// qlogTestClock is a Clock with a fixed time,
+// which records the function passed to AfterFunc.
+type qlogTestClock struct {
+	now time.Time
+	f   func()
+}
+
+func (c *qlogTestClock) Now() time.Time { return c.now }
+
+func (c *qlogTestClock) AfterFunc(d time.Duration, f func()) ClockTimer {
+	c.f = f
+	return qlogTestTimer{}
+}
+
+type qlogTestTimer struct{}
+
+func (qlogTestTimer) Reset(d time.Duration) bool { return true }
+func (qlogTestTimer) Stop() bool                 { return true }
+
+func TestQlogWriterClockAndFlush(t *testing.T) {
+	dir := t.TempDir()
+	w := &QlogWriter{Dir: dir}
+	defer w.Close()
+	clock := &qlogTestClock{now: time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)}
+	w.newTracer(clock, "0102", "server")
+	if clock.f == nil {
+		t.Fatalf("no flush scheduled with Clock.AfterFunc after writing an event")
+	}
+	files, records := readQlogFiles(t, dir)
+	if len(files) != 1 {
+		t.Fatalf("files = %v, want one file", files)
+	}
+	if want := "quic-20010203T040506-000000.sqlog"; files[0] != want {
+		t.Errorf("file name = %v, want %v (from Clock.Now)", files[0], want)
+	}
+	if len(records) != 0 {
+		t.Errorf("before flush, file contains %v records, want none buffered", len(records))
+	}
+
+	clock.f()
+	_, records = readQlogFiles(t, dir)
+	if len(records) != 2 {
+		t.Fatalf("after flush, file contains %v records, want header and one event", len(records))
+	}
+	if got, want := records[1]["time"], float64(clock.now.UnixNano())/1e6; got != want {
+		t.Errorf("connection_started time = %v, want %v (from Clock.Now)", got, want)
+	}
+}
+
+func TestQlogWriterFlushOnClose(t *testing.T) {
+	dir := t.TempDir()
+	w := &QlogWriter{Dir: dir}
+	defer w.Close()
+	clock := &qlogTestClock{now: time.Now()}
+	tr := w.newTracer(clock, "0102", "client")
+	tr.StateChanged(clock.now, TraceStateClosed)
+	_, records := readQlogFiles(t, dir)
+	if got := records[len(records)-1]["name"]; got != "connectivity:connection_state_updated" {
+		t.Errorf("after connection closed, last event written is %v, want connection_state_updated", got)
+	}
+}
+
+func TestQlogWriterRemovesEarlierFiles(t *testing.T) {
+	dir := t.TempDir()
+	earlier := []string{
+		"quic-20000101T000000-000000.sqlog",
+		"quic-20000101T000000-000001.sqlog.gz",
+		"quic-20000102T000000-000000.sqlog",
+		"unrelated.sqlog",
+	}
+	for _, name := range earlier {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w := &QlogWriter{
+		Dir:      dir,
+		MaxFiles: 2,
+	}
+	w.newTracer(systemClock{}, "0102", "client")
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+	ents, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]bool{}
+	for _, e := range ents {
+		got[e.Name()] = true
+	}
+	if len(got) != 4 {
+		t.Errorf("files in dir = %v, want 4", got)
+	}
+	if got[earlier[0]] {
+		t.Errorf("oldest earlier file %v not removed", earlier[0])
+	}
+	for _, name := range earlier[1:] {
+		if !got[name] {
+			t.Errorf("file %v removed, want kept", name)
+		}
+	}
+}
@@ -60,6 +60,13 @@ func (q *queue[T]) get(ctx context.Context, testHooks connTestHooks) (T, error)
 	return v, nil
 }
 
+// len returns the number of items in the queue.
+func (q *queue[T]) len() int {
+	q.gate.lock()
+	defer q.unlock()
+	return len(q.q)
+}
+
 func (q *queue[T]) unlock() {
 	q.gate.unlock(q.err != nil || len(q.q) > 0)
 }
@@ -11,7 +11,7 @@ import (
 )
 
 // QUIC versions.
-// We only support v1 at this time.
+// See Config.Versions for the set of versions a Listener supports.
 const (
 	quicVersion1 = 1
 	quicVersion2 = 0x6b3343cf // https://www.rfc-editor.org/rfc/rfc9369
@@ -35,9 +35,8 @@ const (
 	// Ethernet without using jumbo frames: 1500 byte Ethernet frame,
 	// minus 20 byte IPv4 header and 8 byte UDP header.
 	//
-	// The maximum possible UDP payload is 65527 bytes. Supporting this
-	// without wasting memory in unused receive buffers will require some
-	// care. For now, just limit ourselves to the most common case.
+	// The maximum possible UDP payload is 65527 bytes.
+	// Config.DatagramBufferSize may select a larger or smaller size.
 	maxUDPPayloadSize = 1472
 
 	ackDelayExponent = 3                     // ack_delay_exponent
@@ -58,6 +57,22 @@ const (
 // https://www.rfc-editor.org/rfc/rfc9002.html#section-6.1.2-6
 const timerGranularity = 1 * time.Millisecond
 
+// Default time allowed for a connection to complete the handshake.
+const defaultHandshakeTimeout = 10 * time.Second
+
+// Default time Listener.DialAddrs waits before starting the next connection attempt.
+// https://www.rfc-editor.org/rfc/rfc8305#section-8
+const defaultConnectionAttemptDelay = 250 * time.Millisecond
+
+// Default amount by which a connection's clock may overshoot
+// the time the connection expects to next wake before it is considered
+// to have jumped.
+const defaultClockJumpThreshold = 5 * time.Second
+
+// Default maximum number of inbound connections a Listener holds
+// before they are returned by Accept.
+const defaultMaxAcceptQueue = 1000
+
 // Minimum size of a UDP datagram sent by a client carrying an Initial packet,
 // or a server containing an ack-eliciting Initial packet.
 // https://www.rfc-editor.org/rfc/rfc9000#section-14.1
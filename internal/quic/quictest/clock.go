@@ -0,0 +1,152 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quictest
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/net/internal/quic"
+)
+
+// A Clock is a virtual quic.Clock.
+// Time only passes when Advance is called.
+//
+// A Clock is safe for concurrent use.
+type Clock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*clockTimer
+}
+
+// NewClock returns a Clock with the given current time.
+func NewClock(now time.Time) *Clock {
+	return &Clock{now: now}
+}
+
+// Now returns the Clock's current time.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// AfterFunc calls f after d has passed on the Clock.
+// f is called by Advance, or in its own goroutine if d is not positive.
+func (c *Clock) AfterFunc(d time.Duration, f func()) quic.ClockTimer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &clockTimer{c: c, f: f}
+	c.scheduleLocked(t, d)
+	return t
+}
+
+// Advance moves the Clock forward by d,
+// running any timers which expire in the interval in order.
+// The time is set to each timer's expiry before it is run,
+// and each timer's function returns before the next is run.
+//
+// Timer functions may use the Clock, but must not call Advance.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	end := c.now.Add(d)
+	for {
+		sort.SliceStable(c.timers, func(i, j int) bool {
+			return c.timers[i].when.Before(c.timers[j].when)
+		})
+		if len(c.timers) == 0 || c.timers[0].when.After(end) {
+			break
+		}
+		t := c.timers[0]
+		c.timers = c.timers[1:]
+		t.active = false
+		if t.when.After(c.now) {
+			c.now = t.when
+		}
+		c.mu.Unlock()
+		t.f()
+		c.mu.Lock()
+	}
+	if end.After(c.now) {
+		c.now = end
+	}
+}
+
+// NextTimer returns the time remaining until the next timer expires,
+// or -1 if no timers are scheduled.
+func (c *Clock) NextTimer() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.timers) == 0 {
+		return -1
+	}
+	next := c.timers[0].when
+	for _, t := range c.timers[1:] {
+		if t.when.Before(next) {
+			next = t.when
+		}
+	}
+	if next.Before(c.now) {
+		return 0
+	}
+	return next.Sub(c.now)
+}
+
+// scheduleLocked schedules t to run after d.
+// As with time.AfterFunc, a timer with a non-positive duration runs immediately.
+func (c *Clock) scheduleLocked(t *clockTimer, d time.Duration) {
+	t.when = c.now.Add(d)
+	if d <= 0 {
+		if t.active {
+			c.removeLocked(t)
+		}
+		go t.f()
+		return
+	}
+	if !t.active {
+		t.active = true
+		c.timers = append(c.timers, t)
+	}
+}
+
+func (c *Clock) removeLocked(t *clockTimer) {
+	for i, tt := range c.timers {
+		if tt == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			break
+		}
+	}
+	t.active = false
+}
+
+// A clockTimer is a timer created by Clock.AfterFunc.
+type clockTimer struct {
+	c      *Clock
+	f      func()
+	when   time.Time
+	active bool
+}
+
+func (t *clockTimer) Reset(d time.Duration) bool {
+	t.c.mu.Lock()
+	defer t.c.mu.Unlock()
+	wasActive := t.active
+	t.c.scheduleLocked(t, d)
+	return wasActive
+}
+
+func (t *clockTimer) Stop() bool {
+	t.c.mu.Lock()
+	defer t.c.mu.Unlock()
+	wasActive := t.active
+	if wasActive {
+		t.c.removeLocked(t)
+	}
+	return wasActive
+}
@@ -0,0 +1,206 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+// Package quictest provides utilities for testing code which uses QUIC
+// connections, without real sockets or timers.
+//
+// NewPipe creates a connected pair of quic.Conns which exchange datagrams
+// in memory, and whose timers run on a virtual Clock.
+// Latency, loss, and other network conditions may be simulated
+// by setting an Impairment on the pipe's Transports.
+//
+// LocalhostCertificate and Context are helpers for tests
+// which run QUIC servers on the loopback interface.
+package quictest
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/netip"
+	"sync"
+	"time"
+
+	"golang.org/x/net/internal/quic"
+)
+
+// NextProto is the ALPN protocol used by the default TLS configurations
+// created by NewPipe.
+const NextProto = "quictest"
+
+// ServerName is the name in the certificate presented by
+// the default server TLS configuration created by NewPipe.
+const ServerName = "quictest.example"
+
+var (
+	// ServerAddr and ClientAddr are the addresses of the endpoints of a Pipe.
+	ServerAddr = netip.MustParseAddrPort("192.0.2.1:443")
+	ClientAddr = netip.MustParseAddrPort("192.0.2.2:49152")
+)
+
+// A Pipe is a connected pair of QUIC connections
+// communicating over an in-memory network.
+type Pipe struct {
+	// Client and Server are the two ends of the pipe.
+	Client *quic.Conn
+	Server *quic.Conn
+
+	// ClientListener and ServerListener are the Listeners
+	// which created Client and Server.
+	// The ClientListener may be used to dial more connections to the server,
+	// which are accepted by the ServerListener.
+	ClientListener *quic.Listener
+	ServerListener *quic.Listener
+
+	// ClientTransport and ServerTransport carry datagrams sent by
+	// the client and server. Impairments set on them, with SetImpairment,
+	// simulate network conditions.
+	ClientTransport *Transport
+	ServerTransport *Transport
+
+	// Clock is the virtual clock used by both endpoints,
+	// unless the Config passed to NewPipe set one.
+	// It is always used to delay datagrams sent on the Transports.
+	Clock *Clock
+}
+
+// NewPipe returns a connected pair of QUIC connections.
+//
+// The client and server use copies of clientConfig and serverConfig,
+// either of which may be nil.
+// If a Config has no TLSConfig, a default configuration is used:
+// the server presents a self-signed certificate for ServerName,
+// which the client trusts, and both negotiate the NextProto protocol.
+// If a Config has no Clock, the Pipe's virtual Clock is used,
+// and clock jump detection is disabled (see quic.Config.ClockJumpThreshold)
+// so that advancing the virtual clock does not appear as a jump.
+//
+// NewPipe blocks until the handshake completes or ctx is done.
+// The virtual clock does not advance during the handshake.
+func NewPipe(ctx context.Context, clientConfig, serverConfig *quic.Config) (*Pipe, error) {
+	p := &Pipe{
+		Clock: NewClock(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)),
+	}
+	cconf := p.config(clientConfig, false)
+	sconf := p.config(serverConfig, true)
+	p.ServerTransport, p.ClientTransport = NewTransportPair(p.Clock, ServerAddr, ClientAddr)
+	var err error
+	p.ServerListener, err = quic.ListenPacketConn(p.ServerTransport, sconf)
+	if err != nil {
+		return nil, err
+	}
+	p.ClientListener, err = quic.ListenPacketConn(p.ClientTransport, cconf)
+	if err != nil {
+		p.Close()
+		return nil, err
+	}
+	p.Client, err = p.ClientListener.Dial(ctx, "udp", ServerAddr.String())
+	if err != nil {
+		p.Close()
+		return nil, err
+	}
+	p.Server, err = p.ServerListener.Accept(ctx)
+	if err != nil {
+		p.Close()
+		return nil, err
+	}
+	return p, nil
+}
+
+// config returns a copy of conf, with defaults for the pipe.
+func (p *Pipe) config(conf *quic.Config, server bool) *quic.Config {
+	c := &quic.Config{}
+	if conf != nil {
+		*c = *conf
+	}
+	if c.TLSConfig == nil {
+		c.TLSConfig = defaultTLSConfig(server)
+	}
+	if c.Clock == nil {
+		c.Clock = p.Clock
+		if c.ClockJumpThreshold == 0 {
+			c.ClockJumpThreshold = -1
+		}
+	}
+	return c
+}
+
+// Close closes the pipe's connections and listeners immediately,
+// without waiting for the peers to acknowledge the close.
+func (p *Pipe) Close() {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, l := range []*quic.Listener{p.ClientListener, p.ServerListener} {
+		if l != nil {
+			l.Close(ctx)
+		}
+	}
+}
+
+func defaultTLSConfig(server bool) *tls.Config {
+	cert, pool := testCertificate()
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS13,
+		NextProtos: []string{NextProto},
+	}
+	if server {
+		config.Certificates = []tls.Certificate{cert}
+	} else {
+		config.RootCAs = pool
+		config.ServerName = ServerName
+	}
+	return config
+}
+
+var (
+	testCertOnce sync.Once
+	testCert     tls.Certificate
+	testCertPool *x509.CertPool
+)
+
+// testCertificate returns a self-signed certificate for ServerName,
+// and a pool containing it.
+// The certificate is generated on first use.
+func testCertificate() (tls.Certificate, *x509.CertPool) {
+	testCertOnce.Do(func() {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			panic(err)
+		}
+		tmpl := &x509.Certificate{
+			SerialNumber:          big.NewInt(1),
+			Subject:               pkix.Name{Organization: []string{"quictest"}},
+			DNSNames:              []string{ServerName},
+			NotBefore:             time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC),
+			NotAfter:              time.Date(2084, 1, 1, 0, 0, 0, 0, time.UTC),
+			KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+			ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+			BasicConstraintsValid: true,
+			IsCA:                  true,
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+		if err != nil {
+			panic(err)
+		}
+		leaf, err := x509.ParseCertificate(der)
+		if err != nil {
+			panic(err)
+		}
+		testCert = tls.Certificate{
+			Certificate: [][]byte{der},
+			PrivateKey:  key,
+			Leaf:        leaf,
+		}
+		testCertPool = x509.NewCertPool()
+		testCertPool.AddCert(leaf)
+	})
+	return testCert, testCertPool
+}
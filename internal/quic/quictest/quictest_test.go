@@ -0,0 +1,128 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quictest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"golang.org/x/net/internal/quic"
+)
+
+func newTestPipe(t *testing.T, clientConfig, serverConfig *quic.Config) *Pipe {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	p, err := NewPipe(ctx, clientConfig, serverConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(p.Close)
+	return p
+}
+
+func TestPipeStream(t *testing.T) {
+	p := newTestPipe(t, nil, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	want := bytes.Repeat([]byte("0123456789"), 100000)
+	s, err := p.Client.NewStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		s.WriteContext(ctx, want)
+		s.CloseWrite()
+	}()
+	ss, err := p.Server.AcceptStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(ss)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("read %v bytes, want %v bytes sent by client", len(got), len(want))
+	}
+}
+
+func TestPipeIdleTimeout(t *testing.T) {
+	conf := &quic.Config{
+		MaxIdleTimeout: 10 * time.Second,
+	}
+	p := newTestPipe(t, conf, conf)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	p.Clock.Advance(5 * time.Second)
+	if err := p.Client.Wait(canceledContext()); err != context.Canceled {
+		t.Fatalf("after 5s, client.Wait() = %v, want connection still open", err)
+	}
+	// Connection timers run asynchronously with respect to Advance,
+	// so a packet from the peer processed after advancing the clock
+	// may extend the idle timeout. Keep advancing until the timeout expires.
+	var idleErr quic.IdleTimeoutError
+	for i := 0; i < 10; i++ {
+		p.Clock.Advance(1 * time.Minute)
+		waitCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+		err := p.Client.Wait(waitCtx)
+		cancel()
+		if errors.As(err, &idleErr) {
+			return
+		}
+	}
+	t.Fatalf("after idle timeout, client.Wait() did not return IdleTimeoutError")
+}
+
+func TestClockTimers(t *testing.T) {
+	start := time.Unix(0, 0)
+	c := NewClock(start)
+	ranc := make(chan time.Duration, 3)
+	for _, d := range []time.Duration{3, 1, 2} {
+		// Each timer reports the time at which it runs.
+		c.AfterFunc(d*time.Second, func() { ranc <- c.Now().Sub(start) })
+	}
+	stopped := c.AfterFunc(4*time.Second, func() { ranc <- -1 })
+	if !stopped.Stop() {
+		t.Errorf("Stop() = false, want true for active timer")
+	}
+	if got, want := c.NextTimer(), 1*time.Second; got != want {
+		t.Errorf("NextTimer() = %v, want %v", got, want)
+	}
+	// Advance runs the expired timers in order before returning.
+	c.Advance(10 * time.Second)
+	for _, want := range []time.Duration{1, 2, 3} {
+		select {
+		case got := <-ranc:
+			if got != want*time.Second {
+				t.Errorf("timer ran: %v, want %v", got, want*time.Second)
+			}
+		default:
+			t.Fatalf("timer %v did not run during Advance", want*time.Second)
+		}
+	}
+	select {
+	case d := <-ranc:
+		t.Errorf("stopped timer ran: %v", d)
+	default:
+	}
+	if got, want := c.Now(), time.Unix(10, 0); !got.Equal(want) {
+		t.Errorf("Now() = %v, want %v", got, want)
+	}
+}
+
+func canceledContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return ctx
+}
@@ -0,0 +1,292 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quictest
+
+import (
+	"math/rand"
+	"net"
+	"net/netip"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/net/internal/quic"
+)
+
+// An Impairment describes network conditions applied to datagrams
+// sent by a Transport.
+//
+// Random decisions are made using a source seeded with Seed,
+// so a sequence of datagrams sent with the same Impairment
+// on a virtual Clock is impaired the same way each time.
+type Impairment struct {
+	// Latency is the delay before a datagram is delivered.
+	Latency time.Duration
+
+	// Jitter is the maximum additional random delay before a datagram is delivered.
+	// Datagrams may be reordered by jitter.
+	Jitter time.Duration
+
+	// Reorder is the probability that a datagram is delayed by an
+	// additional ReorderDelay, so that it arrives after datagrams sent after it.
+	Reorder float64
+
+	// ReorderDelay is the additional delay of reordered datagrams.
+	// If zero, a default of 10ms is used.
+	ReorderDelay time.Duration
+
+	// Duplicate is the probability that a datagram is delivered twice.
+	// The delay of each copy is chosen independently.
+	Duplicate float64
+
+	// Loss is the probability that a datagram is dropped.
+	Loss float64
+
+	// Seed seeds the random source.
+	Seed int64
+}
+
+func (imp *Impairment) reorderDelay() time.Duration {
+	if imp.ReorderDelay == 0 {
+		return 10 * time.Millisecond
+	}
+	return imp.ReorderDelay
+}
+
+// TransportStats counts the datagrams sent by a Transport.
+type TransportStats struct {
+	Sent       int // datagrams sent
+	Lost       int // datagrams dropped by Impairment.Loss
+	Duplicated int // datagrams delivered twice by Impairment.Duplicate
+	Reordered  int // datagrams delayed by Impairment.Reorder
+}
+
+// A Transport is one end of an in-memory datagram link.
+// It implements quic.DatagramTransport,
+// and may be passed to quic.ListenPacketConn.
+//
+// By default, datagrams are delivered immediately, reliably, and in order.
+// SetImpairment simulates other network conditions.
+type Transport struct {
+	addr  *net.UDPAddr
+	peer  *Transport
+	clock quic.Clock
+
+	// Receive queue.
+	mu     sync.Mutex
+	queue  [][]byte
+	readyc chan struct{} // has a value when queue is non-empty or closed
+	closed bool
+
+	// Send state.
+	sendMu   sync.Mutex
+	imp      Impairment
+	rand     *rand.Rand
+	inFlight []delayedDatagram // sorted by delivery time
+	timer    quic.ClockTimer
+	stats    TransportStats
+}
+
+type delayedDatagram struct {
+	at time.Time
+	b  []byte
+}
+
+// NewTransportPair returns two connected Transports with the given addresses.
+// Datagrams sent by one are received by the other.
+//
+// The clock is used to delay datagrams when an Impairment adds latency.
+// If nil, the system clock is used.
+func NewTransportPair(clock quic.Clock, addr1, addr2 netip.AddrPort) (*Transport, *Transport) {
+	if clock == nil {
+		clock = systemClock{}
+	}
+	t1 := newTransport(clock, addr1)
+	t2 := newTransport(clock, addr2)
+	t1.peer, t2.peer = t2, t1
+	return t1, t2
+}
+
+func newTransport(clock quic.Clock, addr netip.AddrPort) *Transport {
+	return &Transport{
+		addr:   net.UDPAddrFromAddrPort(addr),
+		clock:  clock,
+		readyc: make(chan struct{}, 1),
+	}
+}
+
+// SetImpairment sets the conditions applied to datagrams subsequently sent by t.
+// Datagrams already in flight are unaffected.
+func (t *Transport) SetImpairment(imp Impairment) {
+	t.sendMu.Lock()
+	defer t.sendMu.Unlock()
+	t.imp = imp
+	t.rand = rand.New(rand.NewSource(imp.Seed))
+}
+
+// Stats returns counters of the datagrams sent by t.
+func (t *Transport) Stats() TransportStats {
+	t.sendMu.Lock()
+	defer t.sendMu.Unlock()
+	return t.stats
+}
+
+// ReadFrom reads a datagram sent by the peer Transport.
+func (t *Transport) ReadFrom(p []byte) (int, net.Addr, error) {
+	for {
+		<-t.readyc
+		t.mu.Lock()
+		if t.closed {
+			t.mu.Unlock()
+			t.signal()
+			return 0, nil, net.ErrClosed
+		}
+		if len(t.queue) == 0 {
+			t.mu.Unlock()
+			continue
+		}
+		b := t.queue[0]
+		t.queue = t.queue[1:]
+		if len(t.queue) > 0 {
+			t.signal()
+		}
+		t.mu.Unlock()
+		return copy(p, b), t.peer.addr, nil
+	}
+}
+
+// WriteTo sends a datagram to the peer Transport.
+// The address is ignored.
+func (t *Transport) WriteTo(p []byte, addr net.Addr) (int, error) {
+	t.mu.Lock()
+	closed := t.closed
+	t.mu.Unlock()
+	if closed {
+		return 0, net.ErrClosed
+	}
+	b := append([]byte(nil), p...)
+
+	t.sendMu.Lock()
+	defer t.sendMu.Unlock()
+	t.stats.Sent++
+	if t.rand == nil {
+		t.peer.deliver(b)
+		return len(p), nil
+	}
+	if t.rand.Float64() < t.imp.Loss {
+		t.stats.Lost++
+		return len(p), nil
+	}
+	copies := 1
+	if t.rand.Float64() < t.imp.Duplicate {
+		t.stats.Duplicated++
+		copies = 2
+	}
+	for i := 0; i < copies; i++ {
+		t.sendLocked(b)
+	}
+	return len(p), nil
+}
+
+// sendLocked sends a datagram after the delay chosen by the Impairment.
+func (t *Transport) sendLocked(b []byte) {
+	delay := t.imp.Latency
+	if t.imp.Jitter > 0 {
+		delay += time.Duration(t.rand.Int63n(int64(t.imp.Jitter)))
+	}
+	if t.rand.Float64() < t.imp.Reorder {
+		t.stats.Reordered++
+		delay += t.imp.reorderDelay()
+	}
+	if delay <= 0 && len(t.inFlight) == 0 {
+		t.peer.deliver(b)
+		return
+	}
+	at := t.clock.Now().Add(delay)
+	i := sort.Search(len(t.inFlight), func(i int) bool {
+		return t.inFlight[i].at.After(at)
+	})
+	t.inFlight = append(t.inFlight, delayedDatagram{})
+	copy(t.inFlight[i+1:], t.inFlight[i:])
+	t.inFlight[i] = delayedDatagram{at: at, b: b}
+	if i == 0 {
+		t.resetTimerLocked()
+	}
+}
+
+// resetTimerLocked schedules delivery of the next datagram in flight.
+func (t *Transport) resetTimerLocked() {
+	if len(t.inFlight) == 0 {
+		return
+	}
+	d := t.inFlight[0].at.Sub(t.clock.Now())
+	if t.timer == nil {
+		t.timer = t.clock.AfterFunc(d, t.deliverInFlight)
+	} else {
+		t.timer.Reset(d)
+	}
+}
+
+// deliverInFlight delivers datagrams whose delay has passed, in order.
+func (t *Transport) deliverInFlight() {
+	t.sendMu.Lock()
+	defer t.sendMu.Unlock()
+	now := t.clock.Now()
+	for len(t.inFlight) > 0 && !t.inFlight[0].at.After(now) {
+		t.peer.deliver(t.inFlight[0].b)
+		t.inFlight = t.inFlight[1:]
+	}
+	t.resetTimerLocked()
+}
+
+// deliver adds a datagram to the receive queue.
+func (t *Transport) deliver(b []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return
+	}
+	t.queue = append(t.queue, b)
+	t.signal()
+}
+
+func (t *Transport) signal() {
+	select {
+	case t.readyc <- struct{}{}:
+	default:
+	}
+}
+
+// Close closes the transport.
+// Datagrams sent to a closed transport are discarded.
+func (t *Transport) Close() error {
+	t.mu.Lock()
+	t.closed = true
+	t.queue = nil
+	t.signal()
+	t.mu.Unlock()
+
+	t.sendMu.Lock()
+	defer t.sendMu.Unlock()
+	t.inFlight = nil
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+	return nil
+}
+
+// LocalAddr returns the transport's address.
+func (t *Transport) LocalAddr() net.Addr { return t.addr }
+
+// systemClock is a quic.Clock using the time package.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+func (systemClock) AfterFunc(d time.Duration, f func()) quic.ClockTimer {
+	return time.AfterFunc(d, f)
+}
@@ -0,0 +1,201 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quictest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// readDatagrams reads datagrams from t until none arrive for a short time.
+func readDatagrams(t *testing.T, tr *Transport) []string {
+	t.Helper()
+	var got []string
+	recvc := make(chan string)
+	go func() {
+		for {
+			b := make([]byte, 100)
+			n, _, err := tr.ReadFrom(b)
+			if err != nil {
+				close(recvc)
+				return
+			}
+			recvc <- string(b[:n])
+		}
+	}()
+	for {
+		select {
+		case s := <-recvc:
+			got = append(got, s)
+		case <-time.After(50 * time.Millisecond):
+			tr.Close()
+			for range recvc {
+			}
+			return got
+		}
+	}
+}
+
+func sendDatagrams(tr *Transport, n int) {
+	for i := 0; i < n; i++ {
+		tr.WriteTo([]byte(fmt.Sprint(i)), nil)
+	}
+}
+
+func TestTransportLatency(t *testing.T) {
+	clock := NewClock(time.Unix(0, 0))
+	a, b := NewTransportPair(clock, ServerAddr, ClientAddr)
+	a.SetImpairment(Impairment{Latency: 10 * time.Millisecond})
+	sendDatagrams(a, 3)
+	clock.Advance(5 * time.Millisecond)
+	select {
+	case <-b.readyc:
+		t.Fatalf("datagram delivered before latency elapsed")
+	case <-time.After(10 * time.Millisecond):
+	}
+	clock.Advance(5 * time.Millisecond)
+	if got, want := readDatagrams(t, b), []string{"0", "1", "2"}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("received %q, want %q", got, want)
+	}
+}
+
+func TestTransportImpairments(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		imp       Impairment
+		wantCount int
+		wantStats TransportStats
+	}{{
+		name:      "loss",
+		imp:       Impairment{Loss: 1},
+		wantCount: 0,
+		wantStats: TransportStats{Sent: 10, Lost: 10},
+	}, {
+		name:      "duplicate",
+		imp:       Impairment{Duplicate: 1},
+		wantCount: 20,
+		wantStats: TransportStats{Sent: 10, Duplicated: 10},
+	}, {
+		name:      "reorder",
+		imp:       Impairment{Reorder: 1},
+		wantCount: 10,
+		wantStats: TransportStats{Sent: 10, Reordered: 10},
+	}} {
+		t.Run(test.name, func(t *testing.T) {
+			clock := NewClock(time.Unix(0, 0))
+			a, b := NewTransportPair(clock, ServerAddr, ClientAddr)
+			a.SetImpairment(test.imp)
+			sendDatagrams(a, 10)
+			clock.Advance(1 * time.Second)
+			if got := readDatagrams(t, b); len(got) != test.wantCount {
+				t.Errorf("received %v datagrams, want %v", len(got), test.wantCount)
+			}
+			if got := a.Stats(); got != test.wantStats {
+				t.Errorf("Stats() = %+v, want %+v", got, test.wantStats)
+			}
+		})
+	}
+}
+
+func TestTransportImpairmentReproducible(t *testing.T) {
+	imp := Impairment{
+		Latency:   10 * time.Millisecond,
+		Jitter:    10 * time.Millisecond,
+		Reorder:   0.1,
+		Duplicate: 0.1,
+		Loss:      0.1,
+		Seed:      1,
+	}
+	var results []string
+	for i := 0; i < 2; i++ {
+		clock := NewClock(time.Unix(0, 0))
+		a, b := NewTransportPair(clock, ServerAddr, ClientAddr)
+		a.SetImpairment(imp)
+		sendDatagrams(a, 100)
+		clock.Advance(1 * time.Second)
+		results = append(results, fmt.Sprint(a.Stats(), readDatagrams(t, b)))
+	}
+	if results[0] != results[1] {
+		t.Errorf("impairments with the same seed differ:\n%v\n%v", results[0], results[1])
+	}
+}
+
+func TestTransportClosed(t *testing.T) {
+	a, b := NewTransportPair(nil, ServerAddr, ClientAddr)
+	b.Close()
+	if _, _, err := b.ReadFrom(make([]byte, 10)); err != net.ErrClosed {
+		t.Errorf("ReadFrom on closed transport: %v, want net.ErrClosed", err)
+	}
+	a.Close()
+	if _, err := a.WriteTo([]byte{0}, nil); err != net.ErrClosed {
+		t.Errorf("WriteTo on closed transport: %v, want net.ErrClosed", err)
+	}
+}
+
+// runClock advances the clock in small steps until done is closed.
+func runClock(clock *Clock, done <-chan struct{}) {
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		clock.Advance(1 * time.Millisecond)
+		time.Sleep(10 * time.Microsecond)
+	}
+}
+
+func TestPipeImpaired(t *testing.T) {
+	p := newTestPipe(t, nil, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	imp := Impairment{
+		Latency:   20 * time.Millisecond,
+		Jitter:    5 * time.Millisecond,
+		Reorder:   0.05,
+		Duplicate: 0.05,
+		Loss:      0.05,
+	}
+	imp.Seed = 1
+	p.ClientTransport.SetImpairment(imp)
+	imp.Seed = 2
+	p.ServerTransport.SetImpairment(imp)
+
+	done := make(chan struct{})
+	defer close(done)
+	go runClock(p.Clock, done)
+
+	want := bytes.Repeat([]byte("0123456789"), 10000)
+	s, err := p.Client.NewStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		s.WriteContext(ctx, want)
+		s.CloseWrite()
+	}()
+	ss, err := p.Server.AcceptStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(ss)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("read %v bytes, want %v bytes sent by client", len(got), len(want))
+	}
+	if st := p.ClientTransport.Stats(); st.Lost == 0 {
+		t.Errorf("client transport stats: %+v; want some datagrams lost", st)
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+// Package quicwire encodes and decodes QUIC variable-length integers,
+// for use by the quic package and the protocols layered on it.
+package quicwire
+
+import (
+	"errors"
+	"io"
+)
+
+const (
+	MaxVarintSize = 8 // encoded size in bytes
+	MaxVarint     = (1 << 62) - 1
+)
+
+// ConsumeVarint parses a variable-length integer, reporting its length.
+// It returns a negative length upon an error.
+//
+// https://www.rfc-editor.org/rfc/rfc9000.html#section-16
+func ConsumeVarint(b []byte) (v uint64, n int) {
+	if len(b) < 1 {
+		return 0, -1
+	}
+	b0 := b[0] & 0x3f
+	switch b[0] >> 6 {
+	case 0:
+		return uint64(b0), 1
+	case 1:
+		if len(b) < 2 {
+			return 0, -1
+		}
+		return uint64(b0)<<8 | uint64(b[1]), 2
+	case 2:
+		if len(b) < 4 {
+			return 0, -1
+		}
+		return uint64(b0)<<24 | uint64(b[1])<<16 | uint64(b[2])<<8 | uint64(b[3]), 4
+	case 3:
+		if len(b) < 8 {
+			return 0, -1
+		}
+		return uint64(b0)<<56 | uint64(b[1])<<48 | uint64(b[2])<<40 | uint64(b[3])<<32 | uint64(b[4])<<24 | uint64(b[5])<<16 | uint64(b[6])<<8 | uint64(b[7]), 8
+	}
+	return 0, -1
+}
+
+// AppendVarint appends a variable-length integer to b.
+//
+// https://www.rfc-editor.org/rfc/rfc9000.html#section-16
+func AppendVarint(b []byte, v uint64) []byte {
+	switch {
+	case v <= 63:
+		return append(b, byte(v))
+	case v <= 16383:
+		return append(b, (1<<6)|byte(v>>8), byte(v))
+	case v <= 1073741823:
+		return append(b, (2<<6)|byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+	case v <= MaxVarint:
+		return append(b, (3<<6)|byte(v>>56), byte(v>>48), byte(v>>40), byte(v>>32), byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+	default:
+		panic("varint too large")
+	}
+}
+
+// SizeVarint returns the size of the variable-length integer encoding of v.
+func SizeVarint(v uint64) int {
+	switch {
+	case v <= 63:
+		return 1
+	case v <= 16383:
+		return 2
+	case v <= 1073741823:
+		return 4
+	case v <= MaxVarint:
+		return 8
+	default:
+		panic("varint too large")
+	}
+}
+
+// ReadVarint reads a variable-length integer from r.
+// It returns io.EOF if r ends before the first byte of the integer,
+// and io.ErrUnexpectedEOF if r ends within the integer.
+func ReadVarint(r io.Reader) (uint64, error) {
+	var buf [MaxVarintSize]byte
+	if _, err := io.ReadFull(r, buf[:1]); err != nil {
+		return 0, err
+	}
+	n := 1 << (buf[0] >> 6)
+	if _, err := io.ReadFull(r, buf[1:n]); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, err
+	}
+	v, _ := ConsumeVarint(buf[:n])
+	return v, nil
+}
@@ -0,0 +1,62 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quicwire
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestVarint(t *testing.T) {
+	for _, test := range []struct {
+		v uint64
+		b []byte
+	}{
+		{0, []byte{0x00}},
+		{63, []byte{0x3f}},
+		{16383, []byte{0x7f, 0xff}},
+		{1073741823, []byte{0xbf, 0xff, 0xff, 0xff}},
+		{MaxVarint, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+		// Example cases from https://www.rfc-editor.org/rfc/rfc9000.html#section-a.1
+		{151288809941952652, []byte{0xc2, 0x19, 0x7c, 0x5e, 0xff, 0x14, 0xe8, 0x8c}},
+		{494878333, []byte{0x9d, 0x7f, 0x3e, 0x7d}},
+		{15293, []byte{0x7b, 0xbd}},
+		{37, []byte{0x25}},
+	} {
+		if got := AppendVarint(nil, test.v); !bytes.Equal(got, test.b) {
+			t.Errorf("AppendVarint(nil, %v) = %x, want %x", test.v, got, test.b)
+		}
+		if got, want := SizeVarint(test.v), len(test.b); got != want {
+			t.Errorf("SizeVarint(%v) = %v, want %v", test.v, got, want)
+		}
+		if got, n := ConsumeVarint(test.b); got != test.v || n != len(test.b) {
+			t.Errorf("ConsumeVarint(%x) = %v, %v; want %v, %v", test.b, got, n, test.v, len(test.b))
+		}
+		if got, n := ConsumeVarint(test.b[:len(test.b)-1]); got != 0 || n >= 0 {
+			t.Errorf("ConsumeVarint(%x) = %v, %v; want 0, -1", test.b[:len(test.b)-1], got, n)
+		}
+		if got, err := ReadVarint(bytes.NewReader(test.b)); got != test.v || err != nil {
+			t.Errorf("ReadVarint(%x) = %v, %v; want %v, nil", test.b, got, err, test.v)
+		}
+	}
+}
+
+func TestReadVarintEOF(t *testing.T) {
+	for _, test := range []struct {
+		b       []byte
+		wantErr error
+	}{
+		{[]byte{}, io.EOF},
+		{[]byte{0x40}, io.ErrUnexpectedEOF},
+		{[]byte{0xc0, 0, 0, 0}, io.ErrUnexpectedEOF},
+	} {
+		if _, err := ReadVarint(bytes.NewReader(test.b)); err != test.wantErr {
+			t.Errorf("ReadVarint(%x) = %v, want %v", test.b, err, test.wantErr)
+		}
+	}
+}
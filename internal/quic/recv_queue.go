@@ -0,0 +1,139 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"sync"
+	"time"
+)
+
+// minConnDatagramBacklog and maxConnDatagramBacklog bound the number of
+// received datagrams queued for a conn before further datagrams are dropped.
+// Within these bounds, the backlog scales with the conn's receive buffer.
+// See Config.connDatagramBacklog.
+const (
+	minConnDatagramBacklog = 64
+	maxConnDatagramBacklog = 1024
+)
+
+// A recvQueue is a bounded queue of datagrams received by the Listener
+// for a conn running its own goroutine.
+//
+// The Listener adds datagrams to the queue without waiting for the conn,
+// so a conn which is slow to handle its datagrams does not block delivery
+// of datagrams to other conns. When the queue is full, newly received
+// datagrams are dropped, as a congested router would drop them;
+// the peer will detect the loss and retransmit.
+//
+// The queue's ring buffer grows as needed, up to limit datagrams,
+// so an idle conn doesn't hold space for a full backlog.
+type recvQueue struct {
+	mu     sync.Mutex
+	limit  int         // maximum queue length; if zero, minConnDatagramBacklog
+	ring   []*datagram // len(ring) is the current capacity
+	head   int         // index of the first datagram in ring
+	len    int         // number of datagrams in ring
+	closed bool
+}
+
+// push adds a datagram to the queue, and reports whether it did so.
+// When it did not, it does not take ownership of m,
+// and full reports whether the datagram was refused because
+// the queue is full rather than closed.
+func (q *recvQueue) push(m *datagram) (ok, full bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return false, false
+	}
+	if q.len == len(q.ring) && !q.grow() {
+		return false, true
+	}
+	q.ring[(q.head+q.len)%len(q.ring)] = m
+	q.len++
+	return true, false
+}
+
+// grow increases the capacity of a full ring,
+// and reports false if it is already at its limit.
+func (q *recvQueue) grow() bool {
+	limit := q.limit
+	if limit <= 0 {
+		limit = minConnDatagramBacklog
+	}
+	if len(q.ring) >= limit {
+		return false
+	}
+	ring := make([]*datagram, min(max(2*len(q.ring), 8), limit))
+	for i := 0; i < q.len; i++ {
+		ring[i] = q.ring[(q.head+i)%len(q.ring)]
+	}
+	q.ring = ring
+	q.head = 0
+	return true
+}
+
+// pop removes and returns the first datagram in the queue, or nil if it is empty.
+func (q *recvQueue) pop() *datagram {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.len == 0 {
+		return nil
+	}
+	m := q.ring[q.head]
+	q.ring[q.head] = nil
+	q.head = (q.head + 1) % len(q.ring)
+	q.len--
+	return m
+}
+
+// close empties the queue and causes future pushes to fail.
+func (q *recvQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	for ; q.len > 0; q.len-- {
+		q.ring[q.head].recycle()
+		q.ring[q.head] = nil
+		q.head = (q.head + 1) % len(q.ring)
+	}
+}
+
+// queueDatagram delivers a datagram received by the Listener to the conn.
+// It does not block. If the conn has too many datagrams waiting to be handled,
+// the datagram is dropped.
+func (c *Conn) queueDatagram(m *datagram) {
+	if c.pool.shard != nil {
+		c.post(m)
+		return
+	}
+	if ok, full := c.recvq.push(m); !ok {
+		// A closed queue belongs to a conn which has exited;
+		// the datagram isn't a drop due to load.
+		if full {
+			c.listener.stats.datagramsDropped.Add(1)
+		}
+		m.recycle()
+		return
+	}
+	c.wake()
+}
+
+// handleQueuedDatagrams handles datagrams in the conn's recvQueue.
+// It reports whether the loop should exit.
+func (c *Conn) handleQueuedDatagrams(now time.Time) (exit bool) {
+	for !c.exited {
+		m := c.recvq.pop()
+		if m == nil {
+			break
+		}
+		if c.handleEvent(now, m) {
+			return true
+		}
+	}
+	return c.exited
+}
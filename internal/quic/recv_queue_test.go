@@ -0,0 +1,122 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"testing"
+)
+
+func TestRecvQueue(t *testing.T) {
+	var q recvQueue
+	var want []*datagram
+	// Push and pop enough datagrams to wrap around the ring.
+	for i := 0; i < 3*minConnDatagramBacklog/2; i++ {
+		m := &datagram{b: []byte{byte(i)}}
+		if ok, _ := q.push(m); !ok {
+			t.Fatalf("push %v: queue unexpectedly full", i)
+		}
+		want = append(want, m)
+		if len(want) > minConnDatagramBacklog/2 {
+			if got := q.pop(); got != want[0] {
+				t.Fatalf("pop returned datagram %v, want %v", got.b[0], want[0].b[0])
+			}
+			want = want[1:]
+		}
+	}
+	for len(want) > 0 {
+		if got := q.pop(); got != want[0] {
+			t.Fatalf("pop returned datagram %v, want %v", got.b[0], want[0].b[0])
+		}
+		want = want[1:]
+	}
+	if got := q.pop(); got != nil {
+		t.Fatalf("pop from empty queue returned %v, want nil", got)
+	}
+}
+
+func TestRecvQueueFull(t *testing.T) {
+	q := recvQueue{limit: 100}
+	for i := 0; i < q.limit; i++ {
+		if ok, _ := q.push(&datagram{}); !ok {
+			t.Fatalf("push %v: queue unexpectedly full", i)
+		}
+	}
+	if ok, full := q.push(&datagram{}); ok || !full {
+		t.Fatalf("push to full queue = %v, %v; want false, true (full)", ok, full)
+	}
+	if got, want := len(q.ring), q.limit; got != want {
+		t.Errorf("full queue capacity = %v, want limit %v", got, want)
+	}
+	q.pop()
+	if ok, _ := q.push(&datagram{}); !ok {
+		t.Fatalf("push after pop failed, want success")
+	}
+	q.close()
+	if q.pop() != nil {
+		t.Fatalf("pop after close returned datagram, want nil")
+	}
+	if ok, full := q.push(&datagram{}); ok || full {
+		t.Fatalf("push after close = %v, %v; want false, false (closed)", ok, full)
+	}
+}
+
+func TestConnQueueDatagramDropsWhenFull(t *testing.T) {
+	tc := newTestConn(t, serverSide)
+	tc.handshake()
+	// The conn's loop doesn't run until the test waits for it,
+	// so datagrams accumulate in its queue.
+	for i := 0; i < tc.conn.recvq.limit+1; i++ {
+		tc.conn.queueDatagram(&datagram{
+			b:    make([]byte, 100),
+			addr: testClientAddr,
+		})
+	}
+	if got, want := tc.listener.l.Stats().DatagramsDropped, uint64(1); got != want {
+		t.Errorf("DatagramsDropped = %v, want %v", got, want)
+	}
+	tc.wait()
+	if m := tc.conn.recvq.pop(); m != nil {
+		t.Errorf("conn did not handle all queued datagrams")
+	}
+}
+
+func TestConnDatagramBacklogScalesWithReadBuffer(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		config Config
+		want   int
+	}{{
+		name: "default",
+		want: (1 << 20) / paddedInitialDatagramSize,
+	}, {
+		name:   "low memory",
+		config: Config{LowMemory: true},
+		want:   minConnDatagramBacklog,
+	}, {
+		name:   "large buffer",
+		config: Config{MaxConnReadBufferSize: 64 << 20},
+		want:   maxConnDatagramBacklog,
+	}} {
+		if got := test.config.connDatagramBacklog(); got != test.want {
+			t.Errorf("%v: connDatagramBacklog() = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestConnQueueDatagramAfterExitNotCounted(t *testing.T) {
+	tc := newTestConn(t, serverSide)
+	tc.handshake()
+	// A conn's queue is closed when its loop exits.
+	tc.conn.recvq.close()
+	tc.conn.queueDatagram(&datagram{
+		b:    make([]byte, 100),
+		addr: testClientAddr,
+	})
+	if got := tc.listener.l.Stats().DatagramsDropped; got != 0 {
+		t.Errorf("DatagramsDropped = %v after queueing to an exited conn, want 0", got)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"context"
+	"net"
+	"net/netip"
+)
+
+// A Resolver looks up the IP addresses of hosts.
+// It is implemented by *net.Resolver.
+//
+// A custom Resolver may be used to control the addresses dialed
+// by Listener.Dial and Listener.DialAddrs, for example to use
+// split-horizon DNS, DNS over HTTPS, or a service discovery system.
+type Resolver interface {
+	// LookupNetIP looks up host, returning its IP addresses.
+	// The network is "ip", "ip4", or "ip6", and limits the address
+	// families returned as with net.Resolver.LookupNetIP.
+	LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error)
+}
+
+// resolve resolves a "host:port" address for the given network
+// ("udp", "udp4", or "udp6") to a list of IP addresses and a port.
+// IP address literals are parsed without consulting the resolver.
+// As with net.Dial, an empty host refers to the local system.
+func (l *Listener) resolve(ctx context.Context, network, address string) (ips []netip.Addr, port uint16, err error) {
+	var ipNetwork string
+	switch network {
+	case "udp":
+		ipNetwork = "ip"
+	case "udp4":
+		ipNetwork = "ip4"
+	case "udp6":
+		ipNetwork = "ip6"
+	default:
+		return nil, 0, net.UnknownNetworkError(network)
+	}
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, 0, err
+	}
+	p, err := net.DefaultResolver.LookupPort(ctx, network, portStr)
+	if err != nil {
+		return nil, 0, err
+	}
+	if host == "" {
+		if ipNetwork == "ip6" {
+			ips = []netip.Addr{netip.IPv6Loopback()}
+		} else {
+			ips = []netip.Addr{netip.AddrFrom4([4]byte{127, 0, 0, 1})}
+		}
+	} else if ip, err := netip.ParseAddr(host); err == nil {
+		ips = []netip.Addr{ip}
+	} else {
+		var r Resolver = net.DefaultResolver
+		if l.config.Resolver != nil {
+			r = l.config.Resolver
+		}
+		ips, err = r.LookupNetIP(ctx, ipNetwork, host)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	// Filter the results, in case the resolver returned unrequested families.
+	filtered := ips[:0:0]
+	for _, ip := range ips {
+		ip = ip.Unmap()
+		if (ipNetwork == "ip4" && !ip.Is4()) || (ipNetwork == "ip6" && !ip.Is6()) {
+			continue
+		}
+		filtered = append(filtered, ip)
+	}
+	if len(filtered) == 0 {
+		return nil, 0, &net.AddrError{Err: "no suitable address found", Addr: host}
+	}
+	return filtered, uint16(p), nil
+}
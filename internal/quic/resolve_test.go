@@ -0,0 +1,97 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type testResolver struct {
+	hosts   map[string][]netip.Addr
+	lookups []string // network and host of each lookup
+}
+
+func (r *testResolver) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
+	r.lookups = append(r.lookups, network+" "+host)
+	ips, ok := r.hosts[host]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return ips, nil
+}
+
+func TestDialResolver(t *testing.T) {
+	r := &testResolver{
+		hosts: map[string][]netip.Addr{
+			"server.test": {
+				netip.MustParseAddr("2001:db8::1"),
+				netip.MustParseAddr("127.0.0.1"),
+			},
+		},
+	}
+	sl := newLocalListener(t, serverSide, &Config{})
+	cl := newLocalListener(t, clientSide, &Config{Resolver: r})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	port := strconv.Itoa(int(sl.LocalAddr().Port()))
+
+	c, err := cl.Dial(ctx, "udp", net.JoinHostPort("server.test", port))
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	if got, want := c.peerAddr, sl.LocalAddr(); got != want {
+		t.Errorf("Dial connected to %v, want %v", got, want)
+	}
+	c, err = cl.DialAddrs(ctx, "udp4", net.JoinHostPort("server.test", port))
+	if err != nil {
+		t.Fatalf("DialAddrs: %v", err)
+	}
+	if got, want := c.peerAddr, sl.LocalAddr(); got != want {
+		t.Errorf("DialAddrs connected to %v, want %v", got, want)
+	}
+	if _, err := cl.Dial(ctx, "udp", net.JoinHostPort("127.0.0.1", port)); err != nil {
+		t.Fatalf("Dial IP literal: %v", err)
+	}
+	c, err = cl.Dial(ctx, "udp", net.JoinHostPort("", port))
+	if err != nil {
+		t.Fatalf("Dial empty host: %v", err)
+	}
+	if got, want := c.peerAddr, sl.LocalAddr(); got != want {
+		t.Errorf("Dial empty host connected to %v, want %v", got, want)
+	}
+	if _, err := cl.Dial(ctx, "udp", net.JoinHostPort("unknown.test", port)); err == nil {
+		t.Errorf("Dial unknown host succeeded, want error")
+	}
+	if _, err := cl.Dial(ctx, "udp6", net.JoinHostPort("127.0.0.1", port)); err == nil {
+		t.Errorf("Dial udp6 to IPv4 literal succeeded, want error")
+	}
+
+	want := []string{
+		"ip server.test",
+		"ip4 server.test",
+		"ip unknown.test",
+	}
+	if got := r.lookups; !slices.Equal(got, want) {
+		t.Errorf("resolver lookups:\n%q\nwant:\n%q", got, want)
+	}
+}
+
+func TestDialUnknownNetwork(t *testing.T) {
+	cl := newLocalListener(t, clientSide, &Config{})
+	_, err := cl.Dial(context.Background(), "tcp", "127.0.0.1:443")
+	var neterr net.UnknownNetworkError
+	if !errors.As(err, &neterr) {
+		t.Errorf("Dial(\"tcp\", ...) = %v, want net.UnknownNetworkError", err)
+	}
+}
@@ -8,7 +8,6 @@ package quic
 
 import (
 	"bytes"
-	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
 	"encoding/binary"
@@ -18,24 +17,6 @@ import (
 	"golang.org/x/crypto/chacha20poly1305"
 )
 
-// AEAD and nonce used to compute the Retry Integrity Tag.
-// https://www.rfc-editor.org/rfc/rfc9001#section-5.8
-var (
-	retrySecret = []byte{0xbe, 0x0c, 0x69, 0x0b, 0x9f, 0x66, 0x57, 0x5a, 0x1d, 0x76, 0x6b, 0x54, 0xe3, 0x68, 0xc8, 0x4e}
-	retryNonce  = []byte{0x46, 0x15, 0x99, 0xd3, 0x5d, 0x63, 0x2b, 0xf2, 0x23, 0x98, 0x25, 0xbb}
-	retryAEAD   = func() cipher.AEAD {
-		c, err := aes.NewCipher(retrySecret)
-		if err != nil {
-			panic(err)
-		}
-		aead, err := cipher.NewGCM(c)
-		if err != nil {
-			panic(err)
-		}
-		return aead
-	}()
-)
-
 // retryTokenValidityPeriod is how long we accept a Retry packet token after sending it.
 const retryTokenValidityPeriod = 5 * time.Second
 
@@ -44,12 +25,15 @@ type retryState struct {
 	aead cipher.AEAD
 }
 
-func (rs *retryState) init() error {
-	// Retry tokens are authenticated using a per-server key chosen at start time.
-	// TODO: Provide a way for the user to set this key.
-	secret := make([]byte, chacha20poly1305.KeySize)
-	if _, err := rand.Read(secret); err != nil {
-		return err
+func (rs *retryState) init(key [32]byte) error {
+	// Retry tokens are authenticated using the configured key,
+	// or a per-server key chosen at start time.
+	secret := key[:]
+	if key == ([32]byte{}) {
+		secret = make([]byte, chacha20poly1305.KeySize)
+		if _, err := rand.Read(secret); err != nil {
+			return err
+		}
 	}
 	aead, err := chacha20poly1305.NewX(secret)
 	if err != nil {
@@ -139,7 +123,7 @@ func (rs *retryState) additionalData(srcConnID []byte, addr netip.AddrPort) []by
 	return additional
 }
 
-func (l *Listener) validateInitialAddress(now time.Time, p genericLongPacket, addr netip.AddrPort) (origDstConnID []byte, ok bool) {
+func (l *Listener) validateInitialAddress(now time.Time, p genericLongPacket, m *datagram) (origDstConnID []byte, ok bool) {
 	// The retry token is at the start of an Initial packet's data.
 	token, n := consumeUint8Bytes(p.data)
 	if n < 0 {
@@ -151,31 +135,46 @@ func (l *Listener) validateInitialAddress(now time.Time, p genericLongPacket, ad
 	if len(token) == 0 {
 		// The sender has not provided a token.
 		// Send a Retry packet to them with one.
-		l.sendRetry(now, p, addr)
+		l.sendRetry(now, p, m)
 		return nil, false
 	}
-	origDstConnID, ok = l.retry.validateToken(now, token, p.srcConnID, p.dstConnID, addr)
+	origDstConnID, ok = l.retry.validateToken(now, token, p.srcConnID, p.dstConnID, m.addr)
 	if !ok {
 		// This does not seem to be a valid token.
 		// Close the connection with an INVALID_TOKEN error.
 		// https://www.rfc-editor.org/rfc/rfc9000#section-8.1.2-5
-		l.sendConnectionClose(p, addr, errInvalidToken)
+		l.sendConnectionClose(p, m, errInvalidToken)
 		return nil, false
 	}
 	return origDstConnID, true
 }
 
-func (l *Listener) sendRetry(now time.Time, p genericLongPacket, addr netip.AddrPort) {
-	token, srcConnID, err := l.retry.makeToken(now, p.srcConnID, p.dstConnID, addr)
+// hasValidRetryToken reports whether an Initial packet carries a valid token
+// from a Retry packet sent by this listener.
+func (l *Listener) hasValidRetryToken(now time.Time, p genericLongPacket, addr netip.AddrPort) bool {
+	if l.retry.aead == nil {
+		// We never send Retry packets.
+		return false
+	}
+	token, n := consumeUint8Bytes(p.data)
+	if n < 0 || len(token) == 0 {
+		return false
+	}
+	_, ok := l.retry.validateToken(now, token, p.srcConnID, p.dstConnID, addr)
+	return ok
+}
+
+func (l *Listener) sendRetry(now time.Time, p genericLongPacket, m *datagram) {
+	token, srcConnID, err := l.retry.makeToken(now, p.srcConnID, p.dstConnID, m.addr)
 	if err != nil {
 		return
 	}
-	b := encodeRetryPacket(p.dstConnID, retryPacket{
+	b := encodeRetryPacket(paramsForVersion(p.version), p.dstConnID, retryPacket{
 		dstConnID: p.srcConnID,
 		srcConnID: srcConnID,
 		token:     token,
 	})
-	l.sendDatagram(b, addr)
+	l.sendDatagram(b, m.localAddr, m.addr)
 }
 
 type retryPacket struct {
@@ -184,7 +183,7 @@ type retryPacket struct {
 	token     []byte
 }
 
-func encodeRetryPacket(originalDstConnID []byte, p retryPacket) []byte {
+func encodeRetryPacket(vp *versionParams, originalDstConnID []byte, p retryPacket) []byte {
 	// Retry packets include an integrity tag, computed by AEAD_AES_128_GCM over
 	// the original destination connection ID followed by the Retry packet
 	// (less the integrity tag itself).
@@ -195,22 +194,25 @@ func encodeRetryPacket(originalDstConnID []byte, p retryPacket) []byte {
 	var b []byte
 	b = appendUint8Bytes(b, originalDstConnID) // Original Destination Connection ID
 	start := len(b)                            // start of the Retry packet
-	b = append(b, headerFormLong|fixedBit|longPacketTypeRetry)
-	b = binary.BigEndian.AppendUint32(b, quicVersion1) // Version
-	b = appendUint8Bytes(b, p.dstConnID)               // Destination Connection ID
-	b = appendUint8Bytes(b, p.srcConnID)               // Source Connection ID
-	b = append(b, p.token...)                          // Token
-	b = retryAEAD.Seal(b, retryNonce, nil, b)          // Retry Integrity Tag
+	b = append(b, headerFormLong|fixedBit|vp.longPacketTypeBits(packetTypeRetry))
+	b = binary.BigEndian.AppendUint32(b, vp.number) // Version
+	b = appendUint8Bytes(b, p.dstConnID)            // Destination Connection ID
+	b = appendUint8Bytes(b, p.srcConnID)            // Source Connection ID
+	b = append(b, p.token...)                       // Token
+	b = vp.retryAEAD.Seal(b, vp.retryNonce, nil, b) // Retry Integrity Tag
 	return b[start:]
 }
 
-func parseRetryPacket(b, origDstConnID []byte) (p retryPacket, ok bool) {
+func parseRetryPacket(vp *versionParams, b, origDstConnID []byte) (p retryPacket, ok bool) {
 	const retryIntegrityTagLength = 128 / 8
 
 	lp, ok := parseGenericLongHeaderPacket(b)
 	if !ok {
 		return retryPacket{}, false
 	}
+	if lp.version != vp.number {
+		return retryPacket{}, false
+	}
 	if len(lp.data) < retryIntegrityTagLength {
 		return retryPacket{}, false
 	}
@@ -221,7 +223,7 @@ func parseRetryPacket(b, origDstConnID []byte) (p retryPacket, ok bool) {
 	// Use this to validate the packet integrity tag.
 	pseudo := appendUint8Bytes(nil, origDstConnID)
 	pseudo = append(pseudo, b[:len(b)-retryIntegrityTagLength]...)
-	wantTag := retryAEAD.Seal(nil, retryNonce, nil, pseudo)
+	wantTag := vp.retryAEAD.Seal(nil, vp.retryNonce, nil, pseudo)
 	if !bytes.Equal(gotTag, wantTag) {
 		return retryPacket{}, false
 	}
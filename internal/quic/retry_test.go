@@ -429,7 +429,7 @@ func TestRetryClientIgnoresRetryWithInvalidIntegrityTag(t *testing.T) {
 	tc := newTestConn(t, clientSide)
 	tc.wantFrameType("client Initial CRYPTO data",
 		packetTypeInitial, debugFrameCrypto{})
-	pkt := encodeRetryPacket(testLocalConnID(-1), retryPacket{
+	pkt := encodeRetryPacket(version1Params, testLocalConnID(-1), retryPacket{
 		srcConnID: testPeerConnID(100),
 		dstConnID: testLocalConnID(0),
 		token:     []byte{1, 2, 3, 4},
@@ -464,7 +464,7 @@ func TestRetryStateValidateInvalidToken(t *testing.T) {
 	// Test handling of tokens that may have a valid signature,
 	// but unexpected contents.
 	var rs retryState
-	if err := rs.init(); err != nil {
+	if err := rs.init([32]byte{}); err != nil {
 		t.Fatal(err)
 	}
 	nonce := make([]byte, rs.aead.NonceSize())
@@ -497,7 +497,7 @@ func TestRetryStateValidateInvalidToken(t *testing.T) {
 
 func TestParseInvalidRetryPackets(t *testing.T) {
 	originalDstConnID := []byte{1, 2, 3, 4}
-	goodPkt := encodeRetryPacket(originalDstConnID, retryPacket{
+	goodPkt := encodeRetryPacket(version1Params, originalDstConnID, retryPacket{
 		dstConnID: []byte{1},
 		srcConnID: []byte{2},
 		token:     []byte{3},
@@ -520,13 +520,62 @@ func TestParseInvalidRetryPackets(t *testing.T) {
 		}(),
 	}} {
 		t.Run(test.name, func(t *testing.T) {
-			if _, ok := parseRetryPacket(test.pkt, originalDstConnID); ok {
+			if _, ok := parseRetryPacket(version1Params, test.pkt, originalDstConnID); ok {
 				t.Errorf("parseRetryPacket succeded, want failure")
 			}
 		})
 	}
 }
 
+func TestParseRetryPacketTestVectors(t *testing.T) {
+	originalDstConnID := unhex(`8394c8f03e515708`)
+	for _, test := range []struct {
+		name string
+		vp   *versionParams
+		pkt  []byte
+	}{{
+		// https://www.rfc-editor.org/rfc/rfc9001#section-a.4
+		name: "v1",
+		vp:   version1Params,
+		pkt: unhex(`
+			ff000000010008f067a5502a4262b574 6f6b656e04a265ba2eff4d829058fb3f
+			0f2496ba
+		`),
+	}, {
+		// https://www.rfc-editor.org/rfc/rfc9369#section-a.4
+		name: "v2",
+		vp:   version2Params,
+		pkt: unhex(`
+			cf6b3343cf0008f067a5502a4262b574 6f6b656ec8646ce8bfe33952d9555436
+			65dcc7b6
+		`),
+	}} {
+		t.Run(test.name, func(t *testing.T) {
+			p, ok := parseRetryPacket(test.vp, test.pkt, originalDstConnID)
+			if !ok {
+				t.Fatalf("parseRetryPacket failed, want success")
+			}
+			if got, want := p.srcConnID, unhex(`f067a5502a4262b5`); !bytes.Equal(got, want) {
+				t.Errorf("Source Connection ID = %x, want %x", got, want)
+			}
+			if got, want := string(p.token), "token"; got != want {
+				t.Errorf("token = %q, want %q", got, want)
+			}
+			if got := getPacketType(test.pkt); got != packetTypeRetry {
+				t.Errorf("getPacketType = %v, want Retry", got)
+			}
+			for _, vp := range []*versionParams{version1Params, version2Params} {
+				if vp == test.vp {
+					continue
+				}
+				if _, ok := parseRetryPacket(vp, test.pkt, originalDstConnID); ok {
+					t.Errorf("parseRetryPacket with version %x succeeded, want failure", vp.number)
+				}
+			}
+		})
+	}
+}
+
 func initialClientCrypto(t *testing.T, l *testListener, p transportParameters) []byte {
 	t.Helper()
 	config := &tls.QUICConfig{TLSConfig: newTestTLSConfig(clientSide)}
@@ -566,3 +615,29 @@ func initialConnectionCloseDatagram(srcConnID, dstConnID []byte, code transportE
 		}},
 	}
 }
+
+func TestRetryStateSharedKey(t *testing.T) {
+	key := [32]byte{1, 2, 3}
+	var rs1, rs2, rs3 retryState
+	for _, rs := range []*retryState{&rs1, &rs2} {
+		if err := rs.init(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := rs3.init([32]byte{}); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	srcConnID := []byte{1, 2, 3, 4}
+	origDstConnID := []byte{5, 6, 7, 8}
+	token, dstConnID, err := rs1.makeToken(now, srcConnID, origDstConnID, testClientAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := rs2.validateToken(now, token, srcConnID, dstConnID, testClientAddr); !ok || !bytes.Equal(got, origDstConnID) {
+		t.Errorf("token not accepted by retryState with the same key")
+	}
+	if _, ok := rs3.validateToken(now, token, srcConnID, dstConnID, testClientAddr); ok {
+		t.Errorf("token accepted by retryState with a random key")
+	}
+}
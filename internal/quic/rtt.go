@@ -18,12 +18,12 @@ type rttState struct {
 	firstSampleTime time.Time     // time of first RTT sample
 }
 
-func (r *rttState) init() {
-	r.minRTT = -1 // -1 indicates the first sample has not been taken yet
+// "[...] the initial RTT SHOULD be set to 333 milliseconds."
+// https://www.rfc-editor.org/rfc/rfc9002.html#section-6.2.2-1
+const defaultInitialRTT = 333 * time.Millisecond
 
-	// "[...] the initial RTT SHOULD be set to 333 milliseconds."
-	// https://www.rfc-editor.org/rfc/rfc9002.html#section-6.2.2-1
-	const initialRTT = 333 * time.Millisecond
+func (r *rttState) init(initialRTT time.Duration) {
+	r.minRTT = -1 // -1 indicates the first sample has not been taken yet
 
 	// https://www.rfc-editor.org/rfc/rfc9002.html#section-5.3-12
 	r.smoothedRTT = initialRTT
@@ -19,7 +19,7 @@ func TestRTTMinRTT(t *testing.T) {
 		now                = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
 	)
 	rtt := &rttState{}
-	rtt.init()
+	rtt.init(defaultInitialRTT)
 
 	// "min_rtt MUST be set to the latest_rtt on the first RTT sample."
 	// https://www.rfc-editor.org/rfc/rfc9002.html#section-5.2-2
@@ -73,7 +73,7 @@ func TestRTTInitialRTT(t *testing.T) {
 		now                = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
 	)
 	rtt := &rttState{}
-	rtt.init()
+	rtt.init(defaultInitialRTT)
 
 	// "When no previous RTT is available,
 	// the initial RTT SHOULD be set to 333 milliseconds."
@@ -0,0 +1,46 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"crypto/tls"
+	"fmt"
+)
+
+// alertUnrecognizedName is the TLS alert sent when a server rejects the server name
+// requested by a client.
+// https://www.rfc-editor.org/rfc/rfc6066#section-3
+const alertUnrecognizedName = tls.AlertError(112)
+
+// serverNameTLSConfig returns a TLS configuration for a server conn
+// which applies Config.RequireServerName and Config.VerifyServerName
+// when the ClientHello is received.
+//
+// The check runs before any other GetConfigForClient hook,
+// including the one installed for Config.GetConfigForConn.
+func (c *Conn) serverNameTLSConfig(tlsConfig *tls.Config) *tls.Config {
+	requireServerName := c.config.RequireServerName
+	verifyServerName := c.config.VerifyServerName
+	getConfigForClient := tlsConfig.GetConfigForClient
+	tlsConfig = tlsConfig.Clone()
+	tlsConfig.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		if requireServerName && hello.ServerName == "" {
+			// Wrap the alert, so the conn closes with it rather than internal_error.
+			return nil, fmt.Errorf("quic: client did not send a server name%.0w", alertUnrecognizedName)
+		}
+		if verifyServerName != nil {
+			if err := verifyServerName(hello.ServerName); err != nil {
+				return nil, fmt.Errorf("quic: server name %q rejected: %w%.0w", hello.ServerName, err, alertUnrecognizedName)
+			}
+		}
+		if getConfigForClient != nil {
+			return getConfigForClient(hello)
+		}
+		return nil, nil
+	}
+	return tlsConfig
+}
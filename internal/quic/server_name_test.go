@@ -0,0 +1,101 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net/netip"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// dialServerName connects to a server with the given SNI,
+// and returns the error from Dial, if any.
+func dialServerName(t *testing.T, srv *Listener, serverName string) error {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	config := &Config{TLSConfig: newTestTLSConfig(clientSide)}
+	config.TLSConfig.ServerName = serverName
+	cli := newLocalListener(t, clientSide, config)
+	c, err := cli.Dial(ctx, "udp", srv.LocalAddr().String())
+	if err != nil {
+		return err
+	}
+	c.Abort(nil)
+	return nil
+}
+
+func wantUnrecognizedName(t *testing.T, err error) {
+	t.Helper()
+	var perr peerTransportError
+	want := errTLSBase + transportError(alertUnrecognizedName)
+	if !errors.As(err, &perr) || perr.code != want {
+		t.Errorf("Dial error: %v, want peer error %v", err, want)
+	}
+}
+
+func TestRequireServerName(t *testing.T) {
+	srv := newLocalListener(t, serverSide, &Config{
+		RequireServerName: true,
+	})
+	if err := dialServerName(t, srv, "example.com"); err != nil {
+		t.Errorf("Dial with server name: %v", err)
+	}
+	wantUnrecognizedName(t, dialServerName(t, srv, ""))
+}
+
+func TestVerifyServerName(t *testing.T) {
+	var (
+		mu  sync.Mutex
+		got []string
+	)
+	srv := newLocalListener(t, serverSide, &Config{
+		VerifyServerName: func(serverName string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			got = append(got, serverName)
+			if serverName != "example.com" {
+				return errors.New("unknown host")
+			}
+			return nil
+		},
+	})
+	if err := dialServerName(t, srv, "example.com"); err != nil {
+		t.Errorf("Dial with known server name: %v", err)
+	}
+	wantUnrecognizedName(t, dialServerName(t, srv, "unknown.example"))
+	wantUnrecognizedName(t, dialServerName(t, srv, ""))
+	want := []string{"example.com", "unknown.example", ""}
+	mu.Lock()
+	defer mu.Unlock()
+	if !slices.Equal(got, want) {
+		t.Errorf("VerifyServerName called with %q, want %q", got, want)
+	}
+}
+
+func TestVerifyServerNameBeforeGetConfigForConn(t *testing.T) {
+	var getConfigCalled atomic.Bool
+	srv := newLocalListener(t, serverSide, &Config{
+		VerifyServerName: func(serverName string) error {
+			return errors.New("unknown host")
+		},
+		GetConfigForConn: func(hello *tls.ClientHelloInfo, remoteAddr netip.AddrPort) (*Config, error) {
+			getConfigCalled.Store(true)
+			return nil, nil
+		},
+	})
+	wantUnrecognizedName(t, dialServerName(t, srv, "example.com"))
+	if getConfigCalled.Load() {
+		t.Errorf("GetConfigForConn called for rejected server name")
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import "crypto/rand"
+
+// spinState tracks the latency spin bit.
+//
+// The spin bit allows an on-path observer to measure the connection's RTT.
+// https://www.rfc-editor.org/rfc/rfc9000#section-17.4
+type spinState struct {
+	// enabled is set when we maintain the spin bit for this connection.
+	// When false, value is a random bit chosen at connection creation.
+	enabled bool
+
+	// value is the spin bit we set in outgoing 1-RTT packets.
+	value bool
+}
+
+func (c *Conn) spinInit() {
+	var b [1]byte
+	rand.Read(b[:])
+	// "[...] an endpoint MUST disable spinning for at least one in every
+	// 16 network paths, or for one in every 16 connection IDs [...]"
+	// https://www.rfc-editor.org/rfc/rfc9000#section-17.4-8
+	c.spin.enabled = !c.config.DisableSpinBit && b[0]&0x0f != 0
+	if !c.spin.enabled {
+		// "It is RECOMMENDED that endpoints set the spin bit
+		// to a random value either chosen independently for each packet
+		// or chosen independently for each connection ID."
+		// https://www.rfc-editor.org/rfc/rfc9000#section-17.4-6
+		c.spin.value = b[0]&0x10 != 0
+	}
+}
+
+// handleSpinBit updates the spin bit upon receiving a 1-RTT packet
+// with packet number num and first header byte hdr.
+// It must be called before the packet is recorded as received.
+func (c *Conn) handleSpinBit(hdr byte, num packetNumber) {
+	if !c.spin.enabled {
+		return
+	}
+	if acks := &c.acks[appDataSpace]; acks.seen.numRanges() > 0 && num <= acks.largestSeen() {
+		// We only update the spin value on the packet with
+		// the largest packet number received so far.
+		return
+	}
+	peerValue := hdr&spinBit != 0
+	if c.side == serverSide {
+		// "[...] a server MUST set the spin bit value to the value
+		// of the spin bit in the packet with the largest packet number [...]"
+		// https://www.rfc-editor.org/rfc/rfc9000#section-17.4-11
+		c.spin.value = peerValue
+	} else {
+		// "[...] a client MUST set the spin value to the inverse of the spin bit
+		// in the packet with the largest packet number [...]"
+		// https://www.rfc-editor.org/rfc/rfc9000#section-17.4-12
+		c.spin.value = !peerValue
+	}
+}
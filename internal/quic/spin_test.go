@@ -0,0 +1,105 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import "testing"
+
+// newSpinTestConn returns a conn which has completed the handshake
+// with the spin bit enabled.
+func newSpinTestConn(t *testing.T, side connSide) *testConn {
+	t.Helper()
+	tc := newTestConn(t, side)
+	// Avoid the random disabling of the spin bit.
+	tc.conn.spin.enabled = true
+	tc.conn.spin.value = false
+	tc.handshake()
+	return tc
+}
+
+// spinRoundTrip sends the conn a 1-RTT packet with the given spin bit,
+// and returns the spin bit of the conn's response.
+func (tc *testConn) spinRoundTrip(peerSpin bool) bool {
+	tc.t.Helper()
+	tc.sendSpinBit = peerSpin
+	tc.writeFrames(packetType1RTT, debugFramePing{})
+	tc.advanceToTimer()
+	tc.wantFrameType("conn sends ACK",
+		packetType1RTT, debugFrameAck{})
+	return tc.lastSpinBit
+}
+
+func TestSpinBitServerReflects(t *testing.T) {
+	tc := newSpinTestConn(t, serverSide)
+	for _, spin := range []bool{true, false, true} {
+		if got := tc.spinRoundTrip(spin); got != spin {
+			t.Errorf("peer sends spin=%v, server responds with spin=%v; want %v", spin, got, spin)
+		}
+	}
+}
+
+func TestSpinBitClientInverts(t *testing.T) {
+	tc := newSpinTestConn(t, clientSide)
+	for _, spin := range []bool{true, false, true} {
+		if got := tc.spinRoundTrip(spin); got != !spin {
+			t.Errorf("peer sends spin=%v, client responds with spin=%v; want %v", spin, got, !spin)
+		}
+	}
+}
+
+func TestSpinBitIgnoresReorderedPackets(t *testing.T) {
+	tc := newSpinTestConn(t, serverSide)
+	// Skip a packet number, and send the skipped packet after its successor.
+	// The conn acks both immediately, since they are out of order.
+	num := tc.peerNextPacketNum[appDataSpace]
+	tc.peerNextPacketNum[appDataSpace] = num + 1
+	tc.sendSpinBit = true
+	tc.writeFrames(packetType1RTT, debugFramePing{})
+	tc.wantFrameType("conn acks packet after gap",
+		packetType1RTT, debugFrameAck{})
+	if !tc.lastSpinBit {
+		t.Fatalf("peer sends spin=true, server responds with spin=false")
+	}
+	tc.peerNextPacketNum[appDataSpace] = num
+	tc.sendSpinBit = false
+	tc.writeFrames(packetType1RTT, debugFramePing{})
+	tc.wantFrameType("conn acks reordered packet",
+		packetType1RTT, debugFrameAck{})
+	if !tc.lastSpinBit {
+		t.Errorf("after reordered packet with spin=false, server responds with spin=false; want true")
+	}
+}
+
+func TestSpinBitDisabled(t *testing.T) {
+	tc := newTestConn(t, serverSide, func(c *Config) {
+		c.DisableSpinBit = true
+	})
+	tc.handshake()
+	want := tc.conn.spin.value
+	for _, spin := range []bool{true, false, true, false} {
+		if got := tc.spinRoundTrip(spin); got != want {
+			t.Errorf("with spin bit disabled, peer sends spin=%v, server responds with spin=%v; want %v", spin, got, want)
+		}
+	}
+}
+
+func TestSpinBitRandomlyDisabled(t *testing.T) {
+	// The spin bit is disabled for one in every 16 connections.
+	const count = 16 * 100
+	disabled := 0
+	for i := 0; i < count; i++ {
+		c := &Conn{config: &Config{}}
+		c.spinInit()
+		if !c.spin.enabled {
+			disabled++
+		}
+	}
+	// The expected number of disabled connections is 100.
+	// Allow for a great deal of variance.
+	if disabled < 25 || disabled > 400 {
+		t.Errorf("spin bit disabled for %v of %v connections, want about %v", disabled, count, count/16)
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"errors"
 	"net/netip"
 	"testing"
+	"time"
 )
 
 func TestStatelessResetClientSendsStatelessResetTokenTransportParameter(t *testing.T) {
@@ -33,6 +34,18 @@ func TestStatelessResetClientSendsStatelessResetTokenTransportParameter(t *testi
 		})
 }
 
+func TestStatelessResetServerSendsStatelessResetTokenTransportParameter(t *testing.T) {
+	// The server provides a token for the connection ID it uses during the handshake,
+	// so the client can recognize a reset for a connection which never changed IDs.
+	// https://www.rfc-editor.org/rfc/rfc9000#section-18.2-4.6.1
+	tc := newTestConn(t, serverSide)
+	tc.handshake()
+	got := tc.sentTransportParameters.statelessResetToken
+	if want := testLocalStatelessResetToken(0); !bytes.Equal(got, want[:]) {
+		t.Errorf("server stateless_reset_token transport parameter = %x, want %x", got, want)
+	}
+}
+
 var testStatelessResetKey = func() (key [32]byte) {
 	if _, err := rand.Read(key[:]); err != nil {
 		panic(err)
@@ -275,3 +288,54 @@ func TestStatelessResetRetiredConnID(t *testing.T) {
 		t.Errorf("conn.Wait() = %v, want connection to be alive", err)
 	}
 }
+
+func TestStatelessResetAfterRestart(t *testing.T) {
+	// A server which restarts with the same StatelessResetKey
+	// resets connections created before the restart,
+	// without needing any record of the connection IDs it issued.
+	ctx := context.Background()
+	srv1 := newLocalListener(t, serverSide, &Config{
+		StatelessResetKey: testStatelessResetKey,
+	})
+	cli := newLocalListener(t, clientSide, &Config{})
+	c, err := cli.Dial(ctx, "udp", srv1.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := srv1.Accept(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate a server crash: stop the server's connections
+	// without informing the client, and close its socket.
+	srv1.connsMu.Lock()
+	for sc := range srv1.conns {
+		sc.exit()
+	}
+	srv1.connsMu.Unlock()
+	srv1.udpConn.Close()
+
+	// Restart the server on the same address with the same key.
+	srv2, err := Listen("udp", srv1.LocalAddr().String(), &Config{
+		TLSConfig:         newTestTLSConfig(serverSide),
+		StatelessResetKey: testStatelessResetKey,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv2.Close(canceledContext())
+
+	// The client's next packet reaches the restarted server,
+	// which responds with a stateless reset.
+	s, err := c.NewStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Write([]byte("hello"))
+	s.CloseWrite()
+	waitCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if err := c.Wait(waitCtx); !errors.Is(err, errStatelessReset) {
+		t.Errorf("conn.Wait() = %v, want errStatelessReset", err)
+	}
+}
@@ -9,8 +9,9 @@ package quic
 import (
 	"context"
 	"errors"
-	"fmt"
 	"io"
+	"sync/atomic"
+	"time"
 )
 
 type Stream struct {
@@ -26,10 +27,14 @@ type Stream struct {
 	inwin       int64           // last MAX_STREAM_DATA sent to the peer
 	insendmax   sentVal         // set when we should send MAX_STREAM_DATA to the peer
 	inmaxbuf    int64           // maximum amount of data we will buffer
+	intuner     windowTuner     // sizes the flow control window
 	insize      int64           // stream final size; -1 before this is known
 	inset       rangeset[int64] // received ranges
 	inclosed    sentVal         // set by CloseRead
+	instopcode  uint64          // error code to send in STOP_SENDING
 	inresetcode int64           // RESET_STREAM code received from the peer; -1 if not reset
+	inpeekwant  int64           // amount of data a blocked PeekContext is waiting for
+	inpeekbuf   []byte          // buffer holding data returned by PeekContext
 
 	// outgate's lock guards all send-related state.
 	//
@@ -41,15 +46,24 @@ type Stream struct {
 	outwin       int64           // maximum MAX_STREAM_DATA received from the peer
 	outmaxsent   int64           // maximum data offset we've sent to the peer
 	outmaxbuf    int64           // maximum amount of data we will buffer
-	outunsent    rangeset[int64] // ranges buffered but not yet sent
+	outunsent    rangeset[int64] // ranges flushed but not yet sent
+	outflushed   int64           // offset of last flush
+	outmanual    bool            // set by SetAutoFlush(false)
 	outacked     rangeset[int64] // ranges sent and acknowledged
 	outopened    sentVal         // set if we should open the stream
 	outclosed    sentVal         // set by CloseWrite
 	outblocked   sentVal         // set when a write to the stream is blocked by flow control
 	outreset     sentVal         // set by Reset
 	outresetcode uint64          // reset code to send in RESET_STREAM
+	outstopcode  int64           // STOP_SENDING code received from the peer; -1 if not received
 	outdone      chan struct{}   // closed when all data sent
 
+	// Context canceled when the stream is reset by the peer
+	// or the conn terminates; guarded by ingate's lock.
+	ctx       context.Context
+	ctxCancel context.CancelCauseFunc
+	ctxStop   func() bool // stops the conn's termination from canceling ctx
+
 	// Atomic stream state bits.
 	//
 	// These bits provide a fast way to coordinate between the
@@ -61,9 +75,17 @@ type Stream struct {
 	// streamQueue* bits must be set with streamsState.sendMu held.
 	state atomicBits[streamState]
 
-	prev, next *Stream // guarded by streamsState.sendMu
+	prev, next *Stream       // guarded by streamsState.sendMu
+	urgency    atomic.Uint32 // send priority, set with streamsState.sendMu held
 }
 
+const (
+	// Stream urgency levels, as defined by RFC 9218.
+	// Lower values are more urgent.
+	maxStreamUrgency     = 7
+	defaultStreamUrgency = 3
+)
+
 type streamState uint32
 
 const (
@@ -143,9 +165,11 @@ func newStream(c *Conn, id streamID) *Stream {
 		id:          id,
 		insize:      -1, // -1 indicates the stream size is unknown
 		inresetcode: -1, // -1 indicates no RESET_STREAM received
+		outstopcode: -1, // -1 indicates no STOP_SENDING received
 		ingate:      newLockedGate(),
 		outgate:     newLockedGate(),
 	}
+	s.urgency.Store(defaultStreamUrgency)
 	if !s.IsReadOnly() {
 		s.outdone = make(chan struct{})
 	}
@@ -164,6 +188,64 @@ func (s *Stream) IsWriteOnly() bool {
 	return s.id.streamType() == uniStream && s.id.initiator() == s.conn.side
 }
 
+// ID returns the stream's QUIC stream ID.
+// https://www.rfc-editor.org/rfc/rfc9000#section-2.1
+func (s *Stream) ID() int64 {
+	return int64(s.id)
+}
+
+// Conn returns the connection the stream belongs to.
+func (s *Stream) Conn() *Conn {
+	return s.conn
+}
+
+// Context returns a context which is canceled when the peer resets the stream
+// or the connection terminates.
+// The context's cause (see context.Cause) describes the reason:
+// a *StreamResetError for a reset, or the connection's error.
+//
+// A handler may use the context to abandon work on behalf of a stream
+// which the peer is no longer interested in.
+// Once the stream has been closed in both directions,
+// its context is no longer canceled when the connection terminates.
+func (s *Stream) Context() context.Context {
+	s.ingate.lock()
+	defer s.inUnlock()
+	if s.ctx != nil {
+		return s.ctx
+	}
+	s.ctx, s.ctxCancel = context.WithCancelCause(context.Background())
+	if s.inresetcode != -1 {
+		s.ctxCancel(s.resetErrorLocked())
+		return s.ctx
+	}
+	if s.state.load()&streamConnRemoved != 0 {
+		return s.ctx
+	}
+	connCtx := s.conn.lifetime.ctx
+	s.ctxStop = context.AfterFunc(connCtx, func() {
+		s.ctxCancel(context.Cause(connCtx))
+	})
+	return s.ctx
+}
+
+// resetErrorLocked returns the error for a stream reset by the peer.
+// s.ingate must be held.
+func (s *Stream) resetErrorLocked() error {
+	return &StreamResetError{Code: uint64(s.inresetcode), codes: s.conn.errorCodeSpace()}
+}
+
+// contextRemoved is called when a finished stream is removed from the conn.
+// The stream's context, if any, is no longer canceled by the conn terminating.
+func (s *Stream) contextRemoved() {
+	s.ingate.lock()
+	defer s.inUnlockNoQueue()
+	if s.ctxStop != nil {
+		s.ctxStop()
+		s.ctxStop = nil
+	}
+}
+
 // Read reads data from the stream.
 // See ReadContext for more details.
 func (s *Stream) Read(b []byte) (n int, err error) {
@@ -178,6 +260,11 @@ func (s *Stream) Read(b []byte) (n int, err error) {
 // returning all data sent by the peer.
 // If the peer aborts reads on the stream, ReadContext returns
 // an error wrapping StreamResetCode.
+//
+// If ctx is done before any data is available, ReadContext returns ctx.Err().
+// The stream is unaffected, and data arriving later may be read by a subsequent call.
+// Use a context to cancel a single read without closing the stream;
+// use CloseRead or Stream.Context to abandon the stream as a whole.
 func (s *Stream) ReadContext(ctx context.Context, b []byte) (n int, err error) {
 	if s.IsWriteOnly() {
 		return 0, errors.New("read from write-only stream")
@@ -190,7 +277,7 @@ func (s *Stream) ReadContext(ctx context.Context, b []byte) (n int, err error) {
 		s.conn.handleStreamBytesReadOffLoop(int64(n)) // must be done with ingate unlocked
 	}()
 	if s.inresetcode != -1 {
-		return 0, fmt.Errorf("stream reset by peer: %w", StreamErrorCode(s.inresetcode))
+		return 0, s.resetErrorLocked()
 	}
 	if s.inclosed.isSet() {
 		return 0, errors.New("read from closed stream")
@@ -208,17 +295,137 @@ func (s *Stream) ReadContext(ctx context.Context, b []byte) (n int, err error) {
 	start := s.in.start
 	end := start + int64(len(b))
 	s.in.copy(start, b)
+	s.inDiscardBeforeLocked(end)
+	if end == s.insize {
+		return len(b), io.EOF
+	}
+	return len(b), nil
+}
+
+// Peek returns the next n bytes of data from the stream without consuming them.
+// See PeekContext for more details.
+func (s *Stream) Peek(n int) ([]byte, error) {
+	return s.PeekContext(context.Background(), n)
+}
+
+// PeekContext returns the next n bytes of data from the stream without consuming them.
+// The returned slice is only valid until the next read from the stream.
+//
+// PeekContext blocks until n bytes of data are available.
+// If the peer closes the stream cleanly with fewer than n bytes remaining,
+// PeekContext returns the remaining data and io.EOF.
+// If the peer aborts reads on the stream, PeekContext returns
+// an error wrapping StreamErrorCode.
+//
+// If ctx is done before n bytes are available, PeekContext returns ctx.Err(),
+// and leaves the stream's data unconsumed.
+//
+// n may not be larger than the stream's read buffer
+// (set by Config.MaxStreamReadBufferSize).
+func (s *Stream) PeekContext(ctx context.Context, n int) (b []byte, err error) {
+	if s.IsWriteOnly() {
+		return nil, errors.New("peek from write-only stream")
+	}
+	if n < 0 || int64(n) > s.inmaxbuf {
+		return nil, errors.New("peek size out of range")
+	}
+	// Record the amount of data we want, so the gate condition
+	// is only set once that much data is available.
+	s.ingate.lock()
+	s.inpeekwant = int64(n)
+	if s.insize == -1 && s.in.start+int64(n) > s.inwin {
+		// The peer can't send us as much data as we want.
+		// Update stream flow control with a STREAM_MAX_DATA frame.
+		s.insendmax.setUnsent()
+	}
+	s.inUnlock()
+	err = s.ingate.waitAndLock(ctx, s.conn.testHooks)
+	if err != nil {
+		s.ingate.lock()
+		s.inpeekwant = 0
+		s.inUnlock()
+		return nil, err
+	}
+	s.inpeekwant = 0
+	defer s.inUnlock()
+	if s.inresetcode != -1 {
+		return nil, s.resetErrorLocked()
+	}
+	if s.inclosed.isSet() {
+		return nil, errors.New("read from closed stream")
+	}
+	if s.insize == s.in.start {
+		return nil, io.EOF
+	}
+	size := min(int64(n), s.inAvailable())
+	if int64(cap(s.inpeekbuf)) < size {
+		s.inpeekbuf = make([]byte, size)
+	}
+	b = s.inpeekbuf[:size]
+	s.in.copy(s.in.start, b)
+	if s.in.start+size == s.insize {
+		return b, io.EOF
+	}
+	return b, nil
+}
+
+// Discard discards up to n bytes of data from the stream
+// without blocking, and returns the number of bytes discarded.
+// It discards fewer than n bytes if less data is available to read.
+//
+// If the peer closes the stream cleanly, Discard returns io.EOF after
+// discarding all data sent by the peer.
+// If the peer aborts reads on the stream, Discard returns
+// an error wrapping StreamErrorCode.
+func (s *Stream) Discard(n int) (discarded int, err error) {
+	if s.IsWriteOnly() {
+		return 0, errors.New("discard from write-only stream")
+	}
+	if n < 0 {
+		return 0, errors.New("negative discard size")
+	}
+	s.ingate.lock()
+	defer func() {
+		s.inUnlock()
+		s.conn.handleStreamBytesReadOffLoop(int64(discarded)) // must be done with ingate unlocked
+	}()
+	if s.inresetcode != -1 {
+		return 0, s.resetErrorLocked()
+	}
+	if s.inclosed.isSet() {
+		return 0, errors.New("read from closed stream")
+	}
+	if s.insize == s.in.start {
+		return 0, io.EOF
+	}
+	size := min(int64(n), s.inAvailable())
+	end := s.in.start + size
+	s.inDiscardBeforeLocked(end)
+	if end == s.insize {
+		return int(size), io.EOF
+	}
+	return int(size), nil
+}
+
+// inAvailable returns the amount of data which may be read from the stream
+// without blocking.
+func (s *Stream) inAvailable() int64 {
+	if len(s.inset) < 1 || s.inset[0].start > s.in.start || s.inset[0].end <= s.in.start {
+		return 0
+	}
+	return s.inset[0].end - s.in.start
+}
+
+// inDiscardBeforeLocked consumes received data prior to end,
+// and updates stream flow control.
+func (s *Stream) inDiscardBeforeLocked(end int64) {
 	s.in.discardBefore(end)
 	if s.insize == -1 || s.insize > s.inwin {
-		if shouldUpdateFlowControl(s.inmaxbuf, s.in.start+s.inmaxbuf-s.inwin) {
+		if shouldUpdateFlowControl(s.intuner.size, s.in.start+s.intuner.size-s.inwin) {
 			// Update stream flow control with a STREAM_MAX_DATA frame.
 			s.insendmax.setUnsent()
 		}
 	}
-	if end == s.insize {
-		return len(b), io.EOF
-	}
-	return len(b), nil
 }
 
 // shouldUpdateFlowControl determines whether to send a flow control window update.
@@ -238,16 +445,48 @@ func (s *Stream) Write(b []byte) (n int, err error) {
 // WriteContext writes data to the stream.
 //
 // WriteContext writes data to the stream write buffer.
-// Buffered data is only sent when the buffer is sufficiently full.
-// Call the Flush method to ensure buffered data is sent.
+// By default, buffered data is sent as soon as flow and congestion control permit.
+// If automatic flushing has been disabled with SetAutoFlush,
+// data is only sent when the buffer is full, or when Flush or Close is called.
 //
-// TODO: Implement Flush.
+// If ctx is done while WriteContext is blocked waiting for space in the
+// write buffer, WriteContext returns the number of bytes buffered so far and ctx.Err().
+// The buffered bytes are still sent, and the stream may continue to be used:
+// A subsequent write may resume from b[n:].
 func (s *Stream) WriteContext(ctx context.Context, b []byte) (n int, err error) {
+	nn, err := s.write(ctx, b, nil)
+	return int(nn), err
+}
+
+// WriteBuffers writes the contents of bufs to the stream.
+// See WriteBuffersContext for more details.
+func (s *Stream) WriteBuffers(bufs [][]byte) (n int64, err error) {
+	return s.WriteBuffersContext(context.Background(), bufs)
+}
+
+// WriteBuffersContext writes the contents of bufs to the stream,
+// as if the slices were concatenated and passed to a single WriteContext call.
+// Each slice is copied directly into the stream write buffer,
+// so a protocol may write a separately-built header and payload
+// without first concatenating them.
+//
+// It returns the total number of bytes written.
+// It does not modify bufs.
+func (s *Stream) WriteBuffersContext(ctx context.Context, bufs [][]byte) (n int64, err error) {
+	return s.write(ctx, nil, bufs)
+}
+
+// write writes b followed by the contents of bufs to the stream.
+func (s *Stream) write(ctx context.Context, b []byte, bufs [][]byte) (n int64, err error) {
 	if s.IsReadOnly() {
 		return 0, errors.New("write to read-only stream")
 	}
 	canWrite := s.outgate.lock()
 	for {
+		// b is the remaining part of the slice currently being written.
+		for len(b) == 0 && len(bufs) > 0 {
+			b, bufs = bufs[0], bufs[1:]
+		}
 		// The first time through this loop, we may or may not be write blocked.
 		// We exit the loop after writing all data, so on subsequent passes through
 		// the loop we are always write blocked.
@@ -261,6 +500,10 @@ func (s *Stream) WriteContext(ctx context.Context, b []byte) (n int, err error)
 			// write blocked. (Unlike traditional condition variables, gates do not
 			// have spurious wakeups.)
 		}
+		if s.outstopcode != -1 {
+			s.outUnlock()
+			return n, &StreamStoppedError{Code: uint64(s.outstopcode), codes: s.conn.errorCodeSpace()}
+		}
 		if s.outreset.isSet() {
 			s.outUnlock()
 			return n, errors.New("write to reset stream")
@@ -269,38 +512,102 @@ func (s *Stream) WriteContext(ctx context.Context, b []byte) (n int, err error)
 			s.outUnlock()
 			return n, errors.New("write to closed stream")
 		}
-		// We set outopened here rather than below,
-		// so if this is a zero-length write we still
-		// open the stream despite not writing any data to it.
-		s.outopened.set()
 		if len(b) == 0 {
 			break
 		}
 		// Write limit is our send buffer limit.
 		// This is a stream offset.
 		lim := s.out.start + s.outmaxbuf
-		// Amount to write is min(the full buffer, data up to the write limit).
-		// This is a number of bytes.
-		nn := min(int64(len(b)), lim-s.out.end)
-		// Copy the data into the output buffer and mark it as unsent.
-		if s.out.end <= s.outwin {
-			s.outunsent.add(s.out.end, min(s.out.end+nn, s.outwin))
+		for len(b) > 0 && s.out.end < lim {
+			// Amount to write is min(the full slice, data up to the write limit).
+			// This is a number of bytes.
+			nn := min(int64(len(b)), lim-s.out.end)
+			// Copy the data into the output buffer.
+			s.out.writeAt(b[:nn], s.out.end)
+			b = b[nn:]
+			n += nn
+			for len(b) == 0 && len(bufs) > 0 {
+				b, bufs = bufs[0], bufs[1:]
+			}
 		}
-		s.out.writeAt(b[:nn], s.out.end)
-		b = b[nn:]
-		n += int(nn)
-		if s.out.end > s.outwin {
-			// We're blocked by flow control.
-			// Send a STREAM_DATA_BLOCKED frame to let the peer know.
-			s.outblocked.set()
+		if len(b) > 0 {
+			// The buffer is full.
+			// Flush it, even if automatic flushing is disabled,
+			// so the data can be sent and acknowledged to make room.
+			s.flushLocked()
 		}
 		// If we have bytes left to send, we're blocked.
 		canWrite = false
 	}
+	if !s.outmanual {
+		// We flush even if this is a zero-length write,
+		// so as to open the stream despite not writing any data to it.
+		s.flushLocked()
+	}
 	s.outUnlock()
 	return n, nil
 }
 
+// Flush sends any data buffered by previous writes to the peer.
+// It does not wait for the data to be sent or acknowledged.
+//
+// Flush is only needed when automatic flushing has been disabled with SetAutoFlush.
+// If the stream has not yet been opened, Flush opens it.
+func (s *Stream) Flush() error {
+	if s.IsReadOnly() {
+		return errors.New("flush of read-only stream")
+	}
+	s.outgate.lock()
+	defer s.outUnlock()
+	if s.outstopcode != -1 {
+		return &StreamStoppedError{Code: uint64(s.outstopcode), codes: s.conn.errorCodeSpace()}
+	}
+	if s.outreset.isSet() {
+		return errors.New("flush of reset stream")
+	}
+	s.flushLocked()
+	return nil
+}
+
+// SetAutoFlush sets whether data written to the stream is sent immediately.
+//
+// Automatic flushing is enabled by default, and each write makes its data
+// available to send as soon as flow and congestion control permit.
+// When it is disabled, written data is buffered until the write buffer
+// (see Config.MaxStreamWriteBufferSize) is full, or until Flush or Close is called.
+// This lets an application coalesce many small writes into fewer, fuller packets.
+//
+// Enabling automatic flushing flushes any buffered data.
+func (s *Stream) SetAutoFlush(autoFlush bool) {
+	if s.IsReadOnly() {
+		return
+	}
+	s.outgate.lock()
+	defer s.outUnlock()
+	s.outmanual = !autoFlush
+	if autoFlush {
+		s.flushLocked()
+	}
+}
+
+// flushLocked makes all buffered data available to send.
+// s.outgate must be held.
+func (s *Stream) flushLocked() {
+	if s.outreset.isSet() {
+		return
+	}
+	s.outopened.set()
+	if s.outflushed < s.outwin {
+		s.outunsent.add(s.outflushed, min(s.out.end, s.outwin))
+	}
+	s.outflushed = s.out.end
+	if s.outflushed > s.outwin {
+		// We're blocked by flow control.
+		// Send a STREAM_DATA_BLOCKED frame to let the peer know.
+		s.outblocked.set()
+	}
+}
+
 // Close closes the stream.
 // See CloseContext for more details.
 func (s *Stream) Close() error {
@@ -332,10 +639,29 @@ func (s *Stream) CloseContext(ctx context.Context) error {
 // It does not wait for the peer to acknowledge the closure.
 // Use CloseContext to wait for the peer's acknowledgement.
 func (s *Stream) CloseRead() {
+	s.StopSending(0)
+}
+
+// StopSending aborts reads on the stream and asks the peer to stop sending.
+// Any blocked reads will be unblocked and return errors.
+//
+// StopSending sends the application protocol error code, which must be
+// less than 2^62, to the peer in a STOP_SENDING frame.
+// Writes by the peer will fail with a StreamStoppedError containing the code.
+// If the stream has already been closed for reading, StopSending has no effect.
+//
+// StopSending does not affect writes.
+// Use Reset to abort writes on the stream.
+func (s *Stream) StopSending(code uint64) {
 	if s.IsWriteOnly() {
 		return
 	}
 	s.ingate.lock()
+	if s.inclosed.isSet() {
+		s.inUnlock()
+		return
+	}
+	s.instopcode = min(code, maxVarint)
 	if s.inset.isrange(0, s.insize) || s.inresetcode != -1 {
 		// We've already received all data from the peer,
 		// so there's no need to send STOP_SENDING.
@@ -362,6 +688,7 @@ func (s *Stream) CloseWrite() {
 	}
 	s.outgate.lock()
 	defer s.outUnlock()
+	s.flushLocked()
 	s.outclosed.set()
 }
 
@@ -381,6 +708,39 @@ func (s *Stream) Reset(code uint64) {
 	s.resetInternal(code, userClosed)
 }
 
+// SetPriority sets the urgency of data written to the stream.
+//
+// Urgency ranges from 0 (most urgent) to 7 (least urgent),
+// as in the HTTP priority scheme defined by RFC 9218.
+// Values outside this range are clamped to it.
+// The default urgency is 3.
+//
+// When multiple streams have data to send, data from streams with more urgent
+// priorities is sent first. Streams with the same urgency share the available
+// bandwidth. Stream control frames, such as RESET_STREAM, are not prioritized.
+//
+// A connection with a custom StreamScheduler (see Config.NewStreamScheduler)
+// may use the stream's priority differently, or ignore it.
+func (s *Stream) SetPriority(urgency int) {
+	urgency = max(0, min(urgency, maxStreamUrgency))
+	c := s.conn
+	c.streams.sendMu.Lock()
+	defer c.streams.sendMu.Unlock()
+	if s.state.load().inQueue() == dataQueue {
+		c.streams.queueData.Remove(s)
+		s.urgency.Store(uint32(urgency))
+		c.streams.queueData.Add(s)
+	} else {
+		s.urgency.Store(uint32(urgency))
+	}
+}
+
+// Priority returns the urgency of data written to the stream,
+// as set by SetPriority.
+func (s *Stream) Priority() int {
+	return int(s.urgency.Load())
+}
+
 // resetInternal resets the send side of the stream.
 //
 // If userClosed is true, this is s.Reset.
@@ -394,6 +754,8 @@ func (s *Stream) resetInternal(code uint64, userClosed bool) {
 	if userClosed {
 		// Mark that the user closed the stream.
 		s.outclosed.set()
+	} else if s.outstopcode == -1 {
+		s.outstopcode = int64(min(code, maxVarint))
 	}
 	if s.outreset.isSet() {
 		return
@@ -423,7 +785,8 @@ func (s *Stream) inUnlock() {
 // inUnlockNoQueue is inUnlock,
 // but reports whether s has frames to write rather than notifying the Conn.
 func (s *Stream) inUnlockNoQueue() streamState {
-	canRead := s.inset.contains(s.in.start) || // data available to read
+	canRead := s.inAvailable() >= max(1, s.inpeekwant) || // data available to read
+		(s.insize != -1 && s.in.start+s.inAvailable() == s.insize) || // all remaining data available
 		s.insize == s.in.start || // at EOF
 		s.inresetcode != -1 || // reset by peer
 		s.inclosed.isSet() // closed locally
@@ -560,6 +923,9 @@ func (s *Stream) handleReset(code uint64, finalSize int64) error {
 	s.in.discardBefore(s.in.end)
 	s.inresetcode = int64(code)
 	s.insize = finalSize
+	if s.ctx != nil {
+		s.ctxCancel(s.resetErrorLocked())
+	}
 	return nil
 }
 
@@ -600,11 +966,11 @@ func (s *Stream) handleMaxStreamData(maxStreamData int64) error {
 	if maxStreamData <= s.outwin {
 		return nil
 	}
-	if s.out.end > s.outwin {
-		s.outunsent.add(s.outwin, min(maxStreamData, s.out.end))
+	if s.outflushed > s.outwin {
+		s.outunsent.add(s.outwin, min(maxStreamData, s.outflushed))
 	}
 	s.outwin = maxStreamData
-	if s.out.end > s.outwin {
+	if s.outflushed > s.outwin {
 		// We've still got more data than flow control window.
 		s.outblocked.setUnsent()
 	} else {
@@ -679,12 +1045,9 @@ func (s *Stream) ackOrLossData(pnum packetNumber, start, end int64, fin bool, fa
 //
 // It returns true if no more frames need appending,
 // false if not everything fit in the current packet.
-func (s *Stream) appendInFramesLocked(w *packetWriter, pnum packetNumber, pto bool) bool {
+func (s *Stream) appendInFramesLocked(now time.Time, w *packetWriter, pnum packetNumber, pto bool) bool {
 	if s.inclosed.shouldSendPTO(pto) {
-		// We don't currently have an API for setting the error code.
-		// Just send zero.
-		code := uint64(0)
-		if !w.appendStopSendingFrame(s.id, code) {
+		if !w.appendStopSendingFrame(s.id, s.instopcode) {
 			return false
 		}
 		s.inclosed.setSent(pnum)
@@ -692,7 +1055,13 @@ func (s *Stream) appendInFramesLocked(w *packetWriter, pnum packetNumber, pto bo
 	// TODO: STOP_SENDING
 	if s.insendmax.shouldSendPTO(pto) {
 		// MAX_STREAM_DATA
-		maxStreamData := s.in.start + s.inmaxbuf
+		if !pto {
+			if grow := s.intuner.update(now, s.in.start, s.conn.loss.rtt.smoothedRTT); grow > 0 {
+				s.conn.growInflowWindow(s.intuner.size + s.intuner.size/2)
+			}
+		}
+		// A blocked PeekContext may need a larger window.
+		maxStreamData := s.in.start + max(s.intuner.size, s.inpeekwant)
 		if !w.appendMaxStreamDataFrame(s.id, maxStreamData) {
 			return false
 		}
@@ -729,7 +1098,7 @@ func (s *Stream) appendOutFramesLocked(w *packetWriter, pnum packetNumber, pto b
 	}
 	for {
 		// STREAM
-		off, size := dataToSend(min(s.out.start, s.outwin), min(s.out.end, s.outwin), s.outunsent, s.outacked, pto)
+		off, size := dataToSend(min(s.out.start, s.outwin), min(s.outflushed, s.outwin), s.outunsent, s.outacked, pto)
 		if end := off + size; end > s.outmaxsent {
 			// This will require connection-level flow control to send.
 			end = min(end, s.outmaxsent+s.conn.streams.outflow.avail())
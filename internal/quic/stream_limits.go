@@ -40,6 +40,22 @@ func (lim *localStreamLimits) open(ctx context.Context, c *Conn) (num int64, err
 	return n, nil
 }
 
+// tryOpen creates a new local stream if MAX_STREAMS quota is available.
+// It reports false if the quota is exhausted.
+// It does not wait for quota, but does wait for the gate,
+// which may be held briefly by another goroutine opening a stream.
+func (lim *localStreamLimits) tryOpen() (num int64, ok bool) {
+	lim.gate.lock()
+	if lim.opened >= lim.max {
+		lim.gate.unlock(false)
+		return 0, false
+	}
+	n := lim.opened
+	lim.opened++
+	lim.gate.unlock(lim.opened < lim.max)
+	return n, true
+}
+
 // setMax sets the MAX_STREAMS provided by the peer.
 func (lim *localStreamLimits) setMax(maxStreams int64) {
 	lim.gate.lock()
@@ -54,10 +70,12 @@ type remoteStreamLimits struct {
 	closed  int64   // number of peer streams in the "closed" state
 	maxOpen int64   // how many streams we want to let the peer simultaneously open
 	sendMax sentVal // set when we should send MAX_STREAMS
+	policy  StreamLimitUpdatePolicy
 }
 
-func (lim *remoteStreamLimits) init(maxOpen int64) {
+func (lim *remoteStreamLimits) init(maxOpen int64, policy StreamLimitUpdatePolicy) {
 	lim.maxOpen = maxOpen
+	lim.policy = policy
 	lim.max = min(maxOpen, implicitStreamLimit) // initial limit sent in transport parameters
 	lim.opened = 0
 }
@@ -90,7 +108,7 @@ func (lim *remoteStreamLimits) maybeUpdateMax() {
 		lim.opened+implicitStreamLimit,
 	)
 	avail := lim.max - lim.opened
-	if newMax > lim.max && (avail < 8 || newMax-lim.max >= 2*avail) {
+	if newMax > lim.max && (lim.policy == StreamLimitUpdateImmediate || avail < 8 || newMax-lim.max >= 2*avail) {
 		// If the peer has less than 8 streams, or if increasing the peer's
 		// stream limit would double it, then send a MAX_STREAMS.
 		lim.max = newMax
@@ -45,6 +45,137 @@ func TestStreamLimitNewStreamBlocked(t *testing.T) {
 	})
 }
 
+func TestStreamLimitOpenStreamNonBlocking(t *testing.T) {
+	testStreamTypes(t, "", func(t *testing.T, styp streamType) {
+		tc := newTestConn(t, clientSide,
+			permissiveTransportParameters,
+			func(p *transportParameters) {
+				p.initialMaxStreamsBidi = 0
+				p.initialMaxStreamsUni = 0
+			})
+		tc.handshake()
+		tc.ignoreFrame(frameTypeAck)
+		open := func() (int64, error) {
+			if styp == uniStream {
+				s, err := tc.conn.OpenUniStream()
+				if err != nil {
+					return 0, err
+				}
+				return s.ID(), nil
+			}
+			s, err := tc.conn.OpenStream()
+			if err != nil {
+				return 0, err
+			}
+			return s.ID(), nil
+		}
+		if _, err := open(); err != ErrStreamLimitReached {
+			t.Fatalf("open stream at limit: %v, want ErrStreamLimitReached", err)
+		}
+		tc.writeFrames(packetType1RTT, debugFrameMaxStreams{
+			streamType: styp,
+			max:        1,
+		})
+		id, err := open()
+		if err != nil {
+			t.Fatalf("open stream after limit raised: %v", err)
+		}
+		if got, want := id, int64(newStreamID(clientSide, styp, 0)); got != want {
+			t.Fatalf("opened stream %v, want %v", got, want)
+		}
+		if _, err := open(); err != ErrStreamLimitReached {
+			t.Fatalf("open stream at raised limit: %v, want ErrStreamLimitReached", err)
+		}
+	})
+}
+
+func TestStreamLimitTryOpenWhileGateHeld(t *testing.T) {
+	// A non-blocking open must not fail with quota available
+	// just because another goroutine holds the gate.
+	var lim localStreamLimits
+	lim.init()
+	lim.setMax(1)
+	lim.gate.lock()
+	go lim.gate.unlock(true)
+	if num, ok := lim.tryOpen(); !ok || num != 0 {
+		t.Fatalf("tryOpen with held gate = %v, %v; want 0, true", num, ok)
+	}
+	if _, ok := lim.tryOpen(); ok {
+		t.Fatalf("tryOpen with exhausted quota = true, want false")
+	}
+}
+
+func TestStreamLimitOpenStreamSyncBlocked(t *testing.T) {
+	tc := newTestConn(t, clientSide,
+		permissiveTransportParameters,
+		func(p *transportParameters) {
+			p.initialMaxStreamsBidi = 0
+		})
+	tc.handshake()
+	tc.ignoreFrame(frameTypeAck)
+	opening := runAsync(tc, func(ctx context.Context) (*Stream, error) {
+		return tc.conn.OpenStreamSync(ctx)
+	})
+	if _, err := opening.result(); err != errNotDone {
+		t.Fatalf("OpenStreamSync blocked by limit: %v, want errNotDone", err)
+	}
+	// A failed non-blocking open does not consume the credit
+	// the blocked OpenStreamSync is waiting for.
+	if _, err := tc.conn.OpenStream(); err != ErrStreamLimitReached {
+		t.Fatalf("OpenStream at limit: %v, want ErrStreamLimitReached", err)
+	}
+	tc.writeFrames(packetType1RTT, debugFrameMaxStreams{
+		streamType: bidiStream,
+		max:        1,
+	})
+	s, err := opening.result()
+	if err != nil {
+		t.Fatalf("OpenStreamSync not completed after limit raised: %v", err)
+	}
+	if got, want := s.ID(), int64(newStreamID(clientSide, bidiStream, 0)); got != want {
+		t.Fatalf("OpenStreamSync() = stream %v, want %v", got, want)
+	}
+
+	opening = runAsync(tc, func(ctx context.Context) (*Stream, error) {
+		return tc.conn.OpenStreamSync(ctx)
+	})
+	opening.cancel()
+	if _, err := opening.result(); err != context.Canceled {
+		t.Fatalf("OpenStreamSync with canceled context: %v, want context.Canceled", err)
+	}
+}
+
+func TestStreamLimitNewStreamBlockedDoesNotBlockConn(t *testing.T) {
+	// A NewStream call blocked on the peer's stream limit
+	// must not prevent the conn from handling frames for other streams.
+	tc, s := newTestConnAndLocalStream(t, clientSide, bidiStream,
+		permissiveTransportParameters,
+		func(p *transportParameters) {
+			p.initialMaxStreamsBidi = 1
+		})
+	opening := runAsync(tc, func(ctx context.Context) (*Stream, error) {
+		return tc.conn.NewStream(ctx)
+	})
+	if _, err := opening.result(); err != errNotDone {
+		t.Fatalf("new stream blocked by limit: %v, want errNotDone", err)
+	}
+	tc.writeFrames(packetType1RTT, debugFrameStream{
+		id:   s.id,
+		data: []byte("data"),
+	})
+	tc.writeFrames(packetType1RTT, debugFrameMaxStreams{
+		streamType: bidiStream,
+		max:        2,
+	})
+	if _, err := opening.result(); err != nil {
+		t.Fatalf("new stream not created after limit raised: %v", err)
+	}
+	got := make([]byte, 4)
+	if n, err := s.Read(got); n != 4 || string(got) != "data" {
+		t.Fatalf("s.Read() = %v, %v (%q); want 4, nil (%q)", n, err, got[:n], "data")
+	}
+}
+
 func TestStreamLimitMaxStreamsDecreases(t *testing.T) {
 	// "MAX_STREAMS frames that do not increase the stream limit MUST be ignored."
 	// https://www.rfc-editor.org/rfc/rfc9000#section-4.6-4
@@ -242,6 +373,41 @@ func TestStreamLimitSendUpdatesMaxStreams(t *testing.T) {
 	})
 }
 
+func TestStreamLimitUpdatePolicy(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		policy StreamLimitUpdatePolicy
+	}{{
+		name:   "batched",
+		policy: StreamLimitUpdateBatched,
+	}, {
+		name:   "immediate",
+		policy: StreamLimitUpdateImmediate,
+	}} {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := canceledContext()
+			tc, s := newTestConnAndRemoteStream(t, serverSide, uniStream, func(c *Config) {
+				c.MaxUniRemoteStreams = 20
+				c.StreamLimitUpdate = test.policy
+			})
+			tc.writeFrames(packetType1RTT, debugFrameStream{
+				id:  s.id,
+				fin: true,
+			})
+			s.CloseContext(ctx)
+			if test.policy == StreamLimitUpdateBatched {
+				tc.wantIdle("closing one of many available streams does not extend the limit")
+				return
+			}
+			tc.wantFrame("closing a stream immediately extends the limit",
+				packetType1RTT, debugFrameMaxStreams{
+					streamType: uniStream,
+					max:        21,
+				})
+		})
+	}
+}
+
 func TestStreamLimitStopSendingDoesNotUpdateMaxStreams(t *testing.T) {
 	tc, s := newTestConnAndRemoteStream(t, serverSide, bidiStream, func(c *Config) {
 		c.MaxBidiRemoteStreams = 1
@@ -0,0 +1,81 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+// A StreamScheduler decides which streams send data in each outgoing packet.
+//
+// A scheduler holds the set of streams with flow-controlled data (STREAM frames)
+// waiting to be sent. When the connection has space in a packet and
+// connection-level flow control quota available, it asks the scheduler for
+// the next stream to send from. Stream control frames, such as RESET_STREAM,
+// are sent before any stream data and are not scheduled.
+//
+// A StreamScheduler is created for each connection by Config.NewStreamScheduler.
+// Its methods are called with a connection lock held. They must not block,
+// and must not call methods on the Conn or its Streams other than
+// Stream.Priority and the Stream's identifying methods.
+type StreamScheduler interface {
+	// Add adds a stream with data to send to the set of scheduled streams.
+	// The stream is not already in the set.
+	Add(s *Stream)
+
+	// Remove removes a stream from the set of scheduled streams.
+	// It is called when the stream has no more data to send,
+	// and when a stream's priority changes.
+	Remove(s *Stream)
+
+	// Next returns the stream which should send data next,
+	// or nil if no stream should send data.
+	// The returned stream must be in the set of scheduled streams.
+	// If Next returns nil while the set is not empty,
+	// it is called again when the connection next sends a packet.
+	Next() *Stream
+
+	// Sent is called after the stream returned by Next sends n bytes
+	// of data and still has more data to send. This happens when the
+	// stream fills the current packet or exhausts the connection-level
+	// flow control window.
+	//
+	// The stream remains in the set of scheduled streams.
+	// A round-robin scheduler might move it to the back of the line.
+	Sent(s *Stream, n int)
+}
+
+// urgencyScheduler is the default StreamScheduler.
+//
+// It sends data from streams in urgency order (see Stream.SetPriority).
+// Streams with the same urgency take turns sending data.
+type urgencyScheduler struct {
+	queues [maxStreamUrgency + 1]streamRing
+}
+
+func (u *urgencyScheduler) Add(s *Stream) {
+	u.queues[s.Priority()].append(s)
+}
+
+func (u *urgencyScheduler) Remove(s *Stream) {
+	u.queues[s.Priority()].remove(s)
+}
+
+func (u *urgencyScheduler) Next() *Stream {
+	for i := range u.queues {
+		if s := u.queues[i].head; s != nil {
+			return s
+		}
+	}
+	return nil
+}
+
+func (u *urgencyScheduler) Sent(s *Stream, n int) {
+	if n > 0 {
+		// The stream got a chance to send data.
+		// Move on to the next stream in line, to avoid starvation.
+		// If the stream sent nothing (because the packet was already full),
+		// it stays at the front of the line for the next packet.
+		u.queues[s.Priority()].head = s.next
+	}
+}
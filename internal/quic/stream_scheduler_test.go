@@ -0,0 +1,155 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"slices"
+	"testing"
+)
+
+// lifoScheduler is a StreamScheduler which sends data from the most recently
+// added stream first, and records calls to Sent.
+type lifoScheduler struct {
+	streams []*Stream
+	sent    map[*Stream]int
+}
+
+func (l *lifoScheduler) Add(s *Stream) {
+	l.streams = append(l.streams, s)
+}
+
+func (l *lifoScheduler) Remove(s *Stream) {
+	i := slices.Index(l.streams, s)
+	if i < 0 {
+		panic("removing stream not in scheduler")
+	}
+	l.streams = slices.Delete(l.streams, i, i+1)
+}
+
+func (l *lifoScheduler) Next() *Stream {
+	if len(l.streams) == 0 {
+		return nil
+	}
+	return l.streams[len(l.streams)-1]
+}
+
+func (l *lifoScheduler) Sent(s *Stream, n int) {
+	l.sent[s] += n
+}
+
+func TestStreamSchedulerCustom(t *testing.T) {
+	ctx := canceledContext()
+	sched := &lifoScheduler{sent: make(map[*Stream]int)}
+	tc := newTestConn(t, clientSide, permissiveTransportParameters,
+		func(p *transportParameters) {
+			p.initialMaxData = 0
+		},
+		func(c *Config) {
+			c.NewStreamScheduler = func(*Conn) StreamScheduler {
+				return sched
+			}
+		})
+	tc.handshake()
+	tc.ignoreFrame(frameTypeAck)
+
+	var streams []*Stream
+	for i := 0; i < 3; i++ {
+		s, err := tc.conn.newLocalStream(ctx, uniStream)
+		if err != nil {
+			t.Fatalf("conn.newLocalStream(%v) = %v", uniStream, err)
+		}
+		s.Write(make([]byte, 2))
+		streams = append(streams, s)
+	}
+	tc.wantIdle("no data sent without connection-level flow control")
+
+	// Streams send data in the reverse of the order they were added.
+	for i := len(streams) - 1; i >= 0; i-- {
+		s := streams[i]
+		tc.writeFrames(packetType1RTT, debugFrameMaxData{
+			max: int64(2*(len(streams)-i) - 1),
+		})
+		tc.wantFrame("scheduler chooses most recently added stream",
+			packetType1RTT, debugFrameStream{
+				id:   s.id,
+				data: []byte{0},
+			})
+		if got, want := sched.sent[s], 1; got != want {
+			t.Errorf("stream %v: scheduler.Sent called with %v bytes, want %v", i, got, want)
+		}
+		tc.writeFrames(packetType1RTT, debugFrameMaxData{
+			max: int64(2 * (len(streams) - i)),
+		})
+		tc.wantFrame("stream continues sending until it has no more data",
+			packetType1RTT, debugFrameStream{
+				id:   s.id,
+				off:  1,
+				data: []byte{0},
+			})
+	}
+	if len(sched.streams) != 0 {
+		t.Errorf("after all data is sent, scheduler holds %v streams, want 0", len(sched.streams))
+	}
+}
+
+// pausingScheduler is a lifoScheduler which sends no data while paused.
+type pausingScheduler struct {
+	lifoScheduler
+	paused bool
+}
+
+func (p *pausingScheduler) Next() *Stream {
+	if p.paused {
+		return nil
+	}
+	return p.lifoScheduler.Next()
+}
+
+func TestStreamSchedulerNextReturnsNil(t *testing.T) {
+	// A scheduler may decline to send data from the streams it holds.
+	// The conn sends that data when the scheduler chooses a stream later.
+	ctx := canceledContext()
+	sched := &pausingScheduler{
+		lifoScheduler: lifoScheduler{sent: make(map[*Stream]int)},
+		paused:        true,
+	}
+	tc := newTestConn(t, clientSide, permissiveTransportParameters,
+		func(c *Config) {
+			c.NewStreamScheduler = func(*Conn) StreamScheduler {
+				return sched
+			}
+		})
+	tc.handshake()
+	tc.ignoreFrame(frameTypeAck)
+
+	s, err := tc.conn.newLocalStream(ctx, uniStream)
+	if err != nil {
+		t.Fatalf("conn.newLocalStream(%v) = %v", uniStream, err)
+	}
+	s.Write([]byte{0})
+	tc.wantIdle("no data sent while scheduler returns no stream")
+
+	// Cause the conn to send a packet.
+	sched.paused = false
+	tc.writeFrames(packetType1RTT, debugFramePing{})
+	tc.wantFrame("data sent once scheduler returns the stream",
+		packetType1RTT, debugFrameStream{
+			id:   s.id,
+			data: []byte{0},
+		})
+}
+
+func TestStreamSchedulerNilUsesDefault(t *testing.T) {
+	tc := newTestConn(t, clientSide, func(c *Config) {
+		c.NewStreamScheduler = func(*Conn) StreamScheduler {
+			return nil
+		}
+	})
+	if _, ok := tc.conn.streams.queueData.(*urgencyScheduler); !ok {
+		t.Errorf("NewStreamScheduler returns nil: conn uses %T, want *urgencyScheduler", tc.conn.streams.queueData)
+	}
+}
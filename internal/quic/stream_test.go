@@ -14,8 +14,10 @@ import (
 	"fmt"
 	"io"
 	"reflect"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestStreamWriteBlockedByOutputBuffer(t *testing.T) {
@@ -486,6 +488,41 @@ func TestStreamReceiveExtendsStreamWindow(t *testing.T) {
 	})
 }
 
+func TestStreamReceiveGrowsInitialWindow(t *testing.T) {
+	testStreamTypes(t, "", func(t *testing.T, styp streamType) {
+		const (
+			initialWindowSize = 4
+			maxWindowSize     = 20
+		)
+		ctx := canceledContext()
+		tc := newTestConn(t, serverSide, func(c *Config) {
+			c.MaxStreamReadBufferSize = maxWindowSize
+			c.InitialStreamReadWindowBidiRemote = initialWindowSize
+			c.InitialStreamReadWindowUni = initialWindowSize
+		})
+		tc.handshake()
+		tc.ignoreFrame(frameTypeAck)
+		sid := newStreamID(clientSide, styp, 0)
+		tc.writeFrames(packetType1RTT, debugFrameStream{
+			id:   sid,
+			off:  0,
+			data: make([]byte, initialWindowSize),
+		})
+		s, err := tc.conn.AcceptStream(ctx)
+		if err != nil {
+			t.Fatalf("AcceptStream: %v", err)
+		}
+		if n, err := s.ReadContext(ctx, make([]byte, 1)); n != 1 || err != nil {
+			t.Fatalf("s.ReadContext() = %v, %v; want 1, nil", n, err)
+		}
+		tc.wantFrame("stream window grows to the maximum after reading data",
+			packetType1RTT, debugFrameMaxStreamData{
+				id:  sid,
+				max: 1 + maxWindowSize,
+			})
+	})
+}
+
 func TestStreamReceiveViolatesStreamDataLimit(t *testing.T) {
 	// "A receiver MUST close the connection with an error of type FLOW_CONTROL_ERROR if
 	// the sender violates the advertised [...] stream data limits [...]"
@@ -738,6 +775,105 @@ func testStreamSendFrameInvalidState(t *testing.T, f func(sid streamID) debugFra
 	})
 }
 
+func TestStreamPeekAndDiscard(t *testing.T) {
+	testStreamTypes(t, "", func(t *testing.T, styp streamType) {
+		tc := newTestConn(t, serverSide)
+		tc.handshake()
+		want := []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+		sid := newStreamID(clientSide, styp, 0)
+		tc.writeFrames(packetType1RTT, debugFrameStream{
+			id:   sid,
+			off:  0,
+			data: want[:4],
+		})
+		s, err := tc.conn.AcceptStream(canceledContext())
+		if err != nil {
+			t.Fatalf("AcceptStream() = %v", err)
+		}
+
+		// PeekContext blocks until the requested amount of data is available.
+		peek := runAsync(tc, func(ctx context.Context) ([]byte, error) {
+			return s.PeekContext(ctx, 8)
+		})
+		if _, err := peek.result(); err != errNotDone {
+			t.Fatalf("PeekContext(8) with 4 bytes available: %v; want it to block", err)
+		}
+		tc.writeFrames(packetType1RTT, debugFrameStream{
+			id:   sid,
+			off:  4,
+			data: want[4:],
+			fin:  true,
+		})
+		if got, err := peek.result(); !bytes.Equal(got, want[:8]) || err != nil {
+			t.Fatalf("PeekContext(8) = %x, %v; want %x, nil", got, err, want[:8])
+		}
+
+		// Peeking does not consume data.
+		if n, err := s.Discard(2); n != 2 || err != nil {
+			t.Fatalf("Discard(2) = %v, %v; want 2, nil", n, err)
+		}
+		if got, err := s.PeekContext(canceledContext(), 3); !bytes.Equal(got, want[2:5]) || err != nil {
+			t.Fatalf("PeekContext(3) = %x, %v; want %x, nil", got, err, want[2:5])
+		}
+		got := make([]byte, 3)
+		if n, err := s.ReadContext(canceledContext(), got); n != 3 || err != nil || !bytes.Equal(got, want[2:5]) {
+			t.Fatalf("ReadContext = %v, %v (read %x); want 3, nil (read %x)", n, err, got, want[2:5])
+		}
+
+		// Peeking past the end of the stream returns the remaining data.
+		if got, err := s.PeekContext(canceledContext(), 8); !bytes.Equal(got, want[5:]) || err != io.EOF {
+			t.Fatalf("PeekContext(8) = %x, %v; want %x, io.EOF", got, err, want[5:])
+		}
+		if n, err := s.Discard(100); n != len(want)-5 || err != io.EOF {
+			t.Fatalf("Discard(100) = %v, %v; want %v, io.EOF", n, err, len(want)-5)
+		}
+	})
+}
+
+func TestStreamPeekExtendsStreamWindow(t *testing.T) {
+	const maxWindowSize = 20
+	ctx := canceledContext()
+	tc := newTestConn(t, serverSide, func(c *Config) {
+		c.MaxStreamReadBufferSize = maxWindowSize
+	})
+	tc.handshake()
+	tc.ignoreFrame(frameTypeAck)
+	sid := newStreamID(clientSide, bidiStream, 0)
+	tc.writeFrames(packetType1RTT, debugFrameStream{
+		id:   sid,
+		off:  0,
+		data: make([]byte, 4),
+	})
+	s, err := tc.conn.AcceptStream(ctx)
+	if err != nil {
+		t.Fatalf("AcceptStream: %v", err)
+	}
+	if n, err := s.Discard(1); n != 1 || err != nil {
+		t.Fatalf("Discard(1) = %v, %v; want 1, nil", n, err)
+	}
+	tc.wantIdle("stream window is not extended after discarding a small amount of data")
+
+	if _, err := s.PeekContext(ctx, maxWindowSize+1); err == nil {
+		t.Fatalf("PeekContext(%v) with read buffer of %v: succeeded, want error", maxWindowSize+1, maxWindowSize)
+	}
+	peek := runAsync(tc, func(ctx context.Context) ([]byte, error) {
+		return s.PeekContext(ctx, maxWindowSize)
+	})
+	tc.wantFrame("blocked peek larger than the peer's window extends stream window",
+		packetType1RTT, debugFrameMaxStreamData{
+			id:  sid,
+			max: 1 + maxWindowSize,
+		})
+	tc.writeFrames(packetType1RTT, debugFrameStream{
+		id:   sid,
+		off:  4,
+		data: make([]byte, maxWindowSize-3),
+	})
+	if got, err := peek.result(); len(got) != maxWindowSize || err != nil {
+		t.Fatalf("PeekContext(%v) = %v bytes, %v; want %v bytes, nil", maxWindowSize, len(got), err, maxWindowSize)
+	}
+}
+
 func TestStreamResetStreamInvalidState(t *testing.T) {
 	// "An endpoint that receives a RESET_STREAM frame for a send-only
 	// stream MUST terminate the connection with error STREAM_STATE_ERROR."
@@ -1037,6 +1173,28 @@ func TestStreamCloseReadOnly(t *testing.T) {
 		})
 }
 
+func TestStreamStopSending(t *testing.T) {
+	tc, s := newTestConnAndRemoteStream(t, serverSide, bidiStream, permissiveTransportParameters)
+	reader := runAsync(tc, func(ctx context.Context) (int, error) {
+		return s.ReadContext(ctx, make([]byte, 4))
+	})
+	s.StopSending(42)
+	tc.wantFrame("StopSending sends STOP_SENDING with the error code",
+		packetType1RTT, debugFrameStopSending{
+			id:   s.id,
+			code: 42,
+		})
+	if n, err := reader.result(); n != 0 || err == nil {
+		t.Errorf("s.Read() interrupted by StopSending = %v, %v; want error", n, err)
+	}
+	s.StopSending(100)
+	s.CloseRead()
+	tc.wantIdle("stopping a stream a second time has no effect")
+	if _, err := s.Write([]byte{0}); err != nil {
+		t.Errorf("s.Write() after StopSending = %v; want success", err)
+	}
+}
+
 func TestStreamCloseUnblocked(t *testing.T) {
 	for _, test := range []struct {
 		name    string
@@ -1175,6 +1333,10 @@ func TestStreamPeerResetWakesBlockedRead(t *testing.T) {
 		if n, err := reader.result(); n != 0 || !errors.Is(err, wantErr) {
 			t.Fatalf("Read reset stream: got %v, %v; want 0, %v", n, err, wantErr)
 		}
+		var resetErr *StreamResetError
+		if _, err := s.Read(make([]byte, 4)); !errors.As(err, &resetErr) || resetErr.Code != sentCode {
+			t.Fatalf("Read reset stream: got %v; want StreamResetError{Code: %v}", err, sentCode)
+		}
 	})
 }
 
@@ -1246,8 +1408,9 @@ func TestStreamPeerStopSendingForActiveStream(t *testing.T) {
 				code:      42,
 				finalSize: 4,
 			})
-		if n, err := s.Write([]byte{0}); err == nil {
-			t.Errorf("s.Write() after STOP_SENDING = %v, %v; want error", n, err)
+		var stoppedErr *StreamStoppedError
+		if n, err := s.Write([]byte{0}); !errors.As(err, &stoppedErr) || stoppedErr.Code != 42 {
+			t.Errorf("s.Write() after STOP_SENDING = %v, %v; want StreamStoppedError{Code: 42}", n, err)
 		}
 		// This ack will result in some of the previous frames being marked as lost.
 		tc.writeAckForLatest()
@@ -1333,3 +1496,294 @@ func makeTestData(n int) []byte {
 	}
 	return b
 }
+
+func TestStreamContextCanceledByPeerReset(t *testing.T) {
+	tc, s := newTestConnAndRemoteStream(t, serverSide, bidiStream)
+	ctx := s.Context()
+	if err := ctx.Err(); err != nil {
+		t.Fatalf("stream context error before reset: %v", err)
+	}
+	const sentCode = 42
+	tc.writeFrames(packetType1RTT, debugFrameResetStream{
+		id:   s.id,
+		code: sentCode,
+	})
+	if err := ctx.Err(); err != context.Canceled {
+		t.Fatalf("stream context error after reset: %v, want context.Canceled", err)
+	}
+	if err := context.Cause(ctx); !errors.Is(err, StreamErrorCode(sentCode)) {
+		t.Errorf("stream context cause after reset: %v, want %v", err, StreamErrorCode(sentCode))
+	}
+	if got := s.Context(); got != ctx {
+		t.Errorf("Context returned a different context on second call")
+	}
+}
+
+func TestStreamContextAfterPeerReset(t *testing.T) {
+	tc, s := newTestConnAndRemoteStream(t, serverSide, uniStream)
+	tc.writeFrames(packetType1RTT, debugFrameResetStream{
+		id: s.id,
+	})
+	if err := s.Context().Err(); err != context.Canceled {
+		t.Fatalf("context of reset stream: error %v, want context.Canceled", err)
+	}
+}
+
+func TestStreamContextCanceledByConnClose(t *testing.T) {
+	tc, s := newTestConnAndRemoteStream(t, serverSide, bidiStream)
+	ctx := s.Context()
+	tc.writeFrames(packetType1RTT, debugFrameConnectionCloseTransport{
+		code: errProtocolViolation,
+	})
+	select {
+	case <-ctx.Done():
+	case <-time.After(10 * time.Second):
+		t.Fatalf("stream context not canceled after connection closed")
+	}
+	if err := context.Cause(ctx); err == nil || err == context.Canceled {
+		t.Errorf("stream context cause after connection closed: %v, want connection error", err)
+	}
+}
+
+func TestStreamContextCanceledByAbort(t *testing.T) {
+	tc, s := newTestConnAndLocalStream(t, clientSide, bidiStream, permissiveTransportParameters)
+	ctx := s.Context()
+	tc.conn.Abort(nil)
+	tc.wantFrame("conn sends CONNECTION_CLOSE after Abort",
+		packetType1RTT, debugFrameConnectionCloseTransport{
+			code: errNo,
+		})
+	select {
+	case <-ctx.Done():
+	case <-time.After(10 * time.Second):
+		t.Fatalf("stream context not canceled after Abort")
+	}
+}
+
+func TestStreamReadContextCanceled(t *testing.T) {
+	tc, s := newTestConnAndRemoteStream(t, serverSide, bidiStream)
+	got := make([]byte, 8)
+	r := runAsync(tc, func(ctx context.Context) (int, error) {
+		return s.ReadContext(ctx, got)
+	})
+	r.cancel()
+	if n, err := r.result(); n != 0 || err != context.Canceled {
+		t.Fatalf("canceled ReadContext = %v, %v; want 0, context.Canceled", n, err)
+	}
+	if err := s.Context().Err(); err != nil {
+		t.Fatalf("stream context error after canceled read: %v", err)
+	}
+
+	// The stream is still usable after a canceled read.
+	want := []byte{0, 1, 2, 3}
+	tc.writeFrames(packetType1RTT, debugFrameStream{
+		id:   s.id,
+		data: want,
+	})
+	if n, err := s.ReadContext(canceledContext(), got); n != len(want) || err != nil || !bytes.Equal(got[:n], want) {
+		t.Fatalf("ReadContext after canceled read = %x, %v; want %x, nil", got[:n], err, want)
+	}
+}
+
+func TestStreamWriteContextCanceled(t *testing.T) {
+	const writeBufferSize = 4
+	tc, s := newTestConnAndLocalStream(t, clientSide, bidiStream, permissiveTransportParameters,
+		func(c *Config) {
+			c.MaxStreamWriteBufferSize = writeBufferSize
+		})
+	tc.ignoreFrame(frameTypeAck)
+	want := []byte{0, 1, 2, 3, 4, 5, 6, 7}
+	w := runAsync(tc, func(ctx context.Context) (int, error) {
+		return s.WriteContext(ctx, want)
+	})
+	tc.wantFrame("write buffer of data sent",
+		packetType1RTT, debugFrameStream{
+			id:   s.id,
+			data: want[:writeBufferSize],
+		})
+	w.cancel()
+	n, err := w.result()
+	if n != writeBufferSize || err != context.Canceled {
+		t.Fatalf("canceled WriteContext = %v, %v; want %v, context.Canceled", n, err, writeBufferSize)
+	}
+
+	// The stream is still usable after a canceled write,
+	// and the write may be resumed.
+	tc.writeAckForAll()
+	if n, err := s.WriteContext(canceledContext(), want[n:]); n != len(want)-writeBufferSize || err != nil {
+		t.Fatalf("resumed WriteContext = %v, %v; want %v, nil", n, err, len(want)-writeBufferSize)
+	}
+	s.CloseWrite()
+	tc.wantFrame("resumed write sends remaining data",
+		packetType1RTT, debugFrameStream{
+			id:   s.id,
+			off:  writeBufferSize,
+			data: want[writeBufferSize:],
+			fin:  true,
+		})
+}
+
+func TestStreamWriteBuffers(t *testing.T) {
+	tc, s := newTestConnAndLocalStream(t, clientSide, bidiStream, permissiveTransportParameters)
+	tc.ignoreFrame(frameTypeAck)
+	bufs := [][]byte{{0, 1, 2}, nil, {3}, {}, {4, 5, 6, 7}}
+	saved := slices.Clone(bufs)
+	n, err := s.WriteBuffersContext(canceledContext(), bufs)
+	if n != 8 || err != nil {
+		t.Fatalf("WriteBuffersContext = %v, %v; want 8, nil", n, err)
+	}
+	if !reflect.DeepEqual(bufs, saved) {
+		t.Errorf("WriteBuffersContext modified bufs: %v, want %v", bufs, saved)
+	}
+	s.CloseWrite()
+	tc.wantFrame("buffers are sent as one STREAM frame",
+		packetType1RTT, debugFrameStream{
+			id:   s.id,
+			data: []byte{0, 1, 2, 3, 4, 5, 6, 7},
+			fin:  true,
+		})
+}
+
+func TestStreamWriteBuffersBlockedByOutputBuffer(t *testing.T) {
+	const writeBufferSize = 4
+	tc, s := newTestConnAndLocalStream(t, clientSide, bidiStream, permissiveTransportParameters,
+		func(c *Config) {
+			c.MaxStreamWriteBufferSize = writeBufferSize
+		})
+	tc.ignoreFrame(frameTypeAck)
+	bufs := [][]byte{{0, 1}, {2, 3, 4}, {5, 6, 7, 8, 9}}
+	w := runAsync(tc, func(ctx context.Context) (int64, error) {
+		return s.WriteBuffersContext(ctx, bufs)
+	})
+	tc.wantFrame("first write buffer of data sent",
+		packetType1RTT, debugFrameStream{
+			id:   s.id,
+			data: []byte{0, 1, 2, 3},
+		})
+	tc.wantIdle("write buffer is full, no more data can be sent")
+	tc.writeAckForAll()
+	tc.wantFrame("second write buffer of data sent",
+		packetType1RTT, debugFrameStream{
+			id:   s.id,
+			off:  4,
+			data: []byte{4, 5, 6, 7},
+		})
+	tc.writeAckForAll()
+	tc.wantFrame("remaining data sent",
+		packetType1RTT, debugFrameStream{
+			id:   s.id,
+			off:  8,
+			data: []byte{8, 9},
+		})
+	if n, err := w.result(); n != 10 || err != nil {
+		t.Fatalf("WriteBuffersContext = %v, %v; want 10, nil", n, err)
+	}
+}
+
+func TestStreamWriteBuffersCanceled(t *testing.T) {
+	const writeBufferSize = 4
+	tc, s := newTestConnAndLocalStream(t, clientSide, bidiStream, permissiveTransportParameters,
+		func(c *Config) {
+			c.MaxStreamWriteBufferSize = writeBufferSize
+		})
+	tc.ignoreFrame(frameTypeAck)
+	bufs := [][]byte{{0, 1, 2}, {3, 4, 5}}
+	n, err := s.WriteBuffersContext(canceledContext(), bufs)
+	if n != writeBufferSize || err != context.Canceled {
+		t.Fatalf("WriteBuffersContext = %v, %v; want %v, context.Canceled", n, err, writeBufferSize)
+	}
+}
+
+func TestStreamFlushManual(t *testing.T) {
+	tc, s := newTestConnAndLocalStream(t, clientSide, bidiStream, permissiveTransportParameters)
+	tc.ignoreFrame(frameTypeAck)
+	s.SetAutoFlush(false)
+	s.Write([]byte{0, 1})
+	s.Write([]byte{2, 3})
+	tc.wantIdle("data is not sent until flushed")
+	if err := s.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	tc.wantFrame("flushed writes are coalesced into one frame",
+		packetType1RTT, debugFrameStream{
+			id:   s.id,
+			data: []byte{0, 1, 2, 3},
+		})
+	s.Write([]byte{4, 5})
+	tc.wantIdle("data written after Flush is not sent until flushed")
+	s.CloseWrite()
+	tc.wantFrame("CloseWrite flushes buffered data",
+		packetType1RTT, debugFrameStream{
+			id:   s.id,
+			off:  4,
+			data: []byte{4, 5},
+			fin:  true,
+		})
+}
+
+func TestStreamFlushManualBufferFull(t *testing.T) {
+	const writeBufferSize = 4
+	tc, s := newTestConnAndLocalStream(t, clientSide, bidiStream, permissiveTransportParameters,
+		func(c *Config) {
+			c.MaxStreamWriteBufferSize = writeBufferSize
+		})
+	tc.ignoreFrame(frameTypeAck)
+	s.SetAutoFlush(false)
+	want := []byte{0, 1, 2, 3, 4, 5}
+	w := runAsync(tc, func(ctx context.Context) (int, error) {
+		return s.WriteContext(ctx, want)
+	})
+	tc.wantFrame("full write buffer is flushed",
+		packetType1RTT, debugFrameStream{
+			id:   s.id,
+			data: want[:writeBufferSize],
+		})
+	tc.writeAckForAll()
+	if n, err := w.result(); n != len(want) || err != nil {
+		t.Fatalf("WriteContext = %v, %v; want %v, nil", n, err, len(want))
+	}
+	tc.wantIdle("remaining data is not sent until flushed")
+	s.SetAutoFlush(true)
+	tc.wantFrame("enabling auto flush flushes buffered data",
+		packetType1RTT, debugFrameStream{
+			id:   s.id,
+			off:  writeBufferSize,
+			data: want[writeBufferSize:],
+		})
+}
+
+func TestStreamFlushManualFlowControl(t *testing.T) {
+	tc, s := newTestConnAndLocalStream(t, clientSide, uniStream,
+		permissiveTransportParameters,
+		func(p *transportParameters) {
+			p.initialMaxStreamDataUni = 2
+		})
+	tc.ignoreFrame(frameTypeAck)
+	s.SetAutoFlush(false)
+	s.Write([]byte{0, 1, 2, 3})
+	tc.wantIdle("data is not sent until flushed")
+	tc.writeFrames(packetType1RTT, debugFrameMaxStreamData{
+		id:  s.id,
+		max: 4,
+	})
+	tc.wantIdle("flow control update does not send unflushed data")
+	s.Flush()
+	tc.wantFrame("flushed data sent",
+		packetType1RTT, debugFrameStream{
+			id:   s.id,
+			data: []byte{0, 1, 2, 3},
+		})
+}
+
+func TestStreamFlushErrors(t *testing.T) {
+	_, rs := newTestConnAndRemoteStream(t, serverSide, uniStream)
+	if err := rs.Flush(); err == nil {
+		t.Errorf("Flush of read-only stream succeeded, want error")
+	}
+	tc, s := newTestConnAndLocalStream(t, clientSide, bidiStream, permissiveTransportParameters)
+	tc.ignoreFrame(frameTypeAck)
+	s.Reset(1)
+	if err := s.Flush(); err == nil {
+		t.Errorf("Flush of reset stream succeeded, want error")
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import "context"
+
+// A SendStream is a unidirectional stream created locally.
+// Data may only be written to it.
+//
+// SendStream provides the write half of the Stream API,
+// so misusing a unidirectional stream is a compile-time error
+// rather than an error returned at runtime.
+type SendStream struct {
+	s *Stream
+}
+
+// A ReceiveStream is a unidirectional stream created by the peer.
+// Data may only be read from it.
+//
+// ReceiveStream provides the read half of the Stream API,
+// so misusing a unidirectional stream is a compile-time error
+// rather than an error returned at runtime.
+type ReceiveStream struct {
+	s *Stream
+}
+
+// OpenUniStream creates a unidirectional, send-only stream without blocking.
+// It is the unidirectional counterpart of OpenStream,
+// and OpenUniStreamSync is the counterpart of OpenStreamSync.
+//
+// If the peer's maximum stream limit for the connection has been reached,
+// OpenUniStream returns ErrStreamLimitReached.
+func (c *Conn) OpenUniStream() (*SendStream, error) {
+	s, err := c.tryNewLocalStream(uniStream)
+	if err != nil {
+		return nil, err
+	}
+	return &SendStream{s}, nil
+}
+
+// OpenUniStreamSync creates a unidirectional, send-only stream.
+//
+// If the peer's maximum stream limit for the connection has been reached,
+// OpenUniStreamSync blocks until the limit is increased or the context expires.
+func (c *Conn) OpenUniStreamSync(ctx context.Context) (*SendStream, error) {
+	s, err := c.newLocalStream(ctx, uniStream)
+	if err != nil {
+		return nil, err
+	}
+	return &SendStream{s}, nil
+}
+
+// AcceptUniStream waits for and returns the next unidirectional stream created by the peer.
+//
+// Once AcceptUniStream has been called, unidirectional streams created by the peer
+// are no longer returned by AcceptStream, which returns only bidirectional streams.
+func (c *Conn) AcceptUniStream(ctx context.Context) (*ReceiveStream, error) {
+	c.separateUniStreams()
+	s, err := c.streams.uniQueue.get(ctx, c.testHooks)
+	if err != nil {
+		return nil, err
+	}
+	return &ReceiveStream{s}, nil
+}
+
+// separateUniStreams arranges for unidirectional streams created by the peer
+// to be returned by AcceptUniStream rather than AcceptStream.
+func (c *Conn) separateUniStreams() {
+	c.streams.streamsMu.Lock()
+	defer c.streams.streamsMu.Unlock()
+	if c.streams.uniSeparate {
+		return
+	}
+	c.streams.uniSeparate = true
+	// Move any unidirectional streams waiting to be returned by AcceptStream.
+	q := &c.streams.queue
+	q.gate.lock()
+	var uni []*Stream
+	bidi := q.q[:0]
+	for _, s := range q.q {
+		if s.id.streamType() == uniStream {
+			uni = append(uni, s)
+		} else {
+			bidi = append(bidi, s)
+		}
+	}
+	clear(q.q[len(bidi):])
+	q.q = bidi
+	q.unlock()
+	for _, s := range uni {
+		c.streams.uniQueue.put(s)
+	}
+}
+
+// Stream returns the underlying Stream.
+func (ss *SendStream) Stream() *Stream { return ss.s }
+
+// ID returns the stream's QUIC stream ID.
+func (ss *SendStream) ID() int64 { return ss.s.ID() }
+
+// Conn returns the connection the stream belongs to.
+func (ss *SendStream) Conn() *Conn { return ss.s.Conn() }
+
+// Context returns a context which is canceled when the connection terminates.
+// See Stream.Context.
+func (ss *SendStream) Context() context.Context { return ss.s.Context() }
+
+// Write writes data to the stream.
+// See Stream.WriteContext.
+func (ss *SendStream) Write(b []byte) (n int, err error) { return ss.s.Write(b) }
+
+// WriteContext writes data to the stream.
+// See Stream.WriteContext.
+func (ss *SendStream) WriteContext(ctx context.Context, b []byte) (n int, err error) {
+	return ss.s.WriteContext(ctx, b)
+}
+
+// WriteBuffers writes the contents of bufs to the stream.
+// See Stream.WriteBuffersContext.
+func (ss *SendStream) WriteBuffers(bufs [][]byte) (n int64, err error) {
+	return ss.s.WriteBuffers(bufs)
+}
+
+// WriteBuffersContext writes the contents of bufs to the stream.
+// See Stream.WriteBuffersContext.
+func (ss *SendStream) WriteBuffersContext(ctx context.Context, bufs [][]byte) (n int64, err error) {
+	return ss.s.WriteBuffersContext(ctx, bufs)
+}
+
+// Flush sends any buffered data to the peer.
+// See Stream.Flush.
+func (ss *SendStream) Flush() error { return ss.s.Flush() }
+
+// SetAutoFlush sets whether data written to the stream is sent immediately.
+// See Stream.SetAutoFlush.
+func (ss *SendStream) SetAutoFlush(autoFlush bool) { ss.s.SetAutoFlush(autoFlush) }
+
+// Close closes the stream and waits for the peer to acknowledge its data.
+// See Stream.CloseContext.
+func (ss *SendStream) Close() error { return ss.s.Close() }
+
+// CloseContext closes the stream and waits for the peer to acknowledge its data.
+// See Stream.CloseContext.
+func (ss *SendStream) CloseContext(ctx context.Context) error { return ss.s.CloseContext(ctx) }
+
+// Reset aborts writes on the stream.
+// See Stream.Reset.
+func (ss *SendStream) Reset(code uint64) { ss.s.Reset(code) }
+
+// SetPriority sets the stream's send priority.
+// See Stream.SetPriority.
+func (ss *SendStream) SetPriority(urgency int) { ss.s.SetPriority(urgency) }
+
+// Priority returns the stream's send priority.
+func (ss *SendStream) Priority() int { return ss.s.Priority() }
+
+// Stream returns the underlying Stream.
+func (rs *ReceiveStream) Stream() *Stream { return rs.s }
+
+// ID returns the stream's QUIC stream ID.
+func (rs *ReceiveStream) ID() int64 { return rs.s.ID() }
+
+// Conn returns the connection the stream belongs to.
+func (rs *ReceiveStream) Conn() *Conn { return rs.s.Conn() }
+
+// Context returns a context which is canceled when the peer resets the stream
+// or the connection terminates.
+// See Stream.Context.
+func (rs *ReceiveStream) Context() context.Context { return rs.s.Context() }
+
+// Read reads data from the stream.
+// See Stream.ReadContext.
+func (rs *ReceiveStream) Read(b []byte) (n int, err error) { return rs.s.Read(b) }
+
+// ReadContext reads data from the stream.
+// See Stream.ReadContext.
+func (rs *ReceiveStream) ReadContext(ctx context.Context, b []byte) (n int, err error) {
+	return rs.s.ReadContext(ctx, b)
+}
+
+// Peek returns the next n bytes of data from the stream without consuming them.
+// See Stream.PeekContext.
+func (rs *ReceiveStream) Peek(n int) ([]byte, error) { return rs.s.Peek(n) }
+
+// PeekContext returns the next n bytes of data from the stream without consuming them.
+// See Stream.PeekContext.
+func (rs *ReceiveStream) PeekContext(ctx context.Context, n int) ([]byte, error) {
+	return rs.s.PeekContext(ctx, n)
+}
+
+// Discard skips the next n bytes of data from the stream.
+// See Stream.Discard.
+func (rs *ReceiveStream) Discard(n int) (discarded int, err error) { return rs.s.Discard(n) }
+
+// Close aborts reads on the stream.
+// See Stream.CloseRead.
+func (rs *ReceiveStream) Close() error {
+	rs.s.CloseRead()
+	return nil
+}
+
+// StopSending aborts reads on the stream, sending the peer an error code.
+// See Stream.StopSending.
+func (rs *ReceiveStream) StopSending(code uint64) { rs.s.StopSending(code) }
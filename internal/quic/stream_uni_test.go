@@ -0,0 +1,165 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"context"
+	"io"
+	"testing"
+)
+
+func TestOpenUniStream(t *testing.T) {
+	tc := newTestConn(t, clientSide, permissiveTransportParameters)
+	tc.handshake()
+	tc.ignoreFrame(frameTypeAck)
+
+	s, err := tc.conn.OpenUniStream()
+	if err != nil {
+		t.Fatalf("OpenUniStream: %v", err)
+	}
+	if got, want := s.ID(), int64(newStreamID(clientSide, uniStream, 0)); got != want {
+		t.Fatalf("s.ID() = %v, want %v", got, want)
+	}
+	if !s.Stream().IsWriteOnly() {
+		t.Fatalf("s.Stream().IsWriteOnly() = false, want true")
+	}
+	want := []byte{0, 1, 2, 3}
+	if n, err := s.Write(want); n != len(want) || err != nil {
+		t.Fatalf("s.Write() = %v, %v; want %v, nil", n, err, len(want))
+	}
+	tc.wantFrame("data written to SendStream",
+		packetType1RTT, debugFrameStream{
+			id:   newStreamID(clientSide, uniStream, 0),
+			data: want,
+		})
+	s.Reset(1)
+	tc.wantFrame("SendStream.Reset sends RESET_STREAM",
+		packetType1RTT, debugFrameResetStream{
+			id:        newStreamID(clientSide, uniStream, 0),
+			code:      1,
+			finalSize: int64(len(want)),
+		})
+}
+
+func TestOpenUniStreamSync(t *testing.T) {
+	tc := newTestConn(t, clientSide,
+		permissiveTransportParameters,
+		func(p *transportParameters) {
+			p.initialMaxStreamsUni = 0
+		})
+	tc.handshake()
+	tc.ignoreFrame(frameTypeAck)
+
+	opening := runAsync(tc, func(ctx context.Context) (*SendStream, error) {
+		return tc.conn.OpenUniStreamSync(ctx)
+	})
+	if _, err := opening.result(); err != errNotDone {
+		t.Fatalf("OpenUniStreamSync blocked by limit: %v, want errNotDone", err)
+	}
+	tc.writeFrames(packetType1RTT, debugFrameMaxStreams{
+		streamType: uniStream,
+		max:        1,
+	})
+	s, err := opening.result()
+	if err != nil {
+		t.Fatalf("OpenUniStreamSync not completed after limit raised: %v", err)
+	}
+	if got, want := s.ID(), int64(newStreamID(clientSide, uniStream, 0)); got != want {
+		t.Fatalf("OpenUniStreamSync() = stream %v, want %v", got, want)
+	}
+}
+
+func TestAcceptUniStream(t *testing.T) {
+	ctx := canceledContext()
+	tc := newTestConn(t, serverSide)
+	tc.handshake()
+	tc.ignoreFrame(frameTypeAck)
+
+	// Streams created before the first call to AcceptUniStream
+	// are moved from the AcceptStream queue.
+	tc.writeFrames(packetType1RTT,
+		debugFrameStream{
+			id:   newStreamID(clientSide, bidiStream, 0),
+			data: []byte{0},
+		},
+		debugFrameStream{
+			id:   newStreamID(clientSide, uniStream, 0),
+			data: []byte{1},
+			fin:  true,
+		})
+	rs, err := tc.conn.AcceptUniStream(ctx)
+	if err != nil {
+		t.Fatalf("AcceptUniStream: %v", err)
+	}
+	if got, want := rs.ID(), int64(newStreamID(clientSide, uniStream, 0)); got != want {
+		t.Fatalf("AcceptUniStream() = stream %v, want %v", got, want)
+	}
+	if got, err := io.ReadAll(rs); err != nil || string(got) != "\x01" {
+		t.Fatalf("io.ReadAll(rs) = %x, %v; want 01, nil", got, err)
+	}
+
+	// Streams created after the first call to AcceptUniStream
+	// are never returned by AcceptStream.
+	tc.writeFrames(packetType1RTT,
+		debugFrameStream{
+			id: newStreamID(clientSide, uniStream, 1),
+		})
+	s, err := tc.conn.AcceptStream(ctx)
+	if err != nil {
+		t.Fatalf("AcceptStream: %v", err)
+	}
+	if got, want := s.ID(), int64(newStreamID(clientSide, bidiStream, 0)); got != want {
+		t.Fatalf("AcceptStream() = stream %v, want %v", got, want)
+	}
+	if _, err := tc.conn.AcceptStream(ctx); err != context.Canceled {
+		t.Fatalf("AcceptStream() = %v, want context.Canceled", err)
+	}
+	rs, err = tc.conn.AcceptUniStream(ctx)
+	if err != nil {
+		t.Fatalf("AcceptUniStream: %v", err)
+	}
+	if got, want := rs.ID(), int64(newStreamID(clientSide, uniStream, 1)); got != want {
+		t.Fatalf("AcceptUniStream() = stream %v, want %v", got, want)
+	}
+
+	rs.StopSending(2)
+	tc.wantFrame("ReceiveStream.StopSending sends STOP_SENDING",
+		packetType1RTT, debugFrameStopSending{
+			id:   newStreamID(clientSide, uniStream, 1),
+			code: 2,
+		})
+}
+
+func TestAcceptUniStreamBlocking(t *testing.T) {
+	tc := newTestConn(t, serverSide)
+	tc.handshake()
+
+	a := runAsync(tc, func(ctx context.Context) (*ReceiveStream, error) {
+		return tc.conn.AcceptUniStream(ctx)
+	})
+	if _, err := a.result(); err != errNotDone {
+		t.Fatalf("AcceptUniStream() = _, %v; want errNotDone", err)
+	}
+	tc.writeFrames(packetType1RTT,
+		debugFrameStream{
+			id: newStreamID(clientSide, bidiStream, 0),
+		})
+	if _, err := a.result(); err != errNotDone {
+		t.Fatalf("after peer creates bidi stream: AcceptUniStream() = _, %v; want errNotDone", err)
+	}
+	tc.writeFrames(packetType1RTT,
+		debugFrameStream{
+			id: newStreamID(clientSide, uniStream, 0),
+		})
+	rs, err := a.result()
+	if err != nil {
+		t.Fatalf("AcceptUniStream() = _, %v, want stream", err)
+	}
+	if got, want := rs.ID(), int64(newStreamID(clientSide, uniStream, 0)); got != want {
+		t.Fatalf("AcceptUniStream() = stream %v, want %v", got, want)
+	}
+}
@@ -15,16 +15,42 @@ import (
 )
 
 // startTLS starts the TLS handshake.
-func (c *Conn) startTLS(now time.Time, initialConnID []byte, params transportParameters) error {
-	c.keysInitial = initialKeys(initialConnID, c.side)
+//
+// params returns the transport parameters sent to the peer.
+func (c *Conn) startTLS(now time.Time, initialConnID []byte, params func() transportParameters) error {
+	c.keysInitial = initialKeys(c.version, initialConnID, c.side)
 
-	qconfig := &tls.QUICConfig{TLSConfig: c.config.TLSConfig}
+	tlsConfig := c.config.TLSConfig
+	if c.config.InsecureLoadTesting && c.side == clientSide {
+		tlsConfig = tlsConfig.Clone()
+		tlsConfig.InsecureSkipVerify = true
+		if tlsConfig.ClientSessionCache == nil {
+			tlsConfig.ClientSessionCache = c.listener.loadTestSessions
+		}
+		if tlsConfig.ServerName == "" {
+			// Sessions are cached by server name,
+			// and are not cached at all when the name is empty.
+			tlsConfig.ServerName = c.peerAddr.String()
+		}
+	}
+	if c.side == serverSide && c.config.GetConfigForConn != nil {
+		tlsConfig = c.connConfigTLSConfig(tlsConfig)
+		// Our transport parameters depend on the conn's Config,
+		// so we send them after receiving the ClientHello.
+		c.connConfig.params = params
+	}
+	if c.side == serverSide && (c.config.RequireServerName || c.config.VerifyServerName != nil) {
+		tlsConfig = c.serverNameTLSConfig(tlsConfig)
+	}
+	qconfig := &tls.QUICConfig{TLSConfig: tlsConfig}
 	if c.side == clientSide {
 		c.tls = tls.QUICClient(qconfig)
 	} else {
 		c.tls = tls.QUICServer(qconfig)
 	}
-	c.tls.SetTransportParameters(marshalTransportParameters(params))
+	if c.connConfig.params == nil {
+		c.tls.SetTransportParameters(marshalTransportParameters(params()))
+	}
 	// TODO: We don't need or want a context for cancelation here,
 	// but users can use a context to plumb values through to hooks defined
 	// in the tls.Config. Pass through a context.
@@ -35,6 +61,9 @@ func (c *Conn) startTLS(now time.Time, initialConnID []byte, params transportPar
 }
 
 func (c *Conn) handleTLSEvents(now time.Time) error {
+	if c.connConfig.pending != nil {
+		c.applyConnConfig(now)
+	}
 	for {
 		e := c.tls.NextEvent()
 		if c.testHooks != nil {
@@ -49,9 +78,9 @@ func (c *Conn) handleTLSEvents(now time.Time) error {
 			}
 			switch e.Level {
 			case tls.QUICEncryptionLevelHandshake:
-				c.keysHandshake.r.init(e.Suite, e.Data)
+				c.keysHandshake.r.init(c.version, e.Suite, e.Data)
 			case tls.QUICEncryptionLevelApplication:
-				c.keysAppData.r.init(e.Suite, e.Data)
+				c.keysAppData.r.init(c.version, e.Suite, e.Data)
 			}
 		case tls.QUICSetWriteSecret:
 			if err := checkCipherSuite(e.Suite); err != nil {
@@ -59,9 +88,14 @@ func (c *Conn) handleTLSEvents(now time.Time) error {
 			}
 			switch e.Level {
 			case tls.QUICEncryptionLevelHandshake:
-				c.keysHandshake.w.init(e.Suite, e.Data)
+				c.keysHandshake.w.init(c.version, e.Suite, e.Data)
 			case tls.QUICEncryptionLevelApplication:
-				c.keysAppData.w.init(e.Suite, e.Data)
+				c.keysAppData.w.init(c.version, e.Suite, e.Data)
+				if c.side == serverSide && c.config.HalfRTTData {
+					// We can send 0.5-RTT data now,
+					// so let the user accept the conn.
+					c.listener.serverConnReady(c)
+				}
 			}
 		case tls.QUICWriteData:
 			var space numberSpace
@@ -77,13 +111,23 @@ func (c *Conn) handleTLSEvents(now time.Time) error {
 			}
 			c.crypto[space].write(e.Data)
 		case tls.QUICHandshakeDone:
+			c.tlsState = c.tls.ConnectionState()
+			c.handshakeDone(now)
 			if c.side == serverSide {
 				// "[...] the TLS handshake is considered confirmed
 				// at the server when the handshake completes."
 				// https://www.rfc-editor.org/rfc/rfc9001#section-4.1.2-1
 				c.confirmHandshake(now)
+				if c.config.InsecureLoadTesting {
+					// Let the client resume this session on its next connection.
+					if err := sendSessionTicket(c.tls); err != nil {
+						return err
+					}
+				}
 			}
-			c.handshakeDone()
+		case tls.QUICTransportParametersRequired:
+			c.tls.SetTransportParameters(marshalTransportParameters(c.connConfig.params()))
+			c.connConfig.params = nil
 		case tls.QUICTransportParameters:
 			params, err := unmarshalTransportParams(e.Data)
 			if err != nil {
@@ -115,5 +159,8 @@ func (c *Conn) handleCrypto(now time.Time, space numberSpace, off int64, data []
 	if err != nil {
 		return err
 	}
+	if err := c.checkCryptoBuffered(); err != nil {
+		return err
+	}
 	return c.handleTLSEvents(now)
 }
@@ -7,6 +7,8 @@
 package quic
 
 import (
+	"bytes"
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"errors"
@@ -616,3 +618,190 @@ func TestConnAEADLimitReached(t *testing.T) {
 	tc.advance(1 * time.Second)
 	tc.wantIdle("auth failures at limit: conn does not process additional packets")
 }
+
+func TestInsecureLoadTestingSessionResumption(t *testing.T) {
+	// The client does not trust the server's certificate;
+	// InsecureLoadTesting disables verification.
+	clientTLS := newTestTLSConfig(clientSide)
+	clientTLS.InsecureSkipVerify = false
+	srv := newLocalListener(t, serverSide, &Config{InsecureLoadTesting: true})
+	cli := newLocalListener(t, clientSide, &Config{
+		TLSConfig:           clientTLS,
+		InsecureLoadTesting: true,
+	})
+	ctx := context.Background()
+	didResume := func(c *Conn) (resumed bool) {
+		c.runOnLoop(func(now time.Time, c *Conn) {
+			resumed = c.tls.ConnectionState().DidResume
+		})
+		return resumed
+	}
+
+	c, err := cli.Dial(ctx, "udp", srv.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("first Dial: %v", err)
+	}
+	if didResume(c) {
+		t.Fatalf("first connection resumed a session; want full handshake")
+	}
+
+	// The session ticket arrives after the client's handshake completes,
+	// so the next connection may race with it.
+	for i := 0; ; i++ {
+		c, err := cli.Dial(ctx, "udp", srv.LocalAddr().String())
+		if err != nil {
+			t.Fatalf("Dial: %v", err)
+		}
+		if didResume(c) {
+			break
+		}
+		if i == 10 {
+			t.Fatalf("connections did not resume a session")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestConnHalfRTTData(t *testing.T) {
+	tc := newTestConn(t, serverSide, func(c *Config) {
+		c.HalfRTTData = true
+	}, permissiveTransportParameters)
+	tc.ignoreFrame(frameTypeAck)
+	tc.ignoreFrame(frameTypeNewConnectionID)
+
+	if _, err := tc.listener.l.Accept(canceledContext()); err == nil {
+		t.Fatalf("Accept before receiving client Initial succeeded, want error")
+	}
+	tc.writeFrames(packetTypeInitial,
+		debugFrameCrypto{
+			data: tc.cryptoDataIn[tls.QUICEncryptionLevelInitial],
+		})
+	c, err := tc.listener.l.Accept(canceledContext())
+	if err != nil {
+		t.Fatalf("Accept after sending server handshake messages: %v", err)
+	}
+	if c != tc.conn {
+		t.Fatalf("Accept returned unexpected conn")
+	}
+
+	s, err := c.NewSendOnlyStream(canceledContext())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte("0.5-RTT data")
+	s.Write(want)
+	tc.wantFrame("server sends Initial CRYPTO frame",
+		packetTypeInitial, debugFrameCrypto{
+			data: tc.cryptoDataOut[tls.QUICEncryptionLevelInitial],
+		})
+	tc.wantFrame("server sends Handshake CRYPTO frame",
+		packetTypeHandshake, debugFrameCrypto{
+			data: tc.cryptoDataOut[tls.QUICEncryptionLevelHandshake],
+		})
+	tc.wantFrame("server sends stream data before handshake completes",
+		packetType1RTT, debugFrameStream{
+			id:   s.id,
+			data: want,
+		})
+
+	tc.writeFrames(packetTypeHandshake,
+		debugFrameCrypto{
+			data: tc.cryptoDataIn[tls.QUICEncryptionLevelHandshake],
+		})
+	tc.wantFrame("server sends HANDSHAKE_DONE after handshake completes",
+		packetType1RTT, debugFrameHandshakeDone{})
+	if _, err := tc.listener.l.Accept(canceledContext()); err == nil {
+		t.Fatalf("Accept after handshake completes returned conn again")
+	}
+}
+
+func TestConnHalfRTTDataDisabled(t *testing.T) {
+	tc := newTestConn(t, serverSide)
+	tc.ignoreFrame(frameTypeAck)
+	tc.writeFrames(packetTypeInitial,
+		debugFrameCrypto{
+			data: tc.cryptoDataIn[tls.QUICEncryptionLevelInitial],
+		})
+	if _, err := tc.listener.l.Accept(canceledContext()); err == nil {
+		t.Fatalf("Accept before handshake completes succeeded, want error")
+	}
+	tc.writeFrames(packetTypeHandshake,
+		debugFrameCrypto{
+			data: tc.cryptoDataIn[tls.QUICEncryptionLevelHandshake],
+		})
+	if _, err := tc.listener.l.Accept(canceledContext()); err != nil {
+		t.Fatalf("Accept after handshake completes: %v", err)
+	}
+}
+
+func TestConnClientCertificate(t *testing.T) {
+	serverConfig := newTestTLSConfig(serverSide)
+	serverConfig.ClientAuth = tls.RequireAnyClientCert
+	clientConfig := newTestTLSConfig(clientSide)
+	clientConfig.Certificates = []tls.Certificate{testCert}
+	cli, srv := newLocalConnPair(t, &Config{TLSConfig: serverConfig}, &Config{TLSConfig: clientConfig})
+	defer cli.Abort(nil)
+	defer srv.Abort(nil)
+
+	certs := srv.ConnectionState().PeerCertificates
+	if len(certs) != 1 || !bytes.Equal(certs[0].Raw, testCert.Certificate[0]) {
+		t.Errorf("server ConnectionState().PeerCertificates = %v, want client certificate", certs)
+	}
+	if got := cli.ConnectionState().PeerCertificates; len(got) != 1 {
+		t.Errorf("client ConnectionState().PeerCertificates has %v certificates, want 1", len(got))
+	}
+}
+
+func TestConnClientCertificateRequired(t *testing.T) {
+	serverConfig := newTestTLSConfig(serverSide)
+	serverConfig.ClientAuth = tls.RequireAnyClientCert
+	testConnClientCertificateFailure(t, serverConfig, newTestTLSConfig(clientSide),
+		errTLSBase+116) // 116: certificate_required
+}
+
+func TestConnClientCertificateNotTrusted(t *testing.T) {
+	serverConfig := newTestTLSConfig(serverSide)
+	serverConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	serverConfig.ClientCAs = x509.NewCertPool() // trusts no one
+	clientConfig := newTestTLSConfig(clientSide)
+	clientConfig.Certificates = []tls.Certificate{testCert}
+	testConnClientCertificateFailure(t, serverConfig, clientConfig,
+		errTLSBase+48) // 48: unknown_ca
+}
+
+func TestConnClientCertificateRejected(t *testing.T) {
+	serverConfig := newTestTLSConfig(serverSide)
+	serverConfig.ClientAuth = tls.RequireAnyClientCert
+	serverConfig.VerifyPeerCertificate = func([][]byte, [][]*x509.Certificate) error {
+		return errors.New("client certificate rejected")
+	}
+	clientConfig := newTestTLSConfig(clientSide)
+	clientConfig.Certificates = []tls.Certificate{testCert}
+	testConnClientCertificateFailure(t, serverConfig, clientConfig,
+		errTLSBase+42) // 42: bad_certificate
+}
+
+// testConnClientCertificateFailure connects a client to a server which rejects
+// the client's certificate, and checks that the server closes the connection
+// with the expected TLS alert.
+func testConnClientCertificateFailure(t *testing.T, serverConfig, clientConfig *tls.Config, want transportError) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	srv := newLocalListener(t, serverSide, &Config{TLSConfig: serverConfig})
+	cli := newLocalListener(t, clientSide, &Config{TLSConfig: clientConfig})
+
+	// The client's side of the handshake completes before the server
+	// verifies the client's certificate, so Dial may succeed.
+	c, err := cli.Dial(ctx, "udp", srv.LocalAddr().String())
+	if err == nil {
+		err = c.Wait(ctx)
+	}
+	var perr peerTransportError
+	if !errors.As(err, &perr) || perr.code != want {
+		t.Fatalf("client connection error: %v, want peer error %v", err, want)
+	}
+	if _, err := srv.Accept(canceledContext()); err == nil {
+		t.Fatalf("server accepted connection with rejected client certificate")
+	}
+}
@@ -0,0 +1,14 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21 && !go1.23
+
+package quic
+
+import "crypto/tls"
+
+// sendSessionTicket sends a session ticket to the client.
+func sendSessionTicket(q *tls.QUICConn) error {
+	return q.SendSessionTicket(false)
+}
@@ -0,0 +1,57 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"time"
+)
+
+// connTombstoneDuration is how long a listener remembers a connection
+// after it has been discarded.
+const connTombstoneDuration = 10 * time.Second
+
+// A connTombstone records a recently discarded connection.
+//
+// Packets may continue to arrive for a connection after it has closed.
+// Rather than treating these packets as belonging to an unknown connection
+// and responding with a stateless reset, the listener resends the
+// connection's final CONNECTION_CLOSE (if the peer never responded to it),
+// or silently drops the packet (if the connection ended in the draining state).
+//
+// "An endpoint that has not yet sent a CONNECTION_CLOSE [...]
+// MAY send the exact same packet in response to any received packet."
+// https://www.rfc-editor.org/rfc/rfc9000#section-10.2.1-3
+//
+// Tombstones are only accessed by the listener's datagram receive loop.
+type connTombstone struct {
+	cids    [][]byte
+	expires time.Time
+
+	// datagram is the last datagram the connection sent containing
+	// a CONNECTION_CLOSE frame, or nil if we should not resend it.
+	datagram []byte
+
+	// The datagram is resent with the same exponential backoff
+	// the connection used in the closing state.
+	lastSent  time.Time
+	sendDelay time.Duration
+}
+
+// handleTombstoneDatagram handles a datagram for a discarded connection.
+func (l *Listener) handleTombstoneDatagram(tomb *connTombstone, m *datagram) {
+	defer m.recycle()
+	if tomb.datagram == nil {
+		return
+	}
+	now := l.timeNow()
+	if now.Before(tomb.lastSent.Add(tomb.sendDelay)) {
+		return
+	}
+	tomb.lastSent = now
+	tomb.sendDelay *= 2
+	l.sendDatagram(tomb.datagram, m.localAddr, m.addr)
+}
@@ -0,0 +1,80 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTombstoneResendsConnectionClose(t *testing.T) {
+	tc := newTestConn(t, serverSide, func(c *Config) {
+		clear(c.StatelessResetKey[:])
+	})
+	tc.handshake()
+	tc.ignoreFrame(frameTypeAck)
+
+	tc.conn.Abort(&ApplicationError{Code: 7})
+	tc.wantFrame("aborting connection generates CONN_CLOSE",
+		packetType1RTT, debugFrameConnectionCloseApplication{
+			code: 7,
+		})
+	tc.advanceToTimer() // drain period ends
+	if err := tc.conn.Wait(canceledContext()); !errors.Is(err, errNoPeerResponse) {
+		t.Fatalf("conn.Wait() = %v, want errNoPeerResponse", err)
+	}
+	select {
+	case <-tc.conn.donec:
+	default:
+		t.Fatalf("conn did not exit after drain period")
+	}
+
+	tc.writeFrames(packetType1RTT, debugFramePing{})
+	tc.wantFrame("packet for discarded conn receives CONN_CLOSE",
+		packetType1RTT, debugFrameConnectionCloseApplication{
+			code: 7,
+		})
+	tc.writeFrames(packetType1RTT, debugFramePing{})
+	tc.wantIdle("CONN_CLOSE resends are rate limited")
+
+	tc.advance(connTombstoneDuration)
+	tc.writeFrames(packetType1RTT, debugFramePing{})
+	tc.wantIdle("tombstone expired, conn is forgotten")
+}
+
+func TestTombstonePeerClosedSuppressesStatelessReset(t *testing.T) {
+	tc := newTestConn(t, serverSide)
+	tc.handshake()
+	tc.ignoreFrame(frameTypeAck)
+
+	tc.writeFrames(packetType1RTT, debugFrameConnectionCloseTransport{
+		code: errNo,
+	})
+	tc.conn.Abort(nil)
+	tc.wantFrame("conn responds to peer's CONN_CLOSE",
+		packetType1RTT, debugFrameConnectionCloseTransport{
+			code: errNo,
+		})
+	tc.advanceToTimer() // drain period ends
+	select {
+	case <-tc.conn.donec:
+	default:
+		t.Fatalf("conn did not exit after drain period")
+	}
+
+	cid := tc.conn.connIDState.local[len(tc.conn.connIDState.local)-1].cid
+	tc.listener.write(newDatagramForReset(cid, 100, testClientAddr))
+	if got := tc.listener.read(); got != nil {
+		t.Fatalf("got response to packet for discarded conn, want none\ngot: %x", got)
+	}
+
+	tc.advance(connTombstoneDuration)
+	tc.listener.write(newDatagramForReset(cid, 100, testClientAddr))
+	if got := tc.listener.read(); got == nil {
+		t.Fatalf("tombstone expired, got no stateless reset")
+	}
+}
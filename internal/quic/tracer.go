@@ -0,0 +1,377 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"time"
+)
+
+// A ConnTracer observes events on a connection.
+//
+// A ConnTracer is created for each connection by Config.NewTracer.
+// Its methods are called on the connection's event loop goroutine:
+// they must not block, and must not call methods on the Conn.
+type ConnTracer interface {
+	// PacketSent is called for each packet sent.
+	PacketSent(now time.Time, p TracePacket)
+
+	// PacketReceived is called for each packet received and successfully decrypted,
+	// and for each Retry, Version Negotiation, or stateless reset packet accepted.
+	PacketReceived(now time.Time, p TracePacket)
+
+	// PacketLost is called when a sent packet is declared lost.
+	// The Frames field of the packet is not set.
+	PacketLost(now time.Time, p TracePacket)
+
+	// CongestionUpdated is called when congestion control or RTT state changes.
+	CongestionUpdated(now time.Time, s TraceCongestion)
+
+	// StateChanged is called when the connection changes state.
+	StateChanged(now time.Time, s TraceState)
+}
+
+// A PacketDropTracer is a ConnTracer which is notified
+// when the connection discards a received packet.
+type PacketDropTracer interface {
+	// PacketDropped is called when a received packet is discarded
+	// without being processed.
+	// The Frames field of the packet is not set,
+	// and the Number field is -1 if the packet number is not known.
+	PacketDropped(now time.Time, p TracePacket, reason TraceDropReason)
+}
+
+// A ClockJumpTracer is a ConnTracer which is notified
+// when the connection detects a jump in its clock.
+// See Config.ClockJumpThreshold.
+type ClockJumpTracer interface {
+	// ClockJumped is called when the clock has advanced by d more than
+	// the connection expected, and the connection will not count d
+	// towards its timers.
+	ClockJumped(now time.Time, d time.Duration)
+}
+
+// A TracePacket describes a QUIC packet.
+type TracePacket struct {
+	Type   string // "Initial", "Handshake", "1-RTT", "Retry", "Stateless Reset", etc.
+	Number int64  // packet number, or -1 for packets without one
+	Size   int    // size in bytes, including packet protection overhead
+	Frames []TraceFrame
+}
+
+// A TraceFrame describes a frame in a QUIC packet.
+type TraceFrame struct {
+	Type    uint64 // frame type, from RFC 9000 Section 19
+	Summary string // human-readable description of the frame contents
+}
+
+// A TraceCongestion is a snapshot of a connection's congestion control state.
+type TraceCongestion struct {
+	CongestionWindow   int
+	BytesInFlight      int
+	SlowStartThreshold int // math.MaxInt when not yet set
+	SmoothedRTT        time.Duration
+	MinRTT             time.Duration // -1 before the first RTT sample
+	RTTVariation       time.Duration
+}
+
+// A TraceState is a connection state.
+type TraceState int
+
+const (
+	// TraceStateHandshake is the state of a connection
+	// before the TLS handshake completes.
+	TraceStateHandshake = TraceState(iota)
+
+	// TraceStateHandshakeDone is entered when the TLS handshake completes.
+	TraceStateHandshakeDone
+
+	// TraceStateHandshakeConfirmed is entered when the handshake is confirmed.
+	// https://www.rfc-editor.org/rfc/rfc9001#section-4.1.2
+	TraceStateHandshakeConfirmed
+
+	// TraceStateClosing is entered when the connection is closed locally.
+	// https://www.rfc-editor.org/rfc/rfc9000.html#section-10.2.1
+	TraceStateClosing
+
+	// TraceStateDraining is entered when the peer closes the connection,
+	// or when the closing state ends.
+	// https://www.rfc-editor.org/rfc/rfc9000.html#section-10.2.2
+	TraceStateDraining
+
+	// TraceStateClosed is entered when the connection's state is discarded.
+	TraceStateClosed
+)
+
+func (s TraceState) String() string {
+	switch s {
+	case TraceStateHandshake:
+		return "handshake"
+	case TraceStateHandshakeDone:
+		return "handshake_done"
+	case TraceStateHandshakeConfirmed:
+		return "handshake_confirmed"
+	case TraceStateClosing:
+		return "closing"
+	case TraceStateDraining:
+		return "draining"
+	case TraceStateClosed:
+		return "closed"
+	default:
+		return "BUG"
+	}
+}
+
+// A TraceDropReason is the reason a received packet was discarded.
+type TraceDropReason int
+
+const (
+	// TraceDropKeysUnavailable indicates the keys needed to decrypt
+	// the packet are not available.
+	TraceDropKeysUnavailable = TraceDropReason(iota)
+
+	// TraceDropDecryptionFailed indicates the packet could not be parsed or decrypted.
+	TraceDropDecryptionFailed
+
+	// TraceDropDuplicate indicates the packet number has already been received.
+	TraceDropDuplicate
+
+	// TraceDropUnexpected indicates the packet is not valid
+	// in the connection's current state:
+	// for example, a Retry packet received after the client
+	// has processed a packet from the server.
+	TraceDropUnexpected
+
+	// TraceDropIntegrityCheckFailed indicates a Retry packet's
+	// Retry Integrity Tag could not be validated.
+	// https://www.rfc-editor.org/rfc/rfc9001#section-5.8
+	TraceDropIntegrityCheckFailed
+
+	// TraceDropInvalid indicates the packet is malformed or its contents are invalid:
+	// for example, a Version Negotiation packet which lists the version in use.
+	TraceDropInvalid
+)
+
+func (r TraceDropReason) String() string {
+	switch r {
+	case TraceDropKeysUnavailable:
+		return "keys_unavailable"
+	case TraceDropDecryptionFailed:
+		return "decryption_failed"
+	case TraceDropDuplicate:
+		return "duplicate"
+	case TraceDropUnexpected:
+		return "unexpected"
+	case TraceDropIntegrityCheckFailed:
+		return "integrity_check_failed"
+	case TraceDropInvalid:
+		return "invalid"
+	default:
+		return "BUG"
+	}
+}
+
+// traceState holds a Conn's tracer and the information needed to
+// report events to it.
+type traceState struct {
+	t ConnTracer
+
+	// Frames in the packet currently being constructed.
+	// Packet payloads are encrypted in place, so we record
+	// frames before protecting the packet.
+	sendFrames []TraceFrame
+
+	lastCongestion TraceCongestion
+}
+
+func (c *Conn) traceInit(now time.Time) {
+	if c.config.NewTracer != nil {
+		c.trace.t = c.config.NewTracer(c)
+	}
+	c.traceStateChanged(now, TraceStateHandshake)
+}
+
+// traceSendingPacket records the frames in a packet about to be sent.
+func (c *Conn) traceSendingPacket(payload []byte) {
+	if c.trace.t == nil {
+		return
+	}
+	c.trace.sendFrames = traceFrames(c.trace.sendFrames[:0], payload, c.errorCodeSpace())
+}
+
+// traceSentPacket reports a sent packet.
+// The packet's frames are those recorded by the last call to traceSendingPacket.
+func (c *Conn) traceSentPacket(now time.Time, ptype packetType, sent *sentPacket) {
+	if c.trace.t == nil || sent == nil {
+		return
+	}
+	c.trace.t.PacketSent(now, TracePacket{
+		Type:   ptype.String(),
+		Number: int64(sent.num),
+		Size:   sent.size,
+		Frames: append([]TraceFrame(nil), c.trace.sendFrames...),
+	})
+}
+
+// traceReceivedPacket reports a received packet.
+func (c *Conn) traceReceivedPacket(now time.Time, ptype packetType, pnum packetNumber, size int, payload []byte) {
+	if c.trace.t == nil {
+		return
+	}
+	c.trace.t.PacketReceived(now, TracePacket{
+		Type:   ptype.String(),
+		Number: int64(pnum),
+		Size:   size,
+		Frames: traceFrames(nil, payload, c.errorCodeSpace()),
+	})
+}
+
+// traceUnnumberedPacket reports an accepted packet which has no packet number
+// or protected payload: a Retry, Version Negotiation, or stateless reset.
+func (c *Conn) traceUnnumberedPacket(now time.Time, ptype string, size int) {
+	if c.trace.t == nil {
+		return
+	}
+	c.trace.t.PacketReceived(now, TracePacket{
+		Type:   ptype,
+		Number: -1,
+		Size:   size,
+	})
+}
+
+// traceDroppedPacket reports a discarded packet.
+// The pnum parameter is -1 if the packet number is not known.
+func (c *Conn) traceDroppedPacket(now time.Time, ptype packetType, pnum packetNumber, size int, reason TraceDropReason) {
+	if c.trace.t == nil {
+		return
+	}
+	t, ok := c.trace.t.(PacketDropTracer)
+	if !ok {
+		return
+	}
+	t.PacketDropped(now, TracePacket{
+		Type:   ptype.String(),
+		Number: int64(pnum),
+		Size:   size,
+	}, reason)
+}
+
+// traceClockJumped reports a clock jump.
+func (c *Conn) traceClockJumped(now time.Time, d time.Duration) {
+	if c.trace.t == nil {
+		return
+	}
+	if t, ok := c.trace.t.(ClockJumpTracer); ok {
+		t.ClockJumped(now, d)
+	}
+}
+
+// traceLostPacket reports a packet declared lost.
+func (c *Conn) traceLostPacket(now time.Time, space numberSpace, sent *sentPacket) {
+	if c.trace.t == nil {
+		return
+	}
+	var ptype packetType
+	switch space {
+	case initialSpace:
+		ptype = packetTypeInitial
+	case handshakeSpace:
+		ptype = packetTypeHandshake
+	case appDataSpace:
+		ptype = packetType1RTT
+	}
+	c.trace.t.PacketLost(now, TracePacket{
+		Type:   ptype.String(),
+		Number: int64(sent.num),
+		Size:   sent.size,
+	})
+}
+
+// ackOrLossFunc returns the function passed to lossState to handle
+// acknowledged and lost packets.
+// When tracing, lost packets are reported to the tracer.
+// When calls to Ping are waiting, 1-RTT packets are reported to them.
+func (c *Conn) ackOrLossFunc(now time.Time) func(numberSpace, *sentPacket, packetFate) {
+	if c.trace.t == nil && !c.pings.send.isSet() {
+		return c.handleAckOrLoss
+	}
+	return func(space numberSpace, sent *sentPacket, fate packetFate) {
+		if fate == packetLost {
+			c.traceLostPacket(now, space, sent)
+		}
+		if space == appDataSpace && c.pings.send.isSet() {
+			c.pingAckOrLoss(now, sent, fate)
+		}
+		c.handleAckOrLoss(space, sent, fate)
+	}
+}
+
+// traceCongestion reports the current congestion control state,
+// if it has changed since the last report.
+func (c *Conn) traceCongestion(now time.Time) {
+	if c.trace.t == nil {
+		return
+	}
+	s := TraceCongestion{
+		CongestionWindow:   c.loss.cc.congestionWindow,
+		BytesInFlight:      c.loss.cc.bytesInFlight,
+		SlowStartThreshold: c.loss.cc.slowStartThreshold,
+		SmoothedRTT:        c.loss.rtt.smoothedRTT,
+		MinRTT:             c.loss.rtt.minRTT,
+		RTTVariation:       c.loss.rtt.rttvar,
+	}
+	if s == c.trace.lastCongestion {
+		return
+	}
+	c.trace.lastCongestion = s
+	c.trace.t.CongestionUpdated(now, s)
+}
+
+// traceStateChanged reports a connection state transition.
+func (c *Conn) traceStateChanged(now time.Time, s TraceState) {
+	if c.trace.t == nil {
+		return
+	}
+	c.trace.t.StateChanged(now, s)
+}
+
+// traceFrames appends a description of each frame in payload to frames.
+// Application error codes are named using codes, which may be nil.
+func traceFrames(frames []TraceFrame, payload []byte, codes *ErrorCodeSpace) []TraceFrame {
+	for len(payload) > 0 {
+		ftype, _ := consumeVarint(payload)
+		f, n := parseDebugFrame(payload)
+		if n < 0 {
+			break
+		}
+		summary := f.String()
+		if code, ok := appErrorCode(f); ok {
+			if name := codes.Name(code); name != "" {
+				summary += " (" + name + ")"
+			}
+		}
+		frames = append(frames, TraceFrame{
+			Type:    ftype,
+			Summary: summary,
+		})
+		payload = payload[n:]
+	}
+	return frames
+}
+
+// appErrorCode returns the application error code carried by f.
+// It reports false if f carries none.
+func appErrorCode(f debugFrame) (uint64, bool) {
+	switch f := f.(type) {
+	case debugFrameResetStream:
+		return f.code, true
+	case debugFrameStopSending:
+		return f.code, true
+	case debugFrameConnectionCloseApplication:
+		return f.code, true
+	}
+	return 0, false
+}
@@ -0,0 +1,146 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testTracer struct {
+	sent       []TracePacket
+	received   []TracePacket
+	lost       []TracePacket
+	congestion []TraceCongestion
+	states     []TraceState
+	dropped    []tracedDrop
+}
+
+type tracedDrop struct {
+	p      TracePacket
+	reason TraceDropReason
+}
+
+func (t *testTracer) PacketSent(now time.Time, p TracePacket)     { t.sent = append(t.sent, p) }
+func (t *testTracer) PacketReceived(now time.Time, p TracePacket) { t.received = append(t.received, p) }
+func (t *testTracer) PacketLost(now time.Time, p TracePacket)     { t.lost = append(t.lost, p) }
+func (t *testTracer) CongestionUpdated(now time.Time, s TraceCongestion) {
+	t.congestion = append(t.congestion, s)
+}
+func (t *testTracer) StateChanged(now time.Time, s TraceState) { t.states = append(t.states, s) }
+func (t *testTracer) PacketDropped(now time.Time, p TracePacket, reason TraceDropReason) {
+	t.dropped = append(t.dropped, tracedDrop{p, reason})
+}
+
+func newTracedTestConn(t *testing.T, side connSide, opts ...any) (*testConn, *testTracer) {
+	t.Helper()
+	tr := &testTracer{}
+	opts = append(opts, func(c *Config) {
+		c.NewTracer = func(*Conn) ConnTracer {
+			return tr
+		}
+	})
+	return newTestConn(t, side, opts...), tr
+}
+
+func hasTraceFrame(packets []TracePacket, ftype uint64) bool {
+	for _, p := range packets {
+		for _, f := range p.Frames {
+			if f.Type == ftype {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func TestTracerHandshake(t *testing.T) {
+	tc, tr := newTracedTestConn(t, clientSide)
+	tc.handshake()
+
+	if len(tr.sent) == 0 || tr.sent[0].Type != "Initial" {
+		t.Fatalf("first traced sent packet: %v, want Initial", tr.sent)
+	}
+	if !hasTraceFrame(tr.sent, frameTypeCrypto) {
+		t.Errorf("traced sent packets contain no CRYPTO frame")
+	}
+	if !hasTraceFrame(tr.received, frameTypeHandshakeDone) {
+		t.Errorf("traced received packets contain no HANDSHAKE_DONE frame")
+	}
+	for _, p := range tr.sent {
+		if p.Size <= 0 {
+			t.Errorf("traced sent packet %v %v has size %v, want > 0", p.Type, p.Number, p.Size)
+		}
+	}
+	if len(tr.congestion) == 0 {
+		t.Errorf("no congestion updates traced after receiving acks")
+	}
+
+	tc.conn.Abort(nil)
+	tc.wantFrame("aborting connection generates CONN_CLOSE",
+		packetType1RTT, debugFrameConnectionCloseTransport{
+			code: errNo,
+		})
+	tc.writeFrames(packetType1RTT, debugFrameConnectionCloseTransport{})
+	tc.advanceToTimer()
+
+	want := []TraceState{
+		TraceStateHandshake,
+		TraceStateHandshakeDone,
+		TraceStateHandshakeConfirmed,
+		TraceStateClosing,
+		TraceStateDraining,
+		TraceStateClosed,
+	}
+	if !reflect.DeepEqual(tr.states, want) {
+		t.Errorf("traced states: %v\nwant: %v", tr.states, want)
+	}
+}
+
+func TestTracerServerHandshakeStates(t *testing.T) {
+	// The server confirms the handshake when it completes,
+	// but the handshake is still done before it is confirmed.
+	tc, tr := newTracedTestConn(t, serverSide)
+	tc.handshake()
+
+	want := []TraceState{
+		TraceStateHandshake,
+		TraceStateHandshakeDone,
+		TraceStateHandshakeConfirmed,
+	}
+	if !reflect.DeepEqual(tr.states, want) {
+		t.Errorf("traced states: %v\nwant: %v", tr.states, want)
+	}
+}
+
+func TestTracerPacketLost(t *testing.T) {
+	tc, tr := newTracedTestConn(t, clientSide)
+	tc.handshake()
+
+	tc.conn.ping(appDataSpace)
+	tc.wantFrame("first ping", packetType1RTT, debugFramePing{})
+	lostNum := tc.lastPacket.num
+	tc.conn.ping(appDataSpace)
+	tc.wantFrame("second ping", packetType1RTT, debugFramePing{})
+
+	// Acknowledging packets well past the first ping causes it to be declared lost.
+	tc.writeFrames(packetType1RTT, debugFrameAck{
+		ranges: []i64range[packetNumber]{{lostNum + 1, tc.lastPacket.num + 1}},
+	})
+	tc.advanceToTimer()
+
+	found := false
+	for _, p := range tr.lost {
+		if p.Number == int64(lostNum) && p.Type == "1-RTT" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("traced lost packets = %v, want packet %v", tr.lost, lostNum)
+	}
+}
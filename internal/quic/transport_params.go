@@ -8,7 +8,10 @@ package quic
 
 import (
 	"encoding/binary"
+	"errors"
+	"fmt"
 	"net/netip"
+	"slices"
 	"time"
 )
 
@@ -35,6 +38,11 @@ type transportParameters struct {
 	activeConnIDLimit              int64
 	initialSrcConnID               []byte
 	retrySrcConnID                 []byte
+	maxDatagramFrameSize           int64
+	minAckDelay                    time.Duration // negative if absent
+	chosenVersion                  uint32        // zero if version_information is absent
+	availableVersions              []uint32
+	unknown                        []TransportParameter
 }
 
 const (
@@ -51,6 +59,7 @@ func defaultTransportParameters() transportParameters {
 		ackDelayExponent:  defaultParamAckDelayExponent,
 		maxAckDelay:       defaultParamMaxAckDelayMilliseconds * time.Millisecond,
 		activeConnIDLimit: defaultParamActiveConnIDLimit,
+		minAckDelay:       -1,
 	}
 }
 
@@ -72,6 +81,9 @@ const (
 	paramActiveConnectionIDLimit         = 0x0e
 	paramInitialSourceConnectionID       = 0x0f
 	paramRetrySourceConnectionID         = 0x10
+	paramVersionInformation              = 0x11       // RFC 9368
+	paramMaxDatagramFrameSize            = 0x20       // RFC 9221
+	paramMinAckDelay                     = 0xff04de1b // draft-ietf-quic-ack-frequency
 )
 
 func marshalTransportParameters(p transportParameters) []byte {
@@ -161,11 +173,42 @@ func marshalTransportParameters(p transportParameters) []byte {
 		b = appendVarint(b, paramRetrySourceConnectionID)
 		b = appendVarintBytes(b, v)
 	}
+	if v := p.maxDatagramFrameSize; v != 0 {
+		b = appendVarint(b, paramMaxDatagramFrameSize)
+		b = appendVarint(b, uint64(sizeVarint(uint64(v))))
+		b = appendVarint(b, uint64(v))
+	}
+	if v := p.minAckDelay; v >= 0 {
+		us := uint64(v / time.Microsecond)
+		b = appendVarint(b, paramMinAckDelay)
+		b = appendVarint(b, uint64(sizeVarint(us)))
+		b = appendVarint(b, us)
+	}
+	if p.chosenVersion != 0 {
+		b = appendVarint(b, paramVersionInformation)
+		b = appendVarint(b, uint64(4+4*len(p.availableVersions)))
+		b = binary.BigEndian.AppendUint32(b, p.chosenVersion)
+		for _, v := range p.availableVersions {
+			b = binary.BigEndian.AppendUint32(b, v)
+		}
+	}
+	for _, u := range p.unknown {
+		b = appendVarint(b, u.ID)
+		b = appendVarintBytes(b, u.Value)
+	}
 	return b
 }
 
 func unmarshalTransportParams(params []byte) (transportParameters, error) {
+	return unmarshalTransportParamsWithOptions(params, TransportParameterOptions{})
+}
+
+func unmarshalTransportParamsWithOptions(params []byte, opts TransportParameterOptions) (transportParameters, error) {
 	p := defaultTransportParameters()
+	var seen map[uint64]bool
+	if opts.Strict {
+		seen = make(map[uint64]bool)
+	}
 	for len(params) > 0 {
 		id, n := consumeVarint(params)
 		if n < 0 {
@@ -177,6 +220,15 @@ func unmarshalTransportParams(params []byte) (transportParameters, error) {
 			return p, localTransportError(errTransportParameter)
 		}
 		params = params[n:]
+		if seen != nil {
+			// "An endpoint SHOULD treat receipt of duplicate transport
+			// parameters as a connection error of type TRANSPORT_PARAMETER_ERROR."
+			// https://www.rfc-editor.org/rfc/rfc9000#section-7.4-8
+			if seen[id] {
+				return p, localTransportError(errTransportParameter)
+			}
+			seen[id] = true
+		}
 		n = 0
 		switch id {
 		case paramOriginalDestinationConnectionID:
@@ -188,6 +240,9 @@ func unmarshalTransportParams(params []byte) (transportParameters, error) {
 			// If this is unreasonably large, consider it as no timeout to avoid
 			// time.Duration overflows.
 			if v > 1<<32 {
+				if opts.Strict {
+					return p, localTransportError(errTransportParameter)
+				}
 				v = 0
 			}
 			p.maxIdleTimeout = time.Duration(v) * time.Millisecond
@@ -272,12 +327,318 @@ func unmarshalTransportParams(params []byte) (transportParameters, error) {
 		case paramRetrySourceConnectionID:
 			p.retrySrcConnID = val
 			n = len(val)
+		case paramMaxDatagramFrameSize:
+			p.maxDatagramFrameSize, n = consumeVarintInt64(val)
+		case paramMinAckDelay:
+			var v uint64
+			v, n = consumeVarint(val)
+			// "Values of 2^24 or greater are invalid [...]"
+			// https://www.ietf.org/archive/id/draft-ietf-quic-ack-frequency-10.html#section-3-5
+			if v >= 1<<24 {
+				return p, localTransportError(errTransportParameter)
+			}
+			p.minAckDelay = time.Duration(v) * time.Microsecond
+		case paramVersionInformation:
+			// "An endpoint MUST treat a version_information transport parameter
+			// with a length that is not a multiple of 4, a Chosen Version of 0,
+			// or an Available Version of 0 as a parsing failure."
+			// https://www.rfc-editor.org/rfc/rfc9368#section-3-10
+			if len(val) < 4 || len(val)%4 != 0 {
+				return p, localTransportError(errTransportParameter)
+			}
+			p.chosenVersion = binary.BigEndian.Uint32(val)
+			p.availableVersions = nil
+			for i := 4; i < len(val); i += 4 {
+				p.availableVersions = append(p.availableVersions, binary.BigEndian.Uint32(val[i:]))
+			}
+			if p.chosenVersion == 0 || slices.Contains(p.availableVersions, 0) {
+				return p, localTransportError(errTransportParameter)
+			}
+			n = len(val)
 		default:
+			if opts.RetainUnknown {
+				p.unknown = append(p.unknown, TransportParameter{
+					ID:    id,
+					Value: val,
+				})
+			}
 			n = len(val)
 		}
 		if n != len(val) {
 			return p, localTransportError(errTransportParameter)
 		}
 	}
+	// "Receipt of a min_ack_delay that is greater than the max_ack_delay
+	// MUST be treated as a connection error of type TRANSPORT_PARAMETER_ERROR."
+	// https://www.ietf.org/archive/id/draft-ietf-quic-ack-frequency-10.html#section-3-5
+	if p.minAckDelay > p.maxAckDelay {
+		return p, localTransportError(errTransportParameter)
+	}
 	return p, nil
 }
+
+// TransportParameters are the QUIC transport parameters an endpoint sends
+// to its peer in the quic_transport_parameters TLS extension.
+// https://www.rfc-editor.org/rfc/rfc9000.html#section-18.2
+//
+// The zero value is not the default value of the parameters;
+// use DefaultTransportParameters to create a TransportParameters
+// with the default values defined by RFC 9000.
+type TransportParameters struct {
+	OriginalDestinationConnectionID []byte
+	MaxIdleTimeout                  time.Duration // zero for no timeout
+	StatelessResetToken             []byte
+	MaxUDPPayloadSize               int64
+	InitialMaxData                  int64
+	InitialMaxStreamDataBidiLocal   int64
+	InitialMaxStreamDataBidiRemote  int64
+	InitialMaxStreamDataUni         int64
+	InitialMaxStreamsBidi           int64
+	InitialMaxStreamsUni            int64
+	AckDelayExponent                int8
+	MaxAckDelay                     time.Duration
+	DisableActiveMigration          bool
+	PreferredAddress                *PreferredAddress // nil if not present
+	ActiveConnectionIDLimit         int64
+	InitialSourceConnectionID       []byte
+	RetrySourceConnectionID         []byte
+	MaxDatagramFrameSize            int64               // RFC 9221
+	MinAckDelay                     time.Duration       // draft-ietf-quic-ack-frequency; negative if absent
+	VersionInformation              *VersionInformation // RFC 9368; nil if not present
+
+	// Unknown contains parameters not recognized by this package,
+	// in the order they appear in the encoding.
+	// It is only populated by ParseTransportParameters when
+	// TransportParameterOptions.RetainUnknown is set.
+	// Marshal appends these parameters after all recognized ones.
+	Unknown []TransportParameter
+}
+
+// A PreferredAddress is the value of the preferred_address transport parameter.
+// https://www.rfc-editor.org/rfc/rfc9000.html#section-18.2-4.32.1
+//
+// An invalid (zero) IPv4 or IPv6 address is encoded as the unspecified
+// address, indicating the server has no preferred address of that family.
+type PreferredAddress struct {
+	IPv4                netip.AddrPort
+	IPv6                netip.AddrPort
+	ConnectionID        []byte
+	StatelessResetToken []byte // 16 bytes
+}
+
+// A VersionInformation is the value of the version_information transport parameter.
+// https://www.rfc-editor.org/rfc/rfc9368#section-3
+type VersionInformation struct {
+	ChosenVersion     Version
+	AvailableVersions []Version
+}
+
+// A TransportParameter is a single transport parameter in its encoded form.
+type TransportParameter struct {
+	ID    uint64
+	Value []byte
+}
+
+// TransportParameterOptions configures ParseTransportParameters.
+type TransportParameterOptions struct {
+	// Strict rejects encodings which RFC 9000 permits, but does not require,
+	// an endpoint to reject: duplicate parameters, and a max_idle_timeout
+	// too large to represent as a time.Duration.
+	// When Strict is not set, the last instance of a duplicated parameter
+	// is used, and an unrepresentable max_idle_timeout is treated as no timeout.
+	Strict bool
+
+	// RetainUnknown causes parameters not recognized by this package,
+	// including reserved parameters used to exercise extension points,
+	// to be returned in TransportParameters.Unknown.
+	// When RetainUnknown is not set, unknown parameters are ignored.
+	RetainUnknown bool
+}
+
+// errInvalidTransportParameters is returned by ParseTransportParameters.
+var errInvalidTransportParameters = errors.New("quic: invalid transport parameters")
+
+// DefaultTransportParameters returns the default values of the
+// transport parameters, as defined by RFC 9000.
+// These are the values of parameters absent from an encoding.
+func DefaultTransportParameters() TransportParameters {
+	return exportTransportParameters(defaultTransportParameters())
+}
+
+// ParseTransportParameters decodes the contents of a
+// quic_transport_parameters TLS extension.
+//
+// Parameters absent from the encoding have their default values.
+// ParseTransportParameters validates the values of the parameters it recognizes,
+// but does not check whether each parameter may be sent by a client or server.
+func ParseTransportParameters(b []byte, opts TransportParameterOptions) (TransportParameters, error) {
+	p, err := unmarshalTransportParamsWithOptions(b, opts)
+	if err != nil {
+		return TransportParameters{}, errInvalidTransportParameters
+	}
+	return exportTransportParameters(p), nil
+}
+
+// Marshal returns the encoding of p, suitable for use as the contents of a
+// quic_transport_parameters TLS extension.
+// Parameters with their default values are omitted.
+//
+// Marshal returns an error if a parameter cannot be encoded:
+// an integer parameter which is negative or does not fit in a variable-length
+// integer, a stateless reset token which is not 16 bytes long,
+// or a connection ID longer than 20 bytes.
+func (p *TransportParameters) Marshal() ([]byte, error) {
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	return marshalTransportParameters(p.internal()), nil
+}
+
+// validate reports whether p can be encoded.
+func (p *TransportParameters) validate() error {
+	for _, v := range []struct {
+		name string
+		v    int64
+	}{
+		{"max_idle_timeout", int64(p.MaxIdleTimeout)},
+		{"max_udp_payload_size", p.MaxUDPPayloadSize},
+		{"initial_max_data", p.InitialMaxData},
+		{"initial_max_stream_data_bidi_local", p.InitialMaxStreamDataBidiLocal},
+		{"initial_max_stream_data_bidi_remote", p.InitialMaxStreamDataBidiRemote},
+		{"initial_max_stream_data_uni", p.InitialMaxStreamDataUni},
+		{"initial_max_streams_bidi", p.InitialMaxStreamsBidi},
+		{"initial_max_streams_uni", p.InitialMaxStreamsUni},
+		{"ack_delay_exponent", int64(p.AckDelayExponent)},
+		{"max_ack_delay", int64(p.MaxAckDelay)},
+		{"active_connection_id_limit", p.ActiveConnectionIDLimit},
+		{"max_datagram_frame_size", p.MaxDatagramFrameSize},
+	} {
+		if v.v < 0 || v.v > maxVarint {
+			return fmt.Errorf("quic: transport parameter %v out of range: %v", v.name, v.v)
+		}
+	}
+	for _, v := range []struct {
+		name string
+		b    []byte
+	}{
+		{"original_destination_connection_id", p.OriginalDestinationConnectionID},
+		{"initial_source_connection_id", p.InitialSourceConnectionID},
+		{"retry_source_connection_id", p.RetrySourceConnectionID},
+	} {
+		if len(v.b) > maxConnIDLen {
+			return fmt.Errorf("quic: transport parameter %v too long", v.name)
+		}
+	}
+	if p.StatelessResetToken != nil && len(p.StatelessResetToken) != statelessResetTokenLen {
+		return errors.New("quic: transport parameter stateless_reset_token must be 16 bytes")
+	}
+	if pa := p.PreferredAddress; pa != nil {
+		if len(pa.ConnectionID) > maxConnIDLen {
+			return errors.New("quic: preferred_address connection ID too long")
+		}
+		if len(pa.StatelessResetToken) != statelessResetTokenLen {
+			return errors.New("quic: preferred_address stateless reset token must be 16 bytes")
+		}
+	}
+	if vi := p.VersionInformation; vi != nil {
+		if vi.ChosenVersion == 0 || slices.Contains(vi.AvailableVersions, 0) {
+			return errors.New("quic: transport parameter version_information contains version 0")
+		}
+	}
+	for _, u := range p.Unknown {
+		if u.ID > maxVarint {
+			return fmt.Errorf("quic: transport parameter ID out of range: %v", u.ID)
+		}
+	}
+	return nil
+}
+
+func exportTransportParameters(p transportParameters) TransportParameters {
+	e := TransportParameters{
+		OriginalDestinationConnectionID: p.originalDstConnID,
+		MaxIdleTimeout:                  p.maxIdleTimeout,
+		StatelessResetToken:             p.statelessResetToken,
+		MaxUDPPayloadSize:               p.maxUDPPayloadSize,
+		InitialMaxData:                  p.initialMaxData,
+		InitialMaxStreamDataBidiLocal:   p.initialMaxStreamDataBidiLocal,
+		InitialMaxStreamDataBidiRemote:  p.initialMaxStreamDataBidiRemote,
+		InitialMaxStreamDataUni:         p.initialMaxStreamDataUni,
+		InitialMaxStreamsBidi:           p.initialMaxStreamsBidi,
+		InitialMaxStreamsUni:            p.initialMaxStreamsUni,
+		AckDelayExponent:                p.ackDelayExponent,
+		MaxAckDelay:                     p.maxAckDelay,
+		DisableActiveMigration:          p.disableActiveMigration,
+		ActiveConnectionIDLimit:         p.activeConnIDLimit,
+		InitialSourceConnectionID:       p.initialSrcConnID,
+		RetrySourceConnectionID:         p.retrySrcConnID,
+		MaxDatagramFrameSize:            p.maxDatagramFrameSize,
+		MinAckDelay:                     p.minAckDelay,
+		Unknown:                         p.unknown,
+	}
+	if p.chosenVersion != 0 {
+		vi := &VersionInformation{
+			ChosenVersion: Version(p.chosenVersion),
+		}
+		for _, v := range p.availableVersions {
+			vi.AvailableVersions = append(vi.AvailableVersions, Version(v))
+		}
+		e.VersionInformation = vi
+	}
+	if p.preferredAddrConnID != nil {
+		e.PreferredAddress = &PreferredAddress{
+			IPv4:                p.preferredAddrV4,
+			IPv6:                p.preferredAddrV6,
+			ConnectionID:        p.preferredAddrConnID,
+			StatelessResetToken: p.preferredAddrResetToken,
+		}
+	}
+	return e
+}
+
+func (p *TransportParameters) internal() transportParameters {
+	i := transportParameters{
+		originalDstConnID:              p.OriginalDestinationConnectionID,
+		maxIdleTimeout:                 p.MaxIdleTimeout,
+		statelessResetToken:            p.StatelessResetToken,
+		maxUDPPayloadSize:              p.MaxUDPPayloadSize,
+		initialMaxData:                 p.InitialMaxData,
+		initialMaxStreamDataBidiLocal:  p.InitialMaxStreamDataBidiLocal,
+		initialMaxStreamDataBidiRemote: p.InitialMaxStreamDataBidiRemote,
+		initialMaxStreamDataUni:        p.InitialMaxStreamDataUni,
+		initialMaxStreamsBidi:          p.InitialMaxStreamsBidi,
+		initialMaxStreamsUni:           p.InitialMaxStreamsUni,
+		ackDelayExponent:               p.AckDelayExponent,
+		maxAckDelay:                    p.MaxAckDelay,
+		disableActiveMigration:         p.DisableActiveMigration,
+		activeConnIDLimit:              p.ActiveConnectionIDLimit,
+		initialSrcConnID:               p.InitialSourceConnectionID,
+		retrySrcConnID:                 p.RetrySourceConnectionID,
+		maxDatagramFrameSize:           p.MaxDatagramFrameSize,
+		minAckDelay:                    p.MinAckDelay,
+		unknown:                        p.Unknown,
+	}
+	if vi := p.VersionInformation; vi != nil {
+		i.chosenVersion = uint32(vi.ChosenVersion)
+		for _, v := range vi.AvailableVersions {
+			i.availableVersions = append(i.availableVersions, uint32(v))
+		}
+	}
+	if pa := p.PreferredAddress; pa != nil {
+		i.preferredAddrV4 = pa.IPv4
+		if !i.preferredAddrV4.Addr().Is4() {
+			i.preferredAddrV4 = netip.AddrPortFrom(netip.IPv4Unspecified(), pa.IPv4.Port())
+		}
+		i.preferredAddrV6 = pa.IPv6
+		if !i.preferredAddrV6.Addr().Is6() {
+			i.preferredAddrV6 = netip.AddrPortFrom(netip.IPv6Unspecified(), pa.IPv6.Port())
+		}
+		i.preferredAddrConnID = pa.ConnectionID
+		if i.preferredAddrConnID == nil {
+			// The internal representation uses a nil connection ID
+			// to indicate the preferred address is absent.
+			i.preferredAddrConnID = []byte{}
+		}
+		i.preferredAddrResetToken = pa.StatelessResetToken
+	}
+	return i
+}
@@ -182,6 +182,24 @@ func TestTransportParametersMarshalUnmarshal(t *testing.T) {
 			byte(len("connid")),
 			'c', 'o', 'n', 'n', 'i', 'd',
 		},
+	}, {
+		params: func(p *transportParameters) {
+			p.maxDatagramFrameSize = 65535
+		},
+		enc: []byte{
+			0x20,                   // max_datagram_frame_size
+			4,                      // length
+			0x80, 0x00, 0xff, 0xff, // varint value
+		},
+	}, {
+		params: func(p *transportParameters) {
+			p.minAckDelay = 1 * time.Millisecond
+		},
+		enc: []byte{
+			0xc0, 0x00, 0x00, 0x00, 0xff, 0x04, 0xde, 0x1b, // min_ack_delay
+			2,          // length
+			0x43, 0xe8, // varint usecs
+		},
 	}} {
 		wantParams := defaultTransportParameters()
 		test.params(&wantParams)
@@ -287,6 +305,23 @@ func TestTransportParametersErrors(t *testing.T) {
 			'8', '9', 'a', 'b', 'c', 'd', 'e', 'f', // reset token
 
 		},
+	}, {
+		desc: "min_ack_delay is larger than max_ack_delay",
+		enc: []byte{
+			0xc0, 0x00, 0x00, 0x00, 0xff, 0x04, 0xde, 0x1b, // min_ack_delay
+			4,                      // length
+			0x80, 0x00, 0x75, 0x30, // 30ms, default max_ack_delay is 25ms
+		},
+	}, {
+		desc: "min_ack_delay is too large",
+		enc: []byte{
+			0x0b,       // max_ack_delay
+			2,          // length
+			0x7f, 0xff, // 2^14-1 msecs
+			0xc0, 0x00, 0x00, 0x00, 0xff, 0x04, 0xde, 0x1b, // min_ack_delay
+			4,                      // length
+			0x81, 0x00, 0x00, 0x00, // 2^24 usecs
+		},
 	}} {
 		_, err := unmarshalTransportParams(test.enc)
 		if err == nil {
@@ -386,3 +421,282 @@ func FuzzTransportParametersMarshalUnmarshal(f *testing.F) {
 		}
 	})
 }
+
+func TestParseTransportParametersRFC9001(t *testing.T) {
+	// Transport parameters from the client Initial in RFC 9001, Appendix A.2.
+	// https://www.rfc-editor.org/rfc/rfc9001#section-a.2
+	enc := []byte{
+		0x04, 0x08, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, // initial_max_data
+		0x05, 0x04, 0x80, 0x00, 0xff, 0xff, // initial_max_stream_data_bidi_local
+		0x07, 0x04, 0x80, 0x00, 0xff, 0xff, // initial_max_stream_data_uni
+		0x08, 0x01, 0x10, // initial_max_streams_bidi
+		0x01, 0x04, 0x80, 0x00, 0x75, 0x30, // max_idle_timeout
+		0x09, 0x01, 0x10, // initial_max_streams_uni
+		0x0f, 0x08, 0x83, 0x94, 0xc8, 0xf0, 0x3e, 0x51, 0x57, 0x08, // initial_source_connection_id
+		0x06, 0x04, 0x80, 0x00, 0xff, 0xff, // initial_max_stream_data_bidi_remote
+	}
+	want := DefaultTransportParameters()
+	want.InitialMaxData = maxVarint
+	want.InitialMaxStreamDataBidiLocal = 0xffff
+	want.InitialMaxStreamDataBidiRemote = 0xffff
+	want.InitialMaxStreamDataUni = 0xffff
+	want.InitialMaxStreamsBidi = 16
+	want.InitialMaxStreamsUni = 16
+	want.MaxIdleTimeout = 30 * time.Second
+	want.InitialSourceConnectionID = []byte{0x83, 0x94, 0xc8, 0xf0, 0x3e, 0x51, 0x57, 0x08}
+	for _, opts := range []TransportParameterOptions{
+		{},
+		{Strict: true, RetainUnknown: true},
+	} {
+		got, err := ParseTransportParameters(enc, opts)
+		if err != nil {
+			t.Fatalf("ParseTransportParameters(%x, %+v) = %v", enc, opts, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("ParseTransportParameters(%x, %+v):\n got: %#v\nwant: %#v", enc, opts, got, want)
+		}
+	}
+}
+
+func TestTransportParametersExportedRoundTrip(t *testing.T) {
+	for _, test := range []struct {
+		desc   string
+		params func(p *TransportParameters)
+	}{{
+		desc:   "defaults",
+		params: func(p *TransportParameters) {},
+	}, {
+		desc: "server parameters",
+		params: func(p *TransportParameters) {
+			p.OriginalDestinationConnectionID = []byte("odcid")
+			p.StatelessResetToken = []byte("0123456789abcdef")
+			p.RetrySourceConnectionID = []byte("rscid")
+			p.PreferredAddress = &PreferredAddress{
+				IPv4:                netip.MustParseAddrPort("127.0.0.1:80"),
+				IPv6:                netip.MustParseAddrPort("[fe80::1]:1024"),
+				ConnectionID:        []byte("connid"),
+				StatelessResetToken: []byte("fedcba9876543210"),
+			}
+		},
+	}, {
+		desc: "all integer parameters",
+		params: func(p *TransportParameters) {
+			p.MaxIdleTimeout = 10 * time.Second
+			p.MaxUDPPayloadSize = 1200
+			p.InitialMaxData = 1
+			p.InitialMaxStreamDataBidiLocal = 2
+			p.InitialMaxStreamDataBidiRemote = 3
+			p.InitialMaxStreamDataUni = 4
+			p.InitialMaxStreamsBidi = 5
+			p.InitialMaxStreamsUni = 6
+			p.AckDelayExponent = 7
+			p.MaxAckDelay = 8 * time.Millisecond
+			p.DisableActiveMigration = true
+			p.ActiveConnectionIDLimit = 9
+			p.InitialSourceConnectionID = []byte("iscid")
+			p.MaxDatagramFrameSize = 10
+		},
+	}, {
+		desc: "version information",
+		params: func(p *TransportParameters) {
+			p.VersionInformation = &VersionInformation{
+				ChosenVersion:     Version2,
+				AvailableVersions: []Version{Version2, Version1},
+			}
+		},
+	}, {
+		desc: "unknown parameters",
+		params: func(p *TransportParameters) {
+			p.InitialMaxData = 1
+			p.Unknown = []TransportParameter{
+				{ID: 27, Value: []byte{}},              // reserved
+				{ID: 0x4000, Value: []byte{1, 2, 3}},   // unknown
+				{ID: 31*100 + 27, Value: []byte{0xff}}, // reserved
+			}
+		},
+	}} {
+		t.Run(test.desc, func(t *testing.T) {
+			want := DefaultTransportParameters()
+			test.params(&want)
+			enc, err := want.Marshal()
+			if err != nil {
+				t.Fatalf("Marshal() = %v", err)
+			}
+			got, err := ParseTransportParameters(enc, TransportParameterOptions{
+				Strict:        true,
+				RetainUnknown: true,
+			})
+			if err != nil {
+				t.Fatalf("ParseTransportParameters(%x) = %v", enc, err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("ParseTransportParameters(%x):\n got: %#v\nwant: %#v", enc, got, want)
+			}
+		})
+	}
+}
+
+func TestTransportParametersPreferredAddressUnspecified(t *testing.T) {
+	p := DefaultTransportParameters()
+	p.PreferredAddress = &PreferredAddress{
+		IPv6:                netip.MustParseAddrPort("[fe80::1]:1024"),
+		ConnectionID:        []byte("connid"),
+		StatelessResetToken: []byte("fedcba9876543210"),
+	}
+	enc, err := p.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ParseTransportParameters(enc, TransportParameterOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := got.PreferredAddress.IPv4, netip.AddrPortFrom(netip.IPv4Unspecified(), 0); got != want {
+		t.Errorf("preferred_address IPv4 = %v, want %v", got, want)
+	}
+}
+
+func TestParseTransportParametersStrict(t *testing.T) {
+	tooManyMS := 1 + (math.MaxInt64 / uint64(time.Millisecond))
+	var overflow []byte
+	overflow = appendVarint(overflow, paramMaxIdleTimeout)
+	overflow = appendVarint(overflow, uint64(sizeVarint(tooManyMS)))
+	overflow = appendVarint(overflow, tooManyMS)
+
+	for _, test := range []struct {
+		desc string
+		enc  []byte
+	}{{
+		desc: "duplicate parameter",
+		enc: []byte{
+			0x04, 1, 10, // initial_max_data
+			0x04, 1, 20, // initial_max_data
+		},
+	}, {
+		desc: "duplicate unknown parameter",
+		enc: []byte{
+			0x1b, 0, // reserved parameter
+			0x1b, 0, // reserved parameter
+		},
+	}, {
+		desc: "max_idle_timeout overflows time.Duration",
+		enc:  overflow,
+	}} {
+		t.Run(test.desc, func(t *testing.T) {
+			if _, err := ParseTransportParameters(test.enc, TransportParameterOptions{}); err != nil {
+				t.Errorf("lenient ParseTransportParameters(%x) = %v, want success", test.enc, err)
+			}
+			if _, err := ParseTransportParameters(test.enc, TransportParameterOptions{Strict: true}); err == nil {
+				t.Errorf("strict ParseTransportParameters(%x) succeeded, want error", test.enc)
+			}
+		})
+	}
+}
+
+func TestParseTransportParametersLenientDuplicate(t *testing.T) {
+	enc := []byte{
+		0x04, 1, 10, // initial_max_data
+		0x04, 1, 20, // initial_max_data
+	}
+	p, err := ParseTransportParameters(enc, TransportParameterOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := p.InitialMaxData, int64(20); got != want {
+		t.Errorf("duplicated initial_max_data = %v, want last value %v", got, want)
+	}
+}
+
+func TestParseTransportParametersUnknown(t *testing.T) {
+	enc := []byte{
+		0x1b, 1, 0xaa, // reserved parameter
+		0x04, 1, 10, // initial_max_data
+		0x40, 0x40, 0, // unknown parameter 0x40
+	}
+	p, err := ParseTransportParameters(enc, TransportParameterOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Unknown != nil {
+		t.Errorf("without RetainUnknown: Unknown = %v, want nil", p.Unknown)
+	}
+	p, err = ParseTransportParameters(enc, TransportParameterOptions{RetainUnknown: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []TransportParameter{
+		{ID: 0x1b, Value: []byte{0xaa}},
+		{ID: 0x40, Value: []byte{}},
+	}
+	if !reflect.DeepEqual(p.Unknown, want) {
+		t.Errorf("with RetainUnknown: Unknown = %v, want %v", p.Unknown, want)
+	}
+	if got, err := p.Marshal(); err != nil || !bytes.Equal(got, []byte{
+		0x04, 1, 10, // initial_max_data
+		0x1b, 1, 0xaa, // reserved parameter
+		0x40, 0x40, 0, // unknown parameter 0x40
+	}) {
+		t.Errorf("Marshal() = %x, %v; want unknown parameters appended", got, err)
+	}
+}
+
+func TestTransportParametersMarshalInvalid(t *testing.T) {
+	for _, test := range []struct {
+		desc   string
+		params func(p *TransportParameters)
+	}{{
+		desc:   "negative initial_max_data",
+		params: func(p *TransportParameters) { p.InitialMaxData = -1 },
+	}, {
+		desc:   "initial_max_streams_bidi too large",
+		params: func(p *TransportParameters) { p.InitialMaxStreamsBidi = 1 << 62 },
+	}, {
+		desc:   "negative max_ack_delay",
+		params: func(p *TransportParameters) { p.MaxAckDelay = -time.Millisecond },
+	}, {
+		desc:   "negative ack_delay_exponent",
+		params: func(p *TransportParameters) { p.AckDelayExponent = -1 },
+	}, {
+		desc:   "short stateless_reset_token",
+		params: func(p *TransportParameters) { p.StatelessResetToken = []byte("short") },
+	}, {
+		desc: "long connection ID",
+		params: func(p *TransportParameters) {
+			p.InitialSourceConnectionID = make([]byte, maxConnIDLen+1)
+		},
+	}, {
+		desc: "preferred_address without stateless reset token",
+		params: func(p *TransportParameters) {
+			p.PreferredAddress = &PreferredAddress{
+				ConnectionID: []byte("connid"),
+			}
+		},
+	}, {
+		desc: "zero version_information Chosen Version",
+		params: func(p *TransportParameters) {
+			p.VersionInformation = &VersionInformation{}
+		},
+	}, {
+		desc: "unknown parameter ID too large",
+		params: func(p *TransportParameters) {
+			p.Unknown = []TransportParameter{{ID: 1 << 62}}
+		},
+	}} {
+		t.Run(test.desc, func(t *testing.T) {
+			p := DefaultTransportParameters()
+			test.params(&p)
+			if enc, err := p.Marshal(); err == nil {
+				t.Errorf("Marshal() = %x, want error", enc)
+			}
+		})
+	}
+}
+
+func TestParseTransportParametersError(t *testing.T) {
+	enc := []byte{
+		0x02, 1, 0, // stateless_reset_token, too short
+	}
+	if _, err := ParseTransportParameters(enc, TransportParameterOptions{}); err == nil {
+		t.Errorf("ParseTransportParameters(%x) succeeded, want error", enc)
+	}
+}
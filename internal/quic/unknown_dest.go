@@ -0,0 +1,90 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"hash/maphash"
+	"runtime"
+	"sync"
+)
+
+// maxUnknownDestBacklog is the maximum number of datagrams queued for each
+// unknownDestPool worker before further datagrams are dropped.
+const maxUnknownDestBacklog = 128
+
+// An unknownDestPool handles datagrams addressed to unknown connections
+// on a bounded set of worker goroutines.
+//
+// Handling these datagrams may involve validating a Retry token,
+// creating a new connection, or generating a stateless reset.
+// Doing this work off the listen loop means a flood of Initial packets
+// does not delay the delivery of datagrams to existing connections.
+//
+// Datagrams are assigned to workers by destination connection ID,
+// so datagrams for the same connection are handled in order,
+// and a client's retransmitted Initial packets do not race to create
+// two connections.
+type unknownDestPool struct {
+	seed    maphash.Seed
+	shards  []chan *datagram
+	pending sync.WaitGroup // datagrams queued or being handled
+}
+
+func (p *unknownDestPool) init(l *Listener) {
+	p.seed = maphash.MakeSeed()
+	workers := runtime.GOMAXPROCS(0)
+	for i := 0; i < workers; i++ {
+		ch := make(chan *datagram, maxUnknownDestBacklog)
+		p.shards = append(p.shards, ch)
+		go p.run(l, ch)
+	}
+}
+
+// dispatch queues a datagram to be handled by a worker.
+// If the worker's queue is full, the datagram is dropped.
+//
+// This is called on the listen loop.
+func (p *unknownDestPool) dispatch(dstConnID []byte, m *datagram) {
+	ch := p.shards[maphash.Bytes(p.seed, dstConnID)%uint64(len(p.shards))]
+	p.pending.Add(1)
+	select {
+	case ch <- m:
+	default:
+		p.pending.Done()
+		m.recycle()
+	}
+}
+
+// run is a worker loop.
+// It exits after the Listener's listen loop exits,
+// discarding any datagrams still queued.
+func (p *unknownDestPool) run(l *Listener, ch <-chan *datagram) {
+	for {
+		select {
+		case m := <-ch:
+			l.handleUnknownDestinationDatagramOffLoop(m)
+			p.pending.Done()
+		case <-l.closec:
+			// The listen loop has exited, so nothing more will be queued.
+			for {
+				select {
+				case m := <-ch:
+					m.recycle()
+					p.pending.Done()
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// wait waits for all queued datagrams to be handled.
+// It is used by tests.
+func (p *unknownDestPool) wait() {
+	p.pending.Wait()
+}
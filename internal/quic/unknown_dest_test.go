@@ -0,0 +1,43 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUnknownDestPoolDiscardsQueuedOnExit(t *testing.T) {
+	l := &Listener{closec: make(chan struct{})}
+	var pool datagramPool
+	pool.init(64, 8)
+	var p unknownDestPool
+	ch := make(chan *datagram, 4)
+	for i := 0; i < cap(ch); i++ {
+		m := pool.get()
+		// A short header packet, which the worker handles by
+		// doing nothing, since the Listener sends no stateless resets.
+		m.b[0] = headerFormShort | fixedBit
+		p.pending.Add(1)
+		ch <- m
+	}
+	close(l.closec)
+	p.run(l, ch)
+	if got := len(ch); got != 0 {
+		t.Errorf("worker exited with %v datagrams queued, want 0", got)
+	}
+	donec := make(chan struct{})
+	go func() {
+		p.wait()
+		close(donec)
+	}()
+	select {
+	case <-donec:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("wait did not return after worker exited")
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package quic
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"fmt"
+)
+
+// A Version is a QUIC version number.
+type Version uint32
+
+// Supported QUIC versions.
+const (
+	Version1 = Version(quicVersion1) // https://www.rfc-editor.org/rfc/rfc9000
+	Version2 = Version(quicVersion2) // https://www.rfc-editor.org/rfc/rfc9369
+)
+
+func (v Version) String() string {
+	switch v {
+	case Version1:
+		return "v1"
+	case Version2:
+		return "v2"
+	}
+	return fmt.Sprintf("0x%08x", uint32(v))
+}
+
+// versionParams contains the parts of the wire format that vary between QUIC versions.
+type versionParams struct {
+	number uint32
+
+	// Salt used to derive Initial packet protection keys.
+	// https://www.rfc-editor.org/rfc/rfc9001#section-5.2-2
+	initialSalt []byte
+
+	// Labels used to derive packet protection and header protection keys,
+	// and to perform key updates.
+	// https://www.rfc-editor.org/rfc/rfc9001#section-5.1
+	keyLabel, ivLabel, hpLabel, kuLabel string
+
+	// Packet types, indexed by the Long Packet Type bits of the first byte.
+	longPacketTypes [4]packetType
+
+	// AEAD and nonce used to compute the Retry Integrity Tag.
+	// https://www.rfc-editor.org/rfc/rfc9001#section-5.8
+	retryAEAD  cipher.AEAD
+	retryNonce []byte
+}
+
+var version1Params = &versionParams{
+	number:      quicVersion1,
+	initialSalt: []byte{0x38, 0x76, 0x2c, 0xf7, 0xf5, 0x59, 0x34, 0xb3, 0x4d, 0x17, 0x9a, 0xe6, 0xa4, 0xc8, 0x0c, 0xad, 0xcc, 0xbb, 0x7f, 0x0a},
+	keyLabel:    "quic key",
+	ivLabel:     "quic iv",
+	hpLabel:     "quic hp",
+	kuLabel:     "quic ku",
+	// https://www.rfc-editor.org/rfc/rfc9000.html#section-17.2-3.6.1
+	longPacketTypes: [4]packetType{
+		packetTypeInitial,
+		packetType0RTT,
+		packetTypeHandshake,
+		packetTypeRetry,
+	},
+	retryAEAD:  newRetryAEAD([]byte{0xbe, 0x0c, 0x69, 0x0b, 0x9f, 0x66, 0x57, 0x5a, 0x1d, 0x76, 0x6b, 0x54, 0xe3, 0x68, 0xc8, 0x4e}),
+	retryNonce: []byte{0x46, 0x15, 0x99, 0xd3, 0x5d, 0x63, 0x2b, 0xf2, 0x23, 0x98, 0x25, 0xbb},
+}
+
+// https://www.rfc-editor.org/rfc/rfc9369#section-3
+var version2Params = &versionParams{
+	number:      quicVersion2,
+	initialSalt: []byte{0x0d, 0xed, 0xe3, 0xde, 0xf7, 0x00, 0xa6, 0xdb, 0x81, 0x93, 0x81, 0xbe, 0x6e, 0x26, 0x9d, 0xcb, 0xf9, 0xbd, 0x2e, 0xd9},
+	keyLabel:    "quicv2 key",
+	ivLabel:     "quicv2 iv",
+	hpLabel:     "quicv2 hp",
+	kuLabel:     "quicv2 ku",
+	longPacketTypes: [4]packetType{
+		packetTypeRetry,
+		packetTypeInitial,
+		packetType0RTT,
+		packetTypeHandshake,
+	},
+	retryAEAD:  newRetryAEAD([]byte{0x8f, 0xb4, 0xb0, 0x1b, 0x56, 0xac, 0x48, 0xe2, 0x60, 0xfb, 0xcb, 0xce, 0xad, 0x7c, 0xcc, 0x92}),
+	retryNonce: []byte{0xd8, 0x69, 0x69, 0xbc, 0x2d, 0x7c, 0x6d, 0x99, 0x90, 0xef, 0xb0, 0x4a},
+}
+
+func newRetryAEAD(key []byte) cipher.AEAD {
+	c, err := aes.NewCipher(key)
+	if err != nil {
+		panic(err)
+	}
+	aead, err := cipher.NewGCM(c)
+	if err != nil {
+		panic(err)
+	}
+	return aead
+}
+
+// paramsForVersion returns the parameters of a QUIC version,
+// or nil if the version is not supported.
+func paramsForVersion(v uint32) *versionParams {
+	switch v {
+	case quicVersion1:
+		return version1Params
+	case quicVersion2:
+		return version2Params
+	}
+	return nil
+}
+
+// headerParamsForVersion returns the parameters used to read and write
+// long header packets with version v.
+// Packets with an unknown version are treated as version 1 packets;
+// a packet's version is validated separately.
+func headerParamsForVersion(v uint32) *versionParams {
+	if vp := paramsForVersion(v); vp != nil {
+		return vp
+	}
+	return version1Params
+}
+
+// packetType returns the packet type for the Long Packet Type bits in a header byte.
+func (vp *versionParams) packetType(b byte) packetType {
+	return vp.longPacketTypes[(b&0x30)>>4]
+}
+
+// longPacketTypeBits returns the Long Packet Type bits for a packet type.
+func (vp *versionParams) longPacketTypeBits(ptype packetType) byte {
+	for i, t := range vp.longPacketTypes {
+		if t == ptype {
+			return byte(i) << 4
+		}
+	}
+	panic("BUG: not a long header packet type")
+}
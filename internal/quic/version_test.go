@@ -10,6 +10,8 @@ import (
 	"bytes"
 	"context"
 	"crypto/tls"
+	"errors"
+	"slices"
 	"testing"
 )
 
@@ -63,8 +65,12 @@ func TestVersionNegotiationClientAborts(t *testing.T) {
 		b: appendVersionNegotiation(nil, p.srcConnID, p.dstConnID, 10),
 	})
 	tc.wantIdle("connection does not send a CONNECTION_CLOSE")
-	if err := tc.conn.waitReady(canceledContext()); err != errVersionNegotiation {
-		t.Errorf("conn.waitReady() = %v, want errVersionNegotiation", err)
+	var verr *VersionNegotiationError
+	if err := tc.conn.waitReady(canceledContext()); !errors.As(err, &verr) {
+		t.Fatalf("conn.waitReady() = %v, want VersionNegotiationError", err)
+	}
+	if got, want := verr.Versions, []Version{10}; !slices.Equal(got, want) {
+		t.Errorf("VersionNegotiationError.Versions = %v, want %v", got, want)
 	}
 }
 
@@ -108,3 +114,288 @@ func TestVersionNegotiationClientIgnoresMismatchingSourceConnID(t *testing.T) {
 	tc.wantFrameType("conn ignores Version Negotiation and continues with handshake",
 		packetTypeHandshake, debugFrameCrypto{})
 }
+
+func TestVersionNegotiationServerListsConfiguredVersions(t *testing.T) {
+	config := &Config{
+		TLSConfig: newTestTLSConfig(serverSide),
+		Versions:  []Version{Version2},
+	}
+	tl := newTestListener(t, config)
+
+	// An Initial packet for QUIC version 1, which the server has not enabled.
+	dstConnID := []byte{1, 2, 3, 4}
+	srcConnID := []byte{5, 6, 7, 8}
+	pkt := []byte{
+		0b1100_0000,
+		0x00, 0x00, 0x00, 0x01,
+	}
+	pkt = append(pkt, byte(len(dstConnID)))
+	pkt = append(pkt, dstConnID...)
+	pkt = append(pkt, byte(len(srcConnID)))
+	pkt = append(pkt, srcConnID...)
+	for len(pkt) < paddedInitialDatagramSize {
+		pkt = append(pkt, 0)
+	}
+
+	tl.write(&datagram{
+		b: pkt,
+	})
+	gotPkt := tl.read()
+	if gotPkt == nil {
+		t.Fatalf("got no response; want Version Negotiaion")
+	}
+	if got := getPacketType(gotPkt); got != packetTypeVersionNegotiation {
+		t.Fatalf("got packet type %v; want Version Negotiaion", got)
+	}
+	_, _, versions := parseVersionNegotiation(gotPkt)
+	if got, want := versions, []byte{0x6b, 0x33, 0x43, 0xcf}; !bytes.Equal(got, want) {
+		t.Errorf("got Supported Version %x, want %x", got, want)
+	}
+}
+
+func TestVersionNegotiationClientIgnoresListOfOwnVersion(t *testing.T) {
+	tc := newTestConn(t, clientSide, func(c *Config) {
+		c.Versions = []Version{Version2}
+	})
+	p := tc.readPacket() // client Initial packet
+	if got, want := p.version, uint32(quicVersion2); got != want {
+		t.Fatalf("client Initial has version %x, want %x", got, want)
+	}
+	tc.listener.write(&datagram{
+		b: appendVersionNegotiation(nil, p.srcConnID, p.dstConnID, quicVersion1, quicVersion2),
+	})
+	if err := tc.conn.waitReady(canceledContext()); err != context.Canceled {
+		t.Errorf("conn.waitReady() = %v, want context.Canceled", err)
+	}
+}
+
+func TestVersionNegotiationClientChangesVersion(t *testing.T) {
+	tc := newTestConn(t, clientSide, func(c *Config) {
+		c.Versions = []Version{Version1, Version2}
+	})
+	tc.ignoreFrame(frameTypeAck)
+	p := tc.readPacket() // client Initial packet
+	tc.listener.write(&datagram{
+		b: appendVersionNegotiation(nil, p.srcConnID, p.dstConnID, 10, quicVersion2),
+	})
+	tc.useConnInitialKeys()
+	p2 := tc.readPacket()
+	if p2 == nil {
+		t.Fatalf("client sent no packet after Version Negotiation, want Initial")
+	}
+	if got, want := p2.version, uint32(quicVersion2); got != want {
+		t.Fatalf("client Initial after Version Negotiation has version %x, want %x", got, want)
+	}
+	if !bytes.Equal(p2.dstConnID, p.dstConnID) {
+		t.Errorf("client Initial after Version Negotiation has dst conn id %x, want %x", p2.dstConnID, p.dstConnID)
+	}
+	if p2.num <= p.num {
+		t.Errorf("client Initial after Version Negotiation has packet number %v, want > %v", p2.num, p.num)
+	}
+	tc.writeFrames(packetTypeInitial,
+		debugFrameCrypto{
+			data: tc.cryptoDataIn[tls.QUICEncryptionLevelInitial],
+		})
+	tc.writeFrames(packetTypeHandshake,
+		debugFrameCrypto{
+			data: tc.cryptoDataIn[tls.QUICEncryptionLevelHandshake],
+		})
+	tc.wantFrameType("handshake continues with the new version",
+		packetTypeHandshake, debugFrameCrypto{})
+	if got, want := tc.conn.Version(), Version2; got != want {
+		t.Errorf("conn.Version() = %v, want %v", got, want)
+	}
+}
+
+func TestVersionNegotiationClientIgnoresSecondVersionNegotiation(t *testing.T) {
+	tc := newTestConn(t, clientSide, func(c *Config) {
+		c.Versions = []Version{Version1, Version2}
+	})
+	p := tc.readPacket() // client Initial packet
+	tc.listener.write(&datagram{
+		b: appendVersionNegotiation(nil, p.srcConnID, p.dstConnID, quicVersion2),
+	})
+	tc.useConnInitialKeys()
+	tc.readPacket() // client Initial packet with version 2
+	tc.listener.write(&datagram{
+		b: appendVersionNegotiation(nil, p.srcConnID, p.dstConnID, quicVersion1),
+	})
+	if err := tc.conn.waitReady(canceledContext()); err != context.Canceled {
+		t.Errorf("conn.waitReady() = %v, want context.Canceled", err)
+	}
+	if got, want := tc.conn.Version(), Version2; got != want {
+		t.Errorf("conn.Version() = %v, want %v", got, want)
+	}
+}
+
+func TestVersionInformationSent(t *testing.T) {
+	for _, test := range []struct {
+		side          connSide
+		wantAvailable []uint32
+	}{{
+		side:          clientSide,
+		wantAvailable: []uint32{quicVersion1},
+	}, {
+		side:          serverSide,
+		wantAvailable: []uint32{quicVersion1, quicVersion2},
+	}} {
+		t.Run(test.side.String(), func(t *testing.T) {
+			tc := newTestConn(t, test.side, func(c *Config) {
+				c.Versions = []Version{Version1, Version2}
+			})
+			tc.handshake()
+			p := tc.sentTransportParameters
+			if got, want := p.chosenVersion, uint32(quicVersion1); got != want {
+				t.Errorf("version_information Chosen Version = %x, want %x", got, want)
+			}
+			if got, want := p.availableVersions, test.wantAvailable; !slices.Equal(got, want) {
+				t.Errorf("version_information Available Versions = %x, want %x", got, want)
+			}
+		})
+	}
+}
+
+func TestVersionInformationServerChosenVersionMismatch(t *testing.T) {
+	// "[A server] MUST validate that the client's Chosen Version
+	// matches the version in use for the connection."
+	// https://www.rfc-editor.org/rfc/rfc9368#section-4
+	tc := newTestConn(t, serverSide, func(p *transportParameters) {
+		p.chosenVersion = quicVersion2
+		p.availableVersions = []uint32{quicVersion2}
+	})
+	tc.ignoreFrame(frameTypeAck)
+	tc.writeFrames(packetTypeInitial,
+		debugFrameCrypto{
+			data: tc.cryptoDataIn[tls.QUICEncryptionLevelInitial],
+		})
+	tc.wantFrame("client's Chosen Version is not the version in use",
+		packetTypeInitial, debugFrameConnectionCloseTransport{
+			code: errVersionNegotiation,
+		})
+}
+
+func TestVersionNegotiationClientValidatesVersionInformation(t *testing.T) {
+	for _, test := range []struct {
+		name         string
+		serverParams func(p *transportParameters)
+	}{{
+		name: "server omits version_information",
+		serverParams: func(p *transportParameters) {
+			p.chosenVersion = 0
+			p.availableVersions = nil
+		},
+	}, {
+		// The Version Negotiation packet listed only version 2,
+		// but the server supports version 1, which we prefer.
+		name: "client would have chosen a different version",
+		serverParams: func(p *transportParameters) {
+			p.availableVersions = []uint32{quicVersion1, quicVersion2}
+		},
+	}} {
+		t.Run(test.name, func(t *testing.T) {
+			tc := newTestConn(t, clientSide, func(c *Config) {
+				c.Versions = []Version{Version1, Version2}
+			}, test.serverParams)
+			tc.ignoreFrame(frameTypeAck)
+			p := tc.readPacket() // client Initial packet
+			tc.ignoreFrame(frameTypeCrypto)
+			tc.listener.write(&datagram{
+				b: appendVersionNegotiation(nil, p.srcConnID, p.dstConnID, quicVersion2),
+			})
+			tc.useConnInitialKeys()
+			tc.writeFrames(packetTypeInitial,
+				debugFrameCrypto{
+					data: tc.cryptoDataIn[tls.QUICEncryptionLevelInitial],
+				})
+			tc.writeFrames(packetTypeHandshake,
+				debugFrameCrypto{
+					data: tc.cryptoDataIn[tls.QUICEncryptionLevelHandshake],
+				})
+			tc.wantFrame("version negotiation was not confirmed by the server",
+				packetTypeInitial, debugFrameConnectionCloseTransport{
+					code: errVersionNegotiation,
+				})
+		})
+	}
+}
+
+// useConnInitialKeys updates the test's Initial keys
+// after the conn under test changes versions.
+func (tc *testConn) useConnInitialKeys() {
+	tc.keysInitial.r = tc.conn.keysInitial.w
+	tc.keysInitial.w = tc.conn.keysInitial.r
+}
+
+func TestVersion2Handshake(t *testing.T) {
+	for _, side := range []connSide{clientSide, serverSide} {
+		t.Run(side.String(), func(t *testing.T) {
+			tc := newTestConn(t, side, func(c *Config) {
+				c.Versions = []Version{Version2, Version1}
+			})
+			tc.uncheckedHandshake()
+			if got, want := tc.conn.Version(), Version2; got != want {
+				t.Errorf("conn.Version() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestVersionConnectLocal(t *testing.T) {
+	for _, test := range []struct {
+		name        string
+		server      []Version
+		client      []Version
+		wantVersion Version
+	}{{
+		name:        "default",
+		wantVersion: Version1,
+	}, {
+		name:        "v2",
+		server:      []Version{Version1, Version2},
+		client:      []Version{Version2},
+		wantVersion: Version2,
+	}, {
+		name:        "client changes to v2",
+		server:      []Version{Version2},
+		client:      []Version{Version1, Version2},
+		wantVersion: Version2,
+	}, {
+		name:        "client prefers v1",
+		server:      []Version{Version2, Version1},
+		client:      []Version{Version1, Version2},
+		wantVersion: Version1,
+	}} {
+		t.Run(test.name, func(t *testing.T) {
+			cli, srv := newLocalConnPair(t,
+				&Config{Versions: test.server},
+				&Config{Versions: test.client})
+			if got := cli.Version(); got != test.wantVersion {
+				t.Errorf("client conn.Version() = %v, want %v", got, test.wantVersion)
+			}
+			if got := srv.Version(); got != test.wantVersion {
+				t.Errorf("server conn.Version() = %v, want %v", got, test.wantVersion)
+			}
+		})
+	}
+}
+
+func TestVersionConnectLocalNoCommonVersion(t *testing.T) {
+	ctx := context.Background()
+	l1 := newLocalListener(t, serverSide, &Config{Versions: []Version{Version2}})
+	l2 := newLocalListener(t, clientSide, &Config{})
+	_, err := l2.Dial(ctx, "udp", l1.LocalAddr().String())
+	var verr *VersionNegotiationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Dial() = %v, want VersionNegotiationError", err)
+	}
+}
+
+func TestListenUnsupportedVersion(t *testing.T) {
+	_, err := Listen("udp", "127.0.0.1:0", &Config{
+		TLSConfig: newTestTLSConfig(serverSide),
+		Versions:  []Version{0x1a2a3a4a},
+	})
+	if err == nil {
+		t.Fatalf("Listen with unsupported version succeeded, want error")
+	}
+}
@@ -168,44 +168,65 @@ func (m ControlMessage) Marshal(lvl, typ int, data []byte) (ControlMessage, erro
 func (m ControlMessage) Parse() ([]ControlMessage, error) {
 	var ms []ControlMessage
 	for len(m) >= controlHeaderLen() {
-		h := (*cmsghdr)(unsafe.Pointer(&m[0]))
-		l := h.len()
-		if l <= 0 {
-			return nil, errors.New("invalid header length")
-		}
-		if uint64(l) < uint64(controlHeaderLen()) {
-			return nil, errors.New("invalid message length")
-		}
-		if uint64(l) > uint64(len(m)) {
-			return nil, errors.New("short buffer")
-		}
-		// On message reception:
-		//
-		// |<- ControlMessageSpace --------------->|
-		// |<- controlMessageLen ---------->|      |
-		// |<- controlHeaderLen ->|         |      |
-		// +---------------+------+---------+------+
-		// |    Header     | PadH |  Data   | PadD |
-		// +---------------+------+---------+------+
-		//
-		// On compatible message reception:
-		//
-		// | ... |<- controlMessageLen ----------->|
-		// | ... |<- controlHeaderLen ->|          |
-		// +-----+---------------+------+----------+
-		// | ... |    Header     | PadH |   Data   |
-		// +-----+---------------+------+----------+
-		ms = append(ms, ControlMessage(m[:l]))
-		ll := l - controlHeaderLen()
-		if len(m) >= ControlMessageSpace(ll) {
-			m = m[ControlMessageSpace(ll):]
-		} else {
-			m = m[controlMessageLen(ll):]
+		var cm ControlMessage
+		var err error
+		cm, m, err = m.Cut()
+		if err != nil {
+			return nil, err
 		}
+		ms = append(ms, cm)
 	}
 	return ms, nil
 }
 
+// Cut slices m around the control message at its head,
+// returning the head message and the remaining stream of messages.
+// If m does not contain a complete control message header,
+// Cut returns nil messages.
+//
+// Unlike Parse, Cut does not allocate.
+// It works for both standard and compatible messages.
+func (m ControlMessage) Cut() (head, rest ControlMessage, err error) {
+	if len(m) < controlHeaderLen() {
+		return nil, nil, nil
+	}
+	h := (*cmsghdr)(unsafe.Pointer(&m[0]))
+	l := h.len()
+	if l <= 0 {
+		return nil, nil, errors.New("invalid header length")
+	}
+	if uint64(l) < uint64(controlHeaderLen()) {
+		return nil, nil, errors.New("invalid message length")
+	}
+	if uint64(l) > uint64(len(m)) {
+		return nil, nil, errors.New("short buffer")
+	}
+	// On message reception:
+	//
+	// |<- ControlMessageSpace --------------->|
+	// |<- controlMessageLen ---------->|      |
+	// |<- controlHeaderLen ->|         |      |
+	// +---------------+------+---------+------+
+	// |    Header     | PadH |  Data   | PadD |
+	// +---------------+------+---------+------+
+	//
+	// On compatible message reception:
+	//
+	// | ... |<- controlMessageLen ----------->|
+	// | ... |<- controlHeaderLen ->|          |
+	// +-----+---------------+------+----------+
+	// | ... |    Header     | PadH |   Data   |
+	// +-----+---------------+------+----------+
+	head = ControlMessage(m[:l])
+	ll := l - controlHeaderLen()
+	if len(m) >= ControlMessageSpace(ll) {
+		rest = m[ControlMessageSpace(ll):]
+	} else {
+		rest = m[controlMessageLen(ll):]
+	}
+	return head, rest, nil
+}
+
 // NewControlMessage returns a new stream of control messages.
 func NewControlMessage(dataLen []int) ControlMessage {
 	var l int
@@ -18,10 +18,13 @@ var (
 	aLongTimeAgo = time.Unix(1, 0)
 )
 
-func (d *Dialer) connect(ctx context.Context, c net.Conn, address string) (_ net.Addr, ctxErr error) {
+// connect runs the SOCKS handshake on c.
+// It returns the connection to use for the proxied traffic,
+// which is c unless AuthenticateConn replaced it, and the bound address.
+func (d *Dialer) connect(ctx context.Context, c net.Conn, address string) (_ net.Conn, _ net.Addr, ctxErr error) {
 	host, port, err := splitHostPort(address)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	if deadline, ok := ctx.Deadline(); ok && !deadline.IsZero() {
 		c.SetDeadline(deadline)
@@ -49,12 +52,12 @@ func (d *Dialer) connect(ctx context.Context, c net.Conn, address string) (_ net
 
 	b := make([]byte, 0, 6+len(host)) // the size here is just an estimate
 	b = append(b, Version5)
-	if len(d.AuthMethods) == 0 || d.Authenticate == nil {
+	if len(d.AuthMethods) == 0 || d.Authenticate == nil && d.AuthenticateConn == nil {
 		b = append(b, 1, byte(AuthMethodNotRequired))
 	} else {
 		ams := d.AuthMethods
 		if len(ams) > 255 {
-			return nil, errors.New("too many authentication methods")
+			return nil, nil, errors.New("too many authentication methods")
 		}
 		b = append(b, byte(len(ams)))
 		for _, am := range ams {
@@ -69,13 +72,23 @@ func (d *Dialer) connect(ctx context.Context, c net.Conn, address string) (_ net
 		return
 	}
 	if b[0] != Version5 {
-		return nil, errors.New("unexpected protocol version " + strconv.Itoa(int(b[0])))
+		return nil, nil, errors.New("unexpected protocol version " + strconv.Itoa(int(b[0])))
 	}
 	am := AuthMethod(b[1])
 	if am == AuthMethodNoAcceptableMethods {
-		return nil, errors.New("no acceptable authentication methods")
+		return nil, nil, errors.New("no acceptable authentication methods")
 	}
-	if d.Authenticate != nil {
+	// rw is the connection used after authentication.
+	// c is not reassigned, since the context watcher uses it concurrently.
+	rw := c
+	if d.AuthenticateConn != nil {
+		if rw, ctxErr = d.AuthenticateConn(ctx, c, am); ctxErr != nil {
+			return
+		}
+		if rw == nil {
+			return nil, nil, errors.New("authentication returned a nil connection")
+		}
+	} else if d.Authenticate != nil {
 		if ctxErr = d.Authenticate(ctx, c, am); ctxErr != nil {
 			return
 		}
@@ -91,32 +104,32 @@ func (d *Dialer) connect(ctx context.Context, c net.Conn, address string) (_ net
 			b = append(b, AddrTypeIPv6)
 			b = append(b, ip6...)
 		} else {
-			return nil, errors.New("unknown address type")
+			return nil, nil, errors.New("unknown address type")
 		}
 	} else {
 		if len(host) > 255 {
-			return nil, errors.New("FQDN too long")
+			return nil, nil, errors.New("FQDN too long")
 		}
 		b = append(b, AddrTypeFQDN)
 		b = append(b, byte(len(host)))
 		b = append(b, host...)
 	}
 	b = append(b, byte(port>>8), byte(port))
-	if _, ctxErr = c.Write(b); ctxErr != nil {
+	if _, ctxErr = rw.Write(b); ctxErr != nil {
 		return
 	}
 
-	if _, ctxErr = io.ReadFull(c, b[:4]); ctxErr != nil {
+	if _, ctxErr = io.ReadFull(rw, b[:4]); ctxErr != nil {
 		return
 	}
 	if b[0] != Version5 {
-		return nil, errors.New("unexpected protocol version " + strconv.Itoa(int(b[0])))
+		return nil, nil, errors.New("unexpected protocol version " + strconv.Itoa(int(b[0])))
 	}
 	if cmdErr := Reply(b[1]); cmdErr != StatusSucceeded {
-		return nil, errors.New("unknown error " + cmdErr.String())
+		return nil, nil, errors.New("unknown error " + cmdErr.String())
 	}
 	if b[2] != 0 {
-		return nil, errors.New("non-zero reserved field")
+		return nil, nil, errors.New("non-zero reserved field")
 	}
 	l := 2
 	var a Addr
@@ -128,19 +141,19 @@ func (d *Dialer) connect(ctx context.Context, c net.Conn, address string) (_ net
 		l += net.IPv6len
 		a.IP = make(net.IP, net.IPv6len)
 	case AddrTypeFQDN:
-		if _, err := io.ReadFull(c, b[:1]); err != nil {
-			return nil, err
+		if _, err := io.ReadFull(rw, b[:1]); err != nil {
+			return nil, nil, err
 		}
 		l += int(b[0])
 	default:
-		return nil, errors.New("unknown address type " + strconv.Itoa(int(b[3])))
+		return nil, nil, errors.New("unknown address type " + strconv.Itoa(int(b[3])))
 	}
 	if cap(b) < l {
 		b = make([]byte, l)
 	} else {
 		b = b[:l]
 	}
-	if _, ctxErr = io.ReadFull(c, b); ctxErr != nil {
+	if _, ctxErr = io.ReadFull(rw, b); ctxErr != nil {
 		return
 	}
 	if a.IP != nil {
@@ -149,7 +162,7 @@ func (d *Dialer) connect(ctx context.Context, c net.Conn, address string) (_ net
 		a.Name = string(b[:len(b)-2])
 	}
 	a.Port = int(b[len(b)-2])<<8 | int(b[len(b)-1])
-	return &a, nil
+	return rw, &a, nil
 }
 
 func splitHostPort(address string) (string, int, error) {
@@ -74,6 +74,7 @@ const (
 	cmdBind    Command = 0x02 // establishes a passive-open forward proxy connection
 
 	AuthMethodNotRequired         AuthMethod = 0x00 // no authentication required
+	AuthMethodGSSAPI              AuthMethod = 0x01 // use GSSAPI
 	AuthMethodUsernamePassword    AuthMethod = 0x02 // use username/password
 	AuthMethodNoAcceptableMethods AuthMethod = 0xff // no acceptable authentication methods
 
@@ -136,6 +137,15 @@ type Dialer struct {
 	// function. It must be non-nil when AuthMethods is not empty.
 	// It must return an error when the authentication is failed.
 	Authenticate func(context.Context, io.ReadWriter, AuthMethod) error
+
+	// AuthenticateConn specifies the optional authentication
+	// function for methods which encapsulate the subsequent
+	// traffic, such as GSSAPI with message protection.
+	// It returns the connection to use after authentication,
+	// which may wrap the connection to the proxy server.
+	// If non-nil, it is used in place of Authenticate.
+	// DialWithConn fails if it returns a different connection.
+	AuthenticateConn func(context.Context, net.Conn, AuthMethod) (net.Conn, error)
 }
 
 // DialContext connects to the provided address on the provided
@@ -169,13 +179,13 @@ func (d *Dialer) DialContext(ctx context.Context, network, address string) (net.
 		proxy, dst, _ := d.pathAddrs(address)
 		return nil, &net.OpError{Op: d.cmd.String(), Net: network, Source: proxy, Addr: dst, Err: err}
 	}
-	a, err := d.connect(ctx, c, address)
+	rw, a, err := d.connect(ctx, c, address)
 	if err != nil {
 		c.Close()
 		proxy, dst, _ := d.pathAddrs(address)
 		return nil, &net.OpError{Op: d.cmd.String(), Net: network, Source: proxy, Addr: dst, Err: err}
 	}
-	return &Conn{Conn: c, boundAddr: a}, nil
+	return &Conn{Conn: rw, boundAddr: a}, nil
 }
 
 // DialWithConn initiates a connection from SOCKS server to the target
@@ -193,7 +203,10 @@ func (d *Dialer) DialWithConn(ctx context.Context, c net.Conn, network, address
 		proxy, dst, _ := d.pathAddrs(address)
 		return nil, &net.OpError{Op: d.cmd.String(), Net: network, Source: proxy, Addr: dst, Err: errors.New("nil context")}
 	}
-	a, err := d.connect(ctx, c, address)
+	rw, a, err := d.connect(ctx, c, address)
+	if err == nil && rw != c {
+		err = errors.New("authentication method encapsulates the connection")
+	}
 	if err != nil {
 		proxy, dst, _ := d.pathAddrs(address)
 		return nil, &net.OpError{Op: d.cmd.String(), Net: network, Source: proxy, Addr: dst, Err: err}
@@ -203,8 +216,9 @@ func (d *Dialer) DialWithConn(ctx context.Context, c net.Conn, network, address
 
 // Dial connects to the provided address on the provided network.
 //
-// Unlike DialContext, it returns a raw transport connection instead
-// of a forward proxy connection.
+// Unlike DialContext, it returns a raw transport connection, or the
+// connection returned by AuthenticateConn, instead of a forward proxy
+// connection.
 //
 // Deprecated: Use DialContext or DialWithConn instead.
 func (d *Dialer) Dial(network, address string) (net.Conn, error) {
@@ -223,11 +237,13 @@ func (d *Dialer) Dial(network, address string) (net.Conn, error) {
 		proxy, dst, _ := d.pathAddrs(address)
 		return nil, &net.OpError{Op: d.cmd.String(), Net: network, Source: proxy, Addr: dst, Err: err}
 	}
-	if _, err := d.DialWithConn(context.Background(), c, network, address); err != nil {
+	rw, _, err := d.connect(context.Background(), c, address)
+	if err != nil {
 		c.Close()
-		return nil, err
+		proxy, dst, _ := d.pathAddrs(address)
+		return nil, &net.OpError{Op: d.cmd.String(), Net: network, Source: proxy, Addr: dst, Err: err}
 	}
-	return c, nil
+	return rw, nil
 }
 
 func (d *Dialer) validateTarget(network, address string) error {
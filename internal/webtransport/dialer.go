@@ -0,0 +1,93 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package webtransport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"golang.org/x/net/internal/http3"
+)
+
+// A Dialer establishes WebTransport sessions with HTTP/3 servers.
+//
+// The Dialer must be installed as the Extension of its Transport.
+type Dialer struct {
+	sessionSet
+
+	// Transport is the HTTP/3 transport used to send session requests.
+	Transport *http3.Transport
+}
+
+var _ http3.Extension = (*Dialer)(nil)
+
+// Dial establishes a session with the server at the "https" URL urlStr.
+// The header h, which may be nil, is sent in the session request.
+//
+// The context bounds the time spent establishing the session.
+// Once Dial returns, canceling the context has no effect on the session.
+//
+// If the server rejects the session, Dial returns the server's response
+// and an error.
+func (d *Dialer) Dial(ctx context.Context, urlStr string, h http.Header) (*http.Response, *Session, error) {
+	if d.Transport == nil || d.Transport.Extension != d {
+		return nil, nil, errors.New("webtransport: Dialer is not the Extension of its Transport")
+	}
+	reqctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(ctx, cancel)
+	defer stop()
+
+	pr, pw := io.Pipe()
+	req, err := http.NewRequestWithContext(reqctx, http.MethodConnect, urlStr, pr)
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+	for k, vv := range h {
+		req.Header[k] = vv
+	}
+	req.Header.Set(":protocol", protocol)
+	resp, err := d.Transport.RoundTrip(req)
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		resp.Body.Close()
+		pw.Close()
+		cancel()
+		return resp, nil, fmt.Errorf("webtransport: server rejected session: %v", resp.Status)
+	}
+	qconn, id, ok := http3.ResponseStream(resp)
+	if !ok {
+		resp.Body.Close()
+		pw.Close()
+		cancel()
+		return resp, nil, errors.New("webtransport: response has no stream")
+	}
+	if !stop() {
+		// The context expired during the request.
+		resp.Body.Close()
+		pw.Close()
+		return resp, nil, ctx.Err()
+	}
+	s := newSession(qconn, id, &d.sessionSet)
+	s.r = resp.Body
+	s.closeRead = func() {
+		resp.Body.Close()
+		cancel()
+	}
+	s.w = pw
+	s.flush = func() {}
+	s.closeWrite = func() { pw.Close() }
+	d.add(s)
+	s.start()
+	return resp, s, nil
+}
@@ -0,0 +1,76 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package webtransport
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"golang.org/x/net/internal/http3"
+)
+
+// A Server accepts WebTransport sessions from HTTP/3 clients.
+//
+// The Server must be installed as the Extension of the http3.Server
+// which receives session requests. Handlers call Upgrade to establish a session.
+//
+// The zero value for Server is a valid server.
+type Server struct {
+	sessionSet
+}
+
+var _ http3.Extension = (*Server)(nil)
+
+// Settings implements http3.Extension.
+// The server advertises support for extended CONNECT, HTTP Datagrams, and WebTransport.
+func (srv *Server) Settings() []http3.Setting {
+	return append([]http3.Setting{
+		{ID: http3.SettingEnableConnectProtocol, Value: 1},
+	}, settings()...)
+}
+
+// IsSessionRequest reports whether r is a request to establish a WebTransport session.
+func IsSessionRequest(r *http.Request) bool {
+	return r.Method == http.MethodConnect && r.Header.Get(":protocol") == protocol
+}
+
+// Upgrade responds to a request to establish a WebTransport session,
+// accepting the session.
+// Response headers set on w before calling Upgrade are sent to the client.
+//
+// The session ends when the handler returns.
+// Handlers should wait for the session to end, for example by
+// receiving from the session's Done channel.
+func (srv *Server) Upgrade(w http.ResponseWriter, r *http.Request) (*Session, error) {
+	if !IsSessionRequest(r) {
+		return nil, errors.New("webtransport: request is not a session request")
+	}
+	qconn, id, ok := http3.RequestStream(r)
+	if !ok {
+		return nil, errors.New("webtransport: request was not received by an http3.Server")
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, errors.New("webtransport: ResponseWriter does not support flushing")
+	}
+	s := newSession(qconn, id, &srv.sessionSet)
+	s.r = r.Body
+	s.closeRead = func() { r.Body.Close() }
+	s.w = w
+	s.flush = flusher.Flush
+	// The CONNECT stream is closed when the handler returns.
+	s.closeWrite = func() {}
+	srv.add(s)
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	s.start()
+	context.AfterFunc(r.Context(), func() {
+		s.terminate(errSessionClosed)
+	})
+	return s, nil
+}
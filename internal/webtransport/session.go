@@ -0,0 +1,521 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package webtransport
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"golang.org/x/net/internal/capsule"
+	"golang.org/x/net/internal/http3"
+	"golang.org/x/net/internal/quic"
+	"golang.org/x/net/internal/quic/quicwire"
+)
+
+// A Session is a WebTransport session.
+//
+// Methods of a Session may be called concurrently.
+type Session struct {
+	qconn *quic.Conn
+	id    int64 // stream ID of the CONNECT stream
+	set   *sessionSet
+
+	r          io.Reader // capsules from the peer
+	closeRead  func()
+	w          io.Writer // capsules to the peer
+	flush      func()
+	closeWrite func()
+
+	bidi      chan *Stream
+	uni       chan *Stream
+	datagrams chan []byte
+	drainc    chan struct{}
+	donec     chan struct{}
+
+	wmu sync.Mutex // serializes writes to w
+
+	mu       sync.Mutex
+	err      error // set before donec is closed
+	drained  bool
+	sentDone bool
+	streams  map[*Stream]struct{}
+}
+
+// Sizes of the queues of incoming streams and datagrams.
+// When a queue is full, further streams are rejected
+// and further datagrams are dropped.
+const (
+	acceptQueueLen   = 64
+	datagramQueueLen = 64
+)
+
+// errSessionClosed is returned by operations on a session closed locally.
+var errSessionClosed = errors.New("webtransport: session closed")
+
+func newSession(qconn *quic.Conn, id int64, set *sessionSet) *Session {
+	return &Session{
+		qconn:     qconn,
+		id:        id,
+		set:       set,
+		bidi:      make(chan *Stream, acceptQueueLen),
+		uni:       make(chan *Stream, acceptQueueLen),
+		datagrams: make(chan []byte, datagramQueueLen),
+		drainc:    make(chan struct{}),
+		donec:     make(chan struct{}),
+		streams:   make(map[*Stream]struct{}),
+	}
+}
+
+// start begins reading capsules from the CONNECT stream.
+func (s *Session) start() {
+	go s.readCapsules()
+}
+
+// readCapsules reads capsules sent by the peer on the CONNECT stream,
+// until the stream ends.
+// https://www.ietf.org/archive/id/draft-ietf-webtrans-http3-09.html#section-5
+func (s *Session) readCapsules() {
+	r := bufio.NewReader(s.r)
+	for {
+		typ, value, err := capsule.Read(r, maxCapsuleLen)
+		if err != nil {
+			// "Cleanly terminating a CONNECT stream without a WT_CLOSE_SESSION capsule
+			// SHALL be semantically equivalent to terminating it with a
+			// WT_CLOSE_SESSION capsule that has an error code of 0 and an empty
+			// error string."
+			s.terminate(&SessionError{})
+			return
+		}
+		switch typ {
+		case capsuleCloseSession:
+			if len(value) < 4 || len(value)-4 > maxCloseMessageLen {
+				s.terminate(&SessionError{})
+				return
+			}
+			s.terminate(&SessionError{
+				Code:    binary.BigEndian.Uint32(value),
+				Message: string(value[4:]),
+			})
+			return
+		case capsuleDrainSession:
+			s.mu.Lock()
+			if !s.drained {
+				s.drained = true
+				close(s.drainc)
+			}
+			s.mu.Unlock()
+		default:
+			// Unknown capsule types are ignored.
+			// https://www.rfc-editor.org/rfc/rfc9297#section-3.2-12
+		}
+	}
+}
+
+// Done returns a channel which is closed when the session ends.
+func (s *Session) Done() <-chan struct{} {
+	return s.donec
+}
+
+// Draining returns a channel which is closed when the peer asks
+// for the session to be gracefully closed.
+func (s *Session) Draining() <-chan struct{} {
+	return s.drainc
+}
+
+// Err returns the reason the session ended,
+// or nil if it has not ended.
+// A session closed by the peer returns a *SessionError.
+func (s *Session) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Close closes the session with an error code of 0.
+func (s *Session) Close() error {
+	return s.CloseWithError(0, "")
+}
+
+// CloseWithError closes the session, sending the peer an application error code
+// and message. Messages longer than 1024 bytes are truncated.
+//
+// All streams in the session are reset.
+func (s *Session) CloseWithError(code uint32, message string) error {
+	if len(message) > maxCloseMessageLen {
+		message = message[:maxCloseMessageLen]
+	}
+	value := binary.BigEndian.AppendUint32(nil, code)
+	value = append(value, message...)
+	s.mu.Lock()
+	if s.sentDone {
+		s.mu.Unlock()
+		return nil
+	}
+	s.sentDone = true
+	s.mu.Unlock()
+	err := s.writeCapsule(capsuleCloseSession, value)
+	s.terminate(errSessionClosed)
+	return err
+}
+
+// Drain asks the peer to gracefully close the session.
+func (s *Session) Drain() error {
+	return s.writeCapsule(capsuleDrainSession, nil)
+}
+
+func (s *Session) writeCapsule(typ uint64, value []byte) error {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+	if _, err := s.w.Write(capsule.Append(nil, typ, value)); err != nil {
+		return err
+	}
+	s.flush()
+	return nil
+}
+
+// terminate ends the session, resetting all of its streams.
+func (s *Session) terminate(err error) {
+	s.mu.Lock()
+	if s.err != nil {
+		s.mu.Unlock()
+		return
+	}
+	s.err = err
+	s.sentDone = true
+	streams := s.streams
+	s.streams = nil
+	s.mu.Unlock()
+
+	s.set.remove(s)
+	close(s.donec)
+	for st := range streams {
+		st.abort(errSessionGone)
+	}
+	s.wmu.Lock()
+	s.closeWrite()
+	s.wmu.Unlock()
+	s.closeRead()
+}
+
+// trackStream records st as belonging to the session.
+// It reports false if the session has ended.
+func (s *Session) trackStream(st *Stream) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.streams == nil {
+		return false
+	}
+	s.streams[st] = struct{}{}
+	return true
+}
+
+func (s *Session) untrackStream(st *Stream) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.streams, st)
+}
+
+// OpenStream opens a new bidirectional stream in the session.
+func (s *Session) OpenStream(ctx context.Context) (*Stream, error) {
+	qs, err := s.qconn.NewStream(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.openStream(qs, signalBidi)
+}
+
+// OpenUniStream opens a new unidirectional stream in the session.
+func (s *Session) OpenUniStream(ctx context.Context) (*Stream, error) {
+	qs, err := s.qconn.NewSendOnlyStream(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.openStream(qs, streamTypeUni)
+}
+
+// openStream writes the header of a stream we created.
+// https://www.ietf.org/archive/id/draft-ietf-webtrans-http3-09.html#section-4.2
+func (s *Session) openStream(qs *quic.Stream, typ uint64) (*Stream, error) {
+	st := &Stream{qs: qs, s: s}
+	if !s.trackStream(st) {
+		st.abort(errSessionGone)
+		return nil, s.Err()
+	}
+	b := quicwire.AppendVarint(nil, typ)
+	b = quicwire.AppendVarint(b, uint64(s.id))
+	if _, err := qs.Write(b); err != nil {
+		st.abort(errSessionGone)
+		s.untrackStream(st)
+		return nil, err
+	}
+	return st, nil
+}
+
+// AcceptStream waits for and returns the next bidirectional stream
+// opened by the peer in the session.
+func (s *Session) AcceptStream(ctx context.Context) (*Stream, error) {
+	return s.accept(ctx, s.bidi)
+}
+
+// AcceptUniStream waits for and returns the next unidirectional stream
+// opened by the peer in the session.
+func (s *Session) AcceptUniStream(ctx context.Context) (*Stream, error) {
+	return s.accept(ctx, s.uni)
+}
+
+func (s *Session) accept(ctx context.Context, c chan *Stream) (*Stream, error) {
+	select {
+	case st := <-c:
+		return st, nil
+	case <-s.donec:
+		return nil, s.Err()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// handleStream is called with a stream opened by the peer in the session,
+// after its header has been read.
+func (s *Session) handleStream(qs *quic.Stream) {
+	st := &Stream{qs: qs, s: s}
+	if !s.trackStream(st) {
+		st.abort(errSessionGone)
+		return
+	}
+	c := s.bidi
+	if qs.IsReadOnly() {
+		c = s.uni
+	}
+	select {
+	case c <- st:
+	default:
+		s.untrackStream(st)
+		st.abort(errBufferedStreamRejected)
+	}
+}
+
+// SendDatagram sends an unreliable datagram in the session.
+// The datagram must fit in a single QUIC packet.
+func (s *Session) SendDatagram(b []byte) error {
+	select {
+	case <-s.donec:
+		return s.Err()
+	default:
+	}
+	return http3.SendDatagram(s.qconn, s.id, b)
+}
+
+// ReceiveDatagram waits for and returns the next datagram received in the session.
+func (s *Session) ReceiveDatagram(ctx context.Context) ([]byte, error) {
+	select {
+	case b := <-s.datagrams:
+		return b, nil
+	case <-s.donec:
+		return nil, s.Err()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// handleDatagram is called with a datagram received in the session.
+func (s *Session) handleDatagram(b []byte) {
+	select {
+	case s.datagrams <- b:
+	default:
+		// The queue is full; drop the datagram.
+	}
+}
+
+// A Stream is a stream in a WebTransport session.
+//
+// Errors returned by reads and writes on a stream terminated by the peer
+// with a WebTransport error code are *StreamErrors.
+type Stream struct {
+	qs *quic.Stream
+	s  *Session
+}
+
+// Session returns the session the stream belongs to.
+func (st *Stream) Session() *Session {
+	return st.s
+}
+
+// Read reads data from the stream.
+func (st *Stream) Read(b []byte) (int, error) {
+	n, err := st.qs.Read(b)
+	return n, streamError(err)
+}
+
+// Write writes data to the stream.
+func (st *Stream) Write(b []byte) (int, error) {
+	n, err := st.qs.Write(b)
+	return n, streamError(err)
+}
+
+// Close closes the stream.
+// It waits for the peer to acknowledge data written to the stream.
+func (st *Stream) Close() error {
+	defer st.s.untrackStream(st)
+	return streamError(st.qs.Close())
+}
+
+// CloseWrite closes the write side of the stream,
+// after sending any data written to it.
+func (st *Stream) CloseWrite() {
+	st.qs.CloseWrite()
+}
+
+// Reset aborts writes on the stream,
+// sending the peer a WebTransport application error code.
+func (st *Stream) Reset(code uint32) {
+	st.qs.Reset(httpErrorCode(code))
+}
+
+// StopSending aborts reads on the stream,
+// asking the peer to stop sending with a WebTransport application error code.
+func (st *Stream) StopSending(code uint32) {
+	st.qs.StopSending(httpErrorCode(code))
+}
+
+// abort terminates both directions of the stream with an HTTP/3 error code.
+func (st *Stream) abort(code uint64) {
+	if !st.qs.IsReadOnly() {
+		st.qs.Reset(code)
+	}
+	if !st.qs.IsWriteOnly() {
+		st.qs.StopSending(code)
+	}
+}
+
+// streamError converts a QUIC stream error containing a WebTransport
+// error code into a *StreamError.
+func streamError(err error) error {
+	var code quic.StreamErrorCode
+	if !errors.As(err, &code) {
+		return err
+	}
+	if c, ok := webtransportErrorCode(uint64(code)); ok {
+		return &StreamError{Code: c}
+	}
+	return err
+}
+
+// A sessionKey identifies a session.
+type sessionKey struct {
+	qconn *quic.Conn
+	id    int64
+}
+
+// A sessionSet is the set of sessions known to a Server or Dialer.
+// It implements http3.Extension.
+type sessionSet struct {
+	mu       sync.Mutex
+	sessions map[sessionKey]*Session
+	changed  chan struct{} // closed when a session is added
+}
+
+// bufferedStreamTimeout is how long a stream which arrives before
+// its session is established waits for the session.
+// https://www.ietf.org/archive/id/draft-ietf-webtrans-http3-09.html#section-4.6
+const bufferedStreamTimeout = 5 * time.Second
+
+func (set *sessionSet) add(s *Session) {
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	if set.sessions == nil {
+		set.sessions = make(map[sessionKey]*Session)
+	}
+	set.sessions[sessionKey{s.qconn, s.id}] = s
+	if set.changed != nil {
+		close(set.changed)
+		set.changed = nil
+	}
+}
+
+func (set *sessionSet) remove(s *Session) {
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	k := sessionKey{s.qconn, s.id}
+	if set.sessions[k] == s {
+		delete(set.sessions, k)
+	}
+}
+
+// lookup returns the session identified by k,
+// or nil if there is none.
+func (set *sessionSet) lookup(k sessionKey) *Session {
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	return set.sessions[k]
+}
+
+// wait waits for the session identified by k to be established,
+// and returns it.
+// It returns nil if the session is not established before ctx is done.
+func (set *sessionSet) wait(ctx context.Context, k sessionKey) *Session {
+	for {
+		set.mu.Lock()
+		s := set.sessions[k]
+		if s != nil {
+			set.mu.Unlock()
+			return s
+		}
+		if set.changed == nil {
+			set.changed = make(chan struct{})
+		}
+		changed := set.changed
+		set.mu.Unlock()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// Settings implements http3.Extension.
+func (set *sessionSet) Settings() []http3.Setting {
+	return settings()
+}
+
+// HandleStream implements http3.Extension.
+func (set *sessionSet) HandleStream(qconn *quic.Conn, typ int64, qs *quic.Stream) bool {
+	switch {
+	case qs.IsReadOnly() && typ == streamTypeUni:
+	case !qs.IsReadOnly() && typ == signalBidi:
+	default:
+		return false
+	}
+	if _, err := quicwire.ReadVarint(qs); err != nil { // stream type or signal value
+		return true
+	}
+	id, err := quicwire.ReadVarint(qs)
+	if err != nil {
+		return true
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), bufferedStreamTimeout)
+	defer cancel()
+	s := set.wait(ctx, sessionKey{qconn, int64(id)})
+	if s == nil {
+		(&Stream{qs: qs}).abort(errBufferedStreamRejected)
+		return true
+	}
+	s.handleStream(qs)
+	return true
+}
+
+// HandleDatagram implements http3.Extension.
+func (set *sessionSet) HandleDatagram(qconn *quic.Conn, streamID int64, payload []byte) {
+	if s := set.lookup(sessionKey{qconn, streamID}); s != nil {
+		s.handleDatagram(payload)
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+// Package webtransport implements WebTransport over HTTP/3.
+//
+// A WebTransport session is established by an extended CONNECT request.
+// Within a session, either endpoint may open bidirectional and unidirectional
+// streams, and send unreliable datagrams.
+//
+// A Server upgrades requests received by an http3.Server to sessions,
+// and a Dialer establishes sessions with a server.
+//
+// https://datatracker.ietf.org/doc/draft-ietf-webtrans-http3/
+package webtransport
+
+import (
+	"fmt"
+
+	"golang.org/x/net/internal/http3"
+)
+
+// protocol is the value of the :protocol pseudo-header field
+// in a request to establish a session.
+const protocol = "webtransport"
+
+// Stream types and signal values.
+// https://www.ietf.org/archive/id/draft-ietf-webtrans-http3-09.html#section-9.2
+const (
+	// streamTypeUni is the type of a unidirectional WebTransport stream.
+	streamTypeUni = 0x54
+
+	// signalBidi is the signal value which begins a bidirectional WebTransport stream,
+	// in place of a frame type.
+	signalBidi = 0x41
+)
+
+// Settings.
+// https://www.ietf.org/archive/id/draft-ietf-webtrans-http3-09.html#section-9.3
+const settingMaxSessions = 0xc671706a
+
+// Capsule types.
+// https://www.ietf.org/archive/id/draft-ietf-webtrans-http3-09.html#section-9.6
+const (
+	capsuleCloseSession = 0x2843
+	capsuleDrainSession = 0x78ae
+)
+
+// maxCloseMessageLen is the maximum length of the message in a WT_CLOSE_SESSION capsule.
+// https://www.ietf.org/archive/id/draft-ietf-webtrans-http3-09.html#section-5-6
+const maxCloseMessageLen = 1024
+
+// maxCapsuleLen is the largest capsule we will read.
+// Capsules we understand are small; larger capsules of unknown type are discarded.
+const maxCapsuleLen = 4096
+
+// HTTP/3 error codes.
+// https://www.ietf.org/archive/id/draft-ietf-webtrans-http3-09.html#section-9.5
+const (
+	errBufferedStreamRejected = 0x3994bd84
+	errSessionGone            = 0x170d7b68
+)
+
+// WebTransport application error codes are mapped into a range of HTTP/3 error codes.
+// https://www.ietf.org/archive/id/draft-ietf-webtrans-http3-09.html#section-4.3
+const (
+	firstErrorCode = 0x52e4a40fa8db
+	lastErrorCode  = 0x52e5ac983162
+)
+
+// httpErrorCode returns the HTTP/3 error code for a WebTransport application error code.
+func httpErrorCode(code uint32) uint64 {
+	// The range skips over the reserved codepoints 0x1f * N + 0x21.
+	return firstErrorCode + uint64(code) + uint64(code)/0x1e
+}
+
+// webtransportErrorCode returns the WebTransport application error code
+// for an HTTP/3 error code.
+// It reports false if the HTTP/3 code is not in the WebTransport range.
+func webtransportErrorCode(code uint64) (uint32, bool) {
+	if code < firstErrorCode || code > lastErrorCode {
+		return 0, false
+	}
+	if (code-0x21)%0x1f == 0 {
+		return 0, false // reserved codepoint
+	}
+	shifted := code - firstErrorCode
+	return uint32(shifted - shifted/0x1f), true
+}
+
+// A StreamError is returned by operations on a stream which the peer
+// reset or stopped with a WebTransport application error code.
+type StreamError struct {
+	Code uint32
+}
+
+func (e *StreamError) Error() string {
+	return fmt.Sprintf("webtransport: stream terminated by peer with error code %v", e.Code)
+}
+
+// A SessionError is returned by operations on a session
+// which the peer closed with a WT_CLOSE_SESSION capsule.
+type SessionError struct {
+	Code    uint32
+	Message string
+}
+
+func (e *SessionError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("webtransport: session closed by peer with error code %v", e.Code)
+	}
+	return fmt.Sprintf("webtransport: session closed by peer with error code %v: %q", e.Code, e.Message)
+}
+
+// settings are the HTTP/3 settings sent by both clients and servers.
+func settings() []http3.Setting {
+	return []http3.Setting{
+		{ID: http3.SettingH3Datagram, Value: 1},
+		{ID: settingMaxSessions, Value: 1},
+	}
+}
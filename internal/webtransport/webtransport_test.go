@@ -0,0 +1,219 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package webtransport
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"golang.org/x/net/internal/http3"
+	"golang.org/x/net/internal/quic"
+	"golang.org/x/net/internal/quic/quictest"
+)
+
+// newTestSession starts a server which runs f for each session,
+// and returns a client session established with it.
+func newTestSession(t *testing.T, f func(*Session)) *Session {
+	t.Helper()
+	l, err := quic.Listen("udp", "127.0.0.1:0", &quic.Config{
+		TLSConfig: &tls.Config{
+			MinVersion:   tls.VersionTLS13,
+			Certificates: []tls.Certificate{quictest.LocalhostCertificate(t)},
+			NextProtos:   []string{"h3"},
+		},
+		MaxDatagramFrameSize: 1200,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wts := &Server{}
+	srv := &http3.Server{
+		Extension: wts,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			s, err := wts.Upgrade(w, r)
+			if err != nil {
+				t.Errorf("Upgrade: %v", err)
+				return
+			}
+			f(s)
+		}),
+	}
+	go srv.Serve(l)
+	t.Cleanup(func() {
+		l.Close(context.Background())
+	})
+
+	tr := &http3.Transport{
+		Config: &quic.Config{
+			TLSConfig: &tls.Config{
+				MinVersion:         tls.VersionTLS13,
+				InsecureSkipVerify: true,
+			},
+			MaxDatagramFrameSize: 1200,
+		},
+	}
+	d := &Dialer{Transport: tr}
+	tr.Extension = d
+	t.Cleanup(tr.CloseIdleConnections)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, s, err := d.Dial(ctx, "https://"+l.LocalAddr().String()+"/wt", nil)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestSessionStreams(t *testing.T) {
+	s := newTestSession(t, func(s *Session) {
+		ctx := context.Background()
+		// Echo the first bidirectional stream.
+		st, err := s.AcceptStream(ctx)
+		if err != nil {
+			t.Errorf("server AcceptStream: %v", err)
+			return
+		}
+		io.Copy(st, st)
+		st.Close()
+		// Reply to the unidirectional stream on a new one.
+		ust, err := s.AcceptUniStream(ctx)
+		if err != nil {
+			t.Errorf("server AcceptUniStream: %v", err)
+			return
+		}
+		b, _ := io.ReadAll(ust)
+		rst, err := s.OpenUniStream(ctx)
+		if err != nil {
+			t.Errorf("server OpenUniStream: %v", err)
+			return
+		}
+		rst.Write(b)
+		rst.Close()
+		<-s.Done()
+	})
+	ctx := quictest.Context(t)
+
+	st, err := s.OpenStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st.Write([]byte("bidi"))
+	st.CloseWrite()
+	if b, err := io.ReadAll(st); err != nil || string(b) != "bidi" {
+		t.Errorf("echoed bidirectional stream: %q, %v; want %q", b, err, "bidi")
+	}
+
+	ust, err := s.OpenUniStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ust.Write([]byte("uni"))
+	ust.Close()
+	rst, err := s.AcceptUniStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, err := io.ReadAll(rst); err != nil || string(b) != "uni" {
+		t.Errorf("reply unidirectional stream: %q, %v; want %q", b, err, "uni")
+	}
+}
+
+func TestSessionDatagrams(t *testing.T) {
+	s := newTestSession(t, func(s *Session) {
+		for {
+			b, err := s.ReceiveDatagram(context.Background())
+			if err != nil {
+				return
+			}
+			s.SendDatagram(b)
+		}
+	})
+	ctx := quictest.Context(t)
+	// Datagrams are unreliable, so resend until we get a reply.
+	for {
+		if err := s.SendDatagram([]byte("ping")); err != nil {
+			t.Fatal(err)
+		}
+		rctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+		b, err := s.ReceiveDatagram(rctx)
+		cancel()
+		if err == nil {
+			if string(b) != "ping" {
+				t.Errorf("ReceiveDatagram = %q, want %q", b, "ping")
+			}
+			return
+		}
+		if ctx.Err() != nil {
+			t.Fatal("timed out waiting for datagram")
+		}
+	}
+}
+
+func TestSessionCloseWithError(t *testing.T) {
+	s := newTestSession(t, func(s *Session) {
+		s.CloseWithError(42, "goodbye")
+	})
+	select {
+	case <-s.Done():
+	case <-quictest.Context(t).Done():
+		t.Fatal("timed out waiting for session to close")
+	}
+	want := &SessionError{Code: 42, Message: "goodbye"}
+	var got *SessionError
+	if err := s.Err(); !errors.As(err, &got) || *got != *want {
+		t.Errorf("Err() = %v, want %v", err, want)
+	}
+	if _, err := s.OpenStream(quictest.Context(t)); err == nil {
+		t.Errorf("OpenStream on closed session succeeded, want error")
+	}
+}
+
+func TestStreamErrorCode(t *testing.T) {
+	s := newTestSession(t, func(s *Session) {
+		st, err := s.AcceptStream(context.Background())
+		if err != nil {
+			return
+		}
+		st.Reset(7)
+		<-s.Done()
+	})
+	ctx := quictest.Context(t)
+	st, err := s.OpenStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st.Write([]byte("x"))
+	_, err = io.ReadAll(st)
+	var serr *StreamError
+	if !errors.As(err, &serr) || serr.Code != 7 {
+		t.Errorf("read from reset stream: %v, want StreamError{7}", err)
+	}
+}
+
+func TestErrorCodeMapping(t *testing.T) {
+	for _, code := range []uint32{0, 1, 0x1d, 0x1e, 0x1f, 1000, 1<<32 - 1} {
+		h := httpErrorCode(code)
+		if h < firstErrorCode || h > lastErrorCode {
+			t.Errorf("httpErrorCode(%v) = %#x, outside WebTransport range", code, h)
+		}
+		if (h-0x21)%0x1f == 0 {
+			t.Errorf("httpErrorCode(%v) = %#x, a reserved codepoint", code, h)
+		}
+		if got, ok := webtransportErrorCode(h); !ok || got != code {
+			t.Errorf("webtransportErrorCode(httpErrorCode(%v)) = %v, %v; want %v, true", code, got, ok, code)
+		}
+	}
+	if _, ok := webtransportErrorCode(firstErrorCode - 1); ok {
+		t.Errorf("webtransportErrorCode(firstErrorCode-1) = ok, want !ok")
+	}
+}
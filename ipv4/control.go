@@ -29,6 +29,7 @@ const (
 	FlagSrc                                // pass the source address on the received packet
 	FlagDst                                // pass the destination address on the received packet
 	FlagInterface                          // pass the interface index on the received packet
+	FlagTOS                                // pass the type-of-service on the received packet
 )
 
 // A ControlMessage represents per packet basis IP-level socket options.
@@ -45,6 +46,7 @@ type ControlMessage struct {
 	Src     net.IP // source address, specifying only
 	Dst     net.IP // destination address, receiving only
 	IfIndex int    // interface index, must be 1 <= value when specifying
+	TOS     int    // type-of-service, receiving only
 }
 
 func (cm *ControlMessage) String() string {
@@ -56,27 +58,46 @@ func (cm *ControlMessage) String() string {
 
 // Marshal returns the binary encoding of cm.
 func (cm *ControlMessage) Marshal() []byte {
+	return cm.AppendMarshal(nil)
+}
+
+// AppendMarshal appends the binary encoding of cm to b
+// and returns the extended buffer.
+// It does not allocate if b has sufficient capacity.
+//
+// Control messages must be suitably aligned,
+// so b should be empty or end on a control message boundary.
+func (cm *ControlMessage) AppendMarshal(b []byte) []byte {
 	if cm == nil {
-		return nil
+		return b
 	}
-	var m socket.ControlMessage
 	if ctlOpts[ctlPacketInfo].name > 0 && (cm.Src.To4() != nil || cm.IfIndex > 0) {
-		m = socket.NewControlMessage([]int{ctlOpts[ctlPacketInfo].length})
+		off := len(b)
+		b = append(b, make([]byte, socket.ControlMessageSpace(ctlOpts[ctlPacketInfo].length))...)
+		ctlOpts[ctlPacketInfo].marshal(b[off:], cm)
 	}
-	if len(m) > 0 {
-		ctlOpts[ctlPacketInfo].marshal(m, cm)
-	}
-	return m
+	return b
 }
 
 // Parse parses b as a control message and stores the result in cm.
+//
+// Parse does not allocate, except to store an address in cm.Dst
+// when cm.Dst is too short to hold it.
+// Reusing the same ControlMessage to parse many messages
+// avoids per-message garbage.
 func (cm *ControlMessage) Parse(b []byte) error {
-	ms, err := socket.ControlMessage(b).Parse()
-	if err != nil {
-		return err
-	}
-	for _, m := range ms {
-		lvl, typ, l, err := m.ParseHeader()
+	m := socket.ControlMessage(b)
+	for len(m) > 0 {
+		var cur socket.ControlMessage
+		var err error
+		cur, m, err = m.Cut()
+		if err != nil {
+			return err
+		}
+		if cur == nil {
+			break
+		}
+		lvl, typ, l, err := cur.ParseHeader()
 		if err != nil {
 			return err
 		}
@@ -85,13 +106,15 @@ func (cm *ControlMessage) Parse(b []byte) error {
 		}
 		switch {
 		case typ == ctlOpts[ctlTTL].name && l >= ctlOpts[ctlTTL].length:
-			ctlOpts[ctlTTL].parse(cm, m.Data(l))
+			ctlOpts[ctlTTL].parse(cm, cur.Data(l))
 		case typ == ctlOpts[ctlDst].name && l >= ctlOpts[ctlDst].length:
-			ctlOpts[ctlDst].parse(cm, m.Data(l))
+			ctlOpts[ctlDst].parse(cm, cur.Data(l))
 		case typ == ctlOpts[ctlInterface].name && l >= ctlOpts[ctlInterface].length:
-			ctlOpts[ctlInterface].parse(cm, m.Data(l))
+			ctlOpts[ctlInterface].parse(cm, cur.Data(l))
 		case typ == ctlOpts[ctlPacketInfo].name && l >= ctlOpts[ctlPacketInfo].length:
-			ctlOpts[ctlPacketInfo].parse(cm, m.Data(l))
+			ctlOpts[ctlPacketInfo].parse(cm, cur.Data(l))
+		case ctlOpts[ctlTOS].name > 0 && typ == ctlOpts[ctlTOS].name && l >= ctlOpts[ctlTOS].length:
+			ctlOpts[ctlTOS].parse(cm, cur.Data(l))
 		}
 	}
 	return nil
@@ -106,6 +129,9 @@ func NewControlMessage(cf ControlFlags) []byte {
 	if opt.isset(FlagTTL) && ctlOpts[ctlTTL].name > 0 {
 		l += socket.ControlMessageSpace(ctlOpts[ctlTTL].length)
 	}
+	if opt.isset(FlagTOS) && ctlOpts[ctlTOS].name > 0 {
+		l += socket.ControlMessageSpace(ctlOpts[ctlTOS].length)
+	}
 	if ctlOpts[ctlPacketInfo].name > 0 {
 		if opt.isset(FlagSrc | FlagDst | FlagInterface) {
 			l += socket.ControlMessageSpace(ctlOpts[ctlPacketInfo].length)
@@ -132,6 +158,7 @@ const (
 	ctlDst               // header field
 	ctlInterface         // inbound or outbound interface
 	ctlPacketInfo        // inbound or outbound packet path
+	ctlTOS               // header field
 	ctlMax
 )
 
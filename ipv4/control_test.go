@@ -5,6 +5,9 @@
 package ipv4_test
 
 import (
+	"bytes"
+	"net"
+	"runtime"
 	"testing"
 
 	"golang.org/x/net/ipv4"
@@ -19,3 +22,58 @@ func TestControlMessageParseWithFuzz(t *testing.T) {
 		cm.Parse([]byte(fuzz))
 	}
 }
+
+func TestControlMessageParseAllocs(t *testing.T) {
+	b := (&ipv4.ControlMessage{Src: net.IPv4(127, 0, 0, 1), IfIndex: 1}).Marshal()
+	if len(b) == 0 {
+		t.Skip("no marshalable control messages on " + runtime.GOOS)
+	}
+	cm := ipv4.ControlMessage{Dst: make(net.IP, net.IPv4len)}
+	if raceEnabled {
+		t.Skip("skipping allocation check with the race detector enabled")
+	}
+	if n := testing.AllocsPerRun(100, func() {
+		if err := cm.Parse(b); err != nil {
+			t.Fatal(err)
+		}
+	}); n != 0 {
+		t.Errorf("Parse: %v allocs, want 0", n)
+	}
+}
+
+func TestControlMessageAppendMarshal(t *testing.T) {
+	cm := &ipv4.ControlMessage{Src: net.IPv4(127, 0, 0, 1), IfIndex: 1}
+	want := cm.Marshal()
+	buf := make([]byte, 0, 256)
+	if got := cm.AppendMarshal(buf); !bytes.Equal(got, want) {
+		t.Errorf("AppendMarshal = %x, want %x", got, want)
+	}
+	if raceEnabled {
+		t.Skip("skipping allocation check with the race detector enabled")
+	}
+	if n := testing.AllocsPerRun(100, func() {
+		cm.AppendMarshal(buf)
+	}); n != 0 {
+		t.Errorf("AppendMarshal: %v allocs, want 0", n)
+	}
+}
+
+func BenchmarkControlMessageParse(b *testing.B) {
+	m := (&ipv4.ControlMessage{Src: net.IPv4(127, 0, 0, 1), IfIndex: 1}).Marshal()
+	var cm ipv4.ControlMessage
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := cm.Parse(m); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkControlMessageAppendMarshal(b *testing.B) {
+	cm := &ipv4.ControlMessage{Src: net.IPv4(127, 0, 0, 1), IfIndex: 1}
+	buf := make([]byte, 0, 256)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf = cm.AppendMarshal(buf[:0])
+	}
+}
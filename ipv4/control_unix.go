@@ -28,6 +28,16 @@ func setControlMessage(c *socket.Conn, opt *rawOpt, cf ControlFlags, on bool) er
 			opt.clear(FlagTTL)
 		}
 	}
+	if so, ok := sockOpts[ssoReceiveTOS]; ok && cf&FlagTOS != 0 {
+		if err := so.SetInt(c, boolint(on)); err != nil {
+			return err
+		}
+		if on {
+			opt.set(FlagTOS)
+		} else {
+			opt.clear(FlagTOS)
+		}
+	}
 	if so, ok := sockOpts[ssoPacketInfo]; ok {
 		if cf&(FlagSrc|FlagDst|FlagInterface) != 0 {
 			if err := so.SetInt(c, boolint(on)); err != nil {
@@ -73,3 +83,7 @@ func marshalTTL(b []byte, cm *ControlMessage) []byte {
 func parseTTL(cm *ControlMessage, b []byte) {
 	cm.TTL = int(*(*byte)(unsafe.Pointer(&b[:1][0])))
 }
+
+func parseTOS(cm *ControlMessage, b []byte) {
+	cm.TOS = int(b[0])
+}
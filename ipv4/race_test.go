@@ -0,0 +1,11 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build race
+
+package ipv4_test
+
+// raceEnabled reports whether the race detector is enabled.
+// The race detector adds allocations, so allocation counts are not checked.
+const raceEnabled = true
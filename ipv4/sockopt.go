@@ -27,6 +27,7 @@ const (
 	ssoBlockSourceGroup          // any-source or source-specific multicast
 	ssoUnblockSourceGroup        // any-source or source-specific multicast
 	ssoAttachFilter              // attach BPF for filtering inbound traffic
+	ssoReceiveTOS                // header field on received packet
 )
 
 // Sticky socket option value types
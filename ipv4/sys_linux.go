@@ -19,6 +19,7 @@ var (
 	ctlOpts = [ctlMax]ctlOpt{
 		ctlTTL:        {unix.IP_TTL, 1, marshalTTL, parseTTL},
 		ctlPacketInfo: {unix.IP_PKTINFO, sizeofInetPktinfo, marshalPacketInfo, parsePacketInfo},
+		ctlTOS:        {unix.IP_TOS, 1, nil, parseTOS},
 	}
 
 	sockOpts = map[int]*sockOpt{
@@ -28,6 +29,7 @@ var (
 		ssoMulticastInterface: {Option: socket.Option{Level: iana.ProtocolIP, Name: unix.IP_MULTICAST_IF, Len: unix.SizeofIPMreqn}, typ: ssoTypeIPMreqn},
 		ssoMulticastLoopback:  {Option: socket.Option{Level: iana.ProtocolIP, Name: unix.IP_MULTICAST_LOOP, Len: 4}},
 		ssoReceiveTTL:         {Option: socket.Option{Level: iana.ProtocolIP, Name: unix.IP_RECVTTL, Len: 4}},
+		ssoReceiveTOS:         {Option: socket.Option{Level: iana.ProtocolIP, Name: unix.IP_RECVTOS, Len: 4}},
 		ssoPacketInfo:         {Option: socket.Option{Level: iana.ProtocolIP, Name: unix.IP_PKTINFO, Len: 4}},
 		ssoHeaderPrepend:      {Option: socket.Option{Level: iana.ProtocolIP, Name: unix.IP_HDRINCL, Len: 4}},
 		ssoICMPFilter:         {Option: socket.Option{Level: iana.ProtocolReserved, Name: unix.ICMP_FILTER, Len: sizeofICMPFilter}},
@@ -77,6 +77,46 @@ func TestPacketConnReadWriteUnicastUDP(t *testing.T) {
 	}
 }
 
+func TestPacketConnReadTOS(t *testing.T) {
+	switch runtime.GOOS {
+	case "darwin", "freebsd", "linux":
+	default:
+		t.Skipf("not supported on %s", runtime.GOOS)
+	}
+	if _, err := nettest.RoutedInterface("ip4", net.FlagUp|net.FlagLoopback); err != nil {
+		t.Skipf("not available on %s", runtime.GOOS)
+	}
+
+	c, err := nettest.NewLocalPacketListener("udp4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	p := ipv4.NewPacketConn(c)
+	defer p.Close()
+	if err := p.SetControlMessage(ipv4.FlagTOS, true); err != nil {
+		t.Fatal(err)
+	}
+	const tos = 0x2a<<2 | 0b01 // DSCP 42, ECT(1)
+	if err := p.SetTOS(tos); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.WriteTo([]byte("HELLO"), nil, c.LocalAddr()); err != nil {
+		t.Fatal(err)
+	}
+	rb := make([]byte, 128)
+	_, cm, _, err := p.ReadFrom(rb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cm == nil {
+		t.Fatal("ReadFrom returned no control message")
+	}
+	if cm.TOS != tos {
+		t.Errorf("received TOS %#x, want %#x", cm.TOS, tos)
+	}
+}
+
 func TestPacketConnReadWriteUnicastICMP(t *testing.T) {
 	if !nettest.SupportsRawSocket() {
 		t.Skipf("not supported on %s/%s", runtime.GOOS, runtime.GOARCH)
@@ -71,8 +71,18 @@ func (cm *ControlMessage) String() string {
 
 // Marshal returns the binary encoding of cm.
 func (cm *ControlMessage) Marshal() []byte {
+	return cm.AppendMarshal(nil)
+}
+
+// AppendMarshal appends the binary encoding of cm to b
+// and returns the extended buffer.
+// It does not allocate if b has sufficient capacity.
+//
+// Control messages must be suitably aligned,
+// so b should be empty or end on a control message boundary.
+func (cm *ControlMessage) AppendMarshal(b []byte) []byte {
 	if cm == nil {
-		return nil
+		return b
 	}
 	var l int
 	tclass := false
@@ -95,10 +105,10 @@ func (cm *ControlMessage) Marshal() []byte {
 		nexthop = true
 		l += socket.ControlMessageSpace(ctlOpts[ctlNextHop].length)
 	}
-	var b []byte
 	if l > 0 {
-		b = make([]byte, l)
-		bb := b
+		off := len(b)
+		b = append(b, make([]byte, l)...)
+		bb := b[off:]
 		if tclass {
 			bb = ctlOpts[ctlTrafficClass].marshal(bb, cm)
 		}
@@ -116,13 +126,24 @@ func (cm *ControlMessage) Marshal() []byte {
 }
 
 // Parse parses b as a control message and stores the result in cm.
+//
+// Parse does not allocate, except to store an address in cm.Dst
+// when cm.Dst is too short to hold it.
+// Reusing the same ControlMessage to parse many messages
+// avoids per-message garbage.
 func (cm *ControlMessage) Parse(b []byte) error {
-	ms, err := socket.ControlMessage(b).Parse()
-	if err != nil {
-		return err
-	}
-	for _, m := range ms {
-		lvl, typ, l, err := m.ParseHeader()
+	m := socket.ControlMessage(b)
+	for len(m) > 0 {
+		var cur socket.ControlMessage
+		var err error
+		cur, m, err = m.Cut()
+		if err != nil {
+			return err
+		}
+		if cur == nil {
+			break
+		}
+		lvl, typ, l, err := cur.ParseHeader()
 		if err != nil {
 			return err
 		}
@@ -131,13 +152,13 @@ func (cm *ControlMessage) Parse(b []byte) error {
 		}
 		switch {
 		case typ == ctlOpts[ctlTrafficClass].name && l >= ctlOpts[ctlTrafficClass].length:
-			ctlOpts[ctlTrafficClass].parse(cm, m.Data(l))
+			ctlOpts[ctlTrafficClass].parse(cm, cur.Data(l))
 		case typ == ctlOpts[ctlHopLimit].name && l >= ctlOpts[ctlHopLimit].length:
-			ctlOpts[ctlHopLimit].parse(cm, m.Data(l))
+			ctlOpts[ctlHopLimit].parse(cm, cur.Data(l))
 		case typ == ctlOpts[ctlPacketInfo].name && l >= ctlOpts[ctlPacketInfo].length:
-			ctlOpts[ctlPacketInfo].parse(cm, m.Data(l))
+			ctlOpts[ctlPacketInfo].parse(cm, cur.Data(l))
 		case typ == ctlOpts[ctlPathMTU].name && l >= ctlOpts[ctlPathMTU].length:
-			ctlOpts[ctlPathMTU].parse(cm, m.Data(l))
+			ctlOpts[ctlPathMTU].parse(cm, cur.Data(l))
 		}
 	}
 	return nil
@@ -5,6 +5,9 @@
 package ipv6_test
 
 import (
+	"bytes"
+	"net"
+	"runtime"
 	"testing"
 
 	"golang.org/x/net/ipv6"
@@ -19,3 +22,58 @@ func TestControlMessageParseWithFuzz(t *testing.T) {
 		cm.Parse([]byte(fuzz))
 	}
 }
+
+func TestControlMessageParseAllocs(t *testing.T) {
+	b := (&ipv6.ControlMessage{Src: net.IPv6loopback, IfIndex: 1}).Marshal()
+	if len(b) == 0 {
+		t.Skip("no marshalable control messages on " + runtime.GOOS)
+	}
+	cm := ipv6.ControlMessage{Dst: make(net.IP, net.IPv6len)}
+	if raceEnabled {
+		t.Skip("skipping allocation check with the race detector enabled")
+	}
+	if n := testing.AllocsPerRun(100, func() {
+		if err := cm.Parse(b); err != nil {
+			t.Fatal(err)
+		}
+	}); n != 0 {
+		t.Errorf("Parse: %v allocs, want 0", n)
+	}
+}
+
+func TestControlMessageAppendMarshal(t *testing.T) {
+	cm := &ipv6.ControlMessage{Src: net.IPv6loopback, IfIndex: 1}
+	want := cm.Marshal()
+	buf := make([]byte, 0, 256)
+	if got := cm.AppendMarshal(buf); !bytes.Equal(got, want) {
+		t.Errorf("AppendMarshal = %x, want %x", got, want)
+	}
+	if raceEnabled {
+		t.Skip("skipping allocation check with the race detector enabled")
+	}
+	if n := testing.AllocsPerRun(100, func() {
+		cm.AppendMarshal(buf)
+	}); n != 0 {
+		t.Errorf("AppendMarshal: %v allocs, want 0", n)
+	}
+}
+
+func BenchmarkControlMessageParse(b *testing.B) {
+	m := (&ipv6.ControlMessage{Src: net.IPv6loopback, IfIndex: 1}).Marshal()
+	var cm ipv6.ControlMessage
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := cm.Parse(m); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkControlMessageAppendMarshal(b *testing.B) {
+	cm := &ipv6.ControlMessage{Src: net.IPv6loopback, IfIndex: 1}
+	buf := make([]byte, 0, 256)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf = cm.AppendMarshal(buf[:0])
+	}
+}
@@ -6,6 +6,7 @@ package ipv6
 
 import (
 	"net"
+	"sync"
 
 	"golang.org/x/net/bpf"
 )
@@ -269,23 +270,75 @@ func (c *dgramOpt) ICMPFilter() (*ICMPFilter, error) {
 	}
 	so, ok := sockOpts[ssoICMPFilter]
 	if !ok {
-		return nil, errNotImplemented
+		if c.icmpFilter == nil {
+			return nil, errNotImplemented
+		}
+		return c.icmpFilter.get(), nil
 	}
 	return so.getICMPFilter(c.Conn)
 }
 
 // SetICMPFilter deploys the ICMP filter.
+//
+// On platforms where the kernel does not support ICMPv6 filters,
+// the filter is emulated by the ReadFrom method of PacketConn.
+// The connection must be an ICMPv6 raw socket.
 func (c *dgramOpt) SetICMPFilter(f *ICMPFilter) error {
 	if !c.ok() {
 		return errInvalidConn
 	}
 	so, ok := sockOpts[ssoICMPFilter]
 	if !ok {
-		return errNotImplemented
+		if c.icmpFilter == nil {
+			return errNotImplemented
+		}
+		c.icmpFilter.set(f)
+		return nil
 	}
 	return so.setICMPFilter(c.Conn, f)
 }
 
+// An icmpFilterEmulator holds an ICMP filter applied in the read path,
+// for platforms where the kernel does not support ICMPv6 filters.
+type icmpFilterEmulator struct {
+	mu sync.RWMutex
+	f  *ICMPFilter // nil if no filter is set
+}
+
+func (e *icmpFilterEmulator) get() *ICMPFilter {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	var f ICMPFilter
+	if e.f == nil {
+		// No filter passes all ICMP types.
+		f.SetAll(false)
+	} else {
+		f = *e.f
+	}
+	return &f
+}
+
+func (e *icmpFilterEmulator) set(f *ICMPFilter) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if f == nil {
+		e.f = nil
+		return
+	}
+	e.f = new(ICMPFilter)
+	*e.f = *f
+}
+
+// willBlock reports whether the ICMP message b is blocked by the filter.
+func (e *icmpFilterEmulator) willBlock(b []byte) bool {
+	if e == nil || len(b) < 1 {
+		return false
+	}
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.f != nil && e.f.WillBlock(ICMPType(b[0]))
+}
+
 // SetBPF attaches a BPF program to the connection.
 //
 // Only supported on Linux.
@@ -67,6 +67,7 @@ type PacketConn struct {
 
 type dgramOpt struct {
 	*socket.Conn
+	icmpFilter *icmpFilterEmulator
 }
 
 func (c *dgramOpt) ok() bool { return c != nil && c.Conn != nil }
@@ -119,9 +120,10 @@ func (c *PacketConn) Close() error {
 // transport.
 func NewPacketConn(c net.PacketConn) *PacketConn {
 	cc, _ := socket.NewConn(c.(net.Conn))
+	f := new(icmpFilterEmulator)
 	return &PacketConn{
 		genericOpt:     genericOpt{Conn: cc},
-		dgramOpt:       dgramOpt{Conn: cc},
-		payloadHandler: payloadHandler{PacketConn: c, Conn: cc},
+		dgramOpt:       dgramOpt{Conn: cc, icmpFilter: f},
+		payloadHandler: payloadHandler{PacketConn: c, Conn: cc, icmpFilter: f},
 	}
 }
@@ -6,9 +6,6 @@ package ipv6
 
 import "golang.org/x/net/internal/iana"
 
-// BUG(mikio): On Windows, methods related to ICMPFilter are not
-// implemented.
-
 // An ICMPType represents a type of ICMP message.
 type ICMPType int
 
@@ -33,6 +30,10 @@ func (typ ICMPType) Protocol() int {
 // device that implements IP. A router means a node that forwards IP
 // packets not explicitly addressed to itself, and a host means a node
 // that is not a router.
+//
+// On platforms where the kernel does not support ICMPv6 filters, such as
+// Windows, the filter is applied by the ReadFrom method of PacketConn,
+// which discards received messages of blocked types.
 type ICMPFilter struct {
 	icmpv6Filter
 }
@@ -2,22 +2,34 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
-//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris && !windows && !zos
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris && !zos
 
 package ipv6
 
+// On platforms without a kernel ICMPv6 filter, the filter uses the
+// same representation as RFC 3542: a set bit passes the ICMP type.
 type icmpv6Filter struct {
+	Filt [8]uint32
 }
 
 func (f *icmpv6Filter) accept(typ ICMPType) {
+	f.Filt[typ>>5] |= 1 << (uint32(typ) & 31)
 }
 
 func (f *icmpv6Filter) block(typ ICMPType) {
+	f.Filt[typ>>5] &^= 1 << (uint32(typ) & 31)
 }
 
 func (f *icmpv6Filter) setAll(block bool) {
+	for i := range f.Filt {
+		if block {
+			f.Filt[i] = 0
+		} else {
+			f.Filt[i] = 1<<32 - 1
+		}
+	}
 }
 
 func (f *icmpv6Filter) willBlock(typ ICMPType) bool {
-	return false
+	return f.Filt[typ>>5]&(1<<(uint32(typ)&31)) == 0
 }
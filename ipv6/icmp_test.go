@@ -34,11 +34,6 @@ func TestICMPString(t *testing.T) {
 }
 
 func TestICMPFilter(t *testing.T) {
-	switch runtime.GOOS {
-	case "fuchsia", "hurd", "js", "nacl", "plan9", "wasip1", "windows":
-		t.Skipf("not supported on %s", runtime.GOOS)
-	}
-
 	var f ipv6.ICMPFilter
 	for _, toggle := range []bool{false, true} {
 		f.SetAll(toggle)
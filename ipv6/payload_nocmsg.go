@@ -16,10 +16,17 @@ func (c *payloadHandler) ReadFrom(b []byte) (n int, cm *ControlMessage, src net.
 	if !c.ok() {
 		return 0, nil, nil, errInvalidConn
 	}
-	if n, src, err = c.PacketConn.ReadFrom(b); err != nil {
-		return 0, nil, nil, err
+	for {
+		if n, src, err = c.PacketConn.ReadFrom(b); err != nil {
+			return 0, nil, nil, err
+		}
+		if _, ok := c.PacketConn.(*net.IPConn); ok && c.icmpFilter.willBlock(b[:n]) {
+			// The kernel does not filter ICMP messages on this
+			// platform, so discard blocked messages here.
+			continue
+		}
+		return
 	}
-	return
 }
 
 // WriteTo writes a payload of the IPv6 datagram, to the destination
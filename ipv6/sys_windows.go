@@ -40,10 +40,6 @@ type ipv6Mtuinfo struct {
 	Mtu  uint32
 }
 
-type icmpv6Filter struct {
-	// TODO(mikio): implement this
-}
-
 var (
 	ctlOpts = [ctlMax]ctlOpt{}
 
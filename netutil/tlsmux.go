@@ -0,0 +1,463 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package netutil
+
+import (
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/cryptobyte"
+)
+
+// A ClientHello contains the routing information in a TLS ClientHello message.
+type ClientHello struct {
+	// ServerName is the server name requested with the
+	// Server Name Indication extension, or "" if none was sent.
+	ServerName string
+
+	// ALPNProtocols are the application protocols offered with the
+	// Application-Layer Protocol Negotiation extension, in preference order.
+	ALPNProtocols []string
+}
+
+// A TLSMux routes the connections accepted from a Listener to other Listeners,
+// based on the server name and application protocols in each connection's
+// TLS ClientHello. TLS is not terminated: connections returned by the
+// routed Listeners are the original connections, with the ClientHello
+// still unread, so they may be passed to tls.Server or forwarded to a backend.
+//
+// Routes are registered with Match, MatchServerName, MatchALPN, and Default,
+// and must be registered before Serve is called.
+// Each connection is routed to the first registered route that matches it.
+type TLSMux struct {
+	// HelloTimeout is the maximum time to wait for a connection's ClientHello.
+	// A connection which sends nothing in this time is closed.
+	// If zero, a default of 10 seconds is used.
+	HelloTimeout time.Duration
+
+	// AcceptQueueSize is the number of routed connections
+	// each route's Listener holds until they are accepted.
+	// If zero, a default of 128 is used.
+	// It must be set before Serve or any route's Accept is called.
+	AcceptQueueSize int
+
+	// AcceptTimeout is the maximum time a routed connection waits
+	// for room in its route's queue. A connection which cannot be queued
+	// in this time, such as one routed to a Listener which is never
+	// accepted from, is closed.
+	// If zero, a default of 10 seconds is used.
+	// If negative, connections are closed as soon as the queue is full.
+	AcceptTimeout time.Duration
+
+	l         net.Listener
+	routes    []*muxListener
+	def       *muxListener
+	closeOnce sync.Once
+	done      chan struct{} // closed when Close is called
+}
+
+const (
+	defaultHelloTimeout    = 10 * time.Second
+	defaultAcceptQueueSize = 128
+	defaultAcceptTimeout   = 10 * time.Second
+)
+
+// maxClientHelloSize is the largest ClientHello a TLSMux will read.
+// A TLS handshake message is at most 2^24 bytes,
+// but real ClientHellos are far smaller.
+const maxClientHelloSize = 64 << 10
+
+// NewTLSMux returns a TLSMux which routes connections accepted from l.
+func NewTLSMux(l net.Listener) *TLSMux {
+	return &TLSMux{
+		l:    l,
+		done: make(chan struct{}),
+	}
+}
+
+// Match returns a Listener which accepts connections for which f returns true.
+func (m *TLSMux) Match(f func(*ClientHello) bool) net.Listener {
+	ml := m.newListener(f)
+	m.routes = append(m.routes, ml)
+	return ml
+}
+
+// MatchServerName returns a Listener which accepts connections requesting
+// the given server name. Names are compared without regard to case.
+// A pattern beginning with "*." matches a single label in that position:
+// "*.example.com" matches "www.example.com", but not "example.com"
+// or "a.b.example.com".
+func (m *TLSMux) MatchServerName(pattern string) net.Listener {
+	return m.Match(func(hello *ClientHello) bool {
+		return matchServerName(pattern, hello.ServerName)
+	})
+}
+
+// MatchALPN returns a Listener which accepts connections offering
+// any of the given application protocols.
+func (m *TLSMux) MatchALPN(protocols ...string) net.Listener {
+	return m.Match(func(hello *ClientHello) bool {
+		for _, p := range hello.ALPNProtocols {
+			for _, want := range protocols {
+				if p == want {
+					return true
+				}
+			}
+		}
+		return false
+	})
+}
+
+// Default returns a Listener which accepts connections that match no other route,
+// including connections which do not begin with a TLS ClientHello.
+// If Default is not called, such connections are closed.
+func (m *TLSMux) Default() net.Listener {
+	if m.def == nil {
+		m.def = m.newListener(nil)
+	}
+	return m.def
+}
+
+func (m *TLSMux) newListener(match func(*ClientHello) bool) *muxListener {
+	return &muxListener{
+		m:      m,
+		match:  match,
+		closed: make(chan struct{}),
+	}
+}
+
+// Serve accepts connections from the underlying Listener and routes them,
+// until the Listener returns an error.
+// Serve always returns a non-nil error. After Close, the error is net.ErrClosed.
+func (m *TLSMux) Serve() error {
+	for {
+		c, err := m.l.Accept()
+		if err != nil {
+			select {
+			case <-m.done:
+				return net.ErrClosed
+			default:
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				continue
+			}
+			return err
+		}
+		go m.route(c)
+	}
+}
+
+// Close closes the underlying Listener and all routed Listeners.
+// Connections waiting to be accepted are closed.
+func (m *TLSMux) Close() error {
+	m.closeOnce.Do(func() { close(m.done) })
+	err := m.l.Close()
+	for _, ml := range m.routes {
+		ml.drain()
+	}
+	if m.def != nil {
+		m.def.drain()
+	}
+	return err
+}
+
+// route reads the ClientHello from c and passes c to the matching Listener.
+func (m *TLSMux) route(c net.Conn) {
+	timeout := m.HelloTimeout
+	if timeout <= 0 {
+		timeout = defaultHelloTimeout
+	}
+	c.SetReadDeadline(time.Now().Add(timeout))
+	hello, raw, err := readClientHello(c)
+	if err != nil && len(raw) == 0 {
+		// The connection was closed or timed out before sending anything.
+		c.Close()
+		return
+	}
+	c.SetReadDeadline(time.Time{})
+	pc := &peekedConn{Conn: c, peeked: raw}
+	ml := m.def
+	if err == nil {
+		for _, r := range m.routes {
+			if r.match(hello) {
+				ml = r
+				break
+			}
+		}
+	}
+	if ml == nil || !ml.deliver(pc) {
+		c.Close()
+	}
+}
+
+// A muxListener is a Listener for one TLSMux route.
+type muxListener struct {
+	m         *TLSMux
+	match     func(*ClientHello) bool
+	queueOnce sync.Once
+	connc     chan net.Conn // created by queue
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// queue returns the route's queue of connections waiting to be accepted.
+// It is created when first used, so the TLSMux's AcceptQueueSize
+// may be set after the route is registered.
+func (l *muxListener) queue() chan net.Conn {
+	l.queueOnce.Do(func() {
+		size := l.m.AcceptQueueSize
+		if size <= 0 {
+			size = defaultAcceptQueueSize
+		}
+		l.connc = make(chan net.Conn, size)
+	})
+	return l.connc
+}
+
+// deliver queues c for a caller of Accept.
+// It reports false if the listener is closed,
+// or if the queue remains full for the TLSMux's AcceptTimeout.
+func (l *muxListener) deliver(c net.Conn) bool {
+	connc := l.queue()
+	select {
+	case <-l.closed:
+		return false
+	case <-l.m.done:
+		return false
+	case connc <- c:
+	default:
+		timeout := l.m.AcceptTimeout
+		if timeout == 0 {
+			timeout = defaultAcceptTimeout
+		}
+		if timeout < 0 {
+			return false
+		}
+		t := time.NewTimer(timeout)
+		defer t.Stop()
+		select {
+		case connc <- c:
+		case <-t.C:
+			return false
+		case <-l.closed:
+			return false
+		case <-l.m.done:
+			return false
+		}
+	}
+	// The listener may have been closed while c was being queued,
+	// after it drained the queue.
+	select {
+	case <-l.closed:
+		l.drain()
+	case <-l.m.done:
+		l.drain()
+	default:
+	}
+	return true
+}
+
+func (l *muxListener) Accept() (net.Conn, error) {
+	connc := l.queue()
+	select {
+	case <-l.closed:
+		return nil, net.ErrClosed
+	case <-l.m.done:
+		return nil, net.ErrClosed
+	default:
+	}
+	select {
+	case c := <-connc:
+		return c, nil
+	case <-l.closed:
+	case <-l.m.done:
+	}
+	return nil, net.ErrClosed
+}
+
+// Close closes the route's Listener.
+// Connections waiting to be accepted, and connections later routed to it,
+// are closed. The underlying Listener remains open.
+func (l *muxListener) Close() error {
+	l.closeOnce.Do(func() { close(l.closed) })
+	l.drain()
+	return nil
+}
+
+// drain closes the connections in the queue.
+func (l *muxListener) drain() {
+	connc := l.queue()
+	for {
+		select {
+		case c := <-connc:
+			c.Close()
+		default:
+			return
+		}
+	}
+}
+
+func (l *muxListener) Addr() net.Addr {
+	return l.m.l.Addr()
+}
+
+// A peekedConn is a net.Conn which returns previously read data
+// before reading from the underlying connection.
+type peekedConn struct {
+	net.Conn
+	peeked []byte
+}
+
+func (c *peekedConn) Read(b []byte) (int, error) {
+	if len(c.peeked) > 0 {
+		n := copy(b, c.peeked)
+		c.peeked = c.peeked[n:]
+		return n, nil
+	}
+	return c.Conn.Read(b)
+}
+
+var errNotClientHello = errors.New("netutil: not a TLS ClientHello")
+
+// readClientHello reads TLS records from r until it has read a complete
+// ClientHello message, and parses it.
+// It returns the data read from r, even when it returns an error.
+func readClientHello(r io.Reader) (hello *ClientHello, raw []byte, err error) {
+	const (
+		recordHeaderLen        = 5
+		recordTypeHandshake    = 22
+		handshakeHeaderLen     = 4
+		typeClientHello        = 1
+		maxPlaintextRecordSize = 16384
+	)
+	var msg []byte // handshake message, reassembled from records
+	for {
+		start := len(raw)
+		raw = append(raw, make([]byte, recordHeaderLen)...)
+		n, err := io.ReadFull(r, raw[start:])
+		raw = raw[:start+n]
+		if err != nil {
+			return nil, raw, err
+		}
+		hdr := raw[start:]
+		if hdr[0] != recordTypeHandshake {
+			return nil, raw, errNotClientHello
+		}
+		length := int(hdr[3])<<8 | int(hdr[4])
+		if length == 0 || length > maxPlaintextRecordSize {
+			return nil, raw, errNotClientHello
+		}
+		start = len(raw)
+		raw = append(raw, make([]byte, length)...)
+		n, err = io.ReadFull(r, raw[start:])
+		raw = raw[:start+n]
+		if err != nil {
+			return nil, raw, err
+		}
+		msg = append(msg, raw[start:]...)
+		if len(msg) < handshakeHeaderLen {
+			continue
+		}
+		if msg[0] != typeClientHello {
+			return nil, raw, errNotClientHello
+		}
+		msgLen := int(msg[1])<<16 | int(msg[2])<<8 | int(msg[3])
+		if msgLen > maxClientHelloSize {
+			return nil, raw, errNotClientHello
+		}
+		if len(msg) >= handshakeHeaderLen+msgLen {
+			hello, ok := parseClientHello(msg[handshakeHeaderLen : handshakeHeaderLen+msgLen])
+			if !ok {
+				return nil, raw, errNotClientHello
+			}
+			return hello, raw, nil
+		}
+	}
+}
+
+// parseClientHello parses the body of a ClientHello message.
+// https://www.rfc-editor.org/rfc/rfc8446#section-4.1.2
+func parseClientHello(b []byte) (*ClientHello, bool) {
+	const (
+		extensionServerName = 0
+		extensionALPN       = 16
+		serverNameTypeHost  = 0
+	)
+	s := cryptobyte.String(b)
+	var sessionID, cipherSuites, compressionMethods cryptobyte.String
+	if !s.Skip(2+32) || // legacy_version, random
+		!s.ReadUint8LengthPrefixed(&sessionID) ||
+		!s.ReadUint16LengthPrefixed(&cipherSuites) ||
+		!s.ReadUint8LengthPrefixed(&compressionMethods) {
+		return nil, false
+	}
+	hello := &ClientHello{}
+	if s.Empty() {
+		// No extensions.
+		return hello, true
+	}
+	var extensions cryptobyte.String
+	if !s.ReadUint16LengthPrefixed(&extensions) || !s.Empty() {
+		return nil, false
+	}
+	for !extensions.Empty() {
+		var typ uint16
+		var data cryptobyte.String
+		if !extensions.ReadUint16(&typ) || !extensions.ReadUint16LengthPrefixed(&data) {
+			return nil, false
+		}
+		switch typ {
+		case extensionServerName:
+			var names cryptobyte.String
+			if !data.ReadUint16LengthPrefixed(&names) {
+				return nil, false
+			}
+			for !names.Empty() {
+				var nameType uint8
+				var name cryptobyte.String
+				if !names.ReadUint8(&nameType) || !names.ReadUint16LengthPrefixed(&name) {
+					return nil, false
+				}
+				if nameType == serverNameTypeHost && hello.ServerName == "" {
+					hello.ServerName = strings.TrimSuffix(string(name), ".")
+				}
+			}
+		case extensionALPN:
+			var protos cryptobyte.String
+			if !data.ReadUint16LengthPrefixed(&protos) {
+				return nil, false
+			}
+			for !protos.Empty() {
+				var proto cryptobyte.String
+				if !protos.ReadUint8LengthPrefixed(&proto) || len(proto) == 0 {
+					return nil, false
+				}
+				hello.ALPNProtocols = append(hello.ALPNProtocols, string(proto))
+			}
+		}
+	}
+	return hello, true
+}
+
+// matchServerName reports whether name matches pattern.
+// See TLSMux.MatchServerName.
+func matchServerName(pattern, name string) bool {
+	if name == "" {
+		return false
+	}
+	if strings.HasPrefix(pattern, "*.") {
+		i := strings.IndexByte(name, '.')
+		if i <= 0 {
+			return false
+		}
+		return strings.EqualFold(pattern[1:], name[i:])
+	}
+	return strings.EqualFold(pattern, name)
+}
@@ -0,0 +1,291 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package netutil
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newTLSMuxTestCert(t *testing.T) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func newTestTLSMux(t *testing.T) *TLSMux {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewTLSMux(l)
+	t.Cleanup(func() { m.Close() })
+	return m
+}
+
+// tlsMuxServe serves TLS on l, writing the name of the route to each connection.
+func tlsMuxServe(l net.Listener, route string, cert tls.Certificate) {
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer c.Close()
+			tc := tls.Server(c, &tls.Config{
+				Certificates: []tls.Certificate{cert},
+				NextProtos:   []string{"h2", "http/1.1"},
+			})
+			tc.Write([]byte(route))
+			tc.Close()
+		}()
+	}
+}
+
+func TestTLSMuxRouting(t *testing.T) {
+	cert := newTLSMuxTestCert(t)
+	m := newTestTLSMux(t)
+	go tlsMuxServe(m.MatchServerName("exact.example.com"), "exact", cert)
+	go tlsMuxServe(m.MatchServerName("*.example.com"), "wildcard", cert)
+	go tlsMuxServe(m.MatchALPN("h2"), "h2", cert)
+	go tlsMuxServe(m.Default(), "default", cert)
+	go m.Serve()
+
+	for _, test := range []struct {
+		serverName string
+		nextProtos []string
+		want       string
+	}{{
+		serverName: "exact.example.com",
+		want:       "exact",
+	}, {
+		serverName: "EXACT.example.com",
+		nextProtos: []string{"h2"},
+		want:       "exact",
+	}, {
+		serverName: "www.example.com",
+		want:       "wildcard",
+	}, {
+		serverName: "a.b.example.com",
+		nextProtos: []string{"http/1.1", "h2"},
+		want:       "h2",
+	}, {
+		serverName: "example.com",
+		want:       "default",
+	}, {
+		// No SNI.
+		want: "default",
+	}} {
+		c, err := tls.Dial("tcp", m.l.Addr().String(), &tls.Config{
+			ServerName:         test.serverName,
+			NextProtos:         test.nextProtos,
+			InsecureSkipVerify: true,
+		})
+		if err != nil {
+			t.Fatalf("tls.Dial(ServerName=%q, NextProtos=%q): %v", test.serverName, test.nextProtos, err)
+		}
+		got, err := io.ReadAll(c)
+		c.Close()
+		if err != nil {
+			t.Fatalf("ServerName=%q, NextProtos=%q: read: %v", test.serverName, test.nextProtos, err)
+		}
+		if string(got) != test.want {
+			t.Errorf("ServerName=%q, NextProtos=%q: routed to %q, want %q", test.serverName, test.nextProtos, got, test.want)
+		}
+	}
+}
+
+func TestTLSMuxNotTLS(t *testing.T) {
+	m := newTestTLSMux(t)
+	m.MatchServerName("example.com")
+	def := m.Default()
+	go m.Serve()
+
+	c, err := net.Dial("tcp", m.l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	const req = "GET / HTTP/1.0\r\n\r\n"
+	if _, err := c.Write([]byte(req)); err != nil {
+		t.Fatal(err)
+	}
+	sc, err := def.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sc.Close()
+	got := make([]byte, len(req))
+	if _, err := io.ReadFull(sc, got); err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != req {
+		t.Errorf("default route read %q, want %q", got, req)
+	}
+}
+
+func TestTLSMuxNoRoute(t *testing.T) {
+	m := newTestTLSMux(t)
+	m.MatchServerName("example.com")
+	go m.Serve()
+
+	c, err := net.Dial("tcp", m.l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.Write([]byte("not tls, no default route"))
+	c.SetReadDeadline(time.Now().Add(10 * time.Second))
+	// The connection is closed with unread data, so it may be reset.
+	_, err = c.Read(make([]byte, 1))
+	if ne, ok := err.(net.Error); err == nil || ok && ne.Timeout() {
+		t.Errorf("read from unrouted connection: %v, want connection closed", err)
+	}
+}
+
+func TestTLSMuxClose(t *testing.T) {
+	m := newTestTLSMux(t)
+	l := m.Default()
+	errc := make(chan error, 1)
+	go func() { errc <- m.Serve() }()
+	m.Close()
+	if err := <-errc; !errors.Is(err, net.ErrClosed) {
+		t.Errorf("Serve after Close = %v, want net.ErrClosed", err)
+	}
+	if _, err := l.Accept(); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("Accept after Close = %v, want net.ErrClosed", err)
+	}
+}
+
+func TestTLSMuxAcceptQueue(t *testing.T) {
+	for _, timeout := range []time.Duration{-1, 10 * time.Millisecond} {
+		m := newTestTLSMux(t)
+		m.AcceptQueueSize = 1
+		m.AcceptTimeout = timeout
+		l := m.Default()
+		go m.Serve()
+
+		dial := func(data string) net.Conn {
+			c, err := net.Dial("tcp", m.l.Addr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { c.Close() })
+			c.Write([]byte(data))
+			c.SetReadDeadline(time.Now().Add(10 * time.Second))
+			return c
+		}
+		wantClosed := func(c net.Conn, what string) {
+			t.Helper()
+			// The connection is closed with unread data, so it may be reset.
+			_, err := c.Read(make([]byte, 1))
+			if ne, ok := err.(net.Error); err == nil || ok && ne.Timeout() {
+				t.Errorf("AcceptTimeout=%v: read from %v: %v, want connection closed", timeout, what, err)
+			}
+		}
+
+		// The first connection fills the queue,
+		// and the second is closed when it cannot be queued.
+		waitQueued := func() {
+			deadline := time.Now().Add(10 * time.Second)
+			for len(m.def.queue()) == 0 && time.Now().Before(deadline) {
+				time.Sleep(time.Millisecond)
+			}
+		}
+		queued := dial("queued")
+		waitQueued()
+		overflow := dial("overflow")
+		wantClosed(overflow, "connection routed to full queue")
+
+		c, err := l.Accept()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make([]byte, len("queued"))
+		if _, err := io.ReadFull(c, got); err != nil || string(got) != "queued" {
+			t.Errorf("AcceptTimeout=%v: accepted connection read %q, %v; want %q", timeout, got, err, "queued")
+		}
+		c.Close()
+		wantClosed(queued, "accepted connection after Close")
+
+		// Closing the route closes connections waiting to be accepted.
+		pending := dial("pending")
+		waitQueued()
+		l.Close()
+		wantClosed(pending, "queued connection after route Close")
+	}
+}
+
+func TestReadClientHello(t *testing.T) {
+	// Capture a ClientHello from crypto/tls.
+	cc, sc := net.Pipe()
+	go tls.Client(cc, &tls.Config{
+		ServerName: "example.com",
+		NextProtos: []string{"h2", "http/1.1"},
+	}).Handshake()
+	defer cc.Close()
+	hello, raw, err := readClientHello(sc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &ClientHello{
+		ServerName:    "example.com",
+		ALPNProtocols: []string{"h2", "http/1.1"},
+	}
+	if !reflect.DeepEqual(hello, want) {
+		t.Errorf("readClientHello = %+v, want %+v", hello, want)
+	}
+
+	// Split the ClientHello across two records.
+	body := raw[5:]
+	split := 10
+	var fragmented []byte
+	fragmented = append(fragmented, 22, raw[1], raw[2], 0, byte(split))
+	fragmented = append(fragmented, body[:split]...)
+	rest := body[split:]
+	fragmented = append(fragmented, 22, raw[1], raw[2], byte(len(rest)>>8), byte(len(rest)))
+	fragmented = append(fragmented, rest...)
+	hello, gotRaw, err := readClientHello(bytes.NewReader(fragmented))
+	if err != nil {
+		t.Fatalf("fragmented ClientHello: %v", err)
+	}
+	if !reflect.DeepEqual(hello, want) {
+		t.Errorf("fragmented ClientHello: readClientHello = %+v, want %+v", hello, want)
+	}
+	if !bytes.Equal(gotRaw, fragmented) {
+		t.Errorf("fragmented ClientHello: raw data not returned")
+	}
+
+	// Truncated ClientHello.
+	if _, _, err := readClientHello(bytes.NewReader(raw[:len(raw)-1])); err == nil {
+		t.Errorf("truncated ClientHello: got no error")
+	}
+}
@@ -24,6 +24,12 @@ type Dialer interface {
 // Auth contains authentication parameters that specific Dialers may require.
 type Auth struct {
 	User, Password string
+
+	// Authenticators specifies additional SOCKS5 authentication
+	// methods, such as GSSAPI, offered to the proxy server.
+	// Username/password authentication is offered only when
+	// User is not empty or Authenticators is empty.
+	Authenticators []SOCKS5Authenticator
 }
 
 // FromEnvironment returns the dialer specified by the proxy-related
@@ -9,6 +9,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/url"
 	"os"
@@ -113,6 +114,189 @@ func TestSOCKS5(t *testing.T) {
 	c.Close()
 }
 
+// tokenAuthenticator is a SOCKS5Authenticator for a private
+// method which sends a token and reads a one-byte status.
+// If wrap is set, it wraps the connection in a countingConn.
+type tokenAuthenticator struct {
+	token string
+	wrap  bool
+}
+
+func (a *tokenAuthenticator) Method() byte { return SOCKS5AuthMethodPrivateMin }
+
+func (a *tokenAuthenticator) Authenticate(ctx context.Context, c net.Conn) (net.Conn, error) {
+	if _, err := io.WriteString(c, a.token); err != nil {
+		return nil, err
+	}
+	b := make([]byte, 1)
+	if _, err := io.ReadFull(c, b); err != nil {
+		return nil, err
+	}
+	if b[0] != 0 {
+		return nil, errors.New("token rejected")
+	}
+	if a.wrap {
+		return &countingConn{Conn: c}, nil
+	}
+	return c, nil
+}
+
+// A countingConn counts the bytes written to a connection.
+type countingConn struct {
+	net.Conn
+	written int
+}
+
+func (c *countingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	c.written += n
+	return n, err
+}
+
+// tokenAuthRequired returns a sockstest auth function which
+// selects the tokenAuthenticator method and accepts only token.
+func tokenAuthRequired(token string) func(io.ReadWriter, []byte) error {
+	return func(rw io.ReadWriter, b []byte) error {
+		req, err := sockstest.ParseAuthRequest(b)
+		if err != nil {
+			return err
+		}
+		m := socks.AuthMethodNoAcceptableMethods
+		for _, am := range req.Methods {
+			if am == SOCKS5AuthMethodPrivateMin {
+				m = am
+			}
+		}
+		b, err = sockstest.MarshalAuthReply(req.Version, m)
+		if err != nil {
+			return err
+		}
+		if _, err := rw.Write(b); err != nil {
+			return err
+		}
+		if m == socks.AuthMethodNoAcceptableMethods {
+			return errors.New("no acceptable methods")
+		}
+		b = make([]byte, len(token))
+		if _, err := io.ReadFull(rw, b); err != nil {
+			return err
+		}
+		status := byte(0)
+		if string(b) != token {
+			status = 1
+		}
+		if _, err := rw.Write([]byte{status}); err != nil {
+			return err
+		}
+		if status != 0 {
+			return errors.New("bad token")
+		}
+		return nil
+	}
+}
+
+func TestSOCKS5Authenticator(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		auth    *Auth
+		wantErr bool
+	}{{
+		name: "accepted",
+		auth: &Auth{Authenticators: []SOCKS5Authenticator{&tokenAuthenticator{token: "secret"}}},
+	}, {
+		name:    "rejected",
+		auth:    &Auth{Authenticators: []SOCKS5Authenticator{&tokenAuthenticator{token: "wrong!"}}},
+		wantErr: true,
+	}, {
+		name:    "not offered",
+		auth:    &Auth{User: "user", Password: "password"},
+		wantErr: true,
+	}} {
+		t.Run(test.name, func(t *testing.T) {
+			ss, err := sockstest.NewServer(tokenAuthRequired("secret"), sockstest.NoProxyRequired)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer ss.Close()
+			proxy, err := SOCKS5("tcp", ss.Addr().String(), test.auth, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c, err := proxy.Dial("tcp", ss.TargetAddr().String())
+			if err == nil {
+				c.Close()
+			}
+			if gotErr := err != nil; gotErr != test.wantErr {
+				t.Errorf("Dial: %v, want error: %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestSOCKS5AuthenticatorWrapsConn(t *testing.T) {
+	ss, err := sockstest.NewServer(tokenAuthRequired("secret"), sockstest.NoProxyRequired)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ss.Close()
+	auth := &Auth{Authenticators: []SOCKS5Authenticator{&tokenAuthenticator{token: "secret", wrap: true}}}
+	proxy, err := SOCKS5("tcp", ss.Addr().String(), auth, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		name string
+		dial func() (net.Conn, error)
+	}{{
+		name: "Dial",
+		dial: func() (net.Conn, error) {
+			return proxy.Dial("tcp", ss.TargetAddr().String())
+		},
+	}, {
+		name: "DialContext",
+		dial: func() (net.Conn, error) {
+			return proxy.(ContextDialer).DialContext(context.Background(), "tcp", ss.TargetAddr().String())
+		},
+	}} {
+		c, err := test.dial()
+		if err != nil {
+			t.Fatalf("%v: %v", test.name, err)
+		}
+		c.Write([]byte("x"))
+		var cc *countingConn
+		switch c := c.(type) {
+		case *countingConn:
+			cc = c
+		case *socks.Conn:
+			cc, _ = c.Conn.(*countingConn)
+		}
+		if cc == nil {
+			t.Errorf("%v returned %T, want connection returned by authenticator", test.name, c)
+		} else if cc.written <= 1 {
+			// The CONNECT request and the byte written above.
+			t.Errorf("%v: %v bytes written through authenticator's connection, want more than 1", test.name, cc.written)
+		}
+		c.Close()
+	}
+}
+
+func TestSOCKS5AuthenticatorReservedMethod(t *testing.T) {
+	for _, m := range []byte{0x00, 0x02, 0xff} {
+		auth := &Auth{Authenticators: []SOCKS5Authenticator{reservedMethodAuthenticator(m)}}
+		if _, err := SOCKS5("tcp", "127.0.0.1:1080", auth, nil); err == nil {
+			t.Errorf("SOCKS5 with authenticator for method %#x: succeeded, want error", m)
+		}
+	}
+}
+
+type reservedMethodAuthenticator byte
+
+func (a reservedMethodAuthenticator) Method() byte { return byte(a) }
+
+func (a reservedMethodAuthenticator) Authenticate(ctx context.Context, c net.Conn) (net.Conn, error) {
+	return c, nil
+}
+
 type funcFailDialer func(context.Context) error
 
 func (f funcFailDialer) Dial(net, addr string) (net.Conn, error) {
@@ -6,13 +6,17 @@ package proxy
 
 import (
 	"context"
+	"errors"
 	"net"
+	"strconv"
 
 	"golang.org/x/net/internal/socks"
 )
 
 // SOCKS5 returns a Dialer that makes SOCKSv5 connections to the given
-// address with an optional username and password.
+// address with optional authentication.
+// The auth parameter may provide a username and password,
+// as well as additional authentication methods such as GSSAPI.
 // See RFC 1928 and RFC 1929.
 func SOCKS5(network, address string, auth *Auth, forward Dialer) (Dialer, error) {
 	d := socks.NewDialer(network, address)
@@ -28,15 +32,62 @@ func SOCKS5(network, address string, auth *Auth, forward Dialer) (Dialer, error)
 		}
 	}
 	if auth != nil {
+		for _, a := range auth.Authenticators {
+			switch m := socks.AuthMethod(a.Method()); m {
+			case socks.AuthMethodNotRequired, socks.AuthMethodUsernamePassword, socks.AuthMethodNoAcceptableMethods:
+				return nil, errors.New("proxy: SOCKS5 authenticator has reserved method code " + strconv.Itoa(int(m)))
+			}
+		}
 		up := socks.UsernamePassword{
 			Username: auth.User,
 			Password: auth.Password,
 		}
-		d.AuthMethods = []socks.AuthMethod{
-			socks.AuthMethodNotRequired,
-			socks.AuthMethodUsernamePassword,
+		d.AuthMethods = []socks.AuthMethod{socks.AuthMethodNotRequired}
+		for _, a := range auth.Authenticators {
+			d.AuthMethods = append(d.AuthMethods, socks.AuthMethod(a.Method()))
+		}
+		if auth.User != "" || len(auth.Authenticators) == 0 {
+			d.AuthMethods = append(d.AuthMethods, socks.AuthMethodUsernamePassword)
+		}
+		authenticators := auth.Authenticators
+		d.AuthenticateConn = func(ctx context.Context, c net.Conn, am socks.AuthMethod) (net.Conn, error) {
+			for _, a := range authenticators {
+				if socks.AuthMethod(a.Method()) == am {
+					return a.Authenticate(ctx, c)
+				}
+			}
+			return c, up.Authenticate(ctx, c, am)
 		}
-		d.Authenticate = up.Authenticate
 	}
 	return d, nil
 }
+
+// SOCKS5 authentication method codes.
+// See RFC 1928, Section 3.
+const (
+	SOCKS5AuthMethodGSSAPI = byte(socks.AuthMethodGSSAPI) // GSSAPI, see RFC 1961
+
+	// Codes in the range 0x80 to 0xfe are reserved for private methods.
+	SOCKS5AuthMethodPrivateMin = 0x80
+	SOCKS5AuthMethodPrivateMax = 0xfe
+)
+
+// A SOCKS5Authenticator implements a SOCKS5 authentication method.
+// It is used to support methods beyond username/password,
+// such as GSSAPI or proprietary methods used by enterprise proxies.
+type SOCKS5Authenticator interface {
+	// Method returns the method code sent to the proxy server
+	// during method negotiation.
+	// It may not be 0x00 (no authentication), 0x02 (username/password),
+	// or 0xff (no acceptable methods); SOCKS5 returns an error if it is.
+	Method() byte
+
+	// Authenticate performs the method-specific sub-negotiation
+	// on c, the connection to the proxy server,
+	// after the proxy server has selected the authenticator's method.
+	// It returns the connection to use for the rest of the session:
+	// c itself, or a connection wrapping c for methods which
+	// encapsulate the traffic, such as GSSAPI with message protection.
+	// It returns a non-nil error if authentication fails.
+	Authenticate(ctx context.Context, c net.Conn) (net.Conn, error)
+}
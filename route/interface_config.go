@@ -0,0 +1,119 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+
+package route
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// SendInterfaceAddr adds or deletes an IPv4 address of a network
+// interface, as described by m.
+//
+// m.Type must be RTM_NEWADDR to add the address, or RTM_DELADDR to
+// delete it, and m.Index is the index of the interface.
+// m.Addrs is indexed by RTAX_IFA for the address, RTAX_NETMASK for its
+// network mask, and RTAX_BRD for its broadcast address or, on a
+// point-to-point interface, the address of the other end.
+// The network mask and broadcast address are optional,
+// and are ignored when deleting an address.
+//
+// Kernels do not accept interface address messages written to a
+// routing socket. Instead, SendInterfaceAddr configures the address
+// with the SIOCAIFADDR or SIOCDIFADDR ioctl, and the kernel reports the
+// change to routing sockets with a message of type m.Type.
+//
+// IPv6 addresses are not supported.
+func SendInterfaceAddr(m *InterfaceAddrMessage) error {
+	var req uint
+	switch m.Type {
+	case syscall.RTM_NEWADDR:
+		req = siocAIFADDR
+	case syscall.RTM_DELADDR:
+		req = siocDIFADDR
+	default:
+		return errUnsupportedMessage
+	}
+	name, err := interfaceName(m.Index)
+	if err != nil {
+		return err
+	}
+	b, err := m.marshalAliasReq(name)
+	if err != nil {
+		return err
+	}
+	s, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM, 0)
+	if err != nil {
+		return os.NewSyscallError("socket", err)
+	}
+	defer syscall.Close(s)
+	if err := ioctlPtr(s, req, unsafe.Pointer(&b[0])); err != nil {
+		return os.NewSyscallError("ioctl", err)
+	}
+	return nil
+}
+
+// marshalAliasReq returns the in_aliasreq structure for the address
+// described by m, on the interface named name.
+// Its prefix is also the ifreq structure used to delete the address.
+func (m *InterfaceAddrMessage) marshalAliasReq(name string) ([]byte, error) {
+	addr := inet4AddrAt(m.Addrs, syscall.RTAX_IFA)
+	if addr == nil || len(name) >= syscall.IFNAMSIZ {
+		return nil, errInvalidAddr
+	}
+	// Each address in the structure is a sockaddr_in,
+	// following the interface name.
+	const sockaddrOff = syscall.IFNAMSIZ
+	l := sizeofInAliasreq
+	if l < sizeofIfreq {
+		l = sizeofIfreq
+	}
+	b := make([]byte, l)
+	copy(b, name)
+	for i, a := range []*Inet4Addr{
+		addr,
+		inet4AddrAt(m.Addrs, syscall.RTAX_BRD),
+		inet4AddrAt(m.Addrs, syscall.RTAX_NETMASK),
+	} {
+		if a == nil {
+			continue
+		}
+		off := sockaddrOff + i*sizeofSockaddrInet
+		if _, err := a.marshal(b[off : off+sizeofSockaddrInet]); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// inet4AddrAt returns the IPv4 address at index i of as, or nil if there is none.
+func inet4AddrAt(as []Addr, i int) *Inet4Addr {
+	if i >= len(as) {
+		return nil
+	}
+	a, _ := as[i].(*Inet4Addr)
+	return a
+}
+
+// interfaceName returns the name of the interface with the given index.
+func interfaceName(index int) (string, error) {
+	b, err := FetchRIB(syscall.AF_UNSPEC, RIBTypeInterface, index)
+	if err != nil {
+		return "", err
+	}
+	ms, err := ParseRIB(RIBTypeInterface, b)
+	if err != nil {
+		return "", err
+	}
+	for _, m := range ms {
+		if m, ok := m.(*InterfaceMessage); ok && m.Index == index {
+			return m.Name, nil
+		}
+	}
+	return "", errNoInterface
+}
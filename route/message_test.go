@@ -7,6 +7,7 @@
 package route
 
 import (
+	"bytes"
 	"os"
 	"syscall"
 	"testing"
@@ -236,3 +237,95 @@ func TestRouteMessage(t *testing.T) {
 		}
 	}
 }
+
+func TestRouteMessageMetrics(t *testing.T) {
+	m := RouteMessage{
+		Version: syscall.RTM_VERSION,
+		Type:    syscall.RTM_ADD,
+		ID:      uintptr(os.Getpid()),
+		Seq:     1,
+		Addrs: []Addr{
+			syscall.RTAX_DST:     &Inet4Addr{IP: [4]byte{192, 0, 2, 0}},
+			syscall.RTAX_GATEWAY: &Inet4Addr{IP: [4]byte{127, 0, 0, 1}},
+			syscall.RTAX_NETMASK: &Inet4Addr{IP: [4]byte{255, 255, 255, 0}},
+		},
+		Metrics: &RouteMetrics{PathMTU: 1280},
+	}
+	b, err := m.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ms, err := ParseRIB(RIBTypeRoute, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ms) != 1 {
+		t.Fatalf("ParseRIB returned %v messages, want 1", len(ms))
+	}
+	rm, ok := ms[0].(*RouteMessage)
+	if !ok {
+		t.Fatalf("ParseRIB returned %T, want *RouteMessage", ms[0])
+	}
+	var rmx *RouteMetrics
+	for _, sys := range rm.Sys() {
+		if sys.SysType() == SysMetrics {
+			rmx = sys.(*RouteMetrics)
+		}
+	}
+	if rmx == nil || rmx.PathMTU != 1280 {
+		t.Errorf("parsed metrics = %+v, want PathMTU 1280", rmx)
+	}
+}
+
+func TestSend(t *testing.T) {
+	m := &RouteMessage{
+		Version: syscall.RTM_VERSION,
+		Type:    syscall.RTM_GET,
+		Addrs: []Addr{
+			syscall.RTAX_DST: &Inet4Addr{IP: [4]byte{127, 0, 0, 1}},
+		},
+	}
+	rm, err := Send(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rm.Type != syscall.RTM_GET || rm.ID != uintptr(os.Getpid()) || rm.Seq == 0 {
+		t.Errorf("Send() reply = %+v, want RTM_GET reply to this process", rm)
+	}
+	if m.ID != 0 || m.Seq != 0 {
+		t.Errorf("Send modified its argument: ID=%v, Seq=%v", m.ID, m.Seq)
+	}
+}
+
+func TestInterfaceAddrMessageAliasReq(t *testing.T) {
+	m := &InterfaceAddrMessage{
+		Type: syscall.RTM_NEWADDR,
+		Addrs: []Addr{
+			syscall.RTAX_NETMASK: &Inet4Addr{IP: [4]byte{255, 255, 255, 0}},
+			syscall.RTAX_IFA:     &Inet4Addr{IP: [4]byte{192, 0, 2, 1}},
+			syscall.RTAX_BRD:     &Inet4Addr{IP: [4]byte{192, 0, 2, 255}},
+		},
+	}
+	b, err := m.marshalAliasReq("lo0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(b[:4]); got != "lo0\x00" {
+		t.Errorf("interface name = %q, want %q", got, "lo0\x00")
+	}
+	for i, want := range [][4]byte{
+		{192, 0, 2, 1},
+		{192, 0, 2, 255},
+		{255, 255, 255, 0},
+	} {
+		sa := b[syscall.IFNAMSIZ+i*sizeofSockaddrInet:]
+		if sa[0] != sizeofSockaddrInet || sa[1] != syscall.AF_INET || !bytes.Equal(sa[4:8], want[:]) {
+			t.Errorf("address %v = %v, want sockaddr_in for %v", i, sa[:sizeofSockaddrInet], want)
+		}
+	}
+
+	m.Addrs = []Addr{syscall.RTAX_IFA: &Inet6Addr{}}
+	if _, err := m.marshalAliasReq("lo0"); err == nil {
+		t.Errorf("marshalAliasReq with IPv6 address succeeded, want error")
+	}
+}
@@ -15,7 +15,9 @@ package route
 import (
 	"errors"
 	"os"
+	"sync/atomic"
 	"syscall"
+	"time"
 )
 
 var (
@@ -25,6 +27,8 @@ var (
 	errInvalidMessage     = errors.New("invalid message")
 	errInvalidAddr        = errors.New("invalid address")
 	errShortBuffer        = errors.New("short buffer")
+	errNoReply            = errors.New("no reply to message")
+	errNoInterface        = errors.New("no such interface")
 )
 
 // A RouteMessage represents a message conveying an address prefix, a
@@ -79,6 +83,12 @@ type RouteMessage struct {
 	Err     error   // error on requested operation
 	Addrs   []Addr  // addresses
 
+	// Metrics, if non-nil, specifies route metrics to initialize
+	// when the message is marshaled, such as the path MTU of a route
+	// being added. It is not set by parsing; use Sys to read the
+	// metrics of a received message.
+	Metrics *RouteMetrics
+
 	extOff int    // offset of header extension
 	raw    []byte // raw message
 }
@@ -88,6 +98,83 @@ func (m *RouteMessage) Marshal() ([]byte, error) {
 	return m.marshal()
 }
 
+var sendSeq atomic.Int32
+
+// sendTimeout is the time Send waits for the kernel's reply.
+const sendTimeout = 5 * time.Second
+
+// Send writes m to a routing socket and returns the kernel's reply.
+// It may be used to add, delete, change or query routes with
+// RTM_ADD, RTM_DELETE, RTM_CHANGE or RTM_GET messages.
+//
+// If m.ID is zero, the message is sent with the process ID as its ID.
+// If m.Seq is zero, the message is sent with a sequence number
+// chosen by Send. m is not modified.
+//
+// If the kernel rejects the message, or does not reply within
+// five seconds, Send returns an error.
+// If the kernel's reply reports an error, Send returns the reply
+// along with its Err.
+//
+// Addresses of network interfaces are not configured with routing
+// messages; use SendInterfaceAddr to add or delete them.
+func Send(m *RouteMessage) (*RouteMessage, error) {
+	req := *m
+	if req.ID == 0 {
+		req.ID = uintptr(os.Getpid())
+	}
+	if req.Seq == 0 {
+		req.Seq = int(sendSeq.Add(1))
+	}
+	wb, err := req.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	s, err := syscall.Socket(syscall.AF_ROUTE, syscall.SOCK_RAW, syscall.AF_UNSPEC)
+	if err != nil {
+		return nil, os.NewSyscallError("socket", err)
+	}
+	defer syscall.Close(s)
+	if _, err := syscall.Write(s, wb); err != nil {
+		return nil, os.NewSyscallError("write", err)
+	}
+	// The routing socket receives all routing messages, not only
+	// replies to our own, so read until we find the reply.
+	// Bound the wait with a receive timeout, which is reduced as
+	// unrelated messages arrive.
+	rb := make([]byte, os.Getpagesize())
+	deadline := time.Now().Add(sendTimeout)
+	for {
+		d := time.Until(deadline)
+		if d <= 0 {
+			return nil, errNoReply
+		}
+		tv := syscall.NsecToTimeval(d.Nanoseconds())
+		if err := syscall.SetsockoptTimeval(s, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &tv); err != nil {
+			return nil, os.NewSyscallError("setsockopt", err)
+		}
+		n, err := syscall.Read(s, rb)
+		if err == syscall.EAGAIN {
+			// The receive timeout expired.
+			return nil, errNoReply
+		}
+		if err != nil {
+			return nil, os.NewSyscallError("read", err)
+		}
+		ms, err := ParseRIB(RIBTypeRoute, rb[:n])
+		if err != nil {
+			continue
+		}
+		for _, rm := range ms {
+			rm, ok := rm.(*RouteMessage)
+			if !ok || rm.Type != req.Type || rm.ID != req.ID || rm.Seq != req.Seq {
+				continue
+			}
+			return rm, rm.Err
+		}
+	}
+}
+
 // A RIBType represents a type of routing information base.
 type RIBType int
 
@@ -41,9 +41,22 @@ func (m *RouteMessage) marshal() ([]byte, error) {
 	if attrs > 0 {
 		nativeEndian.PutUint32(b[12:16], uint32(attrs))
 	}
+	if m.Metrics != nil {
+		m.Metrics.marshal(b, w.extOff)
+	}
 	return b, nil
 }
 
+// putRouteInits sets the rtm_inits field of the route message b,
+// which specifies the route metrics to initialize.
+func putRouteInits(b []byte, inits uint32) {
+	if kernelAlign == 8 {
+		nativeEndian.PutUint64(b[32:40], uint64(inits))
+	} else {
+		nativeEndian.PutUint32(b[32:36], inits)
+	}
+}
+
 func (w *wireFormat) parseRouteMessage(typ RIBType, b []byte) (Message, error) {
 	if len(b) < w.bodyOff {
 		return nil, errMessageTooShort
@@ -30,6 +30,9 @@ func (m *RouteMessage) marshal() ([]byte, error) {
 	if attrs > 0 {
 		nativeEndian.PutUint32(b[12:16], uint32(attrs))
 	}
+	if m.Metrics != nil {
+		m.Metrics.marshal(b)
+	}
 	return b, nil
 }
 
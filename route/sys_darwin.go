@@ -6,6 +6,14 @@ package route
 
 import "syscall"
 
+// Ioctl requests and structure sizes used by SendInterfaceAddr.
+const (
+	siocAIFADDR      = syscall.SIOCAIFADDR
+	siocDIFADDR      = syscall.SIOCDIFADDR
+	sizeofInAliasreq = 0x40
+	sizeofIfreq      = 0x20
+)
+
 func (typ RIBType) parseable() bool {
 	switch typ {
 	case syscall.NET_RT_STAT, syscall.NET_RT_TRASH:
@@ -23,6 +31,13 @@ type RouteMetrics struct {
 // SysType implements the SysType method of Sys interface.
 func (rmx *RouteMetrics) SysType() SysType { return SysMetrics }
 
+func (rmx *RouteMetrics) marshal(b []byte, extOff int) {
+	if rmx.PathMTU > 0 {
+		putRouteInits(b, syscall.RTV_MTU)
+		nativeEndian.PutUint32(b[extOff+4:extOff+8], uint32(rmx.PathMTU))
+	}
+}
+
 // Sys implements the Sys method of Message interface.
 func (m *RouteMessage) Sys() []Sys {
 	return []Sys{
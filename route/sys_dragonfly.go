@@ -9,6 +9,14 @@ import (
 	"unsafe"
 )
 
+// Ioctl requests and structure sizes used by SendInterfaceAddr.
+const (
+	siocAIFADDR      = syscall.SIOCAIFADDR
+	siocDIFADDR      = syscall.SIOCDIFADDR
+	sizeofInAliasreq = 0x40
+	sizeofIfreq      = 0x20
+)
+
 func (typ RIBType) parseable() bool { return true }
 
 // RouteMetrics represents route metrics.
@@ -19,6 +27,13 @@ type RouteMetrics struct {
 // SysType implements the SysType method of Sys interface.
 func (rmx *RouteMetrics) SysType() SysType { return SysMetrics }
 
+func (rmx *RouteMetrics) marshal(b []byte, extOff int) {
+	if rmx.PathMTU > 0 {
+		putRouteInits(b, syscall.RTV_MTU)
+		nativeEndian.PutUint64(b[extOff+8:extOff+16], uint64(rmx.PathMTU))
+	}
+}
+
 // Sys implements the Sys method of Message interface.
 func (m *RouteMessage) Sys() []Sys {
 	return []Sys{
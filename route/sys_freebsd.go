@@ -9,6 +9,14 @@ import (
 	"unsafe"
 )
 
+// Ioctl requests and structure sizes used by SendInterfaceAddr.
+const (
+	siocAIFADDR      = 0x8044692b // _IOW('i', 43, struct in_aliasreq); syscall has the FreeBSD 9 value
+	siocDIFADDR      = syscall.SIOCDIFADDR
+	sizeofInAliasreq = 0x44
+	sizeofIfreq      = 0x20
+)
+
 func (typ RIBType) parseable() bool { return true }
 
 // RouteMetrics represents route metrics.
@@ -19,6 +27,18 @@ type RouteMetrics struct {
 // SysType implements the SysType method of Sys interface.
 func (rmx *RouteMetrics) SysType() SysType { return SysMetrics }
 
+func (rmx *RouteMetrics) marshal(b []byte, extOff int) {
+	if rmx.PathMTU <= 0 {
+		return
+	}
+	putRouteInits(b, syscall.RTV_MTU)
+	if kernelAlign == 8 {
+		nativeEndian.PutUint64(b[extOff+8:extOff+16], uint64(rmx.PathMTU))
+	} else {
+		nativeEndian.PutUint32(b[extOff+4:extOff+8], uint32(rmx.PathMTU))
+	}
+}
+
 // Sys implements the Sys method of Message interface.
 func (m *RouteMessage) Sys() []Sys {
 	if kernelAlign == 8 {
@@ -6,6 +6,14 @@ package route
 
 import "syscall"
 
+// Ioctl requests and structure sizes used by SendInterfaceAddr.
+const (
+	siocAIFADDR      = syscall.SIOCAIFADDR
+	siocDIFADDR      = syscall.SIOCDIFADDR
+	sizeofInAliasreq = 0x40
+	sizeofIfreq      = 0x90
+)
+
 func (typ RIBType) parseable() bool { return true }
 
 // RouteMetrics represents route metrics.
@@ -16,6 +24,13 @@ type RouteMetrics struct {
 // SysType implements the SysType method of Sys interface.
 func (rmx *RouteMetrics) SysType() SysType { return SysMetrics }
 
+func (rmx *RouteMetrics) marshal(b []byte, extOff int) {
+	if rmx.PathMTU > 0 {
+		putRouteInits(b, syscall.RTV_MTU)
+		nativeEndian.PutUint64(b[extOff+8:extOff+16], uint64(rmx.PathMTU))
+	}
+}
+
 // Sys implements the Sys method of Message interface.
 func (m *RouteMessage) Sys() []Sys {
 	return []Sys{
@@ -9,6 +9,14 @@ import (
 	"unsafe"
 )
 
+// Ioctl requests and structure sizes used by SendInterfaceAddr.
+const (
+	siocAIFADDR      = syscall.SIOCAIFADDR
+	siocDIFADDR      = syscall.SIOCDIFADDR
+	sizeofInAliasreq = 0x40
+	sizeofIfreq      = 0x20
+)
+
 func (typ RIBType) parseable() bool {
 	switch typ {
 	case syscall.NET_RT_STATS, syscall.NET_RT_TABLE:
@@ -26,6 +34,13 @@ type RouteMetrics struct {
 // SysType implements the SysType method of Sys interface.
 func (rmx *RouteMetrics) SysType() SysType { return SysMetrics }
 
+func (rmx *RouteMetrics) marshal(b []byte) {
+	if rmx.PathMTU > 0 {
+		nativeEndian.PutUint32(b[36:40], syscall.RTV_MTU)
+		nativeEndian.PutUint32(b[60:64], uint32(rmx.PathMTU))
+	}
+}
+
 // Sys implements the Sys method of Message interface.
 func (m *RouteMessage) Sys() []Sys {
 	return []Sys{
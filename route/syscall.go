@@ -6,7 +6,10 @@
 
 package route
 
-import _ "unsafe" // for linkname
+import "unsafe"
 
 //go:linkname sysctl syscall.sysctl
 func sysctl(mib []int32, old *byte, oldlen *uintptr, new *byte, newlen uintptr) error
+
+//go:linkname ioctlPtr syscall.ioctlPtr
+func ioctlPtr(fd int, req uint, arg unsafe.Pointer) error
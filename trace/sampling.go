@@ -0,0 +1,122 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package trace
+
+import (
+	"sync"
+	"time"
+)
+
+// A SamplingPolicy controls which completed traces of a family are retained
+// for display on /debug/requests.
+//
+// By default, every completed trace is retained (subject to the fixed
+// number of traces kept per latency bucket). In high-QPS services, a
+// sampling policy reduces the cost of retaining traces while still
+// capturing the interesting ones: traces with errors and slow traces are
+// always retained, regardless of the sampling rate and rate limit.
+//
+// Active traces and the latency histogram are not affected by sampling.
+type SamplingPolicy struct {
+	// Rate retains one in every Rate completed traces.
+	// If Rate is zero or one, every trace is eligible to be retained.
+	Rate int
+
+	// MaxPerSecond limits the number of sampled traces retained each second.
+	// If zero, there is no limit.
+	MaxPerSecond int
+
+	// SlowThreshold is the elapsed time after which a trace is always retained.
+	// If zero, only traces with errors are always retained.
+	SlowThreshold time.Duration
+
+	// MaxEvents limits the number of events recorded by each trace,
+	// bounding the memory used by retained traces.
+	// It also limits the value accepted by Trace.SetMaxEvents.
+	// If zero, the default limit is used.
+	MaxEvents int
+}
+
+// SetSamplingPolicy sets the sampling policy for traces of the given family.
+// It affects traces created after the call.
+func SetSamplingPolicy(family string, p SamplingPolicy) {
+	samplersMu.Lock()
+	defer samplersMu.Unlock()
+	samplers[family] = &sampler{policy: p}
+}
+
+// ClearSamplingPolicy removes the sampling policy for traces of the given family,
+// so that every completed trace is retained.
+func ClearSamplingPolicy(family string) {
+	samplersMu.Lock()
+	defer samplersMu.Unlock()
+	delete(samplers, family)
+}
+
+var (
+	samplersMu sync.RWMutex
+	samplers   = make(map[string]*sampler) // family -> sampler
+)
+
+func getSampler(fam string) *sampler {
+	samplersMu.RLock()
+	defer samplersMu.RUnlock()
+	return samplers[fam]
+}
+
+// sampler applies a SamplingPolicy to the traces of a family.
+type sampler struct {
+	policy SamplingPolicy
+
+	mu          sync.Mutex
+	count       int       // completed traces considered for sampling
+	windowStart time.Time // start of the current rate limit window
+	windowCount int       // sampled traces retained in the current window
+}
+
+// maxEvents returns the maximum number of events per trace,
+// given the requested maximum m.
+func (s *sampler) maxEvents(m int) int {
+	if s == nil || s.policy.MaxEvents <= 0 {
+		return m
+	}
+	// Always keep at least three events: first, discarded count, last.
+	limit := s.policy.MaxEvents
+	if limit < 3 {
+		limit = 3
+	}
+	if m > limit {
+		return limit
+	}
+	return m
+}
+
+// retain reports whether a completed trace should be retained.
+// L >= tr.mu
+func (s *sampler) retain(tr *trace, now time.Time) bool {
+	if s == nil || tr.IsError {
+		return true
+	}
+	if s.policy.SlowThreshold > 0 && tr.Elapsed >= s.policy.SlowThreshold {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.count++
+	if s.policy.Rate > 1 && s.count%s.policy.Rate != 1 {
+		return false
+	}
+	if s.policy.MaxPerSecond > 0 {
+		if now.Sub(s.windowStart) >= time.Second {
+			s.windowStart = now
+			s.windowCount = 0
+		}
+		if s.windowCount >= s.policy.MaxPerSecond {
+			return false
+		}
+		s.windowCount++
+	}
+	return true
+}
@@ -363,7 +363,8 @@ func New(family, title string) Trace {
 	tr.ref()
 	tr.Family, tr.Title = family, title
 	tr.Start = time.Now()
-	tr.maxEvents = maxEventsPerTrace
+	tr.sampler = getSampler(family)
+	tr.maxEvents = tr.sampler.maxEvents(maxEventsPerTrace)
 	tr.events = tr.eventsBuf[:0]
 
 	activeMu.RLock()
@@ -412,10 +413,12 @@ func (tr *trace) Finish() {
 	m.Remove(tr)
 
 	f := getFamily(tr.Family, true)
-	tr.mu.RLock() // protects tr fields in Cond.match calls
-	for _, b := range f.Buckets {
-		if b.Cond.match(tr) {
-			b.Add(tr)
+	tr.mu.RLock() // protects tr fields in Cond.match and sampler.retain calls
+	if tr.sampler.retain(tr, tr.Start.Add(elapsed)) {
+		for _, b := range f.Buckets {
+			if b.Cond.match(tr) {
+				b.Add(tr)
+			}
 		}
 	}
 	tr.mu.RUnlock()
@@ -725,6 +728,7 @@ type trace struct {
 	events    []event // Append-only sequence of events (modulo discards).
 	maxEvents int
 	recycler  func(interface{})
+	sampler   *sampler      // sampling policy for the family, or nil
 	IsError   bool          // Whether this trace resulted in an error.
 	Elapsed   time.Duration // Elapsed time for this trace, zero while active.
 	traceID   uint64        // Trace information if non-zero.
@@ -752,6 +756,7 @@ func (tr *trace) reset() {
 	tr.maxEvents = 0
 	tr.events = nil
 	tr.recycler = nil
+	tr.sampler = nil
 	tr.mu.Unlock()
 
 	tr.refs = 0
@@ -856,7 +861,7 @@ func (tr *trace) SetMaxEvents(m int) {
 	tr.mu.Lock()
 	// Always keep at least three events: first, discarded count, last.
 	if len(tr.events) == 0 && m > 3 {
-		tr.maxEvents = m
+		tr.maxEvents = tr.sampler.maxEvents(m)
 	}
 	tr.mu.Unlock()
 }
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"reflect"
 	"testing"
+	"time"
 )
 
 type s struct{}
@@ -176,3 +177,60 @@ func BenchmarkTrace_1000_1000(b *testing.B) {
 func BenchmarkTrace_1000_10000(b *testing.B) {
 	benchmarkTrace(b, 1000, 10000)
 }
+
+func TestSamplingPolicy(t *testing.T) {
+	const fam = "TestSamplingPolicy"
+	SetSamplingPolicy(fam, SamplingPolicy{
+		Rate:          3,
+		SlowThreshold: time.Hour,
+		MaxEvents:     5,
+	})
+	defer ClearSamplingPolicy(fam)
+	// Discard the family's traces, so the test may be run again.
+	t.Cleanup(func() {
+		completedMu.Lock()
+		delete(completedTraces, fam)
+		completedMu.Unlock()
+	})
+
+	for i := 0; i < 9; i++ {
+		tr := New(fam, "ok")
+		tr.SetMaxEvents(100)
+		if got, want := tr.(*trace).maxEvents, 5; got != want {
+			t.Errorf("maxEvents = %v, want %v", got, want)
+		}
+		tr.Finish()
+	}
+	tr := New(fam, "error")
+	tr.SetError()
+	tr.Finish()
+
+	f := getFamily(fam, false)
+	all := f.Buckets[0].Copy(false)
+	defer all.Free()
+	if got, want := len(all), 3+1; got != want {
+		t.Errorf("retained %v traces, want %v", got, want)
+	}
+	errs := f.Buckets[bucketsPerFamily-1].Copy(false)
+	defer errs.Free()
+	if got, want := len(errs), 1; got != want {
+		t.Errorf("retained %v error traces, want %v", got, want)
+	}
+}
+
+func TestSamplingPolicyMaxPerSecond(t *testing.T) {
+	s := &sampler{policy: SamplingPolicy{MaxPerSecond: 2}}
+	tr := &trace{}
+	now := time.Now()
+	var got []bool
+	for _, d := range []time.Duration{0, 0, 0, time.Second, time.Second} {
+		got = append(got, s.retain(tr, now.Add(d)))
+	}
+	if want := []bool{true, true, false, true, true}; !reflect.DeepEqual(got, want) {
+		t.Errorf("retain = %v, want %v", got, want)
+	}
+	tr.IsError = true
+	if !s.retain(tr, now.Add(time.Second)) {
+		t.Errorf("error trace not retained after rate limit exceeded")
+	}
+}
@@ -445,6 +445,19 @@ func (n *memFSNode) DeadProps() (map[xml.Name]Property, error) {
 	return ret, nil
 }
 
+func (n *memFSNode) DeadPropNames() ([]xml.Name, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if len(n.deadProps) == 0 {
+		return nil, nil
+	}
+	ret := make([]xml.Name, 0, len(n.deadProps))
+	for k := range n.deadProps {
+		ret = append(ret, k)
+	}
+	return ret, nil
+}
+
 func (n *memFSNode) Patch(patches []Proppatch) ([]Propstat, error) {
 	n.mu.Lock()
 	defer n.mu.Unlock()
@@ -490,10 +503,15 @@ type memFile struct {
 	pos int
 }
 
-// A *memFile implements the optional DeadPropsHolder interface.
-var _ DeadPropsHolder = (*memFile)(nil)
+// A *memFile implements the optional DeadPropsHolder and DeadPropNameLister
+// interfaces.
+var (
+	_ DeadPropsHolder    = (*memFile)(nil)
+	_ DeadPropNameLister = (*memFile)(nil)
+)
 
 func (f *memFile) DeadProps() (map[xml.Name]Property, error)     { return f.n.DeadProps() }
+func (f *memFile) DeadPropNames() ([]xml.Name, error)            { return f.n.DeadPropNames() }
 func (f *memFile) Patch(patches []Proppatch) ([]Propstat, error) { return f.n.Patch(patches) }
 
 func (f *memFile) Close() error {
@@ -99,6 +99,40 @@ type DeadPropsHolder interface {
 	Patch([]Proppatch) ([]Propstat, error)
 }
 
+// DeadPropNameLister may optionally be implemented by a File which implements
+// DeadPropsHolder, to list the names of its dead properties without
+// retrieving their values. It is used to answer propname requests.
+type DeadPropNameLister interface {
+	// DeadPropNames returns the names of the dead properties held.
+	DeadPropNames() ([]xml.Name, error)
+}
+
+// A PropPolicy controls which properties a Handler reports in response to
+// PROPFIND requests. It permits servers to avoid computing properties which
+// are expensive or which clients should not see.
+type PropPolicy struct {
+	// Allprop, if non-nil, reports whether the property named pname is
+	// returned in response to an allprop request. live reports whether
+	// pname is a live property. Properties excluded from allprop are still
+	// returned when requested by name or listed in an allprop request's
+	// include element.
+	Allprop func(pname xml.Name, live bool) bool
+
+	// Access, if non-nil, reports whether the property named pname of the
+	// resource name may be reported. Properties for which Access returns false
+	// are not computed, are omitted from propname and allprop responses,
+	// and are reported as not found when requested by name.
+	Access func(ctx context.Context, name string, pname xml.Name) bool
+}
+
+func (p *PropPolicy) allprop(pname xml.Name, live bool) bool {
+	return p == nil || p.Allprop == nil || p.Allprop(pname, live)
+}
+
+func (p *PropPolicy) access(ctx context.Context, name string, pname xml.Name) bool {
+	return p == nil || p.Access == nil || p.Access(ctx, name, pname)
+}
+
 // liveProps contains all supported, protected DAV: properties.
 var liveProps = map[xml.Name]struct {
 	// findFn implements the propfind function of this property. If nil,
@@ -166,7 +200,7 @@ var liveProps = map[xml.Name]struct {
 //
 // Each Propstat has a unique status and each property name will only be part
 // of one Propstat element.
-func props(ctx context.Context, fs FileSystem, ls LockSystem, name string, pnames []xml.Name) ([]Propstat, error) {
+func props(ctx context.Context, fs FileSystem, ls LockSystem, name string, pnames []xml.Name, policy *PropPolicy) ([]Propstat, error) {
 	f, err := fs.OpenFile(ctx, name, os.O_RDONLY, 0)
 	if err != nil {
 		return nil, err
@@ -189,6 +223,12 @@ func props(ctx context.Context, fs FileSystem, ls LockSystem, name string, pname
 	pstatOK := Propstat{Status: http.StatusOK}
 	pstatNotFound := Propstat{Status: http.StatusNotFound}
 	for _, pn := range pnames {
+		if !policy.access(ctx, name, pn) {
+			pstatNotFound.Props = append(pstatNotFound.Props, Property{
+				XMLName: pn,
+			})
+			continue
+		}
 		// If this file has dead properties, check if they contain pn.
 		if dp, ok := deadProps[pn]; ok {
 			pstatOK.Props = append(pstatOK.Props, dp)
@@ -214,36 +254,55 @@ func props(ctx context.Context, fs FileSystem, ls LockSystem, name string, pname
 }
 
 // propnames returns the property names defined for resource name.
-func propnames(ctx context.Context, fs FileSystem, ls LockSystem, name string) ([]xml.Name, error) {
+func propnames(ctx context.Context, fs FileSystem, ls LockSystem, name string, policy *PropPolicy) ([]xml.Name, error) {
+	pnames, _, err := propnamesLive(ctx, fs, ls, name, policy)
+	return pnames, err
+}
+
+// propnamesLive returns the property names defined for resource name,
+// and the number of those names (at the start of the returned slice)
+// which are live properties.
+func propnamesLive(ctx context.Context, fs FileSystem, ls LockSystem, name string, policy *PropPolicy) (pnames []xml.Name, nlive int, err error) {
 	f, err := fs.OpenFile(ctx, name, os.O_RDONLY, 0)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	defer f.Close()
 	fi, err := f.Stat()
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	isDir := fi.IsDir()
 
-	var deadProps map[xml.Name]Property
-	if dph, ok := f.(DeadPropsHolder); ok {
-		deadProps, err = dph.DeadProps()
+	var deadNames []xml.Name
+	if dpl, ok := f.(DeadPropNameLister); ok {
+		deadNames, err = dpl.DeadPropNames()
 		if err != nil {
-			return nil, err
+			return nil, 0, err
+		}
+	} else if dph, ok := f.(DeadPropsHolder); ok {
+		deadProps, err := dph.DeadProps()
+		if err != nil {
+			return nil, 0, err
+		}
+		for pn := range deadProps {
+			deadNames = append(deadNames, pn)
 		}
 	}
 
-	pnames := make([]xml.Name, 0, len(liveProps)+len(deadProps))
+	pnames = make([]xml.Name, 0, len(liveProps)+len(deadNames))
 	for pn, prop := range liveProps {
-		if prop.findFn != nil && (prop.dir || !isDir) {
+		if prop.findFn != nil && (prop.dir || !isDir) && policy.access(ctx, name, pn) {
 			pnames = append(pnames, pn)
 		}
 	}
-	for pn := range deadProps {
-		pnames = append(pnames, pn)
+	nlive = len(pnames)
+	for _, pn := range deadNames {
+		if policy.access(ctx, name, pn) {
+			pnames = append(pnames, pn)
+		}
 	}
-	return pnames, nil
+	return pnames, nlive, nil
 }
 
 // allprop returns the properties defined for resource name and the properties
@@ -254,11 +313,17 @@ func propnames(ctx context.Context, fs FileSystem, ls LockSystem, name string) (
 // returned if they are named in 'include'.
 //
 // See http://www.webdav.org/specs/rfc4918.html#METHOD_PROPFIND
-func allprop(ctx context.Context, fs FileSystem, ls LockSystem, name string, include []xml.Name) ([]Propstat, error) {
-	pnames, err := propnames(ctx, fs, ls, name)
+func allprop(ctx context.Context, fs FileSystem, ls LockSystem, name string, include []xml.Name, policy *PropPolicy) ([]Propstat, error) {
+	all, nlive, err := propnamesLive(ctx, fs, ls, name, policy)
 	if err != nil {
 		return nil, err
 	}
+	pnames := all[:0]
+	for i, pn := range all {
+		if policy.allprop(pn, i < nlive) {
+			pnames = append(pnames, pn)
+		}
+	}
 	// Add names from include if they are not already covered in pnames.
 	nameset := make(map[xml.Name]bool)
 	for _, pn := range pnames {
@@ -269,7 +334,7 @@ func allprop(ctx context.Context, fs FileSystem, ls LockSystem, name string, inc
 			pnames = append(pnames, pn)
 		}
 	}
-	return props(ctx, fs, ls, name, pnames)
+	return props(ctx, fs, ls, name, pnames, policy)
 }
 
 // patch patches the properties of resource name. The return values are
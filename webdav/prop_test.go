@@ -522,7 +522,7 @@ func TestMemPS(t *testing.T) {
 			var propstats []Propstat
 			switch op.op {
 			case "propname":
-				pnames, err := propnames(ctx, fs, ls, op.name)
+				pnames, err := propnames(ctx, fs, ls, op.name, nil)
 				if err != nil {
 					t.Errorf("%s: got error %v, want nil", desc, err)
 					continue
@@ -534,9 +534,9 @@ func TestMemPS(t *testing.T) {
 				}
 				continue
 			case "allprop":
-				propstats, err = allprop(ctx, fs, ls, op.name, op.pnames)
+				propstats, err = allprop(ctx, fs, ls, op.name, op.pnames, nil)
 			case "propfind":
-				propstats, err = props(ctx, fs, ls, op.name, op.pnames)
+				propstats, err = props(ctx, fs, ls, op.name, op.pnames, nil)
 			case "proppatch":
 				propstats, err = patch(ctx, fs, ls, op.name, op.patches)
 			default:
@@ -569,6 +569,80 @@ func cmpXMLName(a, b xml.Name) bool {
 	return a.Local < b.Local
 }
 
+func TestPropPolicy(t *testing.T) {
+	ctx := context.Background()
+	fs, err := buildTestFS([]string{"touch /file"})
+	if err != nil {
+		t.Fatalf("cannot create test filesystem: %v", err)
+	}
+	ls := NewMemLS()
+	deadProp := Property{
+		XMLName:  xml.Name{Space: "foo", Local: "bar"},
+		InnerXML: []byte("baz"),
+	}
+	if _, err := patch(ctx, fs, ls, "/file", []Proppatch{{Props: []Property{deadProp}}}); err != nil {
+		t.Fatalf("patch: %v", err)
+	}
+	etag := xml.Name{Space: "DAV:", Local: "getetag"}
+	length := xml.Name{Space: "DAV:", Local: "getcontentlength"}
+	policy := &PropPolicy{
+		Allprop: func(pname xml.Name, live bool) bool {
+			return !live || pname == length
+		},
+		Access: func(ctx context.Context, name string, pname xml.Name) bool {
+			if name != "/file" {
+				t.Errorf("Access called with name %q, want /file", name)
+			}
+			return pname != etag
+		},
+	}
+
+	pnames, err := propnames(ctx, fs, ls, "/file", policy)
+	if err != nil {
+		t.Fatalf("propnames: %v", err)
+	}
+	for _, pn := range pnames {
+		if pn == etag {
+			t.Errorf("propnames returned %v, which Access denies", etag)
+		}
+	}
+
+	// Allprop excludes live properties other than getcontentlength,
+	// but include can still name them.
+	displayName := xml.Name{Space: "DAV:", Local: "displayname"}
+	pstats, err := allprop(ctx, fs, ls, "/file", []xml.Name{displayName}, policy)
+	if err != nil {
+		t.Fatalf("allprop: %v", err)
+	}
+	wantPropstats := []Propstat{{
+		Status: http.StatusOK,
+		Props: []Property{
+			{XMLName: displayName, InnerXML: []byte("file")},
+			{XMLName: length, InnerXML: []byte("0")},
+			deadProp,
+		},
+	}}
+	for _, pst := range pstats {
+		sort.Sort(byPropname(pst.Props))
+	}
+	if !reflect.DeepEqual(pstats, wantPropstats) {
+		t.Errorf("allprop:\ngot  %q\nwant %q", pstats, wantPropstats)
+	}
+
+	// Properties denied by Access are not found when requested by name.
+	pstats, err = props(ctx, fs, ls, "/file", []xml.Name{etag}, policy)
+	if err != nil {
+		t.Fatalf("props: %v", err)
+	}
+	wantPropstats = []Propstat{{
+		Status: http.StatusNotFound,
+		Props:  []Property{{XMLName: etag}},
+	}}
+	if !reflect.DeepEqual(pstats, wantPropstats) {
+		t.Errorf("props:\ngot  %q\nwant %q", pstats, wantPropstats)
+	}
+}
+
 type byXMLName []xml.Name
 
 func (b byXMLName) Len() int           { return len(b) }
@@ -28,6 +28,9 @@ type Handler struct {
 	// Logger is an optional error logger. If non-nil, it will be called
 	// for all HTTP requests.
 	Logger func(*http.Request, error)
+	// PropPolicy optionally controls which properties are reported in
+	// response to PROPFIND requests. If nil, all properties are reported.
+	PropPolicy *PropPolicy
 }
 
 func (h *Handler) stripPrefix(p string) (string, int, error) {
@@ -541,7 +544,7 @@ func (h *Handler) handlePropfind(w http.ResponseWriter, r *http.Request) (status
 
 		var pstats []Propstat
 		if pf.Propname != nil {
-			pnames, err := propnames(ctx, h.FileSystem, h.LockSystem, reqPath)
+			pnames, err := propnames(ctx, h.FileSystem, h.LockSystem, reqPath, h.PropPolicy)
 			if err != nil {
 				return handlePropfindError(err, info)
 			}
@@ -551,9 +554,9 @@ func (h *Handler) handlePropfind(w http.ResponseWriter, r *http.Request) (status
 			}
 			pstats = append(pstats, pstat)
 		} else if pf.Allprop != nil {
-			pstats, err = allprop(ctx, h.FileSystem, h.LockSystem, reqPath, pf.Prop)
+			pstats, err = allprop(ctx, h.FileSystem, h.LockSystem, reqPath, pf.Prop, h.PropPolicy)
 		} else {
-			pstats, err = props(ctx, h.FileSystem, h.LockSystem, reqPath, pf.Prop)
+			pstats, err = props(ctx, h.FileSystem, h.LockSystem, reqPath, pf.Prop, h.PropPolicy)
 		}
 		if err != nil {
 			return handlePropfindError(err, info)
@@ -9,6 +9,8 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strings"
 )
 
 func newServerConn(rwc io.ReadWriteCloser, buf *bufio.ReadWriter, req *http.Request, config *Config, handshake func(*Config, *http.Request) error) (conn *Conn, err error) {
@@ -61,6 +63,15 @@ type Server struct {
 	// Another example, you can select config.Protocol.
 	Handshake func(*Config, *http.Request) error
 
+	// CheckOrigin is an optional function which decides whether to accept
+	// a handshake request based on its Origin header.
+	// It is called before Handshake with the parsed origin,
+	// which is nil if the request has no Origin header.
+	// If CheckOrigin returns false, the handshake is rejected
+	// with 403 Forbidden, and config.Origin is set to origin otherwise.
+	// See SameOrigin for a policy suitable for most browser-facing servers.
+	CheckOrigin func(origin *url.URL, req *http.Request) bool
+
 	// Handler handles a WebSocket connection.
 	Handler
 }
@@ -79,7 +90,24 @@ func (s Server) serveWebSocket(w http.ResponseWriter, req *http.Request) {
 	// the client did not send a handshake that matches with protocol
 	// specification.
 	defer rwc.Close()
-	conn, err := newServerConn(rwc, buf, req, &s.Config, s.Handshake)
+	handshake := s.Handshake
+	if s.CheckOrigin != nil {
+		handshake = func(config *Config, req *http.Request) error {
+			origin, err := Origin(config, req)
+			if err != nil {
+				return err
+			}
+			if !s.CheckOrigin(origin, req) {
+				return ErrBadWebSocketOrigin
+			}
+			config.Origin = origin
+			if s.Handshake != nil {
+				return s.Handshake(config, req)
+			}
+			return nil
+		}
+	}
+	conn, err := newServerConn(rwc, buf, req, &s.Config, handshake)
 	if err != nil {
 		return
 	}
@@ -106,6 +134,14 @@ func checkOrigin(config *Config, req *http.Request) (err error) {
 	return err
 }
 
+// SameOrigin reports whether origin has the same host as the request,
+// as browsers set it for pages served by the same site.
+// It rejects requests without an Origin header.
+// It may be used as Server.CheckOrigin.
+func SameOrigin(origin *url.URL, req *http.Request) bool {
+	return origin != nil && strings.EqualFold(origin.Host, req.Host)
+}
+
 // ServeHTTP implements the http.Handler interface for a WebSocket
 func (h Handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	s := Server{Handler: h, Handshake: checkOrigin}
@@ -66,7 +66,8 @@ var (
 )
 
 // ErrFrameTooLarge is returned by Codec's Receive method if payload size
-// exceeds limit set by Conn.MaxPayloadBytes
+// exceeds limit set by Conn.MaxPayloadBytes, and by Conn's Read method
+// if payload size exceeds limit set by Conn.MaxReadFrameBytes.
 var ErrFrameTooLarge = errors.New("websocket: frame payload size exceeds limit")
 
 // Addr is an implementation of net.Addr for WebSocket.
@@ -170,6 +171,7 @@ type Conn struct {
 	rio sync.Mutex
 	frameReaderFactory
 	frameReader
+	readErr error // returned by all Reads after an oversized frame
 
 	wio sync.Mutex
 	frameWriterFactory
@@ -181,6 +183,29 @@ type Conn struct {
 	// MaxPayloadBytes limits the size of frame payload received over Conn
 	// by Codec's Receive method. If zero, DefaultMaxPayloadBytes is used.
 	MaxPayloadBytes int
+
+	// MaxReadFrameBytes limits the size of frame payload received over Conn
+	// by Read. If zero, frames read by Read are not limited.
+	//
+	// When a frame exceeds the limit, Read sends a close frame with
+	// status 1009 (message too big), closes the connection without
+	// reading the rest of the frame, and returns ErrFrameTooLarge.
+	MaxReadFrameBytes int
+}
+
+// receiveLimit returns the payload size limit for frames read by Codec's
+// Receive method.
+func (ws *Conn) receiveLimit() int {
+	if ws.MaxPayloadBytes == 0 {
+		return DefaultMaxPayloadBytes
+	}
+	return ws.MaxPayloadBytes
+}
+
+// payloadTooLarge reports whether frame's payload exceeds limit.
+func payloadTooLarge(frame frameReader, limit int) bool {
+	hf, ok := frame.(*hybiFrameReader)
+	return ok && hf.header.Length > int64(limit)
 }
 
 // Read implements the io.Reader interface:
@@ -191,6 +216,9 @@ type Conn struct {
 func (ws *Conn) Read(msg []byte) (n int, err error) {
 	ws.rio.Lock()
 	defer ws.rio.Unlock()
+	if ws.readErr != nil {
+		return 0, ws.readErr
+	}
 again:
 	if ws.frameReader == nil {
 		frame, err := ws.frameReaderFactory.NewFrameReader()
@@ -204,6 +232,14 @@ again:
 		if ws.frameReader == nil {
 			goto again
 		}
+		if ws.MaxReadFrameBytes > 0 && payloadTooLarge(ws.frameReader, ws.MaxReadFrameBytes) {
+			// Don't wait for the peer to send the rest of the frame.
+			ws.frameReader = nil
+			ws.frameHandler.WriteClose(closeStatusTooBigData)
+			ws.rwc.Close()
+			ws.readErr = ErrFrameTooLarge
+			return 0, ErrFrameTooLarge
+		}
 	}
 	n, err = ws.frameReader.Read(msg)
 	if err == io.EOF {
@@ -348,11 +384,7 @@ again:
 	if frame == nil {
 		goto again
 	}
-	maxPayloadBytes := ws.MaxPayloadBytes
-	if maxPayloadBytes == 0 {
-		maxPayloadBytes = DefaultMaxPayloadBytes
-	}
-	if hf, ok := frame.(*hybiFrameReader); ok && hf.header.Length > int64(maxPayloadBytes) {
+	if payloadTooLarge(frame, ws.receiveLimit()) {
 		// payload size exceeds limit, no need to call Unmarshal
 		//
 		// set frameReader to current oversized frame so that
@@ -663,3 +663,110 @@ func TestCodec_ReceiveLimited(t *testing.T) {
 	}
 	<-handlerDone
 }
+
+func TestConn_ReadLimited(t *testing.T) {
+	const limit = 16
+	handlerDone := make(chan struct{})
+	limitedHandler := func(ws *Conn) {
+		defer close(handlerDone)
+		defer ws.Close()
+		b := make([]byte, 4*limit)
+		// MaxPayloadBytes applies only to Codec's Receive method.
+		ws.MaxPayloadBytes = limit
+		n, err := ws.Read(b)
+		if err != nil || n != 2*limit {
+			t.Errorf("ws.Read() of %v byte frame with MaxPayloadBytes = %v: %v, %v; want %v, nil", 2*limit, limit, n, err, 2*limit)
+			return
+		}
+		ws.MaxReadFrameBytes = limit
+		n, err = ws.Read(b)
+		if err != nil || n != limit {
+			t.Errorf("ws.Read() of %v byte frame = %v, %v; want %v, nil", limit, n, err, limit)
+			return
+		}
+		if _, err := ws.Read(b); err != ErrFrameTooLarge {
+			t.Errorf("ws.Read() of %v byte frame = %v, want ErrFrameTooLarge", 2*limit, err)
+			return
+		}
+		// The connection has been closed.
+		if _, err := ws.Read(b); err != ErrFrameTooLarge {
+			t.Errorf("ws.Read() after oversized frame = %v, want ErrFrameTooLarge", err)
+		}
+	}
+	server := httptest.NewServer(Handler(limitedHandler))
+	defer server.CloseClientConnections()
+	defer server.Close()
+	addr := server.Listener.Addr().String()
+	ws, err := Dial("ws://"+addr+"/", "", "http://localhost/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	for _, size := range []int{2 * limit, limit, 2 * limit} {
+		if _, err := ws.Write(make([]byte, size)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-handlerDone
+	if _, err := ws.Read(make([]byte, 1)); err == nil {
+		t.Errorf("client ws.Read() after oversized frame succeeded, want error")
+	}
+}
+
+func TestServer_CheckOrigin(t *testing.T) {
+	server := httptest.NewServer(Server{
+		CheckOrigin: SameOrigin,
+		Handler:     echoServer,
+	})
+	defer server.Close()
+	addr := server.Listener.Addr().String()
+
+	ws, err := Dial("ws://"+addr+"/", "", "http://"+addr)
+	if err != nil {
+		t.Fatalf("Dial with same origin: %v", err)
+	}
+	ws.Close()
+
+	for _, origin := range []string{
+		"http://evil.example/",
+		"", // no Origin header
+	} {
+		config, err := NewConfig("ws://"+addr+"/", "http://localhost/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if origin == "" {
+			config.Origin = nil
+		} else if config.Origin, err = url.ParseRequestURI(origin); err != nil {
+			t.Fatal(err)
+		}
+		if ws, err := DialConfig(config); err == nil {
+			ws.Close()
+			t.Errorf("Dial with origin %q succeeded, want error", origin)
+		}
+	}
+}
+
+func TestSameOrigin(t *testing.T) {
+	req := &http.Request{Host: "example.com:8080"}
+	for _, test := range []struct {
+		origin string
+		want   bool
+	}{
+		{"http://example.com:8080", true},
+		{"https://EXAMPLE.com:8080", true},
+		{"http://example.com", false},
+		{"http://evil.example:8080", false},
+	} {
+		origin, err := url.ParseRequestURI(test.origin)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := SameOrigin(origin, req); got != test.want {
+			t.Errorf("SameOrigin(%q, %q) = %v, want %v", test.origin, req.Host, got, test.want)
+		}
+	}
+	if SameOrigin(nil, req) {
+		t.Errorf("SameOrigin(nil, %q) = true, want false", req.Host)
+	}
+}